@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
+	"log"
 	"os"
+	"time"
 
 	"github.com/SawitProRecruitment/UserService/generated"
 	"github.com/SawitProRecruitment/UserService/handler"
 	"github.com/SawitProRecruitment/UserService/repository"
 	"github.com/SawitProRecruitment/UserService/usecase"
+	"github.com/SawitProRecruitment/UserService/utils"
 
 	"github.com/labstack/echo/v4"
 )
@@ -14,24 +18,79 @@ import (
 func main() {
 	e := echo.New()
 
-	var server generated.ServerInterface = newServer()
+	storage := utils.NewObjectStorageFromEnv()
+	server := newServer(storage)
+	e.HTTPErrorHandler = server.HandleError
+
+	// the local storage has no server of its own, its files are served here
+	if local, ok := storage.(*utils.LocalObjectStorage); ok {
+		e.Static(local.Path(), local.Dir)
+	}
+
+	router, err := handler.NewPermissionRouter(e, server)
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+
+	generated.RegisterHandlers(router, server)
+
+	go purgeExpiredData(server.Usecase, time.Duration(utils.GetEnvInt("ACCOUNT_PURGE_INTERVAL_MINUTES", 60))*time.Minute)
 
-	generated.RegisterHandlers(e, server)
 	e.Logger.Fatal(e.Start(":1323"))
 }
 
-func newServer() *handler.Server {
+// purgeExpiredData anonymizes the accounts whose deletion grace period is
+// over and removes the data exports that expired, right away and then every
+// interval. Running it on several instances at once is safe.
+func purgeExpiredData(uc usecase.UsecaseInterface, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purgeDeletedUsersOnce(uc)
+		purgeDataExportsOnce(uc)
+		<-ticker.C
+	}
+}
+
+func purgeDeletedUsersOnce(uc usecase.UsecaseInterface) {
+	output, err := uc.PurgeDeletedUsers(context.Background(), usecase.PurgeDeletedUsersInput{})
+	if err != nil {
+		log.Println("[ERROR][purgeDeletedUsers] error when PurgeDeletedUsers", err)
+		return
+	}
+
+	if output.Count > 0 {
+		log.Printf("[INFO][purgeDeletedUsers] purged %d deleted users", output.Count)
+	}
+}
+
+func purgeDataExportsOnce(uc usecase.UsecaseInterface) {
+	output, err := uc.PurgeDataExports(context.Background(), usecase.PurgeDataExportsInput{})
+	if err != nil {
+		log.Println("[ERROR][purgeDataExports] error when PurgeDataExports", err)
+		return
+	}
+
+	if output.Count > 0 {
+		log.Printf("[INFO][purgeDataExports] removed %d expired data exports", output.Count)
+	}
+}
+
+func newServer(storage utils.ObjectStorage) *handler.Server {
 	dbDsn := os.Getenv("DATABASE_URL")
 	var repo repository.RepositoryInterface = repository.NewRepository(repository.NewRepositoryOptions{
 		Dsn: dbDsn,
 	})
 
 	var usecase usecase.UsecaseInterface = usecase.NewUsecase(usecase.NewUsecaseOptions{
-		Repository: repo,
+		Repository:    repo,
+		ObjectStorage: storage,
 	})
 
 	opts := handler.NewServerOptions{
-		Usecase: usecase,
+		Usecase:                     usecase,
+		RegistrationAntiEnumeration: utils.GetEnvBool("REGISTRATION_ANTI_ENUMERATION", false),
 	}
 
 	return handler.NewServer(opts)
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"errors"
 	"os"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SawitProRecruitment/UserService/usecase"
+	"github.com/SawitProRecruitment/UserService/utils"
+	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,7 +20,70 @@ func Test_newServer(t *testing.T) {
 	defer db.Close()
 	os.Setenv("DATABASE_URL", "sqlmock_db_0")
 
-	got := newServer()
+	got := newServer(&utils.LocalObjectStorage{
+		Dir:     t.TempDir(),
+		BaseURL: "http://localhost:1323/storage",
+	})
 
 	assert.NotNil(t, got)
 }
+
+func Test_purgeDeletedUsersOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	tests := []struct {
+		name     string
+		mockFunc func()
+	}{
+		{
+			name: "error when PurgeDeletedUsers",
+			mockFunc: func() {
+				mockUsecase.EXPECT().PurgeDeletedUsers(gomock.Any(), gomock.Any()).Return(usecase.PurgeDeletedUsersOutput{}, errors.New("test"))
+			},
+		},
+		{
+			name: "success",
+			mockFunc: func() {
+				mockUsecase.EXPECT().PurgeDeletedUsers(gomock.Any(), gomock.Eq(usecase.PurgeDeletedUsersInput{})).Return(usecase.PurgeDeletedUsersOutput{Count: 2}, nil)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc()
+			purgeDeletedUsersOnce(mockUsecase)
+		})
+	}
+}
+
+func Test_purgeDataExportsOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	tests := []struct {
+		name     string
+		mockFunc func()
+	}{
+		{
+			name: "error when PurgeDataExports",
+			mockFunc: func() {
+				mockUsecase.EXPECT().PurgeDataExports(gomock.Any(), gomock.Any()).Return(usecase.PurgeDataExportsOutput{}, errors.New("test"))
+			},
+		},
+		{
+			name: "success",
+			mockFunc: func() {
+				mockUsecase.EXPECT().PurgeDataExports(gomock.Any(), gomock.Eq(usecase.PurgeDataExportsInput{})).Return(usecase.PurgeDataExportsOutput{Count: 2}, nil)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc()
+			purgeDataExportsOnce(mockUsecase)
+		})
+	}
+}
@@ -18,13 +18,218 @@ INSERT INTO test (name) VALUES ('test2');
 
 CREATE TABLE users (
   id serial primary key,
-  phone_number VARCHAR(13) UNIQUE NOT NULL,
-  full_name VARCHAR(60) NOT NULL,
-  password VARCHAR(256) NOT NULL,
+  -- up to 60 grapheme clusters of at most 4 code points each, see utils.FULLNAME_MAX_LENGTH
+  full_name VARCHAR(240) NOT NULL,
+  -- null for users created through an external login provider, they have
+  -- no password to log in with
+  password VARCHAR(256),
+  password_changed_at timestamptz not null default now(),
+  user_group VARCHAR(32) not null default 'default',
+  -- preferred language of messages, null to follow Accept-Language
+  language VARCHAR(8),
   total_login int not null default 0,
-  created_at timestamptz default now(),
+  -- only active users can log in and use their tokens. Pending users wait
+  -- for an admin to activate them, deactivated users reactivate themselves
+  -- by logging in. See usecase.userStatusTransitions
+  status VARCHAR(16) not null default 'active',
+  -- set when the user deleted their own account, until then logging in
+  -- offers to restore it. Afterwards the account is anonymized and this is
+  -- null again, see usecase.PurgeDeletedUsers
+  purge_at timestamptz,
+  -- the objects of the avatar are stored below this key, null without an
+  -- avatar, see usecase.SetAvatar
+  avatar_key VARCHAR(128),
+  -- custom profile attributes, a JSON object matching the latest
+  -- attribute_schemas when it was written
+  attributes jsonb not null default '{}',
+  created_at timestamptz not null default now(),
   updated_at timestamptz,
-  updated_by int
+  updated_by int,
+  check (status in ('pending', 'active', 'suspended', 'deactivated', 'deleted'))
+);
+
+-- the orders of the admin user listing, the id breaks ties so that the
+-- listing can continue after the last user of a page
+create index users_created_at on users(created_at, id);
+create index users_full_name on users(full_name, id);
+create index users_purge_at on users(purge_at) where purge_at is not null;
+
+-- the ways a user can be identified at login. Every user has at most one
+-- primary phone number and one primary email, those are the ones shown on the
+-- profile. Users that registered with a password always have a primary phone
+-- number, users created through an external login provider until they add one
+CREATE TABLE identities (
+  id serial primary key,
+  user_id int not null references users(id) on delete cascade,
+  -- phone, email or the name of an external login provider or directory
+  type VARCHAR(32) not null,
+  -- a phone number in E.164 format (see utils.NormalizePhoneNumber), an email
+  -- address or the subject at the external login provider or directory
+  identifier VARCHAR(254) not null,
+  is_primary boolean not null default false,
+  -- null until the user proved control of the identifier. Phone numbers can
+  -- not be verified yet and are trusted as given, external logins are
+  -- verified by the provider
+  verified_at timestamptz,
+  created_at timestamptz not null default now(),
+  check (type <> 'phone' or identifier ~ '^\+[1-9][0-9]{6,14}$')
+);
+
+-- emails are unique ignoring case, everything else as given. The index names
+-- tell the conflicts apart, see repository.EMAIL_UNIQUE_INDEX
+create unique index identities_identifier on identities(type, identifier) where type <> 'email';
+create unique index identities_email on identities(lower(identifier)) where type = 'email';
+create unique index identities_primary on identities(user_id, type) where is_primary;
+create index identities_user_id on identities(user_id);
+
+-- pending verification of an email identity
+CREATE TABLE email_verifications (
+  id serial primary key,
+  identity_id int unique not null references identities(id) on delete cascade,
+  -- the address the verification was sent to, a later change of the identity invalidates it
+  email VARCHAR(254) not null,
+  -- sha256 hex of the link token and of the code, both are only sent by email
+  token_hash CHAR(64) unique not null,
+  code_hash CHAR(64) not null,
+  attempts int not null default 0,
+  expires_at timestamptz not null,
+  created_at timestamptz not null default now()
+);
+
+CREATE TABLE password_history (
+  id serial primary key,
+  user_id int not null references users(id) on delete cascade,
+  password VARCHAR(256) NOT NULL,
+  created_at timestamptz not null default now()
+);
+
+create index password_history_user_id_created_at on password_history(user_id, created_at desc);
+
+-- audit trail of users.status
+CREATE TABLE user_status_changes (
+  id serial primary key,
+  user_id int not null references users(id) on delete cascade,
+  from_status VARCHAR(16) not null,
+  to_status VARCHAR(16) not null,
+  reason VARCHAR(500) not null default '',
+  -- the admin who made the change, or the user themselves when they
+  -- deactivated their account or reactivated it by logging in
+  changed_by int references users(id) on delete set null,
+  created_at timestamptz not null default now()
+);
+
+create index user_status_changes_user_id_created_at on user_status_changes(user_id, created_at desc);
+
+-- access control, a user is allowed what any of their roles is allowed.
+-- Permission names are referenced by the x-permission of operations in api.yml
+CREATE TABLE roles (
+  id serial primary key,
+  name VARCHAR(32) unique not null,
+  description VARCHAR(256) not null default '',
+  created_at timestamptz not null default now()
+);
+
+CREATE TABLE permissions (
+  id serial primary key,
+  name VARCHAR(64) unique not null,
+  description VARCHAR(256) not null default ''
+);
+
+CREATE TABLE role_permissions (
+  role_id int not null references roles(id) on delete cascade,
+  permission_id int not null references permissions(id) on delete cascade,
+  primary key (role_id, permission_id)
+);
+
+-- the roles of a user are put into the tokens issued at login, a change takes
+-- effect with the next login
+CREATE TABLE user_roles (
+  user_id int not null references users(id) on delete cascade,
+  role_id int not null references roles(id) on delete cascade,
+  created_at timestamptz not null default now(),
+  primary key (user_id, role_id)
+);
+
+create index user_roles_role_id on user_roles(role_id);
+
+-- every token issued to support staff to act as a user, each use of such a
+-- token is stored in impersonation_uses
+CREATE TABLE impersonations (
+  id serial primary key,
+  admin_id int references users(id) on delete set null,
+  user_id int not null references users(id) on delete cascade,
+  reason VARCHAR(500) not null,
+  created_at timestamptz not null default now(),
+  expires_at timestamptz not null
+);
+
+create index impersonations_user_id_created_at on impersonations(user_id, created_at desc);
+
+-- every request made with an impersonation token, the audit trail of what
+-- support staff did as the user. The query string is left out, it may hold
+-- secrets
+CREATE TABLE impersonation_uses (
+  id serial primary key,
+  impersonation_id int not null references impersonations(id) on delete cascade,
+  method VARCHAR(8) not null,
+  path VARCHAR(2048) not null,
+  created_at timestamptz not null default now()
+);
+
+create index impersonation_uses_impersonation_id_created_at on impersonation_uses(impersonation_id, created_at);
+
+-- every login that issued a regular token, the login history of the user
+CREATE TABLE login_events (
+  id serial primary key,
+  user_id int not null references users(id) on delete cascade,
+  -- password, or the name of the directory or login provider, see
+  -- identities.type
+  method VARCHAR(32) not null,
+  -- null when the address of the client is unknown
+  ip VARCHAR(45),
+  created_at timestamptz not null default now()
+);
+
+create index login_events_user_id_created_at on login_events(user_id, created_at desc);
+
+-- personal data bundles too large to be exported right away, built in the
+-- background and downloaded by the user until they expire, see
+-- usecase.ExportUserData
+CREATE TABLE data_exports (
+  id serial primary key,
+  user_id int not null references users(id) on delete cascade,
+  -- json or ndjson, see usecase.DATA_EXPORT_FORMATS
+  format VARCHAR(16) not null,
+  status VARCHAR(16) not null default 'pending',
+  -- null until the bundle is built
+  content bytea,
+  created_at timestamptz not null default now(),
+  completed_at timestamptz,
+  expires_at timestamptz not null,
+  check (status in ('pending', 'ready', 'failed'))
+);
+
+create index data_exports_user_id on data_exports(user_id);
+create index data_exports_expires_at on data_exports(expires_at);
+
+-- the JSON Schema of users.attributes, the latest one is enforced on write.
+-- Earlier ones are kept to tell which schema older attributes were written
+-- against, see utils.AttributeSchema
+CREATE TABLE attribute_schemas (
+  id serial primary key,
+  schema jsonb not null,
+  created_by int references users(id) on delete set null,
+  created_at timestamptz not null default now()
 );
 
-create index user_phone_number on users using hash(phone_number);
+INSERT INTO permissions (name, description) VALUES
+  ('users:read', 'See the accounts of all users'),
+  ('users:write', 'Change the accounts of all users'),
+  ('users:impersonate', 'Act as any user to see what they see'),
+  ('attributes:write', 'Change the schema of the custom profile attributes');
+INSERT INTO roles (name, description) VALUES
+  ('admin', 'Manages the accounts of all users'),
+  ('support', 'Looks into the accounts of users');
+INSERT INTO role_permissions (role_id, permission_id)
+  SELECT r.id, p.id FROM roles r, permissions p
+  WHERE r.name = 'admin' OR (r.name = 'support' AND p.name IN ('users:read', 'users:impersonate'));
@@ -4,4 +4,102 @@ const (
 	FULLNAME_FIELD     = "full_name"
 	PASSWORD_FIELD     = "password"
 	PHONE_NUMBER_FIELD = "phone_number"
+	LANGUAGE_FIELD     = "language"
+	EMAIL_FIELD        = "email"
+	CODE_FIELD         = "code"
+	TOKEN_FIELD        = "token"
+	TYPE_FIELD         = "type"
+	IDENTIFIER_FIELD   = "identifier"
+	PHONE_PREFIX_FIELD = "phone_prefix"
+	CREATED_TO_FIELD   = "created_to"
+	STATUS_FIELD       = "status"
+	SORT_FIELD         = "sort"
+	LIMIT_FIELD        = "limit"
+	CURSOR_FIELD       = "cursor"
+	REASON_FIELD       = "reason"
+	FORMAT_FIELD       = "format"
+	AVATAR_FIELD       = "avatar"
+	ATTRIBUTES_FIELD   = "attributes"
+	SCHEMA_FIELD       = "schema"
+
+	CURRENT_PASSWORD_FIELD = "current_password"
+	NEW_PASSWORD_FIELD     = "new_password"
 )
+
+// message catalog keys, see utils/data/messages
+const (
+	MESSAGE_INTERNAL_SERVER_ERROR      = "INTERNAL_SERVER_ERROR"
+	MESSAGE_FORBIDDEN                  = "FORBIDDEN"
+	MESSAGE_PHONE_NUMBER_ALREADY_USED  = "PHONE_NUMBER_ALREADY_USED"
+	MESSAGE_INVALID_CREDENTIALS        = "INVALID_CREDENTIALS"
+	MESSAGE_REGISTRATION_ACCEPTED      = "REGISTRATION_ACCEPTED"
+	MESSAGE_LOGIN_SUCCESS              = "LOGIN_SUCCESS"
+	MESSAGE_UPDATE_SUCCESS             = "UPDATE_SUCCESS"
+	MESSAGE_PASSWORD_EXPIRED           = "PASSWORD_EXPIRED"
+	MESSAGE_VALIDATION_ERROR           = "VALIDATION_ERROR"
+	MESSAGE_EMAIL_ALREADY_USED         = "EMAIL_ALREADY_USED"
+	MESSAGE_EMAIL_VERIFIED             = "EMAIL_VERIFIED"
+	MESSAGE_EMAIL_VERIFICATION_SENT    = "EMAIL_VERIFICATION_SENT"
+	MESSAGE_EMAIL_VERIFICATION_INVALID = "EMAIL_VERIFICATION_INVALID"
+	MESSAGE_EMAIL_MISSING              = "EMAIL_MISSING"
+	MESSAGE_EMAIL_ALREADY_VERIFIED     = "EMAIL_ALREADY_VERIFIED"
+	MESSAGE_IDENTITY_REMOVED           = "IDENTITY_REMOVED"
+	MESSAGE_IDENTIFIER_ALREADY_USED    = "IDENTIFIER_ALREADY_USED"
+	MESSAGE_IDENTITY_NOT_FOUND         = "IDENTITY_NOT_FOUND"
+	MESSAGE_IDENTITY_NOT_VERIFIABLE    = "IDENTITY_NOT_VERIFIABLE"
+	MESSAGE_IDENTITY_ALREADY_VERIFIED  = "IDENTITY_ALREADY_VERIFIED"
+	MESSAGE_OIDC_PROVIDER_NOT_FOUND    = "OIDC_PROVIDER_NOT_FOUND"
+	MESSAGE_OIDC_LOGIN_FAILED          = "OIDC_LOGIN_FAILED"
+	MESSAGE_PERMISSION_DENIED          = "PERMISSION_DENIED"
+	MESSAGE_USER_NOT_FOUND             = "USER_NOT_FOUND"
+	MESSAGE_ACCOUNT_PENDING            = "ACCOUNT_PENDING"
+	MESSAGE_ACCOUNT_SUSPENDED          = "ACCOUNT_SUSPENDED"
+	MESSAGE_ACCOUNT_DEACTIVATED        = "ACCOUNT_DEACTIVATED"
+	MESSAGE_USER_STATUS_CHANGED        = "USER_STATUS_CHANGED"
+	MESSAGE_USER_NOT_ACTIVE            = "USER_NOT_ACTIVE"
+	MESSAGE_IMPERSONATION_STARTED      = "IMPERSONATION_STARTED"
+	MESSAGE_ACCOUNT_DELETED            = "ACCOUNT_DELETED"
+	MESSAGE_ACCOUNT_RESTORED           = "ACCOUNT_RESTORED"
+	MESSAGE_ACCOUNT_NOT_RESTORABLE     = "ACCOUNT_NOT_RESTORABLE"
+
+	MESSAGE_ACCOUNT_DELETION_SCHEDULED = "ACCOUNT_DELETION_SCHEDULED"
+
+	MESSAGE_DATA_EXPORT_STARTED   = "DATA_EXPORT_STARTED"
+	MESSAGE_DATA_EXPORT_PENDING   = "DATA_EXPORT_PENDING"
+	MESSAGE_DATA_EXPORT_NOT_FOUND = "DATA_EXPORT_NOT_FOUND"
+	MESSAGE_DATA_EXPORT_FAILED    = "DATA_EXPORT_FAILED"
+
+	MESSAGE_AVATAR_UPDATED = "AVATAR_UPDATED"
+	MESSAGE_AVATAR_REMOVED = "AVATAR_REMOVED"
+
+	MESSAGE_ATTRIBUTE_SCHEMA_UPDATED = "ATTRIBUTE_SCHEMA_UPDATED"
+	MESSAGE_ATTRIBUTES_UPDATED       = "ATTRIBUTES_UPDATED"
+
+	MESSAGE_MERGE_PATCH_INVALID = "MERGE_PATCH_INVALID"
+	MESSAGE_PROFILE_CHANGED     = "PROFILE_CHANGED"
+
+	MESSAGE_STATUS_TRANSITION_NOT_ALLOWED = "STATUS_TRANSITION_NOT_ALLOWED"
+	MESSAGE_IMPERSONATION_NOT_ALLOWED     = "IMPERSONATION_NOT_ALLOWED"
+	MESSAGE_LOGIN_REQUIRED                = "LOGIN_REQUIRED"
+
+	MESSAGE_PRIMARY_PHONE_NUMBER_NOT_REMOVABLE = "PRIMARY_PHONE_NUMBER_NOT_REMOVABLE"
+	MESSAGE_LAST_LOGIN_NOT_REMOVABLE           = "LAST_LOGIN_NOT_REMOVABLE"
+)
+
+// MIME_APPLICATION_MERGE_PATCH_JSON is the media type of RFC 7396 JSON merge
+// patches, see PATCH /profile
+const MIME_APPLICATION_MERGE_PATCH_JSON = "application/merge-patch+json"
+
+// OIDC_FLOW_COOKIE keeps an OpenID Connect login between its start and its
+// callback
+const OIDC_FLOW_COOKIE = "oidc_flow"
+
+// the page size of GET /admin/users
+const (
+	ADMIN_USERS_DEFAULT_LIMIT = 20
+	ADMIN_USERS_MAX_LIMIT     = 100
+)
+
+// REASON_MAX_LENGTH is the length in characters of the reason of a status
+// change or an impersonation, see user_status_changes and impersonations
+const REASON_MAX_LENGTH = 500
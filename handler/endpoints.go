@@ -1,16 +1,29 @@
 package handler
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/SawitProRecruitment/UserService/generated"
 	"github.com/SawitProRecruitment/UserService/usecase"
 	"github.com/SawitProRecruitment/UserService/utils"
 	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
 )
 
+// phonePrefixPattern is the start of a phone number in E.164 format
+var phonePrefixPattern = regexp.MustCompile(`^\+[0-9]{1,15}$`)
+
 // This endpoint is used to register a new user
 // (POST /registration)
 func (s *Server) Registration(ctx echo.Context) error {
@@ -21,53 +34,65 @@ func (s *Server) Registration(ctx echo.Context) error {
 
 	ctx.Bind(&req)
 
-	errValidation := utils.ValidateFullName(req.FullName)
+	fullName, errValidation := utils.NormalizeFullName(req.FullName)
 	if errValidation != nil {
 		errs[FULLNAME_FIELD] = errValidation
+		fullName = req.FullName
 	}
 
-	errValidation = utils.ValidatePassword(req.Password)
+	phoneNumber, errValidation := utils.NormalizePhoneNumber(req.PhoneNumber)
 	if errValidation != nil {
-		errs[PASSWORD_FIELD] = errValidation
+		errs[PHONE_NUMBER_FIELD] = errValidation
+		phoneNumber = req.PhoneNumber
 	}
 
-	errValidation = utils.ValidatePhoneNumbers(req.PhoneNumber)
+	errValidation = utils.ValidatePassword(req.Password, utils.PasswordUserInfo{
+		PhoneNumber: phoneNumber,
+		FullName:    fullName,
+	})
 	if errValidation != nil {
-		errs[PHONE_NUMBER_FIELD] = errValidation
+		errs[PASSWORD_FIELD] = errValidation
 	}
 
-	if len(errs) != 0 {
-		var (
-			resp generated.ValidationErrorsResponse
-		)
-
-		for k, v := range errs {
-			resp = append(resp, generated.ValidationError{
-				Field:   k,
-				Message: v.Error(),
-			})
+	// echo does not bind optional fields, see ProfileUpdate
+	email := ctx.FormValue(EMAIL_FIELD)
+	if email != "" {
+		email, errValidation = utils.NormalizeEmail(email)
+		if errValidation != nil {
+			errs[EMAIL_FIELD] = errValidation
 		}
+	}
 
-		return ctx.JSON(http.StatusBadRequest, resp)
+	if len(errs) != 0 {
+		return s.respondError(ctx, newValidationProblem(errs))
 	}
 
 	resp, err := s.Usecase.RegisterNewUser(ctx.Request().Context(), usecase.RegisterNewUserInput{
-		PhoneNumber: req.PhoneNumber,
-		FullName:    req.FullName,
-		Password:    req.Password,
+		PhoneNumber:  phoneNumber,
+		FullName:     fullName,
+		Password:     req.Password,
+		Email:        email,
+		MailLanguage: contextLanguage(ctx),
 	})
 
 	if err != nil {
 		log.Println("[ERROR][Registration] error when RegisterNewUser", err)
-		return ctx.JSON(http.StatusInternalServerError, generated.BasicErrorResponse{
-			Message: "Internal server error",
+		return s.respondError(ctx, err)
+	}
+
+	// the same answer whether or not the phone number or email was already registered
+	if s.RegistrationAntiEnumeration {
+		return ctx.JSON(http.StatusAccepted, generated.BasicSuccessResponse{
+			Message: utils.Localize(contextLanguage(ctx), MESSAGE_REGISTRATION_ACCEPTED, nil),
 		})
 	}
 
 	if resp.IsPhoneNumberExists {
-		return ctx.JSON(http.StatusConflict, generated.BasicErrorResponse{
-			Message: "Phone number already used",
-		})
+		return s.respondError(ctx, newProblem(http.StatusConflict, MESSAGE_PHONE_NUMBER_ALREADY_USED))
+	}
+
+	if resp.IsEmailExists {
+		return s.respondError(ctx, newProblem(http.StatusConflict, MESSAGE_EMAIL_ALREADY_USED))
 	}
 
 	return ctx.JSON(http.StatusOK, generated.SuccessRegistrationResponse{
@@ -78,52 +103,178 @@ func (s *Server) Registration(ctx echo.Context) error {
 // This endpoint is used to login a user
 // (POST /login)
 func (s *Server) Login(ctx echo.Context) error {
+	lang := requestLanguage(ctx, "")
+
 	var (
 		req generated.LoginFormdataBody
 	)
 
 	ctx.Bind(&req)
 
+	// echo does not bind optional fields, see ProfileUpdate
+	phoneNumber := ctx.FormValue(PHONE_NUMBER_FIELD)
+	email := ctx.FormValue(EMAIL_FIELD)
+
+	if phoneNumber == "" && email == "" {
+		return s.respondError(ctx, newValidationProblem(map[string]error{
+			PHONE_NUMBER_FIELD: utils.NewValidationError(utils.CODE_LOGIN_IDENTIFIER_REQUIRED, nil),
+		}))
+	}
+
+	if phoneNumber != "" && email != "" {
+		return s.respondError(ctx, newValidationProblem(map[string]error{
+			EMAIL_FIELD: utils.NewValidationError(utils.CODE_LOGIN_IDENTIFIER_AMBIGUOUS, nil),
+		}))
+	}
+
+	// identifiers are stored normalized, an invalid one is simply not found
+	if phoneNumber != "" {
+		if normalized, err := utils.NormalizePhoneNumber(phoneNumber); err == nil {
+			phoneNumber = normalized
+		}
+	} else if normalized, err := utils.NormalizeEmail(email); err == nil {
+		email = normalized
+	}
+
 	resp, err := s.Usecase.Login(ctx.Request().Context(), usecase.LoginInput{
-		PhoneNumber: req.PhoneNumber,
+		PhoneNumber: phoneNumber,
+		Email:       email,
 		Password:    req.Password,
+		IP:          ctx.RealIP(),
 	})
 
 	if err != nil {
 		log.Println("[ERROR][Login] error when Login", err)
-		return ctx.JSON(http.StatusInternalServerError, generated.BasicErrorResponse{
-			Message: "Internal server error",
-		})
+		return s.respondError(ctx, err)
 	}
 
-	if resp.IsDataNotFound {
-		return ctx.JSON(http.StatusBadRequest, generated.BasicErrorResponse{
-			Message: "Phone number not found",
-		})
+	if resp.IsInvalidCredentials {
+		return s.respondError(ctx, newProblem(http.StatusUnauthorized, MESSAGE_INVALID_CREDENTIALS))
 	}
 
-	if resp.IsPasswordWrong {
-		return ctx.JSON(http.StatusBadRequest, generated.BasicErrorResponse{
-			Message: "Wrong password",
-		})
+	if resp.IsAccountPending {
+		return s.respondError(ctx, newProblem(http.StatusForbidden, MESSAGE_ACCOUNT_PENDING))
+	}
+
+	if resp.IsAccountSuspended {
+		return s.respondError(ctx, newProblem(http.StatusForbidden, MESSAGE_ACCOUNT_SUSPENDED))
+	}
+
+	if resp.IsAccountDeleted {
+		problem := newProblem(http.StatusForbidden, MESSAGE_ACCOUNT_DELETED)
+		problem.Token = resp.Token
+		return s.respondError(ctx, problem)
+	}
+
+	if resp.IsPasswordExpired {
+		problem := newProblem(http.StatusForbidden, MESSAGE_PASSWORD_EXPIRED)
+		problem.Token = resp.Token
+		return s.respondError(ctx, problem)
 	}
 
 	return ctx.JSON(http.StatusOK, generated.LoginSuccessResponse{
-		Message: "Login success",
+		Message: utils.Localize(lang, MESSAGE_LOGIN_SUCCESS, nil),
 		Token:   resp.Token,
 	})
 }
 
+// Start a login at an OpenID Connect provider
+// (GET /oidc/{provider}/start)
+func (s *Server) OidcLoginStart(ctx echo.Context, provider string) error {
+	requestLanguage(ctx, "")
+
+	output, err := s.Usecase.StartOIDCLogin(ctx.Request().Context(), usecase.StartOIDCLoginInput{
+		Provider: provider,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][OidcLoginStart] error when StartOIDCLogin", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsProviderUnknown {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_OIDC_PROVIDER_NOT_FOUND))
+	}
+
+	ctx.SetCookie(newOIDCFlowCookie(ctx, provider, output.FlowToken))
+
+	return ctx.Redirect(http.StatusFound, output.AuthorizationURL)
+}
+
+// Finish a login at an OpenID Connect provider
+// (GET /oidc/{provider}/callback)
+func (s *Server) OidcLoginCallback(ctx echo.Context, provider string, params generated.OidcLoginCallbackParams) error {
+	lang := requestLanguage(ctx, "")
+
+	// a login can only be finished once
+	expired := newOIDCFlowCookie(ctx, provider, "")
+	expired.MaxAge = -1
+	ctx.SetCookie(expired)
+
+	if params.Error != nil {
+		log.Println("[WARN][OidcLoginCallback] login refused by the provider", *params.Error)
+		return s.respondError(ctx, newProblem(http.StatusUnauthorized, MESSAGE_OIDC_LOGIN_FAILED))
+	}
+
+	input := usecase.FinishOIDCLoginInput{
+		Provider: provider,
+		IP:       ctx.RealIP(),
+	}
+
+	if params.Code != nil {
+		input.Code = *params.Code
+	}
+
+	if params.State != nil {
+		input.State = *params.State
+	}
+
+	if cookie, err := ctx.Cookie(OIDC_FLOW_COOKIE); err == nil {
+		input.FlowToken = cookie.Value
+	}
+
+	output, err := s.Usecase.FinishOIDCLogin(ctx.Request().Context(), input)
+
+	if err != nil {
+		log.Println("[ERROR][OidcLoginCallback] error when FinishOIDCLogin", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsProviderUnknown {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_OIDC_PROVIDER_NOT_FOUND))
+	}
+
+	if output.IsInvalid {
+		return s.respondError(ctx, newProblem(http.StatusUnauthorized, MESSAGE_OIDC_LOGIN_FAILED))
+	}
+
+	if output.IsAccountPending {
+		return s.respondError(ctx, newProblem(http.StatusForbidden, MESSAGE_ACCOUNT_PENDING))
+	}
+
+	if output.IsAccountSuspended {
+		return s.respondError(ctx, newProblem(http.StatusForbidden, MESSAGE_ACCOUNT_SUSPENDED))
+	}
+
+	if output.IsAccountDeleted {
+		problem := newProblem(http.StatusForbidden, MESSAGE_ACCOUNT_DELETED)
+		problem.Token = output.Token
+		return s.respondError(ctx, problem)
+	}
+
+	return ctx.JSON(http.StatusOK, generated.LoginSuccessResponse{
+		Message: utils.Localize(lang, MESSAGE_LOGIN_SUCCESS, nil),
+		Token:   output.Token,
+	})
+}
+
 // Get profile data based on the jwt headers
 // (GET /profile)
 func (s *Server) ProfileGet(ctx echo.Context) error {
-
-	id, err := utils.TokenValidity(ctx)
+	id, err := s.tokenValidity(ctx)
 
 	if err != nil {
-		return ctx.JSON(http.StatusForbidden, generated.BasicErrorResponse{
-			Message: "Forbiddenn",
-		})
+		return s.respondError(ctx, err)
 	}
 
 	userData, err := s.Usecase.GetUserData(ctx.Request().Context(), usecase.GetUserDataInput{
@@ -132,27 +283,46 @@ func (s *Server) ProfileGet(ctx echo.Context) error {
 
 	if err != nil {
 		log.Println("[ERROR][ProfileGet] error when GetUserData", err)
-		return ctx.JSON(http.StatusInternalServerError, generated.BasicErrorResponse{
-			Message: "Internal server error",
-		})
+		return s.respondError(ctx, err)
 	}
 
-	return ctx.JSON(http.StatusOK, generated.ProfileGetResponse{
+	resp := generated.ProfileGetResponse{
 		PhoneNumber: userData.PhoneNumber,
 		FullName:    userData.FullName,
-	})
+	}
+
+	if userData.Language != "" {
+		resp.Language = &userData.Language
+	}
+
+	if userData.Email != "" {
+		resp.Email = &userData.Email
+		resp.EmailVerified = &userData.EmailVerified
+	}
+
+	if userData.Avatar != nil {
+		urls := newAvatarURLs(*userData.Avatar)
+		resp.AvatarUrl = &urls.Large
+		resp.AvatarUrls = &urls
+	}
+
+	if len(userData.Attributes) > 0 {
+		attributes := generated.Attributes(userData.Attributes)
+		resp.Attributes = &attributes
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
 }
 
 // Update profile data based on the request body and the jwt headers
 // (PUT /profile)
 func (s *Server) ProfileUpdate(ctx echo.Context) error {
+	lang := requestLanguage(ctx, "")
 
-	id, err := utils.TokenValidity(ctx)
+	id, err := s.tokenValidity(ctx)
 
 	if err != nil {
-		return ctx.JSON(http.StatusForbidden, generated.BasicErrorResponse{
-			Message: "Forbidden",
-		})
+		return s.respondError(ctx, err)
 	}
 
 	var (
@@ -162,55 +332,1572 @@ func (s *Server) ProfileUpdate(ctx echo.Context) error {
 
 	ctx.Bind(&req)
 
+	if req.PhoneNumber != "" && isImpersonation(ctx) {
+		return s.respondError(ctx, newProblem(http.StatusForbidden, MESSAGE_IMPERSONATION_NOT_ALLOWED))
+	}
+
 	if req.FullName != "" {
-		errValidation := utils.ValidateFullName(req.FullName)
+		fullName, errValidation := utils.NormalizeFullName(req.FullName)
 		if errValidation != nil {
 			errs[FULLNAME_FIELD] = errValidation
 		}
+		req.FullName = fullName
 	}
 
 	if req.PhoneNumber != "" {
-		errValidation := utils.ValidatePhoneNumbers(req.PhoneNumber)
+		phoneNumber, errValidation := utils.NormalizePhoneNumber(req.PhoneNumber)
 		if errValidation != nil {
 			errs[PHONE_NUMBER_FIELD] = errValidation
 		}
+		req.PhoneNumber = phoneNumber
 	}
 
-	if len(errs) != 0 {
-		var (
-			resp generated.ValidationErrorsResponse
-		)
-
-		for k, v := range errs {
-			resp = append(resp, generated.ValidationError{
-				Field:   k,
-				Message: v.Error(),
+	// echo does not understand the generated "language,omitempty" form tag of
+	// optional fields, so req.Language is never bound
+	language := ctx.FormValue(LANGUAGE_FIELD)
+	if language != "" {
+		if utils.IsSupportedLanguage(language) {
+			lang = requestLanguage(ctx, language)
+		} else {
+			errs[LANGUAGE_FIELD] = utils.NewValidationError(utils.CODE_LANGUAGE_NOT_SUPPORTED, map[string]interface{}{
+				"supported_languages": utils.SupportedLanguages(),
 			})
 		}
+	}
+
+	email := ctx.FormValue(EMAIL_FIELD)
+	if email != "" {
+		normalized, errValidation := utils.NormalizeEmail(email)
+		if errValidation != nil {
+			errs[EMAIL_FIELD] = errValidation
+		}
+		email = normalized
+	}
+
+	attributes, errValidation := parseAttributes(ctx.FormValue(ATTRIBUTES_FIELD))
+	if errValidation != nil {
+		errs[ATTRIBUTES_FIELD] = errValidation
+	}
 
-		return ctx.JSON(http.StatusBadRequest, resp)
+	if len(errs) != 0 {
+		return s.respondError(ctx, newValidationProblem(errs))
 	}
 
 	output, err := s.Usecase.UpdateUserData(ctx.Request().Context(), usecase.UpdateUserDataInput{
-		Id:          id,
-		PhoneNumber: req.PhoneNumber,
-		FullName:    req.FullName,
+		Id:           id,
+		PhoneNumber:  req.PhoneNumber,
+		FullName:     req.FullName,
+		Language:     language,
+		Email:        email,
+		MailLanguage: lang,
+		Attributes:   attributes,
 	})
 
 	if err != nil {
 		log.Println("[ERROR][ProfileUpdate] error when UpdateUserData", err)
-		return ctx.JSON(http.StatusInternalServerError, generated.BasicErrorResponse{
-			Message: "Internal server error",
-		})
+		return s.respondError(ctx, err)
+	}
+
+	if len(output.AttributeErrors) > 0 {
+		return s.respondError(ctx, newAttributesValidationProblem(output.AttributeErrors))
 	}
 
 	if output.IsPhoneNumberExists {
-		return ctx.JSON(http.StatusConflict, generated.BasicErrorResponse{
-			Message: "Phone number already used",
-		})
+		return s.respondError(ctx, newProblem(http.StatusConflict, MESSAGE_PHONE_NUMBER_ALREADY_USED))
+	}
+
+	if output.IsEmailExists {
+		return s.respondError(ctx, newProblem(http.StatusConflict, MESSAGE_EMAIL_ALREADY_USED))
+	}
+
+	return ctx.JSON(http.StatusOK, generated.BasicSuccessResponse{
+		Message: utils.Localize(lang, MESSAGE_UPDATE_SUCCESS, nil),
+	})
+}
+
+// Change the profile fields present in a JSON merge patch based on the jwt
+// headers
+// (PATCH /profile)
+func (s *Server) ProfilePatch(ctx echo.Context) error {
+	lang := requestLanguage(ctx, "")
+
+	id, err := s.tokenValidity(ctx)
+
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(ctx.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != MIME_APPLICATION_MERGE_PATCH_JSON {
+		return s.respondError(ctx, echo.ErrUnsupportedMediaType)
+	}
+
+	body, err := io.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return s.respondError(ctx, newProblem(http.StatusBadRequest, MESSAGE_MERGE_PATCH_INVALID))
+	}
+
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return s.respondError(ctx, newProblem(http.StatusBadRequest, MESSAGE_MERGE_PATCH_INVALID))
+	}
+
+	var (
+		input = usecase.PatchUserDataInput{
+			Id: id,
+		}
+		errs = make(map[string]error)
+	)
+
+	if raw, ok := patch[PHONE_NUMBER_FIELD]; ok {
+		if isImpersonation(ctx) {
+			return s.respondError(ctx, newProblem(http.StatusForbidden, MESSAGE_IMPERSONATION_NOT_ALLOWED))
+		}
+
+		// a null phone number is empty, which is refused
+		phoneNumber, _, errValidation := patchString(raw)
+		if errValidation == nil {
+			phoneNumber, errValidation = utils.NormalizePhoneNumber(phoneNumber)
+		}
+
+		if errValidation != nil {
+			errs[PHONE_NUMBER_FIELD] = errValidation
+		} else {
+			input.PhoneNumber = &phoneNumber
+		}
+	}
+
+	if raw, ok := patch[FULLNAME_FIELD]; ok {
+		fullName, _, errValidation := patchString(raw)
+		if errValidation == nil {
+			fullName, errValidation = utils.NormalizeFullName(fullName)
+		}
+
+		if errValidation != nil {
+			errs[FULLNAME_FIELD] = errValidation
+		} else {
+			input.FullName = &fullName
+		}
+	}
+
+	if raw, ok := patch[LANGUAGE_FIELD]; ok {
+		language, isNull, errValidation := patchString(raw)
+		if errValidation == nil && !isNull && !utils.IsSupportedLanguage(language) {
+			errValidation = utils.NewValidationError(utils.CODE_LANGUAGE_NOT_SUPPORTED, map[string]interface{}{
+				"supported_languages": utils.SupportedLanguages(),
+			})
+		}
+
+		if errValidation != nil {
+			errs[LANGUAGE_FIELD] = errValidation
+		} else {
+			input.Language = &language
+			lang = requestLanguage(ctx, language)
+		}
+	}
+
+	if raw, ok := patch[EMAIL_FIELD]; ok {
+		email, isNull, errValidation := patchString(raw)
+		if errValidation == nil && !isNull {
+			email, errValidation = utils.NormalizeEmail(email)
+		}
+
+		if errValidation != nil {
+			errs[EMAIL_FIELD] = errValidation
+		} else {
+			input.Email = &email
+		}
+	}
+
+	if raw, ok := patch[ATTRIBUTES_FIELD]; ok {
+		var attributes map[string]interface{}
+		if err := json.Unmarshal(raw, &attributes); err != nil {
+			errs[ATTRIBUTES_FIELD] = utils.NewValidationError(utils.CODE_ATTRIBUTES_NOT_OBJECT, nil)
+		} else {
+			input.AttributesPatch = raw
+		}
+	}
+
+	if len(errs) != 0 {
+		return s.respondError(ctx, newValidationProblem(errs))
+	}
+
+	input.MailLanguage = lang
+
+	output, err := s.Usecase.PatchUserData(ctx.Request().Context(), input)
+
+	if err != nil {
+		log.Println("[ERROR][ProfilePatch] error when PatchUserData", err)
+		return s.respondError(ctx, err)
+	}
+
+	if len(output.AttributeErrors) > 0 {
+		return s.respondError(ctx, newAttributesValidationProblem(output.AttributeErrors))
+	}
+
+	if output.IsConflict {
+		return s.respondError(ctx, newProblem(http.StatusConflict, MESSAGE_PROFILE_CHANGED))
+	}
+
+	if output.IsPhoneNumberExists {
+		return s.respondError(ctx, newProblem(http.StatusConflict, MESSAGE_PHONE_NUMBER_ALREADY_USED))
+	}
+
+	if output.IsEmailExists {
+		return s.respondError(ctx, newProblem(http.StatusConflict, MESSAGE_EMAIL_ALREADY_USED))
+	}
+
+	return ctx.JSON(http.StatusOK, generated.BasicSuccessResponse{
+		Message: utils.Localize(lang, MESSAGE_UPDATE_SUCCESS, nil),
+	})
+}
+
+// Update the password of the user based on the jwt headers
+// (PUT /profile/password)
+func (s *Server) PasswordUpdate(ctx echo.Context) error {
+	lang := requestLanguage(ctx, "")
+
+	id, err := s.tokenValidity(ctx)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+
+	if isImpersonation(ctx) {
+		return s.respondError(ctx, newProblem(http.StatusForbidden, MESSAGE_IMPERSONATION_NOT_ALLOWED))
+	}
+
+	var (
+		req  generated.PasswordUpdateFormdataBody
+		errs = make(map[string]error)
+	)
+
+	ctx.Bind(&req)
+
+	if req.CurrentPassword == "" {
+		errs[CURRENT_PASSWORD_FIELD] = utils.NewValidationError(utils.CODE_PASSWORD_REQUIRED, nil)
+	}
+
+	userData, err := s.Usecase.GetUserData(ctx.Request().Context(), usecase.GetUserDataInput{
+		Id: id,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][PasswordUpdate] error when GetUserData", err)
+		return s.respondError(ctx, err)
+	}
+
+	lang = requestLanguage(ctx, userData.Language)
+
+	errValidation := utils.ValidatePassword(req.NewPassword, utils.PasswordUserInfo{
+		PhoneNumber: userData.PhoneNumber,
+		FullName:    userData.FullName,
+	})
+	if errValidation != nil {
+		errs[NEW_PASSWORD_FIELD] = errValidation
+	}
+
+	if len(errs) != 0 {
+		return s.respondError(ctx, newValidationProblem(errs))
+	}
+
+	output, err := s.Usecase.SetPassword(ctx.Request().Context(), usecase.SetPasswordInput{
+		Id:              id,
+		CurrentPassword: req.CurrentPassword,
+		NewPassword:     req.NewPassword,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][PasswordUpdate] error when SetPassword", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsPasswordWrong {
+		errs[CURRENT_PASSWORD_FIELD] = utils.NewValidationError(utils.CODE_PASSWORD_INCORRECT, nil)
+	}
+
+	if output.IsPasswordReused {
+		errs[NEW_PASSWORD_FIELD] = utils.NewValidationError(utils.CODE_PASSWORD_RECENTLY_USED, nil)
+	}
+
+	if len(errs) != 0 {
+		return s.respondError(ctx, newValidationProblem(errs))
 	}
 
 	return ctx.JSON(http.StatusOK, generated.BasicSuccessResponse{
-		Message: "Update success",
+		Message: utils.Localize(lang, MESSAGE_UPDATE_SUCCESS, nil),
+	})
+}
+
+// Set a new password using the restricted token returned by login when the password is expired
+// (PUT /login/expired-password)
+func (s *Server) ExpiredPasswordUpdate(ctx echo.Context) error {
+	lang := requestLanguage(ctx, "")
+
+	id, err := s.tokenValidityScoped(ctx, utils.TOKEN_SCOPE_PASSWORD_EXPIRED)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+
+	var (
+		req  generated.ExpiredPasswordUpdateFormdataBody
+		errs = make(map[string]error)
+	)
+
+	ctx.Bind(&req)
+
+	userData, err := s.Usecase.GetUserData(ctx.Request().Context(), usecase.GetUserDataInput{
+		Id: id,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][ExpiredPasswordUpdate] error when GetUserData", err)
+		return s.respondError(ctx, err)
+	}
+
+	lang = requestLanguage(ctx, userData.Language)
+
+	errValidation := utils.ValidatePassword(req.NewPassword, utils.PasswordUserInfo{
+		PhoneNumber: userData.PhoneNumber,
+		FullName:    userData.FullName,
+	})
+	if errValidation != nil {
+		errs[NEW_PASSWORD_FIELD] = errValidation
+		return s.respondError(ctx, newValidationProblem(errs))
+	}
+
+	output, err := s.Usecase.SetExpiredPassword(ctx.Request().Context(), usecase.SetExpiredPasswordInput{
+		Id:          id,
+		NewPassword: req.NewPassword,
+		IP:          ctx.RealIP(),
 	})
+
+	if err != nil {
+		log.Println("[ERROR][ExpiredPasswordUpdate] error when SetExpiredPassword", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsPasswordReused {
+		errs[NEW_PASSWORD_FIELD] = utils.NewValidationError(utils.CODE_PASSWORD_RECENTLY_USED, nil)
+		return s.respondError(ctx, newValidationProblem(errs))
+	}
+
+	return ctx.JSON(http.StatusOK, generated.LoginSuccessResponse{
+		Message: utils.Localize(lang, MESSAGE_UPDATE_SUCCESS, nil),
+		Token:   output.Token,
+	})
+}
+
+// Restore a deleted account using the restricted token returned by login during the grace period
+// (POST /login/restoration)
+func (s *Server) AccountRestore(ctx echo.Context) error {
+	lang := requestLanguage(ctx, "")
+
+	// not tokenValidityScoped, the user is not active until restored
+	id, err := utils.TokenValidityScoped(ctx, utils.TOKEN_SCOPE_ACCOUNT_RESTORATION)
+	if err != nil {
+		return s.respondError(ctx, newProblem(http.StatusForbidden, MESSAGE_FORBIDDEN))
+	}
+
+	output, err := s.Usecase.RestoreUser(ctx.Request().Context(), usecase.RestoreUserInput{
+		Id: id,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][AccountRestore] error when RestoreUser", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsNotRestorable {
+		return s.respondError(ctx, newProblem(http.StatusConflict, MESSAGE_ACCOUNT_NOT_RESTORABLE))
+	}
+
+	return ctx.JSON(http.StatusOK, generated.BasicSuccessResponse{
+		Message: utils.Localize(lang, MESSAGE_ACCOUNT_RESTORED, nil),
+	})
+}
+
+// Verify an email address with the token of the mailed link
+// (POST /email/verify)
+func (s *Server) EmailVerify(ctx echo.Context) error {
+	lang := requestLanguage(ctx, "")
+
+	var (
+		req generated.EmailVerifyFormdataBody
+	)
+
+	ctx.Bind(&req)
+
+	output, err := s.Usecase.VerifyEmailByToken(ctx.Request().Context(), usecase.VerifyEmailByTokenInput{
+		Token: req.Token,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][EmailVerify] error when VerifyEmailByToken", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsInvalid {
+		return s.respondError(ctx, newProblem(http.StatusBadRequest, MESSAGE_EMAIL_VERIFICATION_INVALID))
+	}
+
+	return ctx.JSON(http.StatusOK, generated.BasicSuccessResponse{
+		Message: utils.Localize(lang, MESSAGE_EMAIL_VERIFIED, nil),
+	})
+}
+
+// Verify the email of the user with the mailed code
+// (POST /profile/email/verify)
+func (s *Server) ProfileEmailVerify(ctx echo.Context) error {
+	lang := requestLanguage(ctx, "")
+
+	id, err := s.tokenValidity(ctx)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+
+	var (
+		req generated.ProfileEmailVerifyFormdataBody
+	)
+
+	ctx.Bind(&req)
+
+	if req.Code == "" {
+		return s.respondError(ctx, newValidationProblem(map[string]error{
+			CODE_FIELD: utils.NewValidationError(utils.CODE_VERIFICATION_CODE_REQUIRED, nil),
+		}))
+	}
+
+	output, err := s.Usecase.VerifyEmailByCode(ctx.Request().Context(), usecase.VerifyEmailByCodeInput{
+		Id:   id,
+		Code: req.Code,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][ProfileEmailVerify] error when VerifyEmailByCode", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsInvalid {
+		return s.respondError(ctx, newProblem(http.StatusBadRequest, MESSAGE_EMAIL_VERIFICATION_INVALID))
+	}
+
+	return ctx.JSON(http.StatusOK, generated.BasicSuccessResponse{
+		Message: utils.Localize(lang, MESSAGE_EMAIL_VERIFIED, nil),
+	})
+}
+
+// Mail a new verification link and code to the email of the user
+// (POST /profile/email/verification)
+func (s *Server) ProfileEmailVerificationSend(ctx echo.Context) error {
+	id, err := s.tokenValidity(ctx)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+
+	userData, err := s.Usecase.GetUserData(ctx.Request().Context(), usecase.GetUserDataInput{
+		Id: id,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][ProfileEmailVerificationSend] error when GetUserData", err)
+		return s.respondError(ctx, err)
+	}
+
+	lang := requestLanguage(ctx, userData.Language)
+
+	output, err := s.Usecase.SendEmailVerification(ctx.Request().Context(), usecase.SendEmailVerificationInput{
+		Id:           id,
+		MailLanguage: lang,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][ProfileEmailVerificationSend] error when SendEmailVerification", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsEmailMissing {
+		return s.respondError(ctx, newProblem(http.StatusBadRequest, MESSAGE_EMAIL_MISSING))
+	}
+
+	if output.IsEmailVerified {
+		return s.respondError(ctx, newProblem(http.StatusConflict, MESSAGE_EMAIL_ALREADY_VERIFIED))
+	}
+
+	return ctx.JSON(http.StatusAccepted, generated.BasicSuccessResponse{
+		Message: utils.Localize(lang, MESSAGE_EMAIL_VERIFICATION_SENT, nil),
+	})
+}
+
+// List the phone numbers and email addresses the user can log in with
+// (GET /profile/identities)
+func (s *Server) ProfileIdentitiesGet(ctx echo.Context) error {
+	id, err := s.tokenValidity(ctx)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+
+	output, err := s.Usecase.GetIdentities(ctx.Request().Context(), usecase.GetIdentitiesInput{
+		Id: id,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][ProfileIdentitiesGet] error when GetIdentities", err)
+		return s.respondError(ctx, err)
+	}
+
+	resp := generated.IdentitiesResponse{
+		Identities: make([]generated.Identity, 0, len(output.Identities)),
+	}
+
+	for _, identity := range output.Identities {
+		resp.Identities = append(resp.Identities, newIdentityResponse(identity))
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// Add a phone number or email address the user can log in with
+// (POST /profile/identities)
+func (s *Server) ProfileIdentityAdd(ctx echo.Context) error {
+	lang := requestLanguage(ctx, "")
+
+	id, err := s.tokenValidity(ctx)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+
+	var (
+		req  generated.ProfileIdentityAddFormdataBody
+		errs = make(map[string]error)
+	)
+
+	ctx.Bind(&req)
+
+	identityType := string(req.Type)
+	identifier := req.Identifier
+
+	if identityType == usecase.IDENTITY_TYPE_PHONE && isImpersonation(ctx) {
+		return s.respondError(ctx, newProblem(http.StatusForbidden, MESSAGE_IMPERSONATION_NOT_ALLOWED))
+	}
+
+	switch identityType {
+	case usecase.IDENTITY_TYPE_PHONE:
+		phoneNumber, errValidation := utils.NormalizePhoneNumber(identifier)
+		if errValidation != nil {
+			errs[IDENTIFIER_FIELD] = errValidation
+		}
+		identifier = phoneNumber
+	case usecase.IDENTITY_TYPE_EMAIL:
+		email, errValidation := utils.NormalizeEmail(identifier)
+		if errValidation != nil {
+			errs[IDENTIFIER_FIELD] = errValidation
+		}
+		identifier = email
+	default:
+		errs[TYPE_FIELD] = utils.NewValidationError(utils.CODE_IDENTITY_TYPE_NOT_SUPPORTED, map[string]interface{}{
+			"supported_types": []string{usecase.IDENTITY_TYPE_PHONE, usecase.IDENTITY_TYPE_EMAIL},
+		})
+	}
+
+	if len(errs) != 0 {
+		return s.respondError(ctx, newValidationProblem(errs))
+	}
+
+	output, err := s.Usecase.AddIdentity(ctx.Request().Context(), usecase.AddIdentityInput{
+		Id:           id,
+		Type:         identityType,
+		Identifier:   identifier,
+		MailLanguage: lang,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][ProfileIdentityAdd] error when AddIdentity", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsIdentifierExists {
+		return s.respondError(ctx, newProblem(http.StatusConflict, MESSAGE_IDENTIFIER_ALREADY_USED))
+	}
+
+	return ctx.JSON(http.StatusCreated, newIdentityResponse(output.Identity))
+}
+
+// Remove an identity of the user
+// (DELETE /profile/identities/{id})
+func (s *Server) ProfileIdentityRemove(ctx echo.Context, identityId string) error {
+	lang := requestLanguage(ctx, "")
+
+	id, err := s.tokenValidity(ctx)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+
+	// the identity may be a phone number
+	if isImpersonation(ctx) {
+		return s.respondError(ctx, newProblem(http.StatusForbidden, MESSAGE_IMPERSONATION_NOT_ALLOWED))
+	}
+
+	parsedIdentityId, err := strconv.ParseInt(identityId, 10, 64)
+	if err != nil {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_IDENTITY_NOT_FOUND))
+	}
+
+	output, err := s.Usecase.RemoveIdentity(ctx.Request().Context(), usecase.RemoveIdentityInput{
+		Id:         id,
+		IdentityId: parsedIdentityId,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][ProfileIdentityRemove] error when RemoveIdentity", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsNotFound {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_IDENTITY_NOT_FOUND))
+	}
+
+	if output.IsPrimaryPhoneNumber {
+		return s.respondError(ctx, newProblem(http.StatusConflict, MESSAGE_PRIMARY_PHONE_NUMBER_NOT_REMOVABLE))
+	}
+
+	if output.IsLastLogin {
+		return s.respondError(ctx, newProblem(http.StatusConflict, MESSAGE_LAST_LOGIN_NOT_REMOVABLE))
+	}
+
+	return ctx.JSON(http.StatusOK, generated.BasicSuccessResponse{
+		Message: utils.Localize(lang, MESSAGE_IDENTITY_REMOVED, nil),
+	})
+}
+
+// Mail a new verification link and code to an email identity of the user
+// (POST /profile/identities/{id}/verification)
+func (s *Server) ProfileIdentityVerificationSend(ctx echo.Context, identityId string) error {
+	id, err := s.tokenValidity(ctx)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+
+	parsedIdentityId, err := strconv.ParseInt(identityId, 10, 64)
+	if err != nil {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_IDENTITY_NOT_FOUND))
+	}
+
+	userData, err := s.Usecase.GetUserData(ctx.Request().Context(), usecase.GetUserDataInput{
+		Id: id,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][ProfileIdentityVerificationSend] error when GetUserData", err)
+		return s.respondError(ctx, err)
+	}
+
+	lang := requestLanguage(ctx, userData.Language)
+
+	output, err := s.Usecase.SendIdentityVerification(ctx.Request().Context(), usecase.SendIdentityVerificationInput{
+		Id:           id,
+		IdentityId:   parsedIdentityId,
+		MailLanguage: lang,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][ProfileIdentityVerificationSend] error when SendIdentityVerification", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsNotFound {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_IDENTITY_NOT_FOUND))
+	}
+
+	if output.IsNotVerifiable {
+		return s.respondError(ctx, newProblem(http.StatusBadRequest, MESSAGE_IDENTITY_NOT_VERIFIABLE))
+	}
+
+	if output.IsVerified {
+		return s.respondError(ctx, newProblem(http.StatusConflict, MESSAGE_IDENTITY_ALREADY_VERIFIED))
+	}
+
+	return ctx.JSON(http.StatusAccepted, generated.BasicSuccessResponse{
+		Message: utils.Localize(lang, MESSAGE_EMAIL_VERIFICATION_SENT, nil),
+	})
+}
+
+// Deactivate the account of the user, logging in again reactivates it
+// (POST /profile/deactivation)
+func (s *Server) ProfileDeactivate(ctx echo.Context) error {
+	lang := requestLanguage(ctx, "")
+
+	claims, err := s.tokenValidityClaims(ctx)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+
+	if isImpersonation(ctx) {
+		return s.respondError(ctx, newProblem(http.StatusForbidden, MESSAGE_IMPERSONATION_NOT_ALLOWED))
+	}
+
+	// echo does not bind optional fields, see ProfileUpdate
+	password := ctx.FormValue(PASSWORD_FIELD)
+	reason := strings.TrimSpace(ctx.FormValue(REASON_FIELD))
+
+	if reason != "" {
+		if errValidation := validateReason(reason); errValidation != nil {
+			return s.respondError(ctx, newValidationProblem(map[string]error{
+				REASON_FIELD: errValidation,
+			}))
+		}
+	}
+
+	output, err := s.Usecase.DeactivateUser(ctx.Request().Context(), usecase.DeactivateUserInput{
+		Id:              claims.Id,
+		Password:        password,
+		Reason:          reason,
+		AuthenticatedAt: claims.IssuedAt,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][ProfileDeactivate] error when DeactivateUser", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsPasswordWrong {
+		return s.respondError(ctx, newValidationProblem(map[string]error{
+			PASSWORD_FIELD: utils.NewValidationError(utils.CODE_PASSWORD_INCORRECT, nil),
+		}))
+	}
+
+	if output.IsLoginRequired {
+		return s.respondError(ctx, newProblem(http.StatusForbidden, MESSAGE_LOGIN_REQUIRED))
+	}
+
+	return ctx.JSON(http.StatusOK, generated.BasicSuccessResponse{
+		Message: utils.Localize(lang, MESSAGE_ACCOUNT_DEACTIVATED, nil),
+	})
+}
+
+// Delete the account of the user, it is erased after a grace period unless the user logs in again to restore it
+// (DELETE /profile)
+func (s *Server) ProfileDelete(ctx echo.Context) error {
+	lang := requestLanguage(ctx, "")
+
+	claims, err := s.tokenValidityClaims(ctx)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+
+	if isImpersonation(ctx) {
+		return s.respondError(ctx, newProblem(http.StatusForbidden, MESSAGE_IMPERSONATION_NOT_ALLOWED))
+	}
+
+	var (
+		req generated.ProfileDeleteJSONRequestBody
+	)
+
+	// the body is JSON, net/http does not parse forms of DELETE requests
+	ctx.Bind(&req)
+
+	password := ""
+	if req.Password != nil {
+		password = *req.Password
+	}
+
+	output, err := s.Usecase.DeleteUser(ctx.Request().Context(), usecase.DeleteUserInput{
+		Id:              claims.Id,
+		Password:        password,
+		AuthenticatedAt: claims.IssuedAt,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][ProfileDelete] error when DeleteUser", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsPasswordWrong {
+		return s.respondError(ctx, newValidationProblem(map[string]error{
+			PASSWORD_FIELD: utils.NewValidationError(utils.CODE_PASSWORD_INCORRECT, nil),
+		}))
+	}
+
+	if output.IsLoginRequired {
+		return s.respondError(ctx, newProblem(http.StatusForbidden, MESSAGE_LOGIN_REQUIRED))
+	}
+
+	return ctx.JSON(http.StatusOK, generated.AccountDeletionResponse{
+		Message: utils.Localize(lang, MESSAGE_ACCOUNT_DELETION_SCHEDULED, nil),
+		PurgeAt: output.PurgeAt,
+	})
+}
+
+// Export the data kept about the user, large exports are built in the background
+// (GET /profile/export)
+func (s *Server) ProfileExport(ctx echo.Context, params generated.ProfileExportParams) error {
+	lang := requestLanguage(ctx, "")
+
+	id, err := s.tokenValidity(ctx)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+
+	if isImpersonation(ctx) {
+		return s.respondError(ctx, newProblem(http.StatusForbidden, MESSAGE_IMPERSONATION_NOT_ALLOWED))
+	}
+
+	format := usecase.DATA_EXPORT_FORMAT_JSON
+	if params.Format != nil && *params.Format != "" {
+		format = string(*params.Format)
+		if !containsString(usecase.DATA_EXPORT_FORMATS, format) {
+			return s.respondError(ctx, newValidationProblem(map[string]error{
+				FORMAT_FIELD: utils.NewValidationError(utils.CODE_FORMAT_NOT_SUPPORTED, map[string]interface{}{
+					"supported_formats": usecase.DATA_EXPORT_FORMATS,
+				}),
+			}))
+		}
+	}
+
+	output, err := s.Usecase.ExportUserData(ctx.Request().Context(), usecase.ExportUserDataInput{
+		Id:     id,
+		Format: format,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][ProfileExport] error when ExportUserData", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsAsync {
+		return ctx.JSON(http.StatusAccepted, dataExportJobResponse(lang, MESSAGE_DATA_EXPORT_STARTED, output.ExportId, output.ExpiresAt))
+	}
+
+	return respondDataExport(ctx, output.File)
+}
+
+// Download an export built in the background
+// (GET /profile/exports/{id})
+func (s *Server) ProfileExportGet(ctx echo.Context, exportId string) error {
+	lang := requestLanguage(ctx, "")
+
+	id, err := s.tokenValidity(ctx)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+
+	if isImpersonation(ctx) {
+		return s.respondError(ctx, newProblem(http.StatusForbidden, MESSAGE_IMPERSONATION_NOT_ALLOWED))
+	}
+
+	parsedExportId, err := strconv.ParseInt(exportId, 10, 64)
+	if err != nil {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_DATA_EXPORT_NOT_FOUND))
+	}
+
+	output, err := s.Usecase.GetDataExport(ctx.Request().Context(), usecase.GetDataExportInput{
+		Id:       id,
+		ExportId: parsedExportId,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][ProfileExportGet] error when GetDataExport", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsNotFound {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_DATA_EXPORT_NOT_FOUND))
+	}
+
+	if output.IsFailed {
+		return s.respondError(ctx, newProblem(http.StatusGone, MESSAGE_DATA_EXPORT_FAILED))
+	}
+
+	if output.IsPending {
+		return ctx.JSON(http.StatusAccepted, dataExportJobResponse(lang, MESSAGE_DATA_EXPORT_PENDING, parsedExportId, output.ExpiresAt))
+	}
+
+	return respondDataExport(ctx, output.File)
+}
+
+// Upload the avatar of the user
+// (PUT /profile/avatar)
+func (s *Server) ProfileAvatarUpdate(ctx echo.Context) error {
+	lang := requestLanguage(ctx, "")
+
+	id, err := s.tokenValidity(ctx)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+
+	avatar, err := multipartFile(ctx, AVATAR_FIELD)
+	if err != nil {
+		return s.respondError(ctx, newValidationProblem(map[string]error{
+			AVATAR_FIELD: utils.NewValidationError(utils.CODE_AVATAR_REQUIRED, nil),
+		}))
+	}
+	defer avatar.Close()
+
+	output, err := s.Usecase.SetAvatar(ctx.Request().Context(), usecase.SetAvatarInput{
+		Id:      id,
+		Content: avatar,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][ProfileAvatarUpdate] error when SetAvatar", err)
+		return s.respondError(ctx, err)
+	}
+
+	var errValidation error
+	switch {
+	case output.IsTooLarge:
+		errValidation = utils.NewValidationError(utils.CODE_AVATAR_TOO_LARGE, map[string]interface{}{
+			"max_bytes": output.MaxBytes,
+		})
+	case output.IsTypeNotSupported:
+		errValidation = utils.NewValidationError(utils.CODE_AVATAR_TYPE_NOT_SUPPORTED, map[string]interface{}{
+			"supported_types": usecase.AVATAR_TYPES,
+		})
+	case output.IsDimensionsInvalid:
+		errValidation = utils.NewValidationError(utils.CODE_AVATAR_DIMENSIONS_INVALID, map[string]interface{}{
+			"min": usecase.AVATAR_SIZE_SMALL,
+			"max": usecase.AVATAR_MAX_SIDE,
+		})
+	}
+
+	if errValidation != nil {
+		return s.respondError(ctx, newValidationProblem(map[string]error{
+			AVATAR_FIELD: errValidation,
+		}))
+	}
+
+	return ctx.JSON(http.StatusOK, generated.AvatarResponse{
+		Message:    utils.Localize(lang, MESSAGE_AVATAR_UPDATED, nil),
+		AvatarUrls: newAvatarURLs(output.Avatar),
+	})
+}
+
+// Remove the avatar of the user
+// (DELETE /profile/avatar)
+func (s *Server) ProfileAvatarDelete(ctx echo.Context) error {
+	lang := requestLanguage(ctx, "")
+
+	id, err := s.tokenValidity(ctx)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+
+	_, err = s.Usecase.RemoveAvatar(ctx.Request().Context(), usecase.RemoveAvatarInput{
+		Id: id,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][ProfileAvatarDelete] error when RemoveAvatar", err)
+		return s.respondError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, generated.BasicSuccessResponse{
+		Message: utils.Localize(lang, MESSAGE_AVATAR_REMOVED, nil),
+	})
+}
+
+// Search the users, for support staff
+// (GET /admin/users)
+func (s *Server) AdminUsersSearch(ctx echo.Context, params generated.AdminUsersSearchParams) error {
+	requestLanguage(ctx, "")
+
+	var (
+		errs  = make(map[string]error)
+		input = usecase.SearchUsersInput{
+			CreatedFrom: params.CreatedFrom,
+			CreatedTo:   params.CreatedTo,
+			Sort:        usecase.USER_SORT_CREATED_AT_DESC,
+			Limit:       ADMIN_USERS_DEFAULT_LIMIT,
+		}
+	)
+
+	if params.PhonePrefix != nil && *params.PhonePrefix != "" {
+		input.PhonePrefix = *params.PhonePrefix
+		if !phonePrefixPattern.MatchString(input.PhonePrefix) {
+			errs[PHONE_PREFIX_FIELD] = utils.NewValidationError(utils.CODE_PHONE_PREFIX_INVALID, nil)
+		}
+	}
+
+	if params.Name != nil {
+		input.Name = strings.TrimSpace(*params.Name)
+	}
+
+	if input.CreatedFrom != nil && input.CreatedTo != nil && !input.CreatedTo.After(*input.CreatedFrom) {
+		errs[CREATED_TO_FIELD] = utils.NewValidationError(utils.CODE_CREATED_RANGE_INVALID, nil)
+	}
+
+	if params.Status != nil && *params.Status != "" {
+		input.Status = string(*params.Status)
+		if !containsString(usecase.USER_STATUSES, input.Status) {
+			errs[STATUS_FIELD] = utils.NewValidationError(utils.CODE_STATUS_NOT_SUPPORTED, map[string]interface{}{
+				"supported_statuses": usecase.USER_STATUSES,
+			})
+		}
+	}
+
+	if params.Sort != nil && *params.Sort != "" {
+		input.Sort = string(*params.Sort)
+		if !containsString(usecase.USER_SORTS, input.Sort) {
+			errs[SORT_FIELD] = utils.NewValidationError(utils.CODE_SORT_NOT_SUPPORTED, map[string]interface{}{
+				"supported_sorts": usecase.USER_SORTS,
+			})
+		}
+	}
+
+	if params.Limit != nil {
+		input.Limit = *params.Limit
+		if input.Limit < 1 || input.Limit > ADMIN_USERS_MAX_LIMIT {
+			errs[LIMIT_FIELD] = utils.NewValidationError(utils.CODE_LIMIT_OUT_OF_RANGE, map[string]interface{}{
+				"min": 1,
+				"max": ADMIN_USERS_MAX_LIMIT,
+			})
+		}
+	}
+
+	if params.Cursor != nil {
+		input.Cursor = *params.Cursor
+	}
+
+	if len(errs) != 0 {
+		return s.respondError(ctx, newValidationProblem(errs))
+	}
+
+	output, err := s.Usecase.SearchUsers(ctx.Request().Context(), input)
+
+	if err != nil {
+		log.Println("[ERROR][AdminUsersSearch] error when SearchUsers", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsCursorInvalid {
+		return s.respondError(ctx, newValidationProblem(map[string]error{
+			CURSOR_FIELD: utils.NewValidationError(utils.CODE_CURSOR_INVALID, nil),
+		}))
+	}
+
+	resp := generated.AdminUsersResponse{
+		Users: make([]generated.AdminUserSummary, 0, len(output.Users)),
+	}
+
+	for _, user := range output.Users {
+		summary := generated.AdminUserSummary{
+			Id:          strconv.FormatInt(user.Id, 10),
+			FullName:    user.FullName,
+			PhoneNumber: user.PhoneNumber,
+			Status:      generated.UserStatus(user.Status),
+			CreatedAt:   user.CreatedAt,
+		}
+
+		if user.Email != "" {
+			email := user.Email
+			summary.Email = &email
+		}
+
+		resp.Users = append(resp.Users, summary)
+	}
+
+	if output.NextCursor != "" {
+		resp.NextCursor = &output.NextCursor
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// Get the details of a user, for support staff
+// (GET /admin/users/{id})
+func (s *Server) AdminUserGet(ctx echo.Context, userId string) error {
+	requestLanguage(ctx, "")
+
+	id, err := strconv.ParseInt(userId, 10, 64)
+	if err != nil {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_USER_NOT_FOUND))
+	}
+
+	output, err := s.Usecase.GetUserDetails(ctx.Request().Context(), usecase.GetUserDetailsInput{
+		Id: id,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][AdminUserGet] error when GetUserDetails", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsNotFound {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_USER_NOT_FOUND))
+	}
+
+	user := output.User
+	resp := generated.AdminUserResponse{
+		Id:            strconv.FormatInt(user.Id, 10),
+		FullName:      user.FullName,
+		PhoneNumber:   user.PhoneNumber,
+		EmailVerified: user.EmailVerified,
+		Status:        generated.UserStatus(user.Status),
+		UserGroup:     user.UserGroup,
+		Roles:         user.Roles,
+		TotalLogin:    user.TotalLogin,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+	}
+
+	if resp.Roles == nil {
+		resp.Roles = []string{}
+	}
+
+	if user.Email != "" {
+		resp.Email = &user.Email
+	}
+
+	if user.Language != "" {
+		resp.Language = &user.Language
+	}
+
+	if user.UpdatedBy != nil {
+		updatedBy := strconv.FormatInt(*user.UpdatedBy, 10)
+		resp.UpdatedBy = &updatedBy
+	}
+
+	if len(user.Attributes) > 0 {
+		attributes := generated.Attributes(user.Attributes)
+		resp.Attributes = &attributes
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// Move a user to another status, for support staff
+// (PUT /admin/users/{id}/status)
+func (s *Server) AdminUserStatusUpdate(ctx echo.Context, userId string) error {
+	lang := requestLanguage(ctx, "")
+
+	id, err := strconv.ParseInt(userId, 10, 64)
+	if err != nil {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_USER_NOT_FOUND))
+	}
+
+	var (
+		req  generated.AdminUserStatusUpdateFormdataBody
+		errs = make(map[string]error)
+	)
+
+	ctx.Bind(&req)
+
+	status := string(req.Status)
+	if !containsString(usecase.USER_STATUSES, status) {
+		errs[STATUS_FIELD] = utils.NewValidationError(utils.CODE_STATUS_NOT_SUPPORTED, map[string]interface{}{
+			"supported_statuses": usecase.USER_STATUSES,
+		})
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if errValidation := validateReason(reason); errValidation != nil {
+		errs[REASON_FIELD] = errValidation
+	}
+
+	if len(errs) != 0 {
+		return s.respondError(ctx, newValidationProblem(errs))
+	}
+
+	// RequirePermission accepted the token of the admin
+	claims, _ := contextTokenClaims(ctx)
+
+	output, err := s.Usecase.ChangeUserStatus(ctx.Request().Context(), usecase.ChangeUserStatusInput{
+		Id:        id,
+		Status:    status,
+		Reason:    reason,
+		ChangedBy: claims.Id,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][AdminUserStatusUpdate] error when ChangeUserStatus", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsNotFound {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_USER_NOT_FOUND))
+	}
+
+	if output.IsTransitionNotAllowed {
+		return s.respondError(ctx, newProblem(http.StatusConflict, MESSAGE_STATUS_TRANSITION_NOT_ALLOWED))
+	}
+
+	return ctx.JSON(http.StatusOK, generated.BasicSuccessResponse{
+		Message: utils.Localize(lang, MESSAGE_USER_STATUS_CHANGED, nil),
+	})
+}
+
+// List the status changes of a user, for support staff
+// (GET /admin/users/{id}/status-changes)
+func (s *Server) AdminUserStatusChangesGet(ctx echo.Context, userId string) error {
+	requestLanguage(ctx, "")
+
+	id, err := strconv.ParseInt(userId, 10, 64)
+	if err != nil {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_USER_NOT_FOUND))
+	}
+
+	output, err := s.Usecase.GetUserStatusChanges(ctx.Request().Context(), usecase.GetUserStatusChangesInput{
+		Id: id,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][AdminUserStatusChangesGet] error when GetUserStatusChanges", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsNotFound {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_USER_NOT_FOUND))
+	}
+
+	resp := generated.UserStatusChangesResponse{
+		StatusChanges: make([]generated.UserStatusChange, 0, len(output.Changes)),
+	}
+
+	for _, change := range output.Changes {
+		statusChange := generated.UserStatusChange{
+			Id:         strconv.FormatInt(change.Id, 10),
+			FromStatus: generated.UserStatus(change.FromStatus),
+			ToStatus:   generated.UserStatus(change.ToStatus),
+			Reason:     change.Reason,
+			CreatedAt:  change.CreatedAt,
+		}
+
+		if change.ChangedBy != nil {
+			changedBy := strconv.FormatInt(*change.ChangedBy, 10)
+			statusChange.ChangedBy = &changedBy
+		}
+
+		resp.StatusChanges = append(resp.StatusChanges, statusChange)
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// Issue a token to act as a user, for support staff
+// (POST /admin/users/{id}/impersonation)
+func (s *Server) AdminUserImpersonate(ctx echo.Context, userId string) error {
+	lang := requestLanguage(ctx, "")
+
+	id, err := strconv.ParseInt(userId, 10, 64)
+	if err != nil {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_USER_NOT_FOUND))
+	}
+
+	var (
+		req generated.AdminUserImpersonateFormdataBody
+	)
+
+	ctx.Bind(&req)
+
+	reason := strings.TrimSpace(req.Reason)
+	if errValidation := validateReason(reason); errValidation != nil {
+		return s.respondError(ctx, newValidationProblem(map[string]error{
+			REASON_FIELD: errValidation,
+		}))
+	}
+
+	// RequirePermission accepted the token of the admin
+	claims, _ := contextTokenClaims(ctx)
+
+	output, err := s.Usecase.ImpersonateUser(ctx.Request().Context(), usecase.ImpersonateUserInput{
+		Id:      id,
+		AdminId: claims.Id,
+		Reason:  reason,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][AdminUserImpersonate] error when ImpersonateUser", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsNotFound {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_USER_NOT_FOUND))
+	}
+
+	if output.IsNotActive {
+		return s.respondError(ctx, newProblem(http.StatusConflict, MESSAGE_USER_NOT_ACTIVE))
+	}
+
+	return ctx.JSON(http.StatusCreated, generated.ImpersonationResponse{
+		Message:   utils.Localize(lang, MESSAGE_IMPERSONATION_STARTED, nil),
+		Token:     output.Token,
+		ExpiresAt: output.ExpiresAt,
+	})
+}
+
+// Get the JSON Schema the custom profile attributes must match
+// (GET /attribute-schema)
+func (s *Server) AttributeSchemaGet(ctx echo.Context) error {
+	requestLanguage(ctx, "")
+
+	_, err := s.tokenValidity(ctx)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+
+	output, err := s.Usecase.GetAttributeSchema(ctx.Request().Context(), usecase.GetAttributeSchemaInput{})
+	if err != nil {
+		log.Println("[ERROR][AttributeSchemaGet] error when GetAttributeSchema", err)
+		return s.respondError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, generated.AttributeSchemaResponse{
+		Schema: output.Schema,
+	})
+}
+
+// Replace the JSON Schema the custom profile attributes must match
+// (PUT /admin/attribute-schema)
+func (s *Server) AdminAttributeSchemaUpdate(ctx echo.Context) error {
+	lang := requestLanguage(ctx, "")
+
+	var (
+		req generated.AdminAttributeSchemaUpdateJSONRequestBody
+	)
+
+	ctx.Bind(&req)
+
+	// RequirePermission accepted the token of the admin
+	claims, _ := contextTokenClaims(ctx)
+
+	output, err := s.Usecase.SetAttributeSchema(ctx.Request().Context(), usecase.SetAttributeSchemaInput{
+		Schema:    req.Schema,
+		UpdatedBy: claims.Id,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][AdminAttributeSchemaUpdate] error when SetAttributeSchema", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsInvalid {
+		return s.respondError(ctx, newValidationProblem(map[string]error{
+			SCHEMA_FIELD: utils.NewValidationError(utils.CODE_ATTRIBUTE_SCHEMA_INVALID, map[string]interface{}{
+				"reason": output.Reason,
+			}),
+		}))
+	}
+
+	return ctx.JSON(http.StatusOK, generated.BasicSuccessResponse{
+		Message: utils.Localize(lang, MESSAGE_ATTRIBUTE_SCHEMA_UPDATED, nil),
+	})
+}
+
+// Replace the custom attributes of a user, for support staff
+// (PUT /admin/users/{id}/attributes)
+func (s *Server) AdminUserAttributesUpdate(ctx echo.Context, userId string) error {
+	lang := requestLanguage(ctx, "")
+
+	id, err := strconv.ParseInt(userId, 10, 64)
+	if err != nil {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_USER_NOT_FOUND))
+	}
+
+	var (
+		req generated.AdminUserAttributesUpdateJSONRequestBody
+	)
+
+	// attributes that are not an object fail to bind and stay nil
+	ctx.Bind(&req)
+
+	if req.Attributes == nil {
+		return s.respondError(ctx, newValidationProblem(map[string]error{
+			ATTRIBUTES_FIELD: utils.NewValidationError(utils.CODE_ATTRIBUTES_NOT_OBJECT, nil),
+		}))
+	}
+
+	// RequirePermission accepted the token of the admin
+	claims, _ := contextTokenClaims(ctx)
+
+	output, err := s.Usecase.SetUserAttributes(ctx.Request().Context(), usecase.SetUserAttributesInput{
+		Id:         id,
+		Attributes: req.Attributes,
+		UpdatedBy:  claims.Id,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][AdminUserAttributesUpdate] error when SetUserAttributes", err)
+		return s.respondError(ctx, err)
+	}
+
+	if output.IsNotFound {
+		return s.respondError(ctx, newProblem(http.StatusNotFound, MESSAGE_USER_NOT_FOUND))
+	}
+
+	if len(output.AttributeErrors) > 0 {
+		return s.respondError(ctx, newAttributesValidationProblem(output.AttributeErrors))
+	}
+
+	return ctx.JSON(http.StatusOK, generated.BasicSuccessResponse{
+		Message: utils.Localize(lang, MESSAGE_ATTRIBUTES_UPDATED, nil),
+	})
+}
+
+func newIdentityResponse(identity usecase.Identity) generated.Identity {
+	return generated.Identity{
+		Id:         strconv.FormatInt(identity.Id, 10),
+		Type:       identity.Type,
+		Identifier: identity.Identifier,
+		Primary:    identity.IsPrimary,
+		VerifiedAt: identity.VerifiedAt,
+	}
+}
+
+// newOIDCFlowCookie keeps the flow token of an OpenID Connect login for the
+// callback of provider only. SameSite Lax lets it through the redirect back
+// from the provider.
+func newOIDCFlowCookie(ctx echo.Context, provider, flowToken string) *http.Cookie {
+	return &http.Cookie{
+		Name:     OIDC_FLOW_COOKIE,
+		Value:    flowToken,
+		Path:     "/oidc/" + provider,
+		HttpOnly: true,
+		Secure:   ctx.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// requestLanguage picks the language of the response: the preferred language
+// of the user when known, then the Accept-Language header, then the default.
+// The language is kept on ctx for HandleError.
+func requestLanguage(ctx echo.Context, preferred string) string {
+	lang := utils.DefaultLanguage()
+
+	if preferred != "" && utils.IsSupportedLanguage(preferred) {
+		lang = preferred
+	} else if accepted := utils.ParseAcceptLanguage(ctx.Request().Header.Get("Accept-Language")); accepted != "" {
+		lang = accepted
+	}
+
+	ctx.Set(languageContextKey, lang)
+	return lang
+}
+
+// validateReason checks the reason given for a change to the account of a
+// user, see REASON_MAX_LENGTH.
+func validateReason(reason string) error {
+	if reason == "" {
+		return utils.NewValidationError(utils.CODE_REASON_REQUIRED, nil)
+	}
+
+	if utf8.RuneCountInString(reason) > REASON_MAX_LENGTH {
+		return utils.NewValidationError(utils.CODE_REASON_TOO_LONG, map[string]interface{}{
+			"max": REASON_MAX_LENGTH,
+		})
+	}
+
+	return nil
+}
+
+// dataExportJobResponse points to where the background export exportId can
+// be downloaded.
+func dataExportJobResponse(lang, message string, exportId int64, expiresAt time.Time) generated.DataExportJobResponse {
+	return generated.DataExportJobResponse{
+		Message:     utils.Localize(lang, message, nil),
+		Id:          exportId,
+		DownloadUrl: fmt.Sprintf("/profile/exports/%d", exportId),
+		ExpiresAt:   expiresAt,
+	}
+}
+
+// respondDataExport sends an export as a file to save rather than to display.
+func respondDataExport(ctx echo.Context, file usecase.DataExportFile) error {
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", file.FileName))
+	return ctx.Blob(http.StatusOK, file.ContentType, file.Content)
+}
+
+func newAvatarURLs(urls usecase.AvatarURLs) generated.AvatarURLs {
+	return generated.AvatarURLs{
+		Small:  urls.Small,
+		Medium: urls.Medium,
+		Large:  urls.Large,
+	}
+}
+
+// multipartFile returns the part of the multipart request body named field.
+// It is read straight from the request rather than spooled to memory or disk,
+// so that the usecase can stop reading at its size limit.
+func multipartFile(ctx echo.Context, field string) (*multipart.Part, error) {
+	reader, err := ctx.Request().MultipartReader()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		if part.FormName() == field {
+			return part, nil
+		}
+
+		part.Close()
+	}
+}
+
+// parseAttributes decodes custom attributes given as a JSON object in a form
+// field, they are nil when the field is empty.
+func parseAttributes(value string) (map[string]interface{}, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var attributes map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &attributes); err != nil || attributes == nil {
+		return nil, utils.NewValidationError(utils.CODE_ATTRIBUTES_NOT_OBJECT, nil)
+	}
+
+	return attributes, nil
+}
+
+// patchString decodes a member of a merge patch that is a string or null.
+func patchString(raw json.RawMessage) (value string, isNull bool, err error) {
+	var decoded *string
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return "", false, utils.NewValidationError(utils.CODE_VALUE_NOT_STRING, nil)
+	}
+
+	if decoded == nil {
+		return "", true, nil
+	}
+
+	return *decoded, false, nil
+}
+
+// newAttributesValidationProblem reports every attribute error on the field
+// "attributes.<path>", or "attributes" for the attributes as a whole.
+func newAttributesValidationProblem(attributeErrors []usecase.AttributeError) *Problem {
+	fields := make(map[string]utils.ValidationErrors)
+	for _, attributeErr := range attributeErrors {
+		field := strings.Join(append([]string{ATTRIBUTES_FIELD}, attributeErr.Path...), ".")
+
+		var err error
+		if attributeErr.IsReadOnly {
+			err = utils.NewValidationError(utils.CODE_ATTRIBUTE_READ_ONLY, nil)
+		} else {
+			err = utils.NewValidationError(utils.CODE_ATTRIBUTE_INVALID, map[string]interface{}{
+				"reason": attributeErr.Reason,
+			})
+		}
+
+		fields[field] = append(fields[field], err)
+	}
+
+	errs := make(map[string]error, len(fields))
+	for field, fieldErrs := range fields {
+		errs[field] = fieldErrs
+	}
+
+	return newValidationProblem(errs)
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
 }
@@ -1,16 +1,20 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
-	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/SawitProRecruitment/UserService/generated"
 	"github.com/SawitProRecruitment/UserService/usecase"
@@ -29,13 +33,14 @@ func TestServer_Registration(t *testing.T) {
 		ctx func() (echo.Context, *httptest.ResponseRecorder)
 	}
 	tests := []struct {
-		name     string
-		args     args
-		mockFunc func(args)
-		respFunc func(*httptest.ResponseRecorder) interface{}
-		wantCode int
-		wantResp interface{}
-		wantErr  bool
+		name            string
+		args            args
+		antiEnumeration bool
+		mockFunc        func(args)
+		respFunc        func(*httptest.ResponseRecorder) interface{}
+		wantCode        int
+		wantResp        interface{}
+		wantErr         bool
 	}{
 		{
 			name: "error validations",
@@ -57,29 +62,138 @@ func TestServer_Registration(t *testing.T) {
 			},
 			mockFunc: func(a args) {
 			},
-			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
-				var resp generated.ValidationErrorsResponse
-				json.Unmarshal(rec.Body.Bytes(), &resp)
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/registration",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "full_name",
+						Code:    generated.FULLNAMETOOSHORT,
+						Message: "must be at minimum 3 characters and maximum 60 characters",
+						Params:  &map[string]interface{}{"min": float64(3), "max": float64(60)},
+					},
+					generated.ValidationError{
+						Field:   "password",
+						Code:    generated.PASSWORDTOOSHORT,
+						Message: "must be at minimum 6 characters and maximum 64 characters",
+						Params:  &map[string]interface{}{"min": float64(6), "max": float64(64)},
+					},
+					generated.ValidationError{
+						Field:   "password",
+						Code:    generated.PASSWORDMISSINGUPPERCASE,
+						Message: "must contain at least 1 capital character",
+						Params:  &map[string]interface{}{"min": float64(1)},
+					},
+					generated.ValidationError{
+						Field:   "password",
+						Code:    generated.PASSWORDMISSINGNUMBER,
+						Message: "must contain at least 1 number",
+						Params:  &map[string]interface{}{"min": float64(1)},
+					},
+					generated.ValidationError{
+						Field:   "password",
+						Code:    generated.PASSWORDMISSINGSYMBOL,
+						Message: "must contain at least 1 special (non alpha-numeric) character",
+						Params:  &map[string]interface{}{"min": float64(1)},
+					},
+					generated.ValidationError{
+						Field:   "password",
+						Code:    generated.PASSWORDTOOWEAK,
+						Message: "is too easy to guess, please choose a stronger password",
+						Params:  &map[string]interface{}{"score": float64(1), "min_score": float64(2)},
+					},
+					generated.ValidationError{
+						Field:   "phone_number",
+						Code:    generated.PHONENUMBERINVALIDLENGTH,
+						Message: "must have 8 to 12 digits after the country code “+62”",
+						Params:  &map[string]interface{}{"country_code": "62", "lengths": []interface{}{float64(8), float64(9), float64(10), float64(11), float64(12)}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "error breached password",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
 
-				sort.Slice(resp, func(i, j int) bool {
-					return resp[i].Field < resp[j].Field
-				})
+					data := url.Values{}
+					data.Set("phone_number", "+62812345678")
+					data.Set("full_name", "fullloooo")
+					data.Set("password", "Password1!")
 
-				return resp
+					req := httptest.NewRequest(http.MethodPost, "/registration", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
 			},
+			respFunc: problemResponse,
 			wantCode: http.StatusBadRequest,
-			wantResp: generated.ValidationErrorsResponse{
-				generated.ValidationError{
-					Field:   "full_name",
-					Message: "must be at minimum 3 characters and maximum 60 characters",
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/registration",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "password",
+						Code:    generated.PASSWORDBREACHED,
+						Message: "is a commonly used password that has appeared in data breaches, please choose a different one",
+					},
 				},
-				generated.ValidationError{
-					Field:   "password",
-					Message: "must be at minimum 6 characters and maximum 64 characters & must containing at least 1 capital characters AND 1 number AND 1 special (non alpha-numeric) characters",
+			},
+			wantErr: false,
+		},
+		{
+			name: "error password contains personal info",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					data := url.Values{}
+					data.Set("phone_number", "+62812345678")
+					data.Set("full_name", "Budi Santoso")
+					data.Set("password", "Budi#812345678")
+
+					req := httptest.NewRequest(http.MethodPost, "/registration", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
 				},
-				generated.ValidationError{
-					Field:   "phone_number",
-					Message: "must be at minimum 10 characters and maximum 13 characters & must start with the Indonesia country code “+62”",
+			},
+			mockFunc: func(a args) {
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/registration",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "password",
+						Code:    generated.PASSWORDCONTAINSPHONENUMBER,
+						Message: "must not contain your phone number",
+					},
+					generated.ValidationError{
+						Field:   "password",
+						Code:    generated.PASSWORDCONTAINSNAME,
+						Message: "must not contain your name",
+					},
 				},
 			},
 			wantErr: false,
@@ -105,21 +219,21 @@ func TestServer_Registration(t *testing.T) {
 			mockFunc: func(a args) {
 				mockUsecase.EXPECT().RegisterNewUser(gomock.Any(), gomock.Eq(
 					usecase.RegisterNewUserInput{
-						PhoneNumber: "+62812345678",
-						FullName:    "fullloooo",
-						Password:    "AAssff1!",
+						PhoneNumber:  "+62812345678",
+						FullName:     "fullloooo",
+						Password:     "AAssff1!",
+						MailLanguage: "en",
 					},
 				)).Return(usecase.RegisterNewUserOutput{}, errors.New("test"))
 			},
-			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
-				var resp generated.BasicErrorResponse
-				json.Unmarshal(rec.Body.Bytes(), &resp)
-
-				return resp
-			},
+			respFunc: problemResponse,
 			wantCode: http.StatusInternalServerError,
-			wantResp: generated.BasicErrorResponse{
-				Message: "Internal server error",
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/registration",
 			},
 			wantErr: false,
 		},
@@ -144,28 +258,102 @@ func TestServer_Registration(t *testing.T) {
 			mockFunc: func(a args) {
 				mockUsecase.EXPECT().RegisterNewUser(gomock.Any(), gomock.Eq(
 					usecase.RegisterNewUserInput{
-						PhoneNumber: "+62812345678",
-						FullName:    "fullloooo",
-						Password:    "AAssff1!",
+						PhoneNumber:  "+62812345678",
+						FullName:     "fullloooo",
+						Password:     "AAssff1!",
+						MailLanguage: "en",
 					},
 				)).Return(usecase.RegisterNewUserOutput{
 					IsPhoneNumberExists: true,
 				}, nil)
 			},
-			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
-				var resp generated.BasicErrorResponse
-				json.Unmarshal(rec.Body.Bytes(), &resp)
+			respFunc: problemResponse,
+			wantCode: http.StatusConflict,
+			wantResp: generated.Problem{
+				Type:     "/problems/phone-number-already-used",
+				Title:    "Phone number already used",
+				Status:   http.StatusConflict,
+				Detail:   "Another account is already registered with this phone number",
+				Instance: "/registration",
+			},
+			wantErr: false,
+		},
+		{
+			name: "error invalid email",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
 
-				return resp
+					data := url.Values{}
+					data.Set("phone_number", "+62812345678")
+					data.Set("full_name", "fullloooo")
+					data.Set("password", "AAssff1!")
+					data.Set("email", "name@localhost")
+
+					req := httptest.NewRequest(http.MethodPost, "/registration", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/registration",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "email",
+						Code:    generated.EMAILINVALID,
+						Message: "must be a valid email address, e.g. “name@example.com”",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "error email already exists",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					data := url.Values{}
+					data.Set("phone_number", "+62812345678")
+					data.Set("full_name", "fullloooo")
+					data.Set("password", "AAssff1!")
+					data.Set("email", "name@example.com")
+
+					req := httptest.NewRequest(http.MethodPost, "/registration", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
 			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().RegisterNewUser(gomock.Any(), gomock.Any()).Return(usecase.RegisterNewUserOutput{
+					IsEmailExists: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
 			wantCode: http.StatusConflict,
-			wantResp: generated.BasicErrorResponse{
-				Message: "Phone number already used",
+			wantResp: generated.Problem{
+				Type:     "/problems/email-already-used",
+				Title:    "Email already used",
+				Status:   http.StatusConflict,
+				Detail:   "Another account already uses this email address",
+				Instance: "/registration",
 			},
 			wantErr: false,
 		},
 		{
-			name: "successful",
+			name: "successful, email with the domain lowercased",
 			args: args{
 				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
 					e := echo.New()
@@ -174,6 +362,7 @@ func TestServer_Registration(t *testing.T) {
 					data.Set("phone_number", "+62812345678")
 					data.Set("full_name", "fullloooo")
 					data.Set("password", "AAssff1!")
+					data.Set("email", " Name@Example.COM ")
 
 					req := httptest.NewRequest(http.MethodPost, "/registration", strings.NewReader(data.Encode()))
 					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
@@ -185,12 +374,14 @@ func TestServer_Registration(t *testing.T) {
 			mockFunc: func(a args) {
 				mockUsecase.EXPECT().RegisterNewUser(gomock.Any(), gomock.Eq(
 					usecase.RegisterNewUserInput{
-						PhoneNumber: "+62812345678",
-						FullName:    "fullloooo",
-						Password:    "AAssff1!",
+						PhoneNumber:  "+62812345678",
+						FullName:     "fullloooo",
+						Password:     "AAssff1!",
+						Email:        "Name@example.com",
+						MailLanguage: "en",
 					},
 				)).Return(usecase.RegisterNewUserOutput{
-					Id: 5,
+					Id: 12,
 				}, nil)
 			},
 			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
@@ -201,139 +392,94 @@ func TestServer_Registration(t *testing.T) {
 			},
 			wantCode: http.StatusOK,
 			wantResp: generated.SuccessRegistrationResponse{
-				Id: "5",
+				Id: "12",
 			},
 			wantErr: false,
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			tt.mockFunc(tt.args)
-			s := NewServer(NewServerOptions{
-				Usecase: mockUsecase,
-			})
-
-			ctx, rec := tt.args.ctx()
-
-			if err := s.Registration(ctx); (err != nil) != tt.wantErr {
-				t.Errorf("Server.Registration() error = %v, wantErr %v", err, tt.wantErr)
-			}
-
-			assert.Equal(t, tt.wantCode, rec.Code)
-
-			resp := tt.respFunc(rec)
-
-			if respRaw, ok := resp.(generated.ValidationErrorsResponse); ok {
-				sort.Slice(respRaw, func(i, j int) bool {
-					return respRaw[i].Field < respRaw[j].Field
-				})
-			}
-
-			assert.Equal(t, tt.wantResp, resp)
-		})
-	}
-}
-
-func TestServer_Login(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
-	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
-
-	type args struct {
-		ctx func() (echo.Context, *httptest.ResponseRecorder)
-	}
-	tests := []struct {
-		name     string
-		args     args
-		mockFunc func(args)
-		respFunc func(*httptest.ResponseRecorder) interface{}
-		wantCode int
-		wantResp interface{}
-		wantErr  bool
-	}{
 		{
-			name: "error when Login",
+			name: "successful, anti-enumeration new phone number",
 			args: args{
 				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
 					e := echo.New()
 
 					data := url.Values{}
 					data.Set("phone_number", "+62812345678")
+					data.Set("full_name", "fullloooo")
 					data.Set("password", "AAssff1!")
 
-					req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(data.Encode()))
+					req := httptest.NewRequest(http.MethodPost, "/registration", strings.NewReader(data.Encode()))
 					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 					rec := httptest.NewRecorder()
 
 					return e.NewContext(req, rec), rec
 				},
 			},
+			antiEnumeration: true,
 			mockFunc: func(a args) {
-				mockUsecase.EXPECT().Login(gomock.Any(), gomock.Eq(usecase.LoginInput{
-					PhoneNumber: "+62812345678",
-					Password:    "AAssff1!",
-				})).Return(usecase.LoginOutput{}, errors.New("test"))
+				mockUsecase.EXPECT().RegisterNewUser(gomock.Any(), gomock.Any()).Return(usecase.RegisterNewUserOutput{
+					Id: 5,
+				}, nil)
 			},
 			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
-				var resp generated.BasicErrorResponse
+				var resp generated.BasicSuccessResponse
 				json.Unmarshal(rec.Body.Bytes(), &resp)
 
 				return resp
 			},
-			wantCode: http.StatusInternalServerError,
-			wantResp: generated.BasicErrorResponse{
-				Message: "Internal server error",
+			wantCode: http.StatusAccepted,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Registration received. If the phone number was not registered yet, you can now log in",
 			},
 			wantErr: false,
 		},
 		{
-			name: "error data not found",
+			name: "successful, anti-enumeration phone number already exists",
 			args: args{
 				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
 					e := echo.New()
 
 					data := url.Values{}
 					data.Set("phone_number", "+62812345678")
+					data.Set("full_name", "fullloooo")
 					data.Set("password", "AAssff1!")
 
-					req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(data.Encode()))
+					req := httptest.NewRequest(http.MethodPost, "/registration", strings.NewReader(data.Encode()))
 					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 					rec := httptest.NewRecorder()
 
 					return e.NewContext(req, rec), rec
 				},
 			},
+			antiEnumeration: true,
 			mockFunc: func(a args) {
-				mockUsecase.EXPECT().Login(gomock.Any(), gomock.Eq(usecase.LoginInput{
-					PhoneNumber: "+62812345678",
-					Password:    "AAssff1!",
-				})).Return(usecase.LoginOutput{
-					IsDataNotFound: true,
+				mockUsecase.EXPECT().RegisterNewUser(gomock.Any(), gomock.Any()).Return(usecase.RegisterNewUserOutput{
+					IsPhoneNumberExists: true,
 				}, nil)
 			},
 			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
-				var resp generated.BasicErrorResponse
+				var resp generated.BasicSuccessResponse
 				json.Unmarshal(rec.Body.Bytes(), &resp)
 
 				return resp
 			},
-			wantCode: http.StatusBadRequest,
-			wantResp: generated.BasicErrorResponse{
-				Message: "Phone number not found",
+			wantCode: http.StatusAccepted,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Registration received. If the phone number was not registered yet, you can now log in",
 			},
 			wantErr: false,
 		},
 		{
-			name: "error password wrong",
+			name: "successful",
 			args: args{
 				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
 					e := echo.New()
 
 					data := url.Values{}
 					data.Set("phone_number", "+62812345678")
+					data.Set("full_name", "fullloooo")
 					data.Set("password", "AAssff1!")
 
-					req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(data.Encode()))
+					req := httptest.NewRequest(http.MethodPost, "/registration", strings.NewReader(data.Encode()))
 					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 					rec := httptest.NewRecorder()
 
@@ -341,36 +487,40 @@ func TestServer_Login(t *testing.T) {
 				},
 			},
 			mockFunc: func(a args) {
-				mockUsecase.EXPECT().Login(gomock.Any(), gomock.Eq(usecase.LoginInput{
-					PhoneNumber: "+62812345678",
-					Password:    "AAssff1!",
-				})).Return(usecase.LoginOutput{
-					IsPasswordWrong: true,
+				mockUsecase.EXPECT().RegisterNewUser(gomock.Any(), gomock.Eq(
+					usecase.RegisterNewUserInput{
+						PhoneNumber:  "+62812345678",
+						FullName:     "fullloooo",
+						Password:     "AAssff1!",
+						MailLanguage: "en",
+					},
+				)).Return(usecase.RegisterNewUserOutput{
+					Id: 5,
 				}, nil)
 			},
 			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
-				var resp generated.BasicErrorResponse
+				var resp generated.SuccessRegistrationResponse
 				json.Unmarshal(rec.Body.Bytes(), &resp)
 
 				return resp
 			},
-			wantCode: http.StatusBadRequest,
-			wantResp: generated.BasicErrorResponse{
-				Message: "Wrong password",
+			wantCode: http.StatusOK,
+			wantResp: generated.SuccessRegistrationResponse{
+				Id: "5",
 			},
 			wantErr: false,
-		},
-		{
-			name: "success",
+		}, {
+			name: "successful, national number stored in E.164 format",
 			args: args{
 				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
 					e := echo.New()
 
 					data := url.Values{}
-					data.Set("phone_number", "+62812345678")
+					data.Set("phone_number", "0812-3456-789")
+					data.Set("full_name", "fullloooo")
 					data.Set("password", "AAssff1!")
 
-					req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(data.Encode()))
+					req := httptest.NewRequest(http.MethodPost, "/registration", strings.NewReader(data.Encode()))
 					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 					rec := httptest.NewRecorder()
 
@@ -378,170 +528,302 @@ func TestServer_Login(t *testing.T) {
 				},
 			},
 			mockFunc: func(a args) {
-				mockUsecase.EXPECT().Login(gomock.Any(), gomock.Eq(usecase.LoginInput{
-					PhoneNumber: "+62812345678",
-					Password:    "AAssff1!",
-				})).Return(usecase.LoginOutput{
-					Token: "tokennn",
+				mockUsecase.EXPECT().RegisterNewUser(gomock.Any(), gomock.Eq(
+					usecase.RegisterNewUserInput{
+						PhoneNumber:  "+628123456789",
+						FullName:     "fullloooo",
+						Password:     "AAssff1!",
+						MailLanguage: "en",
+					},
+				)).Return(usecase.RegisterNewUserOutput{
+					Id: 5,
 				}, nil)
 			},
 			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
-				var resp generated.LoginSuccessResponse
+				var resp generated.SuccessRegistrationResponse
 				json.Unmarshal(rec.Body.Bytes(), &resp)
 
 				return resp
 			},
 			wantCode: http.StatusOK,
-			wantResp: generated.LoginSuccessResponse{
-				Message: "Login success",
-				Token:   "tokennn",
+			wantResp: generated.SuccessRegistrationResponse{
+				Id: "5",
 			},
 			wantErr: false,
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			tt.mockFunc(tt.args)
-			s := NewServer(NewServerOptions{
-				Usecase: mockUsecase,
-			})
-
-			ctx, rec := tt.args.ctx()
-
-			if err := s.Login(ctx); (err != nil) != tt.wantErr {
-				t.Errorf("Server.Login() error = %v, wantErr %v", err, tt.wantErr)
-			}
+		{
+			name: "successful, Malaysian number",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
 
-			assert.Equal(t, tt.wantCode, rec.Code)
+					data := url.Values{}
+					data.Set("phone_number", "+60 12-345 6789")
+					data.Set("full_name", "fullloooo")
+					data.Set("password", "AAssff1!")
 
-			resp := tt.respFunc(rec)
-			assert.Equal(t, tt.wantResp, resp)
-		})
-	}
-}
+					req := httptest.NewRequest(http.MethodPost, "/registration", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					rec := httptest.NewRecorder()
 
-func TestServer_ProfileGet(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
-	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
-
-	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
-	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
-
-	type args struct {
-		ctx func() (echo.Context, *httptest.ResponseRecorder)
-	}
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().RegisterNewUser(gomock.Any(), gomock.Eq(
+					usecase.RegisterNewUserInput{
+						PhoneNumber:  "+60123456789",
+						FullName:     "fullloooo",
+						Password:     "AAssff1!",
+						MailLanguage: "en",
+					},
+				)).Return(usecase.RegisterNewUserOutput{
+					Id: 5,
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.SuccessRegistrationResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
 
-	tests := []struct {
-		name     string
-		args     args
-		mockFunc func(args)
-		respFunc func(*httptest.ResponseRecorder) interface{}
-		wantCode int
-		wantResp interface{}
-		wantErr  bool
-	}{
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.SuccessRegistrationResponse{
+				Id: "5",
+			},
+			wantErr: false,
+		},
 		{
-			name: "Error token invalid",
+			name: "successful, full name normalized to NFC with collapsed whitespace",
 			args: args{
 				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
 					e := echo.New()
 
-					// token, _ := utils.GenerateToken(50)
-
-					token := "abcd"
-
-					token = fmt.Sprintf("Bearer %s", token)
+					data := url.Values{}
+					data.Set("phone_number", "+62812345678")
+					data.Set("full_name", "  Jose\u0301 \t Rami\u0301rez ")
+					data.Set("password", "AAssff1!")
 
-					req := httptest.NewRequest(http.MethodGet, "/profile", nil)
-					req.Header.Add("Authorization", token)
+					req := httptest.NewRequest(http.MethodPost, "/registration", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 					rec := httptest.NewRecorder()
 
 					return e.NewContext(req, rec), rec
 				},
 			},
-			mockFunc: func(a args) {},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().RegisterNewUser(gomock.Any(), gomock.Eq(
+					usecase.RegisterNewUserInput{
+						PhoneNumber:  "+62812345678",
+						FullName:     "Jos\u00e9 Ram\u00edrez",
+						Password:     "AAssff1!",
+						MailLanguage: "en",
+					},
+				)).Return(usecase.RegisterNewUserOutput{
+					Id: 5,
+				}, nil)
+			},
 			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
-				var resp generated.BasicErrorResponse
+				var resp generated.SuccessRegistrationResponse
 				json.Unmarshal(rec.Body.Bytes(), &resp)
 
 				return resp
 			},
-			wantCode: http.StatusForbidden,
-			wantResp: generated.BasicErrorResponse{
-				Message: "Forbiddenn",
+			wantCode: http.StatusOK,
+			wantResp: generated.SuccessRegistrationResponse{
+				Id: "5",
 			},
 			wantErr: false,
 		},
 		{
-			name: "Error when GetUserData",
+			name: "successful, accented full name counted by characters",
 			args: args{
 				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
 					e := echo.New()
 
-					token, _ := utils.GenerateToken(50)
-
-					token = fmt.Sprintf("Bearer %s", token)
+					data := url.Values{}
+					data.Set("phone_number", "+62812345678")
+					data.Set("full_name", strings.Repeat("e\u0301", 60))
+					data.Set("password", "AAssff1!")
 
-					req := httptest.NewRequest(http.MethodGet, "/profile", nil)
-					req.Header.Add("Authorization", token)
+					req := httptest.NewRequest(http.MethodPost, "/registration", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 					rec := httptest.NewRecorder()
 
 					return e.NewContext(req, rec), rec
 				},
 			},
 			mockFunc: func(a args) {
-				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Eq(usecase.GetUserDataInput{
-					Id: 50,
-				})).Return(usecase.GetUserDataOutput{}, errors.New("test"))
+				mockUsecase.EXPECT().RegisterNewUser(gomock.Any(), gomock.Eq(
+					usecase.RegisterNewUserInput{
+						PhoneNumber:  "+62812345678",
+						FullName:     strings.Repeat("\u00e9", 60),
+						Password:     "AAssff1!",
+						MailLanguage: "en",
+					},
+				)).Return(usecase.RegisterNewUserOutput{
+					Id: 5,
+				}, nil)
 			},
 			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
-				var resp generated.BasicErrorResponse
+				var resp generated.SuccessRegistrationResponse
 				json.Unmarshal(rec.Body.Bytes(), &resp)
 
 				return resp
 			},
-			wantCode: http.StatusInternalServerError,
-			wantResp: generated.BasicErrorResponse{
-				Message: "Internal server error",
+			wantCode: http.StatusOK,
+			wantResp: generated.SuccessRegistrationResponse{
+				Id: "5",
 			},
 			wantErr: false,
 		},
 		{
-			name: "Success",
+			name: "error full name only whitespace",
 			args: args{
 				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
 					e := echo.New()
 
-					token, _ := utils.GenerateToken(50)
+					data := url.Values{}
+					data.Set("phone_number", "+62812345678")
+					data.Set("full_name", "   \t  ")
+					data.Set("password", "AAssff1!")
+
+					req := httptest.NewRequest(http.MethodPost, "/registration", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					rec := httptest.NewRecorder()
 
-					token = fmt.Sprintf("Bearer %s", token)
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/registration",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "full_name",
+						Code:    generated.FULLNAMETOOSHORT,
+						Message: "must be at minimum 3 characters and maximum 60 characters",
+						Params:  &map[string]interface{}{"min": float64(3), "max": float64(60)},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "error full name with invisible characters",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
 
-					req := httptest.NewRequest(http.MethodGet, "/profile", nil)
-					req.Header.Add("Authorization", token)
+					data := url.Values{}
+					data.Set("phone_number", "+62812345678")
+					data.Set("full_name", "John\u200bDoe")
+					data.Set("password", "AAssff1!")
+
+					req := httptest.NewRequest(http.MethodPost, "/registration", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 					rec := httptest.NewRecorder()
 
 					return e.NewContext(req, rec), rec
 				},
 			},
-			mockFunc: func(a args) {
-				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Eq(usecase.GetUserDataInput{
-					Id: 50,
-				})).Return(usecase.GetUserDataOutput{
-					PhoneNumber: "123456789",
-					FullName:    "fullnamee",
-				}, nil)
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/registration",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "full_name",
+						Code:    generated.FULLNAMEINVALIDCHARACTERS,
+						Message: "must not contain control or invisible characters",
+					},
+				},
 			},
-			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
-				var resp generated.ProfileGetResponse
-				json.Unmarshal(rec.Body.Bytes(), &resp)
+			wantErr: false,
+		},
+		{
+			name: "error country not allowed",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
 
-				return resp
+					data := url.Values{}
+					data.Set("phone_number", "+44 20 7946 0958")
+					data.Set("full_name", "fullloooo")
+					data.Set("password", "AAssff1!")
+
+					req := httptest.NewRequest(http.MethodPost, "/registration", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
 			},
-			wantCode: http.StatusOK,
-			wantResp: generated.ProfileGetResponse{
-				PhoneNumber: "123456789",
-				FullName:    "fullnamee",
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/registration",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "phone_number",
+						Code:    generated.PHONENUMBERCOUNTRYNOTALLOWED,
+						Message: "must be a phone number from one of the supported countries: ID, MY, SG",
+						Params:  &map[string]interface{}{"country": "GB", "allowed_countries": []interface{}{"ID", "MY", "SG"}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "error unknown country code",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					data := url.Values{}
+					data.Set("phone_number", "+999123456789")
+					data.Set("full_name", "fullloooo")
+					data.Set("password", "AAssff1!")
+
+					req := httptest.NewRequest(http.MethodPost, "/registration", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/registration",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "phone_number",
+						Code:    generated.PHONENUMBERINVALIDCOUNTRYCODE,
+						Message: "must start with a valid country code",
+					},
+				},
 			},
 			wantErr: false,
 		},
@@ -550,35 +832,36 @@ func TestServer_ProfileGet(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockFunc(tt.args)
 			s := NewServer(NewServerOptions{
-				Usecase: mockUsecase,
+				Usecase:                     mockUsecase,
+				RegistrationAntiEnumeration: tt.antiEnumeration,
 			})
 
 			ctx, rec := tt.args.ctx()
 
-			if err := s.ProfileGet(ctx); (err != nil) != tt.wantErr {
-				t.Errorf("Server.ProfileGet() error = %v, wantErr %v", err, tt.wantErr)
+			if err := s.Registration(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.Registration() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
 			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
 
 			resp := tt.respFunc(rec)
+
 			assert.Equal(t, tt.wantResp, resp)
 		})
 	}
 }
 
-func TestServer_ProfileUpdate(t *testing.T) {
+func TestServer_Login(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
 
-	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
-	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
-
 	type args struct {
 		ctx func() (echo.Context, *httptest.ResponseRecorder)
 	}
-
 	tests := []struct {
 		name     string
 		args     args
@@ -589,202 +872,433 @@ func TestServer_ProfileUpdate(t *testing.T) {
 		wantErr  bool
 	}{
 		{
-			name: "Error when login",
+			name: "error when Login",
 			args: args{
 				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
 					e := echo.New()
 
-					// token, _ := utils.GenerateToken(50)
-					token := "abc"
-
-					token = fmt.Sprintf("Bearer %s", token)
+					data := url.Values{}
+					data.Set("phone_number", "+62812345678")
+					data.Set("password", "AAssff1!")
 
-					req := httptest.NewRequest(http.MethodPost, "/profile", nil)
-					req.Header.Add("Authorization", token)
+					req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 					rec := httptest.NewRecorder()
 
 					return e.NewContext(req, rec), rec
 				},
 			},
 			mockFunc: func(a args) {
+				mockUsecase.EXPECT().Login(gomock.Any(), gomock.Eq(usecase.LoginInput{
+					PhoneNumber: "+62812345678",
+					Password:    "AAssff1!",
+					IP:          "192.0.2.1",
+				})).Return(usecase.LoginOutput{}, errors.New("test"))
 			},
-			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
-				var resp generated.BasicErrorResponse
-				json.Unmarshal(rec.Body.Bytes(), &resp)
-
-				return resp
-			},
-			wantCode: http.StatusForbidden,
-			wantResp: generated.BasicErrorResponse{
-				Message: "Forbidden",
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/login",
 			},
 			wantErr: false,
 		},
 		{
-			name: "Error validations",
+			name: "error invalid credentials",
 			args: args{
 				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
 					e := echo.New()
 
-					token, _ := utils.GenerateToken(50)
-
-					token = fmt.Sprintf("Bearer %s", token)
-
 					data := url.Values{}
-					data.Set("phone_number", "08123456")
-					data.Set("full_name", "fu")
+					data.Set("phone_number", "+62812345678")
+					data.Set("password", "AAssff1!")
 
-					req := httptest.NewRequest(http.MethodPost, "/profile", strings.NewReader(data.Encode()))
+					req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(data.Encode()))
 					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-					req.Header.Add("Authorization", token)
 					rec := httptest.NewRecorder()
 
 					return e.NewContext(req, rec), rec
 				},
 			},
 			mockFunc: func(a args) {
+				mockUsecase.EXPECT().Login(gomock.Any(), gomock.Eq(usecase.LoginInput{
+					PhoneNumber: "+62812345678",
+					Password:    "AAssff1!",
+					IP:          "192.0.2.1",
+				})).Return(usecase.LoginOutput{
+					IsInvalidCredentials: true,
+				}, nil)
 			},
-			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
-				var resp generated.ValidationErrorsResponse
-				json.Unmarshal(rec.Body.Bytes(), &resp)
-
-				return resp
-			},
-			wantCode: http.StatusBadRequest,
-			wantResp: generated.ValidationErrorsResponse{
-				generated.ValidationError{
-					Field:   "full_name",
-					Message: "must be at minimum 3 characters and maximum 60 characters",
-				},
-				generated.ValidationError{
-					Field:   "phone_number",
-					Message: "must be at minimum 10 characters and maximum 13 characters & must start with the Indonesia country code “+62”",
-				},
+			respFunc: problemResponse,
+			wantCode: http.StatusUnauthorized,
+			wantResp: generated.Problem{
+				Type:     "/problems/invalid-credentials",
+				Title:    "Invalid credentials",
+				Status:   http.StatusUnauthorized,
+				Detail:   "The phone number, email or password is incorrect",
+				Instance: "/login",
 			},
 			wantErr: false,
 		},
 		{
-			name: "Error when UpdateUserData",
+			name: "error account pending",
 			args: args{
 				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
 					e := echo.New()
 
-					token, _ := utils.GenerateToken(50)
-
-					token = fmt.Sprintf("Bearer %s", token)
-
 					data := url.Values{}
-					data.Set("phone_number", "+628123456784")
-					data.Set("full_name", "fullnameeaa")
+					data.Set("phone_number", "+62812345678")
+					data.Set("password", "AAssff1!")
 
-					req := httptest.NewRequest(http.MethodPost, "/profile", strings.NewReader(data.Encode()))
+					req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(data.Encode()))
 					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-					req.Header.Add("Authorization", token)
 					rec := httptest.NewRecorder()
 
 					return e.NewContext(req, rec), rec
 				},
 			},
 			mockFunc: func(a args) {
-				mockUsecase.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(usecase.UpdateUserDataInput{
-					Id:          50,
-					PhoneNumber: "+628123456784",
-					FullName:    "fullnameeaa",
-				})).Return(usecase.UpdateUserDataOutput{}, errors.New("test"))
+				mockUsecase.EXPECT().Login(gomock.Any(), gomock.Any()).Return(usecase.LoginOutput{
+					IsAccountPending: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/account-pending",
+				Title:    "Account pending",
+				Status:   http.StatusForbidden,
+				Detail:   "Your account has not been activated yet",
+				Instance: "/login",
+			},
+			wantErr: false,
+		},
+		{
+			name: "error account suspended",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					data := url.Values{}
+					data.Set("phone_number", "+62812345678")
+					data.Set("password", "AAssff1!")
+
+					req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().Login(gomock.Any(), gomock.Any()).Return(usecase.LoginOutput{
+					IsAccountSuspended: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/account-suspended",
+				Title:    "Account suspended",
+				Status:   http.StatusForbidden,
+				Detail:   "Your account has been suspended, please contact support",
+				Instance: "/login",
+			},
+			wantErr: false,
+		},
+		{
+			name: "success",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					data := url.Values{}
+					data.Set("phone_number", "+62812345678")
+					data.Set("password", "AAssff1!")
+
+					req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().Login(gomock.Any(), gomock.Eq(usecase.LoginInput{
+					PhoneNumber: "+62812345678",
+					Password:    "AAssff1!",
+					IP:          "192.0.2.1",
+				})).Return(usecase.LoginOutput{
+					Token: "tokennn",
+				}, nil)
 			},
 			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
-				var resp generated.BasicErrorResponse
+				var resp generated.LoginSuccessResponse
 				json.Unmarshal(rec.Body.Bytes(), &resp)
 
 				return resp
 			},
-			wantCode: http.StatusInternalServerError,
-			wantResp: generated.BasicErrorResponse{
-				Message: "Internal server error",
+			wantCode: http.StatusOK,
+			wantResp: generated.LoginSuccessResponse{
+				Message: "Login success",
+				Token:   "tokennn",
 			},
 			wantErr: false,
 		},
 		{
-			name: "Error phone number exists",
+			name: "success, national phone number normalized before lookup",
 			args: args{
 				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
 					e := echo.New()
 
-					token, _ := utils.GenerateToken(50)
-
-					token = fmt.Sprintf("Bearer %s", token)
-
 					data := url.Values{}
-					data.Set("phone_number", "+628123456784")
-					data.Set("full_name", "fullnameeaa")
+					data.Set("phone_number", "0812 345 678")
+					data.Set("password", "AAssff1!")
 
-					req := httptest.NewRequest(http.MethodPost, "/profile", strings.NewReader(data.Encode()))
+					req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(data.Encode()))
 					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-					req.Header.Add("Authorization", token)
 					rec := httptest.NewRecorder()
 
 					return e.NewContext(req, rec), rec
 				},
 			},
 			mockFunc: func(a args) {
-				mockUsecase.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(usecase.UpdateUserDataInput{
-					Id:          50,
-					PhoneNumber: "+628123456784",
-					FullName:    "fullnameeaa",
-				})).Return(usecase.UpdateUserDataOutput{
-					IsPhoneNumberExists: true,
+				mockUsecase.EXPECT().Login(gomock.Any(), gomock.Eq(usecase.LoginInput{
+					PhoneNumber: "+62812345678",
+					Password:    "AAssff1!",
+					IP:          "192.0.2.1",
+				})).Return(usecase.LoginOutput{
+					Token: "tokennn",
 				}, nil)
 			},
 			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
-				var resp generated.BasicErrorResponse
+				var resp generated.LoginSuccessResponse
 				json.Unmarshal(rec.Body.Bytes(), &resp)
 
 				return resp
 			},
-			wantCode: http.StatusConflict,
-			wantResp: generated.BasicErrorResponse{
-				Message: "Phone number already used",
+			wantCode: http.StatusOK,
+			wantResp: generated.LoginSuccessResponse{
+				Message: "Login success",
+				Token:   "tokennn",
 			},
 			wantErr: false,
 		},
 		{
-			name: "Success",
+			name: "error invalid credentials in the Accept-Language language",
 			args: args{
 				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
 					e := echo.New()
 
-					token, _ := utils.GenerateToken(50)
+					data := url.Values{}
+					data.Set("phone_number", "+62812345678")
+					data.Set("password", "AAssff1!")
 
-					token = fmt.Sprintf("Bearer %s", token)
+					req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					req.Header.Add("Accept-Language", "fr;q=0.9, id-ID;q=0.8, en;q=0.7")
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().Login(gomock.Any(), gomock.Any()).Return(usecase.LoginOutput{
+					IsInvalidCredentials: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusUnauthorized,
+			wantResp: generated.Problem{
+				Type:     "/problems/invalid-credentials",
+				Title:    "Kredensial tidak valid",
+				Status:   http.StatusUnauthorized,
+				Detail:   "Nomor telepon, email, atau kata sandi salah",
+				Instance: "/login",
+			},
+			wantErr: false,
+		},
+		{
+			name: "error neither phone number nor email",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
 
 					data := url.Values{}
-					data.Set("phone_number", "+628123456784")
-					data.Set("full_name", "fullnameeaa")
+					data.Set("password", "AAssff1!")
 
-					req := httptest.NewRequest(http.MethodPost, "/profile", strings.NewReader(data.Encode()))
+					req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/login",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "phone_number",
+						Code:    generated.LOGINIDENTIFIERREQUIRED,
+						Message: "either phone number or email is required",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "error both phone number and email",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					data := url.Values{}
+					data.Set("phone_number", "+62812345678")
+					data.Set("email", "name@example.com")
+					data.Set("password", "AAssff1!")
+
+					req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/login",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "email",
+						Code:    generated.LOGINIDENTIFIERAMBIGUOUS,
+						Message: "only one of phone number and email may be given",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, email normalized before lookup",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					data := url.Values{}
+					data.Set("email", "Name@EXAMPLE.com")
+					data.Set("password", "AAssff1!")
+
+					req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(data.Encode()))
 					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-					req.Header.Add("Authorization", token)
 					rec := httptest.NewRecorder()
 
 					return e.NewContext(req, rec), rec
 				},
 			},
 			mockFunc: func(a args) {
-				mockUsecase.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(usecase.UpdateUserDataInput{
-					Id:          50,
-					PhoneNumber: "+628123456784",
-					FullName:    "fullnameeaa",
-				})).Return(usecase.UpdateUserDataOutput{}, nil)
+				mockUsecase.EXPECT().Login(gomock.Any(), gomock.Eq(usecase.LoginInput{
+					Email:    "Name@example.com",
+					Password: "AAssff1!",
+					IP:       "192.0.2.1",
+				})).Return(usecase.LoginOutput{
+					Token: "tokennn",
+				}, nil)
 			},
 			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
-				var resp generated.BasicSuccessResponse
+				var resp generated.LoginSuccessResponse
 				json.Unmarshal(rec.Body.Bytes(), &resp)
 
 				return resp
 			},
 			wantCode: http.StatusOK,
-			wantResp: generated.BasicSuccessResponse{
-				Message: "Update success",
+			wantResp: generated.LoginSuccessResponse{
+				Message: "Login success",
+				Token:   "tokennn",
+			},
+			wantErr: false,
+		},
+		{
+			name: "password expired",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					data := url.Values{}
+					data.Set("phone_number", "+62812345678")
+					data.Set("password", "AAssff1!")
+
+					req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().Login(gomock.Any(), gomock.Any()).Return(usecase.LoginOutput{
+					IsPasswordExpired: true,
+					Token:             "restricted",
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/password-expired",
+				Title:    "Password expired",
+				Status:   http.StatusForbidden,
+				Detail:   "Please set a new password using the returned token",
+				Instance: "/login",
+				Token:    func(s string) *string { return &s }("restricted"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "account deleted",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					data := url.Values{}
+					data.Set("phone_number", "+62812345678")
+					data.Set("password", "AAssff1!")
+
+					req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().Login(gomock.Any(), gomock.Any()).Return(usecase.LoginOutput{
+					IsAccountDeleted: true,
+					Token:            "restricted",
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/account-deleted",
+				Title:    "Account deleted",
+				Status:   http.StatusForbidden,
+				Detail:   "Your account will be erased soon, restore it with the returned token to keep it",
+				Instance: "/login",
+				Token:    func(s string) *string { return &s }("restricted"),
 			},
 			wantErr: false,
 		},
@@ -797,20 +1311,6706 @@ func TestServer_ProfileUpdate(t *testing.T) {
 			})
 
 			ctx, rec := tt.args.ctx()
-			if err := s.ProfileUpdate(ctx); (err != nil) != tt.wantErr {
-				t.Errorf("Server.ProfileUpdate() error = %v, wantErr %v", err, tt.wantErr)
+
+			if err := s.Login(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.Login() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
 			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
 
 			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
 
-			if respRaw, ok := resp.(generated.ValidationErrorsResponse); ok {
-				sort.Slice(respRaw, func(i, j int) bool {
-					return respRaw[i].Field < respRaw[j].Field
-				})
-			}
+func TestServer_ProfileGet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+	mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil).AnyTimes()
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	type args struct {
+		ctx func() (echo.Context, *httptest.ResponseRecorder)
+	}
 
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error token invalid",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					// token, _ := utils.GenerateToken(50, nil)
+
+					token := "abcd"
+
+					token = fmt.Sprintf("Bearer %s", token)
+
+					req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+					req.Header.Add("Authorization", token)
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when GetUserData",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateToken(50, nil)
+
+					token = fmt.Sprintf("Bearer %s", token)
+
+					req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+					req.Header.Add("Authorization", token)
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Eq(usecase.GetUserDataInput{
+					Id: 50,
+				})).Return(usecase.GetUserDataOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateToken(50, nil)
+
+					token = fmt.Sprintf("Bearer %s", token)
+
+					req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+					req.Header.Add("Authorization", token)
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Eq(usecase.GetUserDataInput{
+					Id: 50,
+				})).Return(usecase.GetUserDataOutput{
+					PhoneNumber: "123456789",
+					FullName:    "fullnamee",
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.ProfileGetResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.ProfileGetResponse{
+				PhoneNumber: "123456789",
+				FullName:    "fullnamee",
+			},
+			wantErr: false,
+		}, {
+			name: "Success with preferred language",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateToken(50, nil)
+
+					token = fmt.Sprintf("Bearer %s", token)
+
+					req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+					req.Header.Add("Authorization", token)
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{
+					PhoneNumber: "123456789",
+					FullName:    "fullnamee",
+					Language:    "id",
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.ProfileGetResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.ProfileGetResponse{
+				PhoneNumber: "123456789",
+				FullName:    "fullnamee",
+				Language:    func(s string) *string { return &s }("id"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success with email",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateToken(50, nil)
+
+					token = fmt.Sprintf("Bearer %s", token)
+
+					req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+					req.Header.Add("Authorization", token)
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{
+					PhoneNumber: "123456789",
+					FullName:    "fullnamee",
+					Email:       "name@example.com",
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.ProfileGetResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.ProfileGetResponse{
+				PhoneNumber:   "123456789",
+				FullName:      "fullnamee",
+				Email:         func(s string) *string { return &s }("name@example.com"),
+				EmailVerified: func(b bool) *bool { return &b }(false),
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success with avatar",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateToken(50, nil)
+
+					token = fmt.Sprintf("Bearer %s", token)
+
+					req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+					req.Header.Add("Authorization", token)
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{
+					PhoneNumber: "123456789",
+					FullName:    "fullnamee",
+					Avatar: &usecase.AvatarURLs{
+						Small:  "http://localhost/storage/avatars/50/abc/small.jpg",
+						Medium: "http://localhost/storage/avatars/50/abc/medium.jpg",
+						Large:  "http://localhost/storage/avatars/50/abc/large.jpg",
+					},
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.ProfileGetResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.ProfileGetResponse{
+				PhoneNumber: "123456789",
+				FullName:    "fullnamee",
+				AvatarUrl:   func(s string) *string { return &s }("http://localhost/storage/avatars/50/abc/large.jpg"),
+				AvatarUrls: &generated.AvatarURLs{
+					Small:  "http://localhost/storage/avatars/50/abc/small.jpg",
+					Medium: "http://localhost/storage/avatars/50/abc/medium.jpg",
+					Large:  "http://localhost/storage/avatars/50/abc/large.jpg",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success with attributes",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateToken(50, nil)
+
+					token = fmt.Sprintf("Bearer %s", token)
+
+					req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+					req.Header.Add("Authorization", token)
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{
+					PhoneNumber: "123456789",
+					FullName:    "fullnamee",
+					Attributes: map[string]interface{}{
+						"employee_id": "E-1",
+						"shoe_size":   float64(42),
+					},
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				return strings.TrimSpace(rec.Body.String())
+			},
+			wantCode: http.StatusOK,
+			wantResp: `{"attributes":{"employee_id":"E-1","shoe_size":42},"full_name":"fullnamee","phone_number":"123456789"}`,
+			wantErr:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := tt.args.ctx()
+
+			if err := s.ProfileGet(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.ProfileGet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_ProfileUpdate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+	mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil).AnyTimes()
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	type args struct {
+		ctx func() (echo.Context, *httptest.ResponseRecorder)
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error when login",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					// token, _ := utils.GenerateToken(50, nil)
+					token := "abc"
+
+					token = fmt.Sprintf("Bearer %s", token)
+
+					req := httptest.NewRequest(http.MethodPost, "/profile", nil)
+					req.Header.Add("Authorization", token)
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error phone number changed while impersonating",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateImpersonationToken(50, 1, 9, time.Now().Add(time.Minute))
+
+					data := url.Values{}
+					data.Set("phone_number", "+62812345678")
+					data.Set("full_name", "Jane Doe")
+
+					req := httptest.NewRequest(http.MethodPut, "/profile", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().RecordImpersonationUse(gomock.Any(), gomock.Eq(usecase.RecordImpersonationUseInput{
+					ImpersonationId: 9,
+					Method:          http.MethodPut,
+					Path:            "/profile",
+				})).Return(usecase.RecordImpersonationUseOutput{}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/impersonation-not-allowed",
+				Title:    "Not allowed while impersonating",
+				Status:   http.StatusForbidden,
+				Detail:   "Only the user themselves can change the password or phone numbers, deactivate or delete the account or export their data",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error validations",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateToken(50, nil)
+
+					token = fmt.Sprintf("Bearer %s", token)
+
+					data := url.Values{}
+					data.Set("phone_number", "08123456")
+					data.Set("full_name", "fu")
+
+					req := httptest.NewRequest(http.MethodPost, "/profile", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					req.Header.Add("Authorization", token)
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/profile",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "full_name",
+						Code:    generated.FULLNAMETOOSHORT,
+						Message: "must be at minimum 3 characters and maximum 60 characters",
+						Params:  &map[string]interface{}{"min": float64(3), "max": float64(60)},
+					},
+					generated.ValidationError{
+						Field:   "phone_number",
+						Code:    generated.PHONENUMBERINVALIDLENGTH,
+						Message: "must have 8 to 12 digits after the country code “+62”",
+						Params:  &map[string]interface{}{"country_code": "62", "lengths": []interface{}{float64(8), float64(9), float64(10), float64(11), float64(12)}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when UpdateUserData",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateToken(50, nil)
+
+					token = fmt.Sprintf("Bearer %s", token)
+
+					data := url.Values{}
+					data.Set("phone_number", "+628123456784")
+					data.Set("full_name", "fullnameeaa")
+
+					req := httptest.NewRequest(http.MethodPost, "/profile", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					req.Header.Add("Authorization", token)
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(usecase.UpdateUserDataInput{
+					Id:           50,
+					PhoneNumber:  "+628123456784",
+					FullName:     "fullnameeaa",
+					MailLanguage: "en",
+				})).Return(usecase.UpdateUserDataOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error phone number exists",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateToken(50, nil)
+
+					token = fmt.Sprintf("Bearer %s", token)
+
+					data := url.Values{}
+					data.Set("phone_number", "+628123456784")
+					data.Set("full_name", "fullnameeaa")
+
+					req := httptest.NewRequest(http.MethodPost, "/profile", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					req.Header.Add("Authorization", token)
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(usecase.UpdateUserDataInput{
+					Id:           50,
+					PhoneNumber:  "+628123456784",
+					FullName:     "fullnameeaa",
+					MailLanguage: "en",
+				})).Return(usecase.UpdateUserDataOutput{
+					IsPhoneNumberExists: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusConflict,
+			wantResp: generated.Problem{
+				Type:     "/problems/phone-number-already-used",
+				Title:    "Phone number already used",
+				Status:   http.StatusConflict,
+				Detail:   "Another account is already registered with this phone number",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateToken(50, nil)
+
+					token = fmt.Sprintf("Bearer %s", token)
+
+					data := url.Values{}
+					data.Set("phone_number", "+628123456784")
+					data.Set("full_name", "fullnameeaa")
+
+					req := httptest.NewRequest(http.MethodPost, "/profile", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					req.Header.Add("Authorization", token)
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(usecase.UpdateUserDataInput{
+					Id:           50,
+					PhoneNumber:  "+628123456784",
+					FullName:     "fullnameeaa",
+					MailLanguage: "en",
+				})).Return(usecase.UpdateUserDataOutput{}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.BasicSuccessResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Update success",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error invalid email",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateToken(50, nil)
+
+					token = fmt.Sprintf("Bearer %s", token)
+
+					data := url.Values{}
+					data.Set("email", "name@")
+
+					req := httptest.NewRequest(http.MethodPost, "/profile", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					req.Header.Add("Authorization", token)
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/profile",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "email",
+						Code:    generated.EMAILINVALID,
+						Message: "must be a valid email address, e.g. “name@example.com”",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error email exists",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateToken(50, nil)
+
+					token = fmt.Sprintf("Bearer %s", token)
+
+					data := url.Values{}
+					data.Set("email", "name@example.com")
+
+					req := httptest.NewRequest(http.MethodPost, "/profile", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					req.Header.Add("Authorization", token)
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(usecase.UpdateUserDataInput{
+					Id:           50,
+					Email:        "name@example.com",
+					MailLanguage: "en",
+				})).Return(usecase.UpdateUserDataOutput{
+					IsEmailExists: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusConflict,
+			wantResp: generated.Problem{
+				Type:     "/problems/email-already-used",
+				Title:    "Email already used",
+				Status:   http.StatusConflict,
+				Detail:   "Another account already uses this email address",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error language not supported",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateToken(50, nil)
+
+					token = fmt.Sprintf("Bearer %s", token)
+
+					data := url.Values{}
+					data.Set("language", "fr")
+
+					req := httptest.NewRequest(http.MethodPut, "/profile", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					req.Header.Add("Authorization", token)
+					req.Header.Add("Accept-Language", "fr-FR, id;q=0.5")
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Permintaan tidak valid",
+				Status:   http.StatusBadRequest,
+				Detail:   "Satu atau lebih isian tidak valid, lihat errors",
+				Instance: "/profile",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "language",
+						Code:    generated.LANGUAGENOTSUPPORTED,
+						Message: "harus salah satu bahasa yang didukung: en, id",
+						Params:  &map[string]interface{}{"supported_languages": []interface{}{"en", "id"}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, new preferred language used for the response",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateToken(50, nil)
+
+					token = fmt.Sprintf("Bearer %s", token)
+
+					data := url.Values{}
+					data.Set("language", "id")
+
+					req := httptest.NewRequest(http.MethodPut, "/profile", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					req.Header.Add("Authorization", token)
+					req.Header.Add("Accept-Language", "en")
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(usecase.UpdateUserDataInput{
+					Id:           50,
+					Language:     "id",
+					MailLanguage: "id",
+				})).Return(usecase.UpdateUserDataOutput{}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.BasicSuccessResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Berhasil diperbarui",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error attributes not an object",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateToken(50, nil)
+
+					data := url.Values{}
+					data.Set("attributes", `["E-1"]`)
+
+					req := httptest.NewRequest(http.MethodPut, "/profile", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/profile",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "attributes",
+						Code:    generated.ATTRIBUTESNOTOBJECT,
+						Message: "must be a JSON object",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error attributes refused",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateToken(50, nil)
+
+					data := url.Values{}
+					data.Set("attributes", `{"employee_id": "E-2", "shoe_size": 12, "extra": true}`)
+
+					req := httptest.NewRequest(http.MethodPut, "/profile", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(usecase.UpdateUserDataInput{
+					Id:           50,
+					MailLanguage: "en",
+					Attributes: map[string]interface{}{
+						"employee_id": "E-2",
+						"shoe_size":   float64(12),
+						"extra":       true,
+					},
+				})).Return(usecase.UpdateUserDataOutput{
+					AttributeErrors: []usecase.AttributeError{
+						{Reason: `property "extra" is unsupported`},
+						{Path: []string{"employee_id"}, IsReadOnly: true},
+						{Path: []string{"shoe_size"}, Reason: "number must be at least 30"},
+					},
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/profile",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "attributes",
+						Code:    generated.ATTRIBUTEINVALID,
+						Message: `does not match the attribute schema: property "extra" is unsupported`,
+						Params:  &map[string]interface{}{"reason": `property "extra" is unsupported`},
+					},
+					{
+						Field:   "attributes.employee_id",
+						Code:    generated.ATTRIBUTEREADONLY,
+						Message: "can only be changed by an admin",
+					},
+					{
+						Field:   "attributes.shoe_size",
+						Code:    generated.ATTRIBUTEINVALID,
+						Message: "does not match the attribute schema: number must be at least 30",
+						Params:  &map[string]interface{}{"reason": "number must be at least 30"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success with attributes",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateToken(50, nil)
+
+					data := url.Values{}
+					data.Set("attributes", `{"shoe_size": 42}`)
+
+					req := httptest.NewRequest(http.MethodPut, "/profile", strings.NewReader(data.Encode()))
+					req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+					req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(usecase.UpdateUserDataInput{
+					Id:           50,
+					MailLanguage: "en",
+					Attributes: map[string]interface{}{
+						"shoe_size": float64(42),
+					},
+				})).Return(usecase.UpdateUserDataOutput{}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.BasicSuccessResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Update success",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := tt.args.ctx()
+			if err := s.ProfileUpdate(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.ProfileUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_ProfilePatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+	mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil).AnyTimes()
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	newCtx := func(token, contentType, body string) func() (echo.Context, *httptest.ResponseRecorder) {
+		return func() (echo.Context, *httptest.ResponseRecorder) {
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodPatch, "/profile", strings.NewReader(body))
+			req.Header.Add("Content-Type", contentType)
+			req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+			rec := httptest.NewRecorder()
+
+			return e.NewContext(req, rec), rec
+		}
+	}
+
+	token, _ := utils.GenerateToken(50, nil)
+	impersonationToken, _ := utils.GenerateImpersonationToken(50, 1, 9, time.Now().Add(time.Minute))
+
+	successResponse := func(rec *httptest.ResponseRecorder) interface{} {
+		var resp generated.BasicSuccessResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+
+		return resp
+	}
+
+	type args struct {
+		ctx func() (echo.Context, *httptest.ResponseRecorder)
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error when login",
+			args: args{
+				ctx: newCtx("abc", MIME_APPLICATION_MERGE_PATCH_JSON, `{"full_name": "Jane Doe"}`),
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error unsupported content type",
+			args: args{
+				ctx: newCtx(token, "application/json", `{"full_name": "Jane Doe"}`),
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusUnsupportedMediaType,
+			wantResp: generated.Problem{
+				Type:     "about:blank",
+				Title:    "Unsupported Media Type",
+				Status:   http.StatusUnsupportedMediaType,
+				Detail:   "Unsupported Media Type",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error merge patch not an object",
+			args: args{
+				ctx: newCtx(token, MIME_APPLICATION_MERGE_PATCH_JSON, `["full_name"]`),
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/merge-patch-invalid",
+				Title:    "Invalid merge patch",
+				Status:   http.StatusBadRequest,
+				Detail:   "The body must be a JSON object, see RFC 7396",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error phone number changed while impersonating",
+			args: args{
+				ctx: newCtx(impersonationToken, MIME_APPLICATION_MERGE_PATCH_JSON, `{"phone_number": "+62812345678"}`),
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().RecordImpersonationUse(gomock.Any(), gomock.Eq(usecase.RecordImpersonationUseInput{
+					ImpersonationId: 9,
+					Method:          http.MethodPatch,
+					Path:            "/profile",
+				})).Return(usecase.RecordImpersonationUseOutput{}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/impersonation-not-allowed",
+				Title:    "Not allowed while impersonating",
+				Status:   http.StatusForbidden,
+				Detail:   "Only the user themselves can change the password or phone numbers, deactivate or delete the account or export their data",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error validations",
+			args: args{
+				ctx: newCtx(token, MIME_APPLICATION_MERGE_PATCH_JSON, `{"full_name": null, "phone_number": 62812345678, "attributes": [1]}`),
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/profile",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "attributes",
+						Code:    generated.ATTRIBUTESNOTOBJECT,
+						Message: "must be a JSON object",
+					},
+					{
+						Field:   "full_name",
+						Code:    generated.FULLNAMETOOSHORT,
+						Message: "must be at minimum 3 characters and maximum 60 characters",
+						Params:  &map[string]interface{}{"min": float64(3), "max": float64(60)},
+					},
+					{
+						Field:   "phone_number",
+						Code:    generated.VALUENOTSTRING,
+						Message: "must be a string",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when PatchUserData",
+			args: args{
+				ctx: newCtx(token, MIME_APPLICATION_MERGE_PATCH_JSON, `{"full_name": "Jane Doe"}`),
+			},
+			mockFunc: func(a args) {
+				fullName := "Jane Doe"
+				mockUsecase.EXPECT().PatchUserData(gomock.Any(), gomock.Eq(usecase.PatchUserDataInput{
+					Id:           50,
+					FullName:     &fullName,
+					MailLanguage: "en",
+				})).Return(usecase.PatchUserDataOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error attributes",
+			args: args{
+				ctx: newCtx(token, MIME_APPLICATION_MERGE_PATCH_JSON, `{"attributes": {"employee_id": null}}`),
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().PatchUserData(gomock.Any(), gomock.Eq(usecase.PatchUserDataInput{
+					Id:              50,
+					MailLanguage:    "en",
+					AttributesPatch: json.RawMessage(`{"employee_id": null}`),
+				})).Return(usecase.PatchUserDataOutput{
+					AttributeErrors: []usecase.AttributeError{
+						{Path: []string{"employee_id"}, IsReadOnly: true},
+					},
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/profile",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "attributes.employee_id",
+						Code:    generated.ATTRIBUTEREADONLY,
+						Message: "can only be changed by an admin",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error profile changed concurrently",
+			args: args{
+				ctx: newCtx(token, MIME_APPLICATION_MERGE_PATCH_JSON, `{"attributes": {"shoe_size": 42}}`),
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().PatchUserData(gomock.Any(), gomock.Eq(usecase.PatchUserDataInput{
+					Id:              50,
+					MailLanguage:    "en",
+					AttributesPatch: json.RawMessage(`{"shoe_size": 42}`),
+				})).Return(usecase.PatchUserDataOutput{IsConflict: true}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusConflict,
+			wantResp: generated.Problem{
+				Type:     "/problems/profile-changed",
+				Title:    "Profile changed concurrently",
+				Status:   http.StatusConflict,
+				Detail:   "The attributes kept being changed by other requests, please try again",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error email already used",
+			args: args{
+				ctx: newCtx(token, MIME_APPLICATION_MERGE_PATCH_JSON, `{"email": " Jane@Example.com "}`),
+			},
+			mockFunc: func(a args) {
+				email := "Jane@example.com"
+				mockUsecase.EXPECT().PatchUserData(gomock.Any(), gomock.Eq(usecase.PatchUserDataInput{
+					Id:           50,
+					Email:        &email,
+					MailLanguage: "en",
+				})).Return(usecase.PatchUserDataOutput{IsEmailExists: true}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusConflict,
+			wantResp: generated.Problem{
+				Type:     "/problems/email-already-used",
+				Title:    "Email already used",
+				Status:   http.StatusConflict,
+				Detail:   "Another account already uses this email address",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success with null language and email",
+			args: args{
+				ctx: newCtx(token, MIME_APPLICATION_MERGE_PATCH_JSON+"; charset=utf-8", `{"full_name": "Jane Doe", "language": null, "email": null}`),
+			},
+			mockFunc: func(a args) {
+				fullName, empty := "Jane Doe", ""
+				mockUsecase.EXPECT().PatchUserData(gomock.Any(), gomock.Eq(usecase.PatchUserDataInput{
+					Id:           50,
+					FullName:     &fullName,
+					Language:     &empty,
+					Email:        &empty,
+					MailLanguage: "en",
+				})).Return(usecase.PatchUserDataOutput{}, nil)
+			},
+			respFunc: successResponse,
+			wantCode: http.StatusOK,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Update success",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success in the patched language",
+			args: args{
+				ctx: newCtx(token, MIME_APPLICATION_MERGE_PATCH_JSON, `{"language": "id"}`),
+			},
+			mockFunc: func(a args) {
+				language := "id"
+				mockUsecase.EXPECT().PatchUserData(gomock.Any(), gomock.Eq(usecase.PatchUserDataInput{
+					Id:           50,
+					Language:     &language,
+					MailLanguage: "id",
+				})).Return(usecase.PatchUserDataOutput{}, nil)
+			},
+			respFunc: successResponse,
+			wantCode: http.StatusOK,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Berhasil diperbarui",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := tt.args.ctx()
+			if err := s.ProfilePatch(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.ProfilePatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_PasswordUpdate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+	mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil).AnyTimes()
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	newCtx := func(currentPassword, newPassword string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		token, _ := utils.GenerateToken(50, nil)
+
+		token = fmt.Sprintf("Bearer %s", token)
+
+		data := url.Values{}
+		data.Set("current_password", currentPassword)
+		data.Set("new_password", newPassword)
+
+		req := httptest.NewRequest(http.MethodPut, "/profile/password", strings.NewReader(data.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Add("Authorization", token)
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	type args struct {
+		ctx func() (echo.Context, *httptest.ResponseRecorder)
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error token invalid",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					req := httptest.NewRequest(http.MethodPut, "/profile/password", nil)
+					req.Header.Add("Authorization", "Bearer abc")
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/profile/password",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error impersonation",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					e := echo.New()
+
+					token, _ := utils.GenerateImpersonationToken(50, 1, 9, time.Now().Add(time.Minute))
+
+					req := httptest.NewRequest(http.MethodPut, "/profile/password", nil)
+					req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+					rec := httptest.NewRecorder()
+
+					return e.NewContext(req, rec), rec
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().RecordImpersonationUse(gomock.Any(), gomock.Eq(usecase.RecordImpersonationUseInput{
+					ImpersonationId: 9,
+					Method:          http.MethodPut,
+					Path:            "/profile/password",
+				})).Return(usecase.RecordImpersonationUseOutput{}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/impersonation-not-allowed",
+				Title:    "Not allowed while impersonating",
+				Status:   http.StatusForbidden,
+				Detail:   "Only the user themselves can change the password or phone numbers, deactivate or delete the account or export their data",
+				Instance: "/profile/password",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when GetUserData",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx("AAssff1!", "BBttgg2@")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Eq(usecase.GetUserDataInput{
+					Id: 50,
+				})).Return(usecase.GetUserDataOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile/password",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error validations",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx("", "asfa")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Eq(usecase.GetUserDataInput{
+					Id: 50,
+				})).Return(usecase.GetUserDataOutput{
+					PhoneNumber: "+62812345678",
+					FullName:    "asfa",
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/profile/password",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "current_password",
+						Code:    generated.PASSWORDREQUIRED,
+						Message: "must not be empty",
+					},
+					generated.ValidationError{
+						Field:   "new_password",
+						Code:    generated.PASSWORDTOOSHORT,
+						Message: "must be at minimum 6 characters and maximum 64 characters",
+						Params:  &map[string]interface{}{"min": float64(6), "max": float64(64)},
+					},
+					generated.ValidationError{
+						Field:   "new_password",
+						Code:    generated.PASSWORDMISSINGUPPERCASE,
+						Message: "must contain at least 1 capital character",
+						Params:  &map[string]interface{}{"min": float64(1)},
+					},
+					generated.ValidationError{
+						Field:   "new_password",
+						Code:    generated.PASSWORDMISSINGNUMBER,
+						Message: "must contain at least 1 number",
+						Params:  &map[string]interface{}{"min": float64(1)},
+					},
+					generated.ValidationError{
+						Field:   "new_password",
+						Code:    generated.PASSWORDMISSINGSYMBOL,
+						Message: "must contain at least 1 special (non alpha-numeric) character",
+						Params:  &map[string]interface{}{"min": float64(1)},
+					},
+					generated.ValidationError{
+						Field:   "new_password",
+						Code:    generated.PASSWORDCONTAINSNAME,
+						Message: "must not contain your name",
+					},
+					generated.ValidationError{
+						Field:   "new_password",
+						Code:    generated.PASSWORDTOOWEAK,
+						Message: "is too easy to guess, please choose a stronger password",
+						Params:  &map[string]interface{}{"score": float64(0), "min_score": float64(2)},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when SetPassword",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx("AAssff1!", "BBttgg2@")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{
+					PhoneNumber: "+62812345678",
+					FullName:    "fullnamee",
+				}, nil)
+
+				mockUsecase.EXPECT().SetPassword(gomock.Any(), gomock.Eq(usecase.SetPasswordInput{
+					Id:              50,
+					CurrentPassword: "AAssff1!",
+					NewPassword:     "BBttgg2@",
+				})).Return(usecase.SetPasswordOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile/password",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error current password wrong",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx("AAssff1!", "BBttgg2@")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{
+					PhoneNumber: "+62812345678",
+					FullName:    "fullnamee",
+				}, nil)
+
+				mockUsecase.EXPECT().SetPassword(gomock.Any(), gomock.Any()).Return(usecase.SetPasswordOutput{
+					IsPasswordWrong: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/profile/password",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "current_password",
+						Code:    generated.PASSWORDINCORRECT,
+						Message: "is incorrect",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error password reused",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx("AAssff1!", "BBttgg2@")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{
+					PhoneNumber: "+62812345678",
+					FullName:    "fullnamee",
+				}, nil)
+
+				mockUsecase.EXPECT().SetPassword(gomock.Any(), gomock.Any()).Return(usecase.SetPasswordOutput{
+					IsPasswordReused: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/profile/password",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "new_password",
+						Code:    generated.PASSWORDRECENTLYUSED,
+						Message: "must not be the same as one of your recently used passwords",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx("AAssff1!", "BBttgg2@")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{
+					PhoneNumber: "+62812345678",
+					FullName:    "fullnamee",
+				}, nil)
+
+				mockUsecase.EXPECT().SetPassword(gomock.Any(), gomock.Any()).Return(usecase.SetPasswordOutput{}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.BasicSuccessResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Update success",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := tt.args.ctx()
+			if err := s.PasswordUpdate(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.PasswordUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_ExpiredPasswordUpdate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+	mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil).AnyTimes()
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	newCtx := func(token, newPassword string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		data := url.Values{}
+		data.Set("new_password", newPassword)
+
+		req := httptest.NewRequest(http.MethodPut, "/login/expired-password", strings.NewReader(data.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	restrictedToken, _ := utils.GenerateScopedToken(50, utils.TOKEN_SCOPE_PASSWORD_EXPIRED, time.Minute)
+	normalToken, _ := utils.GenerateToken(50, nil)
+
+	type args struct {
+		ctx func() (echo.Context, *httptest.ResponseRecorder)
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error normal token not accepted",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(normalToken, "BBttgg2@")
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/login/expired-password",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when GetUserData",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(restrictedToken, "BBttgg2@")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Eq(usecase.GetUserDataInput{
+					Id: 50,
+				})).Return(usecase.GetUserDataOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/login/expired-password",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error validations",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(restrictedToken, "Bb2@")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{
+					PhoneNumber: "+62812345678",
+					FullName:    "fullnamee",
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/login/expired-password",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "new_password",
+						Code:    generated.PASSWORDTOOSHORT,
+						Message: "must be at minimum 6 characters and maximum 64 characters",
+						Params:  &map[string]interface{}{"min": float64(6), "max": float64(64)},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when SetExpiredPassword",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(restrictedToken, "BBttgg2@")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{
+					PhoneNumber: "+62812345678",
+					FullName:    "fullnamee",
+				}, nil)
+
+				mockUsecase.EXPECT().SetExpiredPassword(gomock.Any(), gomock.Any()).Return(usecase.SetExpiredPasswordOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/login/expired-password",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error password reused",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(restrictedToken, "BBttgg2@")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{
+					PhoneNumber: "+62812345678",
+					FullName:    "fullnamee",
+				}, nil)
+
+				mockUsecase.EXPECT().SetExpiredPassword(gomock.Any(), gomock.Any()).Return(usecase.SetExpiredPasswordOutput{
+					IsPasswordReused: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/login/expired-password",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "new_password",
+						Code:    generated.PASSWORDRECENTLYUSED,
+						Message: "must not be the same as one of your recently used passwords",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(restrictedToken, "BBttgg2@")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{
+					PhoneNumber: "+62812345678",
+					FullName:    "fullnamee",
+				}, nil)
+
+				mockUsecase.EXPECT().SetExpiredPassword(gomock.Any(), gomock.Eq(usecase.SetExpiredPasswordInput{
+					Id:          50,
+					NewPassword: "BBttgg2@",
+					IP:          "192.0.2.1",
+				})).Return(usecase.SetExpiredPasswordOutput{
+					Token: "tokennn",
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.LoginSuccessResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.LoginSuccessResponse{
+				Message: "Update success",
+				Token:   "tokennn",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := tt.args.ctx()
+			if err := s.ExpiredPasswordUpdate(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.ExpiredPasswordUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_EmailVerify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	newCtx := func(token string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		data := url.Values{}
+		data.Set("token", token)
+
+		req := httptest.NewRequest(http.MethodPost, "/email/verify", strings.NewReader(data.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	type args struct {
+		ctx func() (echo.Context, *httptest.ResponseRecorder)
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error when VerifyEmailByToken",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx("tokennn")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().VerifyEmailByToken(gomock.Any(), gomock.Eq(usecase.VerifyEmailByTokenInput{
+					Token: "tokennn",
+				})).Return(usecase.VerifyEmailOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/email/verify",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error invalid token",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx("tokennn")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().VerifyEmailByToken(gomock.Any(), gomock.Any()).Return(usecase.VerifyEmailOutput{
+					IsInvalid: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/email-verification-invalid",
+				Title:    "Invalid verification",
+				Status:   http.StatusBadRequest,
+				Detail:   "The verification is unknown, expired or was sent to a previous email address, please request a new one",
+				Instance: "/email/verify",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx("tokennn")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().VerifyEmailByToken(gomock.Any(), gomock.Eq(usecase.VerifyEmailByTokenInput{
+					Token: "tokennn",
+				})).Return(usecase.VerifyEmailOutput{}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.BasicSuccessResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Email verified",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := tt.args.ctx()
+			if err := s.EmailVerify(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.EmailVerify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_ProfileEmailVerify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+	mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil).AnyTimes()
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	newCtx := func(token, code string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		data := url.Values{}
+		data.Set("code", code)
+
+		req := httptest.NewRequest(http.MethodPost, "/profile/email/verify", strings.NewReader(data.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	token, _ := utils.GenerateToken(50, nil)
+
+	type args struct {
+		ctx func() (echo.Context, *httptest.ResponseRecorder)
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error token invalid",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx("abcd", "123456")
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/profile/email/verify",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error code empty",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, "")
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/profile/email/verify",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "code",
+						Code:    generated.VERIFICATIONCODEREQUIRED,
+						Message: "must not be empty",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when VerifyEmailByCode",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, "123456")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().VerifyEmailByCode(gomock.Any(), gomock.Eq(usecase.VerifyEmailByCodeInput{
+					Id:   50,
+					Code: "123456",
+				})).Return(usecase.VerifyEmailOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile/email/verify",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error invalid code",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, "123456")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().VerifyEmailByCode(gomock.Any(), gomock.Any()).Return(usecase.VerifyEmailOutput{
+					IsInvalid: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/email-verification-invalid",
+				Title:    "Invalid verification",
+				Status:   http.StatusBadRequest,
+				Detail:   "The verification is unknown, expired or was sent to a previous email address, please request a new one",
+				Instance: "/profile/email/verify",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, "123456")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().VerifyEmailByCode(gomock.Any(), gomock.Eq(usecase.VerifyEmailByCodeInput{
+					Id:   50,
+					Code: "123456",
+				})).Return(usecase.VerifyEmailOutput{}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.BasicSuccessResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Email verified",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := tt.args.ctx()
+			if err := s.ProfileEmailVerify(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.ProfileEmailVerify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_ProfileEmailVerificationSend(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+	mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil).AnyTimes()
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	newCtx := func(token string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodPost, "/profile/email/verification", nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	token, _ := utils.GenerateToken(50, nil)
+
+	type args struct {
+		ctx func() (echo.Context, *httptest.ResponseRecorder)
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error token invalid",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx("abcd")
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/profile/email/verification",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when GetUserData",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token)
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Eq(usecase.GetUserDataInput{
+					Id: 50,
+				})).Return(usecase.GetUserDataOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile/email/verification",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when SendEmailVerification",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token)
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{}, nil)
+				mockUsecase.EXPECT().SendEmailVerification(gomock.Any(), gomock.Any()).Return(usecase.SendEmailVerificationOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile/email/verification",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error email missing",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token)
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{}, nil)
+				mockUsecase.EXPECT().SendEmailVerification(gomock.Any(), gomock.Any()).Return(usecase.SendEmailVerificationOutput{
+					IsEmailMissing: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/email-missing",
+				Title:    "No email address",
+				Status:   http.StatusBadRequest,
+				Detail:   "Add an email address to your profile first",
+				Instance: "/profile/email/verification",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error email already verified",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token)
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{}, nil)
+				mockUsecase.EXPECT().SendEmailVerification(gomock.Any(), gomock.Any()).Return(usecase.SendEmailVerificationOutput{
+					IsEmailVerified: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusConflict,
+			wantResp: generated.Problem{
+				Type:     "/problems/email-already-verified",
+				Title:    "Email already verified",
+				Status:   http.StatusConflict,
+				Detail:   "The email address of your profile is already verified",
+				Instance: "/profile/email/verification",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, mailed in the preferred language",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token)
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{
+					Language: "id",
+				}, nil)
+				mockUsecase.EXPECT().SendEmailVerification(gomock.Any(), gomock.Eq(usecase.SendEmailVerificationInput{
+					Id:           50,
+					MailLanguage: "id",
+				})).Return(usecase.SendEmailVerificationOutput{}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.BasicSuccessResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusAccepted,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Email verifikasi telah dikirim",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := tt.args.ctx()
+			if err := s.ProfileEmailVerificationSend(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.ProfileEmailVerificationSend() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_ProfileIdentitiesGet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+	mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil).AnyTimes()
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	newCtx := func(token string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/profile/identities", nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	token, _ := utils.GenerateToken(50, nil)
+	verifiedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	type args struct {
+		ctx func() (echo.Context, *httptest.ResponseRecorder)
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error token invalid",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx("abcd")
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/profile/identities",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when GetIdentities",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token)
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetIdentities(gomock.Any(), gomock.Eq(usecase.GetIdentitiesInput{
+					Id: 50,
+				})).Return(usecase.GetIdentitiesOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile/identities",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token)
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetIdentities(gomock.Any(), gomock.Any()).Return(usecase.GetIdentitiesOutput{
+					Identities: []usecase.Identity{
+						{
+							Id:         1,
+							Type:       usecase.IDENTITY_TYPE_PHONE,
+							Identifier: "+62812345678",
+							IsPrimary:  true,
+						},
+						{
+							Id:         2,
+							Type:       usecase.IDENTITY_TYPE_EMAIL,
+							Identifier: "name@example.com",
+							VerifiedAt: &verifiedAt,
+						},
+					},
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.IdentitiesResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.IdentitiesResponse{
+				Identities: []generated.Identity{
+					{
+						Id:         "1",
+						Type:       usecase.IDENTITY_TYPE_PHONE,
+						Identifier: "+62812345678",
+						Primary:    true,
+					},
+					{
+						Id:         "2",
+						Type:       usecase.IDENTITY_TYPE_EMAIL,
+						Identifier: "name@example.com",
+						VerifiedAt: &verifiedAt,
+					},
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := tt.args.ctx()
+			if err := s.ProfileIdentitiesGet(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.ProfileIdentitiesGet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_ProfileIdentityAdd(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+	mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil).AnyTimes()
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	newCtx := func(token, identityType, identifier string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		data := url.Values{}
+		data.Set("type", identityType)
+		data.Set("identifier", identifier)
+
+		req := httptest.NewRequest(http.MethodPost, "/profile/identities", strings.NewReader(data.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	token, _ := utils.GenerateToken(50, nil)
+
+	identityResponse := func(rec *httptest.ResponseRecorder) interface{} {
+		var resp generated.Identity
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+
+		return resp
+	}
+
+	type args struct {
+		ctx func() (echo.Context, *httptest.ResponseRecorder)
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error token invalid",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx("abcd", "phone", "+62812345678")
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/profile/identities",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error phone number added while impersonating",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					impersonationToken, _ := utils.GenerateImpersonationToken(50, 1, 9, time.Now().Add(time.Minute))
+					return newCtx(impersonationToken, "phone", "+62812345678")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().RecordImpersonationUse(gomock.Any(), gomock.Eq(usecase.RecordImpersonationUseInput{
+					ImpersonationId: 9,
+					Method:          http.MethodPost,
+					Path:            "/profile/identities",
+				})).Return(usecase.RecordImpersonationUseOutput{}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/impersonation-not-allowed",
+				Title:    "Not allowed while impersonating",
+				Status:   http.StatusForbidden,
+				Detail:   "Only the user themselves can change the password or phone numbers, deactivate or delete the account or export their data",
+				Instance: "/profile/identities",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error type not supported",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, "google", "12345")
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/profile/identities",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "type",
+						Code:    generated.IDENTITYTYPENOTSUPPORTED,
+						Message: "must be one of the supported types: phone, email",
+						Params:  &map[string]interface{}{"supported_types": []interface{}{"phone", "email"}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error phone number invalid",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, "phone", "+62abc")
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/profile/identities",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "identifier",
+						Code:    generated.PHONENUMBERINVALIDCHARACTERS,
+						Message: "must only contain digits, optionally starting with “+”",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error email invalid",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, "email", "name@localhost")
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/profile/identities",
+				Errors: &[]generated.ValidationError{
+					generated.ValidationError{
+						Field:   "identifier",
+						Code:    generated.EMAILINVALID,
+						Message: "must be a valid email address, e.g. “name@example.com”",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when AddIdentity",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, "phone", "+62812345678")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().AddIdentity(gomock.Any(), gomock.Any()).Return(usecase.AddIdentityOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile/identities",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error identifier already used",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, "email", "name@example.com")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().AddIdentity(gomock.Any(), gomock.Any()).Return(usecase.AddIdentityOutput{
+					IsIdentifierExists: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusConflict,
+			wantResp: generated.Problem{
+				Type:     "/problems/identifier-already-used",
+				Title:    "Identifier already used",
+				Status:   http.StatusConflict,
+				Detail:   "This phone number or email address is already in use",
+				Instance: "/profile/identities",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, phone number normalized",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, "phone", "0812345678")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().AddIdentity(gomock.Any(), gomock.Eq(usecase.AddIdentityInput{
+					Id:           50,
+					Type:         usecase.IDENTITY_TYPE_PHONE,
+					Identifier:   "+62812345678",
+					MailLanguage: "en",
+				})).Return(usecase.AddIdentityOutput{
+					Identity: usecase.Identity{
+						Id:         3,
+						Type:       usecase.IDENTITY_TYPE_PHONE,
+						Identifier: "+62812345678",
+					},
+				}, nil)
+			},
+			respFunc: identityResponse,
+			wantCode: http.StatusCreated,
+			wantResp: generated.Identity{
+				Id:         "3",
+				Type:       usecase.IDENTITY_TYPE_PHONE,
+				Identifier: "+62812345678",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, email normalized",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, "email", " Name@Example.COM ")
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().AddIdentity(gomock.Any(), gomock.Eq(usecase.AddIdentityInput{
+					Id:           50,
+					Type:         usecase.IDENTITY_TYPE_EMAIL,
+					Identifier:   "Name@example.com",
+					MailLanguage: "en",
+				})).Return(usecase.AddIdentityOutput{
+					Identity: usecase.Identity{
+						Id:         4,
+						Type:       usecase.IDENTITY_TYPE_EMAIL,
+						Identifier: "Name@example.com",
+					},
+				}, nil)
+			},
+			respFunc: identityResponse,
+			wantCode: http.StatusCreated,
+			wantResp: generated.Identity{
+				Id:         "4",
+				Type:       usecase.IDENTITY_TYPE_EMAIL,
+				Identifier: "Name@example.com",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := tt.args.ctx()
+			if err := s.ProfileIdentityAdd(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.ProfileIdentityAdd() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_ProfileIdentityRemove(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+	mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil).AnyTimes()
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	newCtx := func(token, id string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodDelete, "/profile/identities/"+id, nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	token, _ := utils.GenerateToken(50, nil)
+
+	type args struct {
+		ctx func() (echo.Context, *httptest.ResponseRecorder)
+		id  string
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error token invalid",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx("abcd", "3")
+				},
+				id: "3",
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/profile/identities/3",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error impersonation",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					impersonationToken, _ := utils.GenerateImpersonationToken(50, 1, 9, time.Now().Add(time.Minute))
+					return newCtx(impersonationToken, "3")
+				},
+				id: "3",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().RecordImpersonationUse(gomock.Any(), gomock.Eq(usecase.RecordImpersonationUseInput{
+					ImpersonationId: 9,
+					Method:          http.MethodDelete,
+					Path:            "/profile/identities/3",
+				})).Return(usecase.RecordImpersonationUseOutput{}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/impersonation-not-allowed",
+				Title:    "Not allowed while impersonating",
+				Status:   http.StatusForbidden,
+				Detail:   "Only the user themselves can change the password or phone numbers, deactivate or delete the account or export their data",
+				Instance: "/profile/identities/3",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error id not a number",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, "abc")
+				},
+				id: "abc",
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/identity-not-found",
+				Title:    "Identity not found",
+				Status:   http.StatusNotFound,
+				Detail:   "You have no identity with this id",
+				Instance: "/profile/identities/abc",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when RemoveIdentity",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, "3")
+				},
+				id: "3",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().RemoveIdentity(gomock.Any(), gomock.Eq(usecase.RemoveIdentityInput{
+					Id:         50,
+					IdentityId: 3,
+				})).Return(usecase.RemoveIdentityOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile/identities/3",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error identity not found",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, "99")
+				},
+				id: "99",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().RemoveIdentity(gomock.Any(), gomock.Any()).Return(usecase.RemoveIdentityOutput{
+					IsNotFound: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/identity-not-found",
+				Title:    "Identity not found",
+				Status:   http.StatusNotFound,
+				Detail:   "You have no identity with this id",
+				Instance: "/profile/identities/99",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error primary phone number",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, "1")
+				},
+				id: "1",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().RemoveIdentity(gomock.Any(), gomock.Any()).Return(usecase.RemoveIdentityOutput{
+					IsPrimaryPhoneNumber: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusConflict,
+			wantResp: generated.Problem{
+				Type:     "/problems/primary-phone-number-not-removable",
+				Title:    "Primary phone number can not be removed",
+				Status:   http.StatusConflict,
+				Detail:   "Every account keeps a phone number, change the phone number of your profile instead",
+				Instance: "/profile/identities/1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error last login",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, "4")
+				},
+				id: "4",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().RemoveIdentity(gomock.Any(), gomock.Eq(usecase.RemoveIdentityInput{
+					Id:         50,
+					IdentityId: 4,
+				})).Return(usecase.RemoveIdentityOutput{
+					IsLastLogin: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusConflict,
+			wantResp: generated.Problem{
+				Type:     "/problems/last-login-not-removable",
+				Title:    "Last login can not be removed",
+				Status:   http.StatusConflict,
+				Detail:   "Your account has no password, this is the only way left to log in",
+				Instance: "/profile/identities/4",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, "3")
+				},
+				id: "3",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().RemoveIdentity(gomock.Any(), gomock.Any()).Return(usecase.RemoveIdentityOutput{}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.BasicSuccessResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Identity removed",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := tt.args.ctx()
+			if err := s.ProfileIdentityRemove(ctx, tt.args.id); (err != nil) != tt.wantErr {
+				t.Errorf("Server.ProfileIdentityRemove() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_ProfileIdentityVerificationSend(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+	mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil).AnyTimes()
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	newCtx := func(token string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodPost, "/profile/identities/3/verification", nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	token, _ := utils.GenerateToken(50, nil)
+
+	type args struct {
+		ctx func() (echo.Context, *httptest.ResponseRecorder)
+		id  string
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error token invalid",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx("abcd")
+				},
+				id: "3",
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/profile/identities/3/verification",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error id not a number",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token)
+				},
+				id: "abc",
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/identity-not-found",
+				Title:    "Identity not found",
+				Status:   http.StatusNotFound,
+				Detail:   "You have no identity with this id",
+				Instance: "/profile/identities/3/verification",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when GetUserData",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token)
+				},
+				id: "3",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Eq(usecase.GetUserDataInput{
+					Id: 50,
+				})).Return(usecase.GetUserDataOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile/identities/3/verification",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when SendIdentityVerification",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token)
+				},
+				id: "3",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{}, nil)
+				mockUsecase.EXPECT().SendIdentityVerification(gomock.Any(), gomock.Any()).Return(usecase.SendIdentityVerificationOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile/identities/3/verification",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error identity not found",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token)
+				},
+				id: "3",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{}, nil)
+				mockUsecase.EXPECT().SendIdentityVerification(gomock.Any(), gomock.Any()).Return(usecase.SendIdentityVerificationOutput{
+					IsNotFound: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/identity-not-found",
+				Title:    "Identity not found",
+				Status:   http.StatusNotFound,
+				Detail:   "You have no identity with this id",
+				Instance: "/profile/identities/3/verification",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error identity not verifiable",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token)
+				},
+				id: "3",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{}, nil)
+				mockUsecase.EXPECT().SendIdentityVerification(gomock.Any(), gomock.Any()).Return(usecase.SendIdentityVerificationOutput{
+					IsNotVerifiable: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/identity-not-verifiable",
+				Title:    "Identity can not be verified",
+				Status:   http.StatusBadRequest,
+				Detail:   "Only email addresses can be verified",
+				Instance: "/profile/identities/3/verification",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error identity already verified",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token)
+				},
+				id: "3",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{}, nil)
+				mockUsecase.EXPECT().SendIdentityVerification(gomock.Any(), gomock.Any()).Return(usecase.SendIdentityVerificationOutput{
+					IsVerified: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusConflict,
+			wantResp: generated.Problem{
+				Type:     "/problems/identity-already-verified",
+				Title:    "Identity already verified",
+				Status:   http.StatusConflict,
+				Detail:   "This identity is already verified",
+				Instance: "/profile/identities/3/verification",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, mailed in the preferred language",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token)
+				},
+				id: "3",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserData(gomock.Any(), gomock.Any()).Return(usecase.GetUserDataOutput{
+					Language: "id",
+				}, nil)
+				mockUsecase.EXPECT().SendIdentityVerification(gomock.Any(), gomock.Eq(usecase.SendIdentityVerificationInput{
+					Id:           50,
+					IdentityId:   3,
+					MailLanguage: "id",
+				})).Return(usecase.SendIdentityVerificationOutput{}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.BasicSuccessResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusAccepted,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Email verifikasi telah dikirim",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := tt.args.ctx()
+			if err := s.ProfileIdentityVerificationSend(ctx, tt.args.id); (err != nil) != tt.wantErr {
+				t.Errorf("Server.ProfileIdentityVerificationSend() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_OidcLoginStart(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	newCtx := func(provider string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/oidc/"+provider+"/start", nil)
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	tests := []struct {
+		name         string
+		provider     string
+		mockFunc     func()
+		wantCode     int
+		wantResp     interface{}
+		wantLocation string
+		wantCookie   string
+	}{
+		{
+			name:     "Error when StartOIDCLogin",
+			provider: "acme",
+			mockFunc: func() {
+				mockUsecase.EXPECT().StartOIDCLogin(gomock.Any(), gomock.Eq(usecase.StartOIDCLoginInput{
+					Provider: "acme",
+				})).Return(usecase.StartOIDCLoginOutput{}, errors.New("test"))
+			},
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/oidc/acme/start",
+			},
+		},
+		{
+			name:     "Error provider unknown",
+			provider: "unknown",
+			mockFunc: func() {
+				mockUsecase.EXPECT().StartOIDCLogin(gomock.Any(), gomock.Any()).Return(usecase.StartOIDCLoginOutput{
+					IsProviderUnknown: true,
+				}, nil)
+			},
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/oidc-provider-not-found",
+				Title:    "Login provider not found",
+				Status:   http.StatusNotFound,
+				Detail:   "There is no login provider with this name",
+				Instance: "/oidc/unknown/start",
+			},
+		},
+		{
+			name:     "Success",
+			provider: "acme",
+			mockFunc: func() {
+				mockUsecase.EXPECT().StartOIDCLogin(gomock.Any(), gomock.Any()).Return(usecase.StartOIDCLoginOutput{
+					AuthorizationURL: "https://idp.example.com/authorize?state=abcd",
+					FlowToken:        "flow-token",
+				}, nil)
+			},
+			wantCode:     http.StatusFound,
+			wantLocation: "https://idp.example.com/authorize?state=abcd",
+			wantCookie:   "oidc_flow=flow-token; Path=/oidc/acme; HttpOnly; SameSite=Lax",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc()
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := newCtx(tt.provider)
+			if err := s.OidcLoginStart(ctx, tt.provider); err != nil {
+				t.Errorf("Server.OidcLoginStart() error = %v", err)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+				assert.Equal(t, tt.wantResp, problemResponse(rec))
+			}
+
+			assert.Equal(t, tt.wantLocation, rec.Header().Get(echo.HeaderLocation))
+			assert.Equal(t, tt.wantCookie, rec.Header().Get(echo.HeaderSetCookie))
+		})
+	}
+}
+
+func TestServer_OidcLoginCallback(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	newCtx := func(provider, flowToken string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/oidc/"+provider+"/callback", nil)
+		if flowToken != "" {
+			req.AddCookie(&http.Cookie{Name: "oidc_flow", Value: flowToken})
+		}
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	code, state, providerError := "code-1", "state-1", "access_denied"
+
+	type args struct {
+		provider  string
+		flowToken string
+		params    generated.OidcLoginCallbackParams
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+	}{
+		{
+			name: "Error login refused by the provider",
+			args: args{
+				provider:  "acme",
+				flowToken: "flow-token",
+				params: generated.OidcLoginCallbackParams{
+					State: &state,
+					Error: &providerError,
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusUnauthorized,
+			wantResp: generated.Problem{
+				Type:     "/problems/oidc-login-failed",
+				Title:    "Login failed",
+				Status:   http.StatusUnauthorized,
+				Detail:   "The login at the provider was cancelled, refused or expired, please start over",
+				Instance: "/oidc/acme/callback",
+			},
+		},
+		{
+			name: "Error account pending",
+			args: args{
+				provider: "acme",
+				params: generated.OidcLoginCallbackParams{
+					Code:  &code,
+					State: &state,
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().FinishOIDCLogin(gomock.Any(), gomock.Any()).Return(usecase.FinishOIDCLoginOutput{
+					IsAccountPending: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/account-pending",
+				Title:    "Account pending",
+				Status:   http.StatusForbidden,
+				Detail:   "Your account has not been activated yet",
+				Instance: "/oidc/acme/callback",
+			},
+		},
+		{
+			name: "Error account suspended",
+			args: args{
+				provider: "acme",
+				params: generated.OidcLoginCallbackParams{
+					Code:  &code,
+					State: &state,
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().FinishOIDCLogin(gomock.Any(), gomock.Any()).Return(usecase.FinishOIDCLoginOutput{
+					IsAccountSuspended: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/account-suspended",
+				Title:    "Account suspended",
+				Status:   http.StatusForbidden,
+				Detail:   "Your account has been suspended, please contact support",
+				Instance: "/oidc/acme/callback",
+			},
+		},
+		{
+			name: "Error account deleted",
+			args: args{
+				provider: "acme",
+				params: generated.OidcLoginCallbackParams{
+					Code:  &code,
+					State: &state,
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().FinishOIDCLogin(gomock.Any(), gomock.Any()).Return(usecase.FinishOIDCLoginOutput{
+					IsAccountDeleted: true,
+					Token:            "restricted",
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/account-deleted",
+				Title:    "Account deleted",
+				Status:   http.StatusForbidden,
+				Detail:   "Your account will be erased soon, restore it with the returned token to keep it",
+				Instance: "/oidc/acme/callback",
+				Token:    func(s string) *string { return &s }("restricted"),
+			},
+		},
+		{
+			name: "Error when FinishOIDCLogin",
+			args: args{
+				provider:  "acme",
+				flowToken: "flow-token",
+				params: generated.OidcLoginCallbackParams{
+					Code:  &code,
+					State: &state,
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().FinishOIDCLogin(gomock.Any(), gomock.Eq(usecase.FinishOIDCLoginInput{
+					Provider:  "acme",
+					Code:      code,
+					State:     state,
+					FlowToken: "flow-token",
+					IP:        "192.0.2.1",
+				})).Return(usecase.FinishOIDCLoginOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/oidc/acme/callback",
+			},
+		},
+		{
+			name: "Error provider unknown",
+			args: args{
+				provider: "unknown",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().FinishOIDCLogin(gomock.Any(), gomock.Eq(usecase.FinishOIDCLoginInput{
+					Provider: "unknown",
+					IP:       "192.0.2.1",
+				})).Return(usecase.FinishOIDCLoginOutput{
+					IsProviderUnknown: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/oidc-provider-not-found",
+				Title:    "Login provider not found",
+				Status:   http.StatusNotFound,
+				Detail:   "There is no login provider with this name",
+				Instance: "/oidc/unknown/callback",
+			},
+		},
+		{
+			name: "Error login invalid",
+			args: args{
+				provider: "acme",
+				params: generated.OidcLoginCallbackParams{
+					Code:  &code,
+					State: &state,
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().FinishOIDCLogin(gomock.Any(), gomock.Any()).Return(usecase.FinishOIDCLoginOutput{
+					IsInvalid: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusUnauthorized,
+			wantResp: generated.Problem{
+				Type:     "/problems/oidc-login-failed",
+				Title:    "Login failed",
+				Status:   http.StatusUnauthorized,
+				Detail:   "The login at the provider was cancelled, refused or expired, please start over",
+				Instance: "/oidc/acme/callback",
+			},
+		},
+		{
+			name: "Success",
+			args: args{
+				provider:  "acme",
+				flowToken: "flow-token",
+				params: generated.OidcLoginCallbackParams{
+					Code:  &code,
+					State: &state,
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().FinishOIDCLogin(gomock.Any(), gomock.Any()).Return(usecase.FinishOIDCLoginOutput{
+					Token: "token",
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.LoginSuccessResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.LoginSuccessResponse{
+				Message: "Login success",
+				Token:   "token",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := newCtx(tt.args.provider, tt.args.flowToken)
+			if err := s.OidcLoginCallback(ctx, tt.args.provider, tt.args.params); err != nil {
+				t.Errorf("Server.OidcLoginCallback() error = %v", err)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			// the flow cookie is dropped whatever the outcome
+			assert.Equal(t, "oidc_flow=; Path=/oidc/"+tt.args.provider+"; Max-Age=0; HttpOnly; SameSite=Lax", rec.Header().Get(echo.HeaderSetCookie))
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_AdminUsersSearch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	newCtx := func() (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	var (
+		createdFrom = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		createdTo   = time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+		createdAt   = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		text        = func(s string) *string { return &s }
+		number      = func(n int) *int { return &n }
+		sort        = func(s generated.AdminUsersSearchParamsSort) *generated.AdminUsersSearchParamsSort { return &s }
+		status      = func(s generated.UserStatus) *generated.UserStatus { return &s }
+	)
+
+	type args struct {
+		params generated.AdminUsersSearchParams
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error parameters invalid",
+			args: args{
+				params: generated.AdminUsersSearchParams{
+					PhonePrefix: text("0812"),
+					CreatedFrom: &createdTo,
+					CreatedTo:   &createdFrom,
+					Status:      status("gone"),
+					Sort:        sort("id"),
+					Limit:       number(101),
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/admin/users",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "created_to",
+						Code:    generated.CREATEDRANGEINVALID,
+						Message: "must be after created_from",
+					},
+					{
+						Field:   "limit",
+						Code:    generated.LIMITOUTOFRANGE,
+						Message: "must be at least 1 and at most 100",
+						Params:  &map[string]interface{}{"min": float64(1), "max": float64(100)},
+					},
+					{
+						Field:   "phone_prefix",
+						Code:    generated.PHONEPREFIXINVALID,
+						Message: "must be “+” followed by up to 15 digits, e.g. “+62812”",
+					},
+					{
+						Field:   "sort",
+						Code:    generated.SORTNOTSUPPORTED,
+						Message: "must be one of the supported sorts: created_at, -created_at, full_name, -full_name",
+						Params:  &map[string]interface{}{"supported_sorts": []interface{}{"created_at", "-created_at", "full_name", "-full_name"}},
+					},
+					{
+						Field:   "status",
+						Code:    generated.STATUSNOTSUPPORTED,
+						Message: "must be one of the supported statuses: pending, active, suspended, deactivated, deleted",
+						Params:  &map[string]interface{}{"supported_statuses": []interface{}{"pending", "active", "suspended", "deactivated", "deleted"}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error limit zero",
+			args: args{
+				params: generated.AdminUsersSearchParams{
+					Limit: number(0),
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/admin/users",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "limit",
+						Code:    generated.LIMITOUTOFRANGE,
+						Message: "must be at least 1 and at most 100",
+						Params:  &map[string]interface{}{"min": float64(1), "max": float64(100)},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error cursor invalid",
+			args: args{
+				params: generated.AdminUsersSearchParams{
+					Cursor: text("abc"),
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().SearchUsers(gomock.Any(), gomock.Any()).Return(usecase.SearchUsersOutput{
+					IsCursorInvalid: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/admin/users",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "cursor",
+						Code:    generated.CURSORINVALID,
+						Message: "must be the next_cursor of a previous page with the same sort",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when SearchUsers",
+			args: args{
+				params: generated.AdminUsersSearchParams{},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().SearchUsers(gomock.Any(), gomock.Any()).Return(usecase.SearchUsersOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/admin/users",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, defaults",
+			args: args{
+				params: generated.AdminUsersSearchParams{
+					PhonePrefix: text(""),
+					Name:        text("  "),
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().SearchUsers(gomock.Any(), gomock.Eq(usecase.SearchUsersInput{
+					Sort:  usecase.USER_SORT_CREATED_AT_DESC,
+					Limit: 20,
+				})).Return(usecase.SearchUsersOutput{}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				return strings.TrimSpace(rec.Body.String())
+			},
+			wantCode: http.StatusOK,
+			wantResp: `{"users":[]}`,
+			wantErr:  false,
+		},
+		{
+			name: "Success, filtered",
+			args: args{
+				params: generated.AdminUsersSearchParams{
+					PhonePrefix: text("+62812"),
+					Name:        text(" doe "),
+					CreatedFrom: &createdFrom,
+					CreatedTo:   &createdTo,
+					Status:      status(usecase.USER_STATUS_ACTIVE),
+					Sort:        sort(generated.FullName),
+					Limit:       number(2),
+					Cursor:      text("cursor"),
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().SearchUsers(gomock.Any(), gomock.Eq(usecase.SearchUsersInput{
+					PhonePrefix: "+62812",
+					Name:        "doe",
+					CreatedFrom: &createdFrom,
+					CreatedTo:   &createdTo,
+					Status:      usecase.USER_STATUS_ACTIVE,
+					Sort:        usecase.USER_SORT_FULL_NAME,
+					Limit:       2,
+					Cursor:      "cursor",
+				})).Return(usecase.SearchUsersOutput{
+					Users: []usecase.UserSummary{
+						{
+							Id:          1,
+							FullName:    "Jane Doe",
+							PhoneNumber: "+6281234567890",
+							Status:      usecase.USER_STATUS_ACTIVE,
+							CreatedAt:   createdAt,
+						},
+						{
+							Id:          2,
+							FullName:    "John Doe",
+							PhoneNumber: "+6281234567891",
+							Email:       "john@example.com",
+							Status:      usecase.USER_STATUS_ACTIVE,
+							CreatedAt:   createdAt,
+						},
+					},
+					NextCursor: "next",
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.AdminUsersResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.AdminUsersResponse{
+				Users: []generated.AdminUserSummary{
+					{
+						Id:          "1",
+						FullName:    "Jane Doe",
+						PhoneNumber: "+6281234567890",
+						Status:      usecase.USER_STATUS_ACTIVE,
+						CreatedAt:   createdAt,
+					},
+					{
+						Id:          "2",
+						FullName:    "John Doe",
+						PhoneNumber: "+6281234567891",
+						Email:       text("john@example.com"),
+						Status:      usecase.USER_STATUS_ACTIVE,
+						CreatedAt:   createdAt,
+					},
+				},
+				NextCursor: text("next"),
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := newCtx()
+			if err := s.AdminUsersSearch(ctx, tt.args.params); (err != nil) != tt.wantErr {
+				t.Errorf("Server.AdminUsersSearch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_AdminUserGet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	newCtx := func(id string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/users/"+id, nil)
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	var (
+		createdAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		updatedAt = time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+		updatedBy = int64(7)
+		text      = func(s string) *string { return &s }
+	)
+
+	type args struct {
+		id string
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error id not a number",
+			args: args{
+				id: "abc",
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/user-not-found",
+				Title:    "User not found",
+				Status:   http.StatusNotFound,
+				Detail:   "There is no user with this id",
+				Instance: "/admin/users/abc",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when GetUserDetails",
+			args: args{
+				id: "1",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserDetails(gomock.Any(), gomock.Any()).Return(usecase.GetUserDetailsOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/admin/users/1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error user not found",
+			args: args{
+				id: "1",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserDetails(gomock.Any(), gomock.Any()).Return(usecase.GetUserDetailsOutput{
+					IsNotFound: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/user-not-found",
+				Title:    "User not found",
+				Status:   http.StatusNotFound,
+				Detail:   "There is no user with this id",
+				Instance: "/admin/users/1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, never updated",
+			args: args{
+				id: "1",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserDetails(gomock.Any(), gomock.Eq(usecase.GetUserDetailsInput{
+					Id: 1,
+				})).Return(usecase.GetUserDetailsOutput{
+					User: usecase.UserDetails{
+						Id:          1,
+						FullName:    "Jane Doe",
+						PhoneNumber: "+6281234567890",
+						Status:      usecase.USER_STATUS_ACTIVE,
+						UserGroup:   "default",
+						CreatedAt:   createdAt,
+					},
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				return strings.TrimSpace(rec.Body.String())
+			},
+			wantCode: http.StatusOK,
+			wantResp: `{"created_at":"2024-01-02T03:04:05Z","email_verified":false,"full_name":"Jane Doe","id":"1","phone_number":"+6281234567890","roles":[],"status":"active","total_login":0,"user_group":"default"}`,
+			wantErr:  false,
+		},
+		{
+			name: "Success",
+			args: args{
+				id: "1",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserDetails(gomock.Any(), gomock.Eq(usecase.GetUserDetailsInput{
+					Id: 1,
+				})).Return(usecase.GetUserDetailsOutput{
+					User: usecase.UserDetails{
+						Id:            1,
+						FullName:      "Jane Doe",
+						PhoneNumber:   "+6281234567890",
+						Email:         "jane@example.com",
+						EmailVerified: true,
+						Language:      "id",
+						Status:        usecase.USER_STATUS_ACTIVE,
+						UserGroup:     "staff",
+						Roles:         []string{"support"},
+						TotalLogin:    12,
+						CreatedAt:     createdAt,
+						UpdatedAt:     &updatedAt,
+						UpdatedBy:     &updatedBy,
+					},
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.AdminUserResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.AdminUserResponse{
+				Id:            "1",
+				FullName:      "Jane Doe",
+				PhoneNumber:   "+6281234567890",
+				Email:         text("jane@example.com"),
+				EmailVerified: true,
+				Language:      text("id"),
+				Status:        usecase.USER_STATUS_ACTIVE,
+				UserGroup:     "staff",
+				Roles:         []string{"support"},
+				TotalLogin:    12,
+				CreatedAt:     createdAt,
+				UpdatedAt:     &updatedAt,
+				UpdatedBy:     text("7"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success with attributes",
+			args: args{
+				id: "1",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserDetails(gomock.Any(), gomock.Eq(usecase.GetUserDetailsInput{
+					Id: 1,
+				})).Return(usecase.GetUserDetailsOutput{
+					User: usecase.UserDetails{
+						Id:          1,
+						FullName:    "Jane Doe",
+						PhoneNumber: "+6281234567890",
+						Status:      usecase.USER_STATUS_ACTIVE,
+						UserGroup:   "default",
+						CreatedAt:   createdAt,
+						Attributes: map[string]interface{}{
+							"employee_id": "E-1",
+						},
+					},
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				return strings.TrimSpace(rec.Body.String())
+			},
+			wantCode: http.StatusOK,
+			wantResp: `{"attributes":{"employee_id":"E-1"},"created_at":"2024-01-02T03:04:05Z","email_verified":false,"full_name":"Jane Doe","id":"1","phone_number":"+6281234567890","roles":[],"status":"active","total_login":0,"user_group":"default"}`,
+			wantErr:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := newCtx(tt.args.id)
+			if err := s.AdminUserGet(ctx, tt.args.id); (err != nil) != tt.wantErr {
+				t.Errorf("Server.AdminUserGet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func problemResponse(rec *httptest.ResponseRecorder) interface{} {
+	var resp generated.Problem
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+
+	return resp
+}
+
+func TestServer_ProfileDeactivate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	newCtx := func(token string, data url.Values) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodPost, "/profile/deactivation", strings.NewReader(data.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	token, _ := utils.GenerateToken(50, nil)
+	claims, _ := utils.TokenParseClaims(token)
+	actingToken, _ := utils.GenerateImpersonationToken(50, 1, 9, time.Now().Add(time.Minute))
+
+	active := func() {
+		mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Eq(usecase.GetUserStatusInput{
+			Id: 50,
+		})).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil)
+	}
+
+	type args struct {
+		ctx func() (echo.Context, *httptest.ResponseRecorder)
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error token invalid",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx("abcd", url.Values{})
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/profile/deactivation",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error account suspended",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, url.Values{"password": {"Current1!"}})
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_SUSPENDED}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/account-suspended",
+				Title:    "Account suspended",
+				Status:   http.StatusForbidden,
+				Detail:   "Your account has been suspended, please contact support",
+				Instance: "/profile/deactivation",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error impersonation",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(actingToken, url.Values{"password": {"Current1!"}})
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().RecordImpersonationUse(gomock.Any(), gomock.Eq(usecase.RecordImpersonationUseInput{
+					ImpersonationId: 9,
+					Method:          http.MethodPost,
+					Path:            "/profile/deactivation",
+				})).Return(usecase.RecordImpersonationUseOutput{}, nil)
+				mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Eq(usecase.GetUserStatusInput{
+					Id: 1,
+				})).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil)
+				active()
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/impersonation-not-allowed",
+				Title:    "Not allowed while impersonating",
+				Status:   http.StatusForbidden,
+				Detail:   "Only the user themselves can change the password or phone numbers, deactivate or delete the account or export their data",
+				Instance: "/profile/deactivation",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error reason too long",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, url.Values{"password": {"Current1!"}, "reason": {strings.Repeat("a", 501)}})
+				},
+			},
+			mockFunc: func(a args) {
+				active()
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/profile/deactivation",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "reason",
+						Code:    generated.REASONTOOLONG,
+						Message: "must be at most 500 characters",
+						Params:  &map[string]interface{}{"max": float64(500)},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when DeactivateUser",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, url.Values{"password": {"Current1!"}})
+				},
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().DeactivateUser(gomock.Any(), gomock.Any()).Return(usecase.DeactivateUserOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile/deactivation",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error password wrong",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, url.Values{"password": {"Wrong1!"}})
+				},
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().DeactivateUser(gomock.Any(), gomock.Any()).Return(usecase.DeactivateUserOutput{
+					IsPasswordWrong: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/profile/deactivation",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "password",
+						Code:    generated.PASSWORDINCORRECT,
+						Message: "is incorrect",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error login required",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, url.Values{})
+				},
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().DeactivateUser(gomock.Any(), gomock.Any()).Return(usecase.DeactivateUserOutput{
+					IsLoginRequired: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/login-required",
+				Title:    "Login required",
+				Status:   http.StatusForbidden,
+				Detail:   "Your account has no password, log in again to confirm this change",
+				Instance: "/profile/deactivation",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, url.Values{"password": {"Current1!"}, "reason": {" taking a break "}})
+				},
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().DeactivateUser(gomock.Any(), gomock.Eq(usecase.DeactivateUserInput{
+					Id:              50,
+					Password:        "Current1!",
+					Reason:          "taking a break",
+					AuthenticatedAt: claims.IssuedAt,
+				})).Return(usecase.DeactivateUserOutput{}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.BasicSuccessResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Account deactivated, log in again to reactivate it",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := tt.args.ctx()
+			if err := s.ProfileDeactivate(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.ProfileDeactivate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_AdminUserStatusUpdate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	newCtx := func(id string, data url.Values) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/users/"+id+"/status", strings.NewReader(data.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+
+		ctx := e.NewContext(req, rec)
+		ctx.Set(tokenClaimsContextKey, utils.TokenClaims{Id: 1, Roles: []string{"admin"}})
+
+		return ctx, rec
+	}
+
+	type args struct {
+		id   string
+		data url.Values
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error id not a number",
+			args: args{
+				id:   "abc",
+				data: url.Values{"status": {"suspended"}, "reason": {"chargeback"}},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/user-not-found",
+				Title:    "User not found",
+				Status:   http.StatusNotFound,
+				Detail:   "There is no user with this id",
+				Instance: "/admin/users/abc/status",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error status and reason invalid",
+			args: args{
+				id:   "2",
+				data: url.Values{"status": {"banned"}, "reason": {"  "}},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/admin/users/2/status",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "reason",
+						Code:    generated.REASONREQUIRED,
+						Message: "must not be empty",
+					},
+					{
+						Field:   "status",
+						Code:    generated.STATUSNOTSUPPORTED,
+						Message: "must be one of the supported statuses: pending, active, suspended, deactivated, deleted",
+						Params:  &map[string]interface{}{"supported_statuses": []interface{}{"pending", "active", "suspended", "deactivated", "deleted"}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error reason too long",
+			args: args{
+				id:   "2",
+				data: url.Values{"status": {"suspended"}, "reason": {strings.Repeat("a", 501)}},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/admin/users/2/status",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "reason",
+						Code:    generated.REASONTOOLONG,
+						Message: "must be at most 500 characters",
+						Params:  &map[string]interface{}{"max": float64(500)},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when ChangeUserStatus",
+			args: args{
+				id:   "2",
+				data: url.Values{"status": {"suspended"}, "reason": {"chargeback"}},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().ChangeUserStatus(gomock.Any(), gomock.Any()).Return(usecase.ChangeUserStatusOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/admin/users/2/status",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error user not found",
+			args: args{
+				id:   "2",
+				data: url.Values{"status": {"suspended"}, "reason": {"chargeback"}},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().ChangeUserStatus(gomock.Any(), gomock.Any()).Return(usecase.ChangeUserStatusOutput{
+					IsNotFound: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/user-not-found",
+				Title:    "User not found",
+				Status:   http.StatusNotFound,
+				Detail:   "There is no user with this id",
+				Instance: "/admin/users/2/status",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error transition not allowed",
+			args: args{
+				id:   "2",
+				data: url.Values{"status": {"active"}, "reason": {"mistake"}},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().ChangeUserStatus(gomock.Any(), gomock.Any()).Return(usecase.ChangeUserStatusOutput{
+					IsTransitionNotAllowed: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusConflict,
+			wantResp: generated.Problem{
+				Type:     "/problems/status-transition-not-allowed",
+				Title:    "Status change not allowed",
+				Status:   http.StatusConflict,
+				Detail:   "The user can not be moved from their current status to this one",
+				Instance: "/admin/users/2/status",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			args: args{
+				id:   "2",
+				data: url.Values{"status": {"suspended"}, "reason": {" chargeback "}},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().ChangeUserStatus(gomock.Any(), gomock.Eq(usecase.ChangeUserStatusInput{
+					Id:        2,
+					Status:    usecase.USER_STATUS_SUSPENDED,
+					Reason:    "chargeback",
+					ChangedBy: 1,
+				})).Return(usecase.ChangeUserStatusOutput{}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.BasicSuccessResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Status changed",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := newCtx(tt.args.id, tt.args.data)
+			if err := s.AdminUserStatusUpdate(ctx, tt.args.id); (err != nil) != tt.wantErr {
+				t.Errorf("Server.AdminUserStatusUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_AdminUserStatusChangesGet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	newCtx := func(id string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/users/"+id+"/status-changes", nil)
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	var (
+		createdAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		changedBy = int64(1)
+	)
+
+	type args struct {
+		id string
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error id not a number",
+			args: args{
+				id: "abc",
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/user-not-found",
+				Title:    "User not found",
+				Status:   http.StatusNotFound,
+				Detail:   "There is no user with this id",
+				Instance: "/admin/users/abc/status-changes",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when GetUserStatusChanges",
+			args: args{
+				id: "2",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserStatusChanges(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusChangesOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/admin/users/2/status-changes",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error user not found",
+			args: args{
+				id: "2",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserStatusChanges(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusChangesOutput{
+					IsNotFound: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/user-not-found",
+				Title:    "User not found",
+				Status:   http.StatusNotFound,
+				Detail:   "There is no user with this id",
+				Instance: "/admin/users/2/status-changes",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, no changes",
+			args: args{
+				id: "2",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserStatusChanges(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusChangesOutput{}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				return strings.TrimSpace(rec.Body.String())
+			},
+			wantCode: http.StatusOK,
+			wantResp: `{"status_changes":[]}`,
+			wantErr:  false,
+		},
+		{
+			name: "Success",
+			args: args{
+				id: "2",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().GetUserStatusChanges(gomock.Any(), gomock.Eq(usecase.GetUserStatusChangesInput{
+					Id: 2,
+				})).Return(usecase.GetUserStatusChangesOutput{
+					Changes: []usecase.UserStatusChange{
+						{
+							Id:         6,
+							FromStatus: usecase.USER_STATUS_SUSPENDED,
+							ToStatus:   usecase.USER_STATUS_ACTIVE,
+							Reason:     "chargeback withdrawn",
+							ChangedBy:  &changedBy,
+							CreatedAt:  createdAt,
+						},
+						{
+							Id:         5,
+							FromStatus: usecase.USER_STATUS_ACTIVE,
+							ToStatus:   usecase.USER_STATUS_SUSPENDED,
+							Reason:     "chargeback",
+							CreatedAt:  createdAt,
+						},
+					},
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.UserStatusChangesResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.UserStatusChangesResponse{
+				StatusChanges: []generated.UserStatusChange{
+					{
+						Id:         "6",
+						FromStatus: generated.Suspended,
+						ToStatus:   generated.Active,
+						Reason:     "chargeback withdrawn",
+						ChangedBy:  func(s string) *string { return &s }("1"),
+						CreatedAt:  createdAt,
+					},
+					{
+						Id:         "5",
+						FromStatus: generated.Active,
+						ToStatus:   generated.Suspended,
+						Reason:     "chargeback",
+						CreatedAt:  createdAt,
+					},
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := newCtx(tt.args.id)
+			if err := s.AdminUserStatusChangesGet(ctx, tt.args.id); (err != nil) != tt.wantErr {
+				t.Errorf("Server.AdminUserStatusChangesGet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_AdminUserImpersonate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	newCtx := func(id string, data url.Values) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/users/"+id+"/impersonation", strings.NewReader(data.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+
+		ctx := e.NewContext(req, rec)
+		ctx.Set(tokenClaimsContextKey, utils.TokenClaims{Id: 1, Roles: []string{"support"}})
+
+		return ctx, rec
+	}
+
+	expiresAt := time.Date(2024, 1, 2, 3, 19, 5, 0, time.UTC)
+
+	type args struct {
+		id   string
+		data url.Values
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error id not a number",
+			args: args{
+				id:   "abc",
+				data: url.Values{"reason": {"ticket 42"}},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/user-not-found",
+				Title:    "User not found",
+				Status:   http.StatusNotFound,
+				Detail:   "There is no user with this id",
+				Instance: "/admin/users/abc/impersonation",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error reason missing",
+			args: args{
+				id:   "2",
+				data: url.Values{},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/admin/users/2/impersonation",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "reason",
+						Code:    generated.REASONREQUIRED,
+						Message: "must not be empty",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when ImpersonateUser",
+			args: args{
+				id:   "2",
+				data: url.Values{"reason": {"ticket 42"}},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().ImpersonateUser(gomock.Any(), gomock.Any()).Return(usecase.ImpersonateUserOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/admin/users/2/impersonation",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error user not found",
+			args: args{
+				id:   "2",
+				data: url.Values{"reason": {"ticket 42"}},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().ImpersonateUser(gomock.Any(), gomock.Any()).Return(usecase.ImpersonateUserOutput{
+					IsNotFound: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/user-not-found",
+				Title:    "User not found",
+				Status:   http.StatusNotFound,
+				Detail:   "There is no user with this id",
+				Instance: "/admin/users/2/impersonation",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error user not active",
+			args: args{
+				id:   "2",
+				data: url.Values{"reason": {"ticket 42"}},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().ImpersonateUser(gomock.Any(), gomock.Any()).Return(usecase.ImpersonateUserOutput{
+					IsNotActive: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusConflict,
+			wantResp: generated.Problem{
+				Type:     "/problems/user-not-active",
+				Title:    "User not active",
+				Status:   http.StatusConflict,
+				Detail:   "Only active users can be impersonated",
+				Instance: "/admin/users/2/impersonation",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			args: args{
+				id:   "2",
+				data: url.Values{"reason": {" ticket 42 "}},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().ImpersonateUser(gomock.Any(), gomock.Eq(usecase.ImpersonateUserInput{
+					Id:      2,
+					AdminId: 1,
+					Reason:  "ticket 42",
+				})).Return(usecase.ImpersonateUserOutput{
+					Token:     "token",
+					ExpiresAt: expiresAt,
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.ImpersonationResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusCreated,
+			wantResp: generated.ImpersonationResponse{
+				Message:   "You can now act as the user until the token expires",
+				Token:     "token",
+				ExpiresAt: expiresAt,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := newCtx(tt.args.id, tt.args.data)
+			if err := s.AdminUserImpersonate(ctx, tt.args.id); (err != nil) != tt.wantErr {
+				t.Errorf("Server.AdminUserImpersonate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_ProfileDelete(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	newCtx := func(token string, body string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodDelete, "/profile", strings.NewReader(body))
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	token, _ := utils.GenerateToken(50, nil)
+	claims, _ := utils.TokenParseClaims(token)
+	actingToken, _ := utils.GenerateImpersonationToken(50, 1, 9, time.Now().Add(time.Minute))
+	purgeAt := time.Date(2024, 2, 1, 3, 4, 5, 0, time.UTC)
+
+	active := func() {
+		mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Eq(usecase.GetUserStatusInput{
+			Id: 50,
+		})).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil)
+	}
+
+	type args struct {
+		ctx func() (echo.Context, *httptest.ResponseRecorder)
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error token invalid",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx("abcd", `{}`)
+				},
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error impersonation",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(actingToken, `{}`)
+				},
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().RecordImpersonationUse(gomock.Any(), gomock.Eq(usecase.RecordImpersonationUseInput{
+					ImpersonationId: 9,
+					Method:          http.MethodDelete,
+					Path:            "/profile",
+				})).Return(usecase.RecordImpersonationUseOutput{}, nil)
+				mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Eq(usecase.GetUserStatusInput{
+					Id: 1,
+				})).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil)
+				active()
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/impersonation-not-allowed",
+				Title:    "Not allowed while impersonating",
+				Status:   http.StatusForbidden,
+				Detail:   "Only the user themselves can change the password or phone numbers, deactivate or delete the account or export their data",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when DeleteUser",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, `{"password":"Current1!"}`)
+				},
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().DeleteUser(gomock.Any(), gomock.Any()).Return(usecase.DeleteUserOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error password wrong",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, `{"password":"Wrong1!"}`)
+				},
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().DeleteUser(gomock.Any(), gomock.Any()).Return(usecase.DeleteUserOutput{
+					IsPasswordWrong: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/profile",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "password",
+						Code:    generated.PASSWORDINCORRECT,
+						Message: "is incorrect",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error login required",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, ``)
+				},
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().DeleteUser(gomock.Any(), gomock.Any()).Return(usecase.DeleteUserOutput{
+					IsLoginRequired: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/login-required",
+				Title:    "Login required",
+				Status:   http.StatusForbidden,
+				Detail:   "Your account has no password, log in again to confirm this change",
+				Instance: "/profile",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, user without a password who logged in recently",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, ``)
+				},
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().DeleteUser(gomock.Any(), gomock.Eq(usecase.DeleteUserInput{
+					Id:              50,
+					AuthenticatedAt: claims.IssuedAt,
+				})).Return(usecase.DeleteUserOutput{
+					PurgeAt: purgeAt,
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.AccountDeletionResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.AccountDeletionResponse{
+				Message: "Account deleted, log in again before it is erased to restore it",
+				PurgeAt: purgeAt,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			args: args{
+				ctx: func() (echo.Context, *httptest.ResponseRecorder) {
+					return newCtx(token, `{"password":"Current1!"}`)
+				},
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().DeleteUser(gomock.Any(), gomock.Eq(usecase.DeleteUserInput{
+					Id:              50,
+					Password:        "Current1!",
+					AuthenticatedAt: claims.IssuedAt,
+				})).Return(usecase.DeleteUserOutput{
+					PurgeAt: purgeAt,
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.AccountDeletionResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.AccountDeletionResponse{
+				Message: "Account deleted, log in again before it is erased to restore it",
+				PurgeAt: purgeAt,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := tt.args.ctx()
+			if err := s.ProfileDelete(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.ProfileDelete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_ProfileExport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	newCtx := func(token string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/profile/export", nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	token, _ := utils.GenerateToken(50, nil)
+	actingToken, _ := utils.GenerateImpersonationToken(50, 1, 9, time.Now().Add(time.Minute))
+	expiresAt := time.Date(2024, 1, 3, 3, 4, 5, 0, time.UTC)
+
+	format := func(f generated.ProfileExportParamsFormat) *generated.ProfileExportParamsFormat {
+		return &f
+	}
+
+	active := func() {
+		mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Eq(usecase.GetUserStatusInput{
+			Id: 50,
+		})).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil)
+	}
+
+	type args struct {
+		token  string
+		params generated.ProfileExportParams
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		// wantHeader is the Content-Type and Content-Disposition of a file
+		wantHeader []string
+		wantErr    bool
+	}{
+		{
+			name: "Error token invalid",
+			args: args{
+				token: "abcd",
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/profile/export",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error impersonation",
+			args: args{
+				token: actingToken,
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().RecordImpersonationUse(gomock.Any(), gomock.Eq(usecase.RecordImpersonationUseInput{
+					ImpersonationId: 9,
+					Method:          http.MethodGet,
+					Path:            "/profile/export",
+				})).Return(usecase.RecordImpersonationUseOutput{}, nil)
+				mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Eq(usecase.GetUserStatusInput{
+					Id: 1,
+				})).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil)
+				active()
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/impersonation-not-allowed",
+				Title:    "Not allowed while impersonating",
+				Status:   http.StatusForbidden,
+				Detail:   "Only the user themselves can change the password or phone numbers, deactivate or delete the account or export their data",
+				Instance: "/profile/export",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error format not supported",
+			args: args{
+				token: token,
+				params: generated.ProfileExportParams{
+					Format: format("csv"),
+				},
+			},
+			mockFunc: func(a args) {
+				active()
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/profile/export",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "format",
+						Code:    generated.FORMATNOTSUPPORTED,
+						Message: "must be one of the supported formats: json, ndjson",
+						Params: &map[string]interface{}{
+							"supported_formats": []interface{}{"json", "ndjson"},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when ExportUserData",
+			args: args{
+				token: token,
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().ExportUserData(gomock.Any(), gomock.Any()).Return(usecase.ExportUserDataOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile/export",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, json by default",
+			args: args{
+				token: token,
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().ExportUserData(gomock.Any(), gomock.Eq(usecase.ExportUserDataInput{
+					Id:     50,
+					Format: usecase.DATA_EXPORT_FORMAT_JSON,
+				})).Return(usecase.ExportUserDataOutput{
+					File: usecase.DataExportFile{
+						FileName:    "user-data-50.json",
+						ContentType: "application/json",
+						Content:     []byte(`{"profile":{}}`),
+					},
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				return rec.Body.String()
+			},
+			wantCode:   http.StatusOK,
+			wantResp:   `{"profile":{}}`,
+			wantHeader: []string{"application/json", `attachment; filename="user-data-50.json"`},
+			wantErr:    false,
+		},
+		{
+			name: "Success, zipped ndjson",
+			args: args{
+				token: token,
+				params: generated.ProfileExportParams{
+					Format: format(generated.Ndjson),
+				},
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().ExportUserData(gomock.Any(), gomock.Eq(usecase.ExportUserDataInput{
+					Id:     50,
+					Format: usecase.DATA_EXPORT_FORMAT_NDJSON,
+				})).Return(usecase.ExportUserDataOutput{
+					File: usecase.DataExportFile{
+						FileName:    "user-data-50.zip",
+						ContentType: "application/zip",
+						Content:     []byte("PK"),
+					},
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				return rec.Body.String()
+			},
+			wantCode:   http.StatusOK,
+			wantResp:   "PK",
+			wantHeader: []string{"application/zip", `attachment; filename="user-data-50.zip"`},
+			wantErr:    false,
+		},
+		{
+			name: "Success, async",
+			args: args{
+				token: token,
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().ExportUserData(gomock.Any(), gomock.Any()).Return(usecase.ExportUserDataOutput{
+					IsAsync:   true,
+					ExportId:  7,
+					ExpiresAt: expiresAt,
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.DataExportJobResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusAccepted,
+			wantResp: generated.DataExportJobResponse{
+				Message:     "Your data is being exported, download it from download_url once it is ready",
+				Id:          7,
+				DownloadUrl: "/profile/exports/7",
+				ExpiresAt:   expiresAt,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := newCtx(tt.args.token)
+			if err := s.ProfileExport(ctx, tt.args.params); (err != nil) != tt.wantErr {
+				t.Errorf("Server.ProfileExport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+			if tt.wantHeader != nil {
+				assert.Equal(t, tt.wantHeader, []string{rec.Header().Get(echo.HeaderContentType), rec.Header().Get(echo.HeaderContentDisposition)})
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_ProfileExportGet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	newCtx := func(token, exportId string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/profile/exports/"+exportId, nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	token, _ := utils.GenerateToken(50, nil)
+	actingToken, _ := utils.GenerateImpersonationToken(50, 1, 9, time.Now().Add(time.Minute))
+	expiresAt := time.Date(2024, 1, 3, 3, 4, 5, 0, time.UTC)
+
+	active := func() {
+		mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Eq(usecase.GetUserStatusInput{
+			Id: 50,
+		})).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil)
+	}
+
+	type args struct {
+		token    string
+		exportId string
+	}
+
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		respFunc   func(*httptest.ResponseRecorder) interface{}
+		wantCode   int
+		wantResp   interface{}
+		wantHeader []string
+		wantErr    bool
+	}{
+		{
+			name: "Error impersonation",
+			args: args{
+				token:    actingToken,
+				exportId: "7",
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().RecordImpersonationUse(gomock.Any(), gomock.Eq(usecase.RecordImpersonationUseInput{
+					ImpersonationId: 9,
+					Method:          http.MethodGet,
+					Path:            "/profile/exports/7",
+				})).Return(usecase.RecordImpersonationUseOutput{}, nil)
+				mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Eq(usecase.GetUserStatusInput{
+					Id: 1,
+				})).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil)
+				active()
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/impersonation-not-allowed",
+				Title:    "Not allowed while impersonating",
+				Status:   http.StatusForbidden,
+				Detail:   "Only the user themselves can change the password or phone numbers, deactivate or delete the account or export their data",
+				Instance: "/profile/exports/7",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error id not a number",
+			args: args{
+				token:    token,
+				exportId: "abc",
+			},
+			mockFunc: func(a args) {
+				active()
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/data-export-not-found",
+				Title:    "Data export not found",
+				Status:   http.StatusNotFound,
+				Detail:   "You have no data export with this id or it has expired",
+				Instance: "/profile/exports/abc",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when GetDataExport",
+			args: args{
+				token:    token,
+				exportId: "7",
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().GetDataExport(gomock.Any(), gomock.Any()).Return(usecase.GetDataExportOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile/exports/7",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error not found",
+			args: args{
+				token:    token,
+				exportId: "7",
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().GetDataExport(gomock.Any(), gomock.Any()).Return(usecase.GetDataExportOutput{
+					IsNotFound: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/data-export-not-found",
+				Title:    "Data export not found",
+				Status:   http.StatusNotFound,
+				Detail:   "You have no data export with this id or it has expired",
+				Instance: "/profile/exports/7",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error failed",
+			args: args{
+				token:    token,
+				exportId: "7",
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().GetDataExport(gomock.Any(), gomock.Any()).Return(usecase.GetDataExportOutput{
+					IsFailed: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusGone,
+			wantResp: generated.Problem{
+				Type:     "/problems/data-export-failed",
+				Title:    "Data export failed",
+				Status:   http.StatusGone,
+				Detail:   "The data export could not be built, please request a new one",
+				Instance: "/profile/exports/7",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, pending",
+			args: args{
+				token:    token,
+				exportId: "7",
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().GetDataExport(gomock.Any(), gomock.Any()).Return(usecase.GetDataExportOutput{
+					IsPending: true,
+					ExpiresAt: expiresAt,
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.DataExportJobResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusAccepted,
+			wantResp: generated.DataExportJobResponse{
+				Message:     "Your data export is not ready yet",
+				Id:          7,
+				DownloadUrl: "/profile/exports/7",
+				ExpiresAt:   expiresAt,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			args: args{
+				token:    token,
+				exportId: "7",
+			},
+			mockFunc: func(a args) {
+				active()
+				mockUsecase.EXPECT().GetDataExport(gomock.Any(), gomock.Eq(usecase.GetDataExportInput{
+					Id:       50,
+					ExportId: 7,
+				})).Return(usecase.GetDataExportOutput{
+					ExpiresAt: expiresAt,
+					File: usecase.DataExportFile{
+						FileName:    "user-data-50.zip",
+						ContentType: "application/zip",
+						Content:     []byte("PK"),
+					},
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				return rec.Body.String()
+			},
+			wantCode:   http.StatusOK,
+			wantResp:   "PK",
+			wantHeader: []string{"application/zip", `attachment; filename="user-data-50.zip"`},
+			wantErr:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := newCtx(tt.args.token, tt.args.exportId)
+			if err := s.ProfileExportGet(ctx, tt.args.exportId); (err != nil) != tt.wantErr {
+				t.Errorf("Server.ProfileExportGet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+			if tt.wantHeader != nil {
+				assert.Equal(t, tt.wantHeader, []string{rec.Header().Get(echo.HeaderContentType), rec.Header().Get(echo.HeaderContentDisposition)})
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_AccountRestore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	newCtx := func(token string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodPost, "/login/restoration", nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	token, _ := utils.GenerateToken(50, nil)
+	restorationToken, _ := utils.GenerateScopedToken(50, utils.TOKEN_SCOPE_ACCOUNT_RESTORATION, time.Minute)
+
+	tests := []struct {
+		name     string
+		token    string
+		mockFunc func()
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "Error regular token",
+			token:    token,
+			mockFunc: func() {},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/login/restoration",
+			},
+			wantErr: false,
+		},
+		{
+			name:  "Error when RestoreUser",
+			token: restorationToken,
+			mockFunc: func() {
+				mockUsecase.EXPECT().RestoreUser(gomock.Any(), gomock.Any()).Return(usecase.RestoreUserOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/login/restoration",
+			},
+			wantErr: false,
+		},
+		{
+			name:  "Error not restorable",
+			token: restorationToken,
+			mockFunc: func() {
+				mockUsecase.EXPECT().RestoreUser(gomock.Any(), gomock.Any()).Return(usecase.RestoreUserOutput{
+					IsNotRestorable: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusConflict,
+			wantResp: generated.Problem{
+				Type:     "/problems/account-not-restorable",
+				Title:    "Account not restorable",
+				Status:   http.StatusConflict,
+				Detail:   "The account is not deleted or has already been erased",
+				Instance: "/login/restoration",
+			},
+			wantErr: false,
+		},
+		{
+			name:  "Success",
+			token: restorationToken,
+			mockFunc: func() {
+				mockUsecase.EXPECT().RestoreUser(gomock.Any(), gomock.Eq(usecase.RestoreUserInput{
+					Id: 50,
+				})).Return(usecase.RestoreUserOutput{}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.BasicSuccessResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Account restored, please log in again",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc()
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := newCtx(tt.token)
+			if err := s.AccountRestore(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.AccountRestore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_ProfileAvatarUpdate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	// newCtx uploads content as field, no body at all when field is empty
+	newCtx := func(token, field string, content []byte) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		var (
+			body        bytes.Buffer
+			contentType string
+		)
+		if field != "" {
+			writer := multipart.NewWriter(&body)
+			writer.WriteField("note", "ignored")
+			part, _ := writer.CreateFormFile(field, "avatar.png")
+			part.Write(content)
+			writer.Close()
+			contentType = writer.FormDataContentType()
+		}
+
+		req := httptest.NewRequest(http.MethodPut, "/profile/avatar", &body)
+		if contentType != "" {
+			req.Header.Set(echo.HeaderContentType, contentType)
+		}
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	token, _ := utils.GenerateToken(50, nil)
+	content := []byte("\x89PNG\x0d\x0a\x1a\x0aimage")
+
+	active := func() {
+		mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Eq(usecase.GetUserStatusInput{
+			Id: 50,
+		})).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil)
+	}
+
+	// setAvatar expects the uploaded content to be handed to SetAvatar
+	setAvatar := func(output usecase.SetAvatarOutput, err error) {
+		mockUsecase.EXPECT().SetAvatar(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, input usecase.SetAvatarInput) (usecase.SetAvatarOutput, error) {
+			got, _ := io.ReadAll(input.Content)
+			if input.Id != 50 || !bytes.Equal(got, content) {
+				t.Errorf("SetAvatar() input = %d, %q", input.Id, got)
+			}
+			return output, err
+		})
+	}
+
+	validationProblem := func(validationError generated.ValidationError) generated.Problem {
+		return generated.Problem{
+			Type:     "/problems/validation-error",
+			Title:    "Invalid request",
+			Status:   http.StatusBadRequest,
+			Detail:   "One or more fields are invalid, see errors",
+			Instance: "/profile/avatar",
+			Errors:   &[]generated.ValidationError{validationError},
+		}
+	}
+
+	type args struct {
+		token string
+		field string
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error token invalid",
+			args: args{
+				token: "abcd",
+				field: "avatar",
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/profile/avatar",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error avatar required when not multipart",
+			args: args{
+				token: token,
+			},
+			mockFunc: func(a args) {
+				active()
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: validationProblem(generated.ValidationError{
+				Field:   "avatar",
+				Code:    generated.AVATARREQUIRED,
+				Message: "must be an uploaded image file",
+			}),
+			wantErr: false,
+		},
+		{
+			name: "Error avatar required when missing",
+			args: args{
+				token: token,
+				field: "picture",
+			},
+			mockFunc: func(a args) {
+				active()
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: validationProblem(generated.ValidationError{
+				Field:   "avatar",
+				Code:    generated.AVATARREQUIRED,
+				Message: "must be an uploaded image file",
+			}),
+			wantErr: false,
+		},
+		{
+			name: "Error when SetAvatar",
+			args: args{
+				token: token,
+				field: "avatar",
+			},
+			mockFunc: func(a args) {
+				active()
+				setAvatar(usecase.SetAvatarOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile/avatar",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error avatar too large",
+			args: args{
+				token: token,
+				field: "avatar",
+			},
+			mockFunc: func(a args) {
+				active()
+				setAvatar(usecase.SetAvatarOutput{IsTooLarge: true, MaxBytes: 1024}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: validationProblem(generated.ValidationError{
+				Field:   "avatar",
+				Code:    generated.AVATARTOOLARGE,
+				Message: "must be at most 1024 bytes",
+				Params: &map[string]interface{}{
+					"max_bytes": float64(1024),
+				},
+			}),
+			wantErr: false,
+		},
+		{
+			name: "Error avatar type not supported",
+			args: args{
+				token: token,
+				field: "avatar",
+			},
+			mockFunc: func(a args) {
+				active()
+				setAvatar(usecase.SetAvatarOutput{IsTypeNotSupported: true}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: validationProblem(generated.ValidationError{
+				Field:   "avatar",
+				Code:    generated.AVATARTYPENOTSUPPORTED,
+				Message: "must be an image of one of the supported types: image/jpeg, image/png, image/gif",
+				Params: &map[string]interface{}{
+					"supported_types": []interface{}{"image/jpeg", "image/png", "image/gif"},
+				},
+			}),
+			wantErr: false,
+		},
+		{
+			name: "Error avatar dimensions invalid",
+			args: args{
+				token: token,
+				field: "avatar",
+			},
+			mockFunc: func(a args) {
+				active()
+				setAvatar(usecase.SetAvatarOutput{IsDimensionsInvalid: true}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: validationProblem(generated.ValidationError{
+				Field:   "avatar",
+				Code:    generated.AVATARDIMENSIONSINVALID,
+				Message: "must be at least 64 and at most 4096 pixels wide and high",
+				Params: &map[string]interface{}{
+					"min": float64(64),
+					"max": float64(4096),
+				},
+			}),
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			args: args{
+				token: token,
+				field: "avatar",
+			},
+			mockFunc: func(a args) {
+				active()
+				setAvatar(usecase.SetAvatarOutput{
+					Avatar: usecase.AvatarURLs{
+						Small:  "http://localhost/storage/avatars/50/abc/small.jpg",
+						Medium: "http://localhost/storage/avatars/50/abc/medium.jpg",
+						Large:  "http://localhost/storage/avatars/50/abc/large.jpg",
+					},
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.AvatarResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.AvatarResponse{
+				Message: "Avatar updated",
+				AvatarUrls: generated.AvatarURLs{
+					Small:  "http://localhost/storage/avatars/50/abc/small.jpg",
+					Medium: "http://localhost/storage/avatars/50/abc/medium.jpg",
+					Large:  "http://localhost/storage/avatars/50/abc/large.jpg",
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := newCtx(tt.args.token, tt.args.field, content)
+			if err := s.ProfileAvatarUpdate(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.ProfileAvatarUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_ProfileAvatarDelete(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	newCtx := func(token string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodDelete, "/profile/avatar", nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	token, _ := utils.GenerateToken(50, nil)
+
+	active := func() {
+		mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Eq(usecase.GetUserStatusInput{
+			Id: 50,
+		})).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		mockFunc func()
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "Error token invalid",
+			token:    "abcd",
+			mockFunc: func() {},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/profile/avatar",
+			},
+			wantErr: false,
+		},
+		{
+			name:  "Error when RemoveAvatar",
+			token: token,
+			mockFunc: func() {
+				active()
+				mockUsecase.EXPECT().RemoveAvatar(gomock.Any(), gomock.Any()).Return(usecase.RemoveAvatarOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile/avatar",
+			},
+			wantErr: false,
+		},
+		{
+			name:  "Success",
+			token: token,
+			mockFunc: func() {
+				active()
+				mockUsecase.EXPECT().RemoveAvatar(gomock.Any(), gomock.Eq(usecase.RemoveAvatarInput{
+					Id: 50,
+				})).Return(usecase.RemoveAvatarOutput{}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.BasicSuccessResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Avatar removed",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc()
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := newCtx(tt.token)
+			if err := s.ProfileAvatarDelete(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.ProfileAvatarDelete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_AttributeSchemaGet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	newCtx := func(token string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/attribute-schema", nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		rec := httptest.NewRecorder()
+
+		return e.NewContext(req, rec), rec
+	}
+
+	token, _ := utils.GenerateToken(50, nil)
+
+	active := func() {
+		mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Eq(usecase.GetUserStatusInput{
+			Id: 50,
+		})).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		mockFunc func()
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "Error token invalid",
+			token:    "abcd",
+			mockFunc: func() {},
+			respFunc: problemResponse,
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/attribute-schema",
+			},
+			wantErr: false,
+		},
+		{
+			name:  "Error when GetAttributeSchema",
+			token: token,
+			mockFunc: func() {
+				active()
+				mockUsecase.EXPECT().GetAttributeSchema(gomock.Any(), gomock.Any()).Return(usecase.GetAttributeSchemaOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/attribute-schema",
+			},
+			wantErr: false,
+		},
+		{
+			name:  "Success",
+			token: token,
+			mockFunc: func() {
+				active()
+				mockUsecase.EXPECT().GetAttributeSchema(gomock.Any(), gomock.Any()).Return(usecase.GetAttributeSchemaOutput{
+					Schema: map[string]interface{}{
+						"type":                 "object",
+						"additionalProperties": false,
+					},
+				}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				return strings.TrimSpace(rec.Body.String())
+			},
+			wantCode: http.StatusOK,
+			wantResp: `{"schema":{"additionalProperties":false,"type":"object"}}`,
+			wantErr:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc()
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := newCtx(tt.token)
+			if err := s.AttributeSchemaGet(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.AttributeSchemaGet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_AdminAttributeSchemaUpdate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	newCtx := func(body string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/attribute-schema", strings.NewReader(body))
+		req.Header.Add("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		ctx := e.NewContext(req, rec)
+		ctx.Set(tokenClaimsContextKey, utils.TokenClaims{Id: 1, Roles: []string{"admin"}})
+
+		return ctx, rec
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		mockFunc func()
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error when SetAttributeSchema",
+			body: `{"schema": {"type": "object"}}`,
+			mockFunc: func() {
+				mockUsecase.EXPECT().SetAttributeSchema(gomock.Any(), gomock.Any()).Return(usecase.SetAttributeSchemaOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/admin/attribute-schema",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error schema invalid",
+			body: `{"schema": {"type": "string"}}`,
+			mockFunc: func() {
+				mockUsecase.EXPECT().SetAttributeSchema(gomock.Any(), gomock.Eq(usecase.SetAttributeSchemaInput{
+					Schema:    map[string]interface{}{"type": "string"},
+					UpdatedBy: 1,
+				})).Return(usecase.SetAttributeSchemaOutput{
+					IsInvalid: true,
+					Reason:    `the schema must be of type "object"`,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/admin/attribute-schema",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "schema",
+						Code:    generated.ATTRIBUTESCHEMAINVALID,
+						Message: `must be a JSON Schema of an object: the schema must be of type "object"`,
+						Params:  &map[string]interface{}{"reason": `the schema must be of type "object"`},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			body: `{"schema": {"type": "object", "properties": {"employee_id": {"type": "string", "readOnly": true}}}}`,
+			mockFunc: func() {
+				mockUsecase.EXPECT().SetAttributeSchema(gomock.Any(), gomock.Eq(usecase.SetAttributeSchemaInput{
+					Schema: map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"employee_id": map[string]interface{}{"type": "string", "readOnly": true},
+						},
+					},
+					UpdatedBy: 1,
+				})).Return(usecase.SetAttributeSchemaOutput{}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.BasicSuccessResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Attribute schema updated",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc()
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := newCtx(tt.body)
+			if err := s.AdminAttributeSchemaUpdate(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Server.AdminAttributeSchemaUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
+			assert.Equal(t, tt.wantResp, resp)
+		})
+	}
+}
+
+func TestServer_AdminUserAttributesUpdate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	newCtx := func(id, body string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/users/"+id+"/attributes", strings.NewReader(body))
+		req.Header.Add("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		ctx := e.NewContext(req, rec)
+		ctx.Set(tokenClaimsContextKey, utils.TokenClaims{Id: 1, Roles: []string{"support"}})
+
+		return ctx, rec
+	}
+
+	type args struct {
+		id   string
+		body string
+	}
+
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		respFunc func(*httptest.ResponseRecorder) interface{}
+		wantCode int
+		wantResp interface{}
+		wantErr  bool
+	}{
+		{
+			name: "Error id not a number",
+			args: args{
+				id:   "abc",
+				body: `{"attributes": {}}`,
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/user-not-found",
+				Title:    "User not found",
+				Status:   http.StatusNotFound,
+				Detail:   "There is no user with this id",
+				Instance: "/admin/users/abc/attributes",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error attributes not an object",
+			args: args{
+				id:   "2",
+				body: `{"attributes": "E-1"}`,
+			},
+			mockFunc: func(a args) {},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/admin/users/2/attributes",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "attributes",
+						Code:    generated.ATTRIBUTESNOTOBJECT,
+						Message: "must be a JSON object",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when SetUserAttributes",
+			args: args{
+				id:   "2",
+				body: `{"attributes": {}}`,
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().SetUserAttributes(gomock.Any(), gomock.Any()).Return(usecase.SetUserAttributesOutput{}, errors.New("test"))
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/admin/users/2/attributes",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error user not found",
+			args: args{
+				id:   "2",
+				body: `{"attributes": {}}`,
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().SetUserAttributes(gomock.Any(), gomock.Any()).Return(usecase.SetUserAttributesOutput{
+					IsNotFound: true,
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusNotFound,
+			wantResp: generated.Problem{
+				Type:     "/problems/user-not-found",
+				Title:    "User not found",
+				Status:   http.StatusNotFound,
+				Detail:   "There is no user with this id",
+				Instance: "/admin/users/2/attributes",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error attributes invalid",
+			args: args{
+				id:   "2",
+				body: `{"attributes": {"shoe_size": "big"}}`,
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().SetUserAttributes(gomock.Any(), gomock.Any()).Return(usecase.SetUserAttributesOutput{
+					AttributeErrors: []usecase.AttributeError{
+						{Path: []string{"shoe_size"}, Reason: "value must be an integer"},
+					},
+				}, nil)
+			},
+			respFunc: problemResponse,
+			wantCode: http.StatusBadRequest,
+			wantResp: generated.Problem{
+				Type:     "/problems/validation-error",
+				Title:    "Invalid request",
+				Status:   http.StatusBadRequest,
+				Detail:   "One or more fields are invalid, see errors",
+				Instance: "/admin/users/2/attributes",
+				Errors: &[]generated.ValidationError{
+					{
+						Field:   "attributes.shoe_size",
+						Code:    generated.ATTRIBUTEINVALID,
+						Message: "does not match the attribute schema: value must be an integer",
+						Params:  &map[string]interface{}{"reason": "value must be an integer"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			args: args{
+				id:   "2",
+				body: `{"attributes": {"employee_id": "E-2"}}`,
+			},
+			mockFunc: func(a args) {
+				mockUsecase.EXPECT().SetUserAttributes(gomock.Any(), gomock.Eq(usecase.SetUserAttributesInput{
+					Id: 2,
+					Attributes: map[string]interface{}{
+						"employee_id": "E-2",
+					},
+					UpdatedBy: 1,
+				})).Return(usecase.SetUserAttributesOutput{}, nil)
+			},
+			respFunc: func(rec *httptest.ResponseRecorder) interface{} {
+				var resp generated.BasicSuccessResponse
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+
+				return resp
+			},
+			wantCode: http.StatusOK,
+			wantResp: generated.BasicSuccessResponse{
+				Message: "Attributes updated",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			ctx, rec := newCtx(tt.args.id, tt.args.body)
+			if err := s.AdminUserAttributesUpdate(ctx, tt.args.id); (err != nil) != tt.wantErr {
+				t.Errorf("Server.AdminUserAttributesUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			}
+
+			resp := tt.respFunc(rec)
 			assert.Equal(t, tt.wantResp, resp)
 		})
 	}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/utils"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	MIME_APPLICATION_PROBLEM_JSON = "application/problem+json"
+
+	// PROBLEM_TYPE_PREFIX is followed by the kebab cased message key of the
+	// problem, e.g. /problems/phone-number-not-found
+	PROBLEM_TYPE_PREFIX = "/problems/"
+	PROBLEM_TYPE_BLANK  = "about:blank"
+
+	languageContextKey = "language"
+)
+
+// Problem is an error written by HandleError as an RFC 7807 problem. Key is
+// the message catalog key of the title, the detail uses Key + "_DETAIL".
+type Problem struct {
+	Status      int
+	Key         string
+	FieldErrors map[string]error
+	Token       string
+}
+
+func newProblem(status int, key string) *Problem {
+	return &Problem{
+		Status: status,
+		Key:    key,
+	}
+}
+
+func newValidationProblem(errs map[string]error) *Problem {
+	return &Problem{
+		Status:      http.StatusBadRequest,
+		Key:         MESSAGE_VALIDATION_ERROR,
+		FieldErrors: errs,
+	}
+}
+
+func (p *Problem) Error() string {
+	return p.Key
+}
+
+// HandleError is the only place error responses are written. It is used as
+// the echo HTTPErrorHandler, so routing and binding errors of echo come out
+// as problems as well. Problems are written as is, echo errors as about:blank
+// problems and anything else as an internal server error.
+func (s *Server) HandleError(err error, ctx echo.Context) {
+	if ctx.Response().Committed {
+		return
+	}
+
+	resp := newProblemResponse(ctx, err)
+
+	ctx.Response().Header().Set(echo.HeaderContentType, MIME_APPLICATION_PROBLEM_JSON)
+	if errWrite := ctx.JSON(resp.Status, resp); errWrite != nil {
+		log.Println("[ERROR][HandleError] error when writing problem", errWrite)
+	}
+}
+
+// respondError writes err through HandleError, handlers return its result so
+// that the error is not handled a second time by echo.
+func (s *Server) respondError(ctx echo.Context, err error) error {
+	s.HandleError(err, ctx)
+	return nil
+}
+
+func newProblemResponse(ctx echo.Context, err error) generated.Problem {
+	var (
+		problem   *Problem
+		httpError *echo.HTTPError
+		lang      = contextLanguage(ctx)
+		instance  = ctx.Request().URL.Path
+	)
+
+	if errors.As(err, &httpError) {
+		resp := generated.Problem{
+			Type:     PROBLEM_TYPE_BLANK,
+			Title:    http.StatusText(httpError.Code),
+			Status:   httpError.Code,
+			Detail:   http.StatusText(httpError.Code),
+			Instance: instance,
+		}
+		if message, ok := httpError.Message.(string); ok {
+			resp.Detail = message
+		}
+		return resp
+	}
+
+	if !errors.As(err, &problem) {
+		problem = newProblem(http.StatusInternalServerError, MESSAGE_INTERNAL_SERVER_ERROR)
+	}
+
+	resp := generated.Problem{
+		Type:     PROBLEM_TYPE_PREFIX + strings.ReplaceAll(strings.ToLower(problem.Key), "_", "-"),
+		Title:    utils.Localize(lang, problem.Key, nil),
+		Status:   problem.Status,
+		Detail:   utils.Localize(lang, problem.Key+"_DETAIL", nil),
+		Instance: instance,
+	}
+
+	if len(problem.FieldErrors) > 0 {
+		fieldErrors := newValidationErrors(lang, problem.FieldErrors)
+		resp.Errors = &fieldErrors
+	}
+
+	if problem.Token != "" {
+		resp.Token = &problem.Token
+	}
+
+	return resp
+}
+
+// contextLanguage returns the language picked by requestLanguage for this
+// request, or picks one when the handler has not done it yet.
+func contextLanguage(ctx echo.Context) string {
+	if lang, ok := ctx.Get(languageContextKey).(string); ok {
+		return lang
+	}
+	return requestLanguage(ctx, "")
+}
+
+func newValidationErrors(lang string, errs map[string]error) []generated.ValidationError {
+	var (
+		resp []generated.ValidationError
+	)
+
+	for k, v := range errs {
+		if ruleErrs, ok := v.(utils.ValidationErrors); ok {
+			for _, ruleErr := range ruleErrs {
+				resp = append(resp, newValidationError(lang, k, ruleErr))
+			}
+			continue
+		}
+
+		resp = append(resp, newValidationError(lang, k, v))
+	}
+
+	// errs is a map, keep the order of the response stable
+	sort.SliceStable(resp, func(i, j int) bool {
+		return resp[i].Field < resp[j].Field
+	})
+
+	return resp
+}
+
+func newValidationError(lang, field string, err error) generated.ValidationError {
+	var (
+		ruleErr *utils.ValidationError
+	)
+
+	if !errors.As(err, &ruleErr) {
+		// every validator returns a utils.ValidationError, this is a programming error
+		log.Println("[WARN][newValidationError] validation error without code for field", field, err)
+		return generated.ValidationError{
+			Field:   field,
+			Message: err.Error(),
+		}
+	}
+
+	resp := generated.ValidationError{
+		Field:   field,
+		Code:    generated.ValidationErrorCode(ruleErr.Code),
+		Message: ruleErr.LocalizedMessage(lang),
+	}
+
+	if len(ruleErr.Params) > 0 {
+		params := ruleErr.Params
+		resp.Params = &params
+	}
+
+	return resp
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_HandleError(t *testing.T) {
+	type args struct {
+		err error
+		ctx func() (echo.Context, *httptest.ResponseRecorder)
+	}
+	newCtx := func(header string) func() (echo.Context, *httptest.ResponseRecorder) {
+		return func() (echo.Context, *httptest.ResponseRecorder) {
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+			if header != "" {
+				req.Header.Add("Accept-Language", header)
+			}
+			rec := httptest.NewRecorder()
+
+			return e.NewContext(req, rec), rec
+		}
+	}
+	tests := []struct {
+		name     string
+		args     args
+		wantCode int
+		wantResp interface{}
+	}{
+		{
+			name: "problem",
+			args: args{
+				err: newProblem(http.StatusForbidden, MESSAGE_FORBIDDEN),
+				ctx: newCtx(""),
+			},
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/profile",
+			},
+		},
+		{
+			name: "problem in the Accept-Language language",
+			args: args{
+				err: newProblem(http.StatusForbidden, MESSAGE_FORBIDDEN),
+				ctx: newCtx("id"),
+			},
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Akses ditolak",
+				Status:   http.StatusForbidden,
+				Detail:   "Token akses tidak ada, tidak valid, atau sudah kedaluwarsa",
+				Instance: "/profile",
+			},
+		},
+		{
+			name: "unknown error is an internal server error",
+			args: args{
+				err: errors.New("test"),
+				ctx: newCtx(""),
+			},
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/profile",
+			},
+		},
+		{
+			name: "echo error",
+			args: args{
+				err: echo.ErrMethodNotAllowed,
+				ctx: newCtx(""),
+			},
+			wantCode: http.StatusMethodNotAllowed,
+			wantResp: generated.Problem{
+				Type:     "about:blank",
+				Title:    "Method Not Allowed",
+				Status:   http.StatusMethodNotAllowed,
+				Detail:   "Method Not Allowed",
+				Instance: "/profile",
+			},
+		},
+		{
+			name: "echo error with message",
+			args: args{
+				err: echo.NewHTTPError(http.StatusUnsupportedMediaType, "unsupported content type"),
+				ctx: newCtx(""),
+			},
+			wantCode: http.StatusUnsupportedMediaType,
+			wantResp: generated.Problem{
+				Type:     "about:blank",
+				Title:    "Unsupported Media Type",
+				Status:   http.StatusUnsupportedMediaType,
+				Detail:   "unsupported content type",
+				Instance: "/profile",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(NewServerOptions{})
+
+			ctx, rec := tt.args.ctx()
+
+			s.HandleError(tt.args.err, ctx)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+			assert.Equal(t, tt.wantResp, problemResponse(rec))
+		})
+	}
+}
+
+func TestServer_HandleError_committed(t *testing.T) {
+	s := NewServer(NewServerOptions{})
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+	rec := httptest.NewRecorder()
+	ctx := e.NewContext(req, rec)
+
+	ctx.NoContent(http.StatusNoContent)
+	s.HandleError(errors.New("test"), ctx)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/usecase"
+	"github.com/SawitProRecruitment/UserService/utils"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	// PERMISSION_EXTENSION names the permission an operation of api.yml
+	// requires, see NewPermissionRouter
+	PERMISSION_EXTENSION = "x-permission"
+
+	tokenClaimsContextKey = "token_claims"
+)
+
+var pathParameter = regexp.MustCompile(`\{([^}]+)\}`)
+
+// RequirePermission lets a request through when its token belongs to a user
+// with a role that grants permission. The claims of the token are kept for
+// the handler, see contextTokenClaims.
+func (s *Server) RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			claims, err := s.tokenValidityClaims(ctx)
+			if err != nil {
+				return s.respondError(ctx, err)
+			}
+
+			output, err := s.Usecase.HasPermission(ctx.Request().Context(), usecase.HasPermissionInput{
+				Roles:      claims.Roles,
+				Permission: permission,
+			})
+
+			if err != nil {
+				log.Println("[ERROR][RequirePermission] error when HasPermission", err)
+				return s.respondError(ctx, err)
+			}
+
+			if !output.IsAllowed {
+				return s.respondError(ctx, newProblem(http.StatusForbidden, MESSAGE_PERMISSION_DENIED))
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// tokenValidity returns the id of the user of the access token of the
+// request, see checkUserStatus. The error is the problem to respond with. The
+// claims of the token are kept for the handler, see contextTokenClaims.
+func (s *Server) tokenValidity(ctx echo.Context) (int64, error) {
+	claims, err := s.tokenValidityClaims(ctx)
+	return claims.Id, err
+}
+
+// tokenValidityClaims is tokenValidity returning the claims of the token.
+// Every use of an impersonation token is recorded, a request that can not be
+// recorded is refused. The token stops working as soon as the admin acting
+// with it is no longer active.
+func (s *Server) tokenValidityClaims(ctx echo.Context) (utils.TokenClaims, error) {
+	claims, err := utils.TokenValidityClaims(ctx)
+	if err != nil {
+		return utils.TokenClaims{}, newProblem(http.StatusForbidden, MESSAGE_FORBIDDEN)
+	}
+
+	if claims.ActorId != 0 {
+		if err := s.checkUserStatus(ctx, claims.ActorId); err != nil {
+			if _, ok := err.(*Problem); ok {
+				return utils.TokenClaims{}, newProblem(http.StatusForbidden, MESSAGE_FORBIDDEN)
+			}
+			return utils.TokenClaims{}, err
+		}
+	}
+
+	if err := s.checkUserStatus(ctx, claims.Id); err != nil {
+		return utils.TokenClaims{}, err
+	}
+
+	if claims.ActorId != 0 {
+		_, err := s.Usecase.RecordImpersonationUse(ctx.Request().Context(), usecase.RecordImpersonationUseInput{
+			ImpersonationId: claims.ImpersonationId,
+			Method:          ctx.Request().Method,
+			Path:            ctx.Request().URL.Path,
+		})
+
+		if err != nil {
+			log.Println("[ERROR][tokenValidityClaims] error when RecordImpersonationUse", err)
+			return utils.TokenClaims{}, err
+		}
+	}
+
+	ctx.Set(tokenClaimsContextKey, claims)
+
+	return claims, nil
+}
+
+// tokenValidityScoped is tokenValidity for a token of scope.
+func (s *Server) tokenValidityScoped(ctx echo.Context, scope string) (int64, error) {
+	id, err := utils.TokenValidityScoped(ctx, scope)
+	if err != nil {
+		return 0, newProblem(http.StatusForbidden, MESSAGE_FORBIDDEN)
+	}
+
+	if err := s.checkUserStatus(ctx, id); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// checkUserStatus lets only active users use their tokens. The status is
+// looked up on every request, so that a suspension or deactivation takes
+// effect before the tokens of the user expire.
+func (s *Server) checkUserStatus(ctx echo.Context, id int64) error {
+	output, err := s.Usecase.GetUserStatus(ctx.Request().Context(), usecase.GetUserStatusInput{
+		Id: id,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][checkUserStatus] error when GetUserStatus", err)
+		return err
+	}
+
+	switch output.Status {
+	case usecase.USER_STATUS_ACTIVE:
+		return nil
+	case usecase.USER_STATUS_SUSPENDED:
+		return newProblem(http.StatusForbidden, MESSAGE_ACCOUNT_SUSPENDED)
+	default:
+		return newProblem(http.StatusForbidden, MESSAGE_FORBIDDEN)
+	}
+}
+
+// contextTokenClaims returns the claims of the token tokenValidity or
+// RequirePermission accepted for this request.
+func contextTokenClaims(ctx echo.Context) (utils.TokenClaims, bool) {
+	claims, ok := ctx.Get(tokenClaimsContextKey).(utils.TokenClaims)
+	return claims, ok
+}
+
+// isImpersonation reports whether the request is made by support staff
+// acting as the user, see utils.GenerateImpersonationToken.
+func isImpersonation(ctx echo.Context) bool {
+	claims, _ := contextTokenClaims(ctx)
+	return claims.ActorId != 0
+}
+
+// permissionRouter puts RequirePermission in front of the routes that
+// require a permission, by method and path as registered, e.g.
+// "GET /admin/users/:id".
+type permissionRouter struct {
+	router      generated.EchoRouter
+	server      *Server
+	permissions map[string]string
+}
+
+// NewPermissionRouter wraps router so that generated.RegisterHandlers guards
+// every operation of api.yml that has an x-permission with RequirePermission.
+func NewPermissionRouter(router generated.EchoRouter, s *Server) (generated.EchoRouter, error) {
+	swagger, err := generated.GetSwagger()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	permissions := make(map[string]string)
+	for path, pathItem := range swagger.Paths {
+		for method, operation := range pathItem.Operations() {
+			permission, ok := operation.Extensions[PERMISSION_EXTENSION].(string)
+			if !ok || permission == "" {
+				continue
+			}
+
+			permissions[method+" "+pathParameter.ReplaceAllString(path, ":$1")] = permission
+		}
+	}
+
+	return newPermissionRouter(router, s, permissions), nil
+}
+
+func newPermissionRouter(router generated.EchoRouter, s *Server, permissions map[string]string) *permissionRouter {
+	return &permissionRouter{
+		router:      router,
+		server:      s,
+		permissions: permissions,
+	}
+}
+
+func (r *permissionRouter) middlewares(method, path string, m []echo.MiddlewareFunc) []echo.MiddlewareFunc {
+	permission, ok := r.permissions[strings.ToUpper(method)+" "+path]
+	if !ok {
+		return m
+	}
+
+	return append([]echo.MiddlewareFunc{r.server.RequirePermission(permission)}, m...)
+}
+
+func (r *permissionRouter) CONNECT(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.router.CONNECT(path, h, r.middlewares(http.MethodConnect, path, m)...)
+}
+
+func (r *permissionRouter) DELETE(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.router.DELETE(path, h, r.middlewares(http.MethodDelete, path, m)...)
+}
+
+func (r *permissionRouter) GET(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.router.GET(path, h, r.middlewares(http.MethodGet, path, m)...)
+}
+
+func (r *permissionRouter) HEAD(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.router.HEAD(path, h, r.middlewares(http.MethodHead, path, m)...)
+}
+
+func (r *permissionRouter) OPTIONS(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.router.OPTIONS(path, h, r.middlewares(http.MethodOptions, path, m)...)
+}
+
+func (r *permissionRouter) PATCH(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.router.PATCH(path, h, r.middlewares(http.MethodPatch, path, m)...)
+}
+
+func (r *permissionRouter) POST(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.router.POST(path, h, r.middlewares(http.MethodPost, path, m)...)
+}
+
+func (r *permissionRouter) PUT(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.router.PUT(path, h, r.middlewares(http.MethodPut, path, m)...)
+}
+
+func (r *permissionRouter) TRACE(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.router.TRACE(path, h, r.middlewares(http.MethodTrace, path, m)...)
+}
@@ -0,0 +1,395 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/usecase"
+	"github.com/SawitProRecruitment/UserService/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_RequirePermission(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	var (
+		adminToken, _   = utils.GenerateToken(50, []string{"admin"})
+		noRoleToken, _  = utils.GenerateToken(51, nil)
+		expiredToken, _ = utils.GenerateScopedToken(50, utils.TOKEN_SCOPE_PASSWORD_EXPIRED, time.Minute)
+		actingToken, _  = utils.GenerateImpersonationToken(51, 50, 9, time.Now().Add(time.Minute))
+	)
+
+	tests := []struct {
+		name       string
+		token      string
+		mockFunc   func()
+		wantCode   int
+		wantResp   interface{}
+		wantClaims utils.TokenClaims
+	}{
+		{
+			name:     "Error token missing",
+			token:    "",
+			mockFunc: func() {},
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/admin/ping",
+			},
+		},
+		{
+			name:     "Error scoped token",
+			token:    expiredToken,
+			mockFunc: func() {},
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/admin/ping",
+			},
+		},
+		{
+			name:  "Error when GetUserStatus",
+			token: adminToken,
+			mockFunc: func() {
+				mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{}, errors.New("test"))
+			},
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/admin/ping",
+			},
+		},
+		{
+			name:  "Error user not found",
+			token: adminToken,
+			mockFunc: func() {
+				mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{IsNotFound: true}, nil)
+			},
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/admin/ping",
+			},
+		},
+		{
+			name:  "Error account suspended",
+			token: adminToken,
+			mockFunc: func() {
+				mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Eq(usecase.GetUserStatusInput{
+					Id: 50,
+				})).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_SUSPENDED}, nil)
+			},
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/account-suspended",
+				Title:    "Account suspended",
+				Status:   http.StatusForbidden,
+				Detail:   "Your account has been suspended, please contact support",
+				Instance: "/admin/ping",
+			},
+		},
+		{
+			name:  "Error account deactivated",
+			token: adminToken,
+			mockFunc: func() {
+				mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_DEACTIVATED}, nil)
+			},
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/admin/ping",
+			},
+		},
+		{
+			name:  "Error impersonating admin no longer active",
+			token: actingToken,
+			mockFunc: func() {
+				mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Eq(usecase.GetUserStatusInput{
+					Id: 50,
+				})).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_SUSPENDED}, nil)
+			},
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/forbidden",
+				Title:    "Forbidden",
+				Status:   http.StatusForbidden,
+				Detail:   "The access token is missing, invalid or expired",
+				Instance: "/admin/ping",
+			},
+		},
+		{
+			name:  "Error when RecordImpersonationUse",
+			token: actingToken,
+			mockFunc: func() {
+				mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil).Times(2)
+				mockUsecase.EXPECT().RecordImpersonationUse(gomock.Any(), gomock.Any()).Return(usecase.RecordImpersonationUseOutput{}, errors.New("test"))
+			},
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/admin/ping",
+			},
+		},
+		{
+			name:  "Error impersonation token has no roles",
+			token: actingToken,
+			mockFunc: func() {
+				mockUsecase.EXPECT().RecordImpersonationUse(gomock.Any(), gomock.Eq(usecase.RecordImpersonationUseInput{
+					ImpersonationId: 9,
+					Method:          http.MethodGet,
+					Path:            "/admin/ping",
+				})).Return(usecase.RecordImpersonationUseOutput{}, nil)
+				mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Eq(usecase.GetUserStatusInput{
+					Id: 50,
+				})).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil)
+				mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Eq(usecase.GetUserStatusInput{
+					Id: 51,
+				})).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil)
+				mockUsecase.EXPECT().HasPermission(gomock.Any(), gomock.Eq(usecase.HasPermissionInput{
+					Permission: "users:read",
+				})).Return(usecase.HasPermissionOutput{}, nil)
+			},
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/permission-denied",
+				Title:    "Permission denied",
+				Status:   http.StatusForbidden,
+				Detail:   "Your roles do not allow this request",
+				Instance: "/admin/ping",
+			},
+		},
+		{
+			name:  "Error when HasPermission",
+			token: adminToken,
+			mockFunc: func() {
+				mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil)
+				mockUsecase.EXPECT().HasPermission(gomock.Any(), gomock.Any()).Return(usecase.HasPermissionOutput{}, errors.New("test"))
+			},
+			wantCode: http.StatusInternalServerError,
+			wantResp: generated.Problem{
+				Type:     "/problems/internal-server-error",
+				Title:    "Internal server error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "The request could not be processed, please try again later",
+				Instance: "/admin/ping",
+			},
+		},
+		{
+			name:  "Error permission denied",
+			token: noRoleToken,
+			mockFunc: func() {
+				mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil)
+				mockUsecase.EXPECT().HasPermission(gomock.Any(), gomock.Eq(usecase.HasPermissionInput{
+					Permission: "users:read",
+				})).Return(usecase.HasPermissionOutput{}, nil)
+			},
+			wantCode: http.StatusForbidden,
+			wantResp: generated.Problem{
+				Type:     "/problems/permission-denied",
+				Title:    "Permission denied",
+				Status:   http.StatusForbidden,
+				Detail:   "Your roles do not allow this request",
+				Instance: "/admin/ping",
+			},
+		},
+		{
+			name:  "Success",
+			token: adminToken,
+			mockFunc: func() {
+				mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil)
+				mockUsecase.EXPECT().HasPermission(gomock.Any(), gomock.Eq(usecase.HasPermissionInput{
+					Roles:      []string{"admin"},
+					Permission: "users:read",
+				})).Return(usecase.HasPermissionOutput{
+					IsAllowed: true,
+				}, nil)
+			},
+			wantCode: http.StatusNoContent,
+			wantClaims: utils.TokenClaims{
+				Id:    50,
+				Roles: []string{"admin"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc()
+			s := NewServer(NewServerOptions{
+				Usecase: mockUsecase,
+			})
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/admin/ping", nil)
+			if tt.token != "" {
+				req.Header.Add("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+
+			var gotClaims utils.TokenClaims
+			next := func(ctx echo.Context) error {
+				gotClaims, _ = contextTokenClaims(ctx)
+				return ctx.NoContent(http.StatusNoContent)
+			}
+
+			if err := s.RequirePermission("users:read")(next)(ctx); err != nil {
+				t.Errorf("Server.RequirePermission() error = %v", err)
+			}
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode >= http.StatusBadRequest {
+				assert.Equal(t, MIME_APPLICATION_PROBLEM_JSON, rec.Header().Get(echo.HeaderContentType))
+				assert.Equal(t, tt.wantResp, problemResponse(rec))
+			}
+			// the token was issued at the start of the test
+			if !gotClaims.IssuedAt.IsZero() {
+				assert.WithinDuration(t, time.Now(), gotClaims.IssuedAt, time.Minute)
+				gotClaims.IssuedAt = time.Time{}
+			}
+			assert.Equal(t, tt.wantClaims, gotClaims)
+		})
+	}
+}
+
+func TestPermissionRouter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUsecase := usecase.NewMockUsecaseInterface(ctrl)
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	s := NewServer(NewServerOptions{
+		Usecase: mockUsecase,
+	})
+
+	e := echo.New()
+	e.HTTPErrorHandler = s.HandleError
+
+	router := newPermissionRouter(e, s, map[string]string{
+		"GET /admin/users/:id":    "users:read",
+		"DELETE /admin/users/:id": "users:write",
+	})
+
+	ok := func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusNoContent)
+	}
+	router.GET("/admin/users/:id", ok)
+	router.DELETE("/admin/users/:id", ok)
+	router.GET("/profile", ok)
+
+	token, _ := utils.GenerateToken(50, []string{"support"})
+
+	mockUsecase.EXPECT().GetUserStatus(gomock.Any(), gomock.Any()).Return(usecase.GetUserStatusOutput{Status: usecase.USER_STATUS_ACTIVE}, nil).AnyTimes()
+	mockUsecase.EXPECT().HasPermission(gomock.Any(), gomock.Eq(usecase.HasPermissionInput{
+		Roles:      []string{"support"},
+		Permission: "users:read",
+	})).Return(usecase.HasPermissionOutput{IsAllowed: true}, nil)
+	mockUsecase.EXPECT().HasPermission(gomock.Any(), gomock.Eq(usecase.HasPermissionInput{
+		Roles:      []string{"support"},
+		Permission: "users:write",
+	})).Return(usecase.HasPermissionOutput{}, nil)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode int
+	}{
+		{
+			name:     "route with a permission that is granted",
+			method:   http.MethodGet,
+			path:     "/admin/users/7",
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "route with a permission that is not granted",
+			method:   http.MethodDelete,
+			path:     "/admin/users/7",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "route without a permission",
+			method:   http.MethodGet,
+			path:     "/profile",
+			wantCode: http.StatusNoContent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Add("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+		})
+	}
+}
+
+func TestNewPermissionRouter(t *testing.T) {
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	s := NewServer(NewServerOptions{})
+
+	e := echo.New()
+	e.HTTPErrorHandler = s.HandleError
+
+	router, err := NewPermissionRouter(e, s)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, router)
+
+	generated.RegisterHandlers(router, s)
+
+	// the operations with an x-permission in api.yml are guarded
+	for _, route := range []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/admin/users"},
+		{http.MethodGet, "/admin/users/7"},
+		{http.MethodPut, "/admin/users/7/status"},
+		{http.MethodGet, "/admin/users/7/status-changes"},
+		{http.MethodPost, "/admin/users/7/impersonation"},
+	} {
+		req := httptest.NewRequest(route.method, route.path, nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code, route.method+" "+route.path)
+	}
+}
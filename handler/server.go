@@ -5,15 +5,20 @@ import (
 )
 
 type Server struct {
-	Usecase usecase.UsecaseInterface
+	Usecase                     usecase.UsecaseInterface
+	RegistrationAntiEnumeration bool
 }
 
 type NewServerOptions struct {
 	Usecase usecase.UsecaseInterface
+	// RegistrationAntiEnumeration answers every valid registration with 202
+	// and without the id, so that it does not reveal registered phone numbers.
+	RegistrationAntiEnumeration bool
 }
 
 func NewServer(opts NewServerOptions) *Server {
 	return &Server{
-		Usecase: opts.Usecase,
+		Usecase:                     opts.Usecase,
+		RegistrationAntiEnumeration: opts.RegistrationAntiEnumeration,
 	}
 }
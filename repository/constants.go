@@ -3,3 +3,40 @@ package repository
 import "github.com/lib/pq"
 
 const KEY_CONFLICT pq.ErrorCode = "23505"
+
+// EMAIL_UNIQUE_INDEX tells a conflict on an email identity apart from one on
+// any other identity, see database.sql
+const EMAIL_UNIQUE_INDEX = "identities_email"
+
+// identities.type of the identifiers users manage themselves, other types are
+// external login providers
+const (
+	IDENTITY_TYPE_PHONE = "phone"
+	IDENTITY_TYPE_EMAIL = "email"
+)
+
+// users.status, see database.sql
+const (
+	USER_STATUS_PENDING     = "pending"
+	USER_STATUS_ACTIVE      = "active"
+	USER_STATUS_SUSPENDED   = "suspended"
+	USER_STATUS_DEACTIVATED = "deactivated"
+	USER_STATUS_DELETED     = "deleted"
+)
+
+var USER_STATUSES = []string{USER_STATUS_PENDING, USER_STATUS_ACTIVE, USER_STATUS_SUSPENDED, USER_STATUS_DEACTIVATED, USER_STATUS_DELETED}
+
+// data_exports.status, see database.sql
+const (
+	DATA_EXPORT_STATUS_PENDING = "pending"
+	DATA_EXPORT_STATUS_READY   = "ready"
+	DATA_EXPORT_STATUS_FAILED  = "failed"
+)
+
+// orders of SearchUsers, a leading "-" sorts descending
+const (
+	USER_SORT_CREATED_AT      = "created_at"
+	USER_SORT_CREATED_AT_DESC = "-created_at"
+	USER_SORT_FULL_NAME       = "full_name"
+	USER_SORT_FULL_NAME_DESC  = "-full_name"
+)
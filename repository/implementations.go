@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/lib/pq"
 	"github.com/pkg/errors"
@@ -13,11 +14,12 @@ func (r *Repository) InsertNewUser(ctx context.Context, input InsertNewUserInput
 		newId  int64
 	)
 
-	err := r.Db.QueryRowContext(ctx, InsertNewUserQuery, input.PhoneNumber, input.FullName, input.Password).Scan(&newId)
+	err := r.Db.QueryRowContext(ctx, InsertNewUserQuery, input.PhoneNumber, input.FullName, input.Password, input.Email).Scan(&newId)
 	if err != nil {
-		if pgerr, ok := err.(*pq.Error); ok && pgerr.Code == "23505" {
+		if pgerr, ok := err.(*pq.Error); ok && pgerr.Code == KEY_CONFLICT {
 			return InsertNewUserOutput{
-				IsPhoneNumberExists: true,
+				IsPhoneNumberExists: pgerr.Constraint != EMAIL_UNIQUE_INDEX,
+				IsEmailExists:       pgerr.Constraint == EMAIL_UNIQUE_INDEX,
 			}, nil
 		}
 		return output, errors.WithStack(err)
@@ -30,19 +32,43 @@ func (r *Repository) InsertNewUser(ctx context.Context, input InsertNewUserInput
 
 func (r *Repository) UpdateUserData(ctx context.Context, input UpdateUserDataInput) (UpdateUserDataOutput, error) {
 	// TODO: add redis here
-	_, err := r.Db.ExecContext(ctx, UpdateUserDataQuery, input.Id, input.PhoneNumber, input.FullName, input.Id)
+	_, err := r.Db.ExecContext(ctx, UpdateUserDataQuery, input.Id, input.PhoneNumber, input.FullName, input.Id, input.Language, input.Email, jsonParam(input.Attributes))
 	if err != nil {
 		if pgerr, ok := err.(*pq.Error); ok && pgerr.Code == KEY_CONFLICT {
 			return UpdateUserDataOutput{
-				IsPhoneNumberExists: true,
+				IsPhoneNumberExists: pgerr.Constraint != EMAIL_UNIQUE_INDEX,
+				IsEmailExists:       pgerr.Constraint == EMAIL_UNIQUE_INDEX,
 			}, nil
 		}
 	}
 	return UpdateUserDataOutput{}, errors.WithStack(err)
 }
 
-func (r *Repository) GetPasswordByPhoneNumber(ctx context.Context, input GetPasswordByPhoneNumberInput) (output GetPasswordByPhoneNumberOutput, err error) {
-	err = r.Db.QueryRowContext(ctx, GetPasswordByPhoneNumberQuery, input.PhoneNumber).Scan(&output.Id, &output.Password, &output.PhoneNumber)
+func (r *Repository) PatchUserData(ctx context.Context, input PatchUserDataInput) (PatchUserDataOutput, error) {
+	var output PatchUserDataOutput
+	err := r.Db.QueryRowContext(ctx, PatchUserDataQuery, input.Id, input.FullName, input.Language, input.PhoneNumber, input.Email, jsonParam(input.Attributes), jsonParam(input.ExpectedAttributes)).Scan(&output.PreviousEmail)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return PatchUserDataOutput{
+				IsNotUpdated: true,
+			}, nil
+		}
+
+		if pgerr, ok := err.(*pq.Error); ok && pgerr.Code == KEY_CONFLICT {
+			return PatchUserDataOutput{
+				IsPhoneNumberExists: pgerr.Constraint != EMAIL_UNIQUE_INDEX,
+				IsEmailExists:       pgerr.Constraint == EMAIL_UNIQUE_INDEX,
+			}, nil
+		}
+
+		return PatchUserDataOutput{}, errors.WithStack(err)
+	}
+
+	return output, nil
+}
+
+func (r *Repository) GetPasswordByIdentity(ctx context.Context, input GetPasswordByIdentityInput) (output GetPasswordByIdentityOutput, err error) {
+	err = r.Db.QueryRowContext(ctx, GetPasswordByIdentityQuery, input.Type, input.Identifier).Scan(&output.Id, &output.Password, &output.PhoneNumber, &output.PasswordChangedAt, &output.UserGroup)
 	err = errors.WithStack(err)
 	return
 }
@@ -56,7 +82,505 @@ func (r *Repository) UpdateTotalLoginById(ctx context.Context, input UpdateTotal
 
 func (r *Repository) GetUserDataById(ctx context.Context, input GetUserDataByIdInput) (output GetUserDataByIdOutput, err error) {
 	// TODO: add redis here
-	err = r.Db.QueryRowContext(ctx, GetUserDataByIdQuery, input.Id).Scan(&output.Id, &output.FullName, &output.PhoneNumber)
+	err = r.Db.QueryRowContext(ctx, GetUserDataByIdQuery, input.Id).Scan(&output.Id, &output.FullName, &output.PhoneNumber, &output.Language, &output.Email, &output.EmailVerified, &output.AvatarKey, &output.Attributes)
+	err = errors.WithStack(err)
+	return
+}
+
+func (r *Repository) UpdatePasswordById(ctx context.Context, input UpdatePasswordByIdInput) (err error) {
+	_, err = r.Db.ExecContext(ctx, UpdatePasswordByIdQuery, input.Id, input.Password)
+
+	err = errors.WithStack(err)
+	return err
+}
+
+func (r *Repository) GetPasswordById(ctx context.Context, input GetPasswordByIdInput) (output GetPasswordByIdOutput, err error) {
+	err = r.Db.QueryRowContext(ctx, GetPasswordByIdQuery, input.Id).Scan(&output.Id, &output.Password)
+	err = errors.WithStack(err)
+	return
+}
+
+func (r *Repository) SetPasswordById(ctx context.Context, input SetPasswordByIdInput) (err error) {
+	_, err = r.Db.ExecContext(ctx, SetPasswordByIdQuery, input.Id, input.Password)
+
+	err = errors.WithStack(err)
+	return err
+}
+
+func (r *Repository) GetPasswordHistoryByUserId(ctx context.Context, input GetPasswordHistoryByUserIdInput) (output GetPasswordHistoryByUserIdOutput, err error) {
+	rows, err := r.Db.QueryContext(ctx, GetPasswordHistoryByUserIdQuery, input.UserId, input.Limit)
+	if err != nil {
+		return output, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var password string
+		if err = rows.Scan(&password); err != nil {
+			return GetPasswordHistoryByUserIdOutput{}, errors.WithStack(err)
+		}
+		output.Passwords = append(output.Passwords, password)
+	}
+
+	err = errors.WithStack(rows.Err())
+	return
+}
+
+func (r *Repository) DeleteOldPasswordHistory(ctx context.Context, input DeleteOldPasswordHistoryInput) (err error) {
+	_, err = r.Db.ExecContext(ctx, DeleteOldPasswordHistoryQuery, input.UserId, input.Keep)
+
+	err = errors.WithStack(err)
+	return err
+}
+
+func (r *Repository) UpsertEmailVerification(ctx context.Context, input UpsertEmailVerificationInput) (err error) {
+	_, err = r.Db.ExecContext(ctx, UpsertEmailVerificationQuery, input.UserId, input.Email, input.TokenHash, input.CodeHash, input.ExpiresAt)
+
+	err = errors.WithStack(err)
+	return err
+}
+
+func (r *Repository) GetEmailVerificationByTokenHash(ctx context.Context, input GetEmailVerificationByTokenHashInput) (output GetEmailVerificationOutput, err error) {
+	err = r.Db.QueryRowContext(ctx, GetEmailVerificationByTokenHashQuery, input.TokenHash).Scan(&output.Id, &output.UserId, &output.Email, &output.CodeHash, &output.Attempts, &output.ExpiresAt)
+	err = errors.WithStack(err)
+	return
+}
+
+func (r *Repository) GetEmailVerificationByUserId(ctx context.Context, input GetEmailVerificationByUserIdInput) (output GetEmailVerificationOutput, err error) {
+	err = r.Db.QueryRowContext(ctx, GetEmailVerificationByUserIdQuery, input.UserId).Scan(&output.Id, &output.UserId, &output.Email, &output.CodeHash, &output.Attempts, &output.ExpiresAt)
+	err = errors.WithStack(err)
+	return
+}
+
+func (r *Repository) IncrementEmailVerificationAttempts(ctx context.Context, input IncrementEmailVerificationAttemptsInput) (err error) {
+	_, err = r.Db.ExecContext(ctx, IncrementEmailVerificationAttemptsQuery, input.Id)
+
+	err = errors.WithStack(err)
+	return err
+}
+
+func (r *Repository) VerifyEmail(ctx context.Context, input VerifyEmailInput) (output VerifyEmailOutput, err error) {
+	var verified int64
+
+	err = r.Db.QueryRowContext(ctx, VerifyEmailQuery, input.UserId, input.Email).Scan(&verified)
+	if err != nil {
+		return output, errors.WithStack(err)
+	}
+
+	output.IsEmailChanged = verified == 0
+	return
+}
+
+func (r *Repository) InsertIdentity(ctx context.Context, input InsertIdentityInput) (InsertIdentityOutput, error) {
+	var output InsertIdentityOutput
+
+	err := r.Db.QueryRowContext(ctx, InsertIdentityQuery, input.UserId, input.Type, input.Identifier, input.IsVerified).Scan(&output.Id)
+	if err != nil {
+		if pgerr, ok := err.(*pq.Error); ok && pgerr.Code == KEY_CONFLICT {
+			return InsertIdentityOutput{
+				IsIdentifierExists: true,
+			}, nil
+		}
+		return InsertIdentityOutput{}, errors.WithStack(err)
+	}
+
+	return output, nil
+}
+
+func (r *Repository) GetIdentitiesByUserId(ctx context.Context, input GetIdentitiesByUserIdInput) (output GetIdentitiesByUserIdOutput, err error) {
+	rows, err := r.Db.QueryContext(ctx, GetIdentitiesByUserIdQuery, input.UserId)
+	if err != nil {
+		return output, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			identity   Identity
+			verifiedAt sql.NullTime
+		)
+		if err = rows.Scan(&identity.Id, &identity.Type, &identity.Identifier, &identity.IsPrimary, &verifiedAt); err != nil {
+			return GetIdentitiesByUserIdOutput{}, errors.WithStack(err)
+		}
+		if verifiedAt.Valid {
+			identity.VerifiedAt = &verifiedAt.Time
+		}
+		output.Identities = append(output.Identities, identity)
+	}
+
+	err = errors.WithStack(rows.Err())
+	return
+}
+
+func (r *Repository) DeleteIdentity(ctx context.Context, input DeleteIdentityInput) (output DeleteIdentityOutput, err error) {
+	result, err := r.Db.ExecContext(ctx, DeleteIdentityQuery, input.Id, input.UserId)
+	if err != nil {
+		return output, errors.WithStack(err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return output, errors.WithStack(err)
+	}
+
+	output.IsDeleted = count > 0
+	return
+}
+
+func (r *Repository) GetUserIdByIdentity(ctx context.Context, input GetUserIdByIdentityInput) (output GetUserIdByIdentityOutput, err error) {
+	err = r.Db.QueryRowContext(ctx, GetUserIdByIdentityQuery, input.Type, input.Identifier).Scan(&output.UserId, &output.IsVerified)
+	err = errors.WithStack(err)
+	return
+}
+
+func (r *Repository) InsertExternalUser(ctx context.Context, input InsertExternalUserInput) (output InsertExternalUserOutput, err error) {
+	err = r.Db.QueryRowContext(ctx, InsertExternalUserQuery, input.FullName, input.Type, input.Identifier, input.Email).Scan(&output.Id)
+	err = errors.WithStack(err)
+	return
+}
+
+func (r *Repository) GetRolesByUserId(ctx context.Context, input GetRolesByUserIdInput) (output GetRolesByUserIdOutput, err error) {
+	rows, err := r.Db.QueryContext(ctx, GetRolesByUserIdQuery, input.UserId)
+	if err != nil {
+		return output, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var role string
+		if err = rows.Scan(&role); err != nil {
+			return GetRolesByUserIdOutput{}, errors.WithStack(err)
+		}
+		output.Roles = append(output.Roles, role)
+	}
+
+	err = errors.WithStack(rows.Err())
+	return
+}
+
+func (r *Repository) HasPermission(ctx context.Context, input HasPermissionInput) (output HasPermissionOutput, err error) {
+	err = r.Db.QueryRowContext(ctx, HasPermissionQuery, pq.Array(input.Roles), input.Permission).Scan(&output.IsAllowed)
+	err = errors.WithStack(err)
+	return
+}
+
+func (r *Repository) GetUserDetailsById(ctx context.Context, input GetUserDetailsByIdInput) (output GetUserDetailsByIdOutput, err error) {
+	var (
+		updatedAt sql.NullTime
+		updatedBy sql.NullInt64
+	)
+
+	err = r.Db.QueryRowContext(ctx, GetUserDetailsByIdQuery, input.Id).Scan(&output.Id, &output.FullName, &output.PhoneNumber, &output.Email, &output.EmailVerified,
+		&output.Language, &output.Status, &output.UserGroup, &output.TotalLogin, &output.CreatedAt, &updatedAt, &updatedBy, &output.Attributes, &output.AvatarKey)
+	if err != nil {
+		return GetUserDetailsByIdOutput{}, errors.WithStack(err)
+	}
+
+	if updatedAt.Valid {
+		output.UpdatedAt = &updatedAt.Time
+	}
+	if updatedBy.Valid {
+		output.UpdatedBy = &updatedBy.Int64
+	}
+
+	return
+}
+
+func (r *Repository) SearchUsers(ctx context.Context, input SearchUsersInput) (output SearchUsersOutput, err error) {
+	query, ok := SearchUsersQueries[input.Sort]
+	if !ok {
+		return output, errors.Errorf("unknown sort %q", input.Sort)
+	}
+
+	var afterId, afterValue interface{}
+	if input.AfterId != 0 {
+		afterId, afterValue = input.AfterId, input.AfterValue
+	}
+
+	rows, err := r.Db.QueryContext(ctx, query, input.PhonePrefix, input.Name, input.CreatedFrom, input.CreatedTo, input.Status, input.Limit, afterId, afterValue)
+	if err != nil {
+		return output, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user UserSummary
+		if err = rows.Scan(&user.Id, &user.FullName, &user.PhoneNumber, &user.Email, &user.Status, &user.CreatedAt); err != nil {
+			return SearchUsersOutput{}, errors.WithStack(err)
+		}
+		output.Users = append(output.Users, user)
+	}
+
+	err = errors.WithStack(rows.Err())
+	return
+}
+
+func (r *Repository) GetUserStatusById(ctx context.Context, input GetUserStatusByIdInput) (output GetUserStatusByIdOutput, err error) {
+	var purgeAt sql.NullTime
+
+	err = r.Db.QueryRowContext(ctx, GetUserStatusByIdQuery, input.Id).Scan(&output.Status, &purgeAt)
+	if err != nil {
+		return GetUserStatusByIdOutput{}, errors.WithStack(err)
+	}
+
+	if purgeAt.Valid {
+		output.PurgeAt = &purgeAt.Time
+	}
+
+	return
+}
+
+func (r *Repository) UpdateUserStatus(ctx context.Context, input UpdateUserStatusInput) (output UpdateUserStatusOutput, err error) {
+	var changeId int64
+
+	err = r.Db.QueryRowContext(ctx, UpdateUserStatusQuery, input.Id, input.FromStatus, input.Status, input.Reason, input.ChangedBy).Scan(&changeId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return output, nil
+		}
+		return output, errors.WithStack(err)
+	}
+
+	output.IsUpdated = true
+	return
+}
+
+func (r *Repository) DeleteUser(ctx context.Context, input DeleteUserInput) (output DeleteUserOutput, err error) {
+	var changeId int64
+
+	err = r.Db.QueryRowContext(ctx, DeleteUserQuery, input.Id, input.Reason, input.PurgeAt).Scan(&changeId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return output, nil
+		}
+		return output, errors.WithStack(err)
+	}
+
+	output.IsDeleted = true
+	return
+}
+
+func (r *Repository) RestoreUser(ctx context.Context, input RestoreUserInput) (output RestoreUserOutput, err error) {
+	var changeId int64
+
+	err = r.Db.QueryRowContext(ctx, RestoreUserQuery, input.Id, input.Reason).Scan(&changeId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return output, nil
+		}
+		return output, errors.WithStack(err)
+	}
+
+	output.IsRestored = true
+	return
+}
+
+func (r *Repository) PurgeDeletedUsers(ctx context.Context, input PurgeDeletedUsersInput) (output PurgeDeletedUsersOutput, err error) {
+	err = r.Db.QueryRowContext(ctx, PurgeDeletedUsersQuery, input.FullName).Scan(&output.Count, pq.Array(&output.AvatarKeys))
+	if err != nil {
+		return PurgeDeletedUsersOutput{}, errors.WithStack(err)
+	}
+
+	return
+}
+
+func (r *Repository) UpdateAvatarKeyById(ctx context.Context, input UpdateAvatarKeyByIdInput) (output UpdateAvatarKeyByIdOutput, err error) {
+	err = r.Db.QueryRowContext(ctx, UpdateAvatarKeyByIdQuery, input.Id, input.AvatarKey).Scan(&output.PreviousAvatarKey)
+	if err != nil {
+		return UpdateAvatarKeyByIdOutput{}, errors.WithStack(err)
+	}
+
+	return
+}
+
+func (r *Repository) GetUserStatusChangesByUserId(ctx context.Context, input GetUserStatusChangesByUserIdInput) (output GetUserStatusChangesByUserIdOutput, err error) {
+	rows, err := r.Db.QueryContext(ctx, GetUserStatusChangesByUserIdQuery, input.UserId)
+	if err != nil {
+		return output, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			change    UserStatusChange
+			changedBy sql.NullInt64
+		)
+		if err = rows.Scan(&change.Id, &change.FromStatus, &change.ToStatus, &change.Reason, &changedBy, &change.CreatedAt); err != nil {
+			return GetUserStatusChangesByUserIdOutput{}, errors.WithStack(err)
+		}
+		if changedBy.Valid {
+			change.ChangedBy = &changedBy.Int64
+		}
+		output.Changes = append(output.Changes, change)
+	}
+
+	err = errors.WithStack(rows.Err())
+	return
+}
+
+func (r *Repository) InsertImpersonation(ctx context.Context, input InsertImpersonationInput) (output InsertImpersonationOutput, err error) {
+	err = r.Db.QueryRowContext(ctx, InsertImpersonationQuery, input.AdminId, input.UserId, input.Reason, input.ExpiresAt).Scan(&output.Id)
+	if err != nil {
+		return InsertImpersonationOutput{}, errors.WithStack(err)
+	}
+
+	return
+}
+
+func (r *Repository) GetImpersonationsByUserId(ctx context.Context, input GetImpersonationsByUserIdInput) (output GetImpersonationsByUserIdOutput, err error) {
+	rows, err := r.Db.QueryContext(ctx, GetImpersonationsByUserIdQuery, input.UserId)
+	if err != nil {
+		return output, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			impersonation Impersonation
+			adminId       sql.NullInt64
+		)
+		if err = rows.Scan(&impersonation.Id, &adminId, &impersonation.Reason, &impersonation.CreatedAt, &impersonation.ExpiresAt); err != nil {
+			return GetImpersonationsByUserIdOutput{}, errors.WithStack(err)
+		}
+		if adminId.Valid {
+			impersonation.AdminId = &adminId.Int64
+		}
+		output.Impersonations = append(output.Impersonations, impersonation)
+	}
+
+	err = errors.WithStack(rows.Err())
+	return
+}
+
+func (r *Repository) InsertImpersonationUse(ctx context.Context, input InsertImpersonationUseInput) (err error) {
+	_, err = r.Db.ExecContext(ctx, InsertImpersonationUseQuery, input.ImpersonationId, input.Method, input.Path)
+
+	err = errors.WithStack(err)
+	return err
+}
+
+func (r *Repository) GetImpersonationUsesByUserId(ctx context.Context, input GetImpersonationUsesByUserIdInput) (output GetImpersonationUsesByUserIdOutput, err error) {
+	rows, err := r.Db.QueryContext(ctx, GetImpersonationUsesByUserIdQuery, input.UserId)
+	if err != nil {
+		return output, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var use ImpersonationUse
+		if err = rows.Scan(&use.Id, &use.ImpersonationId, &use.Method, &use.Path, &use.CreatedAt); err != nil {
+			return GetImpersonationUsesByUserIdOutput{}, errors.WithStack(err)
+		}
+		output.Uses = append(output.Uses, use)
+	}
+
+	err = errors.WithStack(rows.Err())
+	return
+}
+
+func (r *Repository) InsertLoginEvent(ctx context.Context, input InsertLoginEventInput) (err error) {
+	_, err = r.Db.ExecContext(ctx, InsertLoginEventQuery, input.UserId, input.Method, input.IP)
+
+	err = errors.WithStack(err)
+	return err
+}
+
+func (r *Repository) GetLoginEventsByUserId(ctx context.Context, input GetLoginEventsByUserIdInput) (output GetLoginEventsByUserIdOutput, err error) {
+	rows, err := r.Db.QueryContext(ctx, GetLoginEventsByUserIdQuery, input.UserId)
+	if err != nil {
+		return output, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event LoginEvent
+		if err = rows.Scan(&event.Id, &event.Method, &event.IP, &event.CreatedAt); err != nil {
+			return GetLoginEventsByUserIdOutput{}, errors.WithStack(err)
+		}
+		output.Events = append(output.Events, event)
+	}
+
+	err = errors.WithStack(rows.Err())
+	return
+}
+
+func (r *Repository) CountUserRecords(ctx context.Context, input CountUserRecordsInput) (output CountUserRecordsOutput, err error) {
+	err = r.Db.QueryRowContext(ctx, CountUserRecordsQuery, input.UserId).Scan(&output.Count)
+	err = errors.WithStack(err)
+	return
+}
+
+func (r *Repository) InsertDataExport(ctx context.Context, input InsertDataExportInput) (output InsertDataExportOutput, err error) {
+	err = r.Db.QueryRowContext(ctx, InsertDataExportQuery, input.UserId, input.Format, input.ExpiresAt).Scan(&output.Id)
+	if err != nil {
+		return InsertDataExportOutput{}, errors.WithStack(err)
+	}
+
+	return
+}
+
+func (r *Repository) UpdateDataExport(ctx context.Context, input UpdateDataExportInput) (err error) {
+	_, err = r.Db.ExecContext(ctx, UpdateDataExportQuery, input.Id, input.Status, input.Content)
+
+	err = errors.WithStack(err)
+	return err
+}
+
+func (r *Repository) GetDataExport(ctx context.Context, input GetDataExportInput) (output GetDataExportOutput, err error) {
+	err = r.Db.QueryRowContext(ctx, GetDataExportQuery, input.Id, input.UserId).Scan(&output.Id, &output.Format, &output.Status, &output.Content, &output.CreatedAt, &output.ExpiresAt)
+	if err != nil {
+		return GetDataExportOutput{}, errors.WithStack(err)
+	}
+
+	return
+}
+
+func (r *Repository) GetOpenDataExport(ctx context.Context, input GetOpenDataExportInput) (output GetOpenDataExportOutput, err error) {
+	err = r.Db.QueryRowContext(ctx, GetOpenDataExportQuery, input.UserId, input.Format).Scan(&output.Id, &output.ExpiresAt)
+	if err != nil {
+		return GetOpenDataExportOutput{}, errors.WithStack(err)
+	}
+
+	return
+}
+
+func (r *Repository) DeleteExpiredDataExports(ctx context.Context, input DeleteExpiredDataExportsInput) (output DeleteExpiredDataExportsOutput, err error) {
+	result, err := r.Db.ExecContext(ctx, DeleteExpiredDataExportsQuery)
+	if err != nil {
+		return output, errors.WithStack(err)
+	}
+
+	output.Count, err = result.RowsAffected()
 	err = errors.WithStack(err)
 	return
 }
+
+func (r *Repository) GetLatestAttributeSchema(ctx context.Context, input GetLatestAttributeSchemaInput) (output GetLatestAttributeSchemaOutput, err error) {
+	err = r.Db.QueryRowContext(ctx, GetLatestAttributeSchemaQuery).Scan(&output.Schema)
+	err = errors.WithStack(err)
+	return
+}
+
+func (r *Repository) InsertAttributeSchema(ctx context.Context, input InsertAttributeSchemaInput) (output InsertAttributeSchemaOutput, err error) {
+	err = r.Db.QueryRowContext(ctx, InsertAttributeSchemaQuery, jsonParam(input.Schema), input.CreatedBy).Scan(&output.Id)
+	err = errors.WithStack(err)
+	return
+}
+
+// UpdateAttributesById returns sql.ErrNoRows when there is no user with the
+// id.
+func (r *Repository) UpdateAttributesById(ctx context.Context, input UpdateAttributesByIdInput) (err error) {
+	var id int64
+	err = r.Db.QueryRowContext(ctx, UpdateAttributesByIdQuery, input.Id, jsonParam(input.Attributes), input.UpdatedBy).Scan(&id)
+	return errors.WithStack(err)
+}
+
+// jsonParam passes a JSON document as text, lib/pq would send []byte as
+// bytea. A nil document is null.
+func jsonParam(document []byte) interface{} {
+	if document == nil {
+		return nil
+	}
+
+	return string(document)
+}
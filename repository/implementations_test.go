@@ -2,9 +2,11 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"reflect"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/lib/pq"
@@ -39,7 +41,7 @@ func TestRepository_InsertNewUser(t *testing.T) {
 			},
 			mockFunc: func(a args) {
 				mock.ExpectQuery(regexp.QuoteMeta(InsertNewUserQuery)).
-					WithArgs(a.input.PhoneNumber, a.input.FullName, a.input.Password).
+					WithArgs(a.input.PhoneNumber, a.input.FullName, a.input.Password, a.input.Email).
 					WillReturnError(&pq.Error{
 						Code: "23505",
 					})
@@ -49,6 +51,29 @@ func TestRepository_InsertNewUser(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Success, conflict email exists",
+			args: args{
+				input: InsertNewUserInput{
+					PhoneNumber: "12345",
+					FullName:    "fullname",
+					Password:    "password",
+					Email:       "Name@example.com",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(InsertNewUserQuery)).
+					WithArgs(a.input.PhoneNumber, a.input.FullName, a.input.Password, a.input.Email).
+					WillReturnError(&pq.Error{
+						Code:       "23505",
+						Constraint: EMAIL_UNIQUE_INDEX,
+					})
+			},
+			want: InsertNewUserOutput{
+				IsEmailExists: true,
+			},
+			wantErr: false,
+		},
 		{
 			name: "Error when query",
 			args: args{
@@ -60,7 +85,7 @@ func TestRepository_InsertNewUser(t *testing.T) {
 			},
 			mockFunc: func(a args) {
 				mock.ExpectQuery(regexp.QuoteMeta(InsertNewUserQuery)).
-					WithArgs(a.input.PhoneNumber, a.input.FullName, a.input.Password).
+					WithArgs(a.input.PhoneNumber, a.input.FullName, a.input.Password, a.input.Email).
 					WillReturnError(errors.New("test"))
 			},
 			want:    InsertNewUserOutput{},
@@ -77,7 +102,7 @@ func TestRepository_InsertNewUser(t *testing.T) {
 			},
 			mockFunc: func(a args) {
 				mock.ExpectQuery(regexp.QuoteMeta(InsertNewUserQuery)).
-					WithArgs(a.input.PhoneNumber, a.input.FullName, a.input.Password).
+					WithArgs(a.input.PhoneNumber, a.input.FullName, a.input.Password, a.input.Email).
 					WillReturnRows(sqlmock.NewRows([]string{"id"}).
 						AddRow(int64(50)))
 			},
@@ -133,7 +158,7 @@ func TestRepository_UpdateUserData(t *testing.T) {
 			},
 			mockFunc: func(a args) {
 				mock.ExpectExec(regexp.QuoteMeta(UpdateUserDataQuery)).
-					WithArgs(a.input.Id, a.input.PhoneNumber, a.input.FullName, a.input.Id).
+					WithArgs(a.input.Id, a.input.PhoneNumber, a.input.FullName, a.input.Id, a.input.Language, a.input.Email, nil).
 					WillReturnError(&pq.Error{
 						Code: "23505",
 					})
@@ -143,6 +168,29 @@ func TestRepository_UpdateUserData(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Success, conflict email exists",
+			args: args{
+				input: UpdateUserDataInput{
+					Id:          10,
+					PhoneNumber: "phone_number",
+					FullName:    "full_name",
+					Email:       "Name@example.com",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(UpdateUserDataQuery)).
+					WithArgs(a.input.Id, a.input.PhoneNumber, a.input.FullName, a.input.Id, a.input.Language, a.input.Email, nil).
+					WillReturnError(&pq.Error{
+						Code:       "23505",
+						Constraint: EMAIL_UNIQUE_INDEX,
+					})
+			},
+			want: UpdateUserDataOutput{
+				IsEmailExists: true,
+			},
+			wantErr: false,
+		},
 		{
 			name: "Error when query",
 			args: args{
@@ -154,7 +202,7 @@ func TestRepository_UpdateUserData(t *testing.T) {
 			},
 			mockFunc: func(a args) {
 				mock.ExpectExec(regexp.QuoteMeta(UpdateUserDataQuery)).
-					WithArgs(a.input.Id, a.input.PhoneNumber, a.input.FullName, a.input.Id).
+					WithArgs(a.input.Id, a.input.PhoneNumber, a.input.FullName, a.input.Id, a.input.Language, a.input.Email, nil).
 					WillReturnError(errors.New("test"))
 			},
 			want:    UpdateUserDataOutput{},
@@ -167,11 +215,30 @@ func TestRepository_UpdateUserData(t *testing.T) {
 					Id:          10,
 					PhoneNumber: "phone_number",
 					FullName:    "full_name",
+					Language:    "id",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(UpdateUserDataQuery)).
+					WithArgs(a.input.Id, a.input.PhoneNumber, a.input.FullName, a.input.Id, a.input.Language, a.input.Email, nil).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			want:    UpdateUserDataOutput{},
+			wantErr: false,
+		},
+		{
+			name: "Success with attributes",
+			args: args{
+				input: UpdateUserDataInput{
+					Id:          10,
+					PhoneNumber: "phone_number",
+					FullName:    "full_name",
+					Attributes:  []byte(`{"estate_id":7}`),
 				},
 			},
 			mockFunc: func(a args) {
 				mock.ExpectExec(regexp.QuoteMeta(UpdateUserDataQuery)).
-					WithArgs(a.input.Id, a.input.PhoneNumber, a.input.FullName, a.input.Id).
+					WithArgs(a.input.Id, a.input.PhoneNumber, a.input.FullName, a.input.Id, a.input.Language, a.input.Email, `{"estate_id":7}`).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 			},
 			want:    UpdateUserDataOutput{},
@@ -196,7 +263,140 @@ func TestRepository_UpdateUserData(t *testing.T) {
 	}
 }
 
-func TestRepository_GetPasswordByPhoneNumber(t *testing.T) {
+func TestRepository_PatchUserData(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	text := func(s string) *string { return &s }
+
+	type args struct {
+		input PatchUserDataInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     PatchUserDataOutput
+		wantErr  bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: PatchUserDataInput{
+					Id:       10,
+					FullName: text("full_name"),
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(PatchUserDataQuery)).
+					WithArgs(a.input.Id, "full_name", nil, nil, nil, nil, nil).
+					WillReturnError(errors.New("test"))
+			},
+			want:    PatchUserDataOutput{},
+			wantErr: true,
+		},
+		{
+			name: "Success, conflict phone number exists",
+			args: args{
+				input: PatchUserDataInput{
+					Id:          10,
+					PhoneNumber: text("phone_number"),
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(PatchUserDataQuery)).
+					WithArgs(a.input.Id, nil, nil, "phone_number", nil, nil, nil).
+					WillReturnError(&pq.Error{
+						Code: "23505",
+					})
+			},
+			want: PatchUserDataOutput{
+				IsPhoneNumberExists: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, conflict email exists",
+			args: args{
+				input: PatchUserDataInput{
+					Id:    10,
+					Email: text("Name@example.com"),
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(PatchUserDataQuery)).
+					WithArgs(a.input.Id, nil, nil, nil, "Name@example.com", nil, nil).
+					WillReturnError(&pq.Error{
+						Code:       "23505",
+						Constraint: EMAIL_UNIQUE_INDEX,
+					})
+			},
+			want: PatchUserDataOutput{
+				IsEmailExists: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, attributes changed in the meantime",
+			args: args{
+				input: PatchUserDataInput{
+					Id:                 10,
+					Attributes:         []byte(`{"estate_id":8}`),
+					ExpectedAttributes: []byte(`{"estate_id":7}`),
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(PatchUserDataQuery)).
+					WithArgs(a.input.Id, nil, nil, nil, nil, `{"estate_id":8}`, `{"estate_id":7}`).
+					WillReturnRows(sqlmock.NewRows([]string{"email"}))
+			},
+			want: PatchUserDataOutput{
+				IsNotUpdated: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, language and email removed",
+			args: args{
+				input: PatchUserDataInput{
+					Id:       10,
+					Language: text(""),
+					Email:    text(""),
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(PatchUserDataQuery)).
+					WithArgs(a.input.Id, nil, "", nil, "", nil, nil).
+					WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("old@example.com"))
+			},
+			want: PatchUserDataOutput{
+				PreviousEmail: "old@example.com",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			got, err := r.PatchUserData(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.PatchUserData() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Repository.PatchUserData() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepository_GetPasswordByIdentity(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
@@ -204,47 +404,74 @@ func TestRepository_GetPasswordByPhoneNumber(t *testing.T) {
 	defer db.Close()
 
 	type args struct {
-		input GetPasswordByPhoneNumberInput
+		input GetPasswordByIdentityInput
 	}
 	tests := []struct {
 		name       string
 		args       args
 		mockFunc   func(args)
-		wantOutput GetPasswordByPhoneNumberOutput
+		wantOutput GetPasswordByIdentityOutput
 		wantErr    bool
 	}{
 		{
 			name: "Error when query",
 			args: args{
-				input: GetPasswordByPhoneNumberInput{
-					PhoneNumber: "12345",
+				input: GetPasswordByIdentityInput{
+					Type:       IDENTITY_TYPE_PHONE,
+					Identifier: "12345",
 				},
 			},
 			mockFunc: func(a args) {
-				mock.ExpectQuery(regexp.QuoteMeta(GetPasswordByPhoneNumberQuery)).
-					WithArgs(a.input.PhoneNumber).
+				mock.ExpectQuery(regexp.QuoteMeta(GetPasswordByIdentityQuery)).
+					WithArgs(a.input.Type, a.input.Identifier).
 					WillReturnError(errors.New("test"))
 			},
-			wantOutput: GetPasswordByPhoneNumberOutput{},
+			wantOutput: GetPasswordByIdentityOutput{},
 			wantErr:    true,
 		},
 		{
 			name: "Success",
 			args: args{
-				input: GetPasswordByPhoneNumberInput{
-					PhoneNumber: "12345",
+				input: GetPasswordByIdentityInput{
+					Type:       IDENTITY_TYPE_PHONE,
+					Identifier: "12345",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetPasswordByIdentityQuery)).
+					WithArgs(a.input.Type, a.input.Identifier).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "password", "phone_number", "password_changed_at", "user_group"}).
+						AddRow(int64(50), "passwordaa", "phone_000", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "staff"))
+			},
+			wantOutput: GetPasswordByIdentityOutput{
+				Id:                50,
+				PhoneNumber:       "phone_000",
+				Password:          "passwordaa",
+				PasswordChangedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				UserGroup:         "staff",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, email",
+			args: args{
+				input: GetPasswordByIdentityInput{
+					Type:       IDENTITY_TYPE_EMAIL,
+					Identifier: "name@example.com",
 				},
 			},
 			mockFunc: func(a args) {
-				mock.ExpectQuery(regexp.QuoteMeta(GetPasswordByPhoneNumberQuery)).
-					WithArgs(a.input.PhoneNumber).
-					WillReturnRows(sqlmock.NewRows([]string{"id", "password", "phone_number"}).
-						AddRow(int64(50), "passwordaa", "phone_000"))
+				mock.ExpectQuery(regexp.QuoteMeta(GetPasswordByIdentityQuery)).
+					WithArgs(a.input.Type, a.input.Identifier).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "password", "phone_number", "password_changed_at", "user_group"}).
+						AddRow(int64(50), "password", "+62812345678", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "default"))
 			},
-			wantOutput: GetPasswordByPhoneNumberOutput{
-				Id:          50,
-				PhoneNumber: "phone_000",
-				Password:    "passwordaa",
+			wantOutput: GetPasswordByIdentityOutput{
+				Id:                50,
+				PhoneNumber:       "+62812345678",
+				Password:          "password",
+				PasswordChangedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				UserGroup:         "default",
 			},
 			wantErr: false,
 		},
@@ -255,13 +482,13 @@ func TestRepository_GetPasswordByPhoneNumber(t *testing.T) {
 			r := &Repository{
 				Db: db,
 			}
-			gotOutput, err := r.GetPasswordByPhoneNumber(context.Background(), tt.args.input)
+			gotOutput, err := r.GetPasswordByIdentity(context.Background(), tt.args.input)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("Repository.GetPasswordByPhoneNumber() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("Repository.GetPasswordByIdentity() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
-				t.Errorf("Repository.GetPasswordByPhoneNumber() = %v, want %v", gotOutput, tt.wantOutput)
+				t.Errorf("Repository.GetPasswordByIdentity() = %v, want %v", gotOutput, tt.wantOutput)
 			}
 		})
 	}
@@ -367,13 +594,18 @@ func TestRepository_GetUserDataById(t *testing.T) {
 			mockFunc: func(a args) {
 				mock.ExpectQuery(regexp.QuoteMeta(GetUserDataByIdQuery)).
 					WithArgs(a.input.Id).
-					WillReturnRows(sqlmock.NewRows([]string{"id", "full_name", "phone_number"}).
-						AddRow("50", "fullname", "phone_000"))
+					WillReturnRows(sqlmock.NewRows([]string{"id", "full_name", "phone_number", "language", "email", "email_verified", "avatar_key", "attributes"}).
+						AddRow("50", "fullname", "phone_000", "id", "Name@example.com", true, "avatars/50/abc", []byte(`{"estate_id":7}`)))
 			},
 			wantOutput: GetUserDataByIdOutput{
-				Id:          "50",
-				FullName:    "fullname",
-				PhoneNumber: "phone_000",
+				Id:            "50",
+				FullName:      "fullname",
+				PhoneNumber:   "phone_000",
+				Language:      "id",
+				Email:         "Name@example.com",
+				EmailVerified: true,
+				AvatarKey:     "avatars/50/abc",
+				Attributes:    []byte(`{"estate_id":7}`),
 			},
 			wantErr: false,
 		},
@@ -395,3 +627,3413 @@ func TestRepository_GetUserDataById(t *testing.T) {
 		})
 	}
 }
+
+func TestRepository_UpdatePasswordById(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input UpdatePasswordByIdInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		wantErr  bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: UpdatePasswordByIdInput{
+					Id:       99,
+					Password: "$argon2id$v=19$m=65536,t=3,p=2$c2FsdA$a2V5",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(UpdatePasswordByIdQuery)).
+					WithArgs(a.input.Id, a.input.Password).
+					WillReturnError(errors.New("test"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: UpdatePasswordByIdInput{
+					Id:       99,
+					Password: "$argon2id$v=19$m=65536,t=3,p=2$c2FsdA$a2V5",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(UpdatePasswordByIdQuery)).
+					WithArgs(a.input.Id, a.input.Password).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			if err := r.UpdatePasswordById(context.Background(), tt.args.input); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.UpdatePasswordById() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_GetPasswordById(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input GetPasswordByIdInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput GetPasswordByIdOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: GetPasswordByIdInput{
+					Id: 21,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetPasswordByIdQuery)).
+					WithArgs(a.input.Id).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: GetPasswordByIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: GetPasswordByIdInput{
+					Id: 21,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetPasswordByIdQuery)).
+					WithArgs(a.input.Id).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "password"}).
+						AddRow(int64(21), "passwordaa"))
+			},
+			wantOutput: GetPasswordByIdOutput{
+				Id:       21,
+				Password: "passwordaa",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.GetPasswordById(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetPasswordById() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetPasswordById() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_SetPasswordById(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input SetPasswordByIdInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		wantErr  bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: SetPasswordByIdInput{
+					Id:       21,
+					Password: "hashed",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(SetPasswordByIdQuery)).
+					WithArgs(a.input.Id, a.input.Password).
+					WillReturnError(errors.New("test"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: SetPasswordByIdInput{
+					Id:       21,
+					Password: "hashed",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(SetPasswordByIdQuery)).
+					WithArgs(a.input.Id, a.input.Password).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			if err := r.SetPasswordById(context.Background(), tt.args.input); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.SetPasswordById() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_GetPasswordHistoryByUserId(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input GetPasswordHistoryByUserIdInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput GetPasswordHistoryByUserIdOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: GetPasswordHistoryByUserIdInput{
+					UserId: 21,
+					Limit:  5,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetPasswordHistoryByUserIdQuery)).
+					WithArgs(a.input.UserId, a.input.Limit).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: GetPasswordHistoryByUserIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Error when scan",
+			args: args{
+				input: GetPasswordHistoryByUserIdInput{
+					UserId: 21,
+					Limit:  5,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetPasswordHistoryByUserIdQuery)).
+					WithArgs(a.input.UserId, a.input.Limit).
+					WillReturnRows(sqlmock.NewRows([]string{"password"}).
+						AddRow(nil))
+			},
+			wantOutput: GetPasswordHistoryByUserIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: GetPasswordHistoryByUserIdInput{
+					UserId: 21,
+					Limit:  5,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetPasswordHistoryByUserIdQuery)).
+					WithArgs(a.input.UserId, a.input.Limit).
+					WillReturnRows(sqlmock.NewRows([]string{"password"}).
+						AddRow("hash-2").
+						AddRow("hash-1"))
+			},
+			wantOutput: GetPasswordHistoryByUserIdOutput{
+				Passwords: []string{"hash-2", "hash-1"},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.GetPasswordHistoryByUserId(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetPasswordHistoryByUserId() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetPasswordHistoryByUserId() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_DeleteOldPasswordHistory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input DeleteOldPasswordHistoryInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		wantErr  bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: DeleteOldPasswordHistoryInput{
+					UserId: 21,
+					Keep:   5,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(DeleteOldPasswordHistoryQuery)).
+					WithArgs(a.input.UserId, a.input.Keep).
+					WillReturnError(errors.New("test"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: DeleteOldPasswordHistoryInput{
+					UserId: 21,
+					Keep:   5,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(DeleteOldPasswordHistoryQuery)).
+					WithArgs(a.input.UserId, a.input.Keep).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			if err := r.DeleteOldPasswordHistory(context.Background(), tt.args.input); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.DeleteOldPasswordHistory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_UpsertEmailVerification(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	expiresAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	type args struct {
+		input UpsertEmailVerificationInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		wantErr  bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: UpsertEmailVerificationInput{
+					UserId:    21,
+					Email:     "name@example.com",
+					TokenHash: "token_hash",
+					CodeHash:  "code_hash",
+					ExpiresAt: expiresAt,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(UpsertEmailVerificationQuery)).
+					WithArgs(a.input.UserId, a.input.Email, a.input.TokenHash, a.input.CodeHash, a.input.ExpiresAt).
+					WillReturnError(errors.New("test"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: UpsertEmailVerificationInput{
+					UserId:    21,
+					Email:     "name@example.com",
+					TokenHash: "token_hash",
+					CodeHash:  "code_hash",
+					ExpiresAt: expiresAt,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(UpsertEmailVerificationQuery)).
+					WithArgs(a.input.UserId, a.input.Email, a.input.TokenHash, a.input.CodeHash, a.input.ExpiresAt).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			if err := r.UpsertEmailVerification(context.Background(), tt.args.input); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.UpsertEmailVerification() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_GetEmailVerificationByTokenHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	expiresAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	type args struct {
+		input GetEmailVerificationByTokenHashInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput GetEmailVerificationOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: GetEmailVerificationByTokenHashInput{
+					TokenHash: "token_hash",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetEmailVerificationByTokenHashQuery)).
+					WithArgs(a.input.TokenHash).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: GetEmailVerificationOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: GetEmailVerificationByTokenHashInput{
+					TokenHash: "token_hash",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetEmailVerificationByTokenHashQuery)).
+					WithArgs(a.input.TokenHash).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "email", "code_hash", "attempts", "expires_at"}).
+						AddRow(int64(3), int64(21), "name@example.com", "code_hash", 1, expiresAt))
+			},
+			wantOutput: GetEmailVerificationOutput{
+				Id:        3,
+				UserId:    21,
+				Email:     "name@example.com",
+				CodeHash:  "code_hash",
+				Attempts:  1,
+				ExpiresAt: expiresAt,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.GetEmailVerificationByTokenHash(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetEmailVerificationByTokenHash() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetEmailVerificationByTokenHash() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_GetEmailVerificationByUserId(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	expiresAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	type args struct {
+		input GetEmailVerificationByUserIdInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput GetEmailVerificationOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: GetEmailVerificationByUserIdInput{
+					UserId: 21,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetEmailVerificationByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: GetEmailVerificationOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: GetEmailVerificationByUserIdInput{
+					UserId: 21,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetEmailVerificationByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "email", "code_hash", "attempts", "expires_at"}).
+						AddRow(int64(3), int64(21), "name@example.com", "code_hash", 0, expiresAt))
+			},
+			wantOutput: GetEmailVerificationOutput{
+				Id:        3,
+				UserId:    21,
+				Email:     "name@example.com",
+				CodeHash:  "code_hash",
+				ExpiresAt: expiresAt,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.GetEmailVerificationByUserId(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetEmailVerificationByUserId() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetEmailVerificationByUserId() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_IncrementEmailVerificationAttempts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input IncrementEmailVerificationAttemptsInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		wantErr  bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: IncrementEmailVerificationAttemptsInput{
+					Id: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(IncrementEmailVerificationAttemptsQuery)).
+					WithArgs(a.input.Id).
+					WillReturnError(errors.New("test"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: IncrementEmailVerificationAttemptsInput{
+					Id: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(IncrementEmailVerificationAttemptsQuery)).
+					WithArgs(a.input.Id).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			if err := r.IncrementEmailVerificationAttempts(context.Background(), tt.args.input); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.IncrementEmailVerificationAttempts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_VerifyEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input VerifyEmailInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput VerifyEmailOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: VerifyEmailInput{
+					UserId: 21,
+					Email:  "name@example.com",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(VerifyEmailQuery)).
+					WithArgs(a.input.UserId, a.input.Email).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: VerifyEmailOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success, email changed since the verification was sent",
+			args: args{
+				input: VerifyEmailInput{
+					UserId: 21,
+					Email:  "name@example.com",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(VerifyEmailQuery)).
+					WithArgs(a.input.UserId, a.input.Email).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).
+						AddRow(int64(0)))
+			},
+			wantOutput: VerifyEmailOutput{
+				IsEmailChanged: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: VerifyEmailInput{
+					UserId: 21,
+					Email:  "name@example.com",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(VerifyEmailQuery)).
+					WithArgs(a.input.UserId, a.input.Email).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).
+						AddRow(int64(1)))
+			},
+			wantOutput: VerifyEmailOutput{},
+			wantErr:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.VerifyEmail(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.VerifyEmail() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.VerifyEmail() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_InsertIdentity(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input InsertIdentityInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     InsertIdentityOutput
+		wantErr  bool
+	}{
+		{
+			name: "Success, conflict identifier exists",
+			args: args{
+				input: InsertIdentityInput{
+					UserId:     50,
+					Type:       IDENTITY_TYPE_EMAIL,
+					Identifier: "name@example.com",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(InsertIdentityQuery)).
+					WithArgs(a.input.UserId, a.input.Type, a.input.Identifier, a.input.IsVerified).
+					WillReturnError(&pq.Error{
+						Code:       "23505",
+						Constraint: EMAIL_UNIQUE_INDEX,
+					})
+			},
+			want: InsertIdentityOutput{
+				IsIdentifierExists: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error when query",
+			args: args{
+				input: InsertIdentityInput{
+					UserId:     50,
+					Type:       IDENTITY_TYPE_PHONE,
+					Identifier: "+62812345678",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(InsertIdentityQuery)).
+					WithArgs(a.input.UserId, a.input.Type, a.input.Identifier, a.input.IsVerified).
+					WillReturnError(errors.New("test"))
+			},
+			want:    InsertIdentityOutput{},
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: InsertIdentityInput{
+					UserId:     50,
+					Type:       IDENTITY_TYPE_PHONE,
+					Identifier: "+62812345678",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(InsertIdentityQuery)).
+					WithArgs(a.input.UserId, a.input.Type, a.input.Identifier, a.input.IsVerified).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).
+						AddRow(int64(7)))
+			},
+			want: InsertIdentityOutput{
+				Id: 7,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, verified",
+			args: args{
+				input: InsertIdentityInput{
+					UserId:     50,
+					Type:       "acme",
+					Identifier: "248289761001",
+					IsVerified: true,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(InsertIdentityQuery)).
+					WithArgs(a.input.UserId, a.input.Type, a.input.Identifier, true).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).
+						AddRow(int64(8)))
+			},
+			want: InsertIdentityOutput{
+				Id: 8,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			got, err := r.InsertIdentity(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.InsertIdentity() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Repository.InsertIdentity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepository_GetIdentitiesByUserId(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	verifiedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	type args struct {
+		input GetIdentitiesByUserIdInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput GetIdentitiesByUserIdOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: GetIdentitiesByUserIdInput{
+					UserId: 50,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetIdentitiesByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: GetIdentitiesByUserIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Error when scan",
+			args: args{
+				input: GetIdentitiesByUserIdInput{
+					UserId: 50,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetIdentitiesByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "type", "identifier", "is_primary", "verified_at"}).
+						AddRow("not a number", IDENTITY_TYPE_PHONE, "+62812345678", true, nil))
+			},
+			wantOutput: GetIdentitiesByUserIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: GetIdentitiesByUserIdInput{
+					UserId: 50,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetIdentitiesByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "type", "identifier", "is_primary", "verified_at"}).
+						AddRow(int64(1), IDENTITY_TYPE_PHONE, "+62812345678", true, nil).
+						AddRow(int64(2), IDENTITY_TYPE_EMAIL, "name@example.com", false, verifiedAt))
+			},
+			wantOutput: GetIdentitiesByUserIdOutput{
+				Identities: []Identity{
+					{
+						Id:         1,
+						Type:       IDENTITY_TYPE_PHONE,
+						Identifier: "+62812345678",
+						IsPrimary:  true,
+					},
+					{
+						Id:         2,
+						Type:       IDENTITY_TYPE_EMAIL,
+						Identifier: "name@example.com",
+						VerifiedAt: &verifiedAt,
+					},
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.GetIdentitiesByUserId(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetIdentitiesByUserId() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetIdentitiesByUserId() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_DeleteIdentity(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input DeleteIdentityInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput DeleteIdentityOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: DeleteIdentityInput{
+					Id:     7,
+					UserId: 50,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(DeleteIdentityQuery)).
+					WithArgs(a.input.Id, a.input.UserId).
+					WillReturnError(errors.New("test"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "Error when rows affected",
+			args: args{
+				input: DeleteIdentityInput{
+					Id:     7,
+					UserId: 50,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(DeleteIdentityQuery)).
+					WithArgs(a.input.Id, a.input.UserId).
+					WillReturnResult(sqlmock.NewErrorResult(errors.New("test")))
+			},
+			wantErr: true,
+		},
+		{
+			name: "Success, nothing deleted",
+			args: args{
+				input: DeleteIdentityInput{
+					Id:     7,
+					UserId: 50,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(DeleteIdentityQuery)).
+					WithArgs(a.input.Id, a.input.UserId).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantOutput: DeleteIdentityOutput{
+				IsDeleted: false,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: DeleteIdentityInput{
+					Id:     7,
+					UserId: 50,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(DeleteIdentityQuery)).
+					WithArgs(a.input.Id, a.input.UserId).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantOutput: DeleteIdentityOutput{
+				IsDeleted: true,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.DeleteIdentity(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.DeleteIdentity() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.DeleteIdentity() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_GetUserIdByIdentity(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input GetUserIdByIdentityInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     GetUserIdByIdentityOutput
+		wantErr  bool
+	}{
+		{
+			name: "Error not found",
+			args: args{
+				input: GetUserIdByIdentityInput{
+					Type:       "acme",
+					Identifier: "248289761001",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetUserIdByIdentityQuery)).
+					WithArgs(a.input.Type, a.input.Identifier).
+					WillReturnError(sql.ErrNoRows)
+			},
+			want:    GetUserIdByIdentityOutput{},
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: GetUserIdByIdentityInput{
+					Type:       IDENTITY_TYPE_EMAIL,
+					Identifier: "Name@example.com",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetUserIdByIdentityQuery)).
+					WithArgs(a.input.Type, a.input.Identifier).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "verified"}).
+						AddRow(int64(50), true))
+			},
+			want: GetUserIdByIdentityOutput{
+				UserId:     50,
+				IsVerified: true,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			got, err := r.GetUserIdByIdentity(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetUserIdByIdentity() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Repository.GetUserIdByIdentity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepository_InsertExternalUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input InsertExternalUserInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     InsertExternalUserOutput
+		wantErr  bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: InsertExternalUserInput{
+					FullName:   "Jane Doe",
+					Type:       "acme",
+					Identifier: "248289761001",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(InsertExternalUserQuery)).
+					WithArgs(a.input.FullName, a.input.Type, a.input.Identifier, a.input.Email).
+					WillReturnError(errors.New("test"))
+			},
+			want:    InsertExternalUserOutput{},
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: InsertExternalUserInput{
+					FullName:   "Jane Doe",
+					Type:       "acme",
+					Identifier: "248289761001",
+					Email:      "jane@example.com",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(InsertExternalUserQuery)).
+					WithArgs(a.input.FullName, a.input.Type, a.input.Identifier, a.input.Email).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).
+						AddRow(int64(51)))
+			},
+			want: InsertExternalUserOutput{
+				Id: 51,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			got, err := r.InsertExternalUser(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.InsertExternalUser() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Repository.InsertExternalUser() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepository_GetRolesByUserId(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input GetRolesByUserIdInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput GetRolesByUserIdOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: GetRolesByUserIdInput{
+					UserId: 21,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetRolesByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: GetRolesByUserIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Error when scan",
+			args: args{
+				input: GetRolesByUserIdInput{
+					UserId: 21,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetRolesByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnRows(sqlmock.NewRows([]string{"name"}).
+						AddRow(nil))
+			},
+			wantOutput: GetRolesByUserIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success, no roles",
+			args: args{
+				input: GetRolesByUserIdInput{
+					UserId: 21,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetRolesByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnRows(sqlmock.NewRows([]string{"name"}))
+			},
+			wantOutput: GetRolesByUserIdOutput{},
+			wantErr:    false,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: GetRolesByUserIdInput{
+					UserId: 21,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetRolesByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnRows(sqlmock.NewRows([]string{"name"}).
+						AddRow("admin").
+						AddRow("support"))
+			},
+			wantOutput: GetRolesByUserIdOutput{
+				Roles: []string{"admin", "support"},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.GetRolesByUserId(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetRolesByUserId() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetRolesByUserId() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_HasPermission(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input HasPermissionInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput HasPermissionOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: HasPermissionInput{
+					Roles:      []string{"support"},
+					Permission: "users:read",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(HasPermissionQuery)).
+					WithArgs(pq.Array(a.input.Roles), a.input.Permission).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: HasPermissionOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success, not allowed",
+			args: args{
+				input: HasPermissionInput{
+					Roles:      []string{"support"},
+					Permission: "users:write",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(HasPermissionQuery)).
+					WithArgs(pq.Array(a.input.Roles), a.input.Permission).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).
+						AddRow(false))
+			},
+			wantOutput: HasPermissionOutput{},
+			wantErr:    false,
+		},
+		{
+			name: "Success, allowed",
+			args: args{
+				input: HasPermissionInput{
+					Roles:      []string{"admin", "support"},
+					Permission: "users:write",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(HasPermissionQuery)).
+					WithArgs(pq.Array(a.input.Roles), a.input.Permission).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).
+						AddRow(true))
+			},
+			wantOutput: HasPermissionOutput{
+				IsAllowed: true,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.HasPermission(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.HasPermission() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.HasPermission() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_GetUserDetailsById(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var (
+		createdAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		updatedAt = time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+		updatedBy = int64(7)
+		columns   = []string{"id", "full_name", "phone_number", "email", "email_verified", "language", "status", "user_group", "total_login", "created_at", "updated_at", "updated_by", "attributes", "avatar_key"}
+	)
+
+	type args struct {
+		input GetUserDetailsByIdInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput GetUserDetailsByIdOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: GetUserDetailsByIdInput{
+					Id: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetUserDetailsByIdQuery)).
+					WithArgs(a.input.Id).
+					WillReturnError(sql.ErrNoRows)
+			},
+			wantOutput: GetUserDetailsByIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success, never updated",
+			args: args{
+				input: GetUserDetailsByIdInput{
+					Id: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetUserDetailsByIdQuery)).
+					WithArgs(a.input.Id).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "Jane Doe", "+6281234567890", "", false, "", USER_STATUS_ACTIVE, "default", 0, createdAt, nil, nil, []byte(`{}`), ""))
+			},
+			wantOutput: GetUserDetailsByIdOutput{
+				Id:          1,
+				FullName:    "Jane Doe",
+				PhoneNumber: "+6281234567890",
+				Status:      USER_STATUS_ACTIVE,
+				UserGroup:   "default",
+				CreatedAt:   createdAt,
+				Attributes:  []byte(`{}`),
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, updated",
+			args: args{
+				input: GetUserDetailsByIdInput{
+					Id: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetUserDetailsByIdQuery)).
+					WithArgs(a.input.Id).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "Jane Doe", "+6281234567890", "jane@example.com", true, "id", USER_STATUS_ACTIVE, "staff", 12, createdAt, updatedAt, updatedBy, []byte(`{"estate_id":7}`), "avatars/1/abc"))
+			},
+			wantOutput: GetUserDetailsByIdOutput{
+				Id:            1,
+				FullName:      "Jane Doe",
+				PhoneNumber:   "+6281234567890",
+				Email:         "jane@example.com",
+				EmailVerified: true,
+				Language:      "id",
+				Status:        USER_STATUS_ACTIVE,
+				UserGroup:     "staff",
+				TotalLogin:    12,
+				CreatedAt:     createdAt,
+				UpdatedAt:     &updatedAt,
+				UpdatedBy:     &updatedBy,
+				Attributes:    []byte(`{"estate_id":7}`),
+				AvatarKey:     "avatars/1/abc",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.GetUserDetailsById(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetUserDetailsById() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetUserDetailsById() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_SearchUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var (
+		createdFrom = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		createdTo   = time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+		createdAt   = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		columns     = []string{"id", "full_name", "phone_number", "email", "status", "created_at"}
+	)
+
+	type args struct {
+		input SearchUsersInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput SearchUsersOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error unknown sort",
+			args: args{
+				input: SearchUsersInput{
+					Sort:  "id",
+					Limit: 21,
+				},
+			},
+			mockFunc:   func(a args) {},
+			wantOutput: SearchUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Error when query",
+			args: args{
+				input: SearchUsersInput{
+					Sort:  USER_SORT_CREATED_AT_DESC,
+					Limit: 21,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(SearchUsersQueries[USER_SORT_CREATED_AT_DESC])).
+					WithArgs("", "", nil, nil, "", 21, nil, nil).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: SearchUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Error when scan",
+			args: args{
+				input: SearchUsersInput{
+					Sort:  USER_SORT_CREATED_AT_DESC,
+					Limit: 21,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(SearchUsersQueries[USER_SORT_CREATED_AT_DESC])).
+					WithArgs("", "", nil, nil, "", 21, nil, nil).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("x", "Jane Doe", "+6281234567890", "", USER_STATUS_ACTIVE, createdAt))
+			},
+			wantOutput: SearchUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success, first page",
+			args: args{
+				input: SearchUsersInput{
+					Sort:  USER_SORT_CREATED_AT_DESC,
+					Limit: 21,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(SearchUsersQueries[USER_SORT_CREATED_AT_DESC])).
+					WithArgs("", "", nil, nil, "", 21, nil, nil).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(2, "John Doe", "+6281234567891", "john@example.com", USER_STATUS_ACTIVE, createdAt).
+						AddRow(1, "Jane Doe", "+6281234567890", "", USER_STATUS_ACTIVE, createdAt))
+			},
+			wantOutput: SearchUsersOutput{
+				Users: []UserSummary{
+					{
+						Id:          2,
+						FullName:    "John Doe",
+						PhoneNumber: "+6281234567891",
+						Email:       "john@example.com",
+						Status:      USER_STATUS_ACTIVE,
+						CreatedAt:   createdAt,
+					},
+					{
+						Id:          1,
+						FullName:    "Jane Doe",
+						PhoneNumber: "+6281234567890",
+						Status:      USER_STATUS_ACTIVE,
+						CreatedAt:   createdAt,
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, filtered page after a user",
+			args: args{
+				input: SearchUsersInput{
+					PhonePrefix: "+62812",
+					Name:        "doe",
+					CreatedFrom: &createdFrom,
+					CreatedTo:   &createdTo,
+					Status:      USER_STATUS_ACTIVE,
+					Sort:        USER_SORT_FULL_NAME,
+					Limit:       2,
+					AfterId:     1,
+					AfterValue:  "Jane Doe",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(SearchUsersQueries[USER_SORT_FULL_NAME])).
+					WithArgs("+62812", "doe", createdFrom, createdTo, USER_STATUS_ACTIVE, 2, int64(1), "Jane Doe").
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			wantOutput: SearchUsersOutput{},
+			wantErr:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.SearchUsers(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.SearchUsers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.SearchUsers() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_GetUserStatusById(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	purgeAt := time.Date(2024, 2, 1, 3, 4, 5, 0, time.UTC)
+
+	type args struct {
+		input GetUserStatusByIdInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput GetUserStatusByIdOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: GetUserStatusByIdInput{
+					Id: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetUserStatusByIdQuery)).
+					WithArgs(a.input.Id).
+					WillReturnError(sql.ErrNoRows)
+			},
+			wantOutput: GetUserStatusByIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: GetUserStatusByIdInput{
+					Id: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetUserStatusByIdQuery)).
+					WithArgs(a.input.Id).
+					WillReturnRows(sqlmock.NewRows([]string{"status", "purge_at"}).
+						AddRow(USER_STATUS_SUSPENDED, nil))
+			},
+			wantOutput: GetUserStatusByIdOutput{
+				Status: USER_STATUS_SUSPENDED,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success, deleted by the user",
+			args: args{
+				input: GetUserStatusByIdInput{
+					Id: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetUserStatusByIdQuery)).
+					WithArgs(a.input.Id).
+					WillReturnRows(sqlmock.NewRows([]string{"status", "purge_at"}).
+						AddRow(USER_STATUS_DELETED, purgeAt))
+			},
+			wantOutput: GetUserStatusByIdOutput{
+				Status:  USER_STATUS_DELETED,
+				PurgeAt: &purgeAt,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.GetUserStatusById(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetUserStatusById() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetUserStatusById() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_UpdateUserStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input UpdateUserStatusInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput UpdateUserStatusOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: UpdateUserStatusInput{
+					Id:         1,
+					FromStatus: USER_STATUS_ACTIVE,
+					Status:     USER_STATUS_SUSPENDED,
+					Reason:     "spam",
+					ChangedBy:  2,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(UpdateUserStatusQuery)).
+					WithArgs(a.input.Id, a.input.FromStatus, a.input.Status, a.input.Reason, a.input.ChangedBy).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: UpdateUserStatusOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success, status changed meanwhile",
+			args: args{
+				input: UpdateUserStatusInput{
+					Id:         1,
+					FromStatus: USER_STATUS_ACTIVE,
+					Status:     USER_STATUS_SUSPENDED,
+					Reason:     "spam",
+					ChangedBy:  2,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(UpdateUserStatusQuery)).
+					WithArgs(a.input.Id, a.input.FromStatus, a.input.Status, a.input.Reason, a.input.ChangedBy).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			wantOutput: UpdateUserStatusOutput{},
+			wantErr:    false,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: UpdateUserStatusInput{
+					Id:         1,
+					FromStatus: USER_STATUS_ACTIVE,
+					Status:     USER_STATUS_SUSPENDED,
+					Reason:     "spam",
+					ChangedBy:  2,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(UpdateUserStatusQuery)).
+					WithArgs(a.input.Id, a.input.FromStatus, a.input.Status, a.input.Reason, a.input.ChangedBy).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).
+						AddRow(10))
+			},
+			wantOutput: UpdateUserStatusOutput{
+				IsUpdated: true,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.UpdateUserStatus(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.UpdateUserStatus() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.UpdateUserStatus() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_DeleteUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	purgeAt := time.Date(2024, 2, 1, 3, 4, 5, 0, time.UTC)
+
+	type args struct {
+		input DeleteUserInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput DeleteUserOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: DeleteUserInput{
+					Id:      1,
+					Reason:  "deleted by the user",
+					PurgeAt: purgeAt,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(DeleteUserQuery)).
+					WithArgs(a.input.Id, a.input.Reason, a.input.PurgeAt).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: DeleteUserOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success, not active",
+			args: args{
+				input: DeleteUserInput{
+					Id:      1,
+					Reason:  "deleted by the user",
+					PurgeAt: purgeAt,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(DeleteUserQuery)).
+					WithArgs(a.input.Id, a.input.Reason, a.input.PurgeAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			wantOutput: DeleteUserOutput{},
+			wantErr:    false,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: DeleteUserInput{
+					Id:      1,
+					Reason:  "deleted by the user",
+					PurgeAt: purgeAt,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(DeleteUserQuery)).
+					WithArgs(a.input.Id, a.input.Reason, a.input.PurgeAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).
+						AddRow(10))
+			},
+			wantOutput: DeleteUserOutput{
+				IsDeleted: true,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.DeleteUser(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.DeleteUser() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.DeleteUser() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_RestoreUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input RestoreUserInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput RestoreUserOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: RestoreUserInput{
+					Id:     1,
+					Reason: "restored by logging in",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(RestoreUserQuery)).
+					WithArgs(a.input.Id, a.input.Reason).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: RestoreUserOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success, already purged",
+			args: args{
+				input: RestoreUserInput{
+					Id:     1,
+					Reason: "restored by logging in",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(RestoreUserQuery)).
+					WithArgs(a.input.Id, a.input.Reason).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			wantOutput: RestoreUserOutput{},
+			wantErr:    false,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: RestoreUserInput{
+					Id:     1,
+					Reason: "restored by logging in",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(RestoreUserQuery)).
+					WithArgs(a.input.Id, a.input.Reason).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).
+						AddRow(10))
+			},
+			wantOutput: RestoreUserOutput{
+				IsRestored: true,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.RestoreUser(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.RestoreUser() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.RestoreUser() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_PurgeDeletedUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input PurgeDeletedUsersInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput PurgeDeletedUsersOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: PurgeDeletedUsersInput{
+					FullName: "Deleted user",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(PurgeDeletedUsersQuery)).
+					WithArgs(a.input.FullName).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: PurgeDeletedUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: PurgeDeletedUsersInput{
+					FullName: "Deleted user",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(PurgeDeletedUsersQuery)).
+					WithArgs(a.input.FullName).
+					WillReturnRows(sqlmock.NewRows([]string{"count", "avatar_keys"}).
+						AddRow(3, "{avatars/1/abc,avatars/2/def}"))
+			},
+			wantOutput: PurgeDeletedUsersOutput{
+				Count:      3,
+				AvatarKeys: []string{"avatars/1/abc", "avatars/2/def"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success without avatars",
+			args: args{
+				input: PurgeDeletedUsersInput{
+					FullName: "Deleted user",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(PurgeDeletedUsersQuery)).
+					WithArgs(a.input.FullName).
+					WillReturnRows(sqlmock.NewRows([]string{"count", "avatar_keys"}).
+						AddRow(0, "{}"))
+			},
+			wantOutput: PurgeDeletedUsersOutput{
+				AvatarKeys: []string{},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.PurgeDeletedUsers(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.PurgeDeletedUsers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.PurgeDeletedUsers() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_UpdateAvatarKeyById(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input UpdateAvatarKeyByIdInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput UpdateAvatarKeyByIdOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: UpdateAvatarKeyByIdInput{
+					Id:        50,
+					AvatarKey: "avatars/50/def",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(UpdateAvatarKeyByIdQuery)).
+					WithArgs(a.input.Id, a.input.AvatarKey).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: UpdateAvatarKeyByIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: UpdateAvatarKeyByIdInput{
+					Id:        50,
+					AvatarKey: "avatars/50/def",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(UpdateAvatarKeyByIdQuery)).
+					WithArgs(a.input.Id, a.input.AvatarKey).
+					WillReturnRows(sqlmock.NewRows([]string{"avatar_key"}).AddRow("avatars/50/abc"))
+			},
+			wantOutput: UpdateAvatarKeyByIdOutput{
+				PreviousAvatarKey: "avatars/50/abc",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.UpdateAvatarKeyById(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.UpdateAvatarKeyById() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.UpdateAvatarKeyById() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_GetUserStatusChangesByUserId(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var (
+		createdAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		changedBy = int64(2)
+		columns   = []string{"id", "from_status", "to_status", "reason", "changed_by", "created_at"}
+	)
+
+	type args struct {
+		input GetUserStatusChangesByUserIdInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput GetUserStatusChangesByUserIdOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: GetUserStatusChangesByUserIdInput{
+					UserId: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetUserStatusChangesByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: GetUserStatusChangesByUserIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Error when scan",
+			args: args{
+				input: GetUserStatusChangesByUserIdInput{
+					UserId: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetUserStatusChangesByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("x", USER_STATUS_ACTIVE, USER_STATUS_SUSPENDED, "spam", changedBy, createdAt))
+			},
+			wantOutput: GetUserStatusChangesByUserIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: GetUserStatusChangesByUserIdInput{
+					UserId: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetUserStatusChangesByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(11, USER_STATUS_SUSPENDED, USER_STATUS_ACTIVE, "appeal accepted", changedBy, createdAt).
+						AddRow(10, USER_STATUS_ACTIVE, USER_STATUS_SUSPENDED, "spam", nil, createdAt))
+			},
+			wantOutput: GetUserStatusChangesByUserIdOutput{
+				Changes: []UserStatusChange{
+					{
+						Id:         11,
+						FromStatus: USER_STATUS_SUSPENDED,
+						ToStatus:   USER_STATUS_ACTIVE,
+						Reason:     "appeal accepted",
+						ChangedBy:  &changedBy,
+						CreatedAt:  createdAt,
+					},
+					{
+						Id:         10,
+						FromStatus: USER_STATUS_ACTIVE,
+						ToStatus:   USER_STATUS_SUSPENDED,
+						Reason:     "spam",
+						CreatedAt:  createdAt,
+					},
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.GetUserStatusChangesByUserId(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetUserStatusChangesByUserId() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetUserStatusChangesByUserId() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_InsertImpersonation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	expiresAt := time.Date(2024, 1, 2, 3, 19, 5, 0, time.UTC)
+
+	type args struct {
+		input InsertImpersonationInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput InsertImpersonationOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: InsertImpersonationInput{
+					AdminId:   1,
+					UserId:    2,
+					Reason:    "ticket 42",
+					ExpiresAt: expiresAt,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(InsertImpersonationQuery)).
+					WithArgs(a.input.AdminId, a.input.UserId, a.input.Reason, a.input.ExpiresAt).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: InsertImpersonationOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: InsertImpersonationInput{
+					AdminId:   1,
+					UserId:    2,
+					Reason:    "ticket 42",
+					ExpiresAt: expiresAt,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(InsertImpersonationQuery)).
+					WithArgs(a.input.AdminId, a.input.UserId, a.input.Reason, a.input.ExpiresAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+			},
+			wantOutput: InsertImpersonationOutput{
+				Id: 9,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.InsertImpersonation(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.InsertImpersonation() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.InsertImpersonation() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_GetImpersonationsByUserId(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var (
+		createdAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		expiresAt = time.Date(2024, 1, 2, 3, 19, 5, 0, time.UTC)
+		adminId   = int64(1)
+		columns   = []string{"id", "admin_id", "reason", "created_at", "expires_at"}
+	)
+
+	type args struct {
+		input GetImpersonationsByUserIdInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput GetImpersonationsByUserIdOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: GetImpersonationsByUserIdInput{
+					UserId: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetImpersonationsByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: GetImpersonationsByUserIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Error when scan",
+			args: args{
+				input: GetImpersonationsByUserIdInput{
+					UserId: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetImpersonationsByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("x", adminId, "ticket 42", createdAt, expiresAt))
+			},
+			wantOutput: GetImpersonationsByUserIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: GetImpersonationsByUserIdInput{
+					UserId: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetImpersonationsByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(9, adminId, "ticket 42", createdAt, expiresAt).
+						AddRow(8, nil, "ticket 7", createdAt, expiresAt))
+			},
+			wantOutput: GetImpersonationsByUserIdOutput{
+				Impersonations: []Impersonation{
+					{
+						Id:        9,
+						AdminId:   &adminId,
+						Reason:    "ticket 42",
+						CreatedAt: createdAt,
+						ExpiresAt: expiresAt,
+					},
+					{
+						Id:        8,
+						Reason:    "ticket 7",
+						CreatedAt: createdAt,
+						ExpiresAt: expiresAt,
+					},
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.GetImpersonationsByUserId(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetImpersonationsByUserId() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetImpersonationsByUserId() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_InsertImpersonationUse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input InsertImpersonationUseInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		wantErr  bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: InsertImpersonationUseInput{
+					ImpersonationId: 9,
+					Method:          "PUT",
+					Path:            "/profile",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(InsertImpersonationUseQuery)).
+					WithArgs(a.input.ImpersonationId, a.input.Method, a.input.Path).
+					WillReturnError(errors.New("test"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: InsertImpersonationUseInput{
+					ImpersonationId: 9,
+					Method:          "PUT",
+					Path:            "/profile",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(InsertImpersonationUseQuery)).
+					WithArgs(a.input.ImpersonationId, a.input.Method, a.input.Path).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			if err := r.InsertImpersonationUse(context.Background(), tt.args.input); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.InsertImpersonationUse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_GetImpersonationUsesByUserId(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var (
+		createdAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		columns   = []string{"id", "impersonation_id", "method", "path", "created_at"}
+	)
+
+	type args struct {
+		input GetImpersonationUsesByUserIdInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput GetImpersonationUsesByUserIdOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: GetImpersonationUsesByUserIdInput{
+					UserId: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetImpersonationUsesByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: GetImpersonationUsesByUserIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Error when scan",
+			args: args{
+				input: GetImpersonationUsesByUserIdInput{
+					UserId: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetImpersonationUsesByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("x", 9, "GET", "/profile", createdAt))
+			},
+			wantOutput: GetImpersonationUsesByUserIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: GetImpersonationUsesByUserIdInput{
+					UserId: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetImpersonationUsesByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, 9, "GET", "/profile", createdAt).
+						AddRow(2, 9, "PUT", "/profile", createdAt))
+			},
+			wantOutput: GetImpersonationUsesByUserIdOutput{
+				Uses: []ImpersonationUse{
+					{
+						Id:              1,
+						ImpersonationId: 9,
+						Method:          "GET",
+						Path:            "/profile",
+						CreatedAt:       createdAt,
+					},
+					{
+						Id:              2,
+						ImpersonationId: 9,
+						Method:          "PUT",
+						Path:            "/profile",
+						CreatedAt:       createdAt,
+					},
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.GetImpersonationUsesByUserId(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetImpersonationUsesByUserId() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetImpersonationUsesByUserId() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_InsertLoginEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input InsertLoginEventInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		wantErr  bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: InsertLoginEventInput{
+					UserId: 2,
+					Method: "password",
+					IP:     "203.0.113.7",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(InsertLoginEventQuery)).
+					WithArgs(a.input.UserId, a.input.Method, a.input.IP).
+					WillReturnError(errors.New("test"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: InsertLoginEventInput{
+					UserId: 2,
+					Method: "password",
+					IP:     "203.0.113.7",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(InsertLoginEventQuery)).
+					WithArgs(a.input.UserId, a.input.Method, a.input.IP).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			if err := r.InsertLoginEvent(context.Background(), tt.args.input); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.InsertLoginEvent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_GetLoginEventsByUserId(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var (
+		createdAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		columns   = []string{"id", "method", "ip", "created_at"}
+	)
+
+	type args struct {
+		input GetLoginEventsByUserIdInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput GetLoginEventsByUserIdOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: GetLoginEventsByUserIdInput{
+					UserId: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetLoginEventsByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: GetLoginEventsByUserIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Error when scan",
+			args: args{
+				input: GetLoginEventsByUserIdInput{
+					UserId: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetLoginEventsByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("x", "password", "203.0.113.7", createdAt))
+			},
+			wantOutput: GetLoginEventsByUserIdOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: GetLoginEventsByUserIdInput{
+					UserId: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetLoginEventsByUserIdQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(2, "corp", "203.0.113.7", createdAt).
+						AddRow(1, "password", "", createdAt))
+			},
+			wantOutput: GetLoginEventsByUserIdOutput{
+				Events: []LoginEvent{
+					{
+						Id:        2,
+						Method:    "corp",
+						IP:        "203.0.113.7",
+						CreatedAt: createdAt,
+					},
+					{
+						Id:        1,
+						Method:    "password",
+						CreatedAt: createdAt,
+					},
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.GetLoginEventsByUserId(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetLoginEventsByUserId() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetLoginEventsByUserId() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_CountUserRecords(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input CountUserRecordsInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput CountUserRecordsOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: CountUserRecordsInput{
+					UserId: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(CountUserRecordsQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: CountUserRecordsOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: CountUserRecordsInput{
+					UserId: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(CountUserRecordsQuery)).
+					WithArgs(a.input.UserId).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+			},
+			wantOutput: CountUserRecordsOutput{
+				Count: 12,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.CountUserRecords(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.CountUserRecords() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.CountUserRecords() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_InsertDataExport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	expiresAt := time.Date(2024, 1, 3, 3, 4, 5, 0, time.UTC)
+
+	type args struct {
+		input InsertDataExportInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput InsertDataExportOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: InsertDataExportInput{
+					UserId:    2,
+					Format:    "json",
+					ExpiresAt: expiresAt,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(InsertDataExportQuery)).
+					WithArgs(a.input.UserId, a.input.Format, a.input.ExpiresAt).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: InsertDataExportOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: InsertDataExportInput{
+					UserId:    2,
+					Format:    "json",
+					ExpiresAt: expiresAt,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(InsertDataExportQuery)).
+					WithArgs(a.input.UserId, a.input.Format, a.input.ExpiresAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+			},
+			wantOutput: InsertDataExportOutput{
+				Id: 5,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.InsertDataExport(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.InsertDataExport() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.InsertDataExport() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_UpdateDataExport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input UpdateDataExportInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		wantErr  bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: UpdateDataExportInput{
+					Id:      5,
+					Status:  DATA_EXPORT_STATUS_READY,
+					Content: []byte("{}"),
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(UpdateDataExportQuery)).
+					WithArgs(a.input.Id, a.input.Status, a.input.Content).
+					WillReturnError(errors.New("test"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: UpdateDataExportInput{
+					Id:      5,
+					Status:  DATA_EXPORT_STATUS_READY,
+					Content: []byte("{}"),
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(UpdateDataExportQuery)).
+					WithArgs(a.input.Id, a.input.Status, a.input.Content).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			if err := r.UpdateDataExport(context.Background(), tt.args.input); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.UpdateDataExport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_GetDataExport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var (
+		createdAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		expiresAt = time.Date(2024, 1, 3, 3, 4, 5, 0, time.UTC)
+		columns   = []string{"id", "format", "status", "content", "created_at", "expires_at"}
+	)
+
+	type args struct {
+		input GetDataExportInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput GetDataExportOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: GetDataExportInput{
+					Id:     5,
+					UserId: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetDataExportQuery)).
+					WithArgs(a.input.Id, a.input.UserId).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: GetDataExportOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success pending",
+			args: args{
+				input: GetDataExportInput{
+					Id:     5,
+					UserId: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetDataExportQuery)).
+					WithArgs(a.input.Id, a.input.UserId).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(5, "json", DATA_EXPORT_STATUS_PENDING, nil, createdAt, expiresAt))
+			},
+			wantOutput: GetDataExportOutput{
+				Id:        5,
+				Format:    "json",
+				Status:    DATA_EXPORT_STATUS_PENDING,
+				CreatedAt: createdAt,
+				ExpiresAt: expiresAt,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Success ready",
+			args: args{
+				input: GetDataExportInput{
+					Id:     5,
+					UserId: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetDataExportQuery)).
+					WithArgs(a.input.Id, a.input.UserId).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(5, "json", DATA_EXPORT_STATUS_READY, []byte("{}"), createdAt, expiresAt))
+			},
+			wantOutput: GetDataExportOutput{
+				Id:        5,
+				Format:    "json",
+				Status:    DATA_EXPORT_STATUS_READY,
+				Content:   []byte("{}"),
+				CreatedAt: createdAt,
+				ExpiresAt: expiresAt,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.GetDataExport(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetDataExport() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetDataExport() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_GetOpenDataExport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	expiresAt := time.Date(2024, 1, 3, 3, 4, 5, 0, time.UTC)
+
+	type args struct {
+		input GetOpenDataExportInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput GetOpenDataExportOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: GetOpenDataExportInput{
+					UserId: 2,
+					Format: "json",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetOpenDataExportQuery)).
+					WithArgs(a.input.UserId, a.input.Format).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: GetOpenDataExportOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Error when none",
+			args: args{
+				input: GetOpenDataExportInput{
+					UserId: 2,
+					Format: "json",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetOpenDataExportQuery)).
+					WithArgs(a.input.UserId, a.input.Format).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "expires_at"}))
+			},
+			wantOutput: GetOpenDataExportOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: GetOpenDataExportInput{
+					UserId: 2,
+					Format: "json",
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(GetOpenDataExportQuery)).
+					WithArgs(a.input.UserId, a.input.Format).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "expires_at"}).
+						AddRow(5, expiresAt))
+			},
+			wantOutput: GetOpenDataExportOutput{
+				Id:        5,
+				ExpiresAt: expiresAt,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.GetOpenDataExport(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetOpenDataExport() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetOpenDataExport() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_DeleteExpiredDataExports(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input DeleteExpiredDataExportsInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput DeleteExpiredDataExportsOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: DeleteExpiredDataExportsInput{},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(DeleteExpiredDataExportsQuery)).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: DeleteExpiredDataExportsOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: DeleteExpiredDataExportsInput{},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectExec(regexp.QuoteMeta(DeleteExpiredDataExportsQuery)).
+					WillReturnResult(sqlmock.NewResult(0, 4))
+			},
+			wantOutput: DeleteExpiredDataExportsOutput{
+				Count: 4,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.DeleteExpiredDataExports(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.DeleteExpiredDataExports() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.DeleteExpiredDataExports() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_GetLatestAttributeSchema(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		wantOutput GetLatestAttributeSchemaOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when no schema",
+			mockFunc: func() {
+				mock.ExpectQuery(regexp.QuoteMeta(GetLatestAttributeSchemaQuery)).
+					WillReturnError(sql.ErrNoRows)
+			},
+			wantOutput: GetLatestAttributeSchemaOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			mockFunc: func() {
+				mock.ExpectQuery(regexp.QuoteMeta(GetLatestAttributeSchemaQuery)).
+					WillReturnRows(sqlmock.NewRows([]string{"schema"}).AddRow([]byte(`{"type":"object"}`)))
+			},
+			wantOutput: GetLatestAttributeSchemaOutput{
+				Schema: []byte(`{"type":"object"}`),
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc()
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.GetLatestAttributeSchema(context.Background(), GetLatestAttributeSchemaInput{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetLatestAttributeSchema() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetLatestAttributeSchema() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_InsertAttributeSchema(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input InsertAttributeSchemaInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		wantOutput InsertAttributeSchemaOutput
+		wantErr    bool
+	}{
+		{
+			name: "Error when query",
+			args: args{
+				input: InsertAttributeSchemaInput{
+					Schema:    []byte(`{"type":"object"}`),
+					CreatedBy: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(InsertAttributeSchemaQuery)).
+					WithArgs(`{"type":"object"}`, a.input.CreatedBy).
+					WillReturnError(errors.New("test"))
+			},
+			wantOutput: InsertAttributeSchemaOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: InsertAttributeSchemaInput{
+					Schema:    []byte(`{"type":"object"}`),
+					CreatedBy: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(InsertAttributeSchemaQuery)).
+					WithArgs(`{"type":"object"}`, a.input.CreatedBy).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+			},
+			wantOutput: InsertAttributeSchemaOutput{
+				Id: 3,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			gotOutput, err := r.InsertAttributeSchema(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.InsertAttributeSchema() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.InsertAttributeSchema() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_UpdateAttributesById(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	type args struct {
+		input UpdateAttributesByIdInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		wantErr  bool
+	}{
+		{
+			name: "Error when no user",
+			args: args{
+				input: UpdateAttributesByIdInput{
+					Id:         50,
+					Attributes: []byte(`{"estate_id":7}`),
+					UpdatedBy:  1,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(UpdateAttributesByIdQuery)).
+					WithArgs(a.input.Id, `{"estate_id":7}`, a.input.UpdatedBy).
+					WillReturnError(sql.ErrNoRows)
+			},
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			args: args{
+				input: UpdateAttributesByIdInput{
+					Id:         50,
+					Attributes: []byte(`{"estate_id":7}`),
+					UpdatedBy:  1,
+				},
+			},
+			mockFunc: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta(UpdateAttributesByIdQuery)).
+					WithArgs(a.input.Id, `{"estate_id":7}`, a.input.UpdatedBy).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(50))
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			r := &Repository{
+				Db: db,
+			}
+			if err := r.UpdateAttributesById(context.Background(), tt.args.input); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.UpdateAttributesById() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -8,8 +8,50 @@ import "context"
 
 type RepositoryInterface interface {
 	InsertNewUser(ctx context.Context, input InsertNewUserInput) (InsertNewUserOutput, error)
-	GetPasswordByPhoneNumber(ctx context.Context, input GetPasswordByPhoneNumberInput) (output GetPasswordByPhoneNumberOutput, err error)
+	GetPasswordByIdentity(ctx context.Context, input GetPasswordByIdentityInput) (output GetPasswordByIdentityOutput, err error)
 	GetUserDataById(ctx context.Context, input GetUserDataByIdInput) (output GetUserDataByIdOutput, err error)
 	UpdateUserData(ctx context.Context, input UpdateUserDataInput) (UpdateUserDataOutput, error)
+	PatchUserData(ctx context.Context, input PatchUserDataInput) (PatchUserDataOutput, error)
 	UpdateTotalLoginById(ctx context.Context, input UpdateTotalLoginByIdInput) (err error)
+	UpdatePasswordById(ctx context.Context, input UpdatePasswordByIdInput) (err error)
+	GetPasswordById(ctx context.Context, input GetPasswordByIdInput) (output GetPasswordByIdOutput, err error)
+	SetPasswordById(ctx context.Context, input SetPasswordByIdInput) (err error)
+	GetPasswordHistoryByUserId(ctx context.Context, input GetPasswordHistoryByUserIdInput) (output GetPasswordHistoryByUserIdOutput, err error)
+	DeleteOldPasswordHistory(ctx context.Context, input DeleteOldPasswordHistoryInput) (err error)
+	UpsertEmailVerification(ctx context.Context, input UpsertEmailVerificationInput) (err error)
+	GetEmailVerificationByTokenHash(ctx context.Context, input GetEmailVerificationByTokenHashInput) (output GetEmailVerificationOutput, err error)
+	GetEmailVerificationByUserId(ctx context.Context, input GetEmailVerificationByUserIdInput) (output GetEmailVerificationOutput, err error)
+	IncrementEmailVerificationAttempts(ctx context.Context, input IncrementEmailVerificationAttemptsInput) (err error)
+	VerifyEmail(ctx context.Context, input VerifyEmailInput) (output VerifyEmailOutput, err error)
+	InsertIdentity(ctx context.Context, input InsertIdentityInput) (InsertIdentityOutput, error)
+	GetIdentitiesByUserId(ctx context.Context, input GetIdentitiesByUserIdInput) (output GetIdentitiesByUserIdOutput, err error)
+	DeleteIdentity(ctx context.Context, input DeleteIdentityInput) (output DeleteIdentityOutput, err error)
+	GetUserIdByIdentity(ctx context.Context, input GetUserIdByIdentityInput) (output GetUserIdByIdentityOutput, err error)
+	InsertExternalUser(ctx context.Context, input InsertExternalUserInput) (output InsertExternalUserOutput, err error)
+	GetRolesByUserId(ctx context.Context, input GetRolesByUserIdInput) (output GetRolesByUserIdOutput, err error)
+	HasPermission(ctx context.Context, input HasPermissionInput) (output HasPermissionOutput, err error)
+	GetUserDetailsById(ctx context.Context, input GetUserDetailsByIdInput) (output GetUserDetailsByIdOutput, err error)
+	SearchUsers(ctx context.Context, input SearchUsersInput) (output SearchUsersOutput, err error)
+	GetUserStatusById(ctx context.Context, input GetUserStatusByIdInput) (output GetUserStatusByIdOutput, err error)
+	UpdateUserStatus(ctx context.Context, input UpdateUserStatusInput) (output UpdateUserStatusOutput, err error)
+	DeleteUser(ctx context.Context, input DeleteUserInput) (output DeleteUserOutput, err error)
+	RestoreUser(ctx context.Context, input RestoreUserInput) (output RestoreUserOutput, err error)
+	PurgeDeletedUsers(ctx context.Context, input PurgeDeletedUsersInput) (output PurgeDeletedUsersOutput, err error)
+	GetUserStatusChangesByUserId(ctx context.Context, input GetUserStatusChangesByUserIdInput) (output GetUserStatusChangesByUserIdOutput, err error)
+	InsertImpersonation(ctx context.Context, input InsertImpersonationInput) (output InsertImpersonationOutput, err error)
+	UpdateAvatarKeyById(ctx context.Context, input UpdateAvatarKeyByIdInput) (output UpdateAvatarKeyByIdOutput, err error)
+	GetImpersonationsByUserId(ctx context.Context, input GetImpersonationsByUserIdInput) (output GetImpersonationsByUserIdOutput, err error)
+	InsertImpersonationUse(ctx context.Context, input InsertImpersonationUseInput) (err error)
+	GetImpersonationUsesByUserId(ctx context.Context, input GetImpersonationUsesByUserIdInput) (output GetImpersonationUsesByUserIdOutput, err error)
+	InsertLoginEvent(ctx context.Context, input InsertLoginEventInput) (err error)
+	GetLoginEventsByUserId(ctx context.Context, input GetLoginEventsByUserIdInput) (output GetLoginEventsByUserIdOutput, err error)
+	CountUserRecords(ctx context.Context, input CountUserRecordsInput) (output CountUserRecordsOutput, err error)
+	InsertDataExport(ctx context.Context, input InsertDataExportInput) (output InsertDataExportOutput, err error)
+	UpdateDataExport(ctx context.Context, input UpdateDataExportInput) (err error)
+	GetDataExport(ctx context.Context, input GetDataExportInput) (output GetDataExportOutput, err error)
+	GetOpenDataExport(ctx context.Context, input GetOpenDataExportInput) (output GetOpenDataExportOutput, err error)
+	DeleteExpiredDataExports(ctx context.Context, input DeleteExpiredDataExportsInput) (output DeleteExpiredDataExportsOutput, err error)
+	GetLatestAttributeSchema(ctx context.Context, input GetLatestAttributeSchemaInput) (output GetLatestAttributeSchemaOutput, err error)
+	InsertAttributeSchema(ctx context.Context, input InsertAttributeSchemaInput) (output InsertAttributeSchemaOutput, err error)
+	UpdateAttributesById(ctx context.Context, input UpdateAttributesByIdInput) (err error)
 }
@@ -34,19 +34,273 @@ func (m *MockRepositoryInterface) EXPECT() *MockRepositoryInterfaceMockRecorder
 	return m.recorder
 }
 
-// GetPasswordByPhoneNumber mocks base method.
-func (m *MockRepositoryInterface) GetPasswordByPhoneNumber(ctx context.Context, input GetPasswordByPhoneNumberInput) (GetPasswordByPhoneNumberOutput, error) {
+// CountUserRecords mocks base method.
+func (m *MockRepositoryInterface) CountUserRecords(ctx context.Context, input CountUserRecordsInput) (CountUserRecordsOutput, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPasswordByPhoneNumber", ctx, input)
-	ret0, _ := ret[0].(GetPasswordByPhoneNumberOutput)
+	ret := m.ctrl.Call(m, "CountUserRecords", ctx, input)
+	ret0, _ := ret[0].(CountUserRecordsOutput)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetPasswordByPhoneNumber indicates an expected call of GetPasswordByPhoneNumber.
-func (mr *MockRepositoryInterfaceMockRecorder) GetPasswordByPhoneNumber(ctx, input interface{}) *gomock.Call {
+// CountUserRecords indicates an expected call of CountUserRecords.
+func (mr *MockRepositoryInterfaceMockRecorder) CountUserRecords(ctx, input interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordByPhoneNumber", reflect.TypeOf((*MockRepositoryInterface)(nil).GetPasswordByPhoneNumber), ctx, input)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountUserRecords", reflect.TypeOf((*MockRepositoryInterface)(nil).CountUserRecords), ctx, input)
+}
+
+// DeleteExpiredDataExports mocks base method.
+func (m *MockRepositoryInterface) DeleteExpiredDataExports(ctx context.Context, input DeleteExpiredDataExportsInput) (DeleteExpiredDataExportsOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredDataExports", ctx, input)
+	ret0, _ := ret[0].(DeleteExpiredDataExportsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredDataExports indicates an expected call of DeleteExpiredDataExports.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteExpiredDataExports(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredDataExports", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteExpiredDataExports), ctx, input)
+}
+
+// DeleteIdentity mocks base method.
+func (m *MockRepositoryInterface) DeleteIdentity(ctx context.Context, input DeleteIdentityInput) (DeleteIdentityOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdentity", ctx, input)
+	ret0, _ := ret[0].(DeleteIdentityOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteIdentity indicates an expected call of DeleteIdentity.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteIdentity(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdentity", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteIdentity), ctx, input)
+}
+
+// DeleteOldPasswordHistory mocks base method.
+func (m *MockRepositoryInterface) DeleteOldPasswordHistory(ctx context.Context, input DeleteOldPasswordHistoryInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOldPasswordHistory", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOldPasswordHistory indicates an expected call of DeleteOldPasswordHistory.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteOldPasswordHistory(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOldPasswordHistory", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteOldPasswordHistory), ctx, input)
+}
+
+// DeleteUser mocks base method.
+func (m *MockRepositoryInterface) DeleteUser(ctx context.Context, input DeleteUserInput) (DeleteUserOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, input)
+	ret0, _ := ret[0].(DeleteUserOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteUser(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteUser), ctx, input)
+}
+
+// GetDataExport mocks base method.
+func (m *MockRepositoryInterface) GetDataExport(ctx context.Context, input GetDataExportInput) (GetDataExportOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDataExport", ctx, input)
+	ret0, _ := ret[0].(GetDataExportOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDataExport indicates an expected call of GetDataExport.
+func (mr *MockRepositoryInterfaceMockRecorder) GetDataExport(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDataExport", reflect.TypeOf((*MockRepositoryInterface)(nil).GetDataExport), ctx, input)
+}
+
+// GetEmailVerificationByTokenHash mocks base method.
+func (m *MockRepositoryInterface) GetEmailVerificationByTokenHash(ctx context.Context, input GetEmailVerificationByTokenHashInput) (GetEmailVerificationOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEmailVerificationByTokenHash", ctx, input)
+	ret0, _ := ret[0].(GetEmailVerificationOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEmailVerificationByTokenHash indicates an expected call of GetEmailVerificationByTokenHash.
+func (mr *MockRepositoryInterfaceMockRecorder) GetEmailVerificationByTokenHash(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmailVerificationByTokenHash", reflect.TypeOf((*MockRepositoryInterface)(nil).GetEmailVerificationByTokenHash), ctx, input)
+}
+
+// GetEmailVerificationByUserId mocks base method.
+func (m *MockRepositoryInterface) GetEmailVerificationByUserId(ctx context.Context, input GetEmailVerificationByUserIdInput) (GetEmailVerificationOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEmailVerificationByUserId", ctx, input)
+	ret0, _ := ret[0].(GetEmailVerificationOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEmailVerificationByUserId indicates an expected call of GetEmailVerificationByUserId.
+func (mr *MockRepositoryInterfaceMockRecorder) GetEmailVerificationByUserId(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmailVerificationByUserId", reflect.TypeOf((*MockRepositoryInterface)(nil).GetEmailVerificationByUserId), ctx, input)
+}
+
+// GetIdentitiesByUserId mocks base method.
+func (m *MockRepositoryInterface) GetIdentitiesByUserId(ctx context.Context, input GetIdentitiesByUserIdInput) (GetIdentitiesByUserIdOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdentitiesByUserId", ctx, input)
+	ret0, _ := ret[0].(GetIdentitiesByUserIdOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdentitiesByUserId indicates an expected call of GetIdentitiesByUserId.
+func (mr *MockRepositoryInterfaceMockRecorder) GetIdentitiesByUserId(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdentitiesByUserId", reflect.TypeOf((*MockRepositoryInterface)(nil).GetIdentitiesByUserId), ctx, input)
+}
+
+// GetImpersonationUsesByUserId mocks base method.
+func (m *MockRepositoryInterface) GetImpersonationUsesByUserId(ctx context.Context, input GetImpersonationUsesByUserIdInput) (GetImpersonationUsesByUserIdOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImpersonationUsesByUserId", ctx, input)
+	ret0, _ := ret[0].(GetImpersonationUsesByUserIdOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImpersonationUsesByUserId indicates an expected call of GetImpersonationUsesByUserId.
+func (mr *MockRepositoryInterfaceMockRecorder) GetImpersonationUsesByUserId(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImpersonationUsesByUserId", reflect.TypeOf((*MockRepositoryInterface)(nil).GetImpersonationUsesByUserId), ctx, input)
+}
+
+// GetImpersonationsByUserId mocks base method.
+func (m *MockRepositoryInterface) GetImpersonationsByUserId(ctx context.Context, input GetImpersonationsByUserIdInput) (GetImpersonationsByUserIdOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImpersonationsByUserId", ctx, input)
+	ret0, _ := ret[0].(GetImpersonationsByUserIdOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImpersonationsByUserId indicates an expected call of GetImpersonationsByUserId.
+func (mr *MockRepositoryInterfaceMockRecorder) GetImpersonationsByUserId(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImpersonationsByUserId", reflect.TypeOf((*MockRepositoryInterface)(nil).GetImpersonationsByUserId), ctx, input)
+}
+
+// GetLatestAttributeSchema mocks base method.
+func (m *MockRepositoryInterface) GetLatestAttributeSchema(ctx context.Context, input GetLatestAttributeSchemaInput) (GetLatestAttributeSchemaOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatestAttributeSchema", ctx, input)
+	ret0, _ := ret[0].(GetLatestAttributeSchemaOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatestAttributeSchema indicates an expected call of GetLatestAttributeSchema.
+func (mr *MockRepositoryInterfaceMockRecorder) GetLatestAttributeSchema(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestAttributeSchema", reflect.TypeOf((*MockRepositoryInterface)(nil).GetLatestAttributeSchema), ctx, input)
+}
+
+// GetLoginEventsByUserId mocks base method.
+func (m *MockRepositoryInterface) GetLoginEventsByUserId(ctx context.Context, input GetLoginEventsByUserIdInput) (GetLoginEventsByUserIdOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginEventsByUserId", ctx, input)
+	ret0, _ := ret[0].(GetLoginEventsByUserIdOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginEventsByUserId indicates an expected call of GetLoginEventsByUserId.
+func (mr *MockRepositoryInterfaceMockRecorder) GetLoginEventsByUserId(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginEventsByUserId", reflect.TypeOf((*MockRepositoryInterface)(nil).GetLoginEventsByUserId), ctx, input)
+}
+
+// GetOpenDataExport mocks base method.
+func (m *MockRepositoryInterface) GetOpenDataExport(ctx context.Context, input GetOpenDataExportInput) (GetOpenDataExportOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOpenDataExport", ctx, input)
+	ret0, _ := ret[0].(GetOpenDataExportOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOpenDataExport indicates an expected call of GetOpenDataExport.
+func (mr *MockRepositoryInterfaceMockRecorder) GetOpenDataExport(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOpenDataExport", reflect.TypeOf((*MockRepositoryInterface)(nil).GetOpenDataExport), ctx, input)
+}
+
+// GetPasswordById mocks base method.
+func (m *MockRepositoryInterface) GetPasswordById(ctx context.Context, input GetPasswordByIdInput) (GetPasswordByIdOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPasswordById", ctx, input)
+	ret0, _ := ret[0].(GetPasswordByIdOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPasswordById indicates an expected call of GetPasswordById.
+func (mr *MockRepositoryInterfaceMockRecorder) GetPasswordById(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordById", reflect.TypeOf((*MockRepositoryInterface)(nil).GetPasswordById), ctx, input)
+}
+
+// GetPasswordByIdentity mocks base method.
+func (m *MockRepositoryInterface) GetPasswordByIdentity(ctx context.Context, input GetPasswordByIdentityInput) (GetPasswordByIdentityOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPasswordByIdentity", ctx, input)
+	ret0, _ := ret[0].(GetPasswordByIdentityOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPasswordByIdentity indicates an expected call of GetPasswordByIdentity.
+func (mr *MockRepositoryInterfaceMockRecorder) GetPasswordByIdentity(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordByIdentity", reflect.TypeOf((*MockRepositoryInterface)(nil).GetPasswordByIdentity), ctx, input)
+}
+
+// GetPasswordHistoryByUserId mocks base method.
+func (m *MockRepositoryInterface) GetPasswordHistoryByUserId(ctx context.Context, input GetPasswordHistoryByUserIdInput) (GetPasswordHistoryByUserIdOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPasswordHistoryByUserId", ctx, input)
+	ret0, _ := ret[0].(GetPasswordHistoryByUserIdOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPasswordHistoryByUserId indicates an expected call of GetPasswordHistoryByUserId.
+func (mr *MockRepositoryInterfaceMockRecorder) GetPasswordHistoryByUserId(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordHistoryByUserId", reflect.TypeOf((*MockRepositoryInterface)(nil).GetPasswordHistoryByUserId), ctx, input)
+}
+
+// GetRolesByUserId mocks base method.
+func (m *MockRepositoryInterface) GetRolesByUserId(ctx context.Context, input GetRolesByUserIdInput) (GetRolesByUserIdOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRolesByUserId", ctx, input)
+	ret0, _ := ret[0].(GetRolesByUserIdOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRolesByUserId indicates an expected call of GetRolesByUserId.
+func (mr *MockRepositoryInterfaceMockRecorder) GetRolesByUserId(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRolesByUserId", reflect.TypeOf((*MockRepositoryInterface)(nil).GetRolesByUserId), ctx, input)
 }
 
 // GetUserDataById mocks base method.
@@ -64,6 +318,198 @@ func (mr *MockRepositoryInterfaceMockRecorder) GetUserDataById(ctx, input interf
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserDataById", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUserDataById), ctx, input)
 }
 
+// GetUserDetailsById mocks base method.
+func (m *MockRepositoryInterface) GetUserDetailsById(ctx context.Context, input GetUserDetailsByIdInput) (GetUserDetailsByIdOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserDetailsById", ctx, input)
+	ret0, _ := ret[0].(GetUserDetailsByIdOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserDetailsById indicates an expected call of GetUserDetailsById.
+func (mr *MockRepositoryInterfaceMockRecorder) GetUserDetailsById(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserDetailsById", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUserDetailsById), ctx, input)
+}
+
+// GetUserIdByIdentity mocks base method.
+func (m *MockRepositoryInterface) GetUserIdByIdentity(ctx context.Context, input GetUserIdByIdentityInput) (GetUserIdByIdentityOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserIdByIdentity", ctx, input)
+	ret0, _ := ret[0].(GetUserIdByIdentityOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserIdByIdentity indicates an expected call of GetUserIdByIdentity.
+func (mr *MockRepositoryInterfaceMockRecorder) GetUserIdByIdentity(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserIdByIdentity", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUserIdByIdentity), ctx, input)
+}
+
+// GetUserStatusById mocks base method.
+func (m *MockRepositoryInterface) GetUserStatusById(ctx context.Context, input GetUserStatusByIdInput) (GetUserStatusByIdOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserStatusById", ctx, input)
+	ret0, _ := ret[0].(GetUserStatusByIdOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserStatusById indicates an expected call of GetUserStatusById.
+func (mr *MockRepositoryInterfaceMockRecorder) GetUserStatusById(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserStatusById", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUserStatusById), ctx, input)
+}
+
+// GetUserStatusChangesByUserId mocks base method.
+func (m *MockRepositoryInterface) GetUserStatusChangesByUserId(ctx context.Context, input GetUserStatusChangesByUserIdInput) (GetUserStatusChangesByUserIdOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserStatusChangesByUserId", ctx, input)
+	ret0, _ := ret[0].(GetUserStatusChangesByUserIdOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserStatusChangesByUserId indicates an expected call of GetUserStatusChangesByUserId.
+func (mr *MockRepositoryInterfaceMockRecorder) GetUserStatusChangesByUserId(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserStatusChangesByUserId", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUserStatusChangesByUserId), ctx, input)
+}
+
+// HasPermission mocks base method.
+func (m *MockRepositoryInterface) HasPermission(ctx context.Context, input HasPermissionInput) (HasPermissionOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasPermission", ctx, input)
+	ret0, _ := ret[0].(HasPermissionOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HasPermission indicates an expected call of HasPermission.
+func (mr *MockRepositoryInterfaceMockRecorder) HasPermission(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasPermission", reflect.TypeOf((*MockRepositoryInterface)(nil).HasPermission), ctx, input)
+}
+
+// IncrementEmailVerificationAttempts mocks base method.
+func (m *MockRepositoryInterface) IncrementEmailVerificationAttempts(ctx context.Context, input IncrementEmailVerificationAttemptsInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementEmailVerificationAttempts", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrementEmailVerificationAttempts indicates an expected call of IncrementEmailVerificationAttempts.
+func (mr *MockRepositoryInterfaceMockRecorder) IncrementEmailVerificationAttempts(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementEmailVerificationAttempts", reflect.TypeOf((*MockRepositoryInterface)(nil).IncrementEmailVerificationAttempts), ctx, input)
+}
+
+// InsertAttributeSchema mocks base method.
+func (m *MockRepositoryInterface) InsertAttributeSchema(ctx context.Context, input InsertAttributeSchemaInput) (InsertAttributeSchemaOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertAttributeSchema", ctx, input)
+	ret0, _ := ret[0].(InsertAttributeSchemaOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertAttributeSchema indicates an expected call of InsertAttributeSchema.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertAttributeSchema(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertAttributeSchema", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertAttributeSchema), ctx, input)
+}
+
+// InsertDataExport mocks base method.
+func (m *MockRepositoryInterface) InsertDataExport(ctx context.Context, input InsertDataExportInput) (InsertDataExportOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertDataExport", ctx, input)
+	ret0, _ := ret[0].(InsertDataExportOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertDataExport indicates an expected call of InsertDataExport.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertDataExport(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertDataExport", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertDataExport), ctx, input)
+}
+
+// InsertExternalUser mocks base method.
+func (m *MockRepositoryInterface) InsertExternalUser(ctx context.Context, input InsertExternalUserInput) (InsertExternalUserOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertExternalUser", ctx, input)
+	ret0, _ := ret[0].(InsertExternalUserOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertExternalUser indicates an expected call of InsertExternalUser.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertExternalUser(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertExternalUser", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertExternalUser), ctx, input)
+}
+
+// InsertIdentity mocks base method.
+func (m *MockRepositoryInterface) InsertIdentity(ctx context.Context, input InsertIdentityInput) (InsertIdentityOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertIdentity", ctx, input)
+	ret0, _ := ret[0].(InsertIdentityOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertIdentity indicates an expected call of InsertIdentity.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertIdentity(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertIdentity", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertIdentity), ctx, input)
+}
+
+// InsertImpersonation mocks base method.
+func (m *MockRepositoryInterface) InsertImpersonation(ctx context.Context, input InsertImpersonationInput) (InsertImpersonationOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertImpersonation", ctx, input)
+	ret0, _ := ret[0].(InsertImpersonationOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertImpersonation indicates an expected call of InsertImpersonation.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertImpersonation(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertImpersonation", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertImpersonation), ctx, input)
+}
+
+// InsertImpersonationUse mocks base method.
+func (m *MockRepositoryInterface) InsertImpersonationUse(ctx context.Context, input InsertImpersonationUseInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertImpersonationUse", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertImpersonationUse indicates an expected call of InsertImpersonationUse.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertImpersonationUse(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertImpersonationUse", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertImpersonationUse), ctx, input)
+}
+
+// InsertLoginEvent mocks base method.
+func (m *MockRepositoryInterface) InsertLoginEvent(ctx context.Context, input InsertLoginEventInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertLoginEvent", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertLoginEvent indicates an expected call of InsertLoginEvent.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertLoginEvent(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertLoginEvent", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertLoginEvent), ctx, input)
+}
+
 // InsertNewUser mocks base method.
 func (m *MockRepositoryInterface) InsertNewUser(ctx context.Context, input InsertNewUserInput) (InsertNewUserOutput, error) {
 	m.ctrl.T.Helper()
@@ -79,6 +525,137 @@ func (mr *MockRepositoryInterfaceMockRecorder) InsertNewUser(ctx, input interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertNewUser", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertNewUser), ctx, input)
 }
 
+// PatchUserData mocks base method.
+func (m *MockRepositoryInterface) PatchUserData(ctx context.Context, input PatchUserDataInput) (PatchUserDataOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchUserData", ctx, input)
+	ret0, _ := ret[0].(PatchUserDataOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PatchUserData indicates an expected call of PatchUserData.
+func (mr *MockRepositoryInterfaceMockRecorder) PatchUserData(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchUserData", reflect.TypeOf((*MockRepositoryInterface)(nil).PatchUserData), ctx, input)
+}
+
+// PurgeDeletedUsers mocks base method.
+func (m *MockRepositoryInterface) PurgeDeletedUsers(ctx context.Context, input PurgeDeletedUsersInput) (PurgeDeletedUsersOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeletedUsers", ctx, input)
+	ret0, _ := ret[0].(PurgeDeletedUsersOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeletedUsers indicates an expected call of PurgeDeletedUsers.
+func (mr *MockRepositoryInterfaceMockRecorder) PurgeDeletedUsers(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeletedUsers", reflect.TypeOf((*MockRepositoryInterface)(nil).PurgeDeletedUsers), ctx, input)
+}
+
+// RestoreUser mocks base method.
+func (m *MockRepositoryInterface) RestoreUser(ctx context.Context, input RestoreUserInput) (RestoreUserOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreUser", ctx, input)
+	ret0, _ := ret[0].(RestoreUserOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreUser indicates an expected call of RestoreUser.
+func (mr *MockRepositoryInterfaceMockRecorder) RestoreUser(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreUser", reflect.TypeOf((*MockRepositoryInterface)(nil).RestoreUser), ctx, input)
+}
+
+// SearchUsers mocks base method.
+func (m *MockRepositoryInterface) SearchUsers(ctx context.Context, input SearchUsersInput) (SearchUsersOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchUsers", ctx, input)
+	ret0, _ := ret[0].(SearchUsersOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchUsers indicates an expected call of SearchUsers.
+func (mr *MockRepositoryInterfaceMockRecorder) SearchUsers(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsers", reflect.TypeOf((*MockRepositoryInterface)(nil).SearchUsers), ctx, input)
+}
+
+// SetPasswordById mocks base method.
+func (m *MockRepositoryInterface) SetPasswordById(ctx context.Context, input SetPasswordByIdInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPasswordById", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPasswordById indicates an expected call of SetPasswordById.
+func (mr *MockRepositoryInterfaceMockRecorder) SetPasswordById(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPasswordById", reflect.TypeOf((*MockRepositoryInterface)(nil).SetPasswordById), ctx, input)
+}
+
+// UpdateAttributesById mocks base method.
+func (m *MockRepositoryInterface) UpdateAttributesById(ctx context.Context, input UpdateAttributesByIdInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAttributesById", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAttributesById indicates an expected call of UpdateAttributesById.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateAttributesById(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAttributesById", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateAttributesById), ctx, input)
+}
+
+// UpdateAvatarKeyById mocks base method.
+func (m *MockRepositoryInterface) UpdateAvatarKeyById(ctx context.Context, input UpdateAvatarKeyByIdInput) (UpdateAvatarKeyByIdOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAvatarKeyById", ctx, input)
+	ret0, _ := ret[0].(UpdateAvatarKeyByIdOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAvatarKeyById indicates an expected call of UpdateAvatarKeyById.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateAvatarKeyById(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAvatarKeyById", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateAvatarKeyById), ctx, input)
+}
+
+// UpdateDataExport mocks base method.
+func (m *MockRepositoryInterface) UpdateDataExport(ctx context.Context, input UpdateDataExportInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDataExport", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDataExport indicates an expected call of UpdateDataExport.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateDataExport(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDataExport", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateDataExport), ctx, input)
+}
+
+// UpdatePasswordById mocks base method.
+func (m *MockRepositoryInterface) UpdatePasswordById(ctx context.Context, input UpdatePasswordByIdInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePasswordById", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePasswordById indicates an expected call of UpdatePasswordById.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdatePasswordById(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePasswordById", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdatePasswordById), ctx, input)
+}
+
 // UpdateTotalLoginById mocks base method.
 func (m *MockRepositoryInterface) UpdateTotalLoginById(ctx context.Context, input UpdateTotalLoginByIdInput) error {
 	m.ctrl.T.Helper()
@@ -107,3 +684,47 @@ func (mr *MockRepositoryInterfaceMockRecorder) UpdateUserData(ctx, input interfa
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserData", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateUserData), ctx, input)
 }
+
+// UpdateUserStatus mocks base method.
+func (m *MockRepositoryInterface) UpdateUserStatus(ctx context.Context, input UpdateUserStatusInput) (UpdateUserStatusOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserStatus", ctx, input)
+	ret0, _ := ret[0].(UpdateUserStatusOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserStatus indicates an expected call of UpdateUserStatus.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateUserStatus(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserStatus", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateUserStatus), ctx, input)
+}
+
+// UpsertEmailVerification mocks base method.
+func (m *MockRepositoryInterface) UpsertEmailVerification(ctx context.Context, input UpsertEmailVerificationInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertEmailVerification", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpsertEmailVerification indicates an expected call of UpsertEmailVerification.
+func (mr *MockRepositoryInterfaceMockRecorder) UpsertEmailVerification(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertEmailVerification", reflect.TypeOf((*MockRepositoryInterface)(nil).UpsertEmailVerification), ctx, input)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockRepositoryInterface) VerifyEmail(ctx context.Context, input VerifyEmailInput) (VerifyEmailOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", ctx, input)
+	ret0, _ := ret[0].(VerifyEmailOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockRepositoryInterfaceMockRecorder) VerifyEmail(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockRepositoryInterface)(nil).VerifyEmail), ctx, input)
+}
@@ -1,20 +1,410 @@
 package repository
 
+import "fmt"
+
 var (
-	InsertNewUserQuery = `INSERT INTO users(phone_number, full_name, password) values ($1, $2, $3) returning id`
+	InsertNewUserQuery = `WITH new_user AS (
+		INSERT INTO users(full_name, password) values ($2, $3) returning id, password
+	), new_identities AS (
+		INSERT INTO identities(user_id, type, identifier, is_primary)
+		SELECT id, 'phone', $1, true FROM new_user
+		UNION ALL
+		SELECT id, 'email', $4, true FROM new_user WHERE $4 <> ''
+	)
+	INSERT INTO password_history(user_id, password) SELECT id, password FROM new_user returning user_id`
 
-	UpdateUserDataQuery = `UPDATE users 
-	set phone_number = $2, 
-	full_name = $3,
-	updated_at = now(),
-	updated_by = $4
-	WHERE id = $1`
+	UpdateUserDataQuery = `WITH updated_user AS (
+		UPDATE users
+		set full_name = $3,
+		language = nullif($5, ''),
+		attributes = coalesce($7::jsonb, attributes),
+		updated_at = now(),
+		updated_by = $4
+		WHERE id = $1
+	), phone_identity AS (
+		INSERT INTO identities(user_id, type, identifier, is_primary)
+		SELECT $1, 'phone', $2, true WHERE $2 <> ''
+		ON CONFLICT (user_id, type) WHERE is_primary DO UPDATE
+		set identifier = excluded.identifier
+	), removed_email_identity AS (
+		DELETE FROM identities
+		WHERE user_id = $1 AND type = 'email' AND is_primary AND $6 = ''
+	)
+	INSERT INTO identities(user_id, type, identifier, is_primary)
+	SELECT $1, 'email', $6, true WHERE $6 <> ''
+	ON CONFLICT (user_id, type) WHERE is_primary DO UPDATE
+	set identifier = excluded.identifier,
+	verified_at = CASE WHEN lower(identities.identifier) = lower(excluded.identifier) THEN identities.verified_at END`
+
+	// a null parameter leaves its field unchanged, an empty language or email
+	// removes it. The final select still sees the email from before the update
+	PatchUserDataQuery = `WITH updated_user AS (
+		UPDATE users
+		SET full_name = coalesce($2::varchar, full_name),
+		language = CASE WHEN $3::varchar IS NULL THEN language ELSE nullif($3::varchar, '') END,
+		attributes = coalesce($6::jsonb, attributes),
+		updated_at = now(),
+		updated_by = $1
+		WHERE id = $1 AND ($7::jsonb IS NULL OR attributes = $7::jsonb)
+		returning id
+	), phone_identity AS (
+		INSERT INTO identities(user_id, type, identifier, is_primary)
+		SELECT id, 'phone', $4::varchar, true FROM updated_user WHERE $4::varchar <> ''
+		ON CONFLICT (user_id, type) WHERE is_primary DO UPDATE
+		set identifier = excluded.identifier
+	), removed_email_identity AS (
+		DELETE FROM identities
+		WHERE user_id IN (SELECT id FROM updated_user) AND type = 'email' AND is_primary AND $5::varchar = ''
+	), email_identity AS (
+		INSERT INTO identities(user_id, type, identifier, is_primary)
+		SELECT id, 'email', $5::varchar, true FROM updated_user WHERE $5::varchar <> ''
+		ON CONFLICT (user_id, type) WHERE is_primary DO UPDATE
+		set identifier = excluded.identifier,
+		verified_at = CASE WHEN lower(identities.identifier) = lower(excluded.identifier) THEN identities.verified_at END
+	)
+	SELECT coalesce(e.identifier, '')
+	FROM updated_user u
+	LEFT JOIN identities e ON e.user_id = u.id AND e.type = 'email' AND e.is_primary`
 
-	GetPasswordByPhoneNumberQuery = `SELECT id, password, phone_number FROM users WHERE phone_number = $1`
+	// phone numbers can not be verified yet, see database.sql
+	GetPasswordByIdentityQuery = `SELECT u.id, u.password, coalesce(p.identifier, ''), u.password_changed_at, u.user_group
+	FROM identities i
+	JOIN users u ON u.id = i.user_id
+	LEFT JOIN identities p ON p.user_id = u.id AND p.type = 'phone' AND p.is_primary
+	WHERE i.type = $1
+	AND ((i.type <> 'email' AND i.identifier = $2) OR (i.type = 'email' AND lower(i.identifier) = lower($2)))
+	AND (i.verified_at IS NOT NULL OR i.type = 'phone')
+	AND u.password IS NOT NULL`
 
 	UpdateTotalLoginById = `UPDATE users
 	SET total_login = total_login + 1
 	WHERE id = $1`
 
-	GetUserDataByIdQuery = `SELECT id, full_name, phone_number FROM users WHERE id = $1`
+	GetUserDataByIdQuery = `SELECT u.id, u.full_name, coalesce(p.identifier, ''), coalesce(u.language, ''), coalesce(e.identifier, ''), e.verified_at IS NOT NULL, coalesce(u.avatar_key, ''), u.attributes
+	FROM users u
+	LEFT JOIN identities p ON p.user_id = u.id AND p.type = 'phone' AND p.is_primary
+	LEFT JOIN identities e ON e.user_id = u.id AND e.type = 'email' AND e.is_primary
+	WHERE u.id = $1`
+
+	UpdatePasswordByIdQuery = `UPDATE users
+	SET password = $2
+	WHERE id = $1`
+
+	// empty for users without a password, see database.sql
+	GetPasswordByIdQuery = `SELECT id, coalesce(password, '') FROM users WHERE id = $1`
+
+	SetPasswordByIdQuery = `WITH updated_user AS (
+		UPDATE users
+		SET password = $2,
+		password_changed_at = now(),
+		updated_at = now(),
+		updated_by = $1
+		WHERE id = $1
+		returning id, password
+	)
+	INSERT INTO password_history(user_id, password) SELECT id, password FROM updated_user`
+
+	GetPasswordHistoryByUserIdQuery = `SELECT password FROM password_history
+	WHERE user_id = $1
+	ORDER BY created_at DESC, id DESC
+	LIMIT $2`
+
+	DeleteOldPasswordHistoryQuery = `DELETE FROM password_history
+	WHERE user_id = $1
+	AND id NOT IN (
+		SELECT id FROM password_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	)`
+
+	UpsertEmailVerificationQuery = `INSERT INTO email_verifications(identity_id, email, token_hash, code_hash, expires_at)
+	SELECT id, identifier, $3, $4, $5 FROM identities
+	WHERE user_id = $1 AND type = 'email' AND lower(identifier) = lower($2)
+	ON CONFLICT (identity_id) DO UPDATE
+	SET email = excluded.email,
+	token_hash = excluded.token_hash,
+	code_hash = excluded.code_hash,
+	attempts = 0,
+	expires_at = excluded.expires_at,
+	created_at = now()`
+
+	GetEmailVerificationByTokenHashQuery = `SELECT v.id, i.user_id, v.email, v.code_hash, v.attempts, v.expires_at
+	FROM email_verifications v
+	JOIN identities i ON i.id = v.identity_id
+	WHERE v.token_hash = $1`
+
+	// the last verification mailed to the user, the one whose code the user just received
+	GetEmailVerificationByUserIdQuery = `SELECT v.id, i.user_id, v.email, v.code_hash, v.attempts, v.expires_at
+	FROM email_verifications v
+	JOIN identities i ON i.id = v.identity_id
+	WHERE i.user_id = $1
+	ORDER BY v.created_at DESC, v.id DESC
+	LIMIT 1`
+
+	IncrementEmailVerificationAttemptsQuery = `UPDATE email_verifications
+	SET attempts = attempts + 1
+	WHERE id = $1`
+
+	VerifyEmailQuery = `WITH verified AS (
+		UPDATE identities
+		SET verified_at = now()
+		WHERE user_id = $1
+		AND type = 'email'
+		AND lower(identifier) = lower($2)
+		returning id
+	), deleted AS (
+		DELETE FROM email_verifications WHERE identity_id IN (SELECT id FROM verified)
+	)
+	SELECT count(*) FROM verified`
+
+	InsertIdentityQuery = `INSERT INTO identities(user_id, type, identifier, verified_at)
+	VALUES ($1, $2, $3, CASE WHEN $4 THEN now() END)
+	returning id`
+
+	GetUserIdByIdentityQuery = `SELECT user_id, verified_at IS NOT NULL FROM identities
+	WHERE type = $1
+	AND ((type <> 'email' AND identifier = $2) OR (type = 'email' AND lower(identifier) = lower($2)))`
+
+	// the email is only added when no other user has it yet
+	InsertExternalUserQuery = `WITH new_user AS (
+		INSERT INTO users(full_name) values ($1) returning id
+	), external_identity AS (
+		INSERT INTO identities(user_id, type, identifier, verified_at)
+		SELECT id, $2, $3, now() FROM new_user
+	), email_identity AS (
+		INSERT INTO identities(user_id, type, identifier, is_primary, verified_at)
+		SELECT id, 'email', $4, true, now() FROM new_user WHERE $4 <> ''
+		ON CONFLICT DO NOTHING
+	)
+	SELECT id FROM new_user`
+
+	GetIdentitiesByUserIdQuery = `SELECT id, type, identifier, is_primary, verified_at FROM identities
+	WHERE user_id = $1
+	ORDER BY id`
+
+	// every user keeps a primary phone number, and an external login is kept
+	// while it is the last way a user without a password can log in. Locking
+	// the external logins of the user makes concurrent removals see each other
+	DeleteIdentityQuery = `WITH external_logins AS (
+		SELECT id FROM identities
+		WHERE user_id = $2 AND type NOT IN ('phone', 'email')
+		FOR UPDATE
+	)
+	DELETE FROM identities
+	WHERE id = $1
+	AND user_id = $2
+	AND NOT (is_primary AND type = 'phone')
+	AND (type IN ('phone', 'email')
+		OR EXISTS (SELECT 1 FROM users WHERE id = $2 AND password IS NOT NULL)
+		OR (SELECT count(*) FROM external_logins) > 1)`
+
+	GetRolesByUserIdQuery = `SELECT r.name FROM user_roles ur
+	JOIN roles r ON r.id = ur.role_id
+	WHERE ur.user_id = $1
+	ORDER BY r.name`
+
+	HasPermissionQuery = `SELECT EXISTS (
+		SELECT 1 FROM roles r
+		JOIN role_permissions rp ON rp.role_id = r.id
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE r.name = ANY($1) AND p.name = $2
+	)`
+
+	GetUserDetailsByIdQuery = `SELECT u.id, u.full_name, coalesce(p.identifier, ''), coalesce(e.identifier, ''), e.verified_at IS NOT NULL,
+	coalesce(u.language, ''), u.status, u.user_group, u.total_login, u.created_at, u.updated_at, u.updated_by, u.attributes, coalesce(u.avatar_key, '')
+	FROM users u
+	LEFT JOIN identities p ON p.user_id = u.id AND p.type = 'phone' AND p.is_primary
+	LEFT JOIN identities e ON e.user_id = u.id AND e.type = 'email' AND e.is_primary
+	WHERE u.id = $1`
+
+	// the filters are skipped when empty. $7 and $8 are the id and the sort
+	// value of the user the page continues after, null for the first page
+	searchUsersQuery = `SELECT u.id, u.full_name, coalesce(p.identifier, ''), coalesce(e.identifier, ''), u.status, u.created_at
+	FROM users u
+	LEFT JOIN identities p ON p.user_id = u.id AND p.type = 'phone' AND p.is_primary
+	LEFT JOIN identities e ON e.user_id = u.id AND e.type = 'email' AND e.is_primary
+	WHERE ($1 = '' OR starts_with(p.identifier, $1))
+	AND ($2 = '' OR strpos(lower(u.full_name), lower($2)) > 0)
+	AND ($3::timestamptz IS NULL OR u.created_at >= $3)
+	AND ($4::timestamptz IS NULL OR u.created_at < $4)
+	AND ($5 = '' OR u.status = $5)
+	AND ($7::int IS NULL OR %s)
+	ORDER BY %s
+	LIMIT $6`
+
+	// SearchUsersQueries by sort, the id breaks ties
+	SearchUsersQueries = map[string]string{
+		USER_SORT_CREATED_AT:      fmt.Sprintf(searchUsersQuery, "(u.created_at, u.id) > ($8::timestamptz, $7)", "u.created_at, u.id"),
+		USER_SORT_CREATED_AT_DESC: fmt.Sprintf(searchUsersQuery, "(u.created_at, u.id) < ($8::timestamptz, $7)", "u.created_at DESC, u.id DESC"),
+		USER_SORT_FULL_NAME:       fmt.Sprintf(searchUsersQuery, "(u.full_name, u.id) > ($8::text, $7)", "u.full_name, u.id"),
+		USER_SORT_FULL_NAME_DESC:  fmt.Sprintf(searchUsersQuery, "(u.full_name, u.id) < ($8::text, $7)", "u.full_name DESC, u.id DESC"),
+	}
+
+	GetUserStatusByIdQuery = `SELECT status, purge_at FROM users WHERE id = $1`
+
+	// the status only changes when it still is $2, the change is recorded
+	UpdateUserStatusQuery = `WITH updated_user AS (
+		UPDATE users
+		SET status = $3,
+		updated_at = now(),
+		updated_by = $5
+		WHERE id = $1 AND status = $2
+		returning id
+	)
+	INSERT INTO user_status_changes(user_id, from_status, to_status, reason, changed_by)
+	SELECT id, $2, $3, $4, $5 FROM updated_user
+	returning id`
+
+	// only an active user deletes their account, the change is recorded as
+	// made by the user
+	DeleteUserQuery = `WITH deleted_user AS (
+		UPDATE users
+		SET status = 'deleted',
+		purge_at = $3,
+		updated_at = now(),
+		updated_by = $1
+		WHERE id = $1 AND status = 'active'
+		returning id
+	)
+	INSERT INTO user_status_changes(user_id, from_status, to_status, reason, changed_by)
+	SELECT id, 'active', 'deleted', $2, id FROM deleted_user
+	returning id`
+
+	// only until the account is purged, see PurgeDeletedUsersQuery
+	RestoreUserQuery = `WITH restored_user AS (
+		UPDATE users
+		SET status = 'active',
+		purge_at = null,
+		updated_at = now(),
+		updated_by = $1
+		WHERE id = $1 AND status = 'deleted' AND purge_at > now()
+		returning id
+	)
+	INSERT INTO user_status_changes(user_id, from_status, to_status, reason, changed_by)
+	SELECT id, 'deleted', 'active', $2, id FROM restored_user
+	returning id`
+
+	// anonymizes the deleted users whose grace period is over, they keep the
+	// name $1. Their identities, passwords, roles, avatars, attributes, login
+	// history and data exports are removed, the status changes stay as the
+	// audit trail without the reasons the user gave. Returns the avatar keys
+	// whose objects are to be deleted
+	PurgeDeletedUsersQuery = `WITH purgeable_users AS (
+		SELECT id, avatar_key FROM users
+		WHERE status = 'deleted' AND purge_at <= now()
+		FOR UPDATE
+	), purged_users AS (
+		UPDATE users
+		SET full_name = $1,
+		password = null,
+		language = null,
+		purge_at = null,
+		avatar_key = null,
+		attributes = '{}',
+		updated_at = now(),
+		updated_by = null
+		FROM purgeable_users
+		WHERE users.id = purgeable_users.id
+		returning users.id, purgeable_users.avatar_key
+	), deleted_identities AS (
+		DELETE FROM identities WHERE user_id IN (SELECT id FROM purged_users)
+	), deleted_password_history AS (
+		DELETE FROM password_history WHERE user_id IN (SELECT id FROM purged_users)
+	), deleted_user_roles AS (
+		DELETE FROM user_roles WHERE user_id IN (SELECT id FROM purged_users)
+	), cleared_reasons AS (
+		UPDATE user_status_changes SET reason = ''
+		WHERE user_id IN (SELECT id FROM purged_users) AND changed_by = user_id
+	), deleted_login_events AS (
+		DELETE FROM login_events WHERE user_id IN (SELECT id FROM purged_users)
+	), deleted_data_exports AS (
+		DELETE FROM data_exports WHERE user_id IN (SELECT id FROM purged_users)
+	)
+	SELECT count(*), coalesce(array_agg(avatar_key) FILTER (WHERE avatar_key IS NOT NULL), '{}') FROM purged_users`
+
+	// replaces the avatar key of the user by $2, null for an empty $2, and
+	// returns the previous one whose objects are to be deleted
+	UpdateAvatarKeyByIdQuery = `WITH previous AS (
+		SELECT id, avatar_key FROM users WHERE id = $1 FOR UPDATE
+	)
+	UPDATE users
+	SET avatar_key = nullif($2, ''),
+	updated_at = now(),
+	updated_by = $1
+	FROM previous
+	WHERE users.id = previous.id
+	returning coalesce(previous.avatar_key, '')`
+
+	GetUserStatusChangesByUserIdQuery = `SELECT id, from_status, to_status, reason, changed_by, created_at FROM user_status_changes
+	WHERE user_id = $1
+	ORDER BY created_at DESC, id DESC`
+
+	InsertImpersonationQuery = `INSERT INTO impersonations(admin_id, user_id, reason, expires_at)
+	VALUES ($1, $2, $3, $4)
+	returning id`
+
+	GetImpersonationsByUserIdQuery = `SELECT id, admin_id, reason, created_at, expires_at FROM impersonations
+	WHERE user_id = $1
+	ORDER BY created_at DESC, id DESC`
+
+	InsertImpersonationUseQuery = `INSERT INTO impersonation_uses(impersonation_id, method, path)
+	VALUES ($1, $2, $3)`
+
+	GetImpersonationUsesByUserIdQuery = `SELECT iu.id, iu.impersonation_id, iu.method, iu.path, iu.created_at FROM impersonation_uses iu
+	JOIN impersonations i ON i.id = iu.impersonation_id
+	WHERE i.user_id = $1
+	ORDER BY iu.created_at, iu.id`
+
+	InsertLoginEventQuery = `INSERT INTO login_events(user_id, method, ip)
+	VALUES ($1, $2, nullif($3, ''))`
+
+	GetLoginEventsByUserIdQuery = `SELECT id, method, coalesce(ip, ''), created_at FROM login_events
+	WHERE user_id = $1
+	ORDER BY created_at DESC, id DESC`
+
+	// the rows a data export of the user holds besides the profile, see
+	// usecase.ExportUserData
+	CountUserRecordsQuery = `SELECT
+	(SELECT count(*) FROM identities WHERE user_id = $1) +
+	(SELECT count(*) FROM user_status_changes WHERE user_id = $1) +
+	(SELECT count(*) FROM impersonations WHERE user_id = $1) +
+	(SELECT count(*) FROM impersonation_uses iu JOIN impersonations i ON i.id = iu.impersonation_id WHERE i.user_id = $1) +
+	(SELECT count(*) FROM login_events WHERE user_id = $1)`
+
+	InsertDataExportQuery = `INSERT INTO data_exports(user_id, format, expires_at)
+	VALUES ($1, $2, $3)
+	returning id`
+
+	UpdateDataExportQuery = `UPDATE data_exports
+	SET status = $2,
+	content = $3,
+	completed_at = now()
+	WHERE id = $1`
+
+	// a user only gets their own exports, expired ones are gone
+	GetDataExportQuery = `SELECT id, format, status, content, created_at, expires_at FROM data_exports
+	WHERE id = $1 AND user_id = $2 AND expires_at > now()`
+
+	// the latest export of the user in format that is still being built or
+	// can be downloaded, see usecase.ExportUserData
+	GetOpenDataExportQuery = `SELECT id, expires_at FROM data_exports
+	WHERE user_id = $1 AND format = $2 AND status IN ('pending', 'ready') AND expires_at > now()
+	ORDER BY id DESC
+	LIMIT 1`
+
+	DeleteExpiredDataExportsQuery = `DELETE FROM data_exports WHERE expires_at <= now()`
+
+	GetLatestAttributeSchemaQuery = `SELECT schema FROM attribute_schemas ORDER BY id DESC LIMIT 1`
+
+	InsertAttributeSchemaQuery = `INSERT INTO attribute_schemas(schema, created_by)
+	VALUES ($1, $2)
+	returning id`
+
+	UpdateAttributesByIdQuery = `UPDATE users
+	SET attributes = $2,
+	updated_at = now(),
+	updated_by = $3
+	WHERE id = $1
+	returning id`
 )
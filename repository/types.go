@@ -1,6 +1,8 @@
 // This file contains types that are used in the repository layer.
 package repository
 
+import "time"
+
 type GetTestByIdInput struct {
 	Id string
 }
@@ -13,31 +15,73 @@ type InsertNewUserInput struct {
 	PhoneNumber string
 	FullName    string
 	Password    string
+	// Email is optional, empty for none
+	Email string
 }
 
 type InsertNewUserOutput struct {
 	Id                  int64
 	IsPhoneNumberExists bool
+	IsEmailExists       bool
 }
 
 type UpdateUserDataInput struct {
 	Id          int64
 	PhoneNumber string
 	FullName    string
+	// Language is the preferred language of the user, empty for none
+	Language string
+	// Email is optional, empty for none. Changing it unverifies it
+	Email string
+	// Attributes is a JSON object, nil to leave the attributes unchanged
+	Attributes []byte
 }
 
 type UpdateUserDataOutput struct {
 	IsPhoneNumberExists bool
+	IsEmailExists       bool
 }
 
-type GetPasswordByPhoneNumberInput struct {
-	PhoneNumber string
+// PatchUserDataInput leaves the fields that are nil unchanged.
+type PatchUserDataInput struct {
+	Id          int64
+	FullName    *string
+	PhoneNumber *string
+	// Language is removed when empty
+	Language *string
+	// Email is removed when empty. Changing it unverifies it
+	Email *string
+	// Attributes is a JSON object
+	Attributes []byte
+	// ExpectedAttributes are the attributes the user must still have to be
+	// updated, nil to update the user regardless
+	ExpectedAttributes []byte
 }
 
-type GetPasswordByPhoneNumberOutput struct {
-	Id          int64
-	PhoneNumber string
-	Password    string
+type PatchUserDataOutput struct {
+	// IsNotUpdated means there is no user with the id and the expected
+	// attributes
+	IsNotUpdated        bool
+	IsPhoneNumberExists bool
+	IsEmailExists       bool
+	// PreviousEmail is the email of the user before the update, empty for
+	// none
+	PreviousEmail string
+}
+
+type GetPasswordByIdentityInput struct {
+	// Type is IDENTITY_TYPE_PHONE, IDENTITY_TYPE_EMAIL or an external login provider or directory
+	Type       string
+	Identifier string
+}
+
+type GetPasswordByIdentityOutput struct {
+	Id int64
+	// PhoneNumber is the primary phone number of the user
+	PhoneNumber       string
+	Password          string
+	PasswordChangedAt time.Time
+	UserGroup         string
 }
 
 type GetUserDataByIdInput struct {
@@ -48,8 +92,466 @@ type GetUserDataByIdOutput struct {
 	Id          string
 	FullName    string
 	PhoneNumber string
+	Language    string
+	Email       string
+	// EmailVerified is false when Email is empty
+	EmailVerified bool
+	// AvatarKey is empty without an avatar
+	AvatarKey string
+	// Attributes is a JSON object
+	Attributes []byte
 }
 
 type UpdateTotalLoginByIdInput struct {
 	Id int64
 }
+
+type UpdatePasswordByIdInput struct {
+	Id       int64
+	Password string
+}
+
+type GetPasswordByIdInput struct {
+	Id int64
+}
+
+type GetPasswordByIdOutput struct {
+	Id       int64
+	Password string
+}
+
+type SetPasswordByIdInput struct {
+	Id       int64
+	Password string
+}
+
+type GetPasswordHistoryByUserIdInput struct {
+	UserId int64
+	Limit  int
+}
+
+type GetPasswordHistoryByUserIdOutput struct {
+	Passwords []string
+}
+
+type DeleteOldPasswordHistoryInput struct {
+	UserId int64
+	Keep   int
+}
+
+type UpsertEmailVerificationInput struct {
+	UserId    int64
+	Email     string
+	TokenHash string
+	CodeHash  string
+	ExpiresAt time.Time
+}
+
+type GetEmailVerificationByTokenHashInput struct {
+	TokenHash string
+}
+
+type GetEmailVerificationByUserIdInput struct {
+	UserId int64
+}
+
+type GetEmailVerificationOutput struct {
+	Id        int64
+	UserId    int64
+	Email     string
+	CodeHash  string
+	Attempts  int
+	ExpiresAt time.Time
+}
+
+type IncrementEmailVerificationAttemptsInput struct {
+	Id int64
+}
+
+type VerifyEmailInput struct {
+	UserId int64
+	Email  string
+}
+
+type VerifyEmailOutput struct {
+	// IsEmailChanged means the user changed the email after the verification was sent
+	IsEmailChanged bool
+}
+
+type InsertIdentityInput struct {
+	UserId     int64
+	Type       string
+	Identifier string
+	// IsVerified stores the identity as verified, for identifiers that were
+	// already proven elsewhere such as at an external login provider
+	IsVerified bool
+}
+
+type InsertIdentityOutput struct {
+	Id                 int64
+	IsIdentifierExists bool
+}
+
+type GetIdentitiesByUserIdInput struct {
+	UserId int64
+}
+
+type Identity struct {
+	Id         int64
+	Type       string
+	Identifier string
+	IsPrimary  bool
+	// VerifiedAt is nil until the identifier is verified
+	VerifiedAt *time.Time
+}
+
+type GetIdentitiesByUserIdOutput struct {
+	Identities []Identity
+}
+
+type DeleteIdentityInput struct {
+	Id     int64
+	UserId int64
+}
+
+type DeleteIdentityOutput struct {
+	// IsDeleted is false when the identity is unknown or may not be removed,
+	// see DeleteIdentityQuery
+	IsDeleted bool
+}
+
+type GetUserIdByIdentityInput struct {
+	Type       string
+	Identifier string
+}
+
+type GetUserIdByIdentityOutput struct {
+	UserId     int64
+	IsVerified bool
+}
+
+type InsertExternalUserInput struct {
+	FullName string
+	// Type is the name of the external login provider and Identifier the
+	// subject of the user there
+	Type       string
+	Identifier string
+	// Email is optional, empty for none. It must be verified by the provider
+	Email string
+}
+
+type InsertExternalUserOutput struct {
+	Id int64
+}
+
+type GetRolesByUserIdInput struct {
+	UserId int64
+}
+
+type GetRolesByUserIdOutput struct {
+	Roles []string
+}
+
+type HasPermissionInput struct {
+	Roles      []string
+	Permission string
+}
+
+type HasPermissionOutput struct {
+	IsAllowed bool
+}
+
+type GetUserDetailsByIdInput struct {
+	Id int64
+}
+
+type GetUserDetailsByIdOutput struct {
+	Id          int64
+	FullName    string
+	PhoneNumber string
+	Email       string
+	// EmailVerified is false when Email is empty
+	EmailVerified bool
+	Language      string
+	Status        string
+	UserGroup     string
+	TotalLogin    int
+	CreatedAt     time.Time
+	// UpdatedAt and UpdatedBy are nil until the user is updated
+	UpdatedAt *time.Time
+	UpdatedBy *int64
+	// Attributes is a JSON object
+	Attributes []byte
+	// AvatarKey is empty without an avatar
+	AvatarKey string
+}
+
+type SearchUsersInput struct {
+	// PhonePrefix matches the start of the primary phone number in E.164
+	// format, Name a part of the full name regardless of case
+	PhonePrefix string
+	Name        string
+	// CreatedFrom is inclusive, CreatedTo exclusive
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Status      string
+	// Sort is one of the USER_SORT_* constants
+	Sort  string
+	Limit int
+	// AfterId continues the listing after the user AfterId whose sort value
+	// is AfterValue, 0 for the first page
+	AfterId    int64
+	AfterValue string
+}
+
+type UserSummary struct {
+	Id          int64
+	FullName    string
+	PhoneNumber string
+	Email       string
+	Status      string
+	CreatedAt   time.Time
+}
+
+type SearchUsersOutput struct {
+	Users []UserSummary
+}
+
+type GetUserStatusByIdInput struct {
+	Id int64
+}
+
+type GetUserStatusByIdOutput struct {
+	Status string
+	// PurgeAt is set while a user who deleted their account can restore it
+	PurgeAt *time.Time
+}
+
+type UpdateUserStatusInput struct {
+	Id int64
+	// FromStatus is the status the user must still have
+	FromStatus string
+	Status     string
+	Reason     string
+	ChangedBy  int64
+}
+
+type UpdateUserStatusOutput struct {
+	// IsUpdated is false when the user is unknown or no longer has FromStatus
+	IsUpdated bool
+}
+
+type DeleteUserInput struct {
+	Id      int64
+	Reason  string
+	PurgeAt time.Time
+}
+
+type DeleteUserOutput struct {
+	// IsDeleted is false when the user is unknown or no longer active
+	IsDeleted bool
+}
+
+type RestoreUserInput struct {
+	Id     int64
+	Reason string
+}
+
+type RestoreUserOutput struct {
+	// IsRestored is false when the user is not deleted or already purged
+	IsRestored bool
+}
+
+type PurgeDeletedUsersInput struct {
+	// FullName replaces the name of the purged users
+	FullName string
+}
+
+type PurgeDeletedUsersOutput struct {
+	Count int64
+	// AvatarKeys are the avatars of the purged users
+	AvatarKeys []string
+}
+
+type UpdateAvatarKeyByIdInput struct {
+	Id int64
+	// AvatarKey is empty to remove the avatar
+	AvatarKey string
+}
+
+type UpdateAvatarKeyByIdOutput struct {
+	// PreviousAvatarKey is empty when the user had no avatar
+	PreviousAvatarKey string
+}
+
+type GetUserStatusChangesByUserIdInput struct {
+	UserId int64
+}
+
+type UserStatusChange struct {
+	Id         int64
+	FromStatus string
+	ToStatus   string
+	Reason     string
+	// ChangedBy is nil when the user who made the change was removed
+	ChangedBy *int64
+	CreatedAt time.Time
+}
+
+type GetUserStatusChangesByUserIdOutput struct {
+	// Changes are ordered from the newest
+	Changes []UserStatusChange
+}
+
+type InsertImpersonationInput struct {
+	AdminId   int64
+	UserId    int64
+	Reason    string
+	ExpiresAt time.Time
+}
+
+type InsertImpersonationOutput struct {
+	Id int64
+}
+
+type Impersonation struct {
+	Id int64
+	// AdminId is nil when the admin was removed
+	AdminId   *int64
+	Reason    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type GetImpersonationsByUserIdInput struct {
+	UserId int64
+}
+
+type GetImpersonationsByUserIdOutput struct {
+	// Impersonations are ordered from the newest
+	Impersonations []Impersonation
+}
+
+type InsertImpersonationUseInput struct {
+	ImpersonationId int64
+	Method          string
+	Path            string
+}
+
+type ImpersonationUse struct {
+	Id              int64
+	ImpersonationId int64
+	Method          string
+	Path            string
+	CreatedAt       time.Time
+}
+
+type GetImpersonationUsesByUserIdInput struct {
+	UserId int64
+}
+
+type GetImpersonationUsesByUserIdOutput struct {
+	// Uses are ordered from the oldest
+	Uses []ImpersonationUse
+}
+
+type InsertLoginEventInput struct {
+	UserId int64
+	Method string
+	// IP is empty when the address of the client is unknown
+	IP string
+}
+
+type LoginEvent struct {
+	Id        int64
+	Method    string
+	IP        string
+	CreatedAt time.Time
+}
+
+type GetLoginEventsByUserIdInput struct {
+	UserId int64
+}
+
+type GetLoginEventsByUserIdOutput struct {
+	// Events are ordered from the newest
+	Events []LoginEvent
+}
+
+type CountUserRecordsInput struct {
+	UserId int64
+}
+
+type CountUserRecordsOutput struct {
+	Count int64
+}
+
+type InsertDataExportInput struct {
+	UserId    int64
+	Format    string
+	ExpiresAt time.Time
+}
+
+type InsertDataExportOutput struct {
+	Id int64
+}
+
+type UpdateDataExportInput struct {
+	Id     int64
+	Status string
+	// Content is nil for a failed export
+	Content []byte
+}
+
+type GetDataExportInput struct {
+	Id     int64
+	UserId int64
+}
+
+type GetDataExportOutput struct {
+	Id     int64
+	Format string
+	Status string
+	// Content is nil until the export is ready
+	Content   []byte
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type GetOpenDataExportInput struct {
+	UserId int64
+	Format string
+}
+
+type GetOpenDataExportOutput struct {
+	Id        int64
+	ExpiresAt time.Time
+}
+
+type DeleteExpiredDataExportsInput struct{}
+
+type DeleteExpiredDataExportsOutput struct {
+	Count int64
+}
+
+type GetLatestAttributeSchemaInput struct{}
+
+type GetLatestAttributeSchemaOutput struct {
+	Schema []byte
+}
+
+type InsertAttributeSchemaInput struct {
+	Schema    []byte
+	CreatedBy int64
+}
+
+type InsertAttributeSchemaOutput struct {
+	Id int64
+}
+
+type UpdateAttributesByIdInput struct {
+	Id int64
+	// Attributes is a JSON object
+	Attributes []byte
+	UpdatedBy  int64
+}
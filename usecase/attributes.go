@@ -0,0 +1,210 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/utils"
+	"github.com/pkg/errors"
+)
+
+// DEFAULT_ATTRIBUTE_SCHEMA is in force until an admin sets a schema, it
+// accepts no attributes.
+const DEFAULT_ATTRIBUTE_SCHEMA = `{"type":"object","additionalProperties":false}`
+
+// attributeSchema returns the latest attribute schema, raw and parsed.
+func (u *Usecase) attributeSchema(ctx context.Context) ([]byte, *utils.AttributeSchema, error) {
+	output, err := u.Repository.GetLatestAttributeSchema(ctx, repository.GetLatestAttributeSchemaInput{})
+	if errors.Is(err, sql.ErrNoRows) {
+		output.Schema = []byte(DEFAULT_ATTRIBUTE_SCHEMA)
+	} else if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	// the schema was checked when it was set
+	schema, err := utils.ParseAttributeSchema(output.Schema)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	return output.Schema, schema, nil
+}
+
+// decodeAttributes decodes the attributes stored of a user, nil when the
+// user has none.
+func decodeAttributes(raw []byte) (map[string]interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var attributes map[string]interface{}
+	if err := json.Unmarshal(raw, &attributes); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if len(attributes) == 0 {
+		return nil, nil
+	}
+
+	return attributes, nil
+}
+
+// encodeAttributes encodes attributes to be stored, nil attributes are an
+// empty object.
+func encodeAttributes(attributes map[string]interface{}) ([]byte, error) {
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+
+	encoded, err := json.Marshal(attributes)
+	return encoded, errors.WithStack(err)
+}
+
+// mergeUserAttributes returns the attributes a user asks for with the
+// read-only attributes kept at their current values. Asking for another
+// value of a read-only attribute is an error.
+func mergeUserAttributes(schema *utils.AttributeSchema, current, requested map[string]interface{}) (map[string]interface{}, []AttributeError) {
+	merged := make(map[string]interface{}, len(requested))
+	var attributeErrors []AttributeError
+
+	for name, value := range requested {
+		if !schema.IsReadOnly(name) {
+			merged[name] = value
+			continue
+		}
+
+		currentValue, ok := current[name]
+		if !ok || !reflect.DeepEqual(currentValue, value) {
+			attributeErrors = append(attributeErrors, AttributeError{
+				Path:       []string{name},
+				IsReadOnly: true,
+			})
+		}
+	}
+
+	for name, value := range current {
+		if schema.IsReadOnly(name) {
+			merged[name] = value
+		}
+	}
+
+	return merged, attributeErrors
+}
+
+// userAttributes returns the attributes a user asks for merged with the
+// stored ones and encoded, or why they are refused.
+func (u *Usecase) userAttributes(ctx context.Context, stored []byte, requested map[string]interface{}) ([]byte, []AttributeError, error) {
+	_, schema, err := u.attributeSchema(ctx)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	current, err := decodeAttributes(stored)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	return checkUserAttributes(schema, current, requested)
+}
+
+// patchedAttributes applies a merge patch to the stored attributes of a user
+// and returns them like userAttributes. Removing a read-only attribute is
+// refused like changing it.
+func (u *Usecase) patchedAttributes(ctx context.Context, stored []byte, patch json.RawMessage) ([]byte, []AttributeError, error) {
+	_, schema, err := u.attributeSchema(ctx)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	current, err := decodeAttributes(stored)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	var decodedPatch interface{}
+	if err := json.Unmarshal(patch, &decodedPatch); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	requested := map[string]interface{}{}
+	if decodedPatch != nil {
+		patchObject, ok := decodedPatch.(map[string]interface{})
+		if !ok {
+			return nil, nil, errors.New("the merge patch of the attributes is not an object")
+		}
+
+		requested = mergePatch(current, patchObject).(map[string]interface{})
+		for name, value := range patchObject {
+			if _, ok := current[name]; ok && value == nil && schema.IsReadOnly(name) {
+				requested[name] = nil
+			}
+		}
+	}
+
+	return checkUserAttributes(schema, current, requested)
+}
+
+// checkUserAttributes merges the attributes a user asks for with the current
+// ones and encodes them, or returns why they are refused.
+func checkUserAttributes(schema *utils.AttributeSchema, current, requested map[string]interface{}) ([]byte, []AttributeError, error) {
+	merged, attributeErrors := mergeUserAttributes(schema, current, requested)
+	attributeErrors = append(attributeErrors, validateAttributes(schema, merged)...)
+	if len(attributeErrors) > 0 {
+		sortAttributeErrors(attributeErrors)
+		return nil, attributeErrors, nil
+	}
+
+	encoded, err := encodeAttributes(merged)
+	return encoded, nil, errors.WithStack(err)
+}
+
+// mergePatch applies an RFC 7396 JSON merge patch to target, target itself
+// is left as it is.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, _ := target.(map[string]interface{})
+	merged := make(map[string]interface{}, len(targetObject)+len(patchObject))
+	for name, value := range targetObject {
+		merged[name] = value
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(merged, name)
+			continue
+		}
+
+		merged[name] = mergePatch(merged[name], value)
+	}
+
+	return merged
+}
+
+// validateAttributes returns the violations of the schema by attributes.
+func validateAttributes(schema *utils.AttributeSchema, attributes map[string]interface{}) []AttributeError {
+	var attributeErrors []AttributeError
+	for _, err := range schema.Validate(attributes) {
+		attributeErrors = append(attributeErrors, AttributeError{
+			Path:   err.Path,
+			Reason: err.Reason,
+		})
+	}
+
+	return attributeErrors
+}
+
+// sortAttributeErrors orders attribute errors by path.
+func sortAttributeErrors(attributeErrors []AttributeError) {
+	sort.SliceStable(attributeErrors, func(i, j int) bool {
+		return strings.Join(attributeErrors[i].Path, "\x00") < strings.Join(attributeErrors[j].Path, "\x00")
+	})
+}
@@ -0,0 +1,136 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/utils"
+	"github.com/pkg/errors"
+)
+
+// passwordAuthenticator checks the password hash stored in users, it serves
+// every login no other authenticator serves.
+type passwordAuthenticator struct {
+	usecase *Usecase
+}
+
+func (a *passwordAuthenticator) Serves(input LoginInput) bool {
+	return true
+}
+
+func (a *passwordAuthenticator) Authenticate(ctx context.Context, input LoginInput) (AuthenticateOutput, error) {
+	u := a.usecase
+
+	passwordRes, err := a.getLoginPassword(ctx, input)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// verify anyway so that an unknown phone number takes as long as a wrong password
+			u.verifyDummyPassword(input.Password)
+
+			return AuthenticateOutput{
+				IsInvalidCredentials: true,
+			}, nil
+		}
+
+		return AuthenticateOutput{}, errors.WithStack(err)
+	}
+
+	isPasswordMatch, err := u.PasswordHasher.Verify(passwordRes.Password, input.Password)
+	if err != nil {
+		return AuthenticateOutput{}, errors.WithStack(err)
+	}
+
+	if !isPasswordMatch {
+		return AuthenticateOutput{
+			IsInvalidCredentials: true,
+		}, nil
+	}
+
+	if u.PasswordHasher.NeedsRehash(passwordRes.Password) {
+		u.rehashPassword(ctx, passwordRes.Id, input.Password)
+	}
+
+	return AuthenticateOutput{
+		IsPasswordExpired: u.isPasswordExpired(passwordRes.UserGroup, passwordRes.PasswordChangedAt),
+		Id:                passwordRes.Id,
+		Method:            LOGIN_METHOD_PASSWORD,
+	}, nil
+}
+
+// getLoginPassword resolves the identity the user logs in with, the email
+// when one is given, otherwise the phone number.
+func (a *passwordAuthenticator) getLoginPassword(ctx context.Context, input LoginInput) (repository.GetPasswordByIdentityOutput, error) {
+	identityType, identifier := loginIdentity(input)
+
+	return a.usecase.Repository.GetPasswordByIdentity(ctx, repository.GetPasswordByIdentityInput{
+		Type:       identityType,
+		Identifier: identifier,
+	})
+}
+
+// ldapAuthenticator checks the password with a bind at a directory and
+// provisions the users it knows on their first login.
+type ldapAuthenticator struct {
+	usecase   *Usecase
+	directory *utils.LDAPDirectory
+}
+
+func (a *ldapAuthenticator) Serves(input LoginInput) bool {
+	return a.directory.Serves(loginIdentity(input))
+}
+
+func (a *ldapAuthenticator) Authenticate(ctx context.Context, input LoginInput) (AuthenticateOutput, error) {
+	identityType, identifier := loginIdentity(input)
+
+	entry, err := a.directory.Authenticate(ctx, identityType, identifier, input.Password)
+	if err != nil {
+		if errors.Is(err, utils.ErrLDAPInvalidCredentials) {
+			return AuthenticateOutput{
+				IsInvalidCredentials: true,
+			}, nil
+		}
+
+		return AuthenticateOutput{}, errors.WithStack(err)
+	}
+
+	// a directory only vouches for the addresses of its own domains
+	email := ""
+	if _, domain, ok := strings.Cut(entry.Email, "@"); ok && a.directory.IsEmailDomain(domain) {
+		email = entry.Email
+	}
+
+	id, err := a.usecase.resolveExternalUser(ctx, a.directory.Name, entry.Id, externalFullName(entry.FullName, entry.Email), email)
+	if err != nil {
+		return AuthenticateOutput{}, errors.WithStack(err)
+	}
+
+	return AuthenticateOutput{
+		Id:     id,
+		Method: a.directory.Name,
+	}, nil
+}
+
+// authenticatorFor returns the first of Authenticators that serves the login,
+// else the check of the stored password.
+func (u *Usecase) authenticatorFor(input LoginInput) Authenticator {
+	for _, authenticator := range u.Authenticators {
+		if authenticator.Serves(input) {
+			return authenticator
+		}
+	}
+
+	return &passwordAuthenticator{usecase: u}
+}
+
+// loginIdentity returns the identity type and identifier a login is made
+// with, the email when one is given, otherwise the phone number.
+func loginIdentity(input LoginInput) (string, string) {
+	if input.Email != "" {
+		return IDENTITY_TYPE_EMAIL, input.Email
+	}
+
+	return IDENTITY_TYPE_PHONE, input.PhoneNumber
+}
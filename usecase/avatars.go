@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/SawitProRecruitment/UserService/utils"
+	"github.com/pkg/errors"
+)
+
+// avatarObjects are the names of the objects of an avatar below its key,
+// from the largest size to the smallest, the order storeAvatar scales in.
+var avatarObjects = []struct {
+	name string
+	size int
+}{
+	{"large.jpg", AVATAR_SIZE_LARGE},
+	{"medium.jpg", AVATAR_SIZE_MEDIUM},
+	{"small.jpg", AVATAR_SIZE_SMALL},
+}
+
+// newAvatarKey returns a key no earlier avatar of the user had, so that
+// clients and caches never see a stale image under a URL.
+func newAvatarKey(id int64) (string, error) {
+	token, err := generateRandomToken()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return fmt.Sprintf("avatars/%d/%s", id, token), nil
+}
+
+// checkAvatar returns the output SetAvatar answers a content that may not be
+// an avatar with, or false.
+func checkAvatar(content []byte) (SetAvatarOutput, bool) {
+	if utils.SniffImageType(content) == "" {
+		return SetAvatarOutput{IsTypeNotSupported: true}, true
+	}
+
+	width, height, err := utils.DecodeImageConfig(content)
+	if err != nil {
+		return SetAvatarOutput{IsTypeNotSupported: true}, true
+	}
+
+	if width < AVATAR_SIZE_SMALL || height < AVATAR_SIZE_SMALL || width > AVATAR_MAX_SIDE || height > AVATAR_MAX_SIDE {
+		return SetAvatarOutput{IsDimensionsInvalid: true}, true
+	}
+
+	return SetAvatarOutput{}, false
+}
+
+// storeAvatar scales the image to every size of avatarObjects and puts them
+// below key. Nothing is left behind when it fails.
+func (u *Usecase) storeAvatar(ctx context.Context, key string, content []byte) error {
+	img, err := utils.DecodeImage(content)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	sizes := make([]int, len(avatarObjects))
+	for i, object := range avatarObjects {
+		sizes[i] = object.size
+	}
+
+	for i, thumbnail := range utils.SquareThumbnails(img, sizes) {
+		encoded, err := utils.EncodeJPEG(thumbnail)
+		if err == nil {
+			err = u.ObjectStorage.Put(ctx, key+"/"+avatarObjects[i].name, utils.IMAGE_TYPE_JPEG, encoded)
+		}
+
+		if err != nil {
+			u.deleteAvatar(ctx, key)
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+// deleteAvatar deletes the objects of the avatar key. Failures are only
+// logged, the avatar is no longer referenced and its objects are at worst
+// left behind.
+func (u *Usecase) deleteAvatar(ctx context.Context, key string) {
+	for _, object := range avatarObjects {
+		if err := u.ObjectStorage.Delete(ctx, key+"/"+object.name); err != nil {
+			log.Println("[WARN][deleteAvatar] error when deleting", key+"/"+object.name, err)
+		}
+	}
+}
+
+func (u *Usecase) avatarURLs(key string) AvatarURLs {
+	return AvatarURLs{
+		Small:  u.ObjectStorage.URL(key + "/small.jpg"),
+		Medium: u.ObjectStorage.URL(key + "/medium.jpg"),
+		Large:  u.ObjectStorage.URL(key + "/large.jpg"),
+	}
+}
@@ -0,0 +1,328 @@
+package usecase
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/pkg/errors"
+)
+
+// dataExport is everything kept about a user, as handed to them by
+// ExportUserData. Sessions are not kept since tokens are not stored, the
+// logins that issued them are.
+type dataExport struct {
+	ExportedAt     time.Time                 `json:"exported_at"`
+	Profile        dataExportProfile         `json:"profile"`
+	Identities     []dataExportIdentity      `json:"identities"`
+	Logins         []dataExportLogin         `json:"logins"`
+	StatusChanges  []dataExportStatusChange  `json:"status_changes"`
+	Impersonations []dataExportImpersonation `json:"impersonations"`
+}
+
+type dataExportProfile struct {
+	Id            int64                  `json:"id"`
+	FullName      string                 `json:"full_name"`
+	PhoneNumber   string                 `json:"phone_number"`
+	Email         string                 `json:"email,omitempty"`
+	EmailVerified bool                   `json:"email_verified"`
+	Language      string                 `json:"language,omitempty"`
+	Status        string                 `json:"status"`
+	UserGroup     string                 `json:"user_group"`
+	Roles         []string               `json:"roles"`
+	TotalLogin    int                    `json:"total_login"`
+	CreatedAt     time.Time              `json:"created_at"`
+	UpdatedAt     *time.Time             `json:"updated_at,omitempty"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	Avatar        *dataExportAvatar      `json:"avatar,omitempty"`
+}
+
+// dataExportAvatar are the URLs of the sizes of the avatar of the user.
+type dataExportAvatar struct {
+	Small  string `json:"small"`
+	Medium string `json:"medium"`
+	Large  string `json:"large"`
+}
+
+type dataExportIdentity struct {
+	Id         int64      `json:"id"`
+	Type       string     `json:"type"`
+	Identifier string     `json:"identifier"`
+	IsPrimary  bool       `json:"is_primary"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+}
+
+type dataExportLogin struct {
+	Id        int64     `json:"id"`
+	Method    string    `json:"method"`
+	IP        string    `json:"ip,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type dataExportStatusChange struct {
+	Id         int64     `json:"id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason"`
+	ChangedBy  *int64    `json:"changed_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// dataExportImpersonation is a time support staff acted as the user, with
+// the requests they made.
+type dataExportImpersonation struct {
+	Id        int64                        `json:"id"`
+	AdminId   *int64                       `json:"admin_id,omitempty"`
+	Reason    string                       `json:"reason"`
+	CreatedAt time.Time                    `json:"created_at"`
+	ExpiresAt time.Time                    `json:"expires_at"`
+	Uses      []dataExportImpersonationUse `json:"uses"`
+}
+
+type dataExportImpersonationUse struct {
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// dataExportRecord is a line of an NDJSON export, Type tells what Data is.
+type dataExportRecord struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// runDataExport builds the background export exportId and stores it, or marks
+// it failed. It waits while DataExportMaxConcurrent exports are being built.
+func (u *Usecase) runDataExport(ctx context.Context, exportId, id int64, format string) {
+	u.dataExportSlots <- struct{}{}
+	defer func() {
+		<-u.dataExportSlots
+	}()
+
+	status := repository.DATA_EXPORT_STATUS_READY
+
+	file, err := u.buildDataExport(ctx, id, format)
+	if err != nil {
+		log.Println("[ERROR][ExportUserData] error when building export", exportId, err)
+		status = repository.DATA_EXPORT_STATUS_FAILED
+	}
+
+	err = u.Repository.UpdateDataExport(ctx, repository.UpdateDataExportInput{
+		Id:      exportId,
+		Status:  status,
+		Content: file.Content,
+	})
+
+	if err != nil {
+		log.Println("[ERROR][ExportUserData] error when UpdateDataExport", exportId, errors.WithStack(err))
+	}
+}
+
+// buildDataExport collects the data of the user and encodes it in format.
+func (u *Usecase) buildDataExport(ctx context.Context, id int64, format string) (DataExportFile, error) {
+	export, err := u.collectDataExport(ctx, id)
+	if err != nil {
+		return DataExportFile{}, errors.WithStack(err)
+	}
+
+	var content []byte
+	if format == DATA_EXPORT_FORMAT_NDJSON {
+		content, err = encodeDataExportNDJSON(export, dataExportFileName(id, DATA_EXPORT_FORMAT_NDJSON))
+	} else {
+		content, err = json.MarshalIndent(export, "", "  ")
+	}
+
+	if err != nil {
+		return DataExportFile{}, errors.WithStack(err)
+	}
+
+	return dataExportFile(id, format, content), nil
+}
+
+func (u *Usecase) collectDataExport(ctx context.Context, id int64) (dataExport, error) {
+	details, err := u.GetUserDetails(ctx, GetUserDetailsInput{
+		Id: id,
+	})
+
+	if err != nil {
+		return dataExport{}, errors.WithStack(err)
+	}
+
+	if details.IsNotFound {
+		return dataExport{}, errors.Errorf("user %d not found", id)
+	}
+
+	identities, err := u.getIdentities(ctx, id)
+	if err != nil {
+		return dataExport{}, errors.WithStack(err)
+	}
+
+	logins, err := u.Repository.GetLoginEventsByUserId(ctx, repository.GetLoginEventsByUserIdInput{
+		UserId: id,
+	})
+
+	if err != nil {
+		return dataExport{}, errors.WithStack(err)
+	}
+
+	changes, err := u.Repository.GetUserStatusChangesByUserId(ctx, repository.GetUserStatusChangesByUserIdInput{
+		UserId: id,
+	})
+
+	if err != nil {
+		return dataExport{}, errors.WithStack(err)
+	}
+
+	impersonations, err := u.Repository.GetImpersonationsByUserId(ctx, repository.GetImpersonationsByUserIdInput{
+		UserId: id,
+	})
+
+	if err != nil {
+		return dataExport{}, errors.WithStack(err)
+	}
+
+	impersonationUses, err := u.Repository.GetImpersonationUsesByUserId(ctx, repository.GetImpersonationUsesByUserIdInput{
+		UserId: id,
+	})
+
+	if err != nil {
+		return dataExport{}, errors.WithStack(err)
+	}
+
+	uses := make(map[int64][]dataExportImpersonationUse)
+	for _, use := range impersonationUses.Uses {
+		uses[use.ImpersonationId] = append(uses[use.ImpersonationId], dataExportImpersonationUse{
+			Method:    use.Method,
+			Path:      use.Path,
+			CreatedAt: use.CreatedAt,
+		})
+	}
+
+	user := details.User
+	export := dataExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: dataExportProfile{
+			Id:            user.Id,
+			FullName:      user.FullName,
+			PhoneNumber:   user.PhoneNumber,
+			Email:         user.Email,
+			EmailVerified: user.EmailVerified,
+			Language:      user.Language,
+			Status:        user.Status,
+			UserGroup:     user.UserGroup,
+			Roles:         user.Roles,
+			TotalLogin:    user.TotalLogin,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
+			Attributes:    user.Attributes,
+			Avatar:        (*dataExportAvatar)(user.Avatar),
+		},
+		Identities:     make([]dataExportIdentity, 0, len(identities)),
+		Logins:         make([]dataExportLogin, 0, len(logins.Events)),
+		StatusChanges:  make([]dataExportStatusChange, 0, len(changes.Changes)),
+		Impersonations: make([]dataExportImpersonation, 0, len(impersonations.Impersonations)),
+	}
+
+	for _, identity := range identities {
+		export.Identities = append(export.Identities, dataExportIdentity(identity))
+	}
+
+	for _, login := range logins.Events {
+		export.Logins = append(export.Logins, dataExportLogin(login))
+	}
+
+	for _, change := range changes.Changes {
+		export.StatusChanges = append(export.StatusChanges, dataExportStatusChange(change))
+	}
+
+	for _, impersonation := range impersonations.Impersonations {
+		impersonationUses := uses[impersonation.Id]
+		if impersonationUses == nil {
+			impersonationUses = []dataExportImpersonationUse{}
+		}
+
+		export.Impersonations = append(export.Impersonations, dataExportImpersonation{
+			Id:        impersonation.Id,
+			AdminId:   impersonation.AdminId,
+			Reason:    impersonation.Reason,
+			CreatedAt: impersonation.CreatedAt,
+			ExpiresAt: impersonation.ExpiresAt,
+			Uses:      impersonationUses,
+		})
+	}
+
+	return export, nil
+}
+
+// encodeDataExportNDJSON zips the export as the NDJSON file name, starting
+// with an "export" record that holds the time of the export.
+func encodeDataExportNDJSON(export dataExport, name string) ([]byte, error) {
+	records := []dataExportRecord{
+		{Type: "export", Data: map[string]time.Time{"exported_at": export.ExportedAt}},
+		{Type: "profile", Data: export.Profile},
+	}
+
+	for _, identity := range export.Identities {
+		records = append(records, dataExportRecord{Type: "identity", Data: identity})
+	}
+
+	for _, login := range export.Logins {
+		records = append(records, dataExportRecord{Type: "login", Data: login})
+	}
+
+	for _, change := range export.StatusChanges {
+		records = append(records, dataExportRecord{Type: "status_change", Data: change})
+	}
+
+	for _, impersonation := range export.Impersonations {
+		records = append(records, dataExportRecord{Type: "impersonation", Data: impersonation})
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	w, err := archive.Create(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	encoder := json.NewEncoder(w)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// dataExportFileName is the name of the export of the user in format, for an
+// NDJSON export the name of the file inside the zip archive.
+func dataExportFileName(id int64, format string) string {
+	return fmt.Sprintf("user-data-%d.%s", id, format)
+}
+
+func dataExportFile(id int64, format string, content []byte) DataExportFile {
+	if format == DATA_EXPORT_FORMAT_NDJSON {
+		return DataExportFile{
+			FileName:    fmt.Sprintf("user-data-%d.zip", id),
+			ContentType: "application/zip",
+			Content:     content,
+		}
+	}
+
+	return DataExportFile{
+		FileName:    dataExportFileName(id, DATA_EXPORT_FORMAT_JSON),
+		ContentType: "application/json",
+		Content:     content,
+	}
+}
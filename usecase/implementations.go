@@ -2,32 +2,71 @@ package usecase
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
-	"os"
-	"strconv"
+	"math"
+	"math/big"
+	"net"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/SawitProRecruitment/UserService/repository"
 	"github.com/SawitProRecruitment/UserService/utils"
 	"github.com/pkg/errors"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func (u *Usecase) RegisterNewUser(ctx context.Context, input RegisterNewUserInput) (RegisterNewUserOutput, error) {
-	var (
-		hashCostStr = os.Getenv("BCRYPT_COST")
-	)
+// passwordExpiredTokenLifespan is how long the restricted token returned for
+// an expired password can be used to set a new one.
+const passwordExpiredTokenLifespan = 15 * time.Minute
 
-	hashCost, err := strconv.Atoi(hashCostStr)
-	if err != nil {
-		log.Println("[WARN][RegisterNewUser] error when converting hashCost", err)
-	}
+// oidcFlowLifespan is how long a user may take to log in at an OpenID Connect
+// provider.
+const oidcFlowLifespan = 10 * time.Minute
 
-	if hashCost == 0 {
-		hashCost = 5
-	}
+// externalDefaultFullName is the name of a user created through an external
+// login when the provider or directory knows no usable one.
+const externalDefaultFullName = "User"
+
+// userStatusTransitions are the statuses a user can be moved to from each
+// status. Deleted is final.
+var userStatusTransitions = map[string][]string{
+	USER_STATUS_PENDING:     {USER_STATUS_ACTIVE, USER_STATUS_SUSPENDED, USER_STATUS_DELETED},
+	USER_STATUS_ACTIVE:      {USER_STATUS_SUSPENDED, USER_STATUS_DEACTIVATED, USER_STATUS_DELETED},
+	USER_STATUS_SUSPENDED:   {USER_STATUS_ACTIVE, USER_STATUS_DELETED},
+	USER_STATUS_DEACTIVATED: {USER_STATUS_ACTIVE, USER_STATUS_SUSPENDED, USER_STATUS_DELETED},
+}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), hashCost)
+// loginReactivationReason is recorded when a deactivated user logs in again.
+const loginReactivationReason = "logged in again"
+
+const (
+	accountDeletionReason    = "deleted by the user"
+	accountRestorationReason = "restored by the user"
+	// accountRestorationTokenLifespan is how long the restricted token
+	// returned by a login into a deleted account can be used to restore it
+	accountRestorationTokenLifespan = 15 * time.Minute
+	// purgedUserFullName is the name a deleted user keeps once purged
+	purgedUserFullName = "Deleted user"
+)
+
+const (
+	emailVerificationCodeDigits = 6
+	// maxEmailVerificationAttempts limits guessing of the short code, a new
+	// verification has to be requested afterwards
+	maxEmailVerificationAttempts = 5
+)
+
+func (u *Usecase) RegisterNewUser(ctx context.Context, input RegisterNewUserInput) (RegisterNewUserOutput, error) {
+	hashedPassword, err := u.PasswordHasher.Hash(input.Password)
 	if err != nil {
 		return RegisterNewUserOutput{}, errors.WithStack(err)
 	}
@@ -35,67 +74,105 @@ func (u *Usecase) RegisterNewUser(ctx context.Context, input RegisterNewUserInpu
 	output, err := u.Repository.InsertNewUser(ctx, repository.InsertNewUserInput{
 		PhoneNumber: input.PhoneNumber,
 		FullName:    input.FullName,
-		Password:    string(hashedPassword),
+		Password:    hashedPassword,
+		Email:       input.Email,
 	})
 
 	if err != nil {
 		return RegisterNewUserOutput{}, errors.WithStack(err)
 	}
 
-	if output.IsPhoneNumberExists {
+	if output.IsPhoneNumberExists || output.IsEmailExists {
 		return RegisterNewUserOutput{
-			IsPhoneNumberExists: true,
+			IsPhoneNumberExists: output.IsPhoneNumberExists,
+			IsEmailExists:       output.IsEmailExists,
 		}, nil
 	}
 
+	if input.Email != "" {
+		// the user can ask for another verification, the registration stands
+		err = u.sendEmailVerification(ctx, output.Id, input.Email, input.MailLanguage)
+		if err != nil {
+			log.Println("[WARN][RegisterNewUser] error when sending email verification", err)
+		}
+	}
+
 	return RegisterNewUserOutput{
 		Id: output.Id,
 	}, nil
 }
 
 func (u *Usecase) Login(ctx context.Context, input LoginInput) (LoginOutput, error) {
-	passwordRes, err := u.Repository.GetPasswordByPhoneNumber(ctx, repository.GetPasswordByPhoneNumberInput{
-		PhoneNumber: input.PhoneNumber,
-	})
-
+	authenticated, err := u.authenticatorFor(input).Authenticate(ctx, input)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return LoginOutput{
-				IsDataNotFound: true,
-			}, nil
-		}
-
 		return LoginOutput{}, errors.WithStack(err)
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(passwordRes.Password), []byte(input.Password))
+	if authenticated.IsInvalidCredentials {
+		return LoginOutput{
+			IsInvalidCredentials: true,
+		}, nil
+	}
 
+	admitted, err := u.admitUser(ctx, authenticated.Id)
 	if err != nil {
-		if err == bcrypt.ErrMismatchedHashAndPassword {
+		return LoginOutput{}, errors.WithStack(err)
+	}
+
+	switch admitted.Status {
+	case USER_STATUS_ACTIVE:
+	case USER_STATUS_PENDING:
+		return LoginOutput{
+			IsAccountPending: true,
+		}, nil
+	case USER_STATUS_SUSPENDED:
+		return LoginOutput{
+			IsAccountSuspended: true,
+		}, nil
+	case USER_STATUS_DELETED:
+		if !isRestorable(admitted) {
 			return LoginOutput{
-				IsPasswordWrong: true,
+				IsInvalidCredentials: true,
 			}, nil
 		}
 
-		return LoginOutput{}, errors.WithStack(err)
+		restorationToken, err := utils.GenerateScopedToken(authenticated.Id, utils.TOKEN_SCOPE_ACCOUNT_RESTORATION, accountRestorationTokenLifespan)
+		if err != nil {
+			return LoginOutput{}, errors.WithStack(err)
+		}
+
+		return LoginOutput{
+			IsAccountDeleted: true,
+			Token:            restorationToken,
+		}, nil
+	default:
+		return LoginOutput{
+			IsInvalidCredentials: true,
+		}, nil
 	}
 
-	jwtToken, err := utils.GenerateToken(passwordRes.Id)
+	if authenticated.IsPasswordExpired {
+		restrictedToken, err := utils.GenerateScopedToken(authenticated.Id, utils.TOKEN_SCOPE_PASSWORD_EXPIRED, passwordExpiredTokenLifespan)
+		if err != nil {
+			return LoginOutput{}, errors.WithStack(err)
+		}
+
+		return LoginOutput{
+			IsPasswordExpired: true,
+			Token:             restrictedToken,
+		}, nil
+	}
+
+	jwtToken, err := u.generateToken(ctx, authenticated.Id)
 
 	if err != nil {
 		return LoginOutput{}, errors.WithStack(err)
 	}
 
-	// TODO: use message broker here
-	go func(id int64) {
-		err := u.Repository.UpdateTotalLoginById(context.Background(), repository.UpdateTotalLoginByIdInput{
-			Id: id,
-		})
-
-		if err != nil {
-			log.Println("[ERROR][Login] error when UpdateTotalLoginById", errors.WithStack(err))
-		}
-	}(passwordRes.Id)
+	err = u.recordLogin(ctx, authenticated.Id, authenticated.Method, input.IP)
+	if err != nil {
+		return LoginOutput{}, errors.WithStack(err)
+	}
 
 	return LoginOutput{
 		Token: jwtToken,
@@ -111,9 +188,25 @@ func (u *Usecase) GetUserData(ctx context.Context, input GetUserDataInput) (GetU
 		return GetUserDataOutput{}, errors.WithStack(err)
 	}
 
+	var avatar *AvatarURLs
+	if outputRepo.AvatarKey != "" {
+		urls := u.avatarURLs(outputRepo.AvatarKey)
+		avatar = &urls
+	}
+
+	attributes, err := decodeAttributes(outputRepo.Attributes)
+	if err != nil {
+		return GetUserDataOutput{}, errors.WithStack(err)
+	}
+
 	return GetUserDataOutput{
-		PhoneNumber: outputRepo.PhoneNumber,
-		FullName:    outputRepo.FullName,
+		PhoneNumber:   outputRepo.PhoneNumber,
+		FullName:      outputRepo.FullName,
+		Language:      outputRepo.Language,
+		Email:         outputRepo.Email,
+		EmailVerified: outputRepo.EmailVerified,
+		Avatar:        avatar,
+		Attributes:    attributes,
 	}, nil
 }
 
@@ -134,17 +227,1791 @@ func (u *Usecase) UpdateUserData(ctx context.Context, input UpdateUserDataInput)
 		userData.FullName = input.FullName
 	}
 
+	if input.Language != "" {
+		userData.Language = input.Language
+	}
+
+	isEmailChanged := input.Email != "" && !strings.EqualFold(input.Email, userData.Email)
+	if input.Email != "" {
+		userData.Email = input.Email
+	}
+
+	var attributes []byte
+	if input.Attributes != nil {
+		var attributeErrors []AttributeError
+		attributes, attributeErrors, err = u.userAttributes(ctx, userData.Attributes, input.Attributes)
+		if err != nil {
+			return UpdateUserDataOutput{}, errors.WithStack(err)
+		}
+
+		if len(attributeErrors) > 0 {
+			return UpdateUserDataOutput{
+				AttributeErrors: attributeErrors,
+			}, nil
+		}
+	}
+
 	outputRepo, err := u.Repository.UpdateUserData(ctx, repository.UpdateUserDataInput{
 		Id:          input.Id,
 		PhoneNumber: userData.PhoneNumber,
 		FullName:    userData.FullName,
+		Language:    userData.Language,
+		Email:       userData.Email,
+		Attributes:  attributes,
 	})
 
 	if err != nil {
 		return UpdateUserDataOutput{}, errors.WithStack(err)
 	}
 
-	return UpdateUserDataOutput{
-		IsPhoneNumberExists: outputRepo.IsPhoneNumberExists,
+	if outputRepo.IsPhoneNumberExists || outputRepo.IsEmailExists {
+		return UpdateUserDataOutput{
+			IsPhoneNumberExists: outputRepo.IsPhoneNumberExists,
+			IsEmailExists:       outputRepo.IsEmailExists,
+		}, nil
+	}
+
+	if isEmailChanged {
+		err = u.sendEmailVerification(ctx, input.Id, userData.Email, input.MailLanguage)
+		if err != nil {
+			log.Println("[WARN][UpdateUserData] error when sending email verification", err)
+		}
+	}
+
+	return UpdateUserDataOutput{}, nil
+}
+
+func (u *Usecase) PatchUserData(ctx context.Context, input PatchUserDataInput) (PatchUserDataOutput, error) {
+	repoInput := repository.PatchUserDataInput{
+		Id:          input.Id,
+		FullName:    input.FullName,
+		PhoneNumber: input.PhoneNumber,
+		Language:    input.Language,
+		Email:       input.Email,
+	}
+
+	var outputRepo repository.PatchUserDataOutput
+	for attempt := 1; ; attempt++ {
+		// the attributes are only written while they are still the ones the
+		// patch was applied to
+		if input.AttributesPatch != nil {
+			userData, err := u.Repository.GetUserDataById(ctx, repository.GetUserDataByIdInput{
+				Id: input.Id,
+			})
+
+			if err != nil {
+				return PatchUserDataOutput{}, errors.WithStack(err)
+			}
+
+			attributes, attributeErrors, err := u.patchedAttributes(ctx, userData.Attributes, input.AttributesPatch)
+			if err != nil {
+				return PatchUserDataOutput{}, errors.WithStack(err)
+			}
+
+			if len(attributeErrors) > 0 {
+				return PatchUserDataOutput{
+					AttributeErrors: attributeErrors,
+				}, nil
+			}
+
+			repoInput.Attributes = attributes
+			repoInput.ExpectedAttributes = userData.Attributes
+		}
+
+		var err error
+		outputRepo, err = u.Repository.PatchUserData(ctx, repoInput)
+		if err != nil {
+			return PatchUserDataOutput{}, errors.WithStack(err)
+		}
+
+		if !outputRepo.IsNotUpdated {
+			break
+		}
+
+		if input.AttributesPatch == nil {
+			return PatchUserDataOutput{}, errors.Wrap(sql.ErrNoRows, "user not found")
+		}
+
+		if attempt == PATCH_USER_DATA_ATTEMPTS {
+			return PatchUserDataOutput{
+				IsConflict: true,
+			}, nil
+		}
+	}
+
+	if outputRepo.IsPhoneNumberExists || outputRepo.IsEmailExists {
+		return PatchUserDataOutput{
+			IsPhoneNumberExists: outputRepo.IsPhoneNumberExists,
+			IsEmailExists:       outputRepo.IsEmailExists,
+		}, nil
+	}
+
+	if input.Email != nil && *input.Email != "" && !strings.EqualFold(*input.Email, outputRepo.PreviousEmail) {
+		err := u.sendEmailVerification(ctx, input.Id, *input.Email, input.MailLanguage)
+		if err != nil {
+			log.Println("[WARN][PatchUserData] error when sending email verification", err)
+		}
+	}
+
+	return PatchUserDataOutput{}, nil
+}
+
+func (u *Usecase) SetPassword(ctx context.Context, input SetPasswordInput) (SetPasswordOutput, error) {
+	passwordRes, err := u.Repository.GetPasswordById(ctx, repository.GetPasswordByIdInput{
+		Id: input.Id,
+	})
+
+	if err != nil {
+		return SetPasswordOutput{}, errors.WithStack(err)
+	}
+
+	if passwordRes.Password == "" {
+		// users created through an external login have no password to confirm
+		return SetPasswordOutput{
+			IsPasswordWrong: true,
+		}, nil
+	}
+
+	isPasswordMatch, err := u.PasswordHasher.Verify(passwordRes.Password, input.CurrentPassword)
+	if err != nil {
+		return SetPasswordOutput{}, errors.WithStack(err)
+	}
+
+	if !isPasswordMatch {
+		return SetPasswordOutput{
+			IsPasswordWrong: true,
+		}, nil
+	}
+
+	isPasswordReused, err := u.changePassword(ctx, input.Id, passwordRes.Password, input.NewPassword)
+	if err != nil {
+		return SetPasswordOutput{}, errors.WithStack(err)
+	}
+
+	return SetPasswordOutput{
+		IsPasswordReused: isPasswordReused,
+	}, nil
+}
+
+func (u *Usecase) SetExpiredPassword(ctx context.Context, input SetExpiredPasswordInput) (SetExpiredPasswordOutput, error) {
+	passwordRes, err := u.Repository.GetPasswordById(ctx, repository.GetPasswordByIdInput{
+		Id: input.Id,
+	})
+
+	if err != nil {
+		return SetExpiredPasswordOutput{}, errors.WithStack(err)
+	}
+
+	isPasswordReused, err := u.changePassword(ctx, input.Id, passwordRes.Password, input.NewPassword)
+	if err != nil {
+		return SetExpiredPasswordOutput{}, errors.WithStack(err)
+	}
+
+	if isPasswordReused {
+		return SetExpiredPasswordOutput{
+			IsPasswordReused: true,
+		}, nil
+	}
+
+	jwtToken, err := u.generateToken(ctx, input.Id)
+	if err != nil {
+		return SetExpiredPasswordOutput{}, errors.WithStack(err)
+	}
+
+	// the login that found the password expired ends here
+	err = u.recordLogin(ctx, input.Id, LOGIN_METHOD_PASSWORD, input.IP)
+	if err != nil {
+		return SetExpiredPasswordOutput{}, errors.WithStack(err)
+	}
+
+	return SetExpiredPasswordOutput{
+		Token: jwtToken,
+	}, nil
+}
+
+func (u *Usecase) SendEmailVerification(ctx context.Context, input SendEmailVerificationInput) (SendEmailVerificationOutput, error) {
+	userData, err := u.Repository.GetUserDataById(ctx, repository.GetUserDataByIdInput{
+		Id: input.Id,
+	})
+
+	if err != nil {
+		return SendEmailVerificationOutput{}, errors.WithStack(err)
+	}
+
+	if userData.Email == "" {
+		return SendEmailVerificationOutput{
+			IsEmailMissing: true,
+		}, nil
+	}
+
+	if userData.EmailVerified {
+		return SendEmailVerificationOutput{
+			IsEmailVerified: true,
+		}, nil
+	}
+
+	err = u.sendEmailVerification(ctx, input.Id, userData.Email, input.MailLanguage)
+	if err != nil {
+		return SendEmailVerificationOutput{}, errors.WithStack(err)
+	}
+
+	return SendEmailVerificationOutput{}, nil
+}
+
+func (u *Usecase) VerifyEmailByToken(ctx context.Context, input VerifyEmailByTokenInput) (VerifyEmailOutput, error) {
+	verification, err := u.Repository.GetEmailVerificationByTokenHash(ctx, repository.GetEmailVerificationByTokenHashInput{
+		TokenHash: hashVerificationSecret(input.Token),
+	})
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return VerifyEmailOutput{
+				IsInvalid: true,
+			}, nil
+		}
+
+		return VerifyEmailOutput{}, errors.WithStack(err)
+	}
+
+	if time.Now().After(verification.ExpiresAt) {
+		return VerifyEmailOutput{
+			IsInvalid: true,
+		}, nil
+	}
+
+	return u.verifyEmail(ctx, verification)
+}
+
+func (u *Usecase) VerifyEmailByCode(ctx context.Context, input VerifyEmailByCodeInput) (VerifyEmailOutput, error) {
+	verification, err := u.Repository.GetEmailVerificationByUserId(ctx, repository.GetEmailVerificationByUserIdInput{
+		UserId: input.Id,
+	})
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return VerifyEmailOutput{
+				IsInvalid: true,
+			}, nil
+		}
+
+		return VerifyEmailOutput{}, errors.WithStack(err)
+	}
+
+	if time.Now().After(verification.ExpiresAt) || verification.Attempts >= maxEmailVerificationAttempts {
+		return VerifyEmailOutput{
+			IsInvalid: true,
+		}, nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(verification.CodeHash), []byte(hashVerificationSecret(input.Code))) != 1 {
+		err = u.Repository.IncrementEmailVerificationAttempts(ctx, repository.IncrementEmailVerificationAttemptsInput{
+			Id: verification.Id,
+		})
+
+		if err != nil {
+			return VerifyEmailOutput{}, errors.WithStack(err)
+		}
+
+		return VerifyEmailOutput{
+			IsInvalid: true,
+		}, nil
+	}
+
+	return u.verifyEmail(ctx, verification)
+}
+
+func (u *Usecase) GetIdentities(ctx context.Context, input GetIdentitiesInput) (GetIdentitiesOutput, error) {
+	identities, err := u.getIdentities(ctx, input.Id)
+	if err != nil {
+		return GetIdentitiesOutput{}, errors.WithStack(err)
+	}
+
+	return GetIdentitiesOutput{
+		Identities: identities,
+	}, nil
+}
+
+func (u *Usecase) AddIdentity(ctx context.Context, input AddIdentityInput) (AddIdentityOutput, error) {
+	output, err := u.Repository.InsertIdentity(ctx, repository.InsertIdentityInput{
+		UserId:     input.Id,
+		Type:       input.Type,
+		Identifier: input.Identifier,
+	})
+
+	if err != nil {
+		return AddIdentityOutput{}, errors.WithStack(err)
+	}
+
+	if output.IsIdentifierExists {
+		return AddIdentityOutput{
+			IsIdentifierExists: true,
+		}, nil
+	}
+
+	if input.Type == IDENTITY_TYPE_EMAIL {
+		// the user can ask for another verification, the identity stands
+		err = u.sendEmailVerification(ctx, input.Id, input.Identifier, input.MailLanguage)
+		if err != nil {
+			log.Println("[WARN][AddIdentity] error when sending email verification", err)
+		}
+	}
+
+	return AddIdentityOutput{
+		Identity: Identity{
+			Id:         output.Id,
+			Type:       input.Type,
+			Identifier: input.Identifier,
+		},
 	}, nil
 }
+
+func (u *Usecase) RemoveIdentity(ctx context.Context, input RemoveIdentityInput) (RemoveIdentityOutput, error) {
+	refusal, isRefused, err := u.checkIdentityRemoval(ctx, input)
+	if err != nil {
+		return RemoveIdentityOutput{}, errors.WithStack(err)
+	}
+
+	if isRefused {
+		return refusal, nil
+	}
+
+	output, err := u.Repository.DeleteIdentity(ctx, repository.DeleteIdentityInput{
+		Id:     input.IdentityId,
+		UserId: input.Id,
+	})
+
+	if err != nil {
+		return RemoveIdentityOutput{}, errors.WithStack(err)
+	}
+
+	if !output.IsDeleted {
+		// the identities or the password changed meanwhile, tell why the
+		// query refused
+		refusal, isRefused, err := u.checkIdentityRemoval(ctx, input)
+		if err != nil {
+			return RemoveIdentityOutput{}, errors.WithStack(err)
+		}
+
+		if !isRefused {
+			refusal = RemoveIdentityOutput{
+				IsLastLogin: true,
+			}
+		}
+
+		return refusal, nil
+	}
+
+	return RemoveIdentityOutput{}, nil
+}
+
+// checkIdentityRemoval tells whether the identity of input may be removed,
+// and if not the output refusing it. DeleteIdentityQuery makes the same
+// checks.
+func (u *Usecase) checkIdentityRemoval(ctx context.Context, input RemoveIdentityInput) (RemoveIdentityOutput, bool, error) {
+	identities, err := u.getIdentities(ctx, input.Id)
+	if err != nil {
+		return RemoveIdentityOutput{}, false, errors.WithStack(err)
+	}
+
+	var (
+		identity       Identity
+		found          bool
+		externalLogins int
+	)
+	for _, candidate := range identities {
+		if candidate.Id == input.IdentityId {
+			identity, found = candidate, true
+		}
+
+		if isExternalIdentity(candidate.Type) {
+			externalLogins++
+		}
+	}
+
+	if !found {
+		return RemoveIdentityOutput{
+			IsNotFound: true,
+		}, true, nil
+	}
+
+	if identity.IsPrimary && identity.Type == IDENTITY_TYPE_PHONE {
+		return RemoveIdentityOutput{
+			IsPrimaryPhoneNumber: true,
+		}, true, nil
+	}
+
+	if isExternalIdentity(identity.Type) && externalLogins == 1 {
+		passwordRes, err := u.Repository.GetPasswordById(ctx, repository.GetPasswordByIdInput{
+			Id: input.Id,
+		})
+
+		if err != nil {
+			return RemoveIdentityOutput{}, false, errors.WithStack(err)
+		}
+
+		// without a password the phone numbers and emails can not log in
+		if passwordRes.Password == "" {
+			return RemoveIdentityOutput{
+				IsLastLogin: true,
+			}, true, nil
+		}
+	}
+
+	return RemoveIdentityOutput{}, false, nil
+}
+
+func (u *Usecase) SendIdentityVerification(ctx context.Context, input SendIdentityVerificationInput) (SendIdentityVerificationOutput, error) {
+	identity, found, err := u.getIdentity(ctx, input.Id, input.IdentityId)
+	if err != nil {
+		return SendIdentityVerificationOutput{}, errors.WithStack(err)
+	}
+
+	if !found {
+		return SendIdentityVerificationOutput{
+			IsNotFound: true,
+		}, nil
+	}
+
+	if identity.Type != IDENTITY_TYPE_EMAIL {
+		return SendIdentityVerificationOutput{
+			IsNotVerifiable: true,
+		}, nil
+	}
+
+	if identity.VerifiedAt != nil {
+		return SendIdentityVerificationOutput{
+			IsVerified: true,
+		}, nil
+	}
+
+	err = u.sendEmailVerification(ctx, input.Id, identity.Identifier, input.MailLanguage)
+	if err != nil {
+		return SendIdentityVerificationOutput{}, errors.WithStack(err)
+	}
+
+	return SendIdentityVerificationOutput{}, nil
+}
+
+func (u *Usecase) StartOIDCLogin(ctx context.Context, input StartOIDCLoginInput) (StartOIDCLoginOutput, error) {
+	provider, ok := u.OIDCProviders[input.Provider]
+	if !ok {
+		return StartOIDCLoginOutput{
+			IsProviderUnknown: true,
+		}, nil
+	}
+
+	flow := utils.OIDCFlow{
+		Provider: input.Provider,
+	}
+
+	for _, secret := range []*string{&flow.State, &flow.Nonce, &flow.CodeVerifier} {
+		value, err := generateRandomToken()
+		if err != nil {
+			return StartOIDCLoginOutput{}, errors.WithStack(err)
+		}
+		*secret = value
+	}
+
+	authorizationURL, err := provider.AuthCodeURL(ctx, flow.State, flow.Nonce, flow.CodeVerifier)
+	if err != nil {
+		return StartOIDCLoginOutput{}, errors.WithStack(err)
+	}
+
+	flowToken, err := utils.GenerateOIDCFlowToken(flow, oidcFlowLifespan)
+	if err != nil {
+		return StartOIDCLoginOutput{}, errors.WithStack(err)
+	}
+
+	return StartOIDCLoginOutput{
+		AuthorizationURL: authorizationURL,
+		FlowToken:        flowToken,
+	}, nil
+}
+
+func (u *Usecase) FinishOIDCLogin(ctx context.Context, input FinishOIDCLoginInput) (FinishOIDCLoginOutput, error) {
+	provider, ok := u.OIDCProviders[input.Provider]
+	if !ok {
+		return FinishOIDCLoginOutput{
+			IsProviderUnknown: true,
+		}, nil
+	}
+
+	flow, err := utils.ParseOIDCFlowToken(input.FlowToken)
+	if err != nil {
+		log.Println("[WARN][FinishOIDCLogin] invalid flow token", err)
+		return FinishOIDCLoginOutput{
+			IsInvalid: true,
+		}, nil
+	}
+
+	// the state ties the callback to the browser that started the login
+	if flow.Provider != input.Provider || input.Code == "" ||
+		subtle.ConstantTimeCompare([]byte(flow.State), []byte(input.State)) != 1 {
+		return FinishOIDCLoginOutput{
+			IsInvalid: true,
+		}, nil
+	}
+
+	claims, err := provider.Exchange(ctx, input.Code, flow.CodeVerifier, flow.Nonce)
+	if err != nil {
+		if errors.Is(err, utils.ErrOIDCInvalid) {
+			log.Println("[WARN][FinishOIDCLogin] login refused", err)
+			return FinishOIDCLoginOutput{
+				IsInvalid: true,
+			}, nil
+		}
+
+		return FinishOIDCLoginOutput{}, errors.WithStack(err)
+	}
+
+	// a provider only vouches for the addresses of the domains it is trusted
+	// with, any provider could claim any address
+	email := ""
+	if _, domain, ok := strings.Cut(claims.Email, "@"); ok && claims.EmailVerified && provider.IsEmailDomain(domain) {
+		email = claims.Email
+	}
+
+	id, err := u.resolveExternalUser(ctx, input.Provider, claims.Subject, externalFullName(claims.Name, claims.Email), email)
+	if err != nil {
+		return FinishOIDCLoginOutput{}, errors.WithStack(err)
+	}
+
+	admitted, err := u.admitUser(ctx, id)
+	if err != nil {
+		return FinishOIDCLoginOutput{}, errors.WithStack(err)
+	}
+
+	switch admitted.Status {
+	case USER_STATUS_ACTIVE:
+	case USER_STATUS_PENDING:
+		return FinishOIDCLoginOutput{
+			IsAccountPending: true,
+		}, nil
+	case USER_STATUS_SUSPENDED:
+		return FinishOIDCLoginOutput{
+			IsAccountSuspended: true,
+		}, nil
+	case USER_STATUS_DELETED:
+		if !isRestorable(admitted) {
+			return FinishOIDCLoginOutput{
+				IsInvalid: true,
+			}, nil
+		}
+
+		restorationToken, err := utils.GenerateScopedToken(id, utils.TOKEN_SCOPE_ACCOUNT_RESTORATION, accountRestorationTokenLifespan)
+		if err != nil {
+			return FinishOIDCLoginOutput{}, errors.WithStack(err)
+		}
+
+		return FinishOIDCLoginOutput{
+			IsAccountDeleted: true,
+			Token:            restorationToken,
+		}, nil
+	default:
+		return FinishOIDCLoginOutput{
+			IsInvalid: true,
+		}, nil
+	}
+
+	jwtToken, err := u.generateToken(ctx, id)
+	if err != nil {
+		return FinishOIDCLoginOutput{}, errors.WithStack(err)
+	}
+
+	err = u.recordLogin(ctx, id, input.Provider, input.IP)
+	if err != nil {
+		return FinishOIDCLoginOutput{}, errors.WithStack(err)
+	}
+
+	return FinishOIDCLoginOutput{
+		Token: jwtToken,
+	}, nil
+}
+
+func (u *Usecase) HasPermission(ctx context.Context, input HasPermissionInput) (HasPermissionOutput, error) {
+	if len(input.Roles) == 0 {
+		return HasPermissionOutput{}, nil
+	}
+
+	output, err := u.Repository.HasPermission(ctx, repository.HasPermissionInput{
+		Roles:      input.Roles,
+		Permission: input.Permission,
+	})
+
+	if err != nil {
+		return HasPermissionOutput{}, errors.WithStack(err)
+	}
+
+	return HasPermissionOutput{
+		IsAllowed: output.IsAllowed,
+	}, nil
+}
+
+func (u *Usecase) SearchUsers(ctx context.Context, input SearchUsersInput) (SearchUsersOutput, error) {
+	repoInput := repository.SearchUsersInput{
+		PhonePrefix: input.PhonePrefix,
+		Name:        input.Name,
+		CreatedFrom: input.CreatedFrom,
+		CreatedTo:   input.CreatedTo,
+		Status:      input.Status,
+		Sort:        input.Sort,
+		// one more tells whether there is a next page
+		Limit: input.Limit + 1,
+	}
+
+	if input.Cursor != "" {
+		cursor, ok := decodeUserCursor(input.Sort, input.Cursor)
+		if !ok {
+			return SearchUsersOutput{
+				IsCursorInvalid: true,
+			}, nil
+		}
+
+		repoInput.AfterId = cursor.Id
+		repoInput.AfterValue = cursor.Value
+	}
+
+	output, err := u.Repository.SearchUsers(ctx, repoInput)
+	if err != nil {
+		return SearchUsersOutput{}, errors.WithStack(err)
+	}
+
+	users := output.Users
+	nextCursor := ""
+	if len(users) > input.Limit {
+		users = users[:input.Limit]
+		nextCursor = encodeUserCursor(input.Sort, users[len(users)-1])
+	}
+
+	summaries := make([]UserSummary, 0, len(users))
+	for _, user := range users {
+		summaries = append(summaries, UserSummary(user))
+	}
+
+	return SearchUsersOutput{
+		Users:      summaries,
+		NextCursor: nextCursor,
+	}, nil
+}
+
+func (u *Usecase) GetUserDetails(ctx context.Context, input GetUserDetailsInput) (GetUserDetailsOutput, error) {
+	output, err := u.Repository.GetUserDetailsById(ctx, repository.GetUserDetailsByIdInput{
+		Id: input.Id,
+	})
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return GetUserDetailsOutput{
+				IsNotFound: true,
+			}, nil
+		}
+
+		return GetUserDetailsOutput{}, errors.WithStack(err)
+	}
+
+	roles, err := u.Repository.GetRolesByUserId(ctx, repository.GetRolesByUserIdInput{
+		UserId: input.Id,
+	})
+
+	if err != nil {
+		return GetUserDetailsOutput{}, errors.WithStack(err)
+	}
+
+	attributes, err := decodeAttributes(output.Attributes)
+	if err != nil {
+		return GetUserDetailsOutput{}, errors.WithStack(err)
+	}
+
+	var avatar *AvatarURLs
+	if output.AvatarKey != "" {
+		urls := u.avatarURLs(output.AvatarKey)
+		avatar = &urls
+	}
+
+	return GetUserDetailsOutput{
+		User: UserDetails{
+			Id:            output.Id,
+			FullName:      output.FullName,
+			PhoneNumber:   output.PhoneNumber,
+			Email:         output.Email,
+			EmailVerified: output.EmailVerified,
+			Language:      output.Language,
+			Status:        output.Status,
+			UserGroup:     output.UserGroup,
+			Roles:         roles.Roles,
+			TotalLogin:    output.TotalLogin,
+			CreatedAt:     output.CreatedAt,
+			UpdatedAt:     output.UpdatedAt,
+			UpdatedBy:     output.UpdatedBy,
+			Attributes:    attributes,
+			Avatar:        avatar,
+		},
+	}, nil
+}
+
+func (u *Usecase) GetUserStatus(ctx context.Context, input GetUserStatusInput) (GetUserStatusOutput, error) {
+	output, err := u.Repository.GetUserStatusById(ctx, repository.GetUserStatusByIdInput{
+		Id: input.Id,
+	})
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return GetUserStatusOutput{
+				IsNotFound: true,
+			}, nil
+		}
+
+		return GetUserStatusOutput{}, errors.WithStack(err)
+	}
+
+	return GetUserStatusOutput{
+		Status: output.Status,
+	}, nil
+}
+
+func (u *Usecase) ChangeUserStatus(ctx context.Context, input ChangeUserStatusInput) (ChangeUserStatusOutput, error) {
+	current, err := u.GetUserStatus(ctx, GetUserStatusInput{
+		Id: input.Id,
+	})
+
+	if err != nil {
+		return ChangeUserStatusOutput{}, errors.WithStack(err)
+	}
+
+	if current.IsNotFound {
+		return ChangeUserStatusOutput{
+			IsNotFound: true,
+		}, nil
+	}
+
+	if !isUserStatusTransition(current.Status, input.Status) {
+		return ChangeUserStatusOutput{
+			IsTransitionNotAllowed: true,
+		}, nil
+	}
+
+	isChanged, err := u.changeUserStatus(ctx, input.Id, current.Status, input.Status, input.Reason, input.ChangedBy)
+	if err != nil {
+		return ChangeUserStatusOutput{}, errors.WithStack(err)
+	}
+
+	return ChangeUserStatusOutput{
+		// someone else changed the status meanwhile
+		IsTransitionNotAllowed: !isChanged,
+	}, nil
+}
+
+func (u *Usecase) DeactivateUser(ctx context.Context, input DeactivateUserInput) (DeactivateUserOutput, error) {
+	confirmation, err := u.confirmPassword(ctx, input.Id, input.Password, input.AuthenticatedAt)
+	if err != nil {
+		return DeactivateUserOutput{}, errors.WithStack(err)
+	}
+
+	if confirmation.IsPasswordWrong || confirmation.IsLoginRequired {
+		return DeactivateUserOutput{
+			IsPasswordWrong: confirmation.IsPasswordWrong,
+			IsLoginRequired: confirmation.IsLoginRequired,
+		}, nil
+	}
+
+	// tokens are only accepted while the user is active
+	isChanged, err := u.changeUserStatus(ctx, input.Id, USER_STATUS_ACTIVE, USER_STATUS_DEACTIVATED, input.Reason, input.Id)
+	if err != nil {
+		return DeactivateUserOutput{}, errors.WithStack(err)
+	}
+
+	if !isChanged {
+		return DeactivateUserOutput{}, errors.Errorf("status of user %d changed concurrently", input.Id)
+	}
+
+	return DeactivateUserOutput{}, nil
+}
+
+func (u *Usecase) GetUserStatusChanges(ctx context.Context, input GetUserStatusChangesInput) (GetUserStatusChangesOutput, error) {
+	current, err := u.GetUserStatus(ctx, GetUserStatusInput{
+		Id: input.Id,
+	})
+
+	if err != nil {
+		return GetUserStatusChangesOutput{}, errors.WithStack(err)
+	}
+
+	if current.IsNotFound {
+		return GetUserStatusChangesOutput{
+			IsNotFound: true,
+		}, nil
+	}
+
+	output, err := u.Repository.GetUserStatusChangesByUserId(ctx, repository.GetUserStatusChangesByUserIdInput{
+		UserId: input.Id,
+	})
+
+	if err != nil {
+		return GetUserStatusChangesOutput{}, errors.WithStack(err)
+	}
+
+	changes := make([]UserStatusChange, 0, len(output.Changes))
+	for _, change := range output.Changes {
+		changes = append(changes, UserStatusChange(change))
+	}
+
+	return GetUserStatusChangesOutput{
+		Changes: changes,
+	}, nil
+}
+
+func (u *Usecase) verifyEmail(ctx context.Context, verification repository.GetEmailVerificationOutput) (VerifyEmailOutput, error) {
+	output, err := u.Repository.VerifyEmail(ctx, repository.VerifyEmailInput{
+		UserId: verification.UserId,
+		Email:  verification.Email,
+	})
+
+	if err != nil {
+		return VerifyEmailOutput{}, errors.WithStack(err)
+	}
+
+	return VerifyEmailOutput{
+		IsInvalid: output.IsEmailChanged,
+	}, nil
+}
+
+// sendEmailVerification replaces any pending verification of the user and
+// mails a link with a token and a short code, either one verifies email.
+func (u *Usecase) sendEmailVerification(ctx context.Context, id int64, email, lang string) error {
+	token, err := generateRandomToken()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	code, err := generateVerificationCode()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = u.Repository.UpsertEmailVerification(ctx, repository.UpsertEmailVerificationInput{
+		UserId:    id,
+		Email:     email,
+		TokenHash: hashVerificationSecret(token),
+		CodeHash:  hashVerificationSecret(code),
+		ExpiresAt: time.Now().Add(u.EmailVerificationLifespan),
+	})
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	params := map[string]interface{}{
+		"link":    strings.ReplaceAll(u.EmailVerificationLink, "{token}", url.QueryEscape(token)),
+		"code":    code,
+		"minutes": int(u.EmailVerificationLifespan.Minutes()),
+	}
+
+	err = u.MailSender.Send(ctx, utils.Mail{
+		To:      email,
+		Subject: utils.Localize(lang, "EMAIL_VERIFICATION_SUBJECT", nil),
+		Body:    utils.Localize(lang, "EMAIL_VERIFICATION_BODY", params),
+	})
+
+	return errors.WithStack(err)
+}
+
+func (u *Usecase) getIdentities(ctx context.Context, id int64) ([]Identity, error) {
+	output, err := u.Repository.GetIdentitiesByUserId(ctx, repository.GetIdentitiesByUserIdInput{
+		UserId: id,
+	})
+
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	identities := make([]Identity, 0, len(output.Identities))
+	for _, identity := range output.Identities {
+		identities = append(identities, Identity(identity))
+	}
+
+	return identities, nil
+}
+
+// getIdentity finds the identity identityId among the identities of the user
+// id, so that users can only reach their own identities.
+func (u *Usecase) getIdentity(ctx context.Context, id, identityId int64) (Identity, bool, error) {
+	identities, err := u.getIdentities(ctx, id)
+	if err != nil {
+		return Identity{}, false, errors.WithStack(err)
+	}
+
+	for _, identity := range identities {
+		if identity.Id == identityId {
+			return identity, true, nil
+		}
+	}
+
+	return Identity{}, false, nil
+}
+
+// isExternalIdentity reports whether identities of identityType are logins at
+// an external login provider or directory.
+func isExternalIdentity(identityType string) bool {
+	return identityType != IDENTITY_TYPE_PHONE && identityType != IDENTITY_TYPE_EMAIL
+}
+
+// resolveExternalUser returns the user that logged in as subject at the
+// provider or directory identityType: the user the subject is linked to, else
+// the user whose verified email the provider or directory vouches for too,
+// who gets linked, else a new user named fullName. email must be empty unless
+// it is vouched for.
+func (u *Usecase) resolveExternalUser(ctx context.Context, identityType, subject, fullName, email string) (int64, error) {
+	linked, err := u.Repository.GetUserIdByIdentity(ctx, repository.GetUserIdByIdentityInput{
+		Type:       identityType,
+		Identifier: subject,
+	})
+
+	if err == nil {
+		return linked.UserId, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, errors.WithStack(err)
+	}
+
+	if email != "" {
+		owner, err := u.Repository.GetUserIdByIdentity(ctx, repository.GetUserIdByIdentityInput{
+			Type:       IDENTITY_TYPE_EMAIL,
+			Identifier: email,
+		})
+
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return 0, errors.WithStack(err)
+		}
+
+		// an unverified email may have been added by someone else than its owner
+		if err == nil && owner.IsVerified {
+			output, err := u.Repository.InsertIdentity(ctx, repository.InsertIdentityInput{
+				UserId:     owner.UserId,
+				Type:       identityType,
+				Identifier: subject,
+				IsVerified: true,
+			})
+
+			if err != nil {
+				return 0, errors.WithStack(err)
+			}
+
+			if output.IsIdentifierExists {
+				return 0, errors.Errorf("subject of %s was linked concurrently", identityType)
+			}
+
+			return owner.UserId, nil
+		}
+	}
+
+	output, err := u.Repository.InsertExternalUser(ctx, repository.InsertExternalUserInput{
+		FullName:   fullName,
+		Type:       identityType,
+		Identifier: subject,
+		Email:      email,
+	})
+
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	return output.Id, nil
+}
+
+// DeleteUser deletes the account of the user. The tokens of the user stop
+// working right away, the account is purged after the grace period unless the
+// user restores it by logging in.
+func (u *Usecase) DeleteUser(ctx context.Context, input DeleteUserInput) (DeleteUserOutput, error) {
+	confirmation, err := u.confirmPassword(ctx, input.Id, input.Password, input.AuthenticatedAt)
+	if err != nil {
+		return DeleteUserOutput{}, errors.WithStack(err)
+	}
+
+	if confirmation.IsPasswordWrong || confirmation.IsLoginRequired {
+		return DeleteUserOutput{
+			IsPasswordWrong: confirmation.IsPasswordWrong,
+			IsLoginRequired: confirmation.IsLoginRequired,
+		}, nil
+	}
+
+	purgeAt := time.Now().Add(u.AccountDeletionGracePeriod)
+
+	output, err := u.Repository.DeleteUser(ctx, repository.DeleteUserInput{
+		Id:      input.Id,
+		Reason:  accountDeletionReason,
+		PurgeAt: purgeAt,
+	})
+
+	if err != nil {
+		return DeleteUserOutput{}, errors.WithStack(err)
+	}
+
+	if !output.IsDeleted {
+		return DeleteUserOutput{}, errors.Errorf("status of user %d changed concurrently", input.Id)
+	}
+
+	return DeleteUserOutput{
+		PurgeAt: purgeAt,
+	}, nil
+}
+
+// RestoreUser makes a deleted account active again, as long as it has not
+// been purged.
+func (u *Usecase) RestoreUser(ctx context.Context, input RestoreUserInput) (RestoreUserOutput, error) {
+	output, err := u.Repository.RestoreUser(ctx, repository.RestoreUserInput{
+		Id:     input.Id,
+		Reason: accountRestorationReason,
+	})
+
+	if err != nil {
+		return RestoreUserOutput{}, errors.WithStack(err)
+	}
+
+	return RestoreUserOutput{
+		IsNotRestorable: !output.IsRestored,
+	}, nil
+}
+
+// PurgeDeletedUsers anonymizes the accounts whose grace period is over. The
+// rows are kept so that the status changes and the changes made by the user
+// as an admin keep pointing somewhere.
+func (u *Usecase) PurgeDeletedUsers(ctx context.Context, input PurgeDeletedUsersInput) (PurgeDeletedUsersOutput, error) {
+	output, err := u.Repository.PurgeDeletedUsers(ctx, repository.PurgeDeletedUsersInput{
+		FullName: purgedUserFullName,
+	})
+
+	if err != nil {
+		return PurgeDeletedUsersOutput{}, errors.WithStack(err)
+	}
+
+	for _, key := range output.AvatarKeys {
+		u.deleteAvatar(ctx, key)
+	}
+
+	return PurgeDeletedUsersOutput{
+		Count: output.Count,
+	}, nil
+}
+
+// ExportUserData exports the data kept about the user. Users with more
+// records than DataExportSyncMaxRecords get their export built in the
+// background, to be fetched with GetDataExport. Until it expires they get the
+// same export again instead of a new one.
+func (u *Usecase) ExportUserData(ctx context.Context, input ExportUserDataInput) (ExportUserDataOutput, error) {
+	count, err := u.Repository.CountUserRecords(ctx, repository.CountUserRecordsInput{
+		UserId: input.Id,
+	})
+
+	if err != nil {
+		return ExportUserDataOutput{}, errors.WithStack(err)
+	}
+
+	if count.Count <= int64(u.DataExportSyncMaxRecords) {
+		file, err := u.buildDataExport(ctx, input.Id, input.Format)
+		if err != nil {
+			return ExportUserDataOutput{}, errors.WithStack(err)
+		}
+
+		return ExportUserDataOutput{
+			File: file,
+		}, nil
+	}
+
+	existing, err := u.Repository.GetOpenDataExport(ctx, repository.GetOpenDataExportInput{
+		UserId: input.Id,
+		Format: input.Format,
+	})
+
+	if err == nil {
+		return ExportUserDataOutput{
+			IsAsync:   true,
+			ExportId:  existing.Id,
+			ExpiresAt: existing.ExpiresAt,
+		}, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return ExportUserDataOutput{}, errors.WithStack(err)
+	}
+
+	expiresAt := time.Now().Add(u.DataExportLifespan)
+
+	export, err := u.Repository.InsertDataExport(ctx, repository.InsertDataExportInput{
+		UserId:    input.Id,
+		Format:    input.Format,
+		ExpiresAt: expiresAt,
+	})
+
+	if err != nil {
+		return ExportUserDataOutput{}, errors.WithStack(err)
+	}
+
+	// TODO: use message broker here
+	go u.runDataExport(context.Background(), export.Id, input.Id, input.Format)
+
+	return ExportUserDataOutput{
+		IsAsync:   true,
+		ExportId:  export.Id,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (u *Usecase) GetDataExport(ctx context.Context, input GetDataExportInput) (GetDataExportOutput, error) {
+	output, err := u.Repository.GetDataExport(ctx, repository.GetDataExportInput{
+		Id:     input.ExportId,
+		UserId: input.Id,
+	})
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return GetDataExportOutput{
+				IsNotFound: true,
+			}, nil
+		}
+
+		return GetDataExportOutput{}, errors.WithStack(err)
+	}
+
+	switch output.Status {
+	case repository.DATA_EXPORT_STATUS_PENDING:
+		return GetDataExportOutput{
+			IsPending: true,
+			ExpiresAt: output.ExpiresAt,
+		}, nil
+	case repository.DATA_EXPORT_STATUS_FAILED:
+		return GetDataExportOutput{
+			IsFailed: true,
+		}, nil
+	}
+
+	return GetDataExportOutput{
+		ExpiresAt: output.ExpiresAt,
+		File:      dataExportFile(input.Id, output.Format, output.Content),
+	}, nil
+}
+
+// PurgeDataExports removes the exports that can no longer be downloaded.
+func (u *Usecase) PurgeDataExports(ctx context.Context, input PurgeDataExportsInput) (PurgeDataExportsOutput, error) {
+	output, err := u.Repository.DeleteExpiredDataExports(ctx, repository.DeleteExpiredDataExportsInput{})
+	if err != nil {
+		return PurgeDataExportsOutput{}, errors.WithStack(err)
+	}
+
+	return PurgeDataExportsOutput{
+		Count: output.Count,
+	}, nil
+}
+
+// SetAvatar replaces the avatar of the user with the uploaded image, scaled
+// to the square sizes of avatarObjects. The previous avatar is deleted once
+// the new one is in place.
+func (u *Usecase) SetAvatar(ctx context.Context, input SetAvatarInput) (SetAvatarOutput, error) {
+	content, err := io.ReadAll(io.LimitReader(input.Content, u.AvatarMaxBytes+1))
+	if err != nil {
+		return SetAvatarOutput{}, errors.WithStack(err)
+	}
+
+	if int64(len(content)) > u.AvatarMaxBytes {
+		return SetAvatarOutput{
+			IsTooLarge: true,
+			MaxBytes:   u.AvatarMaxBytes,
+		}, nil
+	}
+
+	if output, invalid := checkAvatar(content); invalid {
+		return output, nil
+	}
+
+	key, err := newAvatarKey(input.Id)
+	if err != nil {
+		return SetAvatarOutput{}, errors.WithStack(err)
+	}
+
+	if err := u.storeAvatar(ctx, key, content); err != nil {
+		return SetAvatarOutput{}, errors.WithStack(err)
+	}
+
+	output, err := u.Repository.UpdateAvatarKeyById(ctx, repository.UpdateAvatarKeyByIdInput{
+		Id:        input.Id,
+		AvatarKey: key,
+	})
+
+	if err != nil {
+		u.deleteAvatar(ctx, key)
+		return SetAvatarOutput{}, errors.WithStack(err)
+	}
+
+	if output.PreviousAvatarKey != "" {
+		u.deleteAvatar(ctx, output.PreviousAvatarKey)
+	}
+
+	return SetAvatarOutput{
+		Avatar: u.avatarURLs(key),
+	}, nil
+}
+
+// RemoveAvatar removes the avatar of the user, if any.
+func (u *Usecase) RemoveAvatar(ctx context.Context, input RemoveAvatarInput) (RemoveAvatarOutput, error) {
+	output, err := u.Repository.UpdateAvatarKeyById(ctx, repository.UpdateAvatarKeyByIdInput{
+		Id: input.Id,
+	})
+
+	if err != nil {
+		return RemoveAvatarOutput{}, errors.WithStack(err)
+	}
+
+	if output.PreviousAvatarKey != "" {
+		u.deleteAvatar(ctx, output.PreviousAvatarKey)
+	}
+
+	return RemoveAvatarOutput{}, nil
+}
+
+// ImpersonateUser issues a token that lets support staff act as a user. Every
+// impersonation is recorded, the handler logs each use of its token.
+func (u *Usecase) ImpersonateUser(ctx context.Context, input ImpersonateUserInput) (ImpersonateUserOutput, error) {
+	current, err := u.GetUserStatus(ctx, GetUserStatusInput{
+		Id: input.Id,
+	})
+
+	if err != nil {
+		return ImpersonateUserOutput{}, errors.WithStack(err)
+	}
+
+	if current.IsNotFound {
+		return ImpersonateUserOutput{
+			IsNotFound: true,
+		}, nil
+	}
+
+	if current.Status != USER_STATUS_ACTIVE {
+		return ImpersonateUserOutput{
+			IsNotActive: true,
+		}, nil
+	}
+
+	expiresAt := time.Now().Add(u.ImpersonationLifespan)
+
+	impersonation, err := u.Repository.InsertImpersonation(ctx, repository.InsertImpersonationInput{
+		AdminId:   input.AdminId,
+		UserId:    input.Id,
+		Reason:    input.Reason,
+		ExpiresAt: expiresAt,
+	})
+
+	if err != nil {
+		return ImpersonateUserOutput{}, errors.WithStack(err)
+	}
+
+	token, err := utils.GenerateImpersonationToken(input.Id, input.AdminId, impersonation.Id, expiresAt)
+	if err != nil {
+		return ImpersonateUserOutput{}, errors.WithStack(err)
+	}
+
+	return ImpersonateUserOutput{
+		Token:     token,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// RecordImpersonationUse stores a request made with an impersonation token,
+// the audit trail of what support staff did as the user.
+func (u *Usecase) RecordImpersonationUse(ctx context.Context, input RecordImpersonationUseInput) (RecordImpersonationUseOutput, error) {
+	err := u.Repository.InsertImpersonationUse(ctx, repository.InsertImpersonationUseInput{
+		ImpersonationId: input.ImpersonationId,
+		Method:          input.Method,
+		Path:            input.Path,
+	})
+
+	if err != nil {
+		return RecordImpersonationUseOutput{}, errors.WithStack(err)
+	}
+
+	return RecordImpersonationUseOutput{}, nil
+}
+
+// admitUser returns the status of a user who proved their identity at login.
+// A deactivated user is reactivated, logging in again is their way back.
+func (u *Usecase) admitUser(ctx context.Context, id int64) (repository.GetUserStatusByIdOutput, error) {
+	output, err := u.Repository.GetUserStatusById(ctx, repository.GetUserStatusByIdInput{
+		Id: id,
+	})
+
+	if err != nil {
+		return repository.GetUserStatusByIdOutput{}, errors.WithStack(err)
+	}
+
+	if output.Status != USER_STATUS_DEACTIVATED {
+		return output, nil
+	}
+
+	isChanged, err := u.changeUserStatus(ctx, id, USER_STATUS_DEACTIVATED, USER_STATUS_ACTIVE, loginReactivationReason, id)
+	if err != nil {
+		return repository.GetUserStatusByIdOutput{}, errors.WithStack(err)
+	}
+
+	if !isChanged {
+		return repository.GetUserStatusByIdOutput{}, errors.Errorf("status of user %d changed concurrently", id)
+	}
+
+	return repository.GetUserStatusByIdOutput{
+		Status: USER_STATUS_ACTIVE,
+	}, nil
+}
+
+// passwordConfirmation is what confirmPassword found.
+type passwordConfirmation struct {
+	IsPasswordWrong bool
+	// IsLoginRequired means the user has no password to confirm and has to
+	// log in again at their login provider first
+	IsLoginRequired bool
+}
+
+// confirmPassword checks that the user themselves makes a sensitive change.
+// Users with a password confirm it. Users provisioned by a directory confirm
+// the password of the directory serving their phone number or verified email.
+// Other users only have login providers and must have logged in, at
+// authenticatedAt, no longer than ReauthenticationMaxAge ago.
+func (u *Usecase) confirmPassword(ctx context.Context, id int64, password string, authenticatedAt time.Time) (passwordConfirmation, error) {
+	passwordRes, err := u.Repository.GetPasswordById(ctx, repository.GetPasswordByIdInput{
+		Id: id,
+	})
+
+	if err != nil {
+		return passwordConfirmation{}, errors.WithStack(err)
+	}
+
+	if passwordRes.Password != "" {
+		isPasswordMatch, err := u.PasswordHasher.Verify(passwordRes.Password, password)
+		if err != nil {
+			return passwordConfirmation{}, errors.WithStack(err)
+		}
+
+		return passwordConfirmation{
+			IsPasswordWrong: !isPasswordMatch,
+		}, nil
+	}
+
+	identities, err := u.getIdentities(ctx, id)
+	if err != nil {
+		return passwordConfirmation{}, errors.WithStack(err)
+	}
+
+	for _, identity := range identities {
+		var input LoginInput
+		switch {
+		case identity.Type == IDENTITY_TYPE_PHONE:
+			input.PhoneNumber = identity.Identifier
+		case identity.Type == IDENTITY_TYPE_EMAIL && identity.VerifiedAt != nil:
+			input.Email = identity.Identifier
+		default:
+			continue
+		}
+
+		authenticator := u.authenticatorFor(input)
+		if _, ok := authenticator.(*passwordAuthenticator); ok {
+			continue
+		}
+
+		if password == "" {
+			return passwordConfirmation{
+				IsPasswordWrong: true,
+			}, nil
+		}
+
+		input.Password = password
+		output, err := authenticator.Authenticate(ctx, input)
+		if err != nil {
+			return passwordConfirmation{}, errors.WithStack(err)
+		}
+
+		return passwordConfirmation{
+			// the directory may know the identifier as someone else
+			IsPasswordWrong: output.IsInvalidCredentials || output.Id != id,
+		}, nil
+	}
+
+	if authenticatedAt.IsZero() || time.Since(authenticatedAt) > u.ReauthenticationMaxAge {
+		return passwordConfirmation{
+			IsLoginRequired: true,
+		}, nil
+	}
+
+	return passwordConfirmation{}, nil
+}
+
+// changeUserStatus moves the user from the status from to the status to and
+// records the change. It reports false when the user no longer has from.
+func (u *Usecase) changeUserStatus(ctx context.Context, id int64, from, to, reason string, changedBy int64) (bool, error) {
+	output, err := u.Repository.UpdateUserStatus(ctx, repository.UpdateUserStatusInput{
+		Id:         id,
+		FromStatus: from,
+		Status:     to,
+		Reason:     reason,
+		ChangedBy:  changedBy,
+	})
+
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	return output.IsUpdated, nil
+}
+
+// generateToken issues a regular token for the user, with the roles the user
+// has now.
+func (u *Usecase) generateToken(ctx context.Context, id int64) (string, error) {
+	rolesRes, err := u.Repository.GetRolesByUserId(ctx, repository.GetRolesByUserIdInput{
+		UserId: id,
+	})
+
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return utils.GenerateToken(id, rolesRes.Roles)
+}
+
+// recordLogin keeps a successful login of the user in their login history
+// and counts it. ip is left out unless it is an IP address.
+func (u *Usecase) recordLogin(ctx context.Context, id int64, method, ip string) error {
+	if net.ParseIP(ip) == nil {
+		ip = ""
+	}
+
+	err := u.Repository.InsertLoginEvent(ctx, repository.InsertLoginEventInput{
+		UserId: id,
+		Method: method,
+		IP:     ip,
+	})
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// TODO: use message broker here
+	go func(id int64) {
+		err := u.Repository.UpdateTotalLoginById(context.Background(), repository.UpdateTotalLoginByIdInput{
+			Id: id,
+		})
+
+		if err != nil {
+			log.Println("[ERROR][Login] error when UpdateTotalLoginById", errors.WithStack(err))
+		}
+	}(id)
+
+	return nil
+}
+
+// changePassword stores newPassword unless it matches the current hash or the
+// password history, in which case it returns true and changes nothing.
+func (u *Usecase) changePassword(ctx context.Context, id int64, currentHash, newPassword string) (bool, error) {
+	isPasswordReused, err := u.isPasswordReused(ctx, id, currentHash, newPassword)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	if isPasswordReused {
+		return true, nil
+	}
+
+	hashedPassword, err := u.PasswordHasher.Hash(newPassword)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	err = u.Repository.SetPasswordById(ctx, repository.SetPasswordByIdInput{
+		Id:       id,
+		Password: hashedPassword,
+	})
+
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	if u.PasswordHistorySize > 0 {
+		err = u.Repository.DeleteOldPasswordHistory(ctx, repository.DeleteOldPasswordHistoryInput{
+			UserId: id,
+			Keep:   u.PasswordHistorySize,
+		})
+
+		if err != nil {
+			log.Println("[WARN][SetPassword] error when DeleteOldPasswordHistory", errors.WithStack(err))
+		}
+	}
+
+	return false, nil
+}
+
+// isPasswordExpired reports whether a password changed at changedAt is older
+// than the expiry configured for userGroup.
+func (u *Usecase) isPasswordExpired(userGroup string, changedAt time.Time) bool {
+	days := u.PasswordExpiryDays[userGroup]
+	if days <= 0 || changedAt.IsZero() {
+		return false
+	}
+
+	return time.Since(changedAt) > time.Duration(days)*24*time.Hour
+}
+
+// isPasswordReused checks the new password against the current hash and the
+// last PasswordHistorySize hashes of the user. A history size of 0 disables the check.
+func (u *Usecase) isPasswordReused(ctx context.Context, id int64, currentHash, newPassword string) (bool, error) {
+	if u.PasswordHistorySize <= 0 {
+		return false, nil
+	}
+
+	historyRes, err := u.Repository.GetPasswordHistoryByUserId(ctx, repository.GetPasswordHistoryByUserIdInput{
+		UserId: id,
+		Limit:  u.PasswordHistorySize,
+	})
+
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	for _, hash := range append([]string{currentHash}, historyRes.Passwords...) {
+		isMatch, err := u.PasswordHasher.Verify(hash, newPassword)
+		if err != nil {
+			// hashes made with a retired pepper key or an unknown format can not be compared
+			log.Println("[WARN][SetPassword] error when verifying password history", err)
+			continue
+		}
+
+		if isMatch {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// verifyDummyPassword compares password against a hash of a random password
+// made with the current hasher, so it costs as much as a real verification.
+// The hash is made on first use.
+func (u *Usecase) verifyDummyPassword(password string) {
+	u.dummyPasswordHashOnce.Do(func() {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Println("[WARN][Login] error when generating dummy password", errors.WithStack(err))
+		}
+
+		hashedPassword, err := u.PasswordHasher.Hash(hex.EncodeToString(secret))
+		if err != nil {
+			log.Println("[WARN][Login] error when hashing dummy password", errors.WithStack(err))
+			return
+		}
+
+		u.dummyPasswordHash = hashedPassword
+	})
+
+	if _, err := u.PasswordHasher.Verify(u.dummyPasswordHash, password); err != nil {
+		log.Println("[WARN][Login] error when verifying dummy password", err)
+	}
+}
+
+// rehashPassword upgrades a stored hash that was produced with an outdated
+// algorithm or cost. A failure here must not block the login, so it is only logged.
+func (u *Usecase) rehashPassword(ctx context.Context, id int64, password string) {
+	hashedPassword, err := u.PasswordHasher.Hash(password)
+	if err != nil {
+		log.Println("[WARN][Login] error when rehashing password", errors.WithStack(err))
+		return
+	}
+
+	err = u.Repository.UpdatePasswordById(ctx, repository.UpdatePasswordByIdInput{
+		Id:       id,
+		Password: hashedPassword,
+	})
+	if err != nil {
+		log.Println("[WARN][Login] error when UpdatePasswordById", errors.WithStack(err))
+	}
+}
+
+// generateRandomToken returns a random url safe token, for the link of an
+// email verification and the secrets of an OpenID Connect login.
+func generateRandomToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// generateVerificationCode returns a random code of emailVerificationCodeDigits digits.
+func generateVerificationCode() (string, error) {
+	max := big.NewInt(int64(math.Pow10(emailVerificationCodeDigits)))
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return fmt.Sprintf("%0*d", emailVerificationCodeDigits, n.Int64()), nil
+}
+
+// hashVerificationSecret is how verification tokens and codes are stored.
+// They are short lived random values, a fast hash is enough.
+func hashVerificationSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// externalFullName picks the name of a user created through an external
+// login: the name known to the provider or directory, else the local part of
+// the email.
+func externalFullName(name, email string) string {
+	localPart, _, _ := strings.Cut(email, "@")
+
+	for _, candidate := range []string{name, localPart} {
+		if fullName, err := utils.NormalizeFullName(candidate); err == nil {
+			return fullName
+		}
+	}
+
+	return externalDefaultFullName
+}
+
+// userCursor is where a listing of SearchUsers continues: after the user Id
+// whose sort value is Value. Clients get it as an opaque token.
+type userCursor struct {
+	Sort  string `json:"s"`
+	Id    int64  `json:"i"`
+	Value string `json:"v"`
+}
+
+func encodeUserCursor(sort string, user repository.UserSummary) string {
+	cursor := userCursor{
+		Sort:  sort,
+		Id:    user.Id,
+		Value: user.FullName,
+	}
+
+	if sort == USER_SORT_CREATED_AT || sort == USER_SORT_CREATED_AT_DESC {
+		cursor.Value = user.CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	// marshalling a struct of strings and ints does not fail
+	encoded, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeUserCursor returns the cursor encoded by encodeUserCursor, it is only
+// valid for the sort it was made for.
+func decodeUserCursor(sort, encoded string) (userCursor, bool) {
+	var cursor userCursor
+
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return userCursor{}, false
+	}
+
+	if err := json.Unmarshal(decoded, &cursor); err != nil || cursor.Sort != sort || cursor.Id <= 0 {
+		return userCursor{}, false
+	}
+
+	if sort == USER_SORT_CREATED_AT || sort == USER_SORT_CREATED_AT_DESC {
+		if _, err := time.Parse(time.RFC3339Nano, cursor.Value); err != nil {
+			return userCursor{}, false
+		}
+	}
+
+	return cursor, true
+}
+
+// isRestorable reports whether the user deleted their account and the grace
+// period is not over yet.
+func isRestorable(status repository.GetUserStatusByIdOutput) bool {
+	return status.Status == USER_STATUS_DELETED && status.PurgeAt != nil && time.Now().Before(*status.PurgeAt)
+}
+
+// isUserStatusTransition reports whether a user can be moved from the status
+// from to the status to.
+func isUserStatusTransition(from, to string) bool {
+	for _, status := range userStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (u *Usecase) GetAttributeSchema(ctx context.Context, input GetAttributeSchemaInput) (GetAttributeSchemaOutput, error) {
+	raw, _, err := u.attributeSchema(ctx)
+	if err != nil {
+		return GetAttributeSchemaOutput{}, errors.WithStack(err)
+	}
+
+	var output GetAttributeSchemaOutput
+	if err := json.Unmarshal(raw, &output.Schema); err != nil {
+		return GetAttributeSchemaOutput{}, errors.WithStack(err)
+	}
+
+	return output, nil
+}
+
+func (u *Usecase) SetAttributeSchema(ctx context.Context, input SetAttributeSchemaInput) (SetAttributeSchemaOutput, error) {
+	raw, err := json.Marshal(input.Schema)
+	if err != nil {
+		return SetAttributeSchemaOutput{}, errors.WithStack(err)
+	}
+
+	// attributes already stored are left as they are, they are validated
+	// against the new schema when they are written next
+	if _, err := utils.ParseAttributeSchema(raw); err != nil {
+		return SetAttributeSchemaOutput{
+			IsInvalid: true,
+			Reason:    errors.Cause(err).Error(),
+		}, nil
+	}
+
+	_, err = u.Repository.InsertAttributeSchema(ctx, repository.InsertAttributeSchemaInput{
+		Schema:    raw,
+		CreatedBy: input.UpdatedBy,
+	})
+
+	if err != nil {
+		return SetAttributeSchemaOutput{}, errors.WithStack(err)
+	}
+
+	return SetAttributeSchemaOutput{}, nil
+}
+
+func (u *Usecase) SetUserAttributes(ctx context.Context, input SetUserAttributesInput) (SetUserAttributesOutput, error) {
+	_, schema, err := u.attributeSchema(ctx)
+	if err != nil {
+		return SetUserAttributesOutput{}, errors.WithStack(err)
+	}
+
+	if attributeErrors := validateAttributes(schema, input.Attributes); len(attributeErrors) > 0 {
+		return SetUserAttributesOutput{
+			AttributeErrors: attributeErrors,
+		}, nil
+	}
+
+	attributes, err := encodeAttributes(input.Attributes)
+	if err != nil {
+		return SetUserAttributesOutput{}, errors.WithStack(err)
+	}
+
+	err = u.Repository.UpdateAttributesById(ctx, repository.UpdateAttributesByIdInput{
+		Id:         input.Id,
+		Attributes: attributes,
+		UpdatedBy:  input.UpdatedBy,
+	})
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SetUserAttributesOutput{
+				IsNotFound: true,
+			}, nil
+		}
+
+		return SetUserAttributesOutput{}, errors.WithStack(err)
+	}
+
+	return SetUserAttributesOutput{}, nil
+}
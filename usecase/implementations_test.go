@@ -1,17 +1,49 @@
 package usecase
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/SawitProRecruitment/UserService/repository"
 	"github.com/SawitProRecruitment/UserService/utils"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestUsecase_RegisterNewUser(t *testing.T) {
@@ -23,11 +55,13 @@ func TestUsecase_RegisterNewUser(t *testing.T) {
 		input RegisterNewUserInput
 	}
 	tests := []struct {
-		name     string
-		args     args
-		mockFunc func(args)
-		want     RegisterNewUserOutput
-		wantErr  bool
+		name           string
+		args           args
+		passwordHasher utils.PasswordHasher
+		mockFunc       func(args)
+		want           RegisterNewUserOutput
+		wantMailTo     string
+		wantErr        bool
 	}{
 		{
 			name: "error, when GenerateFromPassword",
@@ -35,12 +69,14 @@ func TestUsecase_RegisterNewUser(t *testing.T) {
 				input: RegisterNewUserInput{
 					PhoneNumber: "phone-000",
 					FullName:    "fullname",
-					Password:    "asfasfascojscoascjasockmasockasmofiajsfoasijfasokmcoaskcjasoifjasofkmasocijasofiajsokmascoaskjcoasifjas",
+					Password:    "aaaa",
 				},
 			},
-			mockFunc: func(a args) {},
-			want:     RegisterNewUserOutput{},
-			wantErr:  true,
+			// bcrypt refuses the cost
+			passwordHasher: &utils.BcryptHasher{Cost: bcrypt.MaxCost + 1},
+			mockFunc:       func(a args) {},
+			want:           RegisterNewUserOutput{},
+			wantErr:        true,
 		},
 		{
 			name: "error, when InsertNewUser",
@@ -76,6 +112,81 @@ func TestUsecase_RegisterNewUser(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "success, email exists",
+			args: args{
+				input: RegisterNewUserInput{
+					PhoneNumber: "phone-000",
+					FullName:    "fullname",
+					Password:    "aaaa",
+					Email:       "name@example.com",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().InsertNewUser(gomock.Any(), gomock.Any()).Return(repository.InsertNewUserOutput{
+					IsEmailExists: true,
+				}, nil)
+			},
+			want: RegisterNewUserOutput{
+				IsEmailExists: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, email verification mailed",
+			args: args{
+				input: RegisterNewUserInput{
+					PhoneNumber:  "phone-000",
+					FullName:     "fullname",
+					Password:     "aaaa",
+					Email:        "name@example.com",
+					MailLanguage: "id",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().InsertNewUser(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, input repository.InsertNewUserInput) (repository.InsertNewUserOutput, error) {
+						assert.Equal(t, a.input.Email, input.Email)
+						return repository.InsertNewUserOutput{
+							Id: 13,
+						}, nil
+					})
+
+				mockRepository.EXPECT().UpsertEmailVerification(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, input repository.UpsertEmailVerificationInput) error {
+						assert.Equal(t, int64(13), input.UserId)
+						assert.Equal(t, a.input.Email, input.Email)
+						return nil
+					})
+			},
+			want: RegisterNewUserOutput{
+				Id: 13,
+			},
+			wantMailTo: "name@example.com",
+			wantErr:    false,
+		},
+		{
+			name: "success, email verification error ignored",
+			args: args{
+				input: RegisterNewUserInput{
+					PhoneNumber: "phone-000",
+					FullName:    "fullname",
+					Password:    "aaaa",
+					Email:       "name@example.com",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().InsertNewUser(gomock.Any(), gomock.Any()).Return(repository.InsertNewUserOutput{
+					Id: 14,
+				}, nil)
+
+				mockRepository.EXPECT().UpsertEmailVerification(gomock.Any(), gomock.Any()).Return(errors.New("test"))
+			},
+			want: RegisterNewUserOutput{
+				Id: 14,
+			},
+			wantErr: false,
+		},
 		{
 			name: "success",
 			args: args{
@@ -95,12 +206,64 @@ func TestUsecase_RegisterNewUser(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "success, stored as argon2id PHC string",
+			args: args{
+				input: RegisterNewUserInput{
+					PhoneNumber: "phone-000",
+					FullName:    "fullname",
+					Password:    "aaaa",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().InsertNewUser(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, input repository.InsertNewUserInput) (repository.InsertNewUserOutput, error) {
+						assert.True(t, strings.HasPrefix(input.Password, "$argon2id$v=19$m=65536,t=3,p=2$"))
+						return repository.InsertNewUserOutput{
+							Id: 11,
+						}, nil
+					})
+			},
+			want: RegisterNewUserOutput{
+				Id: 11,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, stored with pepper key id",
+			args: args{
+				input: RegisterNewUserInput{
+					PhoneNumber: "phone-000",
+					FullName:    "fullname",
+					Password:    "aaaa",
+				},
+			},
+			passwordHasher: utils.NewPepperedPasswordHasher(utils.NewArgon2idHasher(1024, 1, 1), map[string][]byte{
+				"k1": []byte("secret-1"),
+			}, "k1"),
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().InsertNewUser(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, input repository.InsertNewUserInput) (repository.InsertNewUserOutput, error) {
+						assert.True(t, strings.HasPrefix(input.Password, "$pepper$k=k1$argon2id$"))
+						return repository.InsertNewUserOutput{
+							Id: 12,
+						}, nil
+					})
+			},
+			want: RegisterNewUserOutput{
+				Id: 12,
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockFunc(tt.args)
+			mailSender := &recordingMailSender{}
 			u := NewUsecase(NewUsecaseOptions{
-				Repository: mockRepository,
+				Repository:     mockRepository,
+				PasswordHasher: tt.passwordHasher,
+				MailSender:     mailSender,
 			})
 			got, err := u.RegisterNewUser(context.Background(), tt.args.input)
 			if (err != nil) != tt.wantErr {
@@ -110,6 +273,7 @@ func TestUsecase_RegisterNewUser(t *testing.T) {
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Usecase.RegisterNewUser() = %v, want %v", got, tt.want)
 			}
+			assert.Equal(t, tt.wantMailTo, mailSender.lastTo())
 		})
 	}
 }
@@ -122,19 +286,33 @@ func TestUsecase_Login(t *testing.T) {
 	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
 	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
 
+	var (
+		pepperKeys = map[string][]byte{
+			"old": []byte("old-secret"),
+			"new": []byte("new-secret"),
+		}
+		pepperedInnerHasher = utils.NewArgon2idHasher(1024, 1, 1)
+		oldPepperHash, _    = utils.NewPepperedPasswordHasher(pepperedInnerHasher, pepperKeys, "old").Hash("aaaa")
+		purgeAt             = time.Now().Add(time.Hour)
+		purgedAt            = time.Now().Add(-time.Minute)
+	)
+
 	type args struct {
 		input LoginInput
 	}
 	tests := []struct {
-		name     string
-		args     args
-		mockFunc func(args)
-		want     LoginOutput
-		wantId   int64
-		wantErr  bool
+		name               string
+		args               args
+		passwordHasher     utils.PasswordHasher
+		passwordExpiryDays map[string]int
+		mockFunc           func(args)
+		want               LoginOutput
+		wantId             int64
+		wantRoles          []string
+		wantErr            bool
 	}{
 		{
-			name: "error when GetPasswordByPhoneNumber data not found",
+			name: "success, unknown phone number is invalid credentials",
 			args: args{
 				input: LoginInput{
 					PhoneNumber: "phone",
@@ -142,17 +320,18 @@ func TestUsecase_Login(t *testing.T) {
 				},
 			},
 			mockFunc: func(a args) {
-				mockRepository.EXPECT().GetPasswordByPhoneNumber(gomock.Any(), gomock.Eq(repository.GetPasswordByPhoneNumberInput{
-					PhoneNumber: a.input.PhoneNumber,
-				})).Return(repository.GetPasswordByPhoneNumberOutput{}, sql.ErrNoRows)
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Eq(repository.GetPasswordByIdentityInput{
+					Type:       repository.IDENTITY_TYPE_PHONE,
+					Identifier: a.input.PhoneNumber,
+				})).Return(repository.GetPasswordByIdentityOutput{}, sql.ErrNoRows)
 			},
 			want: LoginOutput{
-				IsDataNotFound: true,
+				IsInvalidCredentials: true,
 			},
 			wantErr: false,
 		},
 		{
-			name: "error when GetPasswordByPhoneNumber",
+			name: "error when GetPasswordByIdentity",
 			args: args{
 				input: LoginInput{
 					PhoneNumber: "phone",
@@ -160,9 +339,10 @@ func TestUsecase_Login(t *testing.T) {
 				},
 			},
 			mockFunc: func(a args) {
-				mockRepository.EXPECT().GetPasswordByPhoneNumber(gomock.Any(), gomock.Eq(repository.GetPasswordByPhoneNumberInput{
-					PhoneNumber: a.input.PhoneNumber,
-				})).Return(repository.GetPasswordByPhoneNumberOutput{}, errors.New("test"))
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Eq(repository.GetPasswordByIdentityInput{
+					Type:       repository.IDENTITY_TYPE_PHONE,
+					Identifier: a.input.PhoneNumber,
+				})).Return(repository.GetPasswordByIdentityOutput{}, errors.New("test"))
 			},
 			want:    LoginOutput{},
 			wantErr: true,
@@ -176,16 +356,17 @@ func TestUsecase_Login(t *testing.T) {
 				},
 			},
 			mockFunc: func(a args) {
-				mockRepository.EXPECT().GetPasswordByPhoneNumber(gomock.Any(), gomock.Eq(repository.GetPasswordByPhoneNumberInput{
-					PhoneNumber: a.input.PhoneNumber,
-				})).Return(repository.GetPasswordByPhoneNumberOutput{
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Eq(repository.GetPasswordByIdentityInput{
+					Type:       repository.IDENTITY_TYPE_PHONE,
+					Identifier: a.input.PhoneNumber,
+				})).Return(repository.GetPasswordByIdentityOutput{
 					Id:          10,
 					PhoneNumber: "phone",
 					Password:    "$2a$05$N9yncSBoAMWxz/nyW7APGuzRkXXGh27574xz2pF8dj4vm.In9T0SW",
 				}, nil)
 			},
 			want: LoginOutput{
-				IsPasswordWrong: true,
+				IsInvalidCredentials: true,
 			},
 			wantErr: false,
 		},
@@ -198,9 +379,10 @@ func TestUsecase_Login(t *testing.T) {
 				},
 			},
 			mockFunc: func(a args) {
-				mockRepository.EXPECT().GetPasswordByPhoneNumber(gomock.Any(), gomock.Eq(repository.GetPasswordByPhoneNumberInput{
-					PhoneNumber: a.input.PhoneNumber,
-				})).Return(repository.GetPasswordByPhoneNumberOutput{
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Eq(repository.GetPasswordByIdentityInput{
+					Type:       repository.IDENTITY_TYPE_PHONE,
+					Identifier: a.input.PhoneNumber,
+				})).Return(repository.GetPasswordByIdentityOutput{
 					Id:          10,
 					PhoneNumber: "phone",
 					Password:    "abcd",
@@ -218,14 +400,31 @@ func TestUsecase_Login(t *testing.T) {
 				},
 			},
 			mockFunc: func(a args) {
-				mockRepository.EXPECT().GetPasswordByPhoneNumber(gomock.Any(), gomock.Eq(repository.GetPasswordByPhoneNumberInput{
-					PhoneNumber: a.input.PhoneNumber,
-				})).Return(repository.GetPasswordByPhoneNumberOutput{
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Eq(repository.GetPasswordByIdentityInput{
+					Type:       repository.IDENTITY_TYPE_PHONE,
+					Identifier: a.input.PhoneNumber,
+				})).Return(repository.GetPasswordByIdentityOutput{
 					Id:          10,
 					PhoneNumber: "phone",
 					Password:    "$2a$05$WgWdo896B1Qc3VQRIm78X.rdwOFwEo7dB.bgIbAx8wOBNZCx1eJ2q",
 				}, nil)
 
+				mockRepository.EXPECT().UpdatePasswordById(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, input repository.UpdatePasswordByIdInput) error {
+						assert.Equal(t, int64(10), input.Id)
+						assert.Equal(t, utils.PASSWORD_ALGORITHM_ARGON2ID, utils.PasswordHashAlgorithm(input.Password))
+						return nil
+					})
+
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Eq(repository.GetRolesByUserIdInput{
+					UserId: 10,
+				})).Return(repository.GetRolesByUserIdOutput{}, nil)
+
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 10,
+					Method: LOGIN_METHOD_PASSWORD,
+				})).Return(nil)
 				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Eq(repository.UpdateTotalLoginByIdInput{
 					Id: 10,
 				})).Return(errors.New("test")).AnyTimes()
@@ -234,193 +433,7668 @@ func TestUsecase_Login(t *testing.T) {
 			wantId:  10,
 			wantErr: false,
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			tt.mockFunc(tt.args)
-			u := NewUsecase(NewUsecaseOptions{
-				Repository: mockRepository,
-			})
-			got, err := u.Login(context.Background(), tt.args.input)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("Usecase.Login() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			token := got.Token
-			got.Token = ""
-
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("Usecase.Login() = %v, want %v", got, tt.want)
-			}
-
-			if token != "" {
-				parse, _ := utils.TokenParse(token)
-				assert.Equal(t, tt.wantId, parse)
-			}
-		})
-	}
-}
-
-func TestUsecase_GetUserData(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
-	mockRepository := repository.NewMockRepositoryInterface(ctrl)
-
-	type args struct {
-		input GetUserDataInput
-	}
-	tests := []struct {
-		name     string
-		args     args
-		mockFunc func(args)
-		want     GetUserDataOutput
-		wantErr  bool
-	}{
 		{
-			name: "error when GetUserDataById",
+			name: "success, argon2id hash up to date is not rehashed",
 			args: args{
-				input: GetUserDataInput{
-					Id: 11,
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
 				},
 			},
 			mockFunc: func(a args) {
-				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
-					Id: a.input.Id,
-				})).Return(repository.GetUserDataByIdOutput{}, errors.New("test"))
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Eq(repository.GetPasswordByIdentityInput{
+					Type:       repository.IDENTITY_TYPE_PHONE,
+					Identifier: a.input.PhoneNumber,
+				})).Return(repository.GetPasswordByIdentityOutput{
+					Id:          12,
+					PhoneNumber: "phone",
+					Password:    "$argon2id$v=19$m=65536,t=3,p=2$+5OqHX693TNB1C1p6sUZyA$0rs0JiEay6zMEsnK/7itiD/Th9WpZtAEvG2iL9bttvw",
+				}, nil)
+
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Eq(repository.GetRolesByUserIdInput{
+					UserId: 12,
+				})).Return(repository.GetRolesByUserIdOutput{}, nil)
+
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 12,
+					Method: LOGIN_METHOD_PASSWORD,
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Eq(repository.UpdateTotalLoginByIdInput{
+					Id: 12,
+				})).Return(nil).AnyTimes()
 			},
-			want:    GetUserDataOutput{},
-			wantErr: true,
+			want:    LoginOutput{},
+			wantId:  12,
+			wantErr: false,
 		},
 		{
-			name: "success",
+			name: "success, login kept in the login history with the ip",
 			args: args{
-				input: GetUserDataInput{
-					Id: 11,
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
+					IP:          "2001:db8::7",
 				},
 			},
 			mockFunc: func(a args) {
-				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
-					Id: a.input.Id,
-				})).Return(repository.GetUserDataByIdOutput{
-					PhoneNumber: "phoneNumber",
-					FullName:    "fullName",
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdentityOutput{
+					Id:          12,
+					PhoneNumber: "phone",
+					Password:    "$argon2id$v=19$m=65536,t=3,p=2$+5OqHX693TNB1C1p6sUZyA$0rs0JiEay6zMEsnK/7itiD/Th9WpZtAEvG2iL9bttvw",
 				}, nil)
+
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 12,
+					Method: LOGIN_METHOD_PASSWORD,
+					IP:     "2001:db8::7",
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Eq(repository.UpdateTotalLoginByIdInput{
+					Id: 12,
+				})).Return(nil).AnyTimes()
 			},
-			want: GetUserDataOutput{
-				PhoneNumber: "phoneNumber",
-				FullName:    "fullName",
-			},
+			want:    LoginOutput{},
+			wantId:  12,
 			wantErr: false,
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			tt.mockFunc(tt.args)
-			u := NewUsecase(NewUsecaseOptions{
-				Repository: mockRepository,
-			})
-			got, err := u.GetUserData(context.Background(), tt.args.input)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("Usecase.GetUserData() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("Usecase.GetUserData() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestUsecase_UpdateUserData(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
-	mockRepository := repository.NewMockRepositoryInterface(ctrl)
-
-	type args struct {
-		input UpdateUserDataInput
-	}
-	tests := []struct {
-		name     string
-		args     args
-		mockFunc func(args)
-		want     UpdateUserDataOutput
-		wantErr  bool
-	}{
 		{
-			name: "error when GetUserDataById",
+			name: "success, ip that is not an address is left out of the login history",
 			args: args{
-				input: UpdateUserDataInput{
-					Id:          10,
-					PhoneNumber: "numberPhone",
-					FullName:    "fullname",
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
+					IP:          "unknown, 203.0.113.7",
 				},
 			},
 			mockFunc: func(a args) {
-				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
-					Id: a.input.Id,
-				})).Return(repository.GetUserDataByIdOutput{}, errors.New("test"))
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdentityOutput{
+					Id:          12,
+					PhoneNumber: "phone",
+					Password:    "$argon2id$v=19$m=65536,t=3,p=2$+5OqHX693TNB1C1p6sUZyA$0rs0JiEay6zMEsnK/7itiD/Th9WpZtAEvG2iL9bttvw",
+				}, nil)
+
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 12,
+					Method: LOGIN_METHOD_PASSWORD,
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Eq(repository.UpdateTotalLoginByIdInput{
+					Id: 12,
+				})).Return(nil).AnyTimes()
 			},
-			want:    UpdateUserDataOutput{},
-			wantErr: true,
+			want:    LoginOutput{},
+			wantId:  12,
+			wantErr: false,
 		},
 		{
-			name: "error when UpdateUserData",
+			name: "error when InsertLoginEvent",
 			args: args{
-				input: UpdateUserDataInput{
-					Id:          10,
-					PhoneNumber: "numberPhone",
-					FullName:    "fullname",
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
+					IP:          "203.0.113.7",
 				},
 			},
 			mockFunc: func(a args) {
-				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
-					Id: a.input.Id,
-				})).Return(repository.GetUserDataByIdOutput{
-					Id:          "10",
-					PhoneNumber: "phoneNumber",
-					FullName:    "nameFull",
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdentityOutput{
+					Id:          12,
+					PhoneNumber: "phone",
+					Password:    "$argon2id$v=19$m=65536,t=3,p=2$+5OqHX693TNB1C1p6sUZyA$0rs0JiEay6zMEsnK/7itiD/Th9WpZtAEvG2iL9bttvw",
 				}, nil)
 
-				mockRepository.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(repository.UpdateUserDataInput{
-					Id:          a.input.Id,
-					PhoneNumber: a.input.PhoneNumber,
-					FullName:    a.input.FullName,
-				})).Return(repository.UpdateUserDataOutput{
-					IsPhoneNumberExists: true,
-				}, errors.New("test"))
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 12,
+					Method: LOGIN_METHOD_PASSWORD,
+					IP:     "203.0.113.7",
+				})).Return(errors.New("test"))
 			},
-			want:    UpdateUserDataOutput{},
+			want:    LoginOutput{},
 			wantErr: true,
 		},
 		{
-			name: "success",
+			name: "success, login by email",
 			args: args{
-				input: UpdateUserDataInput{
-					Id:          10,
-					PhoneNumber: "numberPhone",
+				input: LoginInput{
+					Email:    "name@example.com",
+					Password: "aaaa",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Eq(repository.GetPasswordByIdentityInput{
+					Type:       repository.IDENTITY_TYPE_EMAIL,
+					Identifier: a.input.Email,
+				})).Return(repository.GetPasswordByIdentityOutput{
+					Id:          15,
+					PhoneNumber: "phone",
+					Password:    "$argon2id$v=19$m=65536,t=3,p=2$+5OqHX693TNB1C1p6sUZyA$0rs0JiEay6zMEsnK/7itiD/Th9WpZtAEvG2iL9bttvw",
+				}, nil)
+
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Eq(repository.GetRolesByUserIdInput{
+					UserId: 15,
+				})).Return(repository.GetRolesByUserIdOutput{
+					Roles: []string{"admin", "support"},
+				}, nil)
+
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 15,
+					Method: LOGIN_METHOD_PASSWORD,
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Eq(repository.UpdateTotalLoginByIdInput{
+					Id: 15,
+				})).Return(nil).AnyTimes()
+			},
+			want:      LoginOutput{},
+			wantId:    15,
+			wantRoles: []string{"admin", "support"},
+			wantErr:   false,
+		},
+		{
+			name: "error when GetRolesByUserId",
+			args: args{
+				input: LoginInput{
+					Email:    "name@example.com",
+					Password: "aaaa",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdentityOutput{
+					Id:          15,
+					PhoneNumber: "phone",
+					Password:    "$argon2id$v=19$m=65536,t=3,p=2$+5OqHX693TNB1C1p6sUZyA$0rs0JiEay6zMEsnK/7itiD/Th9WpZtAEvG2iL9bttvw",
+				}, nil)
+
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, errors.New("test"))
+			},
+			want:    LoginOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, unknown or unverified email is invalid credentials",
+			args: args{
+				input: LoginInput{
+					Email:    "name@example.com",
+					Password: "aaaa",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Eq(repository.GetPasswordByIdentityInput{
+					Type:       repository.IDENTITY_TYPE_EMAIL,
+					Identifier: a.input.Email,
+				})).Return(repository.GetPasswordByIdentityOutput{}, sql.ErrNoRows)
+			},
+			want: LoginOutput{
+				IsInvalidCredentials: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, weak argon2id hash rehashed and rehash error ignored",
+			args: args{
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Eq(repository.GetPasswordByIdentityInput{
+					Type:       repository.IDENTITY_TYPE_PHONE,
+					Identifier: a.input.PhoneNumber,
+				})).Return(repository.GetPasswordByIdentityOutput{
+					Id:          13,
+					PhoneNumber: "phone",
+					Password:    "$argon2id$v=19$m=1024,t=1,p=1$D6eYpfonenrcKOnfT6QSyg$D5Jng3NqqgmNupvuFJ+67eEsmzemHD0gQeIyRL1uWb8",
+				}, nil)
+
+				mockRepository.EXPECT().UpdatePasswordById(gomock.Any(), gomock.Any()).Return(errors.New("test"))
+
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Eq(repository.GetRolesByUserIdInput{
+					UserId: 13,
+				})).Return(repository.GetRolesByUserIdOutput{}, nil)
+
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 13,
+					Method: LOGIN_METHOD_PASSWORD,
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Eq(repository.UpdateTotalLoginByIdInput{
+					Id: 13,
+				})).Return(nil).AnyTimes()
+			},
+			want:    LoginOutput{},
+			wantId:  13,
+			wantErr: false,
+		},
+		{
+			name: "success, hash peppered with a rotated key is upgraded",
+			args: args{
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
+				},
+			},
+			passwordHasher: utils.NewPepperedPasswordHasher(pepperedInnerHasher, pepperKeys, "new"),
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Eq(repository.GetPasswordByIdentityInput{
+					Type:       repository.IDENTITY_TYPE_PHONE,
+					Identifier: a.input.PhoneNumber,
+				})).Return(repository.GetPasswordByIdentityOutput{
+					Id:          14,
+					PhoneNumber: "phone",
+					Password:    oldPepperHash,
+				}, nil)
+
+				mockRepository.EXPECT().UpdatePasswordById(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, input repository.UpdatePasswordByIdInput) error {
+						assert.Equal(t, int64(14), input.Id)
+						assert.True(t, strings.HasPrefix(input.Password, "$pepper$k=new$argon2id$"))
+						return nil
+					})
+
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Eq(repository.GetRolesByUserIdInput{
+					UserId: 14,
+				})).Return(repository.GetRolesByUserIdOutput{}, nil)
+
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 14,
+					Method: LOGIN_METHOD_PASSWORD,
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Eq(repository.UpdateTotalLoginByIdInput{
+					Id: 14,
+				})).Return(nil).AnyTimes()
+			},
+			want:    LoginOutput{},
+			wantId:  14,
+			wantErr: false,
+		},
+		{
+			name: "success, password mismatch with peppered hash",
+			args: args{
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaab",
+				},
+			},
+			passwordHasher: utils.NewPepperedPasswordHasher(pepperedInnerHasher, pepperKeys, "new"),
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Eq(repository.GetPasswordByIdentityInput{
+					Type:       repository.IDENTITY_TYPE_PHONE,
+					Identifier: a.input.PhoneNumber,
+				})).Return(repository.GetPasswordByIdentityOutput{
+					Id:          14,
+					PhoneNumber: "phone",
+					Password:    oldPepperHash,
+				}, nil)
+			},
+			want: LoginOutput{
+				IsInvalidCredentials: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, password expired returns restricted token",
+			args: args{
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
+				},
+			},
+			passwordExpiryDays: map[string]int{"admin": 30},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Eq(repository.GetPasswordByIdentityInput{
+					Type:       repository.IDENTITY_TYPE_PHONE,
+					Identifier: a.input.PhoneNumber,
+				})).Return(repository.GetPasswordByIdentityOutput{
+					Id:                15,
+					PhoneNumber:       "phone",
+					Password:          "$argon2id$v=19$m=65536,t=3,p=2$+5OqHX693TNB1C1p6sUZyA$0rs0JiEay6zMEsnK/7itiD/Th9WpZtAEvG2iL9bttvw",
+					PasswordChangedAt: time.Now().AddDate(0, 0, -31),
+					UserGroup:         "admin",
+				}, nil)
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+			},
+			want: LoginOutput{
+				IsPasswordExpired: true,
+			},
+			wantId:  15,
+			wantErr: false,
+		},
+		{
+			name: "success, password older than expiry of another group",
+			args: args{
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
+				},
+			},
+			passwordExpiryDays: map[string]int{"admin": 30},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Eq(repository.GetPasswordByIdentityInput{
+					Type:       repository.IDENTITY_TYPE_PHONE,
+					Identifier: a.input.PhoneNumber,
+				})).Return(repository.GetPasswordByIdentityOutput{
+					Id:                16,
+					PhoneNumber:       "phone",
+					Password:          "$argon2id$v=19$m=65536,t=3,p=2$+5OqHX693TNB1C1p6sUZyA$0rs0JiEay6zMEsnK/7itiD/Th9WpZtAEvG2iL9bttvw",
+					PasswordChangedAt: time.Now().AddDate(-1, 0, 0),
+					UserGroup:         "default",
+				}, nil)
+
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Eq(repository.GetRolesByUserIdInput{
+					UserId: 16,
+				})).Return(repository.GetRolesByUserIdOutput{}, nil)
+
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 16,
+					Method: LOGIN_METHOD_PASSWORD,
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Eq(repository.UpdateTotalLoginByIdInput{
+					Id: 16,
+				})).Return(nil).AnyTimes()
+			},
+			want:    LoginOutput{},
+			wantId:  16,
+			wantErr: false,
+		},
+		{
+			name: "error when pepper key is no longer configured",
+			args: args{
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
+				},
+			},
+			passwordHasher: utils.NewPepperedPasswordHasher(pepperedInnerHasher, map[string][]byte{
+				"new": []byte("new-secret"),
+			}, "new"),
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Eq(repository.GetPasswordByIdentityInput{
+					Type:       repository.IDENTITY_TYPE_PHONE,
+					Identifier: a.input.PhoneNumber,
+				})).Return(repository.GetPasswordByIdentityOutput{
+					Id:          14,
+					PhoneNumber: "phone",
+					Password:    oldPepperHash,
+				}, nil)
+			},
+			want:    LoginOutput{},
+			wantErr: true,
+		},
+		{
+			name: "error when GetUserStatusById",
+			args: args{
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdentityOutput{
+					Id:          16,
+					PhoneNumber: "phone",
+					Password:    "$argon2id$v=19$m=65536,t=3,p=2$+5OqHX693TNB1C1p6sUZyA$0rs0JiEay6zMEsnK/7itiD/Th9WpZtAEvG2iL9bttvw",
+				}, nil)
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Eq(repository.GetUserStatusByIdInput{
+					Id: 16,
+				})).Return(repository.GetUserStatusByIdOutput{}, errors.New("test"))
+			},
+			want:    LoginOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, pending account",
+			args: args{
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdentityOutput{
+					Id:          16,
+					PhoneNumber: "phone",
+					Password:    "$argon2id$v=19$m=65536,t=3,p=2$+5OqHX693TNB1C1p6sUZyA$0rs0JiEay6zMEsnK/7itiD/Th9WpZtAEvG2iL9bttvw",
+				}, nil)
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_PENDING}, nil)
+			},
+			want: LoginOutput{
+				IsAccountPending: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, suspended account",
+			args: args{
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdentityOutput{
+					Id:          16,
+					PhoneNumber: "phone",
+					Password:    "$argon2id$v=19$m=65536,t=3,p=2$+5OqHX693TNB1C1p6sUZyA$0rs0JiEay6zMEsnK/7itiD/Th9WpZtAEvG2iL9bttvw",
+				}, nil)
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_SUSPENDED}, nil)
+			},
+			want: LoginOutput{
+				IsAccountSuspended: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, deleted account is invalid credentials",
+			args: args{
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdentityOutput{
+					Id:          16,
+					PhoneNumber: "phone",
+					Password:    "$argon2id$v=19$m=65536,t=3,p=2$+5OqHX693TNB1C1p6sUZyA$0rs0JiEay6zMEsnK/7itiD/Th9WpZtAEvG2iL9bttvw",
+				}, nil)
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_DELETED}, nil)
+			},
+			want: LoginOutput{
+				IsInvalidCredentials: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, account deleted by the user after the grace period is invalid credentials",
+			args: args{
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdentityOutput{
+					Id:          16,
+					PhoneNumber: "phone",
+					Password:    "$argon2id$v=19$m=65536,t=3,p=2$+5OqHX693TNB1C1p6sUZyA$0rs0JiEay6zMEsnK/7itiD/Th9WpZtAEvG2iL9bttvw",
+				}, nil)
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{
+					Status:  USER_STATUS_DELETED,
+					PurgeAt: &purgedAt,
+				}, nil)
+			},
+			want: LoginOutput{
+				IsInvalidCredentials: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, account deleted by the user during the grace period can be restored",
+			args: args{
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdentityOutput{
+					Id:          16,
+					PhoneNumber: "phone",
+					Password:    "$argon2id$v=19$m=65536,t=3,p=2$+5OqHX693TNB1C1p6sUZyA$0rs0JiEay6zMEsnK/7itiD/Th9WpZtAEvG2iL9bttvw",
+				}, nil)
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{
+					Status:  USER_STATUS_DELETED,
+					PurgeAt: &purgeAt,
+				}, nil)
+			},
+			want: LoginOutput{
+				IsAccountDeleted: true,
+			},
+			wantId:  16,
+			wantErr: false,
+		},
+		{
+			name: "error when UpdateUserStatus of deactivated account",
+			args: args{
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdentityOutput{
+					Id:          16,
+					PhoneNumber: "phone",
+					Password:    "$argon2id$v=19$m=65536,t=3,p=2$+5OqHX693TNB1C1p6sUZyA$0rs0JiEay6zMEsnK/7itiD/Th9WpZtAEvG2iL9bttvw",
+				}, nil)
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_DEACTIVATED}, nil)
+				mockRepository.EXPECT().UpdateUserStatus(gomock.Any(), gomock.Any()).Return(repository.UpdateUserStatusOutput{}, errors.New("test"))
+			},
+			want:    LoginOutput{},
+			wantErr: true,
+		},
+		{
+			name: "error deactivated account changed concurrently",
+			args: args{
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdentityOutput{
+					Id:          16,
+					PhoneNumber: "phone",
+					Password:    "$argon2id$v=19$m=65536,t=3,p=2$+5OqHX693TNB1C1p6sUZyA$0rs0JiEay6zMEsnK/7itiD/Th9WpZtAEvG2iL9bttvw",
+				}, nil)
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_DEACTIVATED}, nil)
+				mockRepository.EXPECT().UpdateUserStatus(gomock.Any(), gomock.Any()).Return(repository.UpdateUserStatusOutput{}, nil)
+			},
+			want:    LoginOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, deactivated account is reactivated",
+			args: args{
+				input: LoginInput{
+					PhoneNumber: "phone",
+					Password:    "aaaa",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdentityOutput{
+					Id:          16,
+					PhoneNumber: "phone",
+					Password:    "$argon2id$v=19$m=65536,t=3,p=2$+5OqHX693TNB1C1p6sUZyA$0rs0JiEay6zMEsnK/7itiD/Th9WpZtAEvG2iL9bttvw",
+				}, nil)
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_DEACTIVATED}, nil)
+				mockRepository.EXPECT().UpdateUserStatus(gomock.Any(), gomock.Eq(repository.UpdateUserStatusInput{
+					Id:         16,
+					FromStatus: USER_STATUS_DEACTIVATED,
+					Status:     USER_STATUS_ACTIVE,
+					Reason:     loginReactivationReason,
+					ChangedBy:  16,
+				})).Return(repository.UpdateUserStatusOutput{IsUpdated: true}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 16,
+					Method: LOGIN_METHOD_PASSWORD,
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			},
+			want:    LoginOutput{},
+			wantId:  16,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository:         mockRepository,
+				PasswordHasher:     tt.passwordHasher,
+				PasswordExpiryDays: tt.passwordExpiryDays,
+			})
+			got, err := u.Login(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.Login() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			token := got.Token
+			got.Token = ""
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.Login() = %v, want %v", got, tt.want)
+			}
+
+			if token != "" && got.IsPasswordExpired {
+				_, err := utils.TokenParse(token)
+				assert.Error(t, err)
+
+				parse, _ := utils.TokenParseScoped(token, utils.TOKEN_SCOPE_PASSWORD_EXPIRED)
+				assert.Equal(t, tt.wantId, parse)
+			} else if token != "" && got.IsAccountDeleted {
+				_, err := utils.TokenParse(token)
+				assert.Error(t, err)
+
+				parse, _ := utils.TokenParseScoped(token, utils.TOKEN_SCOPE_ACCOUNT_RESTORATION)
+				assert.Equal(t, tt.wantId, parse)
+			} else if token != "" {
+				claims, _ := utils.TokenParseClaims(token)
+				assert.Equal(t, tt.wantId, claims.Id)
+				assert.Equal(t, tt.wantRoles, claims.Roles)
+			}
+		})
+	}
+}
+
+// recordingMailSender keeps the mails instead of sending them.
+type recordingMailSender struct {
+	mails []utils.Mail
+	err   error
+}
+
+func (s *recordingMailSender) Send(ctx context.Context, mail utils.Mail) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.mails = append(s.mails, mail)
+	return nil
+}
+
+func (s *recordingMailSender) lastTo() string {
+	if len(s.mails) == 0 {
+		return ""
+	}
+	return s.mails[len(s.mails)-1].To
+}
+
+// countingPasswordHasher counts the calls to Verify of the wrapped hasher.
+type countingPasswordHasher struct {
+	utils.PasswordHasher
+	verified int
+}
+
+func (h *countingPasswordHasher) Verify(encodedHash, password string) (bool, error) {
+	h.verified++
+	return h.PasswordHasher.Verify(encodedHash, password)
+}
+
+// stubAuthenticator serves the logins of one email domain with a fixed
+// output, like a directory would.
+type stubAuthenticator struct {
+	domain string
+	output AuthenticateOutput
+	err    error
+	inputs []LoginInput
+}
+
+func (a *stubAuthenticator) Serves(input LoginInput) bool {
+	return strings.HasSuffix(input.Email, "@"+a.domain)
+}
+
+func (a *stubAuthenticator) Authenticate(ctx context.Context, input LoginInput) (AuthenticateOutput, error) {
+	a.inputs = append(a.inputs, input)
+	return a.output, a.err
+}
+
+func TestUsecase_Login_unknownPhoneNumberVerifiesPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	hasher := &countingPasswordHasher{PasswordHasher: utils.NewBcryptHasher(bcrypt.MinCost)}
+
+	mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Any()).
+		Return(repository.GetPasswordByIdentityOutput{}, sql.ErrNoRows).Times(2)
+
+	u := NewUsecase(NewUsecaseOptions{
+		Repository:     mockRepository,
+		PasswordHasher: hasher,
+	})
+
+	for i := 1; i <= 2; i++ {
+		got, err := u.Login(context.Background(), LoginInput{
+			PhoneNumber: "phone",
+			Password:    "aaaa",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, LoginOutput{IsInvalidCredentials: true}, got)
+		assert.Equal(t, i, hasher.verified)
+	}
+
+	assert.Equal(t, utils.PASSWORD_ALGORITHM_BCRYPT, utils.PasswordHashAlgorithm(u.dummyPasswordHash))
+}
+
+// mockDirectory is a local LDAP server that understands simple binds and
+// searches with an equality filter, which is all LDAPDirectory uses.
+// Searches are only answered for the service account.
+type mockDirectory struct {
+	listener        net.Listener
+	serviceDN       string
+	servicePassword string
+	entries         []mockDirectoryEntry
+}
+
+type mockDirectoryEntry struct {
+	dn         string
+	password   string
+	attributes map[string]string
+}
+
+func newMockDirectory(t *testing.T, serviceDN, servicePassword string, entries ...mockDirectoryEntry) *mockDirectory {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when starting the mock directory", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	directory := &mockDirectory{
+		listener:        listener,
+		serviceDN:       serviceDN,
+		servicePassword: servicePassword,
+		entries:         entries,
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go directory.serve(conn)
+		}
+	}()
+
+	return directory
+}
+
+func (d *mockDirectory) url() string {
+	return "ldap://" + d.listener.Addr().String()
+}
+
+func (d *mockDirectory) serve(conn net.Conn) {
+	defer conn.Close()
+
+	boundDN := ""
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		id, request := packet.Children[0].Value.(int64), packet.Children[1]
+
+		switch request.Tag {
+		case ldap.ApplicationBindRequest:
+			dn, password := request.Children[1].Value.(string), request.Children[2].Data.String()
+
+			code := ldap.LDAPResultInvalidCredentials
+			if d.isPassword(dn, password) {
+				boundDN, code = dn, ldap.LDAPResultSuccess
+			}
+			conn.Write(mockDirectoryMessage(id, mockDirectoryResult(ldap.ApplicationBindResponse, code)))
+		case ldap.ApplicationSearchRequest:
+			if boundDN != d.serviceDN {
+				conn.Write(mockDirectoryMessage(id, mockDirectoryResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights)))
+				continue
+			}
+
+			filter, _ := ldap.DecompileFilter(request.Children[6])
+			sizeLimit := int(request.Children[3].Value.(int64))
+
+			code := ldap.LDAPResultSuccess
+			for i, entry := range d.search(filter) {
+				if i == sizeLimit {
+					code = ldap.LDAPResultSizeLimitExceeded
+					break
+				}
+				conn.Write(mockDirectoryMessage(id, entry.packet()))
+			}
+			conn.Write(mockDirectoryMessage(id, mockDirectoryResult(ldap.ApplicationSearchResultDone, code)))
+		default:
+			return
+		}
+	}
+}
+
+func (d *mockDirectory) isPassword(dn, password string) bool {
+	if dn == d.serviceDN {
+		return password == d.servicePassword
+	}
+
+	for _, entry := range d.entries {
+		if entry.dn == dn {
+			return password == entry.password
+		}
+	}
+
+	return false
+}
+
+// search answers filters like (mail=jane@corp.example.com), ignoring case
+// like the matching rules of mail and telephoneNumber do
+func (d *mockDirectory) search(filter string) []mockDirectoryEntry {
+	name, value, _ := strings.Cut(strings.Trim(filter, "()"), "=")
+
+	var found []mockDirectoryEntry
+	for _, entry := range d.entries {
+		if strings.EqualFold(entry.attributes[name], value) {
+			found = append(found, entry)
+		}
+	}
+
+	return found
+}
+
+func (e mockDirectoryEntry) packet() *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, ""))
+
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	for name, value := range e.attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+
+		values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+		values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, ""))
+		attribute.AppendChild(values)
+
+		attributes.AppendChild(attribute)
+	}
+	packet.AppendChild(attributes)
+
+	return packet
+}
+
+func mockDirectoryResult(tag ber.Tag, code int) *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+
+	return packet
+}
+
+func mockDirectoryMessage(id int64, op *ber.Packet) []byte {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+	packet.AppendChild(op)
+
+	return packet.Bytes()
+}
+
+func TestUsecase_Login_directory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	server := newMockDirectory(t, "cn=service,dc=corp,dc=example,dc=com", "service-secret",
+		mockDirectoryEntry{
+			dn:       "uid=jane,ou=people,dc=corp,dc=example,dc=com",
+			password: "Jane-Secret1",
+			attributes: map[string]string{
+				"entryUUID":   "6c1d8a52-0b7e-4c39-9a0e-1d2f3a4b5c6d",
+				"displayName": "Jane Doe",
+				"mail":        "jane@corp.example.com",
+				"mobile":      "+622155501234",
+			},
+		},
+		mockDirectoryEntry{
+			dn:       "uid=john,ou=people,dc=corp,dc=example,dc=com",
+			password: "John-Secret1",
+			attributes: map[string]string{
+				"entryUUID": "0d4f8c2e-5a1b-4e7d-8f3c-2b6a9e1d7c40",
+				"mail":      "john@partner.example.org",
+				"mobile":    "+622155505678",
+			},
+		},
+		mockDirectoryEntry{
+			dn:       "uid=front-desk-1,ou=people,dc=corp,dc=example,dc=com",
+			password: "Desk-Secret1",
+			attributes: map[string]string{
+				"entryUUID": "1f7e2d4c-3b5a-4c69-8d7e-9f0a1b2c3d4e",
+				"mail":      "front-desk@corp.example.com",
+			},
+		},
+		mockDirectoryEntry{
+			dn:       "uid=front-desk-2,ou=people,dc=corp,dc=example,dc=com",
+			password: "Desk-Secret1",
+			attributes: map[string]string{
+				"entryUUID": "2a8f3e5d-4c6b-4d7a-9e8f-0a1b2c3d4e5f",
+				"mail":      "front-desk@corp.example.com",
+			},
+		},
+		mockDirectoryEntry{
+			dn:       "uid=legacy,ou=people,dc=corp,dc=example,dc=com",
+			password: "Legacy-Secret1",
+			attributes: map[string]string{
+				"mail": "legacy@corp.example.com",
+			},
+		},
+	)
+
+	newDirectory := func() *utils.LDAPDirectory {
+		return &utils.LDAPDirectory{
+			Name:           "corp",
+			URL:            server.url(),
+			BindDN:         server.serviceDN,
+			BindPassword:   server.servicePassword,
+			BaseDN:         "dc=corp,dc=example,dc=com",
+			EmailFilter:    "(mail={identifier})",
+			PhoneFilter:    "(mobile={identifier})",
+			IdAttribute:    "entryUUID",
+			NameAttribute:  "displayName",
+			EmailAttribute: "mail",
+			EmailDomains:   []string{"corp.example.com"},
+			PhonePrefixes:  []string{"+62215550"},
+			Timeout:        5 * time.Second,
+		}
+	}
+
+	tests := []struct {
+		name          string
+		input         LoginInput
+		directoryFunc func(*utils.LDAPDirectory)
+		mockFunc      func()
+		want          LoginOutput
+		wantId        int64
+		wantErr       bool
+	}{
+		{
+			name: "success, linked user logs in with email",
+			input: LoginInput{
+				Email:    "jane@corp.example.com",
+				Password: "Jane-Secret1",
+			},
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Eq(repository.GetUserIdByIdentityInput{
+					Type:       "corp",
+					Identifier: "6c1d8a52-0b7e-4c39-9a0e-1d2f3a4b5c6d",
+				})).Return(repository.GetUserIdByIdentityOutput{
+					UserId:     7,
+					IsVerified: true,
+				}, nil)
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 7,
+					Method: "corp",
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			},
+			wantId: 7,
+		},
+		{
+			name: "success, first login provisions the user",
+			input: LoginInput{
+				Email:    "JANE@CORP.EXAMPLE.COM",
+				Password: "Jane-Secret1",
+			},
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Eq(repository.GetUserIdByIdentityInput{
+					Type:       "corp",
+					Identifier: "6c1d8a52-0b7e-4c39-9a0e-1d2f3a4b5c6d",
+				})).Return(repository.GetUserIdByIdentityOutput{}, errors.WithStack(sql.ErrNoRows))
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Eq(repository.GetUserIdByIdentityInput{
+					Type:       IDENTITY_TYPE_EMAIL,
+					Identifier: "jane@corp.example.com",
+				})).Return(repository.GetUserIdByIdentityOutput{}, errors.WithStack(sql.ErrNoRows))
+				mockRepository.EXPECT().InsertExternalUser(gomock.Any(), gomock.Eq(repository.InsertExternalUserInput{
+					FullName:   "Jane Doe",
+					Type:       "corp",
+					Identifier: "6c1d8a52-0b7e-4c39-9a0e-1d2f3a4b5c6d",
+					Email:      "jane@corp.example.com",
+				})).Return(repository.InsertExternalUserOutput{
+					Id: 8,
+				}, nil)
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 8,
+					Method: "corp",
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			},
+			wantId: 8,
+		},
+		{
+			name: "success, first login links the user with the verified email",
+			input: LoginInput{
+				Email:    "jane@corp.example.com",
+				Password: "Jane-Secret1",
+			},
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Eq(repository.GetUserIdByIdentityInput{
+					Type:       "corp",
+					Identifier: "6c1d8a52-0b7e-4c39-9a0e-1d2f3a4b5c6d",
+				})).Return(repository.GetUserIdByIdentityOutput{}, errors.WithStack(sql.ErrNoRows))
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Eq(repository.GetUserIdByIdentityInput{
+					Type:       IDENTITY_TYPE_EMAIL,
+					Identifier: "jane@corp.example.com",
+				})).Return(repository.GetUserIdByIdentityOutput{
+					UserId:     3,
+					IsVerified: true,
+				}, nil)
+				mockRepository.EXPECT().InsertIdentity(gomock.Any(), gomock.Eq(repository.InsertIdentityInput{
+					UserId:     3,
+					Type:       "corp",
+					Identifier: "6c1d8a52-0b7e-4c39-9a0e-1d2f3a4b5c6d",
+					IsVerified: true,
+				})).Return(repository.InsertIdentityOutput{
+					Id: 30,
+				}, nil)
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 3,
+					Method: "corp",
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			},
+			wantId: 3,
+		},
+		{
+			name: "success, login with phone number, email of another domain is not taken over",
+			input: LoginInput{
+				PhoneNumber: "+622155505678",
+				Password:    "John-Secret1",
+			},
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Eq(repository.GetUserIdByIdentityInput{
+					Type:       "corp",
+					Identifier: "0d4f8c2e-5a1b-4e7d-8f3c-2b6a9e1d7c40",
+				})).Return(repository.GetUserIdByIdentityOutput{}, errors.WithStack(sql.ErrNoRows))
+				mockRepository.EXPECT().InsertExternalUser(gomock.Any(), gomock.Eq(repository.InsertExternalUserInput{
+					FullName:   "john",
+					Type:       "corp",
+					Identifier: "0d4f8c2e-5a1b-4e7d-8f3c-2b6a9e1d7c40",
+				})).Return(repository.InsertExternalUserOutput{
+					Id: 9,
+				}, nil)
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 9,
+					Method: "corp",
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			},
+			wantId: 9,
+		},
+		{
+			name: "success, wrong password is invalid credentials",
+			input: LoginInput{
+				Email:    "jane@corp.example.com",
+				Password: "Jane-Secret2",
+			},
+			mockFunc: func() {},
+			want: LoginOutput{
+				IsInvalidCredentials: true,
+			},
+		},
+		{
+			name: "success, empty password is invalid credentials",
+			input: LoginInput{
+				Email:    "jane@corp.example.com",
+				Password: "",
+			},
+			mockFunc: func() {},
+			want: LoginOutput{
+				IsInvalidCredentials: true,
+			},
+		},
+		{
+			name: "success, email unknown to the directory is invalid credentials",
+			input: LoginInput{
+				Email:    "nobody@corp.example.com",
+				Password: "Jane-Secret1",
+			},
+			mockFunc: func() {},
+			want: LoginOutput{
+				IsInvalidCredentials: true,
+			},
+		},
+		{
+			name: "success, email of several entries is invalid credentials",
+			input: LoginInput{
+				Email:    "front-desk@corp.example.com",
+				Password: "Desk-Secret1",
+			},
+			mockFunc: func() {},
+			want: LoginOutput{
+				IsInvalidCredentials: true,
+			},
+		},
+		{
+			name: "success, email of another domain uses the stored password",
+			input: LoginInput{
+				Email:    "jane@example.com",
+				Password: "Jane-Secret1",
+			},
+			mockFunc: func() {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Eq(repository.GetPasswordByIdentityInput{
+					Type:       IDENTITY_TYPE_EMAIL,
+					Identifier: "jane@example.com",
+				})).Return(repository.GetPasswordByIdentityOutput{}, sql.ErrNoRows)
+			},
+			want: LoginOutput{
+				IsInvalidCredentials: true,
+			},
+		},
+		{
+			name: "success, phone number without routed prefix uses the stored password",
+			input: LoginInput{
+				PhoneNumber: "+6281234567890",
+				Password:    "Jane-Secret1",
+			},
+			mockFunc: func() {
+				mockRepository.EXPECT().GetPasswordByIdentity(gomock.Any(), gomock.Eq(repository.GetPasswordByIdentityInput{
+					Type:       IDENTITY_TYPE_PHONE,
+					Identifier: "+6281234567890",
+				})).Return(repository.GetPasswordByIdentityOutput{}, sql.ErrNoRows)
+			},
+			want: LoginOutput{
+				IsInvalidCredentials: true,
+			},
+		},
+		{
+			name: "error when entry has no id",
+			input: LoginInput{
+				Email:    "legacy@corp.example.com",
+				Password: "Legacy-Secret1",
+			},
+			mockFunc: func() {},
+			wantErr:  true,
+		},
+		{
+			name: "error when service account is refused",
+			input: LoginInput{
+				Email:    "jane@corp.example.com",
+				Password: "Jane-Secret1",
+			},
+			directoryFunc: func(d *utils.LDAPDirectory) {
+				d.BindPassword = "wrong"
+			},
+			mockFunc: func() {},
+			wantErr:  true,
+		},
+		{
+			name: "error when directory is unreachable",
+			input: LoginInput{
+				Email:    "jane@corp.example.com",
+				Password: "Jane-Secret1",
+			},
+			directoryFunc: func(d *utils.LDAPDirectory) {
+				d.URL = "ldap://127.0.0.1:1"
+			},
+			mockFunc: func() {},
+			wantErr:  true,
+		},
+		{
+			name: "error when GetUserIdByIdentity",
+			input: LoginInput{
+				Email:    "jane@corp.example.com",
+				Password: "Jane-Secret1",
+			},
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Any()).
+					Return(repository.GetUserIdByIdentityOutput{}, errors.New("test"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc()
+
+			directory := newDirectory()
+			if tt.directoryFunc != nil {
+				tt.directoryFunc(directory)
+			}
+
+			u := NewUsecase(NewUsecaseOptions{
+				Repository:      mockRepository,
+				PasswordHasher:  utils.NewBcryptHasher(bcrypt.MinCost),
+				OIDCProviders:   map[string]*utils.OIDCProvider{},
+				LDAPDirectories: []*utils.LDAPDirectory{directory},
+			})
+			got, err := u.Login(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.Login() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			token := got.Token
+			got.Token = ""
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.Login() = %v, want %v", got, tt.want)
+			}
+
+			if tt.wantId != 0 {
+				id, err := utils.TokenParse(token)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantId, id)
+			} else {
+				assert.Empty(t, token)
+			}
+		})
+	}
+}
+
+func TestUsecase_GetUserData(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	type args struct {
+		input GetUserDataInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     GetUserDataOutput
+		wantErr  bool
+	}{
+		{
+			name: "error when GetUserDataById",
+			args: args{
+				input: GetUserDataInput{
+					Id: 11,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetUserDataByIdOutput{}, errors.New("test"))
+			},
+			want:    GetUserDataOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			args: args{
+				input: GetUserDataInput{
+					Id: 11,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetUserDataByIdOutput{
+					PhoneNumber: "phoneNumber",
+					FullName:    "fullName",
+				}, nil)
+			},
+			want: GetUserDataOutput{
+				PhoneNumber: "phoneNumber",
+				FullName:    "fullName",
+			},
+			wantErr: false,
+		},
+		{
+			name: "success with avatar",
+			args: args{
+				input: GetUserDataInput{
+					Id: 11,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetUserDataByIdOutput{
+					PhoneNumber: "phoneNumber",
+					FullName:    "fullName",
+					AvatarKey:   "avatars/11/abc",
+				}, nil)
+			},
+			want: GetUserDataOutput{
+				PhoneNumber: "phoneNumber",
+				FullName:    "fullName",
+				Avatar: &AvatarURLs{
+					Small:  "http://localhost/storage/avatars/11/abc/small.jpg",
+					Medium: "http://localhost/storage/avatars/11/abc/medium.jpg",
+					Large:  "http://localhost/storage/avatars/11/abc/large.jpg",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "success with attributes",
+			args: args{
+				input: GetUserDataInput{
+					Id: 11,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetUserDataByIdOutput{
+					PhoneNumber: "phoneNumber",
+					FullName:    "fullName",
+					Attributes:  []byte(`{"employee_id": "E-1", "shoe_size": 42}`),
+				}, nil)
+			},
+			want: GetUserDataOutput{
+				PhoneNumber: "phoneNumber",
+				FullName:    "fullName",
+				Attributes: map[string]interface{}{
+					"employee_id": "E-1",
+					"shoe_size":   float64(42),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "success with empty attributes",
+			args: args{
+				input: GetUserDataInput{
+					Id: 11,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetUserDataByIdOutput{
+					PhoneNumber: "phoneNumber",
+					FullName:    "fullName",
+					Attributes:  []byte(`{}`),
+				}, nil)
+			},
+			want: GetUserDataOutput{
+				PhoneNumber: "phoneNumber",
+				FullName:    "fullName",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+				ObjectStorage: &utils.LocalObjectStorage{
+					Dir:     t.TempDir(),
+					BaseURL: "http://localhost/storage/",
+				},
+			})
+			got, err := u.GetUserData(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.GetUserData() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.GetUserData() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_UpdateUserData(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	type args struct {
+		input UpdateUserDataInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		want       UpdateUserDataOutput
+		wantMailTo string
+		wantErr    bool
+	}{
+		{
+			name: "error when GetUserDataById",
+			args: args{
+				input: UpdateUserDataInput{
+					Id:          10,
+					PhoneNumber: "numberPhone",
+					FullName:    "fullname",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetUserDataByIdOutput{}, errors.New("test"))
+			},
+			want:    UpdateUserDataOutput{},
+			wantErr: true,
+		},
+		{
+			name: "error when UpdateUserData",
+			args: args{
+				input: UpdateUserDataInput{
+					Id:          10,
+					PhoneNumber: "numberPhone",
+					FullName:    "fullname",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetUserDataByIdOutput{
+					Id:          "10",
+					PhoneNumber: "phoneNumber",
+					FullName:    "nameFull",
+				}, nil)
+
+				mockRepository.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(repository.UpdateUserDataInput{
+					Id:          a.input.Id,
+					PhoneNumber: a.input.PhoneNumber,
+					FullName:    a.input.FullName,
+				})).Return(repository.UpdateUserDataOutput{
+					IsPhoneNumberExists: true,
+				}, errors.New("test"))
+			},
+			want:    UpdateUserDataOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			args: args{
+				input: UpdateUserDataInput{
+					Id:          10,
+					PhoneNumber: "numberPhone",
 					FullName:    "fullname",
 				},
 			},
 			mockFunc: func(a args) {
-				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
-					Id: a.input.Id,
-				})).Return(repository.GetUserDataByIdOutput{
-					Id:          "10",
-					PhoneNumber: "phoneNumber",
-					FullName:    "nameFull",
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetUserDataByIdOutput{
+					Id:          "10",
+					PhoneNumber: "phoneNumber",
+					FullName:    "nameFull",
+				}, nil)
+
+				mockRepository.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(repository.UpdateUserDataInput{
+					Id:          a.input.Id,
+					PhoneNumber: a.input.PhoneNumber,
+					FullName:    a.input.FullName,
+				})).Return(repository.UpdateUserDataOutput{
+					IsPhoneNumberExists: true,
+				}, nil)
+			},
+			want: UpdateUserDataOutput{
+				IsPhoneNumberExists: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, only language updated",
+			args: args{
+				input: UpdateUserDataInput{
+					Id:       10,
+					Language: "id",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetUserDataByIdOutput{
+					Id:          "10",
+					PhoneNumber: "phoneNumber",
+					FullName:    "nameFull",
+					Language:    "en",
+				}, nil)
+
+				mockRepository.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(repository.UpdateUserDataInput{
+					Id:          a.input.Id,
+					PhoneNumber: "phoneNumber",
+					FullName:    "nameFull",
+					Language:    "id",
+				})).Return(repository.UpdateUserDataOutput{}, nil)
+			},
+			want:    UpdateUserDataOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success, new email is mailed a verification",
+			args: args{
+				input: UpdateUserDataInput{
+					Id:           10,
+					Email:        "new@example.com",
+					MailLanguage: "en",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetUserDataByIdOutput{
+					Id:            "10",
+					PhoneNumber:   "phoneNumber",
+					FullName:      "nameFull",
+					Email:         "old@example.com",
+					EmailVerified: true,
+				}, nil)
+
+				mockRepository.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(repository.UpdateUserDataInput{
+					Id:          a.input.Id,
+					PhoneNumber: "phoneNumber",
+					FullName:    "nameFull",
+					Email:       "new@example.com",
+				})).Return(repository.UpdateUserDataOutput{}, nil)
+
+				mockRepository.EXPECT().UpsertEmailVerification(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			want:       UpdateUserDataOutput{},
+			wantMailTo: "new@example.com",
+			wantErr:    false,
+		},
+		{
+			name: "success, same email in another case is not verified again",
+			args: args{
+				input: UpdateUserDataInput{
+					Id:    10,
+					Email: "Name@Example.com",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetUserDataByIdOutput{
+					Id:            "10",
+					PhoneNumber:   "phoneNumber",
+					FullName:      "nameFull",
+					Email:         "name@example.com",
+					EmailVerified: true,
+				}, nil)
+
+				mockRepository.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(repository.UpdateUserDataInput{
+					Id:          a.input.Id,
+					PhoneNumber: "phoneNumber",
+					FullName:    "nameFull",
+					Email:       "Name@Example.com",
+				})).Return(repository.UpdateUserDataOutput{}, nil)
+			},
+			want:    UpdateUserDataOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success, email exists",
+			args: args{
+				input: UpdateUserDataInput{
+					Id:    10,
+					Email: "new@example.com",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetUserDataByIdOutput{
+					Id:          "10",
+					PhoneNumber: "phoneNumber",
+					FullName:    "nameFull",
+				}, nil)
+
+				mockRepository.EXPECT().UpdateUserData(gomock.Any(), gomock.Any()).Return(repository.UpdateUserDataOutput{
+					IsEmailExists: true,
+				}, nil)
+			},
+			want: UpdateUserDataOutput{
+				IsEmailExists: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success with attributes, read-only attributes are kept",
+			args: args{
+				input: UpdateUserDataInput{
+					Id: 10,
+					Attributes: map[string]interface{}{
+						"shoe_size": float64(43),
+					},
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetUserDataByIdOutput{
+					Id:          "10",
+					PhoneNumber: "phoneNumber",
+					FullName:    "nameFull",
+					Attributes:  []byte(`{"employee_id": "E-1", "shoe_size": 42}`),
+				}, nil)
+
+				mockRepository.EXPECT().GetLatestAttributeSchema(gomock.Any(), gomock.Any()).Return(repository.GetLatestAttributeSchemaOutput{
+					Schema: []byte(testAttributeSchema),
+				}, nil)
+
+				mockRepository.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(repository.UpdateUserDataInput{
+					Id:          a.input.Id,
+					PhoneNumber: "phoneNumber",
+					FullName:    "nameFull",
+					Attributes:  []byte(`{"employee_id":"E-1","shoe_size":43}`),
+				})).Return(repository.UpdateUserDataOutput{}, nil)
+			},
+			want:    UpdateUserDataOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success, unchanged read-only attribute can be repeated",
+			args: args{
+				input: UpdateUserDataInput{
+					Id: 10,
+					Attributes: map[string]interface{}{
+						"employee_id": "E-1",
+					},
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetUserDataByIdOutput{
+					Id:          "10",
+					PhoneNumber: "phoneNumber",
+					FullName:    "nameFull",
+					Attributes:  []byte(`{"employee_id": "E-1", "shoe_size": 42}`),
+				}, nil)
+
+				mockRepository.EXPECT().GetLatestAttributeSchema(gomock.Any(), gomock.Any()).Return(repository.GetLatestAttributeSchemaOutput{
+					Schema: []byte(testAttributeSchema),
+				}, nil)
+
+				mockRepository.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(repository.UpdateUserDataInput{
+					Id:          a.input.Id,
+					PhoneNumber: "phoneNumber",
+					FullName:    "nameFull",
+					Attributes:  []byte(`{"employee_id":"E-1"}`),
+				})).Return(repository.UpdateUserDataOutput{}, nil)
+			},
+			want:    UpdateUserDataOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success, changed read-only attribute and invalid attribute are refused",
+			args: args{
+				input: UpdateUserDataInput{
+					Id:       10,
+					FullName: "fullname",
+					Attributes: map[string]interface{}{
+						"employee_id": "E-2",
+						"shoe_size":   "big",
+					},
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetUserDataByIdOutput{
+					Id:          "10",
+					PhoneNumber: "phoneNumber",
+					FullName:    "nameFull",
+					Attributes:  []byte(`{"employee_id": "E-1"}`),
+				}, nil)
+
+				mockRepository.EXPECT().GetLatestAttributeSchema(gomock.Any(), gomock.Any()).Return(repository.GetLatestAttributeSchemaOutput{
+					Schema: []byte(testAttributeSchema),
+				}, nil)
+			},
+			want: UpdateUserDataOutput{
+				AttributeErrors: []AttributeError{
+					{Path: []string{"employee_id"}, IsReadOnly: true},
+					{Path: []string{"shoe_size"}, Reason: `value must be an integer`},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, no attribute is accepted without a schema",
+			args: args{
+				input: UpdateUserDataInput{
+					Id: 10,
+					Attributes: map[string]interface{}{
+						"shoe_size": float64(43),
+					},
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetUserDataByIdOutput{
+					Id:          "10",
+					PhoneNumber: "phoneNumber",
+					FullName:    "nameFull",
+				}, nil)
+
+				mockRepository.EXPECT().GetLatestAttributeSchema(gomock.Any(), gomock.Any()).Return(repository.GetLatestAttributeSchemaOutput{}, sql.ErrNoRows)
+			},
+			want: UpdateUserDataOutput{
+				AttributeErrors: []AttributeError{
+					{Reason: `property "shoe_size" is unsupported`},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "error when GetLatestAttributeSchema",
+			args: args{
+				input: UpdateUserDataInput{
+					Id:         10,
+					Attributes: map[string]interface{}{},
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetUserDataByIdOutput{
+					Id: "10",
+				}, nil)
+
+				mockRepository.EXPECT().GetLatestAttributeSchema(gomock.Any(), gomock.Any()).Return(repository.GetLatestAttributeSchemaOutput{}, errors.New("test"))
+			},
+			want:    UpdateUserDataOutput{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			mailSender := &recordingMailSender{}
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+				MailSender: mailSender,
+			})
+			got, err := u.UpdateUserData(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.UpdateUserData() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.UpdateUserData() = %v, want %v", got, tt.want)
+			}
+			assert.Equal(t, tt.wantMailTo, mailSender.lastTo())
+		})
+	}
+}
+
+func TestUsecase_PatchUserData(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	text := func(s string) *string { return &s }
+
+	storedAttributes := []byte(`{"employee_id": "E-1", "shoe_size": 42}`)
+	schema := func() {
+		mockRepository.EXPECT().GetLatestAttributeSchema(gomock.Any(), gomock.Any()).Return(repository.GetLatestAttributeSchemaOutput{
+			Schema: []byte(testAttributeSchema),
+		}, nil)
+	}
+	stored := func(a PatchUserDataInput) {
+		mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Eq(repository.GetUserDataByIdInput{
+			Id: a.Id,
+		})).Return(repository.GetUserDataByIdOutput{
+			Id:         "10",
+			Attributes: storedAttributes,
+		}, nil)
+	}
+
+	type args struct {
+		input PatchUserDataInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		want       PatchUserDataOutput
+		wantMailTo string
+		wantErr    bool
+	}{
+		{
+			name: "error when PatchUserData",
+			args: args{
+				input: PatchUserDataInput{
+					Id:       10,
+					FullName: text("fullname"),
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().PatchUserData(gomock.Any(), gomock.Eq(repository.PatchUserDataInput{
+					Id:       a.input.Id,
+					FullName: a.input.FullName,
+				})).Return(repository.PatchUserDataOutput{}, errors.New("test"))
+			},
+			want:    PatchUserDataOutput{},
+			wantErr: true,
+		},
+		{
+			name: "error when the user is not updated",
+			args: args{
+				input: PatchUserDataInput{
+					Id:       10,
+					FullName: text("fullname"),
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().PatchUserData(gomock.Any(), gomock.Any()).Return(repository.PatchUserDataOutput{
+					IsNotUpdated: true,
+				}, nil)
+			},
+			want:    PatchUserDataOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, phone number exists",
+			args: args{
+				input: PatchUserDataInput{
+					Id:          10,
+					PhoneNumber: text("+6281234567890"),
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().PatchUserData(gomock.Any(), gomock.Eq(repository.PatchUserDataInput{
+					Id:          a.input.Id,
+					PhoneNumber: a.input.PhoneNumber,
+				})).Return(repository.PatchUserDataOutput{
+					IsPhoneNumberExists: true,
+				}, nil)
+			},
+			want: PatchUserDataOutput{
+				IsPhoneNumberExists: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, new email is mailed a verification",
+			args: args{
+				input: PatchUserDataInput{
+					Id:           10,
+					Email:        text("new@example.com"),
+					MailLanguage: "en",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().PatchUserData(gomock.Any(), gomock.Eq(repository.PatchUserDataInput{
+					Id:    a.input.Id,
+					Email: a.input.Email,
+				})).Return(repository.PatchUserDataOutput{
+					PreviousEmail: "old@example.com",
+				}, nil)
+
+				mockRepository.EXPECT().UpsertEmailVerification(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			want:       PatchUserDataOutput{},
+			wantMailTo: "new@example.com",
+			wantErr:    false,
+		},
+		{
+			name: "success, same email in another case is not verified again",
+			args: args{
+				input: PatchUserDataInput{
+					Id:    10,
+					Email: text("Name@Example.com"),
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().PatchUserData(gomock.Any(), gomock.Any()).Return(repository.PatchUserDataOutput{
+					PreviousEmail: "name@example.com",
+				}, nil)
+			},
+			want:    PatchUserDataOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success, language and email removed",
+			args: args{
+				input: PatchUserDataInput{
+					Id:       10,
+					Language: text(""),
+					Email:    text(""),
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().PatchUserData(gomock.Any(), gomock.Eq(repository.PatchUserDataInput{
+					Id:       a.input.Id,
+					Language: text(""),
+					Email:    text(""),
+				})).Return(repository.PatchUserDataOutput{
+					PreviousEmail: "old@example.com",
+				}, nil)
+			},
+			want:    PatchUserDataOutput{},
+			wantErr: false,
+		},
+		{
+			name: "error when GetUserDataById",
+			args: args{
+				input: PatchUserDataInput{
+					Id:              10,
+					AttributesPatch: json.RawMessage(`{"shoe_size": 43}`),
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Any()).Return(repository.GetUserDataByIdOutput{}, errors.New("test"))
+			},
+			want:    PatchUserDataOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, attributes patched",
+			args: args{
+				input: PatchUserDataInput{
+					Id:              10,
+					AttributesPatch: json.RawMessage(`{"shoe_size": null}`),
+				},
+			},
+			mockFunc: func(a args) {
+				stored(a.input)
+				schema()
+
+				mockRepository.EXPECT().PatchUserData(gomock.Any(), gomock.Eq(repository.PatchUserDataInput{
+					Id:                 a.input.Id,
+					Attributes:         []byte(`{"employee_id":"E-1"}`),
+					ExpectedAttributes: storedAttributes,
+				})).Return(repository.PatchUserDataOutput{}, nil)
+			},
+			want:    PatchUserDataOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success, attributes removed except the read-only ones",
+			args: args{
+				input: PatchUserDataInput{
+					Id:              10,
+					AttributesPatch: json.RawMessage(`null`),
+				},
+			},
+			mockFunc: func(a args) {
+				stored(a.input)
+				schema()
+
+				mockRepository.EXPECT().PatchUserData(gomock.Any(), gomock.Eq(repository.PatchUserDataInput{
+					Id:                 a.input.Id,
+					Attributes:         []byte(`{"employee_id":"E-1"}`),
+					ExpectedAttributes: storedAttributes,
+				})).Return(repository.PatchUserDataOutput{}, nil)
+			},
+			want:    PatchUserDataOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success, read-only attribute removed is refused",
+			args: args{
+				input: PatchUserDataInput{
+					Id:              10,
+					FullName:        text("fullname"),
+					AttributesPatch: json.RawMessage(`{"employee_id": null, "shoe_size": 43}`),
+				},
+			},
+			mockFunc: func(a args) {
+				stored(a.input)
+				schema()
+			},
+			want: PatchUserDataOutput{
+				AttributeErrors: []AttributeError{
+					{Path: []string{"employee_id"}, IsReadOnly: true},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, attributes patched again after a concurrent change",
+			args: args{
+				input: PatchUserDataInput{
+					Id:              10,
+					AttributesPatch: json.RawMessage(`{"shoe_size": 44}`),
+				},
+			},
+			mockFunc: func(a args) {
+				stored(a.input)
+				schema()
+				mockRepository.EXPECT().PatchUserData(gomock.Any(), gomock.Any()).Return(repository.PatchUserDataOutput{
+					IsNotUpdated: true,
+				}, nil)
+
+				stored(a.input)
+				schema()
+				mockRepository.EXPECT().PatchUserData(gomock.Any(), gomock.Eq(repository.PatchUserDataInput{
+					Id:                 a.input.Id,
+					Attributes:         []byte(`{"employee_id":"E-1","shoe_size":44}`),
+					ExpectedAttributes: storedAttributes,
+				})).Return(repository.PatchUserDataOutput{}, nil)
+			},
+			want:    PatchUserDataOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success, attributes keep changing concurrently",
+			args: args{
+				input: PatchUserDataInput{
+					Id:              10,
+					AttributesPatch: json.RawMessage(`{"shoe_size": 44}`),
+				},
+			},
+			mockFunc: func(a args) {
+				for i := 0; i < PATCH_USER_DATA_ATTEMPTS; i++ {
+					stored(a.input)
+					schema()
+					mockRepository.EXPECT().PatchUserData(gomock.Any(), gomock.Any()).Return(repository.PatchUserDataOutput{
+						IsNotUpdated: true,
+					}, nil)
+				}
+			},
+			want: PatchUserDataOutput{
+				IsConflict: true,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			mailSender := &recordingMailSender{}
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+				MailSender: mailSender,
+			})
+			got, err := u.PatchUserData(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.PatchUserData() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.PatchUserData() = %v, want %v", got, tt.want)
+			}
+			assert.Equal(t, tt.wantMailTo, mailSender.lastTo())
+		})
+	}
+}
+
+func TestUsecase_SetPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	var (
+		hasher         = utils.NewArgon2idHasher(1024, 1, 1)
+		currentHash, _ = hasher.Hash("Current1!")
+		oldHash, _     = hasher.Hash("Older1!")
+	)
+
+	type args struct {
+		input SetPasswordInput
+	}
+	tests := []struct {
+		name                string
+		args                args
+		passwordHistorySize int
+		mockFunc            func(args)
+		want                SetPasswordOutput
+		wantErr             bool
+	}{
+		{
+			name: "error when GetPasswordById",
+			args: args{
+				input: SetPasswordInput{
+					Id:              10,
+					CurrentPassword: "Current1!",
+					NewPassword:     "Newest1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Eq(repository.GetPasswordByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetPasswordByIdOutput{}, errors.New("test"))
+			},
+			want:    SetPasswordOutput{},
+			wantErr: true,
+		},
+		{
+			name: "error when verifying current password",
+			args: args{
+				input: SetPasswordInput{
+					Id:              10,
+					CurrentPassword: "Current1!",
+					NewPassword:     "Newest1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Eq(repository.GetPasswordByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetPasswordByIdOutput{
+					Id:       10,
+					Password: "abcd",
+				}, nil)
+			},
+			want:    SetPasswordOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, user without password",
+			args: args{
+				input: SetPasswordInput{
+					Id:              10,
+					CurrentPassword: "Current1!",
+					NewPassword:     "Newest1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{
+					Id: 10,
+				}, nil)
+			},
+			want: SetPasswordOutput{
+				IsPasswordWrong: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, current password wrong",
+			args: args{
+				input: SetPasswordInput{
+					Id:              10,
+					CurrentPassword: "Wrong1!",
+					NewPassword:     "Newest1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Eq(repository.GetPasswordByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetPasswordByIdOutput{
+					Id:       10,
+					Password: currentHash,
+				}, nil)
+			},
+			want: SetPasswordOutput{
+				IsPasswordWrong: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error when GetPasswordHistoryByUserId",
+			args: args{
+				input: SetPasswordInput{
+					Id:              10,
+					CurrentPassword: "Current1!",
+					NewPassword:     "Newest1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Eq(repository.GetPasswordByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetPasswordByIdOutput{
+					Id:       10,
+					Password: currentHash,
+				}, nil)
+
+				mockRepository.EXPECT().GetPasswordHistoryByUserId(gomock.Any(), gomock.Eq(repository.GetPasswordHistoryByUserIdInput{
+					UserId: a.input.Id,
+					Limit:  5,
+				})).Return(repository.GetPasswordHistoryByUserIdOutput{}, errors.New("test"))
+			},
+			want:    SetPasswordOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, new password is the current password",
+			args: args{
+				input: SetPasswordInput{
+					Id:              10,
+					CurrentPassword: "Current1!",
+					NewPassword:     "Current1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Eq(repository.GetPasswordByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetPasswordByIdOutput{
+					Id:       10,
+					Password: currentHash,
+				}, nil)
+
+				mockRepository.EXPECT().GetPasswordHistoryByUserId(gomock.Any(), gomock.Any()).Return(repository.GetPasswordHistoryByUserIdOutput{}, nil)
+			},
+			want: SetPasswordOutput{
+				IsPasswordReused: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, new password found in history",
+			args: args{
+				input: SetPasswordInput{
+					Id:              10,
+					CurrentPassword: "Current1!",
+					NewPassword:     "Older1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Eq(repository.GetPasswordByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetPasswordByIdOutput{
+					Id:       10,
+					Password: currentHash,
+				}, nil)
+
+				mockRepository.EXPECT().GetPasswordHistoryByUserId(gomock.Any(), gomock.Any()).Return(repository.GetPasswordHistoryByUserIdOutput{
+					Passwords: []string{"abcd", currentHash, oldHash},
+				}, nil)
+			},
+			want: SetPasswordOutput{
+				IsPasswordReused: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error when SetPasswordById",
+			args: args{
+				input: SetPasswordInput{
+					Id:              10,
+					CurrentPassword: "Current1!",
+					NewPassword:     "Newest1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{
+					Id:       10,
+					Password: currentHash,
+				}, nil)
+
+				mockRepository.EXPECT().GetPasswordHistoryByUserId(gomock.Any(), gomock.Any()).Return(repository.GetPasswordHistoryByUserIdOutput{
+					Passwords: []string{currentHash, oldHash},
+				}, nil)
+
+				mockRepository.EXPECT().SetPasswordById(gomock.Any(), gomock.Any()).Return(errors.New("test"))
+			},
+			want:    SetPasswordOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, old history cleanup error ignored",
+			args: args{
+				input: SetPasswordInput{
+					Id:              10,
+					CurrentPassword: "Current1!",
+					NewPassword:     "Newest1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{
+					Id:       10,
+					Password: currentHash,
+				}, nil)
+
+				mockRepository.EXPECT().GetPasswordHistoryByUserId(gomock.Any(), gomock.Any()).Return(repository.GetPasswordHistoryByUserIdOutput{
+					Passwords: []string{currentHash, oldHash},
+				}, nil)
+
+				mockRepository.EXPECT().SetPasswordById(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, input repository.SetPasswordByIdInput) error {
+						assert.Equal(t, int64(10), input.Id)
+						isMatch, _ := hasher.Verify(input.Password, "Newest1!")
+						assert.True(t, isMatch)
+						return nil
+					})
+
+				mockRepository.EXPECT().DeleteOldPasswordHistory(gomock.Any(), gomock.Eq(repository.DeleteOldPasswordHistoryInput{
+					UserId: 10,
+					Keep:   5,
+				})).Return(errors.New("test"))
+			},
+			want:    SetPasswordOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success, history check disabled",
+			args: args{
+				input: SetPasswordInput{
+					Id:              10,
+					CurrentPassword: "Current1!",
+					NewPassword:     "Current1!",
+				},
+			},
+			passwordHistorySize: -1,
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{
+					Id:       10,
+					Password: currentHash,
+				}, nil)
+
+				mockRepository.EXPECT().SetPasswordById(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			want:    SetPasswordOutput{},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository:          mockRepository,
+				PasswordHasher:      hasher,
+				PasswordHistorySize: tt.passwordHistorySize,
+			})
+			got, err := u.SetPassword(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.SetPassword() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.SetPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_SetExpiredPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	var (
+		hasher         = utils.NewArgon2idHasher(1024, 1, 1)
+		currentHash, _ = hasher.Hash("Current1!")
+	)
+
+	type args struct {
+		input SetExpiredPasswordInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     SetExpiredPasswordOutput
+		wantId   int64
+		wantErr  bool
+	}{
+		{
+			name: "error when GetPasswordById",
+			args: args{
+				input: SetExpiredPasswordInput{
+					Id:          10,
+					NewPassword: "Newest1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Eq(repository.GetPasswordByIdInput{
+					Id: a.input.Id,
+				})).Return(repository.GetPasswordByIdOutput{}, errors.New("test"))
+			},
+			want:    SetExpiredPasswordOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, new password is the expired password",
+			args: args{
+				input: SetExpiredPasswordInput{
+					Id:          10,
+					NewPassword: "Current1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{
+					Id:       10,
+					Password: currentHash,
+				}, nil)
+
+				mockRepository.EXPECT().GetPasswordHistoryByUserId(gomock.Any(), gomock.Any()).Return(repository.GetPasswordHistoryByUserIdOutput{}, nil)
+			},
+			want: SetExpiredPasswordOutput{
+				IsPasswordReused: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error when SetPasswordById",
+			args: args{
+				input: SetExpiredPasswordInput{
+					Id:          10,
+					NewPassword: "Newest1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{
+					Id:       10,
+					Password: currentHash,
+				}, nil)
+
+				mockRepository.EXPECT().GetPasswordHistoryByUserId(gomock.Any(), gomock.Any()).Return(repository.GetPasswordHistoryByUserIdOutput{}, nil)
+
+				mockRepository.EXPECT().SetPasswordById(gomock.Any(), gomock.Any()).Return(errors.New("test"))
+			},
+			want:    SetExpiredPasswordOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			args: args{
+				input: SetExpiredPasswordInput{
+					Id:          10,
+					NewPassword: "Newest1!",
+					IP:          "203.0.113.7",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{
+					Id:       10,
+					Password: currentHash,
+				}, nil)
+
+				mockRepository.EXPECT().GetPasswordHistoryByUserId(gomock.Any(), gomock.Any()).Return(repository.GetPasswordHistoryByUserIdOutput{}, nil)
+
+				mockRepository.EXPECT().SetPasswordById(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, input repository.SetPasswordByIdInput) error {
+						assert.Equal(t, int64(10), input.Id)
+						isMatch, _ := hasher.Verify(input.Password, "Newest1!")
+						assert.True(t, isMatch)
+						return nil
+					})
+
+				mockRepository.EXPECT().DeleteOldPasswordHistory(gomock.Any(), gomock.Any()).Return(nil)
+
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Eq(repository.GetRolesByUserIdInput{
+					UserId: 10,
+				})).Return(repository.GetRolesByUserIdOutput{}, nil)
+
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 10,
+					Method: LOGIN_METHOD_PASSWORD,
+					IP:     "203.0.113.7",
+				})).Return(nil)
+
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Eq(repository.UpdateTotalLoginByIdInput{
+					Id: 10,
+				})).Return(nil).AnyTimes()
+			},
+			want:    SetExpiredPasswordOutput{},
+			wantId:  10,
+			wantErr: false,
+		},
+		{
+			name: "error when GetRolesByUserId",
+			args: args{
+				input: SetExpiredPasswordInput{
+					Id:          10,
+					NewPassword: "Newest1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{
+					Id:       10,
+					Password: currentHash,
+				}, nil)
+
+				mockRepository.EXPECT().GetPasswordHistoryByUserId(gomock.Any(), gomock.Any()).Return(repository.GetPasswordHistoryByUserIdOutput{}, nil)
+
+				mockRepository.EXPECT().SetPasswordById(gomock.Any(), gomock.Any()).Return(nil)
+
+				mockRepository.EXPECT().DeleteOldPasswordHistory(gomock.Any(), gomock.Any()).Return(nil)
+
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, errors.New("test"))
+			},
+			want:    SetExpiredPasswordOutput{},
+			wantErr: true,
+		},
+		{
+			name: "error when InsertLoginEvent",
+			args: args{
+				input: SetExpiredPasswordInput{
+					Id:          10,
+					NewPassword: "Newest1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{
+					Id:       10,
+					Password: currentHash,
+				}, nil)
+
+				mockRepository.EXPECT().GetPasswordHistoryByUserId(gomock.Any(), gomock.Any()).Return(repository.GetPasswordHistoryByUserIdOutput{}, nil)
+
+				mockRepository.EXPECT().SetPasswordById(gomock.Any(), gomock.Any()).Return(nil)
+
+				mockRepository.EXPECT().DeleteOldPasswordHistory(gomock.Any(), gomock.Any()).Return(nil)
+
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Any()).Return(errors.New("test"))
+			},
+			want:    SetExpiredPasswordOutput{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository:     mockRepository,
+				PasswordHasher: hasher,
+			})
+			got, err := u.SetExpiredPassword(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.SetExpiredPassword() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			token := got.Token
+			got.Token = ""
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.SetExpiredPassword() = %v, want %v", got, tt.want)
+			}
+
+			if token != "" {
+				parse, _ := utils.TokenParse(token)
+				assert.Equal(t, tt.wantId, parse)
+			}
+		})
+	}
+}
+
+func TestUsecase_SendEmailVerification(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	type args struct {
+		input SendEmailVerificationInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		mailErr    error
+		want       SendEmailVerificationOutput
+		wantMailTo string
+		wantErr    bool
+	}{
+		{
+			name: "error GetUserDataById",
+			args: args{
+				input: SendEmailVerificationInput{
+					Id: 10,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Any()).Return(repository.GetUserDataByIdOutput{}, errors.New("test"))
+			},
+			want:    SendEmailVerificationOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, email missing",
+			args: args{
+				input: SendEmailVerificationInput{
+					Id: 10,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Any()).Return(repository.GetUserDataByIdOutput{
+					Id: "10",
+				}, nil)
+			},
+			want: SendEmailVerificationOutput{
+				IsEmailMissing: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, email already verified",
+			args: args{
+				input: SendEmailVerificationInput{
+					Id: 10,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Any()).Return(repository.GetUserDataByIdOutput{
+					Id:            "10",
+					Email:         "name@example.com",
+					EmailVerified: true,
+				}, nil)
+			},
+			want: SendEmailVerificationOutput{
+				IsEmailVerified: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error UpsertEmailVerification",
+			args: args{
+				input: SendEmailVerificationInput{
+					Id: 10,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Any()).Return(repository.GetUserDataByIdOutput{
+					Id:    "10",
+					Email: "name@example.com",
+				}, nil)
+
+				mockRepository.EXPECT().UpsertEmailVerification(gomock.Any(), gomock.Any()).Return(errors.New("test"))
+			},
+			want:    SendEmailVerificationOutput{},
+			wantErr: true,
+		},
+		{
+			name: "error mail sender",
+			args: args{
+				input: SendEmailVerificationInput{
+					Id: 10,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Any()).Return(repository.GetUserDataByIdOutput{
+					Id:    "10",
+					Email: "name@example.com",
+				}, nil)
+
+				mockRepository.EXPECT().UpsertEmailVerification(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			mailErr: errors.New("test"),
+			want:    SendEmailVerificationOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			args: args{
+				input: SendEmailVerificationInput{
+					Id:           10,
+					MailLanguage: "en",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Any()).Return(repository.GetUserDataByIdOutput{
+					Id:    "10",
+					Email: "name@example.com",
+				}, nil)
+
+				mockRepository.EXPECT().UpsertEmailVerification(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, input repository.UpsertEmailVerificationInput) error {
+						assert.Equal(t, int64(10), input.UserId)
+						assert.Equal(t, "name@example.com", input.Email)
+						assert.WithinDuration(t, time.Now().Add(time.Hour), input.ExpiresAt, time.Minute)
+						return nil
+					})
+			},
+			want:       SendEmailVerificationOutput{},
+			wantMailTo: "name@example.com",
+			wantErr:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			mailSender := &recordingMailSender{err: tt.mailErr}
+			u := NewUsecase(NewUsecaseOptions{
+				Repository:                mockRepository,
+				MailSender:                mailSender,
+				EmailVerificationLink:     "https://example.com/verify?token={token}",
+				EmailVerificationLifespan: time.Hour,
+			})
+			got, err := u.SendEmailVerification(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.SendEmailVerification() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.SendEmailVerification() = %v, want %v", got, tt.want)
+			}
+			assert.Equal(t, tt.wantMailTo, mailSender.lastTo())
+		})
+	}
+}
+
+func TestUsecase_SendEmailVerification_mailedSecretsMatchStoredHashes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	var stored repository.UpsertEmailVerificationInput
+	mockRepository.EXPECT().GetUserDataById(gomock.Any(), gomock.Any()).Return(repository.GetUserDataByIdOutput{
+		Id:    "10",
+		Email: "name@example.com",
+	}, nil)
+	mockRepository.EXPECT().UpsertEmailVerification(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, input repository.UpsertEmailVerificationInput) error {
+			stored = input
+			return nil
+		})
+
+	mailSender := &recordingMailSender{}
+	u := NewUsecase(NewUsecaseOptions{
+		Repository:                mockRepository,
+		MailSender:                mailSender,
+		EmailVerificationLink:     "https://example.com/verify?token={token}",
+		EmailVerificationLifespan: time.Hour,
+	})
+
+	_, err := u.SendEmailVerification(context.Background(), SendEmailVerificationInput{Id: 10, MailLanguage: "en"})
+	assert.NoError(t, err)
+	assert.Len(t, mailSender.mails, 1)
+
+	body := mailSender.mails[0].Body
+	_, token, found := strings.Cut(body, "https://example.com/verify?token=")
+	assert.True(t, found)
+	token, _, _ = strings.Cut(token, "\n")
+	assert.Equal(t, stored.TokenHash, hashVerificationSecret(token))
+
+	code := ""
+	for _, field := range strings.Fields(body) {
+		if len(field) == emailVerificationCodeDigits && hashVerificationSecret(field) == stored.CodeHash {
+			code = field
+		}
+	}
+	assert.NotEmpty(t, code, "mailed code does not match the stored hash")
+	assert.Contains(t, body, "60")
+}
+
+func TestUsecase_VerifyEmailByToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	verification := repository.GetEmailVerificationOutput{
+		Id:        3,
+		UserId:    10,
+		Email:     "name@example.com",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	type args struct {
+		input VerifyEmailByTokenInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     VerifyEmailOutput
+		wantErr  bool
+	}{
+		{
+			name: "success, unknown token",
+			args: args{
+				input: VerifyEmailByTokenInput{
+					Token: "token",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetEmailVerificationByTokenHash(gomock.Any(), gomock.Eq(repository.GetEmailVerificationByTokenHashInput{
+					TokenHash: hashVerificationSecret(a.input.Token),
+				})).Return(repository.GetEmailVerificationOutput{}, sql.ErrNoRows)
+			},
+			want: VerifyEmailOutput{
+				IsInvalid: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error GetEmailVerificationByTokenHash",
+			args: args{
+				input: VerifyEmailByTokenInput{
+					Token: "token",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetEmailVerificationByTokenHash(gomock.Any(), gomock.Any()).Return(repository.GetEmailVerificationOutput{}, errors.New("test"))
+			},
+			want:    VerifyEmailOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, expired token",
+			args: args{
+				input: VerifyEmailByTokenInput{
+					Token: "token",
+				},
+			},
+			mockFunc: func(a args) {
+				expired := verification
+				expired.ExpiresAt = time.Now().Add(-time.Minute)
+				mockRepository.EXPECT().GetEmailVerificationByTokenHash(gomock.Any(), gomock.Any()).Return(expired, nil)
+			},
+			want: VerifyEmailOutput{
+				IsInvalid: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error VerifyEmail",
+			args: args{
+				input: VerifyEmailByTokenInput{
+					Token: "token",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetEmailVerificationByTokenHash(gomock.Any(), gomock.Any()).Return(verification, nil)
+				mockRepository.EXPECT().VerifyEmail(gomock.Any(), gomock.Any()).Return(repository.VerifyEmailOutput{}, errors.New("test"))
+			},
+			want:    VerifyEmailOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, email changed since the token was sent",
+			args: args{
+				input: VerifyEmailByTokenInput{
+					Token: "token",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetEmailVerificationByTokenHash(gomock.Any(), gomock.Any()).Return(verification, nil)
+				mockRepository.EXPECT().VerifyEmail(gomock.Any(), gomock.Any()).Return(repository.VerifyEmailOutput{
+					IsEmailChanged: true,
+				}, nil)
+			},
+			want: VerifyEmailOutput{
+				IsInvalid: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success",
+			args: args{
+				input: VerifyEmailByTokenInput{
+					Token: "token",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetEmailVerificationByTokenHash(gomock.Any(), gomock.Any()).Return(verification, nil)
+				mockRepository.EXPECT().VerifyEmail(gomock.Any(), gomock.Eq(repository.VerifyEmailInput{
+					UserId: 10,
+					Email:  "name@example.com",
+				})).Return(repository.VerifyEmailOutput{}, nil)
+			},
+			want:    VerifyEmailOutput{},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+			})
+			got, err := u.VerifyEmailByToken(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.VerifyEmailByToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.VerifyEmailByToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_VerifyEmailByCode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	verification := repository.GetEmailVerificationOutput{
+		Id:        3,
+		UserId:    10,
+		Email:     "name@example.com",
+		CodeHash:  hashVerificationSecret("123456"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	type args struct {
+		input VerifyEmailByCodeInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     VerifyEmailOutput
+		wantErr  bool
+	}{
+		{
+			name: "success, no pending verification",
+			args: args{
+				input: VerifyEmailByCodeInput{
+					Id:   10,
+					Code: "123456",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetEmailVerificationByUserId(gomock.Any(), gomock.Eq(repository.GetEmailVerificationByUserIdInput{
+					UserId: a.input.Id,
+				})).Return(repository.GetEmailVerificationOutput{}, sql.ErrNoRows)
+			},
+			want: VerifyEmailOutput{
+				IsInvalid: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error GetEmailVerificationByUserId",
+			args: args{
+				input: VerifyEmailByCodeInput{
+					Id:   10,
+					Code: "123456",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetEmailVerificationByUserId(gomock.Any(), gomock.Any()).Return(repository.GetEmailVerificationOutput{}, errors.New("test"))
+			},
+			want:    VerifyEmailOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, expired code",
+			args: args{
+				input: VerifyEmailByCodeInput{
+					Id:   10,
+					Code: "123456",
+				},
+			},
+			mockFunc: func(a args) {
+				expired := verification
+				expired.ExpiresAt = time.Now().Add(-time.Minute)
+				mockRepository.EXPECT().GetEmailVerificationByUserId(gomock.Any(), gomock.Any()).Return(expired, nil)
+			},
+			want: VerifyEmailOutput{
+				IsInvalid: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, out of attempts even with the right code",
+			args: args{
+				input: VerifyEmailByCodeInput{
+					Id:   10,
+					Code: "123456",
+				},
+			},
+			mockFunc: func(a args) {
+				exhausted := verification
+				exhausted.Attempts = maxEmailVerificationAttempts
+				mockRepository.EXPECT().GetEmailVerificationByUserId(gomock.Any(), gomock.Any()).Return(exhausted, nil)
+			},
+			want: VerifyEmailOutput{
+				IsInvalid: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, wrong code counts an attempt",
+			args: args{
+				input: VerifyEmailByCodeInput{
+					Id:   10,
+					Code: "654321",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetEmailVerificationByUserId(gomock.Any(), gomock.Any()).Return(verification, nil)
+				mockRepository.EXPECT().IncrementEmailVerificationAttempts(gomock.Any(), gomock.Eq(repository.IncrementEmailVerificationAttemptsInput{
+					Id: 3,
+				})).Return(nil)
+			},
+			want: VerifyEmailOutput{
+				IsInvalid: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error IncrementEmailVerificationAttempts",
+			args: args{
+				input: VerifyEmailByCodeInput{
+					Id:   10,
+					Code: "654321",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetEmailVerificationByUserId(gomock.Any(), gomock.Any()).Return(verification, nil)
+				mockRepository.EXPECT().IncrementEmailVerificationAttempts(gomock.Any(), gomock.Any()).Return(errors.New("test"))
+			},
+			want:    VerifyEmailOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			args: args{
+				input: VerifyEmailByCodeInput{
+					Id:   10,
+					Code: "123456",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetEmailVerificationByUserId(gomock.Any(), gomock.Any()).Return(verification, nil)
+				mockRepository.EXPECT().VerifyEmail(gomock.Any(), gomock.Eq(repository.VerifyEmailInput{
+					UserId: 10,
+					Email:  "name@example.com",
+				})).Return(repository.VerifyEmailOutput{}, nil)
+			},
+			want:    VerifyEmailOutput{},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+			})
+			got, err := u.VerifyEmailByCode(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.VerifyEmailByCode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.VerifyEmailByCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_GetIdentities(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	verifiedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	type args struct {
+		input GetIdentitiesInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     GetIdentitiesOutput
+		wantErr  bool
+	}{
+		{
+			name: "error GetIdentitiesByUserId",
+			args: args{
+				input: GetIdentitiesInput{
+					Id: 10,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetIdentitiesByUserIdOutput{}, errors.New("test"))
+			},
+			want:    GetIdentitiesOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			args: args{
+				input: GetIdentitiesInput{
+					Id: 10,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Eq(repository.GetIdentitiesByUserIdInput{
+					UserId: a.input.Id,
+				})).Return(repository.GetIdentitiesByUserIdOutput{
+					Identities: []repository.Identity{
+						{
+							Id:         1,
+							Type:       repository.IDENTITY_TYPE_PHONE,
+							Identifier: "+62812345678",
+							IsPrimary:  true,
+						},
+						{
+							Id:         2,
+							Type:       repository.IDENTITY_TYPE_EMAIL,
+							Identifier: "name@example.com",
+							VerifiedAt: &verifiedAt,
+						},
+					},
+				}, nil)
+			},
+			want: GetIdentitiesOutput{
+				Identities: []Identity{
+					{
+						Id:         1,
+						Type:       IDENTITY_TYPE_PHONE,
+						Identifier: "+62812345678",
+						IsPrimary:  true,
+					},
+					{
+						Id:         2,
+						Type:       IDENTITY_TYPE_EMAIL,
+						Identifier: "name@example.com",
+						VerifiedAt: &verifiedAt,
+					},
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+			})
+			got, err := u.GetIdentities(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.GetIdentities() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.GetIdentities() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_AddIdentity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	type args struct {
+		input AddIdentityInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		want       AddIdentityOutput
+		wantMailTo string
+		wantErr    bool
+	}{
+		{
+			name: "error InsertIdentity",
+			args: args{
+				input: AddIdentityInput{
+					Id:         10,
+					Type:       IDENTITY_TYPE_PHONE,
+					Identifier: "+62812345678",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().InsertIdentity(gomock.Any(), gomock.Any()).Return(repository.InsertIdentityOutput{}, errors.New("test"))
+			},
+			want:    AddIdentityOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, identifier exists",
+			args: args{
+				input: AddIdentityInput{
+					Id:         10,
+					Type:       IDENTITY_TYPE_EMAIL,
+					Identifier: "name@example.com",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().InsertIdentity(gomock.Any(), gomock.Any()).Return(repository.InsertIdentityOutput{
+					IsIdentifierExists: true,
+				}, nil)
+			},
+			want: AddIdentityOutput{
+				IsIdentifierExists: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, phone number",
+			args: args{
+				input: AddIdentityInput{
+					Id:         10,
+					Type:       IDENTITY_TYPE_PHONE,
+					Identifier: "+62812345678",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().InsertIdentity(gomock.Any(), gomock.Eq(repository.InsertIdentityInput{
+					UserId:     10,
+					Type:       repository.IDENTITY_TYPE_PHONE,
+					Identifier: "+62812345678",
+				})).Return(repository.InsertIdentityOutput{
+					Id: 3,
+				}, nil)
+			},
+			want: AddIdentityOutput{
+				Identity: Identity{
+					Id:         3,
+					Type:       IDENTITY_TYPE_PHONE,
+					Identifier: "+62812345678",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, email is mailed a verification",
+			args: args{
+				input: AddIdentityInput{
+					Id:           10,
+					Type:         IDENTITY_TYPE_EMAIL,
+					Identifier:   "name@example.com",
+					MailLanguage: "id",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().InsertIdentity(gomock.Any(), gomock.Any()).Return(repository.InsertIdentityOutput{
+					Id: 4,
+				}, nil)
+
+				mockRepository.EXPECT().UpsertEmailVerification(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, input repository.UpsertEmailVerificationInput) error {
+						assert.Equal(t, int64(10), input.UserId)
+						assert.Equal(t, "name@example.com", input.Email)
+						return nil
+					})
+			},
+			want: AddIdentityOutput{
+				Identity: Identity{
+					Id:         4,
+					Type:       IDENTITY_TYPE_EMAIL,
+					Identifier: "name@example.com",
+				},
+			},
+			wantMailTo: "name@example.com",
+			wantErr:    false,
+		},
+		{
+			name: "success, email verification error ignored",
+			args: args{
+				input: AddIdentityInput{
+					Id:         10,
+					Type:       IDENTITY_TYPE_EMAIL,
+					Identifier: "name@example.com",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().InsertIdentity(gomock.Any(), gomock.Any()).Return(repository.InsertIdentityOutput{
+					Id: 4,
+				}, nil)
+
+				mockRepository.EXPECT().UpsertEmailVerification(gomock.Any(), gomock.Any()).Return(errors.New("test"))
+			},
+			want: AddIdentityOutput{
+				Identity: Identity{
+					Id:         4,
+					Type:       IDENTITY_TYPE_EMAIL,
+					Identifier: "name@example.com",
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			mailSender := &recordingMailSender{}
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+				MailSender: mailSender,
+			})
+			got, err := u.AddIdentity(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.AddIdentity() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.AddIdentity() = %v, want %v", got, tt.want)
+			}
+			assert.Equal(t, tt.wantMailTo, mailSender.lastTo())
+		})
+	}
+}
+
+func TestUsecase_RemoveIdentity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	identities := repository.GetIdentitiesByUserIdOutput{
+		Identities: []repository.Identity{
+			{
+				Id:         1,
+				Type:       repository.IDENTITY_TYPE_PHONE,
+				Identifier: "+62812345678",
+				IsPrimary:  true,
+			},
+			{
+				Id:         2,
+				Type:       repository.IDENTITY_TYPE_EMAIL,
+				Identifier: "name@example.com",
+				IsPrimary:  true,
+			},
+			{
+				Id:         3,
+				Type:       repository.IDENTITY_TYPE_PHONE,
+				Identifier: "+62812345679",
+			},
+		},
+	}
+
+	// a user created through an external login, without a password
+	externalIdentities := repository.GetIdentitiesByUserIdOutput{
+		Identities: []repository.Identity{
+			{
+				Id:         4,
+				Type:       "google",
+				Identifier: "subject-1",
+			},
+			{
+				Id:         5,
+				Type:       repository.IDENTITY_TYPE_EMAIL,
+				Identifier: "name@example.com",
+				IsPrimary:  true,
+			},
+		},
+	}
+
+	linkedIdentities := repository.GetIdentitiesByUserIdOutput{
+		Identities: append([]repository.Identity{
+			{
+				Id:         6,
+				Type:       "corp",
+				Identifier: "uid=name",
+			},
+		}, externalIdentities.Identities...),
+	}
+
+	type args struct {
+		input RemoveIdentityInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     RemoveIdentityOutput
+		wantErr  bool
+	}{
+		{
+			name: "error GetIdentitiesByUserId",
+			args: args{
+				input: RemoveIdentityInput{
+					Id:         10,
+					IdentityId: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetIdentitiesByUserIdOutput{}, errors.New("test"))
+			},
+			want:    RemoveIdentityOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, identity of another user not found",
+			args: args{
+				input: RemoveIdentityInput{
+					Id:         10,
+					IdentityId: 99,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Eq(repository.GetIdentitiesByUserIdInput{
+					UserId: a.input.Id,
+				})).Return(identities, nil)
+			},
+			want: RemoveIdentityOutput{
+				IsNotFound: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, primary phone number kept",
+			args: args{
+				input: RemoveIdentityInput{
+					Id:         10,
+					IdentityId: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(identities, nil)
+			},
+			want: RemoveIdentityOutput{
+				IsPrimaryPhoneNumber: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error DeleteIdentity",
+			args: args{
+				input: RemoveIdentityInput{
+					Id:         10,
+					IdentityId: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(identities, nil)
+				mockRepository.EXPECT().DeleteIdentity(gomock.Any(), gomock.Any()).Return(repository.DeleteIdentityOutput{}, errors.New("test"))
+			},
+			want:    RemoveIdentityOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, primary email removed",
+			args: args{
+				input: RemoveIdentityInput{
+					Id:         10,
+					IdentityId: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(identities, nil)
+				mockRepository.EXPECT().DeleteIdentity(gomock.Any(), gomock.Eq(repository.DeleteIdentityInput{
+					Id:     2,
+					UserId: 10,
+				})).Return(repository.DeleteIdentityOutput{
+					IsDeleted: true,
+				}, nil)
+			},
+			want:    RemoveIdentityOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success",
+			args: args{
+				input: RemoveIdentityInput{
+					Id:         10,
+					IdentityId: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(identities, nil)
+				mockRepository.EXPECT().DeleteIdentity(gomock.Any(), gomock.Eq(repository.DeleteIdentityInput{
+					Id:     3,
+					UserId: 10,
+				})).Return(repository.DeleteIdentityOutput{
+					IsDeleted: true,
+				}, nil)
+			},
+			want:    RemoveIdentityOutput{},
+			wantErr: false,
+		},
+		{
+			name: "error GetPasswordById",
+			args: args{
+				input: RemoveIdentityInput{
+					Id:         10,
+					IdentityId: 4,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(externalIdentities, nil)
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, errors.New("test"))
+			},
+			want:    RemoveIdentityOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, last external login of a user without a password kept",
+			args: args{
+				input: RemoveIdentityInput{
+					Id:         10,
+					IdentityId: 4,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(externalIdentities, nil)
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Eq(repository.GetPasswordByIdInput{
+					Id: 10,
+				})).Return(repository.GetPasswordByIdOutput{}, nil)
+			},
+			want: RemoveIdentityOutput{
+				IsLastLogin: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, last external login of a user with a password removed",
+			args: args{
+				input: RemoveIdentityInput{
+					Id:         10,
+					IdentityId: 4,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(externalIdentities, nil)
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{
+					Password: "hash",
+				}, nil)
+				mockRepository.EXPECT().DeleteIdentity(gomock.Any(), gomock.Eq(repository.DeleteIdentityInput{
+					Id:     4,
+					UserId: 10,
+				})).Return(repository.DeleteIdentityOutput{
+					IsDeleted: true,
+				}, nil)
+			},
+			want:    RemoveIdentityOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success, external login removed while another one is left",
+			args: args{
+				input: RemoveIdentityInput{
+					Id:         10,
+					IdentityId: 4,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(linkedIdentities, nil)
+				mockRepository.EXPECT().DeleteIdentity(gomock.Any(), gomock.Eq(repository.DeleteIdentityInput{
+					Id:     4,
+					UserId: 10,
+				})).Return(repository.DeleteIdentityOutput{
+					IsDeleted: true,
+				}, nil)
+			},
+			want:    RemoveIdentityOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success, external login became the last login meanwhile",
+			args: args{
+				input: RemoveIdentityInput{
+					Id:         10,
+					IdentityId: 4,
+				},
+			},
+			mockFunc: func(a args) {
+				gomock.InOrder(
+					mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(linkedIdentities, nil),
+					mockRepository.EXPECT().DeleteIdentity(gomock.Any(), gomock.Any()).Return(repository.DeleteIdentityOutput{}, nil),
+					mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(externalIdentities, nil),
+					mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil),
+				)
+			},
+			want: RemoveIdentityOutput{
+				IsLastLogin: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, identity removed meanwhile not found",
+			args: args{
+				input: RemoveIdentityInput{
+					Id:         10,
+					IdentityId: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				gomock.InOrder(
+					mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(identities, nil),
+					mockRepository.EXPECT().DeleteIdentity(gomock.Any(), gomock.Any()).Return(repository.DeleteIdentityOutput{}, nil),
+					mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetIdentitiesByUserIdOutput{}, nil),
+				)
+			},
+			want: RemoveIdentityOutput{
+				IsNotFound: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, nothing deleted though removable",
+			args: args{
+				input: RemoveIdentityInput{
+					Id:         10,
+					IdentityId: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(identities, nil).Times(2)
+				mockRepository.EXPECT().DeleteIdentity(gomock.Any(), gomock.Any()).Return(repository.DeleteIdentityOutput{}, nil)
+			},
+			want: RemoveIdentityOutput{
+				IsLastLogin: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error GetIdentitiesByUserId after nothing deleted",
+			args: args{
+				input: RemoveIdentityInput{
+					Id:         10,
+					IdentityId: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				gomock.InOrder(
+					mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(identities, nil),
+					mockRepository.EXPECT().DeleteIdentity(gomock.Any(), gomock.Any()).Return(repository.DeleteIdentityOutput{}, nil),
+					mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetIdentitiesByUserIdOutput{}, errors.New("test")),
+				)
+			},
+			want:    RemoveIdentityOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, email of a user without a password removed",
+			args: args{
+				input: RemoveIdentityInput{
+					Id:         10,
+					IdentityId: 5,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(externalIdentities, nil)
+				mockRepository.EXPECT().DeleteIdentity(gomock.Any(), gomock.Eq(repository.DeleteIdentityInput{
+					Id:     5,
+					UserId: 10,
+				})).Return(repository.DeleteIdentityOutput{
+					IsDeleted: true,
+				}, nil)
+			},
+			want:    RemoveIdentityOutput{},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+			})
+			got, err := u.RemoveIdentity(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.RemoveIdentity() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.RemoveIdentity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_SendIdentityVerification(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	verifiedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	identities := repository.GetIdentitiesByUserIdOutput{
+		Identities: []repository.Identity{
+			{
+				Id:         1,
+				Type:       repository.IDENTITY_TYPE_PHONE,
+				Identifier: "+62812345678",
+				IsPrimary:  true,
+			},
+			{
+				Id:         2,
+				Type:       repository.IDENTITY_TYPE_EMAIL,
+				Identifier: "verified@example.com",
+				VerifiedAt: &verifiedAt,
+			},
+			{
+				Id:         3,
+				Type:       repository.IDENTITY_TYPE_EMAIL,
+				Identifier: "name@example.com",
+			},
+		},
+	}
+
+	type args struct {
+		input SendIdentityVerificationInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		want       SendIdentityVerificationOutput
+		wantMailTo string
+		wantErr    bool
+	}{
+		{
+			name: "error GetIdentitiesByUserId",
+			args: args{
+				input: SendIdentityVerificationInput{
+					Id:         10,
+					IdentityId: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetIdentitiesByUserIdOutput{}, errors.New("test"))
+			},
+			want:    SendIdentityVerificationOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, not found",
+			args: args{
+				input: SendIdentityVerificationInput{
+					Id:         10,
+					IdentityId: 99,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(identities, nil)
+			},
+			want: SendIdentityVerificationOutput{
+				IsNotFound: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, phone number not verifiable",
+			args: args{
+				input: SendIdentityVerificationInput{
+					Id:         10,
+					IdentityId: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(identities, nil)
+			},
+			want: SendIdentityVerificationOutput{
+				IsNotVerifiable: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, already verified",
+			args: args{
+				input: SendIdentityVerificationInput{
+					Id:         10,
+					IdentityId: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(identities, nil)
+			},
+			want: SendIdentityVerificationOutput{
+				IsVerified: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error UpsertEmailVerification",
+			args: args{
+				input: SendIdentityVerificationInput{
+					Id:         10,
+					IdentityId: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(identities, nil)
+				mockRepository.EXPECT().UpsertEmailVerification(gomock.Any(), gomock.Any()).Return(errors.New("test"))
+			},
+			want:    SendIdentityVerificationOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			args: args{
+				input: SendIdentityVerificationInput{
+					Id:         10,
+					IdentityId: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(identities, nil)
+				mockRepository.EXPECT().UpsertEmailVerification(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, input repository.UpsertEmailVerificationInput) error {
+						assert.Equal(t, int64(10), input.UserId)
+						assert.Equal(t, "name@example.com", input.Email)
+						return nil
+					})
+			},
+			want:       SendIdentityVerificationOutput{},
+			wantMailTo: "name@example.com",
+			wantErr:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			mailSender := &recordingMailSender{}
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+				MailSender: mailSender,
+			})
+			got, err := u.SendIdentityVerification(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.SendIdentityVerification() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.SendIdentityVerification() = %v, want %v", got, tt.want)
+			}
+			assert.Equal(t, tt.wantMailTo, mailSender.lastTo())
+		})
+	}
+}
+
+// mockIdP is a local OpenID Connect provider. The ID token it hands out for
+// mockIdPCode carries claims, on top of the issuer, audience, times and the
+// nonce of the last authorization.
+type mockIdP struct {
+	server     *httptest.Server
+	key        *rsa.PrivateKey
+	signingKey *rsa.PrivateKey
+	claims     jwt.MapClaims
+	// tokenStatus answers the token request instead of an ID token when set
+	tokenStatus   int
+	codeChallenge string
+	nonce         string
+}
+
+const (
+	mockIdPCode         = "code-1"
+	mockIdPClientId     = "client-1"
+	mockIdPClientSecret = "secret-1"
+)
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when generating the key of the mock IdP", err)
+	}
+
+	idp := &mockIdP{
+		key:        key,
+		signingKey: key,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{
+				{
+					"kty": "RSA",
+					"kid": "key-1",
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+				},
+			},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		var (
+			clientId, clientSecret, _ = r.BasicAuth()
+			challenge                 = sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		)
+
+		if idp.tokenStatus != 0 {
+			w.WriteHeader(idp.tokenStatus)
+			return
+		}
+
+		if r.PostForm.Get("code") != mockIdPCode || clientId != mockIdPClientId || clientSecret != mockIdPClientSecret ||
+			base64.RawURLEncoding.EncodeToString(challenge[:]) != idp.codeChallenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":   idp.server.URL,
+			"aud":   mockIdPClientId,
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(5 * time.Minute).Unix(),
+			"nonce": idp.nonce,
+		}
+		for name, value := range idp.claims {
+			claims[name] = value
+		}
+
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		idToken.Header["kid"] = "key-1"
+		signed, _ := idToken.SignedString(idp.signingKey)
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access-1",
+			"token_type":   "Bearer",
+			"id_token":     signed,
+		})
+	})
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *mockIdP) provider(name string) *utils.OIDCProvider {
+	return &utils.OIDCProvider{
+		Name:         name,
+		Issuer:       idp.server.URL,
+		ClientId:     mockIdPClientId,
+		ClientSecret: mockIdPClientSecret,
+		RedirectURL:  "http://localhost:1323/oidc/" + name + "/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+	}
+}
+
+// authorize plays the user logging in at the provider, it returns the state
+// to redirect back with.
+func (idp *mockIdP) authorize(t *testing.T, authorizationURL string) string {
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when parsing the authorization URL", err)
+	}
+
+	query := parsed.Query()
+	idp.codeChallenge = query.Get("code_challenge")
+	idp.nonce = query.Get("nonce")
+
+	return query.Get("state")
+}
+
+func TestUsecase_StartOIDCLogin(t *testing.T) {
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	idp := newMockIdP(t)
+
+	wrongIssuer := idp.provider("wrong")
+	wrongIssuer.Issuer = idp.server.URL + "/"
+
+	u := NewUsecase(NewUsecaseOptions{
+		OIDCProviders: map[string]*utils.OIDCProvider{
+			"acme":  idp.provider("acme"),
+			"wrong": wrongIssuer,
+		},
+	})
+
+	t.Run("success, provider unknown", func(t *testing.T) {
+		got, err := u.StartOIDCLogin(context.Background(), StartOIDCLoginInput{
+			Provider: "unknown",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, StartOIDCLoginOutput{IsProviderUnknown: true}, got)
+	})
+
+	t.Run("error, discovered issuer differs", func(t *testing.T) {
+		_, err := u.StartOIDCLogin(context.Background(), StartOIDCLoginInput{
+			Provider: "wrong",
+		})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		got, err := u.StartOIDCLogin(context.Background(), StartOIDCLoginInput{
+			Provider: "acme",
+		})
+		assert.NoError(t, err)
+
+		parsed, _ := url.Parse(got.AuthorizationURL)
+		query := parsed.Query()
+		assert.Equal(t, idp.server.URL+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+		assert.Equal(t, "code", query.Get("response_type"))
+		assert.Equal(t, mockIdPClientId, query.Get("client_id"))
+		assert.Equal(t, "http://localhost:1323/oidc/acme/callback", query.Get("redirect_uri"))
+		assert.Equal(t, "openid email profile", query.Get("scope"))
+		assert.Equal(t, "S256", query.Get("code_challenge_method"))
+
+		flow, err := utils.ParseOIDCFlowToken(got.FlowToken)
+		assert.NoError(t, err)
+		assert.Equal(t, "acme", flow.Provider)
+		assert.Equal(t, flow.State, query.Get("state"))
+		assert.Equal(t, flow.Nonce, query.Get("nonce"))
+
+		challenge := sha256.Sum256([]byte(flow.CodeVerifier))
+		assert.Equal(t, base64.RawURLEncoding.EncodeToString(challenge[:]), query.Get("code_challenge"))
+
+		_, err = utils.TokenParse(got.FlowToken)
+		assert.Error(t, err, "the flow token must not be accepted as an access token")
+	})
+}
+
+func TestUsecase_FinishOIDCLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	idp := newMockIdP(t)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	// acme is trusted with the addresses of example.com, other with none
+	acme := idp.provider("acme")
+	acme.EmailDomains = []string{"example.com"}
+
+	u := NewUsecase(NewUsecaseOptions{
+		Repository: mockRepository,
+		OIDCProviders: map[string]*utils.OIDCProvider{
+			"acme":  acme,
+			"other": idp.provider("other"),
+		},
+	})
+
+	janeClaims := jwt.MapClaims{
+		"sub":            "248289761001",
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"name":           "Jane Doe",
+	}
+
+	purgeAt := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name string
+		// provider is where the login starts and finishes, acme when empty
+		provider string
+		claims   jwt.MapClaims
+		idpFunc  func(*mockIdP)
+		modify   func(*FinishOIDCLoginInput)
+		mockFunc func()
+		want     FinishOIDCLoginOutput
+		wantId   int64
+		// wantRestorationId is the user of the restricted token returned
+		// for an account that can be restored
+		wantRestorationId int64
+		wantErr           bool
+	}{
+		{
+			name:   "success, provider unknown",
+			claims: janeClaims,
+			modify: func(input *FinishOIDCLoginInput) {
+				input.Provider = "unknown"
+			},
+			mockFunc: func() {},
+			want: FinishOIDCLoginOutput{
+				IsProviderUnknown: true,
+			},
+		},
+		{
+			name:   "success, flow token invalid",
+			claims: janeClaims,
+			modify: func(input *FinishOIDCLoginInput) {
+				input.FlowToken = "abcd"
+			},
+			mockFunc: func() {},
+			want: FinishOIDCLoginOutput{
+				IsInvalid: true,
+			},
+		},
+		{
+			name:   "success, state differs",
+			claims: janeClaims,
+			modify: func(input *FinishOIDCLoginInput) {
+				input.State = "forged"
+			},
+			mockFunc: func() {},
+			want: FinishOIDCLoginOutput{
+				IsInvalid: true,
+			},
+		},
+		{
+			name:   "success, login started at another provider",
+			claims: janeClaims,
+			modify: func(input *FinishOIDCLoginInput) {
+				input.Provider = "other"
+			},
+			mockFunc: func() {},
+			want: FinishOIDCLoginOutput{
+				IsInvalid: true,
+			},
+		},
+		{
+			name:   "success, code refused",
+			claims: janeClaims,
+			modify: func(input *FinishOIDCLoginInput) {
+				input.Code = "code-2"
+			},
+			mockFunc: func() {},
+			want: FinishOIDCLoginOutput{
+				IsInvalid: true,
+			},
+		},
+		{
+			name:   "error, token endpoint fails",
+			claims: janeClaims,
+			idpFunc: func(idp *mockIdP) {
+				idp.tokenStatus = http.StatusServiceUnavailable
+			},
+			mockFunc: func() {},
+			want:     FinishOIDCLoginOutput{},
+			wantErr:  true,
+		},
+		{
+			name:   "success, ID token signed with another key",
+			claims: janeClaims,
+			idpFunc: func(idp *mockIdP) {
+				idp.signingKey = otherKey
+			},
+			mockFunc: func() {},
+			want: FinishOIDCLoginOutput{
+				IsInvalid: true,
+			},
+		},
+		{
+			name: "success, ID token for another client",
+			claims: jwt.MapClaims{
+				"sub": "248289761001",
+				"aud": "client-2",
+			},
+			mockFunc: func() {},
+			want: FinishOIDCLoginOutput{
+				IsInvalid: true,
+			},
+		},
+		{
+			name: "success, ID token expired",
+			claims: jwt.MapClaims{
+				"sub": "248289761001",
+				"exp": time.Now().Add(-time.Hour).Unix(),
+			},
+			mockFunc: func() {},
+			want: FinishOIDCLoginOutput{
+				IsInvalid: true,
+			},
+		},
+		{
+			name: "success, ID token replayed with another nonce",
+			claims: jwt.MapClaims{
+				"sub":   "248289761001",
+				"nonce": "replayed",
+			},
+			mockFunc: func() {},
+			want: FinishOIDCLoginOutput{
+				IsInvalid: true,
+			},
+		},
+		{
+			name:   "error when GetUserIdByIdentity",
+			claims: janeClaims,
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetUserIdByIdentityOutput{}, errors.New("test"))
+			},
+			want:    FinishOIDCLoginOutput{},
+			wantErr: true,
+		},
+		{
+			name:   "error when InsertLoginEvent",
+			claims: janeClaims,
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetUserIdByIdentityOutput{
+					UserId:     50,
+					IsVerified: true,
+				}, nil)
+
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Any()).Return(errors.New("test"))
+			},
+			wantErr: true,
+		},
+		{
+			name:   "success, subject already linked",
+			claims: janeClaims,
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Eq(repository.GetUserIdByIdentityInput{
+					Type:       "acme",
+					Identifier: "248289761001",
+				})).Return(repository.GetUserIdByIdentityOutput{
+					UserId:     50,
+					IsVerified: true,
+				}, nil)
+
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Eq(repository.GetRolesByUserIdInput{
+					UserId: 50,
+				})).Return(repository.GetRolesByUserIdOutput{}, nil)
+
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 50,
+					Method: "acme",
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Eq(repository.UpdateTotalLoginByIdInput{
+					Id: 50,
+				})).Return(nil).AnyTimes()
+			},
+			want:   FinishOIDCLoginOutput{},
+			wantId: 50,
+		},
+		{
+			name:   "success, account deleted by the user during the grace period can be restored",
+			claims: janeClaims,
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Eq(repository.GetUserIdByIdentityInput{
+					Type:       "acme",
+					Identifier: "248289761001",
+				})).Return(repository.GetUserIdByIdentityOutput{
+					UserId:     50,
+					IsVerified: true,
+				}, nil)
+
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{
+					Status:  USER_STATUS_DELETED,
+					PurgeAt: &purgeAt,
+				}, nil)
+			},
+			want: FinishOIDCLoginOutput{
+				IsAccountDeleted: true,
+			},
+			wantRestorationId: 50,
+		},
+		{
+			name:   "success, linked to the user with the verified email",
+			claims: janeClaims,
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Eq(repository.GetUserIdByIdentityInput{
+					Type:       "acme",
+					Identifier: "248289761001",
+				})).Return(repository.GetUserIdByIdentityOutput{}, sql.ErrNoRows)
+
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Eq(repository.GetUserIdByIdentityInput{
+					Type:       repository.IDENTITY_TYPE_EMAIL,
+					Identifier: "jane@example.com",
+				})).Return(repository.GetUserIdByIdentityOutput{
+					UserId:     50,
+					IsVerified: true,
+				}, nil)
+
+				mockRepository.EXPECT().InsertIdentity(gomock.Any(), gomock.Eq(repository.InsertIdentityInput{
+					UserId:     50,
+					Type:       "acme",
+					Identifier: "248289761001",
+					IsVerified: true,
+				})).Return(repository.InsertIdentityOutput{
+					Id: 9,
+				}, nil)
+
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 50,
+					Method: "acme",
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			},
+			want:   FinishOIDCLoginOutput{},
+			wantId: 50,
+		},
+		{
+			name:   "error, subject linked concurrently",
+			claims: janeClaims,
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetUserIdByIdentityOutput{}, sql.ErrNoRows)
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetUserIdByIdentityOutput{
+					UserId:     50,
+					IsVerified: true,
+				}, nil)
+				mockRepository.EXPECT().InsertIdentity(gomock.Any(), gomock.Any()).Return(repository.InsertIdentityOutput{
+					IsIdentifierExists: true,
+				}, nil)
+			},
+			want:    FinishOIDCLoginOutput{},
+			wantErr: true,
+		},
+		{
+			name:   "success, unverified email of another user is not linked",
+			claims: janeClaims,
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetUserIdByIdentityOutput{}, sql.ErrNoRows)
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetUserIdByIdentityOutput{
+					UserId: 50,
+				}, nil)
+
+				mockRepository.EXPECT().InsertExternalUser(gomock.Any(), gomock.Eq(repository.InsertExternalUserInput{
+					FullName:   "Jane Doe",
+					Type:       "acme",
+					Identifier: "248289761001",
+					Email:      "jane@example.com",
+				})).Return(repository.InsertExternalUserOutput{
+					Id: 51,
+				}, nil)
+
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 51,
+					Method: "acme",
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			},
+			want:   FinishOIDCLoginOutput{},
+			wantId: 51,
+		},
+		{
+			name:     "success, provider not trusted with the email domain creates a new user",
+			provider: "other",
+			claims:   janeClaims,
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Eq(repository.GetUserIdByIdentityInput{
+					Type:       "other",
+					Identifier: "248289761001",
+				})).Return(repository.GetUserIdByIdentityOutput{}, sql.ErrNoRows)
+
+				mockRepository.EXPECT().InsertExternalUser(gomock.Any(), gomock.Eq(repository.InsertExternalUserInput{
+					FullName:   "Jane Doe",
+					Type:       "other",
+					Identifier: "248289761001",
+				})).Return(repository.InsertExternalUserOutput{
+					Id: 53,
+				}, nil)
+
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 53,
+					Method: "other",
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			},
+			want:   FinishOIDCLoginOutput{},
+			wantId: 53,
+		},
+		{
+			name: "success, verified email of another domain creates a new user",
+			claims: jwt.MapClaims{
+				"sub":            "248289761004",
+				"email":          "jane@elsewhere.test",
+				"email_verified": true,
+				"name":           "Jane Doe",
+			},
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetUserIdByIdentityOutput{}, sql.ErrNoRows)
+
+				mockRepository.EXPECT().InsertExternalUser(gomock.Any(), gomock.Eq(repository.InsertExternalUserInput{
+					FullName:   "Jane Doe",
+					Type:       "acme",
+					Identifier: "248289761004",
+				})).Return(repository.InsertExternalUserOutput{
+					Id: 54,
+				}, nil)
+
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 54,
+					Method: "acme",
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			},
+			want:   FinishOIDCLoginOutput{},
+			wantId: 54,
+		},
+		{
+			name: "success, new user without verified email or name",
+			claims: jwt.MapClaims{
+				"sub":            "248289761002",
+				"email":          "jane@example.com",
+				"email_verified": false,
+			},
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetUserIdByIdentityOutput{}, sql.ErrNoRows)
+
+				mockRepository.EXPECT().InsertExternalUser(gomock.Any(), gomock.Eq(repository.InsertExternalUserInput{
+					FullName:   "jane",
+					Type:       "acme",
+					Identifier: "248289761002",
+				})).Return(repository.InsertExternalUserOutput{
+					Id: 52,
+				}, nil)
+
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().InsertLoginEvent(gomock.Any(), gomock.Eq(repository.InsertLoginEventInput{
+					UserId: 52,
+					Method: "acme",
+				})).Return(nil)
+				mockRepository.EXPECT().UpdateTotalLoginById(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			},
+			want:   FinishOIDCLoginOutput{},
+			wantId: 52,
+		},
+		{
+			name: "error when InsertExternalUser",
+			claims: jwt.MapClaims{
+				"sub": "248289761003",
+			},
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserIdByIdentity(gomock.Any(), gomock.Any()).Return(repository.GetUserIdByIdentityOutput{}, sql.ErrNoRows)
+				mockRepository.EXPECT().InsertExternalUser(gomock.Any(), gomock.Eq(repository.InsertExternalUserInput{
+					FullName:   externalDefaultFullName,
+					Type:       "acme",
+					Identifier: "248289761003",
+				})).Return(repository.InsertExternalUserOutput{}, errors.New("test"))
+			},
+			want:    FinishOIDCLoginOutput{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp.claims = tt.claims
+			idp.signingKey = idp.key
+			idp.tokenStatus = 0
+			if tt.idpFunc != nil {
+				tt.idpFunc(idp)
+			}
+			tt.mockFunc()
+
+			provider := tt.provider
+			if provider == "" {
+				provider = "acme"
+			}
+
+			start, err := u.StartOIDCLogin(context.Background(), StartOIDCLoginInput{
+				Provider: provider,
+			})
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when starting the login", err)
+			}
+
+			input := FinishOIDCLoginInput{
+				Provider:  provider,
+				Code:      mockIdPCode,
+				State:     idp.authorize(t, start.AuthorizationURL),
+				FlowToken: start.FlowToken,
+			}
+			if tt.modify != nil {
+				tt.modify(&input)
+			}
+
+			got, err := u.FinishOIDCLogin(context.Background(), input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.FinishOIDCLogin() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			token := got.Token
+			got.Token = ""
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.FinishOIDCLogin() = %v, want %v", got, tt.want)
+			}
+
+			if tt.wantId != 0 {
+				id, err := utils.TokenParse(token)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantId, id)
+			} else if tt.wantRestorationId != 0 {
+				id, err := utils.TokenParseScoped(token, utils.TOKEN_SCOPE_ACCOUNT_RESTORATION)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantRestorationId, id)
+			} else {
+				assert.Empty(t, token)
+			}
+		})
+	}
+}
+
+func TestUsecase_HasPermission(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	type args struct {
+		input HasPermissionInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     HasPermissionOutput
+		wantErr  bool
+	}{
+		{
+			name: "success, no roles is not allowed",
+			args: args{
+				input: HasPermissionInput{
+					Permission: "users:read",
+				},
+			},
+			mockFunc: func(a args) {},
+			want:     HasPermissionOutput{},
+			wantErr:  false,
+		},
+		{
+			name: "error when HasPermission",
+			args: args{
+				input: HasPermissionInput{
+					Roles:      []string{"support"},
+					Permission: "users:read",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().HasPermission(gomock.Any(), gomock.Any()).Return(repository.HasPermissionOutput{}, errors.New("test"))
+			},
+			want:    HasPermissionOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, not allowed",
+			args: args{
+				input: HasPermissionInput{
+					Roles:      []string{"support"},
+					Permission: "users:write",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().HasPermission(gomock.Any(), gomock.Eq(repository.HasPermissionInput{
+					Roles:      []string{"support"},
+					Permission: "users:write",
+				})).Return(repository.HasPermissionOutput{}, nil)
+			},
+			want:    HasPermissionOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success, allowed",
+			args: args{
+				input: HasPermissionInput{
+					Roles:      []string{"admin"},
+					Permission: "users:write",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().HasPermission(gomock.Any(), gomock.Eq(repository.HasPermissionInput{
+					Roles:      []string{"admin"},
+					Permission: "users:write",
+				})).Return(repository.HasPermissionOutput{
+					IsAllowed: true,
+				}, nil)
+			},
+			want: HasPermissionOutput{
+				IsAllowed: true,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+			})
+			got, err := u.HasPermission(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.HasPermission() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.HasPermission() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_SearchUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	var (
+		createdFrom = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		createdAt   = time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.UTC)
+		jane        = repository.UserSummary{
+			Id:          1,
+			FullName:    "Jane Doe",
+			PhoneNumber: "+6281234567890",
+			Status:      USER_STATUS_ACTIVE,
+			CreatedAt:   createdAt,
+		}
+		john = repository.UserSummary{
+			Id:          2,
+			FullName:    "John Doe",
+			PhoneNumber: "+6281234567891",
+			Email:       "john@example.com",
+			Status:      USER_STATUS_ACTIVE,
+			CreatedAt:   createdAt,
+		}
+		cursor = func(c userCursor) string {
+			encoded, _ := json.Marshal(c)
+			return base64.RawURLEncoding.EncodeToString(encoded)
+		}
+	)
+
+	type args struct {
+		input SearchUsersInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     SearchUsersOutput
+		wantErr  bool
+	}{
+		{
+			name: "success, cursor not encoded",
+			args: args{
+				input: SearchUsersInput{
+					Sort:   USER_SORT_CREATED_AT_DESC,
+					Limit:  1,
+					Cursor: "not a cursor",
+				},
+			},
+			mockFunc: func(a args) {},
+			want: SearchUsersOutput{
+				IsCursorInvalid: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, cursor of another sort",
+			args: args{
+				input: SearchUsersInput{
+					Sort:   USER_SORT_CREATED_AT_DESC,
+					Limit:  1,
+					Cursor: cursor(userCursor{Sort: USER_SORT_FULL_NAME, Id: 1, Value: "Jane Doe"}),
+				},
+			},
+			mockFunc: func(a args) {},
+			want: SearchUsersOutput{
+				IsCursorInvalid: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, cursor with a value of another type",
+			args: args{
+				input: SearchUsersInput{
+					Sort:   USER_SORT_CREATED_AT,
+					Limit:  1,
+					Cursor: cursor(userCursor{Sort: USER_SORT_CREATED_AT, Id: 1, Value: "Jane Doe"}),
+				},
+			},
+			mockFunc: func(a args) {},
+			want: SearchUsersOutput{
+				IsCursorInvalid: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error when SearchUsers",
+			args: args{
+				input: SearchUsersInput{
+					Sort:  USER_SORT_CREATED_AT_DESC,
+					Limit: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().SearchUsers(gomock.Any(), gomock.Any()).Return(repository.SearchUsersOutput{}, errors.New("test"))
+			},
+			want:    SearchUsersOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, no users",
+			args: args{
+				input: SearchUsersInput{
+					Name:  "nobody",
+					Sort:  USER_SORT_CREATED_AT_DESC,
+					Limit: 20,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().SearchUsers(gomock.Any(), gomock.Eq(repository.SearchUsersInput{
+					Name:  "nobody",
+					Sort:  USER_SORT_CREATED_AT_DESC,
+					Limit: 21,
+				})).Return(repository.SearchUsersOutput{}, nil)
+			},
+			want: SearchUsersOutput{
+				Users: []UserSummary{},
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, page with a next page",
+			args: args{
+				input: SearchUsersInput{
+					PhonePrefix: "+62812",
+					CreatedFrom: &createdFrom,
+					Status:      USER_STATUS_ACTIVE,
+					Sort:        USER_SORT_CREATED_AT,
+					Limit:       1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().SearchUsers(gomock.Any(), gomock.Eq(repository.SearchUsersInput{
+					PhonePrefix: "+62812",
+					CreatedFrom: &createdFrom,
+					Status:      USER_STATUS_ACTIVE,
+					Sort:        USER_SORT_CREATED_AT,
+					Limit:       2,
+				})).Return(repository.SearchUsersOutput{
+					Users: []repository.UserSummary{jane, john},
+				}, nil)
+			},
+			want: SearchUsersOutput{
+				Users:      []UserSummary{UserSummary(jane)},
+				NextCursor: cursor(userCursor{Sort: USER_SORT_CREATED_AT, Id: 1, Value: "2024-01-02T03:04:05.6Z"}),
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, last page after a cursor",
+			args: args{
+				input: SearchUsersInput{
+					Sort:   USER_SORT_FULL_NAME,
+					Limit:  1,
+					Cursor: cursor(userCursor{Sort: USER_SORT_FULL_NAME, Id: 1, Value: "Jane Doe"}),
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().SearchUsers(gomock.Any(), gomock.Eq(repository.SearchUsersInput{
+					Sort:       USER_SORT_FULL_NAME,
+					Limit:      2,
+					AfterId:    1,
+					AfterValue: "Jane Doe",
+				})).Return(repository.SearchUsersOutput{
+					Users: []repository.UserSummary{john},
+				}, nil)
+			},
+			want: SearchUsersOutput{
+				Users: []UserSummary{UserSummary(john)},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+			})
+			got, err := u.SearchUsers(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.SearchUsers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.SearchUsers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_SearchUsers_nextCursorContinuesListing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+
+	u := NewUsecase(NewUsecaseOptions{
+		Repository: mockRepository,
+	})
+
+	mockRepository.EXPECT().SearchUsers(gomock.Any(), gomock.Any()).Return(repository.SearchUsersOutput{
+		Users: []repository.UserSummary{
+			{Id: 2, FullName: "John Doe", CreatedAt: createdAt},
+			{Id: 1, FullName: "Jane Doe", CreatedAt: createdAt},
+		},
+	}, nil)
+
+	first, err := u.SearchUsers(context.Background(), SearchUsersInput{
+		Sort:  USER_SORT_CREATED_AT_DESC,
+		Limit: 1,
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, first.NextCursor)
+
+	mockRepository.EXPECT().SearchUsers(gomock.Any(), gomock.Eq(repository.SearchUsersInput{
+		Sort:       USER_SORT_CREATED_AT_DESC,
+		Limit:      2,
+		AfterId:    2,
+		AfterValue: createdAt.Format(time.RFC3339Nano),
+	})).Return(repository.SearchUsersOutput{}, nil)
+
+	second, err := u.SearchUsers(context.Background(), SearchUsersInput{
+		Sort:   USER_SORT_CREATED_AT_DESC,
+		Limit:  1,
+		Cursor: first.NextCursor,
+	})
+	assert.NoError(t, err)
+	assert.False(t, second.IsCursorInvalid)
+
+	// a cursor is tied to its sort
+	third, err := u.SearchUsers(context.Background(), SearchUsersInput{
+		Sort:   USER_SORT_CREATED_AT,
+		Limit:  1,
+		Cursor: first.NextCursor,
+	})
+	assert.NoError(t, err)
+	assert.True(t, third.IsCursorInvalid)
+}
+
+func TestUsecase_GetUserDetails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	var (
+		createdAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		updatedAt = time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+		updatedBy = int64(1)
+	)
+
+	type args struct {
+		input GetUserDetailsInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     GetUserDetailsOutput
+		wantErr  bool
+	}{
+		{
+			name: "success, user not found",
+			args: args{
+				input: GetUserDetailsInput{
+					Id: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDetailsById(gomock.Any(), gomock.Any()).Return(repository.GetUserDetailsByIdOutput{}, errors.WithStack(sql.ErrNoRows))
+			},
+			want: GetUserDetailsOutput{
+				IsNotFound: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error when GetUserDetailsById",
+			args: args{
+				input: GetUserDetailsInput{
+					Id: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDetailsById(gomock.Any(), gomock.Any()).Return(repository.GetUserDetailsByIdOutput{}, errors.New("test"))
+			},
+			want:    GetUserDetailsOutput{},
+			wantErr: true,
+		},
+		{
+			name: "error when GetRolesByUserId",
+			args: args{
+				input: GetUserDetailsInput{
+					Id: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDetailsById(gomock.Any(), gomock.Any()).Return(repository.GetUserDetailsByIdOutput{Id: 1}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, errors.New("test"))
+			},
+			want:    GetUserDetailsOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			args: args{
+				input: GetUserDetailsInput{
+					Id: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserDetailsById(gomock.Any(), gomock.Eq(repository.GetUserDetailsByIdInput{
+					Id: 1,
+				})).Return(repository.GetUserDetailsByIdOutput{
+					Id:            1,
+					FullName:      "Jane Doe",
+					PhoneNumber:   "+6281234567890",
+					Email:         "jane@example.com",
+					EmailVerified: true,
+					Language:      "id",
+					Status:        USER_STATUS_ACTIVE,
+					UserGroup:     "staff",
+					TotalLogin:    12,
+					CreatedAt:     createdAt,
+					UpdatedAt:     &updatedAt,
+					UpdatedBy:     &updatedBy,
+					AvatarKey:     "avatars/1/abc",
+				}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Eq(repository.GetRolesByUserIdInput{
+					UserId: 1,
+				})).Return(repository.GetRolesByUserIdOutput{
+					Roles: []string{"support"},
+				}, nil)
+			},
+			want: GetUserDetailsOutput{
+				User: UserDetails{
+					Id:            1,
+					FullName:      "Jane Doe",
+					PhoneNumber:   "+6281234567890",
+					Email:         "jane@example.com",
+					EmailVerified: true,
+					Language:      "id",
+					Status:        USER_STATUS_ACTIVE,
+					UserGroup:     "staff",
+					Roles:         []string{"support"},
+					TotalLogin:    12,
+					CreatedAt:     createdAt,
+					UpdatedAt:     &updatedAt,
+					UpdatedBy:     &updatedBy,
+					Avatar: &AvatarURLs{
+						Small:  "http://localhost/storage/avatars/1/abc/small.jpg",
+						Medium: "http://localhost/storage/avatars/1/abc/medium.jpg",
+						Large:  "http://localhost/storage/avatars/1/abc/large.jpg",
+					},
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+				ObjectStorage: &utils.LocalObjectStorage{
+					Dir:     t.TempDir(),
+					BaseURL: "http://localhost/storage/",
+				},
+			})
+			got, err := u.GetUserDetails(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.GetUserDetails() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.GetUserDetails() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_GetUserStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	type args struct {
+		input GetUserStatusInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     GetUserStatusOutput
+		wantErr  bool
+	}{
+		{
+			name: "success, user not found",
+			args: args{
+				input: GetUserStatusInput{
+					Id: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{}, errors.WithStack(sql.ErrNoRows))
+			},
+			want: GetUserStatusOutput{
+				IsNotFound: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error when GetUserStatusById",
+			args: args{
+				input: GetUserStatusInput{
+					Id: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{}, errors.New("test"))
+			},
+			want:    GetUserStatusOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			args: args{
+				input: GetUserStatusInput{
+					Id: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Eq(repository.GetUserStatusByIdInput{
+					Id: 1,
+				})).Return(repository.GetUserStatusByIdOutput{
+					Status: USER_STATUS_SUSPENDED,
+				}, nil)
+			},
+			want: GetUserStatusOutput{
+				Status: USER_STATUS_SUSPENDED,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+			})
+			got, err := u.GetUserStatus(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.GetUserStatus() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.GetUserStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_ChangeUserStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	type args struct {
+		input ChangeUserStatusInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     ChangeUserStatusOutput
+		wantErr  bool
+	}{
+		{
+			name: "success, user not found",
+			args: args{
+				input: ChangeUserStatusInput{
+					Id:     2,
+					Status: USER_STATUS_SUSPENDED,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{}, errors.WithStack(sql.ErrNoRows))
+			},
+			want: ChangeUserStatusOutput{
+				IsNotFound: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error when GetUserStatusById",
+			args: args{
+				input: ChangeUserStatusInput{
+					Id:     2,
+					Status: USER_STATUS_SUSPENDED,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{}, errors.New("test"))
+			},
+			want:    ChangeUserStatusOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, deleted is final",
+			args: args{
+				input: ChangeUserStatusInput{
+					Id:     2,
+					Status: USER_STATUS_ACTIVE,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_DELETED}, nil)
+			},
+			want: ChangeUserStatusOutput{
+				IsTransitionNotAllowed: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, suspended user cannot be deactivated",
+			args: args{
+				input: ChangeUserStatusInput{
+					Id:     2,
+					Status: USER_STATUS_DEACTIVATED,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_SUSPENDED}, nil)
+			},
+			want: ChangeUserStatusOutput{
+				IsTransitionNotAllowed: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error when UpdateUserStatus",
+			args: args{
+				input: ChangeUserStatusInput{
+					Id:     2,
+					Status: USER_STATUS_SUSPENDED,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().UpdateUserStatus(gomock.Any(), gomock.Any()).Return(repository.UpdateUserStatusOutput{}, errors.New("test"))
+			},
+			want:    ChangeUserStatusOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, status changed meanwhile",
+			args: args{
+				input: ChangeUserStatusInput{
+					Id:     2,
+					Status: USER_STATUS_SUSPENDED,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().UpdateUserStatus(gomock.Any(), gomock.Any()).Return(repository.UpdateUserStatusOutput{}, nil)
+			},
+			want: ChangeUserStatusOutput{
+				IsTransitionNotAllowed: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success",
+			args: args{
+				input: ChangeUserStatusInput{
+					Id:        2,
+					Status:    USER_STATUS_SUSPENDED,
+					Reason:    "chargeback",
+					ChangedBy: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Eq(repository.GetUserStatusByIdInput{
+					Id: 2,
+				})).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().UpdateUserStatus(gomock.Any(), gomock.Eq(repository.UpdateUserStatusInput{
+					Id:         2,
+					FromStatus: USER_STATUS_ACTIVE,
+					Status:     USER_STATUS_SUSPENDED,
+					Reason:     "chargeback",
+					ChangedBy:  1,
+				})).Return(repository.UpdateUserStatusOutput{IsUpdated: true}, nil)
+			},
+			want:    ChangeUserStatusOutput{},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+			})
+			got, err := u.ChangeUserStatus(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.ChangeUserStatus() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.ChangeUserStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_DeactivateUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	var (
+		hasher         = utils.NewArgon2idHasher(1024, 1, 1)
+		currentHash, _ = hasher.Hash("Current1!")
+		verifiedAt     = time.Now()
+
+		// the email is served by the directory
+		directoryIdentities = repository.GetIdentitiesByUserIdOutput{
+			Identities: []repository.Identity{
+				{Id: 1, Type: "corp", Identifier: "6c1d8a52-0b7e-4c39-9a0e-1d2f3a4b5c6d"},
+				{Id: 2, Type: IDENTITY_TYPE_EMAIL, Identifier: "jane@corp.example.com", IsPrimary: true, VerifiedAt: &verifiedAt},
+			},
+		}
+		// an unverified email does not make the user a directory user
+		providerIdentities = repository.GetIdentitiesByUserIdOutput{
+			Identities: []repository.Identity{
+				{Id: 1, Type: "acme", Identifier: "subject-1"},
+				{Id: 2, Type: IDENTITY_TYPE_EMAIL, Identifier: "jane@corp.example.com", IsPrimary: true},
+			},
+		}
+	)
+
+	type args struct {
+		input DeactivateUserInput
+	}
+	tests := []struct {
+		name          string
+		args          args
+		authenticator *stubAuthenticator
+		mockFunc      func(args)
+		want          DeactivateUserOutput
+		wantErr       bool
+	}{
+		{
+			name: "error when GetPasswordById",
+			args: args{
+				input: DeactivateUserInput{
+					Id:       3,
+					Password: "Current1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, errors.New("test"))
+			},
+			want:    DeactivateUserOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, password wrong",
+			args: args{
+				input: DeactivateUserInput{
+					Id:       3,
+					Password: "Wrong1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{Password: currentHash}, nil)
+			},
+			want: DeactivateUserOutput{
+				IsPasswordWrong: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error when UpdateUserStatus",
+			args: args{
+				input: DeactivateUserInput{
+					Id:       3,
+					Password: "Current1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{Password: currentHash}, nil)
+				mockRepository.EXPECT().UpdateUserStatus(gomock.Any(), gomock.Any()).Return(repository.UpdateUserStatusOutput{}, errors.New("test"))
+			},
+			want:    DeactivateUserOutput{},
+			wantErr: true,
+		},
+		{
+			name: "error status changed concurrently",
+			args: args{
+				input: DeactivateUserInput{
+					Id:       3,
+					Password: "Current1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{Password: currentHash}, nil)
+				mockRepository.EXPECT().UpdateUserStatus(gomock.Any(), gomock.Any()).Return(repository.UpdateUserStatusOutput{}, nil)
+			},
+			want:    DeactivateUserOutput{},
+			wantErr: true,
+		},
+		{
+			name: "error when GetIdentitiesByUserId",
+			args: args{
+				input: DeactivateUserInput{
+					Id: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetIdentitiesByUserIdOutput{}, errors.New("test"))
+			},
+			want:    DeactivateUserOutput{},
+			wantErr: true,
+		},
+		{
+			name: "error when Authenticate",
+			args: args{
+				input: DeactivateUserInput{
+					Id:       3,
+					Password: "Directory1!",
+				},
+			},
+			authenticator: &stubAuthenticator{domain: "corp.example.com", err: errors.New("test")},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(directoryIdentities, nil)
+			},
+			want:    DeactivateUserOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, directory password wrong",
+			args: args{
+				input: DeactivateUserInput{
+					Id:       3,
+					Password: "Wrong1!",
+				},
+			},
+			authenticator: &stubAuthenticator{domain: "corp.example.com", output: AuthenticateOutput{IsInvalidCredentials: true}},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(directoryIdentities, nil)
+			},
+			want: DeactivateUserOutput{
+				IsPasswordWrong: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, empty password of a directory user is wrong",
+			args: args{
+				input: DeactivateUserInput{
+					Id:              3,
+					AuthenticatedAt: time.Now(),
+				},
+			},
+			authenticator: &stubAuthenticator{domain: "corp.example.com", output: AuthenticateOutput{Id: 3}},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(directoryIdentities, nil)
+			},
+			want: DeactivateUserOutput{
+				IsPasswordWrong: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, directory password of someone else is wrong",
+			args: args{
+				input: DeactivateUserInput{
+					Id:       3,
+					Password: "Directory1!",
+				},
+			},
+			authenticator: &stubAuthenticator{domain: "corp.example.com", output: AuthenticateOutput{Id: 4}},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(directoryIdentities, nil)
+			},
+			want: DeactivateUserOutput{
+				IsPasswordWrong: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, directory password",
+			args: args{
+				input: DeactivateUserInput{
+					Id:       3,
+					Password: "Directory1!",
+				},
+			},
+			authenticator: &stubAuthenticator{domain: "corp.example.com", output: AuthenticateOutput{Id: 3}},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(directoryIdentities, nil)
+				mockRepository.EXPECT().UpdateUserStatus(gomock.Any(), gomock.Any()).Return(repository.UpdateUserStatusOutput{IsUpdated: true}, nil)
+			},
+			want:    DeactivateUserOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success, login provider user without a login time must log in",
+			args: args{
+				input: DeactivateUserInput{
+					Id: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(providerIdentities, nil)
+			},
+			want: DeactivateUserOutput{
+				IsLoginRequired: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, login provider user with an old login must log in",
+			args: args{
+				input: DeactivateUserInput{
+					Id:              3,
+					AuthenticatedAt: time.Now().Add(-6 * time.Minute),
+				},
+			},
+			authenticator: &stubAuthenticator{domain: "corp.example.com", output: AuthenticateOutput{Id: 3}},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(providerIdentities, nil)
+			},
+			want: DeactivateUserOutput{
+				IsLoginRequired: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, login provider user with a recent login",
+			args: args{
+				input: DeactivateUserInput{
+					Id:              3,
+					AuthenticatedAt: time.Now().Add(-time.Minute),
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(providerIdentities, nil)
+				mockRepository.EXPECT().UpdateUserStatus(gomock.Any(), gomock.Any()).Return(repository.UpdateUserStatusOutput{IsUpdated: true}, nil)
+			},
+			want:    DeactivateUserOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success",
+			args: args{
+				input: DeactivateUserInput{
+					Id:       3,
+					Password: "Current1!",
+					Reason:   "taking a break",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Eq(repository.GetPasswordByIdInput{
+					Id: 3,
+				})).Return(repository.GetPasswordByIdOutput{Password: currentHash}, nil)
+				mockRepository.EXPECT().UpdateUserStatus(gomock.Any(), gomock.Eq(repository.UpdateUserStatusInput{
+					Id:         3,
+					FromStatus: USER_STATUS_ACTIVE,
+					Status:     USER_STATUS_DEACTIVATED,
+					Reason:     "taking a break",
+					ChangedBy:  3,
+				})).Return(repository.UpdateUserStatusOutput{IsUpdated: true}, nil)
+			},
+			want:    DeactivateUserOutput{},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository:     mockRepository,
+				PasswordHasher: hasher,
+			})
+			if tt.authenticator != nil {
+				u.Authenticators = []Authenticator{tt.authenticator}
+			}
+			got, err := u.DeactivateUser(context.Background(), tt.args.input)
+			if tt.authenticator != nil {
+				for _, input := range tt.authenticator.inputs {
+					assert.Equal(t, LoginInput{Email: "jane@corp.example.com", Password: tt.args.input.Password}, input)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.DeactivateUser() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.DeactivateUser() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_GetUserStatusChanges(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	var (
+		createdAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		changedBy = int64(1)
+	)
+
+	type args struct {
+		input GetUserStatusChangesInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     GetUserStatusChangesOutput
+		wantErr  bool
+	}{
+		{
+			name: "success, user not found",
+			args: args{
+				input: GetUserStatusChangesInput{
+					Id: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{}, errors.WithStack(sql.ErrNoRows))
+			},
+			want: GetUserStatusChangesOutput{
+				IsNotFound: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error when GetUserStatusChangesByUserId",
+			args: args{
+				input: GetUserStatusChangesInput{
+					Id: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().GetUserStatusChangesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusChangesByUserIdOutput{}, errors.New("test"))
+			},
+			want:    GetUserStatusChangesOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			args: args{
+				input: GetUserStatusChangesInput{
+					Id: 2,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_SUSPENDED}, nil)
+				mockRepository.EXPECT().GetUserStatusChangesByUserId(gomock.Any(), gomock.Eq(repository.GetUserStatusChangesByUserIdInput{
+					UserId: 2,
+				})).Return(repository.GetUserStatusChangesByUserIdOutput{
+					Changes: []repository.UserStatusChange{
+						{
+							Id:         5,
+							FromStatus: USER_STATUS_ACTIVE,
+							ToStatus:   USER_STATUS_SUSPENDED,
+							Reason:     "chargeback",
+							ChangedBy:  &changedBy,
+							CreatedAt:  createdAt,
+						},
+					},
+				}, nil)
+			},
+			want: GetUserStatusChangesOutput{
+				Changes: []UserStatusChange{
+					{
+						Id:         5,
+						FromStatus: USER_STATUS_ACTIVE,
+						ToStatus:   USER_STATUS_SUSPENDED,
+						Reason:     "chargeback",
+						ChangedBy:  &changedBy,
+						CreatedAt:  createdAt,
+					},
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+			})
+			got, err := u.GetUserStatusChanges(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.GetUserStatusChanges() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.GetUserStatusChanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_ImpersonateUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	utils.KeyDataPrivate, _ = os.ReadFile("./../rsakey/jwtrsa256.key")
+	utils.KeyDataPublic, _ = os.ReadFile("./../rsakey/jwtrsa256.key.pub")
+
+	type args struct {
+		input ImpersonateUserInput
+	}
+	tests := []struct {
+		name       string
+		args       args
+		mockFunc   func(args)
+		want       ImpersonateUserOutput
+		wantClaims utils.TokenClaims
+		wantErr    bool
+	}{
+		{
+			name: "error when GetUserStatusById",
+			args: args{
+				input: ImpersonateUserInput{
+					Id:      2,
+					AdminId: 1,
+					Reason:  "ticket 42",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{}, errors.New("test"))
+			},
+			want:    ImpersonateUserOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, user not found",
+			args: args{
+				input: ImpersonateUserInput{
+					Id:      2,
+					AdminId: 1,
+					Reason:  "ticket 42",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{}, errors.WithStack(sql.ErrNoRows))
+			},
+			want: ImpersonateUserOutput{
+				IsNotFound: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, user suspended",
+			args: args{
+				input: ImpersonateUserInput{
+					Id:      2,
+					AdminId: 1,
+					Reason:  "ticket 42",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_SUSPENDED}, nil)
+			},
+			want: ImpersonateUserOutput{
+				IsNotActive: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error when InsertImpersonation",
+			args: args{
+				input: ImpersonateUserInput{
+					Id:      2,
+					AdminId: 1,
+					Reason:  "ticket 42",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().InsertImpersonation(gomock.Any(), gomock.Any()).Return(repository.InsertImpersonationOutput{}, errors.New("test"))
+			},
+			want:    ImpersonateUserOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			args: args{
+				input: ImpersonateUserInput{
+					Id:      2,
+					AdminId: 1,
+					Reason:  "ticket 42",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetUserStatusById(gomock.Any(), gomock.Eq(repository.GetUserStatusByIdInput{
+					Id: 2,
+				})).Return(repository.GetUserStatusByIdOutput{Status: USER_STATUS_ACTIVE}, nil)
+				mockRepository.EXPECT().InsertImpersonation(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, input repository.InsertImpersonationInput) (repository.InsertImpersonationOutput, error) {
+					assert.Equal(t, int64(1), input.AdminId)
+					assert.Equal(t, int64(2), input.UserId)
+					assert.Equal(t, "ticket 42", input.Reason)
+					assert.WithinDuration(t, time.Now().Add(15*time.Minute), input.ExpiresAt, time.Minute)
+
+					return repository.InsertImpersonationOutput{Id: 9}, nil
+				})
+			},
+			want: ImpersonateUserOutput{},
+			wantClaims: utils.TokenClaims{
+				Id:              2,
+				ActorId:         1,
+				ImpersonationId: 9,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository:            mockRepository,
+				ImpersonationLifespan: 15 * time.Minute,
+			})
+			got, err := u.ImpersonateUser(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.ImpersonateUser() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantClaims.Id != 0 {
+				claims, err := utils.TokenParseClaims(got.Token)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantClaims, claims)
+				assert.WithinDuration(t, time.Now().Add(15*time.Minute), got.ExpiresAt, time.Minute)
+
+				got.Token = ""
+				got.ExpiresAt = time.Time{}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.ImpersonateUser() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_RecordImpersonationUse(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	input := RecordImpersonationUseInput{
+		ImpersonationId: 9,
+		Method:          "PUT",
+		Path:            "/profile",
+	}
+
+	tests := []struct {
+		name     string
+		mockFunc func()
+		want     RecordImpersonationUseOutput
+		wantErr  bool
+	}{
+		{
+			name: "error when InsertImpersonationUse",
+			mockFunc: func() {
+				mockRepository.EXPECT().InsertImpersonationUse(gomock.Any(), gomock.Any()).Return(errors.New("test"))
+			},
+			want:    RecordImpersonationUseOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			mockFunc: func() {
+				mockRepository.EXPECT().InsertImpersonationUse(gomock.Any(), gomock.Eq(repository.InsertImpersonationUseInput{
+					ImpersonationId: 9,
+					Method:          "PUT",
+					Path:            "/profile",
+				})).Return(nil)
+			},
+			want:    RecordImpersonationUseOutput{},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc()
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+			})
+			got, err := u.RecordImpersonationUse(context.Background(), input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.RecordImpersonationUse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.RecordImpersonationUse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_DeleteUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	var (
+		hasher         = utils.NewArgon2idHasher(1024, 1, 1)
+		currentHash, _ = hasher.Hash("Current1!")
+		verifiedAt     = time.Now()
+
+		// the email is served by the directory
+		directoryIdentities = repository.GetIdentitiesByUserIdOutput{
+			Identities: []repository.Identity{
+				{Id: 1, Type: "corp", Identifier: "6c1d8a52-0b7e-4c39-9a0e-1d2f3a4b5c6d"},
+				{Id: 2, Type: IDENTITY_TYPE_EMAIL, Identifier: "jane@corp.example.com", IsPrimary: true, VerifiedAt: &verifiedAt},
+			},
+		}
+		// an unverified email does not make the user a directory user
+		providerIdentities = repository.GetIdentitiesByUserIdOutput{
+			Identities: []repository.Identity{
+				{Id: 1, Type: "acme", Identifier: "subject-1"},
+				{Id: 2, Type: IDENTITY_TYPE_EMAIL, Identifier: "jane@corp.example.com", IsPrimary: true},
+			},
+		}
+	)
+
+	type args struct {
+		input DeleteUserInput
+	}
+	tests := []struct {
+		name          string
+		args          args
+		authenticator *stubAuthenticator
+		mockFunc      func(args)
+		want          DeleteUserOutput
+		wantErr       bool
+	}{
+		{
+			name: "error when GetPasswordById",
+			args: args{
+				input: DeleteUserInput{
+					Id:       3,
+					Password: "Current1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, errors.New("test"))
+			},
+			want:    DeleteUserOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, password wrong",
+			args: args{
+				input: DeleteUserInput{
+					Id:       3,
+					Password: "Wrong1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{Password: currentHash}, nil)
+			},
+			want: DeleteUserOutput{
+				IsPasswordWrong: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error when DeleteUser",
+			args: args{
+				input: DeleteUserInput{
+					Id:       3,
+					Password: "Current1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{Password: currentHash}, nil)
+				mockRepository.EXPECT().DeleteUser(gomock.Any(), gomock.Any()).Return(repository.DeleteUserOutput{}, errors.New("test"))
+			},
+			want:    DeleteUserOutput{},
+			wantErr: true,
+		},
+		{
+			name: "error status changed concurrently",
+			args: args{
+				input: DeleteUserInput{
+					Id:       3,
+					Password: "Current1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{Password: currentHash}, nil)
+				mockRepository.EXPECT().DeleteUser(gomock.Any(), gomock.Any()).Return(repository.DeleteUserOutput{}, nil)
+			},
+			want:    DeleteUserOutput{},
+			wantErr: true,
+		},
+		{
+			name: "error when GetIdentitiesByUserId",
+			args: args{
+				input: DeleteUserInput{
+					Id: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetIdentitiesByUserIdOutput{}, errors.New("test"))
+			},
+			want:    DeleteUserOutput{},
+			wantErr: true,
+		},
+		{
+			name: "error when Authenticate",
+			args: args{
+				input: DeleteUserInput{
+					Id:       3,
+					Password: "Directory1!",
+				},
+			},
+			authenticator: &stubAuthenticator{domain: "corp.example.com", err: errors.New("test")},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(directoryIdentities, nil)
+			},
+			want:    DeleteUserOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, directory password wrong",
+			args: args{
+				input: DeleteUserInput{
+					Id:       3,
+					Password: "Wrong1!",
+				},
+			},
+			authenticator: &stubAuthenticator{domain: "corp.example.com", output: AuthenticateOutput{IsInvalidCredentials: true}},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(directoryIdentities, nil)
+			},
+			want: DeleteUserOutput{
+				IsPasswordWrong: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, empty password of a directory user is wrong",
+			args: args{
+				input: DeleteUserInput{
+					Id:              3,
+					AuthenticatedAt: time.Now(),
+				},
+			},
+			authenticator: &stubAuthenticator{domain: "corp.example.com", output: AuthenticateOutput{Id: 3}},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(directoryIdentities, nil)
+			},
+			want: DeleteUserOutput{
+				IsPasswordWrong: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, directory password of someone else is wrong",
+			args: args{
+				input: DeleteUserInput{
+					Id:       3,
+					Password: "Directory1!",
+				},
+			},
+			authenticator: &stubAuthenticator{domain: "corp.example.com", output: AuthenticateOutput{Id: 4}},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(directoryIdentities, nil)
+			},
+			want: DeleteUserOutput{
+				IsPasswordWrong: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, directory password",
+			args: args{
+				input: DeleteUserInput{
+					Id:       3,
+					Password: "Directory1!",
+				},
+			},
+			authenticator: &stubAuthenticator{domain: "corp.example.com", output: AuthenticateOutput{Id: 3}},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(directoryIdentities, nil)
+				mockRepository.EXPECT().DeleteUser(gomock.Any(), gomock.Any()).Return(repository.DeleteUserOutput{IsDeleted: true}, nil)
+			},
+			want:    DeleteUserOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success, login provider user without a login time must log in",
+			args: args{
+				input: DeleteUserInput{
+					Id: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(providerIdentities, nil)
+			},
+			want: DeleteUserOutput{
+				IsLoginRequired: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, login provider user with an old login must log in",
+			args: args{
+				input: DeleteUserInput{
+					Id:              3,
+					AuthenticatedAt: time.Now().Add(-6 * time.Minute),
+				},
+			},
+			authenticator: &stubAuthenticator{domain: "corp.example.com", output: AuthenticateOutput{Id: 3}},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(providerIdentities, nil)
+			},
+			want: DeleteUserOutput{
+				IsLoginRequired: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, login provider user with a recent login",
+			args: args{
+				input: DeleteUserInput{
+					Id:              3,
+					AuthenticatedAt: time.Now().Add(-time.Minute),
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Any()).Return(repository.GetPasswordByIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(providerIdentities, nil)
+				mockRepository.EXPECT().DeleteUser(gomock.Any(), gomock.Any()).Return(repository.DeleteUserOutput{IsDeleted: true}, nil)
+			},
+			want:    DeleteUserOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success",
+			args: args{
+				input: DeleteUserInput{
+					Id:       3,
+					Password: "Current1!",
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetPasswordById(gomock.Any(), gomock.Eq(repository.GetPasswordByIdInput{
+					Id: 3,
+				})).Return(repository.GetPasswordByIdOutput{Password: currentHash}, nil)
+				mockRepository.EXPECT().DeleteUser(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, input repository.DeleteUserInput) (repository.DeleteUserOutput, error) {
+						assert.Equal(t, int64(3), input.Id)
+						assert.Equal(t, accountDeletionReason, input.Reason)
+						assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), input.PurgeAt, time.Minute)
+
+						return repository.DeleteUserOutput{IsDeleted: true}, nil
+					})
+			},
+			want:    DeleteUserOutput{},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository:                 mockRepository,
+				PasswordHasher:             hasher,
+				AccountDeletionGracePeriod: 30 * 24 * time.Hour,
+			})
+			if tt.authenticator != nil {
+				u.Authenticators = []Authenticator{tt.authenticator}
+			}
+			got, err := u.DeleteUser(context.Background(), tt.args.input)
+			if tt.authenticator != nil {
+				for _, input := range tt.authenticator.inputs {
+					assert.Equal(t, LoginInput{Email: "jane@corp.example.com", Password: tt.args.input.Password}, input)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.DeleteUser() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !got.PurgeAt.IsZero() {
+				assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), got.PurgeAt, time.Minute)
+				got.PurgeAt = time.Time{}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.DeleteUser() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_RestoreUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	type args struct {
+		input RestoreUserInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     RestoreUserOutput
+		wantErr  bool
+	}{
+		{
+			name: "error when RestoreUser",
+			args: args{
+				input: RestoreUserInput{
+					Id: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().RestoreUser(gomock.Any(), gomock.Any()).Return(repository.RestoreUserOutput{}, errors.New("test"))
+			},
+			want:    RestoreUserOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, already purged",
+			args: args{
+				input: RestoreUserInput{
+					Id: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().RestoreUser(gomock.Any(), gomock.Any()).Return(repository.RestoreUserOutput{}, nil)
+			},
+			want: RestoreUserOutput{
+				IsNotRestorable: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success",
+			args: args{
+				input: RestoreUserInput{
+					Id: 3,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().RestoreUser(gomock.Any(), gomock.Eq(repository.RestoreUserInput{
+					Id:     3,
+					Reason: accountRestorationReason,
+				})).Return(repository.RestoreUserOutput{IsRestored: true}, nil)
+			},
+			want:    RestoreUserOutput{},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+			})
+			got, err := u.RestoreUser(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.RestoreUser() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.RestoreUser() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_PurgeDeletedUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+	storage := &utils.LocalObjectStorage{
+		Dir:     t.TempDir(),
+		BaseURL: "http://localhost/storage",
+	}
+
+	tests := []struct {
+		name     string
+		mockFunc func()
+		want     PurgeDeletedUsersOutput
+		wantErr  bool
+	}{
+		{
+			name: "error when PurgeDeletedUsers",
+			mockFunc: func() {
+				mockRepository.EXPECT().PurgeDeletedUsers(gomock.Any(), gomock.Any()).Return(repository.PurgeDeletedUsersOutput{}, errors.New("test"))
+			},
+			want:    PurgeDeletedUsersOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			mockFunc: func() {
+				mockRepository.EXPECT().PurgeDeletedUsers(gomock.Any(), gomock.Eq(repository.PurgeDeletedUsersInput{
+					FullName: purgedUserFullName,
+				})).Return(repository.PurgeDeletedUsersOutput{Count: 2}, nil)
+			},
+			want: PurgeDeletedUsersOutput{
+				Count: 2,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success deleting avatars",
+			mockFunc: func() {
+				putAvatar(t, storage, "avatars/3/abc")
+				putAvatar(t, storage, "avatars/4/def")
+				mockRepository.EXPECT().PurgeDeletedUsers(gomock.Any(), gomock.Any()).Return(repository.PurgeDeletedUsersOutput{
+					Count:      3,
+					AvatarKeys: []string{"avatars/3/abc", "avatars/4/def"},
+				}, nil)
+			},
+			want: PurgeDeletedUsersOutput{
+				Count: 3,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc()
+			u := NewUsecase(NewUsecaseOptions{
+				Repository:    mockRepository,
+				ObjectStorage: storage,
+			})
+			got, err := u.PurgeDeletedUsers(context.Background(), PurgeDeletedUsersInput{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.PurgeDeletedUsers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.PurgeDeletedUsers() = %v, want %v", got, tt.want)
+			}
+			if files := storedFiles(t, storage.Dir); len(files) != 0 {
+				t.Errorf("Usecase.PurgeDeletedUsers() left %v", files)
+			}
+		})
+	}
+}
+
+func TestUsecase_ExportUserData(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	var (
+		createdAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		adminId   = int64(1)
+	)
+
+	// expectDataExport expects the data of user id to be collected, AnyTimes
+	// so that it also serves a background export
+	expectDataExport := func(id int64) {
+		mockRepository.EXPECT().GetUserDetailsById(gomock.Any(), gomock.Eq(repository.GetUserDetailsByIdInput{
+			Id: id,
+		})).Return(repository.GetUserDetailsByIdOutput{
+			Id:          id,
+			FullName:    "Jane Doe",
+			PhoneNumber: "+6281234567890",
+			Status:      USER_STATUS_ACTIVE,
+			UserGroup:   "default",
+			TotalLogin:  4,
+			CreatedAt:   createdAt,
+			AvatarKey:   fmt.Sprintf("avatars/%d/abc", id),
+		}, nil).AnyTimes()
+		mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Eq(repository.GetRolesByUserIdInput{
+			UserId: id,
+		})).Return(repository.GetRolesByUserIdOutput{Roles: []string{"support"}}, nil).AnyTimes()
+		mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Eq(repository.GetIdentitiesByUserIdInput{
+			UserId: id,
+		})).Return(repository.GetIdentitiesByUserIdOutput{
+			Identities: []repository.Identity{
+				{Id: 1, Type: IDENTITY_TYPE_PHONE, Identifier: "+6281234567890", IsPrimary: true, VerifiedAt: &createdAt},
+			},
+		}, nil).AnyTimes()
+		mockRepository.EXPECT().GetLoginEventsByUserId(gomock.Any(), gomock.Eq(repository.GetLoginEventsByUserIdInput{
+			UserId: id,
+		})).Return(repository.GetLoginEventsByUserIdOutput{
+			Events: []repository.LoginEvent{
+				{Id: 8, Method: "acme", IP: "203.0.113.7", CreatedAt: createdAt.Add(time.Hour)},
+				{Id: 7, Method: LOGIN_METHOD_PASSWORD, CreatedAt: createdAt},
+			},
+		}, nil).AnyTimes()
+		mockRepository.EXPECT().GetUserStatusChangesByUserId(gomock.Any(), gomock.Eq(repository.GetUserStatusChangesByUserIdInput{
+			UserId: id,
+		})).Return(repository.GetUserStatusChangesByUserIdOutput{
+			Changes: []repository.UserStatusChange{
+				{Id: 2, FromStatus: USER_STATUS_PENDING, ToStatus: USER_STATUS_ACTIVE, Reason: "verified", ChangedBy: &adminId, CreatedAt: createdAt},
+			},
+		}, nil).AnyTimes()
+		mockRepository.EXPECT().GetImpersonationsByUserId(gomock.Any(), gomock.Eq(repository.GetImpersonationsByUserIdInput{
+			UserId: id,
+		})).Return(repository.GetImpersonationsByUserIdOutput{
+			Impersonations: []repository.Impersonation{
+				{Id: 4, Reason: "ticket 43", CreatedAt: createdAt, ExpiresAt: createdAt.Add(15 * time.Minute)},
+				{Id: 3, AdminId: &adminId, Reason: "ticket 42", CreatedAt: createdAt, ExpiresAt: createdAt.Add(15 * time.Minute)},
+			},
+		}, nil).AnyTimes()
+		mockRepository.EXPECT().GetImpersonationUsesByUserId(gomock.Any(), gomock.Eq(repository.GetImpersonationUsesByUserIdInput{
+			UserId: id,
+		})).Return(repository.GetImpersonationUsesByUserIdOutput{
+			Uses: []repository.ImpersonationUse{
+				{Id: 5, ImpersonationId: 3, Method: "GET", Path: "/profile", CreatedAt: createdAt},
+				{Id: 6, ImpersonationId: 3, Method: "PUT", Path: "/profile", CreatedAt: createdAt},
+			},
+		}, nil).AnyTimes()
+	}
+
+	wantExport := func(id int64) *dataExport {
+		return &dataExport{
+			Profile: dataExportProfile{
+				Id:          id,
+				FullName:    "Jane Doe",
+				PhoneNumber: "+6281234567890",
+				Status:      USER_STATUS_ACTIVE,
+				UserGroup:   "default",
+				Roles:       []string{"support"},
+				TotalLogin:  4,
+				CreatedAt:   createdAt,
+				Avatar: &dataExportAvatar{
+					Small:  fmt.Sprintf("http://localhost/storage/avatars/%d/abc/small.jpg", id),
+					Medium: fmt.Sprintf("http://localhost/storage/avatars/%d/abc/medium.jpg", id),
+					Large:  fmt.Sprintf("http://localhost/storage/avatars/%d/abc/large.jpg", id),
+				},
+			},
+			Identities: []dataExportIdentity{
+				{Id: 1, Type: IDENTITY_TYPE_PHONE, Identifier: "+6281234567890", IsPrimary: true, VerifiedAt: &createdAt},
+			},
+			Logins: []dataExportLogin{
+				{Id: 8, Method: "acme", IP: "203.0.113.7", CreatedAt: createdAt.Add(time.Hour)},
+				{Id: 7, Method: LOGIN_METHOD_PASSWORD, CreatedAt: createdAt},
+			},
+			StatusChanges: []dataExportStatusChange{
+				{Id: 2, FromStatus: USER_STATUS_PENDING, ToStatus: USER_STATUS_ACTIVE, Reason: "verified", ChangedBy: &adminId, CreatedAt: createdAt},
+			},
+			Impersonations: []dataExportImpersonation{
+				{Id: 4, Reason: "ticket 43", CreatedAt: createdAt, ExpiresAt: createdAt.Add(15 * time.Minute), Uses: []dataExportImpersonationUse{}},
+				{
+					Id:        3,
+					AdminId:   &adminId,
+					Reason:    "ticket 42",
+					CreatedAt: createdAt,
+					ExpiresAt: createdAt.Add(15 * time.Minute),
+					Uses: []dataExportImpersonationUse{
+						{Method: "GET", Path: "/profile", CreatedAt: createdAt},
+						{Method: "PUT", Path: "/profile", CreatedAt: createdAt},
+					},
+				},
+			},
+		}
+	}
+
+	type args struct {
+		input ExportUserDataInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     ExportUserDataOutput
+		// wantExport is the decoded content of the file of want
+		wantExport *dataExport
+		wantErr    bool
+	}{
+		{
+			name: "error when CountUserRecords",
+			args: args{
+				input: ExportUserDataInput{
+					Id:     20,
+					Format: DATA_EXPORT_FORMAT_JSON,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().CountUserRecords(gomock.Any(), gomock.Any()).Return(repository.CountUserRecordsOutput{}, errors.New("test"))
+			},
+			want:    ExportUserDataOutput{},
+			wantErr: true,
+		},
+		{
+			name: "error when GetUserDetailsById",
+			args: args{
+				input: ExportUserDataInput{
+					Id:     20,
+					Format: DATA_EXPORT_FORMAT_JSON,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().CountUserRecords(gomock.Any(), gomock.Any()).Return(repository.CountUserRecordsOutput{Count: 3}, nil)
+				mockRepository.EXPECT().GetUserDetailsById(gomock.Any(), gomock.Any()).Return(repository.GetUserDetailsByIdOutput{}, errors.New("test"))
+			},
+			want:    ExportUserDataOutput{},
+			wantErr: true,
+		},
+		{
+			name: "error when GetLoginEventsByUserId",
+			args: args{
+				input: ExportUserDataInput{
+					Id:     20,
+					Format: DATA_EXPORT_FORMAT_JSON,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().CountUserRecords(gomock.Any(), gomock.Any()).Return(repository.CountUserRecordsOutput{Count: 3}, nil)
+				mockRepository.EXPECT().GetUserDetailsById(gomock.Any(), gomock.Any()).Return(repository.GetUserDetailsByIdOutput{Id: 20}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetIdentitiesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().GetLoginEventsByUserId(gomock.Any(), gomock.Any()).Return(repository.GetLoginEventsByUserIdOutput{}, errors.New("test"))
+			},
+			want:    ExportUserDataOutput{},
+			wantErr: true,
+		},
+		{
+			name: "error when GetImpersonationUsesByUserId",
+			args: args{
+				input: ExportUserDataInput{
+					Id:     20,
+					Format: DATA_EXPORT_FORMAT_JSON,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().CountUserRecords(gomock.Any(), gomock.Any()).Return(repository.CountUserRecordsOutput{Count: 3}, nil)
+				mockRepository.EXPECT().GetUserDetailsById(gomock.Any(), gomock.Any()).Return(repository.GetUserDetailsByIdOutput{Id: 20}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetIdentitiesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().GetLoginEventsByUserId(gomock.Any(), gomock.Any()).Return(repository.GetLoginEventsByUserIdOutput{}, nil)
+				mockRepository.EXPECT().GetUserStatusChangesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusChangesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().GetImpersonationsByUserId(gomock.Any(), gomock.Any()).Return(repository.GetImpersonationsByUserIdOutput{}, nil)
+				mockRepository.EXPECT().GetImpersonationUsesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetImpersonationUsesByUserIdOutput{}, errors.New("test"))
+			},
+			want:    ExportUserDataOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, json",
+			args: args{
+				input: ExportUserDataInput{
+					Id:     21,
+					Format: DATA_EXPORT_FORMAT_JSON,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().CountUserRecords(gomock.Any(), gomock.Eq(repository.CountUserRecordsInput{
+					UserId: 21,
+				})).Return(repository.CountUserRecordsOutput{Count: 3}, nil)
+				expectDataExport(21)
+			},
+			want: ExportUserDataOutput{
+				File: DataExportFile{
+					FileName:    "user-data-21.json",
+					ContentType: "application/json",
+				},
+			},
+			wantExport: wantExport(21),
+			wantErr:    false,
+		},
+		{
+			name: "success, zipped ndjson",
+			args: args{
+				input: ExportUserDataInput{
+					Id:     22,
+					Format: DATA_EXPORT_FORMAT_NDJSON,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().CountUserRecords(gomock.Any(), gomock.Any()).Return(repository.CountUserRecordsOutput{Count: 5}, nil)
+				expectDataExport(22)
+			},
+			want: ExportUserDataOutput{
+				File: DataExportFile{
+					FileName:    "user-data-22.zip",
+					ContentType: "application/zip",
+				},
+			},
+			wantExport: wantExport(22),
+			wantErr:    false,
+		},
+		{
+			name: "error when GetOpenDataExport",
+			args: args{
+				input: ExportUserDataInput{
+					Id:     23,
+					Format: DATA_EXPORT_FORMAT_JSON,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().CountUserRecords(gomock.Any(), gomock.Any()).Return(repository.CountUserRecordsOutput{Count: 6}, nil)
+				mockRepository.EXPECT().GetOpenDataExport(gomock.Any(), gomock.Any()).Return(repository.GetOpenDataExportOutput{}, errors.New("test"))
+			},
+			want:    ExportUserDataOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, async export of the format already pending or ready",
+			args: args{
+				input: ExportUserDataInput{
+					Id:     23,
+					Format: DATA_EXPORT_FORMAT_NDJSON,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().CountUserRecords(gomock.Any(), gomock.Any()).Return(repository.CountUserRecordsOutput{Count: 6}, nil)
+				mockRepository.EXPECT().GetOpenDataExport(gomock.Any(), gomock.Eq(repository.GetOpenDataExportInput{
+					UserId: 23,
+					Format: DATA_EXPORT_FORMAT_NDJSON,
+				})).Return(repository.GetOpenDataExportOutput{
+					Id:        8,
+					ExpiresAt: time.Now().Add(time.Hour),
+				}, nil)
+			},
+			want: ExportUserDataOutput{
+				IsAsync:  true,
+				ExportId: 8,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error when InsertDataExport",
+			args: args{
+				input: ExportUserDataInput{
+					Id:     23,
+					Format: DATA_EXPORT_FORMAT_JSON,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().CountUserRecords(gomock.Any(), gomock.Any()).Return(repository.CountUserRecordsOutput{Count: 6}, nil)
+				mockRepository.EXPECT().GetOpenDataExport(gomock.Any(), gomock.Any()).Return(repository.GetOpenDataExportOutput{}, sql.ErrNoRows)
+				mockRepository.EXPECT().InsertDataExport(gomock.Any(), gomock.Any()).Return(repository.InsertDataExportOutput{}, errors.New("test"))
+			},
+			want:    ExportUserDataOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, async",
+			args: args{
+				input: ExportUserDataInput{
+					Id:     24,
+					Format: DATA_EXPORT_FORMAT_JSON,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().CountUserRecords(gomock.Any(), gomock.Any()).Return(repository.CountUserRecordsOutput{Count: 6}, nil)
+				mockRepository.EXPECT().GetOpenDataExport(gomock.Any(), gomock.Any()).Return(repository.GetOpenDataExportOutput{}, sql.ErrNoRows)
+				mockRepository.EXPECT().InsertDataExport(gomock.Any(), gomock.Any()).Return(repository.InsertDataExportOutput{Id: 9}, nil)
+				expectDataExport(24)
+				mockRepository.EXPECT().UpdateDataExport(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			},
+			want: ExportUserDataOutput{
+				IsAsync:  true,
+				ExportId: 9,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository:               mockRepository,
+				DataExportSyncMaxRecords: 5,
+				ObjectStorage: &utils.LocalObjectStorage{
+					Dir:     t.TempDir(),
+					BaseURL: "http://localhost/storage/",
+				},
+			})
+			got, err := u.ExportUserData(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.ExportUserData() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got.IsAsync != got.ExpiresAt.After(time.Now()) {
+				t.Errorf("Usecase.ExportUserData() ExpiresAt = %v, want a future time for an async export only", got.ExpiresAt)
+			}
+			got.ExpiresAt = time.Time{}
+			if tt.wantExport != nil {
+				gotExport := decodeDataExport(t, tt.args.input.Format, got.File.Content)
+				if !reflect.DeepEqual(gotExport, *tt.wantExport) {
+					t.Errorf("Usecase.ExportUserData() export = %+v, want %+v", gotExport, *tt.wantExport)
+				}
+				got.File.Content = nil
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.ExportUserData() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// decodeDataExport reads back the content of an export in format, without
+// the time of the export.
+func decodeDataExport(t *testing.T, format string, content []byte) dataExport {
+	t.Helper()
+
+	var export dataExport
+	if format == DATA_EXPORT_FORMAT_JSON {
+		if err := json.Unmarshal(content, &export); err != nil {
+			t.Fatalf("invalid json export: %v", err)
+		}
+	} else {
+		archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		if err != nil || len(archive.File) != 1 {
+			t.Fatalf("invalid zip export: %v", err)
+		}
+
+		f, err := archive.File[0].Open()
+		if err != nil {
+			t.Fatalf("invalid zip export: %v", err)
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var record struct {
+				Type string          `json:"type"`
+				Data json.RawMessage `json:"data"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Fatalf("invalid ndjson line %q: %v", scanner.Text(), err)
+			}
+
+			var err error
+			switch record.Type {
+			case "export":
+				err = json.Unmarshal(record.Data, &export)
+			case "profile":
+				err = json.Unmarshal(record.Data, &export.Profile)
+			case "identity":
+				var identity dataExportIdentity
+				err = json.Unmarshal(record.Data, &identity)
+				export.Identities = append(export.Identities, identity)
+			case "login":
+				var login dataExportLogin
+				err = json.Unmarshal(record.Data, &login)
+				export.Logins = append(export.Logins, login)
+			case "status_change":
+				var change dataExportStatusChange
+				err = json.Unmarshal(record.Data, &change)
+				export.StatusChanges = append(export.StatusChanges, change)
+			case "impersonation":
+				var impersonation dataExportImpersonation
+				err = json.Unmarshal(record.Data, &impersonation)
+				export.Impersonations = append(export.Impersonations, impersonation)
+			default:
+				t.Fatalf("unexpected ndjson record type %q", record.Type)
+			}
+			if err != nil {
+				t.Fatalf("invalid ndjson %s record: %v", record.Type, err)
+			}
+		}
+	}
+
+	if export.ExportedAt.IsZero() {
+		t.Errorf("export has no exported_at")
+	}
+	export.ExportedAt = time.Time{}
+
+	return export
+}
+
+func TestUsecase_runDataExport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	tests := []struct {
+		name     string
+		mockFunc func()
+	}{
+		{
+			name: "failed when GetUserDetailsById",
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserDetailsById(gomock.Any(), gomock.Any()).Return(repository.GetUserDetailsByIdOutput{}, errors.New("test"))
+				mockRepository.EXPECT().UpdateDataExport(gomock.Any(), gomock.Eq(repository.UpdateDataExportInput{
+					Id:     9,
+					Status: repository.DATA_EXPORT_STATUS_FAILED,
+				})).Return(nil)
+			},
+		},
+		{
+			name: "ready, error when UpdateDataExport is only logged",
+			mockFunc: func() {
+				mockRepository.EXPECT().GetUserDetailsById(gomock.Any(), gomock.Any()).Return(repository.GetUserDetailsByIdOutput{Id: 2}, nil)
+				mockRepository.EXPECT().GetRolesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetRolesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().GetIdentitiesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetIdentitiesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().GetLoginEventsByUserId(gomock.Any(), gomock.Any()).Return(repository.GetLoginEventsByUserIdOutput{}, nil)
+				mockRepository.EXPECT().GetUserStatusChangesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetUserStatusChangesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().GetImpersonationsByUserId(gomock.Any(), gomock.Any()).Return(repository.GetImpersonationsByUserIdOutput{}, nil)
+				mockRepository.EXPECT().GetImpersonationUsesByUserId(gomock.Any(), gomock.Any()).Return(repository.GetImpersonationUsesByUserIdOutput{}, nil)
+				mockRepository.EXPECT().UpdateDataExport(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input repository.UpdateDataExportInput) error {
+					if input.Id != 9 || input.Status != repository.DATA_EXPORT_STATUS_READY || len(input.Content) == 0 {
+						t.Errorf("UpdateDataExport() input = %+v, want the ready export 9", input)
+					}
+					return errors.New("test")
+				})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc()
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+			})
+			u.runDataExport(context.Background(), 9, 2, DATA_EXPORT_FORMAT_NDJSON)
+		})
+	}
+}
+
+func TestUsecase_runDataExport_maxConcurrent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	started := make(chan struct{})
+	mockRepository.EXPECT().GetUserDetailsById(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, repository.GetUserDetailsByIdInput) (repository.GetUserDetailsByIdOutput, error) {
+		close(started)
+		return repository.GetUserDetailsByIdOutput{}, errors.New("test")
+	})
+	mockRepository.EXPECT().UpdateDataExport(gomock.Any(), gomock.Any()).Return(nil)
+
+	u := NewUsecase(NewUsecaseOptions{
+		Repository:              mockRepository,
+		DataExportMaxConcurrent: 1,
+	})
+
+	// another export is being built
+	u.dataExportSlots <- struct{}{}
+
+	done := make(chan struct{})
+	go func() {
+		u.runDataExport(context.Background(), 9, 2, DATA_EXPORT_FORMAT_JSON)
+		close(done)
+	}()
+
+	select {
+	case <-started:
+		t.Fatal("runDataExport() started while no slot was free")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-u.dataExportSlots
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("runDataExport() did not finish once a slot was free")
+	}
+
+	if len(u.dataExportSlots) != 0 {
+		t.Errorf("runDataExport() kept its slot")
+	}
+}
+
+func TestUsecase_GetDataExport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	expiresAt := time.Date(2024, 1, 3, 3, 4, 5, 0, time.UTC)
+
+	type args struct {
+		input GetDataExportInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     GetDataExportOutput
+		wantErr  bool
+	}{
+		{
+			name: "error when GetDataExport",
+			args: args{
+				input: GetDataExportInput{
+					Id:       2,
+					ExportId: 9,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetDataExport(gomock.Any(), gomock.Any()).Return(repository.GetDataExportOutput{}, errors.New("test"))
+			},
+			want:    GetDataExportOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, not found",
+			args: args{
+				input: GetDataExportInput{
+					Id:       2,
+					ExportId: 9,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetDataExport(gomock.Any(), gomock.Any()).Return(repository.GetDataExportOutput{}, errors.WithStack(sql.ErrNoRows))
+			},
+			want: GetDataExportOutput{
+				IsNotFound: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, pending",
+			args: args{
+				input: GetDataExportInput{
+					Id:       2,
+					ExportId: 9,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetDataExport(gomock.Any(), gomock.Any()).Return(repository.GetDataExportOutput{
+					Id:        9,
+					Format:    DATA_EXPORT_FORMAT_JSON,
+					Status:    repository.DATA_EXPORT_STATUS_PENDING,
+					ExpiresAt: expiresAt,
+				}, nil)
+			},
+			want: GetDataExportOutput{
+				IsPending: true,
+				ExpiresAt: expiresAt,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, failed",
+			args: args{
+				input: GetDataExportInput{
+					Id:       2,
+					ExportId: 9,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetDataExport(gomock.Any(), gomock.Any()).Return(repository.GetDataExportOutput{
+					Id:     9,
+					Format: DATA_EXPORT_FORMAT_JSON,
+					Status: repository.DATA_EXPORT_STATUS_FAILED,
+				}, nil)
+			},
+			want: GetDataExportOutput{
+				IsFailed: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, ready",
+			args: args{
+				input: GetDataExportInput{
+					Id:       2,
+					ExportId: 9,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetDataExport(gomock.Any(), gomock.Eq(repository.GetDataExportInput{
+					Id:     9,
+					UserId: 2,
+				})).Return(repository.GetDataExportOutput{
+					Id:        9,
+					Format:    DATA_EXPORT_FORMAT_NDJSON,
+					Status:    repository.DATA_EXPORT_STATUS_READY,
+					Content:   []byte("PK"),
+					ExpiresAt: expiresAt,
+				}, nil)
+			},
+			want: GetDataExportOutput{
+				ExpiresAt: expiresAt,
+				File: DataExportFile{
+					FileName:    "user-data-2.zip",
+					ContentType: "application/zip",
+					Content:     []byte("PK"),
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+			})
+			got, err := u.GetDataExport(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.GetDataExport() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.GetDataExport() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_PurgeDataExports(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	tests := []struct {
+		name     string
+		mockFunc func()
+		want     PurgeDataExportsOutput
+		wantErr  bool
+	}{
+		{
+			name: "error when DeleteExpiredDataExports",
+			mockFunc: func() {
+				mockRepository.EXPECT().DeleteExpiredDataExports(gomock.Any(), gomock.Any()).Return(repository.DeleteExpiredDataExportsOutput{}, errors.New("test"))
+			},
+			want:    PurgeDataExportsOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			mockFunc: func() {
+				mockRepository.EXPECT().DeleteExpiredDataExports(gomock.Any(), gomock.Any()).Return(repository.DeleteExpiredDataExportsOutput{Count: 3}, nil)
+			},
+			want: PurgeDataExportsOutput{
+				Count: 3,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc()
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+			})
+			got, err := u.PurgeDataExports(context.Background(), PurgeDataExportsInput{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.PurgeDataExports() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.PurgeDataExports() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// testImage returns a PNG of width x height, its left half transparent.
+func testImage(t *testing.T, width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := width / 2; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: 200, G: 20, B: 20, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// testPNGHeader returns a PNG of width by height pixels made of its header
+// only, a few bytes that claim a huge image.
+func testPNGHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], width)
+	binary.BigEndian.PutUint32(ihdr[8:], height)
+	// 8 bit RGBA, no interlacing
+	ihdr[12], ihdr[13] = 8, 6
+
+	content := []byte("\x89PNG\x0d\x0a\x1a\x0a")
+	content = binary.BigEndian.AppendUint32(content, uint32(len(ihdr)-4))
+	content = append(content, ihdr...)
+	content = binary.BigEndian.AppendUint32(content, crc32.ChecksumIEEE(ihdr))
+
+	return content
+}
+
+// putAvatar stores the objects of the avatar key in storage.
+func putAvatar(t *testing.T, storage utils.ObjectStorage, key string) {
+	for _, object := range avatarObjects {
+		if err := storage.Put(context.Background(), key+"/"+object.name, utils.IMAGE_TYPE_JPEG, []byte("jpeg")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// storedFiles returns the files below dir, by slash separated path.
+func storedFiles(t *testing.T, dir string) map[string][]byte {
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		rel, _ := filepath.Rel(dir, name)
+		files[filepath.ToSlash(rel)] = content
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+// checkStoredAvatar fails unless files hold the sizes of the avatar key as
+// JPEG.
+func checkStoredAvatar(t *testing.T, files map[string][]byte, key string) {
+	for _, object := range avatarObjects {
+		content, ok := files[key+"/"+object.name]
+		if !ok {
+			t.Errorf("avatar %s/%s not stored", key, object.name)
+			continue
+		}
+
+		config, err := jpeg.DecodeConfig(bytes.NewReader(content))
+		if err != nil {
+			t.Errorf("avatar %s/%s: %v", key, object.name, err)
+			continue
+		}
+
+		if config.Width != object.size || config.Height != object.size {
+			t.Errorf("avatar %s/%s is %dx%d, want %d", key, object.name, config.Width, config.Height, object.size)
+		}
+	}
+}
+
+// avatarKeyOf returns the avatar key of the URLs of storage.
+func avatarKeyOf(storage *utils.LocalObjectStorage, urls AvatarURLs) string {
+	return strings.TrimSuffix(strings.TrimPrefix(urls.Large, storage.BaseURL+"/"), "/large.jpg")
+}
+
+func TestUsecase_SetAvatar(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	tests := []struct {
+		name     string
+		content  []byte
+		maxBytes int64
+		mockFunc func(storage *utils.LocalObjectStorage)
+		want     SetAvatarOutput
+		// wantKey is whether the returned avatar is stored
+		wantKey bool
+		// wantFiles are the files kept besides the returned avatar
+		wantFiles []string
+		wantErr   bool
+	}{
+		{
+			name:     "too large",
+			content:  bytes.Repeat([]byte{0}, 1025),
+			maxBytes: 1024,
+			mockFunc: func(storage *utils.LocalObjectStorage) {
+			},
+			want: SetAvatarOutput{
+				IsTooLarge: true,
+				MaxBytes:   1024,
+			},
+		},
+		{
+			name:    "type not supported",
+			content: []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"),
+			mockFunc: func(storage *utils.LocalObjectStorage) {
+			},
+			want: SetAvatarOutput{
+				IsTypeNotSupported: true,
+			},
+		},
+		{
+			name:    "type not supported when corrupt",
+			content: append([]byte("\x89PNG\x0d\x0a\x1a\x0a"), "broken"...),
+			mockFunc: func(storage *utils.LocalObjectStorage) {
+			},
+			want: SetAvatarOutput{
+				IsTypeNotSupported: true,
+			},
+		},
+		{
+			name:    "dimensions invalid when too small",
+			content: testImage(t, 100, AVATAR_SIZE_SMALL-1),
+			mockFunc: func(storage *utils.LocalObjectStorage) {
+			},
+			want: SetAvatarOutput{
+				IsDimensionsInvalid: true,
+			},
+		},
+		{
+			name:    "dimensions invalid when too large",
+			content: testImage(t, AVATAR_MAX_SIDE+1, AVATAR_SIZE_SMALL),
+			mockFunc: func(storage *utils.LocalObjectStorage) {
+			},
+			want: SetAvatarOutput{
+				IsDimensionsInvalid: true,
+			},
+		},
+		{
+			name:    "dimensions invalid when a small upload claims a huge image",
+			content: testPNGHeader(60000, 60000),
+			mockFunc: func(storage *utils.LocalObjectStorage) {
+			},
+			want: SetAvatarOutput{
+				IsDimensionsInvalid: true,
+			},
+		},
+		{
+			name:    "error when UpdateAvatarKeyById",
+			content: testImage(t, 300, 200),
+			mockFunc: func(storage *utils.LocalObjectStorage) {
+				mockRepository.EXPECT().UpdateAvatarKeyById(gomock.Any(), gomock.Any()).Return(repository.UpdateAvatarKeyByIdOutput{}, errors.New("test"))
+			},
+			want:    SetAvatarOutput{},
+			wantErr: true,
+		},
+		{
+			name:    "success",
+			content: testImage(t, 300, 200),
+			mockFunc: func(storage *utils.LocalObjectStorage) {
+				mockRepository.EXPECT().UpdateAvatarKeyById(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, input repository.UpdateAvatarKeyByIdInput) (repository.UpdateAvatarKeyByIdOutput, error) {
+					if input.Id != 11 || !strings.HasPrefix(input.AvatarKey, "avatars/11/") {
+						t.Errorf("UpdateAvatarKeyById() input = %v", input)
+					}
+					return repository.UpdateAvatarKeyByIdOutput{}, nil
+				})
+			},
+			wantKey: true,
+		},
+		{
+			name:    "success replacing the previous avatar",
+			content: testImage(t, 64, 900),
+			mockFunc: func(storage *utils.LocalObjectStorage) {
+				putAvatar(t, storage, "avatars/11/previous")
+				putAvatar(t, storage, "avatars/12/other")
+				mockRepository.EXPECT().UpdateAvatarKeyById(gomock.Any(), gomock.Any()).Return(repository.UpdateAvatarKeyByIdOutput{
+					PreviousAvatarKey: "avatars/11/previous",
+				}, nil)
+			},
+			wantKey:   true,
+			wantFiles: []string{"avatars/12/other/large.jpg", "avatars/12/other/medium.jpg", "avatars/12/other/small.jpg"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &utils.LocalObjectStorage{
+				Dir:     t.TempDir(),
+				BaseURL: "http://localhost/storage",
+			}
+			tt.mockFunc(storage)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository:     mockRepository,
+				ObjectStorage:  storage,
+				AvatarMaxBytes: tt.maxBytes,
+			})
+			got, err := u.SetAvatar(context.Background(), SetAvatarInput{
+				Id:      11,
+				Content: bytes.NewReader(tt.content),
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.SetAvatar() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			files := storedFiles(t, storage.Dir)
+			if tt.wantKey {
+				key := avatarKeyOf(storage, got.Avatar)
+				if !reflect.DeepEqual(got.Avatar, u.avatarURLs(key)) {
+					t.Errorf("Usecase.SetAvatar() avatar = %v", got.Avatar)
+				}
+				checkStoredAvatar(t, files, key)
+				for _, object := range avatarObjects {
+					delete(files, key+"/"+object.name)
+				}
+				got.Avatar = AvatarURLs{}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.SetAvatar() = %v, want %v", got, tt.want)
+			}
+
+			names := make([]string, 0, len(files))
+			for name := range files {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if len(names) != 0 || len(tt.wantFiles) != 0 {
+				assert.Equal(t, tt.wantFiles, names)
+			}
+		})
+	}
+}
+
+func TestUsecase_SetAvatar_s3(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	// a stand-in for an S3 compatible server with path style buckets
+	var (
+		mu      sync.Mutex
+		objects = make(map[string][]byte)
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(body)
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") ||
+			r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		key := strings.TrimPrefix(r.URL.Path, "/avatars-bucket/")
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			if r.Header.Get("Content-Type") != utils.IMAGE_TYPE_JPEG {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			objects[key] = body
+			w.WriteHeader(http.StatusOK)
+		case http.MethodDelete:
+			delete(objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	storage := &utils.S3ObjectStorage{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          "avatars-bucket",
+		AccessKeyId:     "access",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+	}
+	putAvatar(t, storage, "avatars/11/previous")
+
+	mockRepository.EXPECT().UpdateAvatarKeyById(gomock.Any(), gomock.Any()).Return(repository.UpdateAvatarKeyByIdOutput{
+		PreviousAvatarKey: "avatars/11/previous",
+	}, nil)
+
+	u := NewUsecase(NewUsecaseOptions{
+		Repository:    mockRepository,
+		ObjectStorage: storage,
+	})
+	got, err := u.SetAvatar(context.Background(), SetAvatarInput{
+		Id:      11,
+		Content: bytes.NewReader(testImage(t, 600, 600)),
+	})
+	if err != nil {
+		t.Fatalf("Usecase.SetAvatar() error = %v", err)
+	}
+
+	prefix := server.URL + "/avatars-bucket/"
+	if !strings.HasPrefix(got.Avatar.Large, prefix) {
+		t.Fatalf("Usecase.SetAvatar() avatar = %v", got.Avatar)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	key := strings.TrimSuffix(strings.TrimPrefix(got.Avatar.Large, prefix), "/large.jpg")
+	checkStoredAvatar(t, objects, key)
+	if len(objects) != len(avatarObjects) {
+		t.Errorf("Usecase.SetAvatar() left %d objects, want %d", len(objects), len(avatarObjects))
+	}
+}
+
+func TestUsecase_RemoveAvatar(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+	storage := &utils.LocalObjectStorage{
+		Dir:     t.TempDir(),
+		BaseURL: "http://localhost/storage",
+	}
+
+	tests := []struct {
+		name     string
+		mockFunc func()
+		want     RemoveAvatarOutput
+		wantErr  bool
+	}{
+		{
+			name: "error when UpdateAvatarKeyById",
+			mockFunc: func() {
+				mockRepository.EXPECT().UpdateAvatarKeyById(gomock.Any(), gomock.Any()).Return(repository.UpdateAvatarKeyByIdOutput{}, errors.New("test"))
+			},
+			want:    RemoveAvatarOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success without avatar",
+			mockFunc: func() {
+				mockRepository.EXPECT().UpdateAvatarKeyById(gomock.Any(), gomock.Eq(repository.UpdateAvatarKeyByIdInput{
+					Id: 11,
+				})).Return(repository.UpdateAvatarKeyByIdOutput{}, nil)
+			},
+			want:    RemoveAvatarOutput{},
+			wantErr: false,
+		},
+		{
+			name: "success",
+			mockFunc: func() {
+				putAvatar(t, storage, "avatars/11/abc")
+				mockRepository.EXPECT().UpdateAvatarKeyById(gomock.Any(), gomock.Eq(repository.UpdateAvatarKeyByIdInput{
+					Id: 11,
+				})).Return(repository.UpdateAvatarKeyByIdOutput{
+					PreviousAvatarKey: "avatars/11/abc",
+				}, nil)
+			},
+			want:    RemoveAvatarOutput{},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc()
+			u := NewUsecase(NewUsecaseOptions{
+				Repository:    mockRepository,
+				ObjectStorage: storage,
+			})
+			got, err := u.RemoveAvatar(context.Background(), RemoveAvatarInput{
+				Id: 11,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.RemoveAvatar() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.RemoveAvatar() = %v, want %v", got, tt.want)
+			}
+			if files := storedFiles(t, storage.Dir); len(files) != 0 {
+				t.Errorf("Usecase.RemoveAvatar() left %v", files)
+			}
+		})
+	}
+}
+
+// testAttributeSchema has a read-only attribute and one users may change.
+const testAttributeSchema = `{
+	"type": "object",
+	"additionalProperties": false,
+	"properties": {
+		"employee_id": {"type": "string", "readOnly": true},
+		"shoe_size": {"type": "integer", "minimum": 30}
+	}
+}`
+
+func TestUsecase_GetAttributeSchema(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	tests := []struct {
+		name     string
+		mockFunc func()
+		want     GetAttributeSchemaOutput
+		wantErr  bool
+	}{
+		{
+			name: "error when GetLatestAttributeSchema",
+			mockFunc: func() {
+				mockRepository.EXPECT().GetLatestAttributeSchema(gomock.Any(), gomock.Any()).Return(repository.GetLatestAttributeSchemaOutput{}, errors.New("test"))
+			},
+			want:    GetAttributeSchemaOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, default schema",
+			mockFunc: func() {
+				mockRepository.EXPECT().GetLatestAttributeSchema(gomock.Any(), gomock.Any()).Return(repository.GetLatestAttributeSchemaOutput{}, sql.ErrNoRows)
+			},
+			want: GetAttributeSchemaOutput{
+				Schema: map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": false,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "success",
+			mockFunc: func() {
+				mockRepository.EXPECT().GetLatestAttributeSchema(gomock.Any(), gomock.Any()).Return(repository.GetLatestAttributeSchemaOutput{
+					Schema: []byte(`{"type": "object", "properties": {"shoe_size": {"type": "integer"}}}`),
+				}, nil)
+			},
+			want: GetAttributeSchemaOutput{
+				Schema: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"shoe_size": map[string]interface{}{"type": "integer"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc()
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+			})
+			got, err := u.GetAttributeSchema(context.Background(), GetAttributeSchemaInput{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.GetAttributeSchema() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.GetAttributeSchema() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_SetAttributeSchema(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"shoe_size": map[string]interface{}{"type": "integer"},
+		},
+	}
+
+	type args struct {
+		input SetAttributeSchemaInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     SetAttributeSchemaOutput
+		wantErr  bool
+	}{
+		{
+			name: "success, schema not of an object",
+			args: args{
+				input: SetAttributeSchemaInput{
+					Schema:    map[string]interface{}{"type": "string"},
+					UpdatedBy: 1,
+				},
+			},
+			mockFunc: func(a args) {},
+			want: SetAttributeSchemaOutput{
+				IsInvalid: true,
+				Reason:    `the schema must be of type "object"`,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, invalid schema",
+			args: args{
+				input: SetAttributeSchemaInput{
+					Schema: map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"shoe_size": map[string]interface{}{"type": "whole number"},
+						},
+					},
+					UpdatedBy: 1,
+				},
+			},
+			mockFunc: func(a args) {},
+			want: SetAttributeSchemaOutput{
+				IsInvalid: true,
+				Reason:    `unsupported 'type' value "whole number"`,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error when InsertAttributeSchema",
+			args: args{
+				input: SetAttributeSchemaInput{
+					Schema:    schema,
+					UpdatedBy: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().InsertAttributeSchema(gomock.Any(), gomock.Any()).Return(repository.InsertAttributeSchemaOutput{}, errors.New("test"))
+			},
+			want:    SetAttributeSchemaOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			args: args{
+				input: SetAttributeSchemaInput{
+					Schema:    schema,
+					UpdatedBy: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().InsertAttributeSchema(gomock.Any(), gomock.Eq(repository.InsertAttributeSchemaInput{
+					Schema:    []byte(`{"properties":{"shoe_size":{"type":"integer"}},"type":"object"}`),
+					CreatedBy: 1,
+				})).Return(repository.InsertAttributeSchemaOutput{Id: 2}, nil)
+			},
+			want:    SetAttributeSchemaOutput{},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc(tt.args)
+			u := NewUsecase(NewUsecaseOptions{
+				Repository: mockRepository,
+			})
+			got, err := u.SetAttributeSchema(context.Background(), tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Usecase.SetAttributeSchema() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Usecase.SetAttributeSchema() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsecase_SetUserAttributes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepository := repository.NewMockRepositoryInterface(ctrl)
+
+	type args struct {
+		input SetUserAttributesInput
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockFunc func(args)
+		want     SetUserAttributesOutput
+		wantErr  bool
+	}{
+		{
+			name: "error when GetLatestAttributeSchema",
+			args: args{
+				input: SetUserAttributesInput{
+					Id:        10,
+					UpdatedBy: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetLatestAttributeSchema(gomock.Any(), gomock.Any()).Return(repository.GetLatestAttributeSchemaOutput{}, errors.New("test"))
+			},
+			want:    SetUserAttributesOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, invalid attributes",
+			args: args{
+				input: SetUserAttributesInput{
+					Id: 10,
+					Attributes: map[string]interface{}{
+						"shoe_size": float64(12),
+						"extra":     true,
+					},
+					UpdatedBy: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetLatestAttributeSchema(gomock.Any(), gomock.Any()).Return(repository.GetLatestAttributeSchemaOutput{
+					Schema: []byte(testAttributeSchema),
+				}, nil)
+			},
+			want: SetUserAttributesOutput{
+				AttributeErrors: []AttributeError{
+					{Reason: `property "extra" is unsupported`},
+					{Path: []string{"shoe_size"}, Reason: `number must be at least 30`},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "success, not found",
+			args: args{
+				input: SetUserAttributesInput{
+					Id:        10,
+					UpdatedBy: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetLatestAttributeSchema(gomock.Any(), gomock.Any()).Return(repository.GetLatestAttributeSchemaOutput{
+					Schema: []byte(testAttributeSchema),
 				}, nil)
 
-				mockRepository.EXPECT().UpdateUserData(gomock.Any(), gomock.Eq(repository.UpdateUserDataInput{
-					Id:          a.input.Id,
-					PhoneNumber: a.input.PhoneNumber,
-					FullName:    a.input.FullName,
-				})).Return(repository.UpdateUserDataOutput{
-					IsPhoneNumberExists: true,
+				mockRepository.EXPECT().UpdateAttributesById(gomock.Any(), gomock.Eq(repository.UpdateAttributesByIdInput{
+					Id:         a.input.Id,
+					Attributes: []byte(`{}`),
+					UpdatedBy:  a.input.UpdatedBy,
+				})).Return(sql.ErrNoRows)
+			},
+			want: SetUserAttributesOutput{
+				IsNotFound: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error when UpdateAttributesById",
+			args: args{
+				input: SetUserAttributesInput{
+					Id:        10,
+					UpdatedBy: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetLatestAttributeSchema(gomock.Any(), gomock.Any()).Return(repository.GetLatestAttributeSchemaOutput{
+					Schema: []byte(testAttributeSchema),
 				}, nil)
+
+				mockRepository.EXPECT().UpdateAttributesById(gomock.Any(), gomock.Any()).Return(errors.New("test"))
 			},
-			want: UpdateUserDataOutput{
-				IsPhoneNumberExists: true,
+			want:    SetUserAttributesOutput{},
+			wantErr: true,
+		},
+		{
+			name: "success, read-only attributes can be set",
+			args: args{
+				input: SetUserAttributesInput{
+					Id: 10,
+					Attributes: map[string]interface{}{
+						"employee_id": "E-2",
+						"shoe_size":   float64(42),
+					},
+					UpdatedBy: 1,
+				},
+			},
+			mockFunc: func(a args) {
+				mockRepository.EXPECT().GetLatestAttributeSchema(gomock.Any(), gomock.Any()).Return(repository.GetLatestAttributeSchemaOutput{
+					Schema: []byte(testAttributeSchema),
+				}, nil)
+
+				mockRepository.EXPECT().UpdateAttributesById(gomock.Any(), gomock.Eq(repository.UpdateAttributesByIdInput{
+					Id:         a.input.Id,
+					Attributes: []byte(`{"employee_id":"E-2","shoe_size":42}`),
+					UpdatedBy:  a.input.UpdatedBy,
+				})).Return(nil)
 			},
+			want:    SetUserAttributesOutput{},
 			wantErr: false,
 		},
 	}
@@ -430,13 +8104,13 @@ func TestUsecase_UpdateUserData(t *testing.T) {
 			u := NewUsecase(NewUsecaseOptions{
 				Repository: mockRepository,
 			})
-			got, err := u.UpdateUserData(context.Background(), tt.args.input)
+			got, err := u.SetUserAttributes(context.Background(), tt.args.input)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("Usecase.UpdateUserData() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("Usecase.SetUserAttributes() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("Usecase.UpdateUserData() = %v, want %v", got, tt.want)
+				t.Errorf("Usecase.SetUserAttributes() = %v, want %v", got, tt.want)
 			}
 		})
 	}
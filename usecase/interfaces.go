@@ -13,4 +13,44 @@ type UsecaseInterface interface {
 	Login(ctx context.Context, input LoginInput) (LoginOutput, error)
 	GetUserData(ctx context.Context, input GetUserDataInput) (GetUserDataOutput, error)
 	UpdateUserData(ctx context.Context, input UpdateUserDataInput) (UpdateUserDataOutput, error)
+	PatchUserData(ctx context.Context, input PatchUserDataInput) (PatchUserDataOutput, error)
+	SetPassword(ctx context.Context, input SetPasswordInput) (SetPasswordOutput, error)
+	SetExpiredPassword(ctx context.Context, input SetExpiredPasswordInput) (SetExpiredPasswordOutput, error)
+	SendEmailVerification(ctx context.Context, input SendEmailVerificationInput) (SendEmailVerificationOutput, error)
+	VerifyEmailByToken(ctx context.Context, input VerifyEmailByTokenInput) (VerifyEmailOutput, error)
+	VerifyEmailByCode(ctx context.Context, input VerifyEmailByCodeInput) (VerifyEmailOutput, error)
+	GetIdentities(ctx context.Context, input GetIdentitiesInput) (GetIdentitiesOutput, error)
+	AddIdentity(ctx context.Context, input AddIdentityInput) (AddIdentityOutput, error)
+	RemoveIdentity(ctx context.Context, input RemoveIdentityInput) (RemoveIdentityOutput, error)
+	SendIdentityVerification(ctx context.Context, input SendIdentityVerificationInput) (SendIdentityVerificationOutput, error)
+	StartOIDCLogin(ctx context.Context, input StartOIDCLoginInput) (StartOIDCLoginOutput, error)
+	FinishOIDCLogin(ctx context.Context, input FinishOIDCLoginInput) (FinishOIDCLoginOutput, error)
+	HasPermission(ctx context.Context, input HasPermissionInput) (HasPermissionOutput, error)
+	SearchUsers(ctx context.Context, input SearchUsersInput) (SearchUsersOutput, error)
+	GetUserDetails(ctx context.Context, input GetUserDetailsInput) (GetUserDetailsOutput, error)
+	GetUserStatus(ctx context.Context, input GetUserStatusInput) (GetUserStatusOutput, error)
+	ChangeUserStatus(ctx context.Context, input ChangeUserStatusInput) (ChangeUserStatusOutput, error)
+	DeactivateUser(ctx context.Context, input DeactivateUserInput) (DeactivateUserOutput, error)
+	GetUserStatusChanges(ctx context.Context, input GetUserStatusChangesInput) (GetUserStatusChangesOutput, error)
+	ImpersonateUser(ctx context.Context, input ImpersonateUserInput) (ImpersonateUserOutput, error)
+	RecordImpersonationUse(ctx context.Context, input RecordImpersonationUseInput) (RecordImpersonationUseOutput, error)
+	DeleteUser(ctx context.Context, input DeleteUserInput) (DeleteUserOutput, error)
+	RestoreUser(ctx context.Context, input RestoreUserInput) (RestoreUserOutput, error)
+	PurgeDeletedUsers(ctx context.Context, input PurgeDeletedUsersInput) (PurgeDeletedUsersOutput, error)
+	ExportUserData(ctx context.Context, input ExportUserDataInput) (ExportUserDataOutput, error)
+	GetDataExport(ctx context.Context, input GetDataExportInput) (GetDataExportOutput, error)
+	PurgeDataExports(ctx context.Context, input PurgeDataExportsInput) (PurgeDataExportsOutput, error)
+	SetAvatar(ctx context.Context, input SetAvatarInput) (SetAvatarOutput, error)
+	RemoveAvatar(ctx context.Context, input RemoveAvatarInput) (RemoveAvatarOutput, error)
+	GetAttributeSchema(ctx context.Context, input GetAttributeSchemaInput) (GetAttributeSchemaOutput, error)
+	SetAttributeSchema(ctx context.Context, input SetAttributeSchemaInput) (SetAttributeSchemaOutput, error)
+	SetUserAttributes(ctx context.Context, input SetUserAttributesInput) (SetUserAttributesOutput, error)
+}
+
+// Authenticator verifies the password of a login. Login asks the first
+// authenticator that serves the identifier and falls back to the password
+// stored in users.
+type Authenticator interface {
+	Serves(input LoginInput) bool
+	Authenticate(ctx context.Context, input LoginInput) (AuthenticateOutput, error)
 }
@@ -34,6 +34,141 @@ func (m *MockUsecaseInterface) EXPECT() *MockUsecaseInterfaceMockRecorder {
 	return m.recorder
 }
 
+// AddIdentity mocks base method.
+func (m *MockUsecaseInterface) AddIdentity(ctx context.Context, input AddIdentityInput) (AddIdentityOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddIdentity", ctx, input)
+	ret0, _ := ret[0].(AddIdentityOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddIdentity indicates an expected call of AddIdentity.
+func (mr *MockUsecaseInterfaceMockRecorder) AddIdentity(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddIdentity", reflect.TypeOf((*MockUsecaseInterface)(nil).AddIdentity), ctx, input)
+}
+
+// ChangeUserStatus mocks base method.
+func (m *MockUsecaseInterface) ChangeUserStatus(ctx context.Context, input ChangeUserStatusInput) (ChangeUserStatusOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangeUserStatus", ctx, input)
+	ret0, _ := ret[0].(ChangeUserStatusOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangeUserStatus indicates an expected call of ChangeUserStatus.
+func (mr *MockUsecaseInterfaceMockRecorder) ChangeUserStatus(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeUserStatus", reflect.TypeOf((*MockUsecaseInterface)(nil).ChangeUserStatus), ctx, input)
+}
+
+// DeactivateUser mocks base method.
+func (m *MockUsecaseInterface) DeactivateUser(ctx context.Context, input DeactivateUserInput) (DeactivateUserOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeactivateUser", ctx, input)
+	ret0, _ := ret[0].(DeactivateUserOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeactivateUser indicates an expected call of DeactivateUser.
+func (mr *MockUsecaseInterfaceMockRecorder) DeactivateUser(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeactivateUser", reflect.TypeOf((*MockUsecaseInterface)(nil).DeactivateUser), ctx, input)
+}
+
+// DeleteUser mocks base method.
+func (m *MockUsecaseInterface) DeleteUser(ctx context.Context, input DeleteUserInput) (DeleteUserOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, input)
+	ret0, _ := ret[0].(DeleteUserOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockUsecaseInterfaceMockRecorder) DeleteUser(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUsecaseInterface)(nil).DeleteUser), ctx, input)
+}
+
+// ExportUserData mocks base method.
+func (m *MockUsecaseInterface) ExportUserData(ctx context.Context, input ExportUserDataInput) (ExportUserDataOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportUserData", ctx, input)
+	ret0, _ := ret[0].(ExportUserDataOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExportUserData indicates an expected call of ExportUserData.
+func (mr *MockUsecaseInterfaceMockRecorder) ExportUserData(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportUserData", reflect.TypeOf((*MockUsecaseInterface)(nil).ExportUserData), ctx, input)
+}
+
+// FinishOIDCLogin mocks base method.
+func (m *MockUsecaseInterface) FinishOIDCLogin(ctx context.Context, input FinishOIDCLoginInput) (FinishOIDCLoginOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishOIDCLogin", ctx, input)
+	ret0, _ := ret[0].(FinishOIDCLoginOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FinishOIDCLogin indicates an expected call of FinishOIDCLogin.
+func (mr *MockUsecaseInterfaceMockRecorder) FinishOIDCLogin(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishOIDCLogin", reflect.TypeOf((*MockUsecaseInterface)(nil).FinishOIDCLogin), ctx, input)
+}
+
+// GetAttributeSchema mocks base method.
+func (m *MockUsecaseInterface) GetAttributeSchema(ctx context.Context, input GetAttributeSchemaInput) (GetAttributeSchemaOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAttributeSchema", ctx, input)
+	ret0, _ := ret[0].(GetAttributeSchemaOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAttributeSchema indicates an expected call of GetAttributeSchema.
+func (mr *MockUsecaseInterfaceMockRecorder) GetAttributeSchema(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAttributeSchema", reflect.TypeOf((*MockUsecaseInterface)(nil).GetAttributeSchema), ctx, input)
+}
+
+// GetDataExport mocks base method.
+func (m *MockUsecaseInterface) GetDataExport(ctx context.Context, input GetDataExportInput) (GetDataExportOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDataExport", ctx, input)
+	ret0, _ := ret[0].(GetDataExportOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDataExport indicates an expected call of GetDataExport.
+func (mr *MockUsecaseInterfaceMockRecorder) GetDataExport(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDataExport", reflect.TypeOf((*MockUsecaseInterface)(nil).GetDataExport), ctx, input)
+}
+
+// GetIdentities mocks base method.
+func (m *MockUsecaseInterface) GetIdentities(ctx context.Context, input GetIdentitiesInput) (GetIdentitiesOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdentities", ctx, input)
+	ret0, _ := ret[0].(GetIdentitiesOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdentities indicates an expected call of GetIdentities.
+func (mr *MockUsecaseInterfaceMockRecorder) GetIdentities(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdentities", reflect.TypeOf((*MockUsecaseInterface)(nil).GetIdentities), ctx, input)
+}
+
 // GetUserData mocks base method.
 func (m *MockUsecaseInterface) GetUserData(ctx context.Context, input GetUserDataInput) (GetUserDataOutput, error) {
 	m.ctrl.T.Helper()
@@ -49,6 +184,81 @@ func (mr *MockUsecaseInterfaceMockRecorder) GetUserData(ctx, input interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserData", reflect.TypeOf((*MockUsecaseInterface)(nil).GetUserData), ctx, input)
 }
 
+// GetUserDetails mocks base method.
+func (m *MockUsecaseInterface) GetUserDetails(ctx context.Context, input GetUserDetailsInput) (GetUserDetailsOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserDetails", ctx, input)
+	ret0, _ := ret[0].(GetUserDetailsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserDetails indicates an expected call of GetUserDetails.
+func (mr *MockUsecaseInterfaceMockRecorder) GetUserDetails(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserDetails", reflect.TypeOf((*MockUsecaseInterface)(nil).GetUserDetails), ctx, input)
+}
+
+// GetUserStatus mocks base method.
+func (m *MockUsecaseInterface) GetUserStatus(ctx context.Context, input GetUserStatusInput) (GetUserStatusOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserStatus", ctx, input)
+	ret0, _ := ret[0].(GetUserStatusOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserStatus indicates an expected call of GetUserStatus.
+func (mr *MockUsecaseInterfaceMockRecorder) GetUserStatus(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserStatus", reflect.TypeOf((*MockUsecaseInterface)(nil).GetUserStatus), ctx, input)
+}
+
+// GetUserStatusChanges mocks base method.
+func (m *MockUsecaseInterface) GetUserStatusChanges(ctx context.Context, input GetUserStatusChangesInput) (GetUserStatusChangesOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserStatusChanges", ctx, input)
+	ret0, _ := ret[0].(GetUserStatusChangesOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserStatusChanges indicates an expected call of GetUserStatusChanges.
+func (mr *MockUsecaseInterfaceMockRecorder) GetUserStatusChanges(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserStatusChanges", reflect.TypeOf((*MockUsecaseInterface)(nil).GetUserStatusChanges), ctx, input)
+}
+
+// HasPermission mocks base method.
+func (m *MockUsecaseInterface) HasPermission(ctx context.Context, input HasPermissionInput) (HasPermissionOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasPermission", ctx, input)
+	ret0, _ := ret[0].(HasPermissionOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HasPermission indicates an expected call of HasPermission.
+func (mr *MockUsecaseInterfaceMockRecorder) HasPermission(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasPermission", reflect.TypeOf((*MockUsecaseInterface)(nil).HasPermission), ctx, input)
+}
+
+// ImpersonateUser mocks base method.
+func (m *MockUsecaseInterface) ImpersonateUser(ctx context.Context, input ImpersonateUserInput) (ImpersonateUserOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImpersonateUser", ctx, input)
+	ret0, _ := ret[0].(ImpersonateUserOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImpersonateUser indicates an expected call of ImpersonateUser.
+func (mr *MockUsecaseInterfaceMockRecorder) ImpersonateUser(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImpersonateUser", reflect.TypeOf((*MockUsecaseInterface)(nil).ImpersonateUser), ctx, input)
+}
+
 // Login mocks base method.
 func (m *MockUsecaseInterface) Login(ctx context.Context, input LoginInput) (LoginOutput, error) {
 	m.ctrl.T.Helper()
@@ -64,6 +274,66 @@ func (mr *MockUsecaseInterfaceMockRecorder) Login(ctx, input interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockUsecaseInterface)(nil).Login), ctx, input)
 }
 
+// PatchUserData mocks base method.
+func (m *MockUsecaseInterface) PatchUserData(ctx context.Context, input PatchUserDataInput) (PatchUserDataOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchUserData", ctx, input)
+	ret0, _ := ret[0].(PatchUserDataOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PatchUserData indicates an expected call of PatchUserData.
+func (mr *MockUsecaseInterfaceMockRecorder) PatchUserData(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchUserData", reflect.TypeOf((*MockUsecaseInterface)(nil).PatchUserData), ctx, input)
+}
+
+// PurgeDataExports mocks base method.
+func (m *MockUsecaseInterface) PurgeDataExports(ctx context.Context, input PurgeDataExportsInput) (PurgeDataExportsOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDataExports", ctx, input)
+	ret0, _ := ret[0].(PurgeDataExportsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDataExports indicates an expected call of PurgeDataExports.
+func (mr *MockUsecaseInterfaceMockRecorder) PurgeDataExports(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDataExports", reflect.TypeOf((*MockUsecaseInterface)(nil).PurgeDataExports), ctx, input)
+}
+
+// PurgeDeletedUsers mocks base method.
+func (m *MockUsecaseInterface) PurgeDeletedUsers(ctx context.Context, input PurgeDeletedUsersInput) (PurgeDeletedUsersOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeletedUsers", ctx, input)
+	ret0, _ := ret[0].(PurgeDeletedUsersOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeletedUsers indicates an expected call of PurgeDeletedUsers.
+func (mr *MockUsecaseInterfaceMockRecorder) PurgeDeletedUsers(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeletedUsers", reflect.TypeOf((*MockUsecaseInterface)(nil).PurgeDeletedUsers), ctx, input)
+}
+
+// RecordImpersonationUse mocks base method.
+func (m *MockUsecaseInterface) RecordImpersonationUse(ctx context.Context, input RecordImpersonationUseInput) (RecordImpersonationUseOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordImpersonationUse", ctx, input)
+	ret0, _ := ret[0].(RecordImpersonationUseOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordImpersonationUse indicates an expected call of RecordImpersonationUse.
+func (mr *MockUsecaseInterfaceMockRecorder) RecordImpersonationUse(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordImpersonationUse", reflect.TypeOf((*MockUsecaseInterface)(nil).RecordImpersonationUse), ctx, input)
+}
+
 // RegisterNewUser mocks base method.
 func (m *MockUsecaseInterface) RegisterNewUser(ctx context.Context, input RegisterNewUserInput) (RegisterNewUserOutput, error) {
 	m.ctrl.T.Helper()
@@ -79,6 +349,186 @@ func (mr *MockUsecaseInterfaceMockRecorder) RegisterNewUser(ctx, input interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterNewUser", reflect.TypeOf((*MockUsecaseInterface)(nil).RegisterNewUser), ctx, input)
 }
 
+// RemoveAvatar mocks base method.
+func (m *MockUsecaseInterface) RemoveAvatar(ctx context.Context, input RemoveAvatarInput) (RemoveAvatarOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveAvatar", ctx, input)
+	ret0, _ := ret[0].(RemoveAvatarOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveAvatar indicates an expected call of RemoveAvatar.
+func (mr *MockUsecaseInterfaceMockRecorder) RemoveAvatar(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveAvatar", reflect.TypeOf((*MockUsecaseInterface)(nil).RemoveAvatar), ctx, input)
+}
+
+// RemoveIdentity mocks base method.
+func (m *MockUsecaseInterface) RemoveIdentity(ctx context.Context, input RemoveIdentityInput) (RemoveIdentityOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveIdentity", ctx, input)
+	ret0, _ := ret[0].(RemoveIdentityOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveIdentity indicates an expected call of RemoveIdentity.
+func (mr *MockUsecaseInterfaceMockRecorder) RemoveIdentity(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveIdentity", reflect.TypeOf((*MockUsecaseInterface)(nil).RemoveIdentity), ctx, input)
+}
+
+// RestoreUser mocks base method.
+func (m *MockUsecaseInterface) RestoreUser(ctx context.Context, input RestoreUserInput) (RestoreUserOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreUser", ctx, input)
+	ret0, _ := ret[0].(RestoreUserOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreUser indicates an expected call of RestoreUser.
+func (mr *MockUsecaseInterfaceMockRecorder) RestoreUser(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreUser", reflect.TypeOf((*MockUsecaseInterface)(nil).RestoreUser), ctx, input)
+}
+
+// SearchUsers mocks base method.
+func (m *MockUsecaseInterface) SearchUsers(ctx context.Context, input SearchUsersInput) (SearchUsersOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchUsers", ctx, input)
+	ret0, _ := ret[0].(SearchUsersOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchUsers indicates an expected call of SearchUsers.
+func (mr *MockUsecaseInterfaceMockRecorder) SearchUsers(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsers", reflect.TypeOf((*MockUsecaseInterface)(nil).SearchUsers), ctx, input)
+}
+
+// SendEmailVerification mocks base method.
+func (m *MockUsecaseInterface) SendEmailVerification(ctx context.Context, input SendEmailVerificationInput) (SendEmailVerificationOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendEmailVerification", ctx, input)
+	ret0, _ := ret[0].(SendEmailVerificationOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendEmailVerification indicates an expected call of SendEmailVerification.
+func (mr *MockUsecaseInterfaceMockRecorder) SendEmailVerification(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendEmailVerification", reflect.TypeOf((*MockUsecaseInterface)(nil).SendEmailVerification), ctx, input)
+}
+
+// SendIdentityVerification mocks base method.
+func (m *MockUsecaseInterface) SendIdentityVerification(ctx context.Context, input SendIdentityVerificationInput) (SendIdentityVerificationOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendIdentityVerification", ctx, input)
+	ret0, _ := ret[0].(SendIdentityVerificationOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendIdentityVerification indicates an expected call of SendIdentityVerification.
+func (mr *MockUsecaseInterfaceMockRecorder) SendIdentityVerification(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendIdentityVerification", reflect.TypeOf((*MockUsecaseInterface)(nil).SendIdentityVerification), ctx, input)
+}
+
+// SetAttributeSchema mocks base method.
+func (m *MockUsecaseInterface) SetAttributeSchema(ctx context.Context, input SetAttributeSchemaInput) (SetAttributeSchemaOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAttributeSchema", ctx, input)
+	ret0, _ := ret[0].(SetAttributeSchemaOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetAttributeSchema indicates an expected call of SetAttributeSchema.
+func (mr *MockUsecaseInterfaceMockRecorder) SetAttributeSchema(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAttributeSchema", reflect.TypeOf((*MockUsecaseInterface)(nil).SetAttributeSchema), ctx, input)
+}
+
+// SetAvatar mocks base method.
+func (m *MockUsecaseInterface) SetAvatar(ctx context.Context, input SetAvatarInput) (SetAvatarOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAvatar", ctx, input)
+	ret0, _ := ret[0].(SetAvatarOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetAvatar indicates an expected call of SetAvatar.
+func (mr *MockUsecaseInterfaceMockRecorder) SetAvatar(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAvatar", reflect.TypeOf((*MockUsecaseInterface)(nil).SetAvatar), ctx, input)
+}
+
+// SetExpiredPassword mocks base method.
+func (m *MockUsecaseInterface) SetExpiredPassword(ctx context.Context, input SetExpiredPasswordInput) (SetExpiredPasswordOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetExpiredPassword", ctx, input)
+	ret0, _ := ret[0].(SetExpiredPasswordOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetExpiredPassword indicates an expected call of SetExpiredPassword.
+func (mr *MockUsecaseInterfaceMockRecorder) SetExpiredPassword(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetExpiredPassword", reflect.TypeOf((*MockUsecaseInterface)(nil).SetExpiredPassword), ctx, input)
+}
+
+// SetPassword mocks base method.
+func (m *MockUsecaseInterface) SetPassword(ctx context.Context, input SetPasswordInput) (SetPasswordOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPassword", ctx, input)
+	ret0, _ := ret[0].(SetPasswordOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetPassword indicates an expected call of SetPassword.
+func (mr *MockUsecaseInterfaceMockRecorder) SetPassword(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPassword", reflect.TypeOf((*MockUsecaseInterface)(nil).SetPassword), ctx, input)
+}
+
+// SetUserAttributes mocks base method.
+func (m *MockUsecaseInterface) SetUserAttributes(ctx context.Context, input SetUserAttributesInput) (SetUserAttributesOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserAttributes", ctx, input)
+	ret0, _ := ret[0].(SetUserAttributesOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetUserAttributes indicates an expected call of SetUserAttributes.
+func (mr *MockUsecaseInterfaceMockRecorder) SetUserAttributes(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserAttributes", reflect.TypeOf((*MockUsecaseInterface)(nil).SetUserAttributes), ctx, input)
+}
+
+// StartOIDCLogin mocks base method.
+func (m *MockUsecaseInterface) StartOIDCLogin(ctx context.Context, input StartOIDCLoginInput) (StartOIDCLoginOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartOIDCLogin", ctx, input)
+	ret0, _ := ret[0].(StartOIDCLoginOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StartOIDCLogin indicates an expected call of StartOIDCLogin.
+func (mr *MockUsecaseInterfaceMockRecorder) StartOIDCLogin(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartOIDCLogin", reflect.TypeOf((*MockUsecaseInterface)(nil).StartOIDCLogin), ctx, input)
+}
+
 // UpdateUserData mocks base method.
 func (m *MockUsecaseInterface) UpdateUserData(ctx context.Context, input UpdateUserDataInput) (UpdateUserDataOutput, error) {
 	m.ctrl.T.Helper()
@@ -93,3 +543,85 @@ func (mr *MockUsecaseInterfaceMockRecorder) UpdateUserData(ctx, input interface{
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserData", reflect.TypeOf((*MockUsecaseInterface)(nil).UpdateUserData), ctx, input)
 }
+
+// VerifyEmailByCode mocks base method.
+func (m *MockUsecaseInterface) VerifyEmailByCode(ctx context.Context, input VerifyEmailByCodeInput) (VerifyEmailOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmailByCode", ctx, input)
+	ret0, _ := ret[0].(VerifyEmailOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyEmailByCode indicates an expected call of VerifyEmailByCode.
+func (mr *MockUsecaseInterfaceMockRecorder) VerifyEmailByCode(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmailByCode", reflect.TypeOf((*MockUsecaseInterface)(nil).VerifyEmailByCode), ctx, input)
+}
+
+// VerifyEmailByToken mocks base method.
+func (m *MockUsecaseInterface) VerifyEmailByToken(ctx context.Context, input VerifyEmailByTokenInput) (VerifyEmailOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmailByToken", ctx, input)
+	ret0, _ := ret[0].(VerifyEmailOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyEmailByToken indicates an expected call of VerifyEmailByToken.
+func (mr *MockUsecaseInterfaceMockRecorder) VerifyEmailByToken(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmailByToken", reflect.TypeOf((*MockUsecaseInterface)(nil).VerifyEmailByToken), ctx, input)
+}
+
+// MockAuthenticator is a mock of Authenticator interface.
+type MockAuthenticator struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuthenticatorMockRecorder
+}
+
+// MockAuthenticatorMockRecorder is the mock recorder for MockAuthenticator.
+type MockAuthenticatorMockRecorder struct {
+	mock *MockAuthenticator
+}
+
+// NewMockAuthenticator creates a new mock instance.
+func NewMockAuthenticator(ctrl *gomock.Controller) *MockAuthenticator {
+	mock := &MockAuthenticator{ctrl: ctrl}
+	mock.recorder = &MockAuthenticatorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuthenticator) EXPECT() *MockAuthenticatorMockRecorder {
+	return m.recorder
+}
+
+// Authenticate mocks base method.
+func (m *MockAuthenticator) Authenticate(ctx context.Context, input LoginInput) (AuthenticateOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Authenticate", ctx, input)
+	ret0, _ := ret[0].(AuthenticateOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Authenticate indicates an expected call of Authenticate.
+func (mr *MockAuthenticatorMockRecorder) Authenticate(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authenticate", reflect.TypeOf((*MockAuthenticator)(nil).Authenticate), ctx, input)
+}
+
+// Serves mocks base method.
+func (m *MockAuthenticator) Serves(input LoginInput) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Serves", input)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Serves indicates an expected call of Serves.
+func (mr *MockAuthenticatorMockRecorder) Serves(input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Serves", reflect.TypeOf((*MockAuthenticator)(nil).Serves), input)
+}
@@ -1,25 +1,63 @@
 package usecase
 
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/utils"
+)
+
 type RegisterNewUserInput struct {
 	PhoneNumber string
 	FullName    string
 	Password    string
+	// Email is optional, a verification is mailed to it in MailLanguage
+	Email        string
+	MailLanguage string
 }
 
 type RegisterNewUserOutput struct {
 	Id                  int64
 	IsPhoneNumberExists bool
+	IsEmailExists       bool
 }
 
+// LoginInput identifies the user either by PhoneNumber or by a verified Email.
 type LoginInput struct {
 	PhoneNumber string
+	Email       string
 	Password    string
+	// IP is the address of the client, kept in the login history
+	IP string
 }
 
 type LoginOutput struct {
-	IsDataNotFound  bool
-	IsPasswordWrong bool
-	Token           string
+	// IsInvalidCredentials is set both for an unknown phone number and for a
+	// wrong password, callers must not be able to tell them apart.
+	IsInvalidCredentials bool
+	// IsPasswordExpired means Token is a restricted token that is only
+	// accepted by SetExpiredPassword.
+	IsPasswordExpired bool
+	// IsAccountPending and IsAccountSuspended are only set once the
+	// credentials are valid, a deleted account that can no longer be
+	// restored has invalid credentials
+	IsAccountPending   bool
+	IsAccountSuspended bool
+	// IsAccountDeleted means the user deleted their account and can still
+	// restore it, Token is a restricted token only accepted by RestoreUser
+	IsAccountDeleted bool
+	Token            string
+}
+
+type AuthenticateOutput struct {
+	// IsInvalidCredentials has the same meaning as in LoginOutput
+	IsInvalidCredentials bool
+	IsPasswordExpired    bool
+	Id                   int64
+	// Method is how the user logged in, see LOGIN_METHOD_PASSWORD
+	Method string
 }
 
 type GetUserDataInput struct {
@@ -27,16 +65,579 @@ type GetUserDataInput struct {
 }
 
 type GetUserDataOutput struct {
-	PhoneNumber string
-	FullName    string
+	PhoneNumber   string
+	FullName      string
+	Language      string
+	Email         string
+	EmailVerified bool
+	// Avatar is nil when the user has not uploaded one
+	Avatar *AvatarURLs
+	// Attributes is nil without custom attributes
+	Attributes map[string]interface{}
 }
 
 type UpdateUserDataInput struct {
 	Id          int64
 	PhoneNumber string
 	FullName    string
+	Language    string
+	// Email is left unchanged when empty. A new email is unverified until the
+	// verification mailed in MailLanguage is completed
+	Email        string
+	MailLanguage string
+	// Attributes replace the custom attributes of the user, they are left
+	// unchanged when nil. Read-only attributes keep their current values and
+	// may only be repeated unchanged
+	Attributes map[string]interface{}
 }
 
 type UpdateUserDataOutput struct {
 	IsPhoneNumberExists bool
+	IsEmailExists       bool
+	// AttributeErrors are why Attributes were refused, nothing is updated
+	// then
+	AttributeErrors []AttributeError
+}
+
+// PATCH_USER_DATA_ATTEMPTS is how often PatchUserData merges the attributes
+// again when they were changed concurrently.
+const PATCH_USER_DATA_ATTEMPTS = 3
+
+// PatchUserDataInput leaves the fields that are nil unchanged, the values
+// are normalized already.
+type PatchUserDataInput struct {
+	Id          int64
+	FullName    *string
+	PhoneNumber *string
+	// Language is removed when empty
+	Language *string
+	// Email is removed when empty. A new email is unverified until the
+	// verification mailed in MailLanguage is completed
+	Email        *string
+	MailLanguage string
+	// AttributesPatch is an RFC 7396 JSON merge patch of the custom
+	// attributes, an object or null to remove them, nil to leave them
+	// unchanged. The rules of UpdateUserDataInput.Attributes apply to the
+	// patched attributes
+	AttributesPatch json.RawMessage
+}
+
+type PatchUserDataOutput struct {
+	IsPhoneNumberExists bool
+	IsEmailExists       bool
+	// IsConflict means the attributes kept being changed concurrently,
+	// nothing is updated then
+	IsConflict bool
+	// AttributeErrors are why the patched attributes were refused, nothing
+	// is updated then
+	AttributeErrors []AttributeError
+}
+
+type SetPasswordInput struct {
+	Id              int64
+	CurrentPassword string
+	NewPassword     string
+}
+
+type SetPasswordOutput struct {
+	IsPasswordWrong  bool
+	IsPasswordReused bool
+}
+
+type SetExpiredPasswordInput struct {
+	Id          int64
+	NewPassword string
+	// IP is like in LoginInput
+	IP string
+}
+
+type SetExpiredPasswordOutput struct {
+	IsPasswordReused bool
+	Token            string
+}
+
+type SendEmailVerificationInput struct {
+	Id           int64
+	MailLanguage string
+}
+
+type SendEmailVerificationOutput struct {
+	IsEmailMissing  bool
+	IsEmailVerified bool
+}
+
+type VerifyEmailByTokenInput struct {
+	Token string
+}
+
+type VerifyEmailByCodeInput struct {
+	Id   int64
+	Code string
+}
+
+type VerifyEmailOutput struct {
+	// IsInvalid means the token or code is unknown, expired, out of attempts
+	// or was sent to an email the user has changed since
+	IsInvalid bool
+}
+
+// the identity types users can add to their account themselves
+const (
+	IDENTITY_TYPE_PHONE = repository.IDENTITY_TYPE_PHONE
+	IDENTITY_TYPE_EMAIL = repository.IDENTITY_TYPE_EMAIL
+)
+
+// LOGIN_METHOD_PASSWORD is the method of the logins checked against the
+// password stored in users in the login history, directories and login
+// providers log in with their name
+const LOGIN_METHOD_PASSWORD = "password"
+
+type Identity struct {
+	Id         int64
+	Type       string
+	Identifier string
+	IsPrimary  bool
+	// VerifiedAt is nil until the identifier is verified
+	VerifiedAt *time.Time
+}
+
+type GetIdentitiesInput struct {
+	Id int64
+}
+
+type GetIdentitiesOutput struct {
+	Identities []Identity
+}
+
+type AddIdentityInput struct {
+	Id int64
+	// Type is IDENTITY_TYPE_PHONE or IDENTITY_TYPE_EMAIL
+	Type string
+	// Identifier is a normalized phone number or email
+	Identifier string
+	// MailLanguage is the language of the verification mailed to a new email
+	MailLanguage string
+}
+
+type AddIdentityOutput struct {
+	Identity           Identity
+	IsIdentifierExists bool
+}
+
+type RemoveIdentityInput struct {
+	Id         int64
+	IdentityId int64
+}
+
+type RemoveIdentityOutput struct {
+	IsNotFound           bool
+	IsPrimaryPhoneNumber bool
+	// IsLastLogin is set for the last external login of a user without a
+	// password, who could not log in anymore without it
+	IsLastLogin bool
+}
+
+type SendIdentityVerificationInput struct {
+	Id           int64
+	IdentityId   int64
+	MailLanguage string
+}
+
+type SendIdentityVerificationOutput struct {
+	IsNotFound bool
+	// IsNotVerifiable means the identity is not an email
+	IsNotVerifiable bool
+	IsVerified      bool
+}
+
+type StartOIDCLoginInput struct {
+	Provider string
+}
+
+type StartOIDCLoginOutput struct {
+	IsProviderUnknown bool
+	// AuthorizationURL is where the user logs in at the provider
+	AuthorizationURL string
+	// FlowToken has to be kept by the browser and handed back to
+	// FinishOIDCLogin, it expires after a few minutes
+	FlowToken string
+}
+
+type FinishOIDCLoginInput struct {
+	Provider string
+	// Code and State are the parameters the provider redirected back with
+	Code      string
+	State     string
+	FlowToken string
+	// IP is like in LoginInput
+	IP string
+}
+
+type FinishOIDCLoginOutput struct {
+	IsProviderUnknown bool
+	// IsInvalid means the provider refused the login, the login expired or
+	// it was not started by the same browser, or the account is deleted and
+	// can no longer be restored
+	IsInvalid          bool
+	IsAccountPending   bool
+	IsAccountSuspended bool
+	// IsAccountDeleted is set as for LoginOutput
+	IsAccountDeleted bool
+	Token            string
+}
+
+// HasPermissionInput asks whether any of Roles grants Permission.
+type HasPermissionInput struct {
+	Roles      []string
+	Permission string
+}
+
+type HasPermissionOutput struct {
+	IsAllowed bool
+}
+
+// the statuses of users and the orders of SearchUsers, a leading "-" sorts
+// descending
+const (
+	USER_STATUS_PENDING     = repository.USER_STATUS_PENDING
+	USER_STATUS_ACTIVE      = repository.USER_STATUS_ACTIVE
+	USER_STATUS_SUSPENDED   = repository.USER_STATUS_SUSPENDED
+	USER_STATUS_DEACTIVATED = repository.USER_STATUS_DEACTIVATED
+	USER_STATUS_DELETED     = repository.USER_STATUS_DELETED
+
+	USER_SORT_CREATED_AT      = repository.USER_SORT_CREATED_AT
+	USER_SORT_CREATED_AT_DESC = repository.USER_SORT_CREATED_AT_DESC
+	USER_SORT_FULL_NAME       = repository.USER_SORT_FULL_NAME
+	USER_SORT_FULL_NAME_DESC  = repository.USER_SORT_FULL_NAME_DESC
+)
+
+var (
+	USER_STATUSES = repository.USER_STATUSES
+	USER_SORTS    = []string{USER_SORT_CREATED_AT, USER_SORT_CREATED_AT_DESC, USER_SORT_FULL_NAME, USER_SORT_FULL_NAME_DESC}
+)
+
+type UserSummary struct {
+	Id          int64
+	FullName    string
+	PhoneNumber string
+	Email       string
+	Status      string
+	CreatedAt   time.Time
+}
+
+// SearchUsersInput filters are ignored when empty.
+type SearchUsersInput struct {
+	// PhonePrefix matches the start of the primary phone number in E.164
+	// format, Name a part of the full name regardless of case
+	PhonePrefix string
+	Name        string
+	// CreatedFrom is inclusive, CreatedTo exclusive
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Status      string
+	// Sort is one of USER_SORTS
+	Sort  string
+	Limit int
+	// Cursor is the NextCursor of the previous page with the same Sort, empty
+	// for the first page
+	Cursor string
+}
+
+type SearchUsersOutput struct {
+	// IsCursorInvalid means Cursor was not returned for this Sort
+	IsCursorInvalid bool
+	Users           []UserSummary
+	// NextCursor is empty on the last page
+	NextCursor string
+}
+
+type GetUserDetailsInput struct {
+	Id int64
+}
+
+type UserDetails struct {
+	Id          int64
+	FullName    string
+	PhoneNumber string
+	Email       string
+	// EmailVerified is false when Email is empty
+	EmailVerified bool
+	Language      string
+	Status        string
+	UserGroup     string
+	Roles         []string
+	TotalLogin    int
+	CreatedAt     time.Time
+	// UpdatedAt and UpdatedBy are nil until the user is updated
+	UpdatedAt *time.Time
+	UpdatedBy *int64
+	// Attributes is nil without custom attributes
+	Attributes map[string]interface{}
+	// Avatar is nil without an avatar
+	Avatar *AvatarURLs
+}
+
+type GetUserDetailsOutput struct {
+	IsNotFound bool
+	User       UserDetails
+}
+
+type GetUserStatusInput struct {
+	Id int64
+}
+
+type GetUserStatusOutput struct {
+	IsNotFound bool
+	Status     string
+}
+
+type ChangeUserStatusInput struct {
+	Id     int64
+	Status string
+	Reason string
+	// ChangedBy is the admin who changes the status
+	ChangedBy int64
+}
+
+type ChangeUserStatusOutput struct {
+	IsNotFound bool
+	// IsTransitionNotAllowed means the user can not be moved from their
+	// current status to Status, see userStatusTransitions
+	IsTransitionNotAllowed bool
+}
+
+type DeactivateUserInput struct {
+	Id int64
+	// Password confirms the deactivation, it is the password of the directory
+	// for users provisioned by one
+	Password string
+	Reason   string
+	// AuthenticatedAt is when the user logged in, it confirms the
+	// deactivation for users who only have login providers
+	AuthenticatedAt time.Time
+}
+
+type DeactivateUserOutput struct {
+	IsPasswordWrong bool
+	// IsLoginRequired means the user has no password and logged in more than
+	// ReauthenticationMaxAge ago, they have to log in again first
+	IsLoginRequired bool
+}
+
+type GetUserStatusChangesInput struct {
+	Id int64
+}
+
+type UserStatusChange struct {
+	Id         int64
+	FromStatus string
+	ToStatus   string
+	Reason     string
+	// ChangedBy is nil when the user who made the change was removed
+	ChangedBy *int64
+	CreatedAt time.Time
+}
+
+type GetUserStatusChangesOutput struct {
+	IsNotFound bool
+	// Changes are ordered from the newest
+	Changes []UserStatusChange
+}
+
+type ImpersonateUserInput struct {
+	// Id is the user to act as
+	Id      int64
+	AdminId int64
+	Reason  string
+}
+
+type ImpersonateUserOutput struct {
+	IsNotFound bool
+	// IsNotActive means the user could not use the token, see USER_STATUS_ACTIVE
+	IsNotActive bool
+	Token       string
+	ExpiresAt   time.Time
+}
+
+type RecordImpersonationUseInput struct {
+	ImpersonationId int64
+	Method          string
+	// Path is the path of the request without the query string
+	Path string
+}
+
+type RecordImpersonationUseOutput struct{}
+
+type DeleteUserInput struct {
+	Id int64
+	// Password confirms the deletion like in DeactivateUserInput
+	Password string
+	// AuthenticatedAt is like in DeactivateUserInput
+	AuthenticatedAt time.Time
+}
+
+type DeleteUserOutput struct {
+	IsPasswordWrong bool
+	// IsLoginRequired is like in DeactivateUserOutput
+	IsLoginRequired bool
+	// PurgeAt is when the account is anonymized, logging in until then
+	// offers to restore it
+	PurgeAt time.Time
+}
+
+type RestoreUserInput struct {
+	Id int64
+}
+
+type RestoreUserOutput struct {
+	// IsNotRestorable means the account is not deleted or has already been
+	// purged
+	IsNotRestorable bool
+}
+
+type PurgeDeletedUsersInput struct{}
+
+type PurgeDeletedUsersOutput struct {
+	Count int64
+}
+
+// the sizes avatars are scaled to, in pixels. Uploads may not be smaller than
+// the smallest one
+const (
+	AVATAR_SIZE_SMALL  = 64
+	AVATAR_SIZE_MEDIUM = 256
+	AVATAR_SIZE_LARGE  = 512
+	// AVATAR_MAX_SIDE is the width or height in pixels an upload may not
+	// exceed. It bounds the image checkAvatar lets be decoded to 16
+	// megapixels, 64 MiB in memory, however small the upload is
+	AVATAR_MAX_SIDE = 4096
+)
+
+var AVATAR_TYPES = utils.IMAGE_TYPES
+
+type AvatarURLs struct {
+	Small  string
+	Medium string
+	Large  string
+}
+
+type SetAvatarInput struct {
+	Id int64
+	// Content is the uploaded image, one of AVATAR_TYPES
+	Content io.Reader
+}
+
+type SetAvatarOutput struct {
+	IsTooLarge          bool
+	IsTypeNotSupported  bool
+	IsDimensionsInvalid bool
+	// MaxBytes is the size IsTooLarge refers to
+	MaxBytes int64
+	Avatar   AvatarURLs
+}
+
+type RemoveAvatarInput struct {
+	Id int64
+}
+
+type RemoveAvatarOutput struct{}
+
+// the formats of a data export
+const (
+	DATA_EXPORT_FORMAT_JSON = "json"
+	// DATA_EXPORT_FORMAT_NDJSON is a zip archive holding one NDJSON file, a
+	// record per line
+	DATA_EXPORT_FORMAT_NDJSON = "ndjson"
+)
+
+var DATA_EXPORT_FORMATS = []string{DATA_EXPORT_FORMAT_JSON, DATA_EXPORT_FORMAT_NDJSON}
+
+type DataExportFile struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+}
+
+type ExportUserDataInput struct {
+	Id int64
+	// Format is one of DATA_EXPORT_FORMATS
+	Format string
+}
+
+type ExportUserDataOutput struct {
+	// IsAsync means the user has too many records to export them right away,
+	// the export ExportId can be fetched with GetDataExport once it is ready
+	// and until ExpiresAt
+	IsAsync   bool
+	ExportId  int64
+	ExpiresAt time.Time
+	// File is only set when the export is not async
+	File DataExportFile
+}
+
+type GetDataExportInput struct {
+	Id       int64
+	ExportId int64
+}
+
+type GetDataExportOutput struct {
+	// IsNotFound means the export is unknown, of another user or expired
+	IsNotFound bool
+	IsPending  bool
+	IsFailed   bool
+	// ExpiresAt is until when the export can be downloaded
+	ExpiresAt time.Time
+	File      DataExportFile
+}
+
+type PurgeDataExportsInput struct{}
+
+type PurgeDataExportsOutput struct {
+	Count int64
+}
+
+// AttributeError is why custom attributes were refused.
+type AttributeError struct {
+	// Path is the attribute and the fields within it, empty for the
+	// attributes as a whole
+	Path []string
+	// IsReadOnly means the attribute can only be changed by admins,
+	// otherwise Reason describes the violation of the attribute schema
+	IsReadOnly bool
+	Reason     string
+}
+
+type GetAttributeSchemaInput struct{}
+
+type GetAttributeSchemaOutput struct {
+	Schema map[string]interface{}
+}
+
+type SetAttributeSchemaInput struct {
+	Schema map[string]interface{}
+	// UpdatedBy is the admin who sets the schema
+	UpdatedBy int64
+}
+
+type SetAttributeSchemaOutput struct {
+	// IsInvalid means Schema is not a usable JSON Schema of an object,
+	// Reason describes why
+	IsInvalid bool
+	Reason    string
+}
+
+type SetUserAttributesInput struct {
+	Id int64
+	// Attributes replace the custom attributes of the user, including the
+	// read-only ones
+	Attributes map[string]interface{}
+	// UpdatedBy is the admin who sets the attributes
+	UpdatedBy int64
+}
+
+type SetUserAttributesOutput struct {
+	IsNotFound bool
+	// AttributeErrors are why Attributes were refused, nothing is updated
+	// then
+	AttributeErrors []AttributeError
 }
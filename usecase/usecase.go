@@ -1,17 +1,205 @@
 package usecase
 
-import "github.com/SawitProRecruitment/UserService/repository"
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/utils"
+)
 
 type Usecase struct {
-	Repository repository.RepositoryInterface
+	Repository          repository.RepositoryInterface
+	PasswordHasher      utils.PasswordHasher
+	PasswordHistorySize int
+	PasswordExpiryDays  map[string]int
+	MailSender          utils.MailSender
+
+	EmailVerificationLink     string
+	EmailVerificationLifespan time.Duration
+
+	ImpersonationLifespan time.Duration
+
+	AccountDeletionGracePeriod time.Duration
+	ReauthenticationMaxAge     time.Duration
+
+	DataExportSyncMaxRecords int
+	DataExportLifespan       time.Duration
+	// dataExportSlots holds a value per background export being built, see
+	// runDataExport
+	dataExportSlots chan struct{}
+
+	ObjectStorage  utils.ObjectStorage
+	AvatarMaxBytes int64
+
+	OIDCProviders map[string]*utils.OIDCProvider
+	// Authenticators verify the logins they serve instead of the password
+	// stored in users
+	Authenticators []Authenticator
+
+	dummyPasswordHash     string
+	dummyPasswordHashOnce sync.Once
 }
 
 type NewUsecaseOptions struct {
-	Repository repository.RepositoryInterface
+	Repository     repository.RepositoryInterface
+	PasswordHasher utils.PasswordHasher
+	// PasswordHistorySize is the number of previous passwords a new password
+	// may not match. When zero, PASSWORD_HISTORY_SIZE is used (5 when unset).
+	PasswordHistorySize int
+	// PasswordExpiryDays is the maximum password age per user group. Groups
+	// without an entry, or with 0, never expire. When nil, PASSWORD_EXPIRY_DAYS
+	// is used.
+	PasswordExpiryDays map[string]int
+	// MailSender defaults to utils.NewMailSenderFromEnv.
+	MailSender utils.MailSender
+	// EmailVerificationLink should point to a page that posts the {token} to
+	// POST /email/verify. When empty, EMAIL_VERIFICATION_LINK is used.
+	EmailVerificationLink string
+	// EmailVerificationLifespan defaults to EMAIL_VERIFICATION_LIFESPAN_MINUTES
+	// (60 when unset).
+	EmailVerificationLifespan time.Duration
+	// ImpersonationLifespan is how long support staff can act as a user with
+	// one token. When zero, IMPERSONATION_LIFESPAN_MINUTES is used (15 when
+	// unset).
+	ImpersonationLifespan time.Duration
+	// AccountDeletionGracePeriod is how long a user can restore the account
+	// they deleted before it is anonymized. When zero,
+	// ACCOUNT_DELETION_GRACE_DAYS is used (30 when unset).
+	AccountDeletionGracePeriod time.Duration
+	// ReauthenticationMaxAge is how recent the login of a user without a
+	// password must be for them to deactivate or delete their account. When
+	// zero, REAUTHENTICATION_MAX_AGE_MINUTES is used (5 when unset).
+	ReauthenticationMaxAge time.Duration
+	// DataExportSyncMaxRecords is the number of records a user can have for
+	// their data to be exported during the request, larger exports are built
+	// in the background. When zero, DATA_EXPORT_SYNC_MAX_RECORDS is used (500
+	// when unset).
+	DataExportSyncMaxRecords int
+	// DataExportLifespan is how long a background export can be downloaded.
+	// When zero, DATA_EXPORT_LIFESPAN_HOURS is used (24 when unset).
+	DataExportLifespan time.Duration
+	// DataExportMaxConcurrent is the number of background exports built at
+	// the same time, the others wait. When zero, DATA_EXPORT_MAX_CONCURRENT is
+	// used (2 when unset).
+	DataExportMaxConcurrent int
+	// ObjectStorage keeps the avatars. When nil,
+	// utils.NewObjectStorageFromEnv is used.
+	ObjectStorage utils.ObjectStorage
+	// AvatarMaxBytes is the size an uploaded avatar may not exceed. When zero,
+	// AVATAR_MAX_BYTES is used (5 MiB when unset).
+	AvatarMaxBytes int64
+	// OIDCProviders are the OpenID Connect providers users can log in with,
+	// by name. When nil, utils.NewOIDCProvidersFromEnv is used.
+	OIDCProviders map[string]*utils.OIDCProvider
+	// LDAPDirectories verify the logins their routing rules match, the first
+	// match wins. When nil, utils.NewLDAPDirectoriesFromEnv is used.
+	LDAPDirectories []*utils.LDAPDirectory
 }
 
 func NewUsecase(opts NewUsecaseOptions) *Usecase {
-	return &Usecase{
-		Repository: opts.Repository,
+	if opts.PasswordHasher == nil {
+		opts.PasswordHasher = utils.NewPasswordHasherFromEnv()
+	}
+
+	if opts.PasswordHistorySize == 0 {
+		opts.PasswordHistorySize = utils.GetEnvInt("PASSWORD_HISTORY_SIZE", 5)
+	}
+
+	if opts.PasswordExpiryDays == nil {
+		opts.PasswordExpiryDays = utils.GetEnvIntMap("PASSWORD_EXPIRY_DAYS")
+	}
+
+	if opts.MailSender == nil {
+		opts.MailSender = utils.NewMailSenderFromEnv()
+	}
+
+	if opts.EmailVerificationLink == "" {
+		opts.EmailVerificationLink = utils.GetEnvString("EMAIL_VERIFICATION_LINK", "http://localhost:3000/verify-email?token={token}")
+	}
+
+	if opts.EmailVerificationLifespan == 0 {
+		opts.EmailVerificationLifespan = time.Duration(utils.GetEnvInt("EMAIL_VERIFICATION_LIFESPAN_MINUTES", 60)) * time.Minute
+	}
+
+	if opts.ImpersonationLifespan == 0 {
+		opts.ImpersonationLifespan = time.Duration(utils.GetEnvInt("IMPERSONATION_LIFESPAN_MINUTES", 15)) * time.Minute
+	}
+
+	if opts.AccountDeletionGracePeriod == 0 {
+		opts.AccountDeletionGracePeriod = time.Duration(utils.GetEnvInt("ACCOUNT_DELETION_GRACE_DAYS", 30)) * 24 * time.Hour
+	}
+
+	if opts.ReauthenticationMaxAge == 0 {
+		opts.ReauthenticationMaxAge = time.Duration(utils.GetEnvInt("REAUTHENTICATION_MAX_AGE_MINUTES", 5)) * time.Minute
+	}
+
+	if opts.DataExportSyncMaxRecords == 0 {
+		opts.DataExportSyncMaxRecords = utils.GetEnvInt("DATA_EXPORT_SYNC_MAX_RECORDS", 500)
+	}
+
+	if opts.DataExportLifespan == 0 {
+		opts.DataExportLifespan = time.Duration(utils.GetEnvInt("DATA_EXPORT_LIFESPAN_HOURS", 24)) * time.Hour
+	}
+
+	if opts.DataExportMaxConcurrent == 0 {
+		opts.DataExportMaxConcurrent = utils.GetEnvInt("DATA_EXPORT_MAX_CONCURRENT", 2)
+	}
+
+	if opts.ObjectStorage == nil {
+		opts.ObjectStorage = utils.NewObjectStorageFromEnv()
+	}
+
+	if opts.AvatarMaxBytes == 0 {
+		opts.AvatarMaxBytes = int64(utils.GetEnvInt("AVATAR_MAX_BYTES", 5<<20))
+	}
+
+	if opts.OIDCProviders == nil {
+		opts.OIDCProviders = utils.NewOIDCProvidersFromEnv()
+	}
+
+	if opts.LDAPDirectories == nil {
+		opts.LDAPDirectories = utils.NewLDAPDirectoriesFromEnv()
 	}
+
+	u := &Usecase{
+		Repository:          opts.Repository,
+		PasswordHasher:      opts.PasswordHasher,
+		PasswordHistorySize: opts.PasswordHistorySize,
+		PasswordExpiryDays:  opts.PasswordExpiryDays,
+		MailSender:          opts.MailSender,
+
+		EmailVerificationLink:     opts.EmailVerificationLink,
+		EmailVerificationLifespan: opts.EmailVerificationLifespan,
+
+		ImpersonationLifespan: opts.ImpersonationLifespan,
+
+		AccountDeletionGracePeriod: opts.AccountDeletionGracePeriod,
+		ReauthenticationMaxAge:     opts.ReauthenticationMaxAge,
+
+		DataExportSyncMaxRecords: opts.DataExportSyncMaxRecords,
+		DataExportLifespan:       opts.DataExportLifespan,
+		dataExportSlots:          make(chan struct{}, opts.DataExportMaxConcurrent),
+
+		ObjectStorage:  opts.ObjectStorage,
+		AvatarMaxBytes: opts.AvatarMaxBytes,
+
+		OIDCProviders: opts.OIDCProviders,
+	}
+
+	for _, directory := range opts.LDAPDirectories {
+		// both would own the same identities
+		if _, ok := opts.OIDCProviders[directory.Name]; ok {
+			log.Printf("[WARN][NewUsecase] ignoring directory %q, there is a login provider with the same name", directory.Name)
+			continue
+		}
+
+		u.Authenticators = append(u.Authenticators, &ldapAuthenticator{
+			usecase:   u,
+			directory: directory,
+		})
+	}
+
+	return u
 }
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/pkg/errors"
+)
+
+// AttributeSchema is the JSON Schema the custom profile attributes of users
+// must match. It is written in the dialect of OpenAPI 3.0, e.g. "nullable"
+// rather than a list of types, and can not use $ref. Properties marked
+// "readOnly" can only be changed by admins.
+type AttributeSchema struct {
+	schema *openapi3.Schema
+}
+
+// AttributeError is a violation of the attribute schema.
+type AttributeError struct {
+	// Path is the attribute and the fields within it, empty for the
+	// attributes as a whole
+	Path   []string
+	Reason string
+}
+
+// ParseAttributeSchema parses a JSON Schema of an object. The error
+// describes what is wrong with the schema.
+func ParseAttributeSchema(raw []byte) (*AttributeSchema, error) {
+	var schema openapi3.Schema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if schema.Type != openapi3.TypeObject {
+		return nil, errors.New(`the schema must be of type "object"`)
+	}
+
+	if err := schema.Validate(context.Background()); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &AttributeSchema{schema: &schema}, nil
+}
+
+// IsReadOnly reports whether only admins may change the attribute name.
+func (s *AttributeSchema) IsReadOnly(name string) bool {
+	property, ok := s.schema.Properties[name]
+	return ok && property.Value != nil && property.Value.ReadOnly
+}
+
+// Validate returns every violation of the schema by attributes, ordered by
+// path. Nil attributes are an empty object.
+func (s *AttributeSchema) Validate(attributes map[string]interface{}) []AttributeError {
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+
+	// the schema only understands the types encoding/json decodes into
+	var value interface{}
+	encoded, err := json.Marshal(attributes)
+	if err == nil {
+		err = json.Unmarshal(encoded, &value)
+	}
+
+	if err != nil {
+		return []AttributeError{{Reason: err.Error()}}
+	}
+
+	var attributeErrors []AttributeError
+	collectAttributeErrors(s.schema.VisitJSON(value, openapi3.MultiErrors()), &attributeErrors)
+
+	sort.SliceStable(attributeErrors, func(i, j int) bool {
+		return strings.Join(attributeErrors[i].Path, "\x00") < strings.Join(attributeErrors[j].Path, "\x00")
+	})
+
+	return attributeErrors
+}
+
+func collectAttributeErrors(err error, attributeErrors *[]AttributeError) {
+	switch err := err.(type) {
+	case nil:
+	case openapi3.MultiError:
+		for _, err := range err {
+			collectAttributeErrors(err, attributeErrors)
+		}
+	case *openapi3.SchemaError:
+		reason := err.Reason
+		if reason == "" {
+			reason = err.Error()
+		}
+
+		*attributeErrors = append(*attributeErrors, AttributeError{
+			Path:   err.JSONPointer(),
+			Reason: reason,
+		})
+	default:
+		*attributeErrors = append(*attributeErrors, AttributeError{
+			Reason: err.Error(),
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// SHA1_LINE_MAX_LENGTH bounds a line of a breached password file, a SHA-1 in
+// hex with its count is far shorter.
+const SHA1_LINE_MAX_LENGTH = 128
+
+// SHA1_FILE_CHECKED_LINES is the number of lines spread over a breached
+// password file OpenSHA1PasswordFile checks, a file that is not sorted or not
+// made of SHA-1 hashes is caught without reading it all.
+const SHA1_FILE_CHECKED_LINES = 64
+
+// defaultBreachedPasswords is a small list of very common passwords used when
+// BREACHED_PASSWORDS_FILE is not configured.
+//
+//go:embed data/breached_passwords.sha1
+var defaultBreachedPasswords []byte
+
+var (
+	BreachedPasswords     *SHA1PasswordFile
+	breachedPasswordsOnce sync.Once
+)
+
+// SHA1PasswordFile looks passwords up in a file of SHA-1 password hashes
+// sorted in ascending order, one upper or lower case hex SHA-1 per line,
+// optionally followed by ":<count>" as in the Have I Been Pwned downloads
+// ordered by hash. Lookups binary search the file through ReadAt, so only a
+// few lines are read per lookup and the file is never loaded in memory.
+type SHA1PasswordFile struct {
+	r    io.ReaderAt
+	size int64
+}
+
+func NewSHA1PasswordFile(r io.ReaderAt, size int64) *SHA1PasswordFile {
+	return &SHA1PasswordFile{
+		r:    r,
+		size: size,
+	}
+}
+
+// OpenSHA1PasswordFile opens the sorted file at path, it stays open for the
+// lifetime of the process.
+func OpenSHA1PasswordFile(path string) (*SHA1PasswordFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, errors.WithStack(err)
+	}
+
+	list := NewSHA1PasswordFile(f, info.Size())
+
+	// catch a file in another format early rather than on every lookup
+	if err := list.check(); err != nil {
+		f.Close()
+		return nil, errors.WithStack(err)
+	}
+
+	return list, nil
+}
+
+// check reads SHA1_FILE_CHECKED_LINES lines spread over the file and makes
+// sure they hold SHA-1 hashes in ascending order.
+func (l *SHA1PasswordFile) check() error {
+	var (
+		previous      []byte
+		previousStart = int64(-1)
+	)
+
+	for i := int64(0); i <= SHA1_FILE_CHECKED_LINES; i++ {
+		start, _, hash, err := l.lineAfter(l.size * i / SHA1_FILE_CHECKED_LINES)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if start == l.size || start == previousStart {
+			continue
+		}
+
+		if previous != nil && bytes.Compare(previous, hash) >= 0 {
+			return errors.Errorf("hashes are not in ascending order at offset %d", start)
+		}
+
+		previous, previousStart = hash, start
+	}
+
+	if previous == nil {
+		return errors.New("no SHA-1 hashes")
+	}
+
+	return nil
+}
+
+// Contains reports whether the SHA-1 of password is in the file.
+func (l *SHA1PasswordFile) Contains(password string) (bool, error) {
+	hash := sha1.Sum([]byte(password))
+
+	// the line of the hash, if any, starts in [low, high)
+	low, high := int64(0), l.size
+	for low < high {
+		middle := low + (high-low)/2
+
+		start, end, lineHash, err := l.lineAfter(middle)
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+
+		if start >= high {
+			high = middle
+			continue
+		}
+
+		switch bytes.Compare(lineHash, hash[:]) {
+		case 0:
+			return true, nil
+		case -1:
+			low = end + 1
+		default:
+			high = middle
+		}
+	}
+
+	return false, nil
+}
+
+// lineAfter returns the first line starting at or after offset with the
+// offset of its start, of its end and the hash on it. start is the size of
+// the file when there is no such line.
+func (l *SHA1PasswordFile) lineAfter(offset int64) (start, end int64, hash []byte, err error) {
+	from := offset
+	if offset > 0 {
+		// a line starts at offset when the previous byte ends a line
+		from = offset - 1
+	}
+
+	buf := make([]byte, 2*SHA1_LINE_MAX_LENGTH)
+	n, err := l.r.ReadAt(buf, from)
+	if err != nil && err != io.EOF {
+		return 0, 0, nil, errors.WithStack(err)
+	}
+	buf = buf[:n]
+	isEOF := from+int64(n) >= l.size
+
+	i := 0
+	if offset > 0 {
+		i = bytes.IndexByte(buf, '\n')
+		if i < 0 && isEOF {
+			return l.size, l.size, nil, nil
+		}
+		if i < 0 || i > SHA1_LINE_MAX_LENGTH {
+			return 0, 0, nil, errors.Errorf("line longer than %d bytes near offset %d", SHA1_LINE_MAX_LENGTH, offset)
+		}
+		i++
+	}
+
+	start = from + int64(i)
+	if start >= l.size {
+		return l.size, l.size, nil, nil
+	}
+
+	line := buf[i:]
+	j := bytes.IndexByte(line, '\n')
+	if j < 0 {
+		if !isEOF {
+			return 0, 0, nil, errors.Errorf("line longer than %d bytes at offset %d", SHA1_LINE_MAX_LENGTH, start)
+		}
+		j = len(line)
+	}
+	end = start + int64(j)
+
+	entry := strings.TrimSpace(string(line[:j]))
+	if idx := strings.Index(entry, ":"); idx >= 0 {
+		entry = entry[:idx]
+	}
+
+	hash, err = hex.DecodeString(entry)
+	if err != nil || len(hash) != sha1.Size {
+		return 0, 0, nil, errors.Errorf("invalid SHA-1 entry %q at offset %d", entry, start)
+	}
+
+	return start, end, hash, nil
+}
+
+// IsBreachedPassword reports whether s appears in the breached password list.
+// The list is opened once from BREACHED_PASSWORDS_FILE, falling back to the
+// embedded list of common passwords.
+func IsBreachedPassword(s string) bool {
+	breachedPasswordsOnce.Do(func() {
+		if BreachedPasswords != nil {
+			return
+		}
+
+		if path := GetEnvString("BREACHED_PASSWORDS_FILE", ""); path != "" {
+			list, err := OpenSHA1PasswordFile(path)
+			if err == nil {
+				BreachedPasswords = list
+				return
+			}
+			log.Println("[ERROR][IsBreachedPassword] failed to open breached password file, using embedded list", err)
+		}
+
+		BreachedPasswords = NewSHA1PasswordFile(bytes.NewReader(defaultBreachedPasswords), int64(len(defaultBreachedPasswords)))
+	})
+
+	isBreached, err := BreachedPasswords.Contains(s)
+	if err != nil {
+		log.Println("[ERROR][IsBreachedPassword] failed to look the password up in the breached password list", err)
+		return false
+	}
+
+	return isBreached
+}
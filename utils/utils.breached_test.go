@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testBreachedPasswords are passwords ordered by their SHA-1, the first, the
+// last and the middle one are left out of the test files.
+func testBreachedPasswords() []string {
+	passwords := make([]string, 0, 101)
+	for i := 0; i < cap(passwords); i++ {
+		passwords = append(passwords, fmt.Sprintf("password-%d", i))
+	}
+
+	sort.Slice(passwords, func(i, j int) bool {
+		a, b := sha1.Sum([]byte(passwords[i])), sha1.Sum([]byte(passwords[j]))
+		return bytes.Compare(a[:], b[:]) < 0
+	})
+
+	return passwords
+}
+
+// testSHA1PasswordFile returns the hashes of the passwords between the first
+// and the last one except the middle one, a line each made by line.
+func testSHA1PasswordFile(passwords []string, line func(hash string, i int) string) string {
+	var b strings.Builder
+	for i := 1; i < len(passwords)-1; i++ {
+		if i == len(passwords)/2 {
+			continue
+		}
+		hash := sha1.Sum([]byte(passwords[i]))
+		b.WriteString(line(strings.ToUpper(hex.EncodeToString(hash[:])), i))
+	}
+
+	return b.String()
+}
+
+func TestSHA1PasswordFile_Contains(t *testing.T) {
+	passwords := testBreachedPasswords()
+	middle := len(passwords) / 2
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "uppercase hex",
+			content: testSHA1PasswordFile(passwords, func(hash string, i int) string {
+				return hash + "\n"
+			}),
+		},
+		{
+			name: "lowercase hex",
+			content: testSHA1PasswordFile(passwords, func(hash string, i int) string {
+				return strings.ToLower(hash) + "\n"
+			}),
+		},
+		{
+			name: "count suffix",
+			content: testSHA1PasswordFile(passwords, func(hash string, i int) string {
+				return fmt.Sprintf("%s:%d\n", hash, i*1000)
+			}),
+		},
+		{
+			name: "missing final newline",
+			content: strings.TrimSuffix(testSHA1PasswordFile(passwords, func(hash string, i int) string {
+				return hash + ":3\n"
+			}), "\n"),
+		},
+		{
+			name: "CRLF line endings",
+			content: testSHA1PasswordFile(passwords, func(hash string, i int) string {
+				return hash + ":3\r\n"
+			}),
+		},
+	}
+
+	lookups := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{name: "hit on the first line", password: passwords[1], want: true},
+		{name: "hit on a middle line", password: passwords[middle-1], want: true},
+		{name: "hit on the last line", password: passwords[len(passwords)-2], want: true},
+		{name: "miss before the first line", password: passwords[0], want: false},
+		{name: "miss between lines", password: passwords[middle], want: false},
+		{name: "miss after the last line", password: passwords[len(passwords)-1], want: false},
+		{name: "miss of another password", password: "correct horse battery staple", want: false},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "breached.sha1")
+		if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		file, err := OpenSHA1PasswordFile(path)
+		if err != nil {
+			t.Errorf("OpenSHA1PasswordFile() %s error = %v", tt.name, err)
+			continue
+		}
+
+		lists := map[string]*SHA1PasswordFile{
+			"reader": NewSHA1PasswordFile(strings.NewReader(tt.content), int64(len(tt.content))),
+			"file":   file,
+		}
+
+		for listName, list := range lists {
+			for _, lookup := range lookups {
+				t.Run(tt.name+", "+listName+", "+lookup.name, func(t *testing.T) {
+					got, err := list.Contains(lookup.password)
+					if err != nil {
+						t.Errorf("SHA1PasswordFile.Contains() error = %v", err)
+						return
+					}
+					assert.Equal(t, lookup.want, got)
+				})
+			}
+		}
+	}
+}
+
+func TestOpenSHA1PasswordFile(t *testing.T) {
+	passwords := testBreachedPasswords()
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			content: "",
+			wantErr: true,
+		},
+		{
+			name:    "not hex",
+			content: "password1\nqwerty\n",
+			wantErr: true,
+		},
+		{
+			name: "not hex in the middle",
+			content: testSHA1PasswordFile(passwords, func(hash string, i int) string {
+				if i == len(passwords)/3 {
+					return "not a sha1 hash\n"
+				}
+				return hash + "\n"
+			}),
+			wantErr: true,
+		},
+		{
+			name:    "not a SHA-1",
+			content: "5F4DCC3B5AA765D61D8327DEB882CF99\n",
+			wantErr: true,
+		},
+		{
+			name: "unsorted",
+			content: testSHA1PasswordFile(passwords, func(hash string, i int) string {
+				// the second half before the first one
+				if i < len(passwords)/2 {
+					return ""
+				}
+				return hash + "\n"
+			}) + testSHA1PasswordFile(passwords, func(hash string, i int) string {
+				if i >= len(passwords)/2 {
+					return ""
+				}
+				return hash + "\n"
+			}),
+			wantErr: true,
+		},
+		{
+			name:    "line too long",
+			content: strings.Repeat("A", 4*SHA1_LINE_MAX_LENGTH) + "\n",
+			wantErr: true,
+		},
+		{
+			name: "success",
+			content: testSHA1PasswordFile(passwords, func(hash string, i int) string {
+				return hash + ":1\n"
+			}),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "breached.sha1")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := OpenSHA1PasswordFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("OpenSHA1PasswordFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := OpenSHA1PasswordFile(filepath.Join(t.TempDir(), "missing.sha1"))
+		assert.Error(t, err)
+	})
+}
+
+func TestIsBreachedPassword_embeddedList(t *testing.T) {
+	embedded := NewSHA1PasswordFile(bytes.NewReader(defaultBreachedPasswords), int64(len(defaultBreachedPasswords)))
+	assert.NoError(t, embedded.check())
+
+	got, err := embedded.Contains("password")
+	assert.NoError(t, err)
+	assert.True(t, got)
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func GetEnvString(key, defaultValue string) string {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func GetEnvInt(key string, defaultValue int) int {
+	valueStr := strings.TrimSpace(os.Getenv(key))
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		log.Printf("[WARN][GetEnvInt] error when converting %s, using default %d: %v", key, defaultValue, err)
+		return defaultValue
+	}
+
+	return value
+}
+
+func GetEnvBool(key string, defaultValue bool) bool {
+	valueStr := strings.TrimSpace(os.Getenv(key))
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		log.Printf("[WARN][GetEnvBool] error when converting %s, using default %t: %v", key, defaultValue, err)
+		return defaultValue
+	}
+
+	return value
+}
+
+// GetEnvList reads a comma separated list, empty entries are skipped.
+func GetEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// GetEnvIntMap reads a "key:value,key:value" list of integers, for example
+// PASSWORD_EXPIRY_DAYS="admin:30,default:90". Malformed entries are skipped.
+func GetEnvIntMap(key string) map[string]int {
+	values := make(map[string]int)
+
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, valueStr, ok := strings.Cut(entry, ":")
+		if !ok {
+			log.Printf("[WARN][GetEnvIntMap] ignoring malformed entry %q of %s", entry, key)
+			continue
+		}
+
+		value, err := strconv.Atoi(strings.TrimSpace(valueStr))
+		if err != nil {
+			log.Printf("[WARN][GetEnvIntMap] ignoring malformed entry %q of %s: %v", entry, key, err)
+			continue
+		}
+
+		values[strings.TrimSpace(name)] = value
+	}
+
+	return values
+}
@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	LANGUAGE_EN = "en"
+	LANGUAGE_ID = "id"
+)
+
+// messageFiles holds one catalog per language, named <language>.json. Keys are
+// message ids or validation error codes, values may contain {param}
+// placeholders.
+//
+//go:embed data/messages/*.json
+var messageFiles embed.FS
+
+var (
+	MessageCatalog     map[string]map[string]string
+	messageCatalogOnce sync.Once
+)
+
+func getMessageCatalog() map[string]map[string]string {
+	messageCatalogOnce.Do(func() {
+		if MessageCatalog != nil {
+			return
+		}
+
+		MessageCatalog = make(map[string]map[string]string)
+
+		entries, err := messageFiles.ReadDir("data/messages")
+		if err != nil {
+			log.Println("[ERROR][getMessageCatalog] failed to read embedded message catalog", err)
+			return
+		}
+
+		for _, entry := range entries {
+			data, err := messageFiles.ReadFile(path.Join("data/messages", entry.Name()))
+			if err != nil {
+				log.Println("[ERROR][getMessageCatalog] failed to read", entry.Name(), err)
+				continue
+			}
+
+			var messages map[string]string
+			if err := json.Unmarshal(data, &messages); err != nil {
+				log.Println("[ERROR][getMessageCatalog] failed to parse", entry.Name(), err)
+				continue
+			}
+
+			MessageCatalog[strings.TrimSuffix(entry.Name(), ".json")] = messages
+		}
+	})
+	return MessageCatalog
+}
+
+func IsSupportedLanguage(lang string) bool {
+	_, ok := getMessageCatalog()[lang]
+	return ok
+}
+
+// SupportedLanguages lists the languages of the message catalog, sorted.
+func SupportedLanguages() []string {
+	languages := make([]string, 0, len(getMessageCatalog()))
+	for lang := range getMessageCatalog() {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// DefaultLanguage is used when neither the user nor the request picked a
+// supported language. It is read from DEFAULT_LANGUAGE.
+func DefaultLanguage() string {
+	lang := strings.ToLower(GetEnvString("DEFAULT_LANGUAGE", LANGUAGE_EN))
+	if !IsSupportedLanguage(lang) {
+		return LANGUAGE_EN
+	}
+	return lang
+}
+
+// Localize returns the message for key in lang with its {param} placeholders
+// replaced. Missing translations fall back to English, then to the key itself.
+func Localize(lang, key string, params map[string]interface{}) string {
+	catalog := getMessageCatalog()
+
+	message, ok := catalog[lang][key]
+	if !ok {
+		lang = LANGUAGE_EN
+		message, ok = catalog[LANGUAGE_EN][key]
+	}
+	if !ok {
+		log.Println("[WARN][Localize] missing message", key)
+		return key
+	}
+
+	if len(params) == 0 {
+		return message
+	}
+
+	replacements := make([]string, 0, len(params)*2)
+	for name, value := range params {
+		replacements = append(replacements, "{"+name+"}", formatMessageParam(lang, value))
+	}
+
+	return strings.NewReplacer(replacements...).Replace(message)
+}
+
+func formatMessageParam(lang string, value interface{}) string {
+	switch v := value.(type) {
+	case []string:
+		return strings.Join(v, ", ")
+	case []int:
+		return formatIntAlternatives(lang, v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// formatIntAlternatives writes a list of accepted numbers as a range when it
+// is contiguous ("8 to 12"), or as alternatives otherwise ("9 or 11").
+func formatIntAlternatives(lang string, values []int) string {
+	sorted := append([]int{}, values...)
+	sort.Ints(sorted)
+
+	switch {
+	case len(sorted) == 0:
+		return ""
+	case len(sorted) == 1:
+		return strconv.Itoa(sorted[0])
+	case sorted[len(sorted)-1]-sorted[0] == len(sorted)-1:
+		return Localize(lang, "LIST_RANGE", map[string]interface{}{
+			"first": sorted[0],
+			"last":  sorted[len(sorted)-1],
+		})
+	}
+
+	items := make([]string, 0, len(sorted)-1)
+	for _, v := range sorted[:len(sorted)-1] {
+		items = append(items, strconv.Itoa(v))
+	}
+
+	return Localize(lang, "LIST_ALTERNATIVES", map[string]interface{}{
+		"items": strings.Join(items, ", "),
+		"last":  sorted[len(sorted)-1],
+	})
+}
+
+// ParseAcceptLanguage returns the supported language the Accept-Language
+// header prefers most, or "" when it names none of them. Region subtags are
+// ignored, so "id-ID" selects "id".
+func ParseAcceptLanguage(header string) string {
+	var (
+		best        string
+		bestQuality float64
+	)
+
+	for _, part := range strings.Split(header, ",") {
+		tag, quality := strings.TrimSpace(part), 1.0
+
+		if idx := strings.Index(tag, ";"); idx >= 0 {
+			for _, param := range strings.Split(tag[idx+1:], ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if name != "q" {
+					continue
+				}
+				q, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					q = 0
+				}
+				quality = q
+			}
+			tag = strings.TrimSpace(tag[:idx])
+		}
+
+		lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if quality <= bestQuality || !IsSupportedLanguage(lang) {
+			continue
+		}
+
+		best, bestQuality = lang, quality
+	}
+
+	return best
+}
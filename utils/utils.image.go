@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"net/http"
+
+	// registered for image.Decode
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/pkg/errors"
+)
+
+// the image types SniffImageType recognizes
+const (
+	IMAGE_TYPE_JPEG = "image/jpeg"
+	IMAGE_TYPE_PNG  = "image/png"
+	IMAGE_TYPE_GIF  = "image/gif"
+)
+
+var IMAGE_TYPES = []string{IMAGE_TYPE_JPEG, IMAGE_TYPE_PNG, IMAGE_TYPE_GIF}
+
+const imageJPEGQuality = 85
+
+// SniffImageType returns the type of the image from its content, regardless
+// of what the client claims, or "" when it is none of IMAGE_TYPES.
+func SniffImageType(content []byte) string {
+	contentType := http.DetectContentType(content)
+	for _, imageType := range IMAGE_TYPES {
+		if contentType == imageType {
+			return imageType
+		}
+	}
+
+	return ""
+}
+
+// DecodeImageConfig returns the dimensions of the image without decoding its
+// pixels, so that huge images can be refused before they take up memory.
+func DecodeImageConfig(content []byte) (width, height int, err error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return 0, 0, errors.WithStack(err)
+	}
+
+	return config.Width, config.Height, nil
+}
+
+// DecodeImage decodes a JPEG, PNG or GIF image, the first frame of an
+// animated GIF.
+func DecodeImage(content []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(content))
+	return img, errors.WithStack(err)
+}
+
+// SquareThumbnails crops the center square of img and scales it to each of
+// sizes, in pixels. Transparent areas become white since thumbnails are
+// encoded as JPEG. The sizes should be ordered from the largest, each one is
+// scaled from the previous.
+func SquareThumbnails(img image.Image, sizes []int) []*image.RGBA {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+
+	crop := image.Rect(0, 0, side, side)
+	offset := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+
+	square := image.NewRGBA(crop)
+	draw.Draw(square, crop, image.White, image.Point{}, draw.Src)
+	draw.Draw(square, crop, img, offset, draw.Over)
+
+	thumbnails := make([]*image.RGBA, 0, len(sizes))
+	src := square
+	for _, size := range sizes {
+		src = scaleRGBA(src, size)
+		thumbnails = append(thumbnails, src)
+	}
+
+	return thumbnails
+}
+
+// scaleRGBA scales the square src to size x size. Each pixel is the average
+// of the source pixels it covers, which keeps downscaled photos smooth, an
+// upscaled image repeats its pixels.
+func scaleRGBA(src *image.RGBA, size int) *image.RGBA {
+	srcSize := src.Bounds().Dx()
+	if srcSize == size {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0, y1 := scaleSpan(y, size, srcSize)
+		for x := 0; x < size; x++ {
+			x0, x1 := scaleSpan(x, size, srcSize)
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += int(p[0])
+					g += int(p[1])
+					b += int(p[2])
+					a += int(p[3])
+					n++
+				}
+			}
+
+			p := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4]
+			p[0] = uint8(r / n)
+			p[1] = uint8(g / n)
+			p[2] = uint8(b / n)
+			p[3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+// scaleSpan returns the source pixels [from, to) the destination pixel i of
+// dstSize covers in srcSize, at least one.
+func scaleSpan(i, dstSize, srcSize int) (int, int) {
+	from := i * srcSize / dstSize
+	to := (i + 1) * srcSize / dstSize
+	if to <= from {
+		to = from + 1
+	}
+
+	return from, to
+}
+
+// EncodeJPEG encodes img as a JPEG file.
+func EncodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: imageJPEGQuality}); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -13,11 +13,125 @@ import (
 	"github.com/pkg/errors"
 )
 
+const (
+	TOKEN_SCOPE_PASSWORD_EXPIRED    = "password_expired"
+	TOKEN_SCOPE_OIDC_FLOW           = "oidc_flow"
+	TOKEN_SCOPE_ACCOUNT_RESTORATION = "account_restoration"
+)
+
 var (
 	KeyDataPrivate, KeyDataPublic []byte
+
+	ErrTokenScopeNotAllowed = errors.New("token scope is not allowed here")
 )
 
-func GenerateToken(id int64) (string, error) {
+// TokenClaims are what a regular token tells about its user.
+type TokenClaims struct {
+	Id int64
+	// Roles are the roles of the user when the token was issued
+	Roles []string
+	// ActorId is the admin acting as the user with an impersonation token, 0
+	// for the tokens of the user themselves
+	ActorId int64
+	// ImpersonationId is the impersonation an impersonation token was issued
+	// for
+	ImpersonationId int64
+	// IssuedAt is when the user logged in, tokens are only issued at login.
+	// It is zero for impersonation tokens and tokens issued before it was
+	// added.
+	IssuedAt time.Time
+}
+
+func GenerateToken(id int64, roles []string) (string, error) {
+	tokenLifespanStr := os.Getenv("JWT_LIVESPAN")
+	tokenLifespan, err := strconv.Atoi(tokenLifespanStr)
+	if err != nil {
+		log.Println("[WARN][GenerateToken] error when converting tokenLifespan", errors.WithStack(err))
+	}
+	if tokenLifespan == 0 {
+		tokenLifespan = 60
+	}
+
+	if roles == nil {
+		roles = []string{}
+	}
+
+	return signToken(jwt.MapClaims{
+		"id":    id,
+		"roles": roles,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute * time.Duration(tokenLifespan)).Unix(),
+	})
+}
+
+// GenerateImpersonationToken creates a token of the user id for the admin
+// actorId, named by its act claim. It carries no roles, so that acting as a
+// user never grants more than the user has.
+func GenerateImpersonationToken(id, actorId, impersonationId int64, expiresAt time.Time) (string, error) {
+	return signToken(jwt.MapClaims{
+		"id":    id,
+		"roles": []string{},
+		"act": map[string]interface{}{
+			"id": actorId,
+		},
+		"jti": strconv.FormatInt(impersonationId, 10),
+		"exp": expiresAt.Unix(),
+	})
+}
+
+// GenerateScopedToken creates a short lived token that is only accepted by
+// endpoints asking for the same scope, see TokenParseScoped.
+func GenerateScopedToken(id int64, scope string, lifespan time.Duration) (string, error) {
+	return signToken(jwt.MapClaims{
+		"id":    id,
+		"scope": scope,
+		"exp":   time.Now().Add(lifespan).Unix(),
+	})
+}
+
+// OIDCFlow is what the callback of an OpenID Connect login needs to know
+// about the login that was started, see GenerateOIDCFlowToken.
+type OIDCFlow struct {
+	Provider     string
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+// GenerateOIDCFlowToken keeps flow with the browser between the start and the
+// callback of an OpenID Connect login. It carries no user id, so it is never
+// accepted as an access token.
+func GenerateOIDCFlowToken(flow OIDCFlow, lifespan time.Duration) (string, error) {
+	return signToken(jwt.MapClaims{
+		"scope":         TOKEN_SCOPE_OIDC_FLOW,
+		"provider":      flow.Provider,
+		"state":         flow.State,
+		"nonce":         flow.Nonce,
+		"code_verifier": flow.CodeVerifier,
+		"exp":           time.Now().Add(lifespan).Unix(),
+	})
+}
+
+func ParseOIDCFlowToken(tokenString string) (OIDCFlow, error) {
+	claims, err := parseTokenClaims(tokenString)
+	if err != nil {
+		return OIDCFlow{}, err
+	}
+
+	if tokenScope, _ := claims["scope"].(string); tokenScope != TOKEN_SCOPE_OIDC_FLOW {
+		return OIDCFlow{}, errors.WithStack(ErrTokenScopeNotAllowed)
+	}
+
+	var flow OIDCFlow
+	flow.Provider, _ = claims["provider"].(string)
+	flow.State, _ = claims["state"].(string)
+	flow.Nonce, _ = claims["nonce"].(string)
+	flow.CodeVerifier, _ = claims["code_verifier"].(string)
+
+	return flow, nil
+}
+
+func signToken(claims jwt.MapClaims) (string, error) {
 	var (
 		err error
 	)
@@ -35,19 +149,7 @@ func GenerateToken(id int64) (string, error) {
 		return "", errors.WithStack(errors.New("Error when generate token"))
 	}
 
-	tokenLifespanStr := os.Getenv("JWT_LIVESPAN")
-	tokenLifespan, err := strconv.Atoi(tokenLifespanStr)
-	if err != nil {
-		log.Println("[WARN][GenerateToken] error when converting tokenLifespan", errors.WithStack(err))
-	}
-	if tokenLifespan == 0 {
-		tokenLifespan = 60
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
-		"id":  id,
-		"exp": time.Now().Add(time.Minute * time.Duration(tokenLifespan)).Unix(),
-	})
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
 
 	tokenString, err := token.SignedString(key)
 
@@ -65,7 +167,106 @@ func TokenValidity(ctx echo.Context) (int64, error) {
 	return TokenParse(tokenString)
 }
 
+// TokenParse validates a regular token and returns the user id. Scoped
+// tokens are rejected.
 func TokenParse(tokenString string) (int64, error) {
+	claims, err := TokenParseClaims(tokenString)
+	if err != nil {
+		return 0, err
+	}
+
+	return claims.Id, nil
+}
+
+func TokenValidityClaims(ctx echo.Context) (TokenClaims, error) {
+
+	tokenString := ExtractToken(ctx)
+
+	return TokenParseClaims(tokenString)
+}
+
+// TokenParseClaims validates a regular token like TokenParse and returns its
+// claims. Tokens issued before roles were added have none. Impersonation
+// tokens are regular tokens with an actor.
+func TokenParseClaims(tokenString string) (TokenClaims, error) {
+	claims, err := parseTokenClaims(tokenString)
+	if err != nil {
+		return TokenClaims{}, err
+	}
+
+	if _, ok := claims["scope"]; ok {
+		return TokenClaims{}, errors.WithStack(ErrTokenScopeNotAllowed)
+	}
+
+	id, err := tokenUserId(claims)
+	if err != nil {
+		return TokenClaims{}, err
+	}
+
+	output := TokenClaims{
+		Id: id,
+	}
+
+	rolesRaw, _ := claims["roles"].([]interface{})
+	for _, roleRaw := range rolesRaw {
+		if role, ok := roleRaw.(string); ok {
+			output.Roles = append(output.Roles, role)
+		}
+	}
+
+	if issuedAt, err := claims.GetIssuedAt(); err == nil && issuedAt != nil {
+		output.IssuedAt = issuedAt.Time
+	}
+
+	if actRaw, ok := claims["act"]; ok {
+		act, _ := actRaw.(map[string]interface{})
+		actorId, err := tokenUserId(act)
+		if err != nil {
+			return TokenClaims{}, errors.Wrap(err, "act")
+		}
+
+		output.ActorId = actorId
+		jti, _ := claims["jti"].(string)
+		output.ImpersonationId, _ = strconv.ParseInt(jti, 10, 64)
+		// acting as a user never counts as a login of the user
+		output.IssuedAt = time.Time{}
+	}
+
+	return output, nil
+}
+
+func TokenValidityScoped(ctx echo.Context, scope string) (int64, error) {
+
+	tokenString := ExtractToken(ctx)
+
+	return TokenParseScoped(tokenString, scope)
+}
+
+// TokenParseScoped validates a token created by GenerateScopedToken for scope
+// and returns the user id.
+func TokenParseScoped(tokenString string, scope string) (int64, error) {
+	claims, err := parseTokenClaims(tokenString)
+	if err != nil {
+		return 0, err
+	}
+
+	if tokenScope, _ := claims["scope"].(string); tokenScope != scope {
+		return 0, errors.WithStack(ErrTokenScopeNotAllowed)
+	}
+
+	return tokenUserId(claims)
+}
+
+func tokenUserId(claims jwt.MapClaims) (int64, error) {
+	idRaw, ok := claims["id"].(float64)
+	if !ok {
+		return 0, errors.New("token has no user id")
+	}
+
+	return int64(idRaw), nil
+}
+
+func parseTokenClaims(tokenString string) (jwt.MapClaims, error) {
 	var (
 		err error
 	)
@@ -73,14 +274,14 @@ func TokenParse(tokenString string) (int64, error) {
 		KeyDataPublic, err = os.ReadFile("rsakey/jwtrsa256.key.pub")
 		if err != nil {
 			log.Println("[ERROR][TokenValid] failed to read public key", err)
-			return 0, errors.WithStack(errors.New("Error when read public key"))
+			return nil, errors.WithStack(errors.New("Error when read public key"))
 		}
 	}
 
 	key, err := jwt.ParseRSAPublicKeyFromPEM(KeyDataPublic)
 	if err != nil {
 		log.Println("[ERROR][TokenValid] failed to parse rsa public key from PEM", err)
-		return 0, errors.WithStack(errors.New("Error when generate token"))
+		return nil, errors.WithStack(errors.New("Error when generate token"))
 	}
 
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
@@ -90,13 +291,10 @@ func TokenParse(tokenString string) (int64, error) {
 		return key, nil
 	})
 	if err != nil {
-		return 0, errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
 
-	claims := token.Claims.(jwt.MapClaims)
-	idRaw := claims["id"].(float64)
-
-	return int64(idRaw), nil
+	return token.Claims.(jwt.MapClaims), nil
 }
 
 func ExtractToken(ctx echo.Context) string {
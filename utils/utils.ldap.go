@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/pkg/errors"
+)
+
+const ldapDefaultTimeout = 10 * time.Second
+
+// ErrLDAPInvalidCredentials means the directory knows no single entry for the
+// identifier or refused the password.
+var ErrLDAPInvalidCredentials = errors.New("invalid directory credentials")
+
+// LDAPEntry is the directory entry of a user who logged in.
+type LDAPEntry struct {
+	// Id is the value of the id attribute, stable across renames of the entry
+	Id       string
+	FullName string
+	Email    string
+}
+
+// LDAPDirectory is an LDAP or Active Directory server that verifies the
+// password of a login with a bind as the entry found for the identifier. The
+// routing rules decide which logins it serves. Safe for concurrent use, every
+// login uses its own connection.
+type LDAPDirectory struct {
+	Name string
+	// URL is ldap://host:port or ldaps://host:port
+	URL      string
+	StartTLS bool
+	// BindDN and BindPassword are the service account used to find the entry
+	// of a login, the search is anonymous when BindDN is empty
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// EmailFilter and PhoneFilter find the entry of a login, {identifier} is
+	// replaced by the escaped email or phone number. A login with an
+	// identifier type without a filter is refused.
+	EmailFilter string
+	PhoneFilter string
+
+	IdAttribute    string
+	NameAttribute  string
+	EmailAttribute string
+
+	// EmailDomains and PhonePrefixes route logins to the directory, by the
+	// domain of the email or the start of the phone number in E.164 format
+	EmailDomains  []string
+	PhonePrefixes []string
+	Timeout       time.Duration
+}
+
+// Serves reports whether a login with the identifier should be verified by
+// the directory.
+func (d *LDAPDirectory) Serves(identifierType, identifier string) bool {
+	switch identifierType {
+	case "email":
+		_, domain, ok := strings.Cut(identifier, "@")
+		return ok && d.IsEmailDomain(domain)
+	case "phone":
+		for _, prefix := range d.PhonePrefixes {
+			if strings.HasPrefix(identifier, prefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// IsEmailDomain reports whether the directory is responsible for the
+// addresses of domain.
+func (d *LDAPDirectory) IsEmailDomain(domain string) bool {
+	for _, routed := range d.EmailDomains {
+		if strings.EqualFold(domain, routed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Authenticate finds the entry of the identifier and binds as it with
+// password. ErrLDAPInvalidCredentials is returned when the directory does not
+// accept the login, other errors mean the directory could not be asked.
+func (d *LDAPDirectory) Authenticate(ctx context.Context, identifierType, identifier, password string) (LDAPEntry, error) {
+	filter := d.PhoneFilter
+	if identifierType == "email" {
+		filter = d.EmailFilter
+	}
+
+	// an empty password would make an unauthenticated bind, which succeeds
+	if filter == "" || identifier == "" || password == "" {
+		return LDAPEntry{}, ErrLDAPInvalidCredentials
+	}
+
+	conn, err := d.dial(ctx)
+	if err != nil {
+		return LDAPEntry{}, errors.WithStack(err)
+	}
+	defer conn.Close()
+
+	if d.BindDN != "" {
+		if err := conn.Bind(d.BindDN, d.BindPassword); err != nil {
+			return LDAPEntry{}, errors.Wrap(err, "service account bind")
+		}
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		d.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		// two entries are enough to tell the identifier is ambiguous
+		2, int(d.timeout().Seconds()), false,
+		strings.ReplaceAll(filter, "{identifier}", ldap.EscapeFilter(identifier)),
+		[]string{d.IdAttribute, d.NameAttribute, d.EmailAttribute},
+		nil,
+	))
+
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		log.Printf("[WARN][LDAPDirectory.Authenticate] %s has several entries for the identifier", d.Name)
+		return LDAPEntry{}, ErrLDAPInvalidCredentials
+	}
+
+	if err != nil {
+		return LDAPEntry{}, errors.Wrap(err, "search")
+	}
+
+	if len(result.Entries) != 1 {
+		if len(result.Entries) > 1 {
+			log.Printf("[WARN][LDAPDirectory.Authenticate] %s has several entries for the identifier", d.Name)
+		}
+
+		return LDAPEntry{}, ErrLDAPInvalidCredentials
+	}
+
+	entry := result.Entries[0]
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return LDAPEntry{}, ErrLDAPInvalidCredentials
+		}
+
+		return LDAPEntry{}, errors.Wrap(err, "user bind")
+	}
+
+	id := entry.GetRawAttributeValue(d.IdAttribute)
+	if len(id) == 0 {
+		return LDAPEntry{}, errors.Errorf("entry of %s has no %s", d.Name, d.IdAttribute)
+	}
+
+	return LDAPEntry{
+		Id:       ldapIdString(id),
+		FullName: strings.TrimSpace(entry.GetAttributeValue(d.NameAttribute)),
+		Email:    strings.TrimSpace(entry.GetAttributeValue(d.EmailAttribute)),
+	}, nil
+}
+
+func (d *LDAPDirectory) dial(ctx context.Context) (*ldap.Conn, error) {
+	dialer := &net.Dialer{Timeout: d.timeout()}
+	if deadline, ok := ctx.Deadline(); ok {
+		dialer.Deadline = deadline
+	}
+
+	conn, err := ldap.DialURL(d.URL, ldap.DialWithDialer(dialer))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	conn.SetTimeout(d.timeout())
+
+	if d.StartTLS {
+		u, err := url.Parse(d.URL)
+		if err != nil {
+			conn.Close()
+			return nil, errors.WithStack(err)
+		}
+
+		if err := conn.StartTLS(&tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}); err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "start tls")
+		}
+	}
+
+	return conn, nil
+}
+
+func (d *LDAPDirectory) timeout() time.Duration {
+	if d.Timeout <= 0 {
+		return ldapDefaultTimeout
+	}
+
+	return d.Timeout
+}
+
+// ldapIdString keeps textual ids like entryUUID and hex encodes binary ones
+// like objectGUID of Active Directory.
+func ldapIdString(id []byte) string {
+	if utf8.Valid(id) {
+		return string(id)
+	}
+
+	return hex.EncodeToString(id)
+}
+
+// NewLDAPDirectoriesFromEnv reads the directories named in LDAP_DIRECTORIES,
+// each configured through LDAP_<NAME>_URL, _BASE_DN, _BIND_DN,
+// _BIND_PASSWORD, _START_TLS, _EMAIL_FILTER, _PHONE_FILTER, _ID_ATTRIBUTE,
+// _NAME_ATTRIBUTE, _EMAIL_ATTRIBUTE, _EMAIL_DOMAINS, _PHONE_PREFIXES and
+// _TIMEOUT_SECONDS. The directories are returned in the order they are named,
+// the first one that serves a login verifies it.
+func NewLDAPDirectoriesFromEnv() []*LDAPDirectory {
+	var directories []*LDAPDirectory
+
+	for _, name := range strings.Split(os.Getenv("LDAP_DIRECTORIES"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if !IsValidExternalIdentityType(name) {
+			log.Printf("[WARN][NewLDAPDirectoriesFromEnv] ignoring directory %q, names are lower case letters, digits and \"-\" and must not be phone or email", name)
+			continue
+		}
+
+		prefix := "LDAP_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		directory := &LDAPDirectory{
+			Name:           name,
+			URL:            GetEnvString(prefix+"URL", ""),
+			StartTLS:       GetEnvBool(prefix+"START_TLS", false),
+			BindDN:         GetEnvString(prefix+"BIND_DN", ""),
+			BindPassword:   os.Getenv(prefix + "BIND_PASSWORD"),
+			BaseDN:         GetEnvString(prefix+"BASE_DN", ""),
+			EmailFilter:    GetEnvString(prefix+"EMAIL_FILTER", "(mail={identifier})"),
+			PhoneFilter:    GetEnvString(prefix+"PHONE_FILTER", "(telephoneNumber={identifier})"),
+			IdAttribute:    GetEnvString(prefix+"ID_ATTRIBUTE", "entryUUID"),
+			NameAttribute:  GetEnvString(prefix+"NAME_ATTRIBUTE", "displayName"),
+			EmailAttribute: GetEnvString(prefix+"EMAIL_ATTRIBUTE", "mail"),
+			EmailDomains:   GetEnvList(prefix + "EMAIL_DOMAINS"),
+			PhonePrefixes:  GetEnvList(prefix + "PHONE_PREFIXES"),
+			Timeout:        time.Duration(GetEnvInt(prefix+"TIMEOUT_SECONDS", 10)) * time.Second,
+		}
+
+		if directory.URL == "" || directory.BaseDN == "" {
+			log.Printf("[WARN][NewLDAPDirectoriesFromEnv] ignoring directory %q, %sURL and %sBASE_DN are required", name, prefix, prefix)
+			continue
+		}
+
+		directories = append(directories, directory)
+	}
+
+	return directories
+}
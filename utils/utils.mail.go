@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	MAIL_SENDER_LOG  = "log"
+	MAIL_SENDER_SMTP = "smtp"
+)
+
+type Mail struct {
+	To      string
+	Subject string
+	// Body is plain text
+	Body string
+}
+
+// MailSender delivers mails to users. Implementations must be safe for
+// concurrent use.
+type MailSender interface {
+	Send(ctx context.Context, mail Mail) error
+}
+
+// LogMailSender only logs mails, it is meant for local development and is
+// the default when MAIL_SENDER is not set.
+type LogMailSender struct{}
+
+func (s *LogMailSender) Send(ctx context.Context, mail Mail) error {
+	log.Printf("[INFO][LogMailSender] mail to %s, subject %q:\n%s", mail.To, mail.Subject, mail.Body)
+	return nil
+}
+
+// SMTPMailSender sends mails through an SMTP relay. Auth may be nil for
+// relays that do not require authentication.
+type SMTPMailSender struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+func NewSMTPMailSender(addr, from, username, password string) *SMTPMailSender {
+	var auth smtp.Auth
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPMailSender{
+		Addr: addr,
+		From: from,
+		Auth: auth,
+	}
+}
+
+func (s *SMTPMailSender) Send(ctx context.Context, mail Mail) error {
+	if strings.ContainsAny(mail.To+mail.Subject, "\r\n") {
+		return errors.New("mail header must not contain a line break")
+	}
+
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		s.From,
+		mail.To,
+		mime.QEncoding.Encode("utf-8", mail.Subject),
+		time.Now().Format(time.RFC1123Z),
+		strings.ReplaceAll(mail.Body, "\n", "\r\n"),
+	)
+
+	err := smtp.SendMail(s.Addr, s.Auth, s.From, []string{mail.To}, []byte(message))
+	return errors.WithStack(err)
+}
+
+// NewMailSenderFromEnv builds the mail sender from MAIL_SENDER ("log" or
+// "smtp"). The SMTP sender is configured with SMTP_ADDR, SMTP_USERNAME,
+// SMTP_PASSWORD and MAIL_FROM.
+func NewMailSenderFromEnv() MailSender {
+	switch sender := GetEnvString("MAIL_SENDER", MAIL_SENDER_LOG); sender {
+	case MAIL_SENDER_SMTP:
+		return NewSMTPMailSender(
+			GetEnvString("SMTP_ADDR", "localhost:25"),
+			GetEnvString("MAIL_FROM", "no-reply@localhost"),
+			GetEnvString("SMTP_USERNAME", ""),
+			GetEnvString("SMTP_PASSWORD", ""),
+		)
+	case MAIL_SENDER_LOG:
+		return &LogMailSender{}
+	default:
+		log.Printf("[WARN][NewMailSenderFromEnv] unknown MAIL_SENDER %q, mails are only logged", sender)
+		return &LogMailSender{}
+	}
+}
@@ -0,0 +1,431 @@
+package utils
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+)
+
+const (
+	oidcHTTPTimeout = 10 * time.Second
+	// oidcMaxResponseSize bounds what is read from a provider
+	oidcMaxResponseSize = 1 << 20
+	// oidcClockSkew is tolerated on the time claims of ID tokens
+	oidcClockSkew = time.Minute
+)
+
+// ErrOIDCInvalid means the provider refused the login or answered with
+// something that can not be trusted, the user has to start over.
+var ErrOIDCInvalid = errors.New("invalid OpenID Connect login")
+
+// externalIdentityType is the name of a login provider or directory. It is
+// also stored as identities.type, so it must fit there and must not be
+// mistaken for the identities users manage themselves.
+var externalIdentityType = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// OIDCClaims are the claims of a verified ID token that identify the user.
+type OIDCClaims struct {
+	Subject string
+	// Email is only meaningful when EmailVerified is set
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// OIDCProvider is an OpenID Connect provider users can log in with through
+// the authorization code flow with PKCE. The endpoints and signing keys are
+// discovered from the issuer on first use. Safe for concurrent use.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	// RedirectURL is the callback registered at the provider
+	RedirectURL string
+	Scopes      []string
+	// EmailDomains are the domains whose addresses the provider is trusted
+	// with. A verified address of these domains links the login to the user
+	// who has it, addresses of other domains are ignored
+	EmailDomains []string
+	HTTPClient   *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcTokenResponse struct {
+	IdToken string `json:"id_token"`
+}
+
+type oidcJWKS struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+type oidcIdTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// AuthCodeURL returns the URL of the provider the user is sent to. The
+// provider hands state back to the callback and puts nonce into the ID token,
+// codeVerifier is only sent on Exchange.
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.ClientId)
+	query.Set("redirect_uri", p.RedirectURL)
+	query.Set("scope", strings.Join(p.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems the authorization code and verifies the returned ID token:
+// signature, issuer, audience, expiry and nonce. A refused code or an invalid
+// token returns an error wrapping ErrOIDCInvalid.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (OIDCClaims, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return OIDCClaims{}, errors.WithStack(err)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return OIDCClaims{}, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.ClientId), url.QueryEscape(p.ClientSecret))
+
+	var tokenResp oidcTokenResponse
+	status, err := p.doJSON(req, &tokenResp)
+	if err != nil {
+		return OIDCClaims{}, errors.WithStack(err)
+	}
+
+	if status == http.StatusBadRequest || status == http.StatusUnauthorized {
+		// invalid_grant and friends, e.g. a reused or expired code
+		return OIDCClaims{}, errors.Wrapf(ErrOIDCInvalid, "token endpoint answered %d", status)
+	}
+
+	if status != http.StatusOK {
+		return OIDCClaims{}, errors.Errorf("token endpoint of %s answered %d", p.Name, status)
+	}
+
+	if tokenResp.IdToken == "" {
+		return OIDCClaims{}, errors.Wrap(ErrOIDCInvalid, "token response has no id_token")
+	}
+
+	return p.verifyIdToken(ctx, tokenResp.IdToken, nonce)
+}
+
+func (p *OIDCProvider) verifyIdToken(ctx context.Context, idToken, nonce string) (OIDCClaims, error) {
+	var (
+		claims  oidcIdTokenClaims
+		keyErr  error
+		options = []jwt.ParserOption{
+			jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+			jwt.WithIssuer(p.Issuer),
+			jwt.WithAudience(p.ClientId),
+			jwt.WithExpirationRequired(),
+			jwt.WithIssuedAt(),
+			jwt.WithLeeway(oidcClockSkew),
+		}
+	)
+
+	_, err := jwt.ParseWithClaims(idToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		key, err := p.getKey(ctx, kid)
+		if err != nil {
+			keyErr = err
+		}
+		return key, err
+	}, options...)
+
+	if keyErr != nil && !errors.Is(keyErr, ErrOIDCInvalid) {
+		// the keys could not be fetched, that is not the fault of the token
+		return OIDCClaims{}, errors.WithStack(keyErr)
+	}
+
+	if err != nil {
+		return OIDCClaims{}, errors.Wrap(ErrOIDCInvalid, err.Error())
+	}
+
+	if claims.Subject == "" {
+		return OIDCClaims{}, errors.Wrap(ErrOIDCInvalid, "id_token has no subject")
+	}
+
+	if nonce == "" || claims.Nonce != nonce {
+		return OIDCClaims{}, errors.Wrap(ErrOIDCInvalid, "id_token nonce does not match")
+	}
+
+	return OIDCClaims{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// getKey returns the signing key kid. The keys are fetched again once when kid
+// is unknown, providers rotate their keys.
+func (p *OIDCProvider) getKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+
+	if ok {
+		return key, nil
+	}
+
+	keys, err := p.fetchKeys(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	key, ok = keys[kid]
+	if !ok && kid == "" && len(keys) == 1 {
+		// a provider with a single key may leave out the kid
+		for _, key = range keys {
+			ok = true
+		}
+	}
+
+	if !ok {
+		return nil, errors.Wrapf(ErrOIDCInvalid, "unknown signing key %q", kid)
+	}
+
+	return key, nil
+}
+
+func (p *OIDCProvider) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	discovery, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery.JWKSURI, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var jwks oidcJWKS
+	status, err := p.doJSON(req, &jwks)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if status != http.StatusOK {
+		return nil, errors.Errorf("jwks endpoint of %s answered %d", p.Name, status)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil || len(e) > 4 {
+			log.Printf("[WARN][OIDCProvider] ignoring malformed key %q of %s", jwk.Kid, p.Name)
+			continue
+		}
+
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
+
+func (p *OIDCProvider) getDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	discovery := p.discovery
+	p.mu.Unlock()
+
+	if discovery != nil {
+		return discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	discovery = &oidcDiscovery{}
+	status, err := p.doJSON(req, discovery)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if status != http.StatusOK {
+		return nil, errors.Errorf("discovery of %s answered %d", p.Name, status)
+	}
+
+	if discovery.Issuer != p.Issuer {
+		return nil, errors.Errorf("discovery of %s is for issuer %q", p.Name, discovery.Issuer)
+	}
+
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.Errorf("discovery of %s is missing endpoints", p.Name)
+	}
+
+	p.mu.Lock()
+	p.discovery = discovery
+	p.mu.Unlock()
+
+	return discovery, nil
+}
+
+// doJSON decodes the body into v when the response is 200 and returns the
+// status either way.
+func (p *OIDCProvider) doJSON(req *http.Request, v interface{}) (int, error) {
+	client := p.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: oidcHTTPTimeout}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, oidcMaxResponseSize))
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+
+	err = json.Unmarshal(body, v)
+	return resp.StatusCode, errors.Wrapf(err, "invalid response from %s", req.URL.Host)
+}
+
+// IsEmailDomain reports whether the provider is trusted with the addresses
+// of domain.
+func (p *OIDCProvider) IsEmailDomain(domain string) bool {
+	for _, trusted := range p.EmailDomains {
+		if strings.EqualFold(domain, trusted) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewOIDCProvidersFromEnv builds the providers listed in OIDC_PROVIDERS, a
+// comma separated list of lower case names. Each provider is configured with
+// OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET and
+// optionally OIDC_<NAME>_SCOPES (space separated, "openid email profile" when
+// unset) and OIDC_<NAME>_EMAIL_DOMAINS (comma separated, none when unset),
+// where <NAME> is the upper case name with "-" replaced by "_". The callback
+// is OIDC_REDIRECT_URL with {provider} replaced by the name. Misconfigured
+// providers are skipped.
+func NewOIDCProvidersFromEnv() map[string]*OIDCProvider {
+	var (
+		providers   = make(map[string]*OIDCProvider)
+		redirectURL = GetEnvString("OIDC_REDIRECT_URL", "http://localhost:1323/oidc/{provider}/callback")
+	)
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if !IsValidExternalIdentityType(name) {
+			log.Printf("[WARN][NewOIDCProvidersFromEnv] ignoring provider %q, names are lower case letters, digits and \"-\" and must not be phone or email", name)
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := &OIDCProvider{
+			Name:         name,
+			Issuer:       GetEnvString(prefix+"ISSUER", ""),
+			ClientId:     GetEnvString(prefix+"CLIENT_ID", ""),
+			ClientSecret: GetEnvString(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  strings.ReplaceAll(redirectURL, "{provider}", name),
+			Scopes:       strings.Fields(GetEnvString(prefix+"SCOPES", "openid email profile")),
+			EmailDomains: GetEnvList(prefix + "EMAIL_DOMAINS"),
+		}
+
+		if provider.Issuer == "" || provider.ClientId == "" {
+			log.Printf("[WARN][NewOIDCProvidersFromEnv] ignoring provider %q, %sISSUER and %sCLIENT_ID are required", name, prefix, prefix)
+			continue
+		}
+
+		providers[name] = provider
+	}
+
+	return providers
+}
+
+// IsValidExternalIdentityType reports whether name can be used for a login
+// provider or directory.
+func IsValidExternalIdentityType(name string) bool {
+	return externalIdentityType.MatchString(name) && name != "phone" && name != "email"
+}
@@ -0,0 +1,311 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	PASSWORD_ALGORITHM_BCRYPT   = "bcrypt"
+	PASSWORD_ALGORITHM_ARGON2ID = "argon2id"
+
+	// BCRYPT_MAX_PASSWORD_BYTES is the longest password bcrypt accepts, see
+	// BcryptHasher.Hash
+	BCRYPT_MAX_PASSWORD_BYTES = 72
+)
+
+var (
+	ErrUnknownPasswordHash = errors.New("unknown password hash format")
+	ErrInvalidPasswordHash = errors.New("invalid password hash")
+)
+
+// PasswordHasher hashes and verifies passwords. Hashes are stored as PHC
+// strings (or the modular crypt format for bcrypt) so the algorithm and its
+// parameters can be read back from the stored value.
+type PasswordHasher interface {
+	Algorithm() string
+	Hash(password string) (string, error)
+	Verify(encodedHash, password string) (bool, error)
+	NeedsRehash(encodedHash string) bool
+}
+
+// PasswordHashAlgorithm returns the algorithm used to produce encodedHash,
+// or an empty string when the format is not recognized.
+func PasswordHashAlgorithm(encodedHash string) string {
+	_, encodedHash, _ = SplitPepperedHash(encodedHash)
+
+	switch {
+	case strings.HasPrefix(encodedHash, "$argon2id$"):
+		return PASSWORD_ALGORITHM_ARGON2ID
+	case strings.HasPrefix(encodedHash, "$2a$"),
+		strings.HasPrefix(encodedHash, "$2b$"),
+		strings.HasPrefix(encodedHash, "$2y$"):
+		return PASSWORD_ALGORITHM_BCRYPT
+	default:
+		return ""
+	}
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func NewBcryptHasher(cost int) *BcryptHasher {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	return &BcryptHasher{
+		Cost: cost,
+	}
+}
+
+func (h *BcryptHasher) Algorithm() string {
+	return PASSWORD_ALGORITHM_BCRYPT
+}
+
+// Hash hashes the password with bcrypt. A password longer than
+// BCRYPT_MAX_PASSWORD_BYTES, which bcrypt refuses, is hashed with SHA-256
+// first, so every byte of it still counts.
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword(bcryptPassword(password), h.Cost)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(hashed), nil
+}
+
+func (h *BcryptHasher) Verify(encodedHash, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), bcryptPassword(password))
+	if err != nil {
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return false, errors.WithStack(err)
+	}
+	return true, nil
+}
+
+// bcryptPassword is the input of bcrypt for password: the password itself, or
+// the base64 of its SHA-256 when it is too long for bcrypt.
+func bcryptPassword(password string) []byte {
+	if len(password) <= BCRYPT_MAX_PASSWORD_BYTES {
+		return []byte(password)
+	}
+
+	sum := sha256.Sum256([]byte(password))
+	return []byte(base64.StdEncoding.EncodeToString(sum[:]))
+}
+
+func (h *BcryptHasher) NeedsRehash(encodedHash string) bool {
+	if PasswordHashAlgorithm(encodedHash) != PASSWORD_ALGORITHM_BCRYPT {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	if err != nil {
+		return true
+	}
+	return cost < h.Cost
+}
+
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+type argon2idParams struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func NewArgon2idHasher(memory, iterations uint32, parallelism uint8) *Argon2idHasher {
+	if memory == 0 {
+		memory = 64 * 1024
+	}
+	if iterations == 0 {
+		iterations = 3
+	}
+	if parallelism == 0 {
+		parallelism = 2
+	}
+	return &Argon2idHasher{
+		Memory:      memory,
+		Iterations:  iterations,
+		Parallelism: parallelism,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+func (h *Argon2idHasher) Algorithm() string {
+	return PASSWORD_ALGORITHM_ARGON2ID
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.Memory,
+		h.Iterations,
+		h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(encodedHash, password string) (bool, error) {
+	params, err := decodeArgon2idHash(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), params.salt, params.iterations, params.memory, params.parallelism, uint32(len(params.key)))
+
+	return subtle.ConstantTimeCompare(key, params.key) == 1, nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(encodedHash string) bool {
+	params, err := decodeArgon2idHash(encodedHash)
+	if err != nil {
+		return true
+	}
+	return params.memory < h.Memory ||
+		params.iterations < h.Iterations ||
+		params.parallelism < h.Parallelism ||
+		uint32(len(params.key)) < h.KeyLength
+}
+
+// decodeArgon2idHash parses a PHC string of the form
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func decodeArgon2idHash(encodedHash string) (argon2idParams, error) {
+	var (
+		params  argon2idParams
+		version int
+	)
+
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[1] != PASSWORD_ALGORITHM_ARGON2ID {
+		return params, errors.WithStack(ErrInvalidPasswordHash)
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, errors.WithStack(ErrInvalidPasswordHash)
+	}
+	if version != argon2.Version {
+		return params, errors.WithStack(ErrInvalidPasswordHash)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return params, errors.WithStack(ErrInvalidPasswordHash)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, errors.WithStack(ErrInvalidPasswordHash)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, errors.WithStack(ErrInvalidPasswordHash)
+	}
+
+	params.salt = salt
+	params.key = key
+
+	return params, nil
+}
+
+// MultiPasswordHasher hashes new passwords with Preferred and verifies stored
+// hashes with whichever hasher matches their algorithm, so hashes produced by
+// an older configuration keep working until they are upgraded.
+type MultiPasswordHasher struct {
+	Preferred PasswordHasher
+	Hashers   map[string]PasswordHasher
+}
+
+func NewMultiPasswordHasher(preferred PasswordHasher, legacy ...PasswordHasher) *MultiPasswordHasher {
+	hashers := map[string]PasswordHasher{
+		preferred.Algorithm(): preferred,
+	}
+	for _, h := range legacy {
+		if _, ok := hashers[h.Algorithm()]; !ok {
+			hashers[h.Algorithm()] = h
+		}
+	}
+	return &MultiPasswordHasher{
+		Preferred: preferred,
+		Hashers:   hashers,
+	}
+}
+
+func (h *MultiPasswordHasher) Algorithm() string {
+	return h.Preferred.Algorithm()
+}
+
+func (h *MultiPasswordHasher) Hash(password string) (string, error) {
+	return h.Preferred.Hash(password)
+}
+
+func (h *MultiPasswordHasher) Verify(encodedHash, password string) (bool, error) {
+	hasher, ok := h.Hashers[PasswordHashAlgorithm(encodedHash)]
+	if !ok {
+		return false, errors.WithStack(ErrUnknownPasswordHash)
+	}
+	return hasher.Verify(encodedHash, password)
+}
+
+func (h *MultiPasswordHasher) NeedsRehash(encodedHash string) bool {
+	if PasswordHashAlgorithm(encodedHash) != h.Preferred.Algorithm() {
+		return true
+	}
+	return h.Preferred.NeedsRehash(encodedHash)
+}
+
+// NewPasswordHasherFromEnv builds the password hasher from PASSWORD_HASH_ALGORITHM,
+// BCRYPT_COST and the ARGON2_* variables. Argon2id is used unless bcrypt is
+// explicitly requested; both algorithms are always accepted on verification.
+// When PASSWORD_PEPPER_KEYS is set, passwords are peppered with the key named
+// by PASSWORD_PEPPER_KEY_ID.
+func NewPasswordHasherFromEnv() PasswordHasher {
+	return NewPepperedPasswordHasher(
+		newMultiPasswordHasherFromEnv(),
+		ParsePepperKeys(GetEnvString("PASSWORD_PEPPER_KEYS", "")),
+		GetEnvString("PASSWORD_PEPPER_KEY_ID", ""),
+	)
+}
+
+func newMultiPasswordHasherFromEnv() *MultiPasswordHasher {
+	var (
+		bcryptHasher = NewBcryptHasher(GetEnvInt("BCRYPT_COST", bcrypt.DefaultCost))
+		argonHasher  = NewArgon2idHasher(
+			uint32(GetEnvInt("ARGON2_MEMORY", 64*1024)),
+			uint32(GetEnvInt("ARGON2_ITERATIONS", 3)),
+			uint8(GetEnvInt("ARGON2_PARALLELISM", 2)),
+		)
+	)
+
+	if GetEnvString("PASSWORD_HASH_ALGORITHM", PASSWORD_ALGORITHM_ARGON2ID) == PASSWORD_ALGORITHM_BCRYPT {
+		return NewMultiPasswordHasher(bcryptHasher, argonHasher)
+	}
+
+	return NewMultiPasswordHasher(argonHasher, bcryptHasher)
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBcryptHasher(t *testing.T) {
+	// 64 runes of 3 bytes each, within PASSWORD_MAX_LENGTH but 192 bytes
+	multibyte := strings.Repeat("密", 64)
+	if utf8.RuneCountInString(multibyte) != 64 || len(multibyte) <= BCRYPT_MAX_PASSWORD_BYTES {
+		t.Fatalf("multibyte password has %d runes, %d bytes", utf8.RuneCountInString(multibyte), len(multibyte))
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		// other is a password that must not verify against the hash
+		other string
+	}{
+		{
+			name:     "short",
+			password: "Secret1!",
+			other:    "Secret1?",
+		},
+		{
+			name:     "at the bcrypt limit",
+			password: strings.Repeat("a", BCRYPT_MAX_PASSWORD_BYTES),
+			other:    strings.Repeat("a", BCRYPT_MAX_PASSWORD_BYTES-1) + "b",
+		},
+		{
+			name:     "over the bcrypt limit",
+			password: strings.Repeat("a", BCRYPT_MAX_PASSWORD_BYTES) + "1",
+			other:    strings.Repeat("a", BCRYPT_MAX_PASSWORD_BYTES) + "2",
+		},
+		{
+			name:     "64 multibyte runes",
+			password: multibyte,
+			other:    strings.Repeat("密", 63) + "码",
+		},
+	}
+
+	h := NewBcryptHasher(bcrypt.MinCost)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := h.Hash(tt.password)
+			if err != nil {
+				t.Fatalf("BcryptHasher.Hash() error = %v", err)
+			}
+			assert.Equal(t, PASSWORD_ALGORITHM_BCRYPT, PasswordHashAlgorithm(hash))
+
+			got, err := h.Verify(hash, tt.password)
+			assert.NoError(t, err)
+			assert.True(t, got, "BcryptHasher.Verify() of the password")
+
+			got, err = h.Verify(hash, tt.other)
+			assert.NoError(t, err)
+			assert.False(t, got, "BcryptHasher.Verify() of another password")
+		})
+	}
+}
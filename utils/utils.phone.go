@@ -0,0 +1,180 @@
+package utils
+
+import (
+	_ "embed"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+)
+
+// defaultPhoneMetadata holds the country code, trunk prefix and allowed
+// national number lengths of every region we know how to parse.
+//
+//go:embed data/phone_metadata.json
+var defaultPhoneMetadata []byte
+
+// PhoneRegionMetadata describes the numbering plan of a single region.
+type PhoneRegionMetadata struct {
+	CountryCode           string `json:"country_code"`
+	TrunkPrefix           string `json:"trunk_prefix"`
+	NationalNumberLengths []int  `json:"national_number_lengths"`
+}
+
+// PhoneNumberPolicy decides which phone numbers are accepted. It is loaded
+// from the PHONE_* environment variables, see NewPhoneNumberPolicyFromEnv.
+type PhoneNumberPolicy struct {
+	// DefaultRegion is used for numbers written in national format, e.g. 0812...
+	DefaultRegion  string
+	AllowedRegions []string
+}
+
+var (
+	PhoneMetadata     map[string]PhoneRegionMetadata
+	phoneMetadataOnce sync.Once
+
+	CurrentPhoneNumberPolicy     *PhoneNumberPolicy
+	currentPhoneNumberPolicyOnce sync.Once
+)
+
+func NewPhoneNumberPolicyFromEnv() *PhoneNumberPolicy {
+	var allowedRegions []string
+	for _, region := range strings.Split(GetEnvString("PHONE_ALLOWED_REGIONS", "ID,MY,SG"), ",") {
+		if region = strings.ToUpper(strings.TrimSpace(region)); region != "" {
+			allowedRegions = append(allowedRegions, region)
+		}
+	}
+
+	return &PhoneNumberPolicy{
+		DefaultRegion:  strings.ToUpper(GetEnvString("PHONE_DEFAULT_REGION", "ID")),
+		AllowedRegions: allowedRegions,
+	}
+}
+
+// GetPhoneNumberPolicy returns CurrentPhoneNumberPolicy, loading it from the
+// environment on first use.
+func GetPhoneNumberPolicy() *PhoneNumberPolicy {
+	currentPhoneNumberPolicyOnce.Do(func() {
+		if CurrentPhoneNumberPolicy == nil {
+			CurrentPhoneNumberPolicy = NewPhoneNumberPolicyFromEnv()
+		}
+	})
+	return CurrentPhoneNumberPolicy
+}
+
+func getPhoneMetadata() map[string]PhoneRegionMetadata {
+	phoneMetadataOnce.Do(func() {
+		if PhoneMetadata != nil {
+			return
+		}
+
+		err := json.Unmarshal(defaultPhoneMetadata, &PhoneMetadata)
+		if err != nil {
+			log.Println("[ERROR][getPhoneMetadata] failed to load embedded phone metadata", err)
+			PhoneMetadata = make(map[string]PhoneRegionMetadata)
+		}
+	})
+	return PhoneMetadata
+}
+
+// Normalize parses s, written either in international format (+6281234567890)
+// or in the national format of DefaultRegion (081234567890), and returns it
+// in E.164 format. Spaces, dashes, dots and parentheses are ignored.
+func (p *PhoneNumberPolicy) Normalize(s string) (string, error) {
+	var (
+		metadata       = getPhoneMetadata()
+		cleaned        = strings.Map(dropPhoneSeparator, strings.TrimSpace(s))
+		region         string
+		nationalNumber string
+	)
+
+	if cleaned == "" {
+		return "", NewValidationError(CODE_PHONE_NUMBER_REQUIRED, nil)
+	}
+
+	if strings.HasPrefix(cleaned, "+") {
+		digits := cleaned[1:]
+		if onlyDigits(digits) != digits {
+			return "", NewValidationError(CODE_PHONE_NUMBER_INVALID_CHARACTERS, nil)
+		}
+
+		region = phoneRegionByCountryCode(metadata, digits)
+		if region == "" {
+			return "", NewValidationError(CODE_PHONE_NUMBER_INVALID_COUNTRY_CODE, nil)
+		}
+
+		regionMetadata := metadata[region]
+		nationalNumber = digits[len(regionMetadata.CountryCode):]
+
+		// "+62 0812..." is a common way to write a national number with a country code
+		if regionMetadata.TrunkPrefix == "0" {
+			nationalNumber = strings.TrimPrefix(nationalNumber, "0")
+		}
+	} else {
+		if onlyDigits(cleaned) != cleaned {
+			return "", NewValidationError(CODE_PHONE_NUMBER_INVALID_CHARACTERS, nil)
+		}
+
+		regionMetadata, ok := metadata[p.DefaultRegion]
+		if !ok || regionMetadata.TrunkPrefix == "" || !strings.HasPrefix(cleaned, regionMetadata.TrunkPrefix) {
+			return "", NewValidationError(CODE_PHONE_NUMBER_MISSING_COUNTRY_CODE, nil)
+		}
+
+		region = p.DefaultRegion
+		nationalNumber = cleaned[len(regionMetadata.TrunkPrefix):]
+	}
+
+	if !p.isRegionAllowed(region) {
+		return "", NewValidationError(CODE_PHONE_NUMBER_COUNTRY_NOT_ALLOWED, map[string]interface{}{
+			"country":           region,
+			"allowed_countries": p.AllowedRegions,
+		})
+	}
+
+	regionMetadata := metadata[region]
+	if !containsInt(regionMetadata.NationalNumberLengths, len(nationalNumber)) {
+		return "", NewValidationError(CODE_PHONE_NUMBER_INVALID_LENGTH, map[string]interface{}{
+			"country_code": regionMetadata.CountryCode,
+			"lengths":      regionMetadata.NationalNumberLengths,
+		})
+	}
+
+	return "+" + regionMetadata.CountryCode + nationalNumber, nil
+}
+
+func (p *PhoneNumberPolicy) isRegionAllowed(region string) bool {
+	for _, allowed := range p.AllowedRegions {
+		if allowed == region {
+			return true
+		}
+	}
+	return false
+}
+
+// phoneRegionByCountryCode finds the region whose country code prefixes
+// digits. E.164 country codes are prefix free, so at most one region matches.
+func phoneRegionByCountryCode(metadata map[string]PhoneRegionMetadata, digits string) string {
+	for region, regionMetadata := range metadata {
+		if strings.HasPrefix(digits, regionMetadata.CountryCode) {
+			return region
+		}
+	}
+	return ""
+}
+
+func dropPhoneSeparator(r rune) rune {
+	switch r {
+	case ' ', '-', '.', '(', ')':
+		return -1
+	}
+	return r
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// PasswordPolicy describes the rules a password has to satisfy. It is loaded
+// from the PASSWORD_* environment variables, see NewPasswordPolicyFromEnv.
+type PasswordPolicy struct {
+	MinLength            int
+	MaxLength            int
+	RequireUppercase     bool
+	RequireLowercase     bool
+	RequireNumber        bool
+	RequireSymbol        bool
+	MinStrengthScore     int
+	DisallowPersonalInfo bool
+	RejectBreached       bool
+}
+
+// PasswordUserInfo is the personal data a password must not contain.
+type PasswordUserInfo struct {
+	PhoneNumber string
+	FullName    string
+}
+
+var (
+	CurrentPasswordPolicy     *PasswordPolicy
+	currentPasswordPolicyOnce sync.Once
+)
+
+func NewPasswordPolicyFromEnv() *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength:            GetEnvInt("PASSWORD_MIN_LENGTH", 6),
+		MaxLength:            GetEnvInt("PASSWORD_MAX_LENGTH", 64),
+		RequireUppercase:     GetEnvBool("PASSWORD_REQUIRE_UPPERCASE", true),
+		RequireLowercase:     GetEnvBool("PASSWORD_REQUIRE_LOWERCASE", false),
+		RequireNumber:        GetEnvBool("PASSWORD_REQUIRE_NUMBER", true),
+		RequireSymbol:        GetEnvBool("PASSWORD_REQUIRE_SYMBOL", true),
+		MinStrengthScore:     GetEnvInt("PASSWORD_MIN_STRENGTH_SCORE", 2),
+		DisallowPersonalInfo: GetEnvBool("PASSWORD_DISALLOW_PERSONAL_INFO", true),
+		RejectBreached:       GetEnvBool("PASSWORD_REJECT_BREACHED", true),
+	}
+}
+
+// GetPasswordPolicy returns CurrentPasswordPolicy, loading it from the
+// environment on first use.
+func GetPasswordPolicy() *PasswordPolicy {
+	currentPasswordPolicyOnce.Do(func() {
+		if CurrentPasswordPolicy == nil {
+			CurrentPasswordPolicy = NewPasswordPolicyFromEnv()
+		}
+	})
+	return CurrentPasswordPolicy
+}
+
+// Validate checks s against every rule of the policy and returns a
+// ValidationErrors with one entry per failed rule, or nil.
+func (p *PasswordPolicy) Validate(s string, userInfo PasswordUserInfo) error {
+	var (
+		totalChar                     int
+		upper, lower, number, special bool
+		errs                          ValidationErrors
+	)
+
+	for _, c := range s {
+		switch {
+		case unicode.IsNumber(c):
+			number = true
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			special = true
+		default:
+		}
+		totalChar++
+	}
+
+	lengthParams := map[string]interface{}{"min": p.MinLength, "max": p.MaxLength}
+	switch {
+	case totalChar < p.MinLength:
+		errs = append(errs, NewValidationError(CODE_PASSWORD_TOO_SHORT, lengthParams))
+	case totalChar > p.MaxLength:
+		errs = append(errs, NewValidationError(CODE_PASSWORD_TOO_LONG, lengthParams))
+	}
+
+	if p.RequireUppercase && !upper {
+		errs = append(errs, NewValidationError(CODE_PASSWORD_MISSING_UPPERCASE, map[string]interface{}{"min": 1}))
+	}
+
+	if p.RequireLowercase && !lower {
+		errs = append(errs, NewValidationError(CODE_PASSWORD_MISSING_LOWERCASE, map[string]interface{}{"min": 1}))
+	}
+
+	if p.RequireNumber && !number {
+		errs = append(errs, NewValidationError(CODE_PASSWORD_MISSING_NUMBER, map[string]interface{}{"min": 1}))
+	}
+
+	if p.RequireSymbol && !special {
+		errs = append(errs, NewValidationError(CODE_PASSWORD_MISSING_SYMBOL, map[string]interface{}{"min": 1}))
+	}
+
+	if p.DisallowPersonalInfo {
+		if containsPhoneNumber(s, userInfo.PhoneNumber) {
+			errs = append(errs, NewValidationError(CODE_PASSWORD_CONTAINS_PHONE_NUMBER, nil))
+		}
+
+		if containsName(s, userInfo.FullName) {
+			errs = append(errs, NewValidationError(CODE_PASSWORD_CONTAINS_NAME, nil))
+		}
+	}
+
+	isBreached := p.RejectBreached && IsBreachedPassword(s)
+	if isBreached {
+		errs = append(errs, NewValidationError(CODE_PASSWORD_BREACHED, nil))
+	}
+
+	// a breached password already scores 0, reporting it twice is noise
+	if !isBreached && p.MinStrengthScore > 0 && totalChar > 0 {
+		if score := PasswordStrengthScore(s, userInfo); score < p.MinStrengthScore {
+			errs = append(errs, NewValidationError(CODE_PASSWORD_TOO_WEAK, map[string]interface{}{
+				"score":     score,
+				"min_score": p.MinStrengthScore,
+			}))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// containsPhoneNumber reports whether the digits of the password contain the
+// national part of the phone number (the number without its country code or
+// trunk prefix), or the full number as written.
+func containsPhoneNumber(password, phoneNumber string) bool {
+	digits := onlyDigits(phoneNumber)
+	if len(digits) < 6 {
+		return false
+	}
+
+	passwordDigits := onlyDigits(password)
+	if passwordDigits == "" {
+		return false
+	}
+
+	candidates := []string{digits}
+	switch {
+	case strings.HasPrefix(phoneNumber, "+") && len(digits) > 8:
+		// drop a 1-3 digit country code, keep at least the subscriber part
+		for i := 1; i <= 3; i++ {
+			candidates = append(candidates, digits[i:])
+		}
+	case strings.HasPrefix(digits, "0"):
+		candidates = append(candidates, digits[1:])
+	}
+
+	for _, c := range candidates {
+		if len(c) >= 6 && strings.Contains(passwordDigits, c) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsName(password, fullName string) bool {
+	var (
+		lowerPassword = strings.ToLower(password)
+		parts         = strings.Fields(strings.ToLower(fullName))
+	)
+
+	if len(parts) > 1 && strings.Contains(lowerPassword, strings.Join(parts, "")) {
+		return true
+	}
+
+	for _, part := range parts {
+		if len([]rune(part)) >= 3 && strings.Contains(lowerPassword, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func onlyDigits(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
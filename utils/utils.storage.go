@@ -0,0 +1,310 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	OBJECT_STORAGE_LOCAL = "local"
+	OBJECT_STORAGE_S3    = "s3"
+)
+
+const s3DefaultTimeout = 30 * time.Second
+
+// ObjectStorage keeps files like avatars under keys such as
+// "avatars/1/abc/small.jpg" and serves them to clients. Implementations must
+// be safe for concurrent use.
+type ObjectStorage interface {
+	Put(ctx context.Context, key, contentType string, content []byte) error
+	// Delete succeeds for a key that does not exist
+	Delete(ctx context.Context, key string) error
+	// URL is where clients download the object key
+	URL(key string) string
+}
+
+// LocalObjectStorage keeps the objects as files below Dir, it is meant for
+// local development and is the default when OBJECT_STORAGE is not set. The
+// server has to serve Dir at the path of BaseURL, see Path.
+type LocalObjectStorage struct {
+	Dir     string
+	BaseURL string
+}
+
+func (s *LocalObjectStorage) Put(ctx context.Context, key, contentType string, content []byte) error {
+	name, err := s.filename(key)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// write aside and rename, so that the file is never served half written
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return errors.WithStack(err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(os.Rename(tmp.Name(), name))
+}
+
+func (s *LocalObjectStorage) Delete(ctx context.Context, key string) error {
+	name, err := s.filename(key)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+func (s *LocalObjectStorage) URL(key string) string {
+	return strings.TrimRight(s.BaseURL, "/") + "/" + key
+}
+
+// Path is the path of BaseURL, where the server has to serve Dir.
+func (s *LocalObjectStorage) Path() string {
+	u, err := url.Parse(s.BaseURL)
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+
+	return u.Path
+}
+
+// filename is the file of key, keys must stay inside Dir.
+func (s *LocalObjectStorage) filename(key string) (string, error) {
+	if key == "" || path.IsAbs(key) || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return "", errors.Errorf("invalid object key %q", key)
+	}
+
+	return filepath.Join(s.Dir, filepath.FromSlash(key)), nil
+}
+
+// S3ObjectStorage keeps the objects in a bucket of S3 or of an S3 compatible
+// server like MinIO, requests are signed with AWS Signature Version 4. The
+// objects must be readable at PublicURL, e.g. through a bucket policy or a
+// CDN.
+type S3ObjectStorage struct {
+	// Endpoint is e.g. https://s3.ap-southeast-3.amazonaws.com or
+	// http://localhost:9000
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyId     string
+	SecretAccessKey string
+	// PathStyle addresses the bucket as Endpoint/Bucket rather than as
+	// Bucket.Endpoint, most S3 compatible servers need it
+	PathStyle bool
+	// PublicURL is where clients download the objects, Endpoint/Bucket when
+	// empty
+	PublicURL string
+	Client    *http.Client
+}
+
+func (s *S3ObjectStorage) Put(ctx context.Context, key, contentType string, content []byte) error {
+	return s.do(ctx, http.MethodPut, key, contentType, content)
+}
+
+func (s *S3ObjectStorage) Delete(ctx context.Context, key string) error {
+	// S3 answers 204 for a missing key as well
+	return s.do(ctx, http.MethodDelete, key, "", nil)
+}
+
+func (s *S3ObjectStorage) URL(key string) string {
+	if s.PublicURL != "" {
+		return strings.TrimRight(s.PublicURL, "/") + "/" + key
+	}
+
+	return s.objectURL(key)
+}
+
+func (s *S3ObjectStorage) objectURL(key string) string {
+	endpoint := strings.TrimRight(s.Endpoint, "/")
+	if s.PathStyle {
+		return endpoint + "/" + s.Bucket + "/" + s3EscapePath(key)
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint + "/" + s3EscapePath(key)
+	}
+
+	u.Host = s.Bucket + "." + u.Host
+	return u.String() + "/" + s3EscapePath(key)
+}
+
+func (s *S3ObjectStorage) do(ctx context.Context, method, key, contentType string, content []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key), bytes.NewReader(content))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	s.sign(req, content, time.Now().UTC())
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: s3DefaultTimeout}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.Errorf("s3 %s %s: %s %s", method, key, resp.Status, body)
+	}
+
+	return nil
+}
+
+// sign adds the AWS Signature Version 4 authorization of the request, see
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (s *S3ObjectStorage) sign(req *http.Request, content []byte, now time.Time) {
+	var (
+		amzDate     = now.Format("20060102T150405Z")
+		date        = now.Format("20060102")
+		payloadHash = sha256Hex(content)
+		scope       = date + "/" + s.Region + "/s3/aws4_request"
+	)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyId, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath escapes every segment of key the way S3 expects in the
+// canonical request.
+func s3EscapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(segment), "+", "%2B")
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// NewObjectStorageFromEnv builds the object storage from OBJECT_STORAGE
+// ("local" or "s3"). The local storage is configured with
+// OBJECT_STORAGE_LOCAL_DIR and OBJECT_STORAGE_LOCAL_BASE_URL, the S3 storage
+// with S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY_ID,
+// S3_SECRET_ACCESS_KEY, S3_PATH_STYLE and S3_PUBLIC_URL.
+func NewObjectStorageFromEnv() ObjectStorage {
+	switch storage := GetEnvString("OBJECT_STORAGE", OBJECT_STORAGE_LOCAL); storage {
+	case OBJECT_STORAGE_S3:
+		return &S3ObjectStorage{
+			Endpoint:        GetEnvString("S3_ENDPOINT", "https://s3.amazonaws.com"),
+			Region:          GetEnvString("S3_REGION", "us-east-1"),
+			Bucket:          GetEnvString("S3_BUCKET", ""),
+			AccessKeyId:     GetEnvString("S3_ACCESS_KEY_ID", ""),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle:       GetEnvBool("S3_PATH_STYLE", false),
+			PublicURL:       GetEnvString("S3_PUBLIC_URL", ""),
+		}
+	case OBJECT_STORAGE_LOCAL:
+		return newLocalObjectStorageFromEnv()
+	default:
+		log.Printf("[WARN][NewObjectStorageFromEnv] unknown OBJECT_STORAGE %q, files are kept locally", storage)
+		return newLocalObjectStorageFromEnv()
+	}
+}
+
+func newLocalObjectStorageFromEnv() *LocalObjectStorage {
+	return &LocalObjectStorage{
+		Dir:     GetEnvString("OBJECT_STORAGE_LOCAL_DIR", "./storage"),
+		BaseURL: GetEnvString("OBJECT_STORAGE_LOCAL_BASE_URL", "http://localhost:1323/storage"),
+	}
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+var (
+	keyboardRows = []string{
+		"`1234567890-=",
+		"qwertyuiop[]\\",
+		"asdfghjkl;'",
+		"zxcvbnm,./",
+	}
+
+	commonPasswordWords = []string{
+		"password", "passw0rd", "qwerty", "admin", "welcome", "letmein", "login",
+		"iloveyou", "monkey", "dragon", "master", "secret", "sunshine", "princess",
+		"football", "baseball", "shadow", "michael", "superman", "batman", "trustno1",
+		"indonesia", "jakarta", "bandung", "surabaya", "bismillah", "sayang", "rahasia",
+		"sawit", "changeme", "summer", "winter", "spring", "autumn", "test",
+	}
+)
+
+// PasswordStrengthScore estimates how hard s is to guess on the same 0-4
+// scale as zxcvbn: 0 is too guessable, 4 is very unguessable. The estimate
+// discounts repeated characters, alphabetical or numeric sequences, keyboard
+// walks, common words and the user's own phone number or name.
+func PasswordStrengthScore(s string, userInfo PasswordUserInfo) int {
+	if s == "" || IsBreachedPassword(s) {
+		return 0
+	}
+
+	var (
+		runes  = []rune(s)
+		lower  = strings.ToLower(s)
+		weight = make([]float64, len(runes))
+		pool   float64
+		seen   = make(map[string]bool)
+	)
+
+	for i, c := range runes {
+		weight[i] = 1
+
+		switch {
+		case unicode.IsLower(c):
+			seen["lower"] = true
+		case unicode.IsUpper(c):
+			seen["upper"] = true
+		case unicode.IsNumber(c):
+			seen["number"] = true
+		case c < unicode.MaxASCII:
+			seen["symbol"] = true
+		default:
+			seen["other"] = true
+		}
+
+		if i == 0 {
+			continue
+		}
+
+		prev, cur := unicode.ToLower(runes[i-1]), unicode.ToLower(c)
+		switch {
+		case prev == cur:
+			weight[i] = 0.25
+		case (cur-prev == 1 || prev-cur == 1) && sameClass(prev, cur):
+			weight[i] = 0.25
+		case isKeyboardNeighbour(prev, cur):
+			weight[i] = 0.5
+		}
+	}
+
+	for class, size := range map[string]float64{"lower": 26, "upper": 26, "number": 10, "symbol": 33, "other": 100} {
+		if seen[class] {
+			pool += size
+		}
+	}
+
+	words := append([]string{}, commonPasswordWords...)
+	words = append(words, strings.Fields(strings.ToLower(userInfo.FullName))...)
+	if digits := onlyDigits(userInfo.PhoneNumber); len(digits) >= 6 {
+		words = append(words, digits, digits[len(digits)-6:])
+	}
+
+	runeLower := []rune(lower)
+	for _, word := range words {
+		wordRunes := []rune(word)
+		if len(wordRunes) < 3 {
+			continue
+		}
+		for start := 0; start+len(wordRunes) <= len(runeLower); start++ {
+			if string(runeLower[start:start+len(wordRunes)]) != word {
+				continue
+			}
+			// a dictionary word counts as a single guess-worthy token
+			for i := start; i < start+len(wordRunes); i++ {
+				weight[i] = 0
+			}
+			weight[start] = 1
+		}
+	}
+
+	var effectiveLength float64
+	for _, w := range weight {
+		effectiveLength += w
+	}
+
+	log10Guesses := effectiveLength * math.Log10(pool)
+
+	switch {
+	case log10Guesses < 3:
+		return 0
+	case log10Guesses < 6:
+		return 1
+	case log10Guesses < 8:
+		return 2
+	case log10Guesses < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func sameClass(a, b rune) bool {
+	return (unicode.IsLetter(a) && unicode.IsLetter(b)) || (unicode.IsNumber(a) && unicode.IsNumber(b))
+}
+
+func isKeyboardNeighbour(a, b rune) bool {
+	for _, row := range keyboardRows {
+		ia, ib := strings.IndexRune(row, a), strings.IndexRune(row, b)
+		if ia >= 0 && ib >= 0 && (ia-ib == 1 || ib-ia == 1) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,74 +1,245 @@
 package utils
 
 import (
-	"errors"
+	"net/mail"
 	"strings"
 	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// FULLNAME_MAX_LENGTH is counted in user perceived characters (grapheme
+	// clusters). users.full_name is sized for FULLNAME_MAX_LENGTH *
+	// FULLNAME_MAX_RUNES_PER_CHARACTER code points, keep database.sql in sync.
+	FULLNAME_MIN_LENGTH              = 3
+	FULLNAME_MAX_LENGTH              = 60
+	FULLNAME_MAX_RUNES_PER_CHARACTER = 4
+
+	// EMAIL_MAX_LENGTH is the longest address SMTP can deliver to, keep users.email in sync
+	EMAIL_MAX_LENGTH = 254
 )
 
+// invisibleLetters are graphic according to unicode but render as blank space.
+var invisibleLetters = map[rune]bool{
+	'\u115f': true, // hangul choseong filler
+	'\u1160': true, // hangul jungseong filler
+	'\u2800': true, // braille pattern blank
+	'\u3164': true, // hangul filler
+	'\uffa0': true, // halfwidth hangul filler
+}
+
+// ValidateFullName checks the normalized form of s, see NormalizeFullName.
 func ValidateFullName(s string) error {
+	_, err := NormalizeFullName(s)
+	return err
+}
+
+// NormalizeFullName applies NFC normalization, trims and collapses
+// whitespace and returns the name as it should be stored. Names with control
+// or invisible characters, or outside the length limits, are rejected.
+func NormalizeFullName(s string) (string, error) {
 	var (
-		totalChar = len(s)
+		runes        = []rune(norm.NFC.String(s))
+		b            strings.Builder
+		pendingSpace bool
 	)
 
-	if totalChar < 3 || totalChar > 60 {
-		return errors.New("must be at minimum 3 characters and maximum 60 characters")
+	for i, c := range runes {
+		switch {
+		case unicode.IsSpace(c):
+			pendingSpace = b.Len() > 0
+			continue
+		case isJoinerBetweenLetters(runes, i):
+		case unicode.IsControl(c), unicode.In(c, unicode.Cf, unicode.Co, unicode.Cs), !unicode.IsGraphic(c), invisibleLetters[c]:
+			return "", NewValidationError(CODE_FULL_NAME_INVALID_CHARACTERS, nil)
+		}
+
+		if pendingSpace {
+			b.WriteRune(' ')
+			pendingSpace = false
+		}
+		b.WriteRune(c)
 	}
 
-	return nil
-}
+	name := b.String()
 
-func ValidatePhoneNumbers(s string) error {
 	var (
-		totalChar = len(s)
-		err       = make([]string, 0)
+		totalChar = uniseg.GraphemeClusterCount(name)
+		params    = map[string]interface{}{"min": FULLNAME_MIN_LENGTH, "max": FULLNAME_MAX_LENGTH}
 	)
 
-	if totalChar < 10 || totalChar > 13 {
-		err = append(err, "must be at minimum 10 characters and maximum 13 characters")
+	if totalChar < FULLNAME_MIN_LENGTH {
+		return "", NewValidationError(CODE_FULL_NAME_TOO_SHORT, params)
 	}
 
-	if totalChar > 2 && !(s[0] == '+' && s[1] == '6' && s[2] == '2') {
-		err = append(err, "must start with the Indonesia country code “+62”")
+	if totalChar > FULLNAME_MAX_LENGTH || utf8.RuneCountInString(name) > FULLNAME_MAX_LENGTH*FULLNAME_MAX_RUNES_PER_CHARACTER {
+		return "", NewValidationError(CODE_FULL_NAME_TOO_LONG, params)
 	}
 
-	if len(err) == 0 {
-		return nil
+	return name, nil
+}
+
+// isJoinerBetweenLetters allows the zero width (non-)joiner some scripts,
+// e.g. Persian or Devanagari, need inside a word.
+func isJoinerBetweenLetters(runes []rune, i int) bool {
+	if runes[i] != '\u200c' && runes[i] != '\u200d' {
+		return false
 	}
 
-	return errors.New(strings.Join(err, " & "))
+	return i > 0 && i < len(runes)-1 &&
+		(unicode.IsLetter(runes[i-1]) || unicode.IsMark(runes[i-1])) &&
+		unicode.IsLetter(runes[i+1])
 }
 
-func ValidatePassword(s string) error {
-	var (
-		totalChar                int
-		capital, number, special bool
-		err                      = make([]string, 0)
-	)
-	for _, c := range s {
-		switch {
-		case unicode.IsNumber(c):
-			number = true
-		case unicode.IsUpper(c):
-			capital = true
-		case unicode.IsPunct(c) || unicode.IsSymbol(c):
-			special = true
-		default:
-		}
-		totalChar++
+// ValidatePhoneNumbers checks that s is a valid phone number from one of the
+// allowed countries, see PhoneNumberPolicy.
+func ValidatePhoneNumbers(s string) error {
+	_, err := GetPhoneNumberPolicy().Normalize(s)
+	return err
+}
+
+// NormalizePhoneNumber returns s in E.164 format, the format phone numbers
+// are stored and looked up in.
+func NormalizePhoneNumber(s string) (string, error) {
+	return GetPhoneNumberPolicy().Normalize(s)
+}
+
+// NormalizeEmail trims s and lowercases its domain. Only a bare address is
+// accepted, e.g. "Name <name@example.com>" is rejected. The local part keeps
+// its case, addresses are compared case-insensitively.
+func NormalizeEmail(s string) (string, error) {
+	s = strings.TrimSpace(s)
+
+	if utf8.RuneCountInString(s) > EMAIL_MAX_LENGTH {
+		return "", NewValidationError(CODE_EMAIL_TOO_LONG, map[string]interface{}{"max": EMAIL_MAX_LENGTH})
 	}
 
-	if totalChar < 6 || totalChar > 64 {
-		err = append(err, "must be at minimum 6 characters and maximum 64 characters")
+	address, err := mail.ParseAddress(s)
+	if err != nil || address.Name != "" || address.Address != s {
+		return "", NewValidationError(CODE_EMAIL_INVALID, nil)
 	}
 
-	if !(number && capital && special) {
-		err = append(err, "must containing at least 1 capital characters AND 1 number AND 1 special (non alpha-numeric) characters")
+	localPart, domain, ok := cutLast(s, "@")
+	if !ok || !strings.Contains(domain, ".") || strings.HasPrefix(domain, "[") {
+		return "", NewValidationError(CODE_EMAIL_INVALID, nil)
 	}
 
-	if len(err) == 0 {
-		return nil
+	return localPart + "@" + strings.ToLower(domain), nil
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
 	}
+	return s, "", false
+}
 
-	return errors.New(strings.Join(err, " & "))
+// ValidatePassword validates s against the configured password policy.
+func ValidatePassword(s string, userInfo PasswordUserInfo) error {
+	return GetPasswordPolicy().Validate(s, userInfo)
+}
+
+// Validation error codes are part of the API contract, see ValidationErrorCode
+// in api.yml. Never change the value of an existing code.
+const (
+	CODE_FULL_NAME_TOO_SHORT          = "FULL_NAME_TOO_SHORT"
+	CODE_FULL_NAME_TOO_LONG           = "FULL_NAME_TOO_LONG"
+	CODE_FULL_NAME_INVALID_CHARACTERS = "FULL_NAME_INVALID_CHARACTERS"
+
+	CODE_PHONE_NUMBER_REQUIRED             = "PHONE_NUMBER_REQUIRED"
+	CODE_PHONE_NUMBER_INVALID_CHARACTERS   = "PHONE_NUMBER_INVALID_CHARACTERS"
+	CODE_PHONE_NUMBER_INVALID_COUNTRY_CODE = "PHONE_NUMBER_INVALID_COUNTRY_CODE"
+	CODE_PHONE_NUMBER_MISSING_COUNTRY_CODE = "PHONE_NUMBER_MISSING_COUNTRY_CODE"
+	CODE_PHONE_NUMBER_COUNTRY_NOT_ALLOWED  = "PHONE_NUMBER_COUNTRY_NOT_ALLOWED"
+	CODE_PHONE_NUMBER_INVALID_LENGTH       = "PHONE_NUMBER_INVALID_LENGTH"
+
+	CODE_PASSWORD_REQUIRED              = "PASSWORD_REQUIRED"
+	CODE_PASSWORD_TOO_SHORT             = "PASSWORD_TOO_SHORT"
+	CODE_PASSWORD_TOO_LONG              = "PASSWORD_TOO_LONG"
+	CODE_PASSWORD_MISSING_UPPERCASE     = "PASSWORD_MISSING_UPPERCASE"
+	CODE_PASSWORD_MISSING_LOWERCASE     = "PASSWORD_MISSING_LOWERCASE"
+	CODE_PASSWORD_MISSING_NUMBER        = "PASSWORD_MISSING_NUMBER"
+	CODE_PASSWORD_MISSING_SYMBOL        = "PASSWORD_MISSING_SYMBOL"
+	CODE_PASSWORD_CONTAINS_PHONE_NUMBER = "PASSWORD_CONTAINS_PHONE_NUMBER"
+	CODE_PASSWORD_CONTAINS_NAME         = "PASSWORD_CONTAINS_NAME"
+	CODE_PASSWORD_BREACHED              = "PASSWORD_BREACHED"
+	CODE_PASSWORD_TOO_WEAK              = "PASSWORD_TOO_WEAK"
+	CODE_PASSWORD_INCORRECT             = "PASSWORD_INCORRECT"
+	CODE_PASSWORD_RECENTLY_USED         = "PASSWORD_RECENTLY_USED"
+
+	CODE_EMAIL_INVALID  = "EMAIL_INVALID"
+	CODE_EMAIL_TOO_LONG = "EMAIL_TOO_LONG"
+
+	CODE_LOGIN_IDENTIFIER_REQUIRED  = "LOGIN_IDENTIFIER_REQUIRED"
+	CODE_LOGIN_IDENTIFIER_AMBIGUOUS = "LOGIN_IDENTIFIER_AMBIGUOUS"
+
+	CODE_VERIFICATION_CODE_REQUIRED = "VERIFICATION_CODE_REQUIRED"
+
+	CODE_IDENTITY_TYPE_NOT_SUPPORTED = "IDENTITY_TYPE_NOT_SUPPORTED"
+
+	CODE_LANGUAGE_NOT_SUPPORTED = "LANGUAGE_NOT_SUPPORTED"
+
+	CODE_PHONE_PREFIX_INVALID  = "PHONE_PREFIX_INVALID"
+	CODE_STATUS_NOT_SUPPORTED  = "STATUS_NOT_SUPPORTED"
+	CODE_SORT_NOT_SUPPORTED    = "SORT_NOT_SUPPORTED"
+	CODE_LIMIT_OUT_OF_RANGE    = "LIMIT_OUT_OF_RANGE"
+	CODE_CREATED_RANGE_INVALID = "CREATED_RANGE_INVALID"
+	CODE_CURSOR_INVALID        = "CURSOR_INVALID"
+
+	CODE_REASON_REQUIRED = "REASON_REQUIRED"
+	CODE_REASON_TOO_LONG = "REASON_TOO_LONG"
+
+	CODE_FORMAT_NOT_SUPPORTED = "FORMAT_NOT_SUPPORTED"
+
+	CODE_AVATAR_REQUIRED           = "AVATAR_REQUIRED"
+	CODE_AVATAR_TOO_LARGE          = "AVATAR_TOO_LARGE"
+	CODE_AVATAR_TYPE_NOT_SUPPORTED = "AVATAR_TYPE_NOT_SUPPORTED"
+	CODE_AVATAR_DIMENSIONS_INVALID = "AVATAR_DIMENSIONS_INVALID"
+
+	CODE_ATTRIBUTES_NOT_OBJECT    = "ATTRIBUTES_NOT_OBJECT"
+	CODE_ATTRIBUTE_INVALID        = "ATTRIBUTE_INVALID"
+	CODE_ATTRIBUTE_READ_ONLY      = "ATTRIBUTE_READ_ONLY"
+	CODE_ATTRIBUTE_SCHEMA_INVALID = "ATTRIBUTE_SCHEMA_INVALID"
+
+	CODE_VALUE_NOT_STRING = "VALUE_NOT_STRING"
+)
+
+// ValidationError is a single violated rule. Code and Params are meant for
+// clients, Message is the English description from the message catalog.
+type ValidationError struct {
+	Code    string
+	Message string
+	Params  map[string]interface{}
+}
+
+func NewValidationError(code string, params map[string]interface{}) *ValidationError {
+	return &ValidationError{
+		Code:    code,
+		Message: Localize(LANGUAGE_EN, code, params),
+		Params:  params,
+	}
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// LocalizedMessage returns Message translated to lang.
+func (e *ValidationError) LocalizedMessage(lang string) string {
+	return Localize(lang, e.Code, e.Params)
+}
+
+// ValidationErrors holds every rule a value failed so that each one can be
+// reported separately.
+type ValidationErrors []error
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, 0, len(v))
+	for _, err := range v {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, " & ")
 }